@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"plivo/internal/selfcheck"
+	"time"
+)
+
+// runCheck implements the `plivo check` subcommand. It runs the self-check
+// sequence against a running broker, or against an ephemeral in-process one
+// when no URL is given, and returns the process exit code.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	baseURL := fs.String("url", "", "Base URL of a running broker (default: start an ephemeral in-process broker)")
+	apiKey := fs.String("api-key", os.Getenv("API_KEY"), "API key for authentication")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout for each check step")
+	fs.Parse(args)
+
+	opts := selfcheck.Options{
+		BaseURL: *baseURL,
+		APIKey:  *apiKey,
+		Timeout: *timeout,
+	}
+
+	if opts.BaseURL == "" {
+		cfg := config.DefaultConfig()
+		cfg.Security.APIKey = opts.APIKey
+
+		addr, _, stop, err := startEphemeralBroker(cfg)
+		if err != nil {
+			log.Printf("selfcheck: failed to start ephemeral broker: %v", err)
+			return 1
+		}
+		defer stop()
+		opts.BaseURL = "http://" + addr
+	}
+
+	log.Printf("selfcheck: running against %s", opts.BaseURL)
+	if err := selfcheck.Run(opts); err != nil {
+		log.Printf("selfcheck: FAILED: %v", err)
+		return 1
+	}
+
+	log.Println("selfcheck: OK")
+	return 0
+}
+
+// startEphemeralBroker starts an in-process broker on a random loopback port
+// and returns its address, its hub and a function that stops it
+func startEphemeralBroker(cfg *config.Config) (string, *pubsub.Hub, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	authService, err := auth.NewService(cfg.Security)
+	if err != nil {
+		listener.Close()
+		return "", nil, nil, err
+	}
+
+	hub := pubsub.NewHubWithOptions(pubsub.NewHubOptions(cfg.PubSub))
+	go hub.Run()
+
+	router, _ := newRouter(hub, cfg, authService)
+	server := &http.Server{Handler: router}
+	go server.Serve(listener)
+
+	stop := func() {
+		server.Close()
+		hub.Shutdown()
+	}
+	return listener.Addr().String(), hub, stop, nil
+}
@@ -24,97 +24,268 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/health": {
+        "/acl": {
+            "get": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Get the access control list in force: the roles, each granting subscribe, publish or admin permissions on topic patterns, and the tenants bound to them. Without an ACL every authenticated caller may do anything.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "summary": "Get the ACL",
+                "responses": {
+                    "200": {
+                        "description": "The ACL, if one is enforced",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ACLResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Replace the access control list and start enforcing it. Roles grant permissions on topic patterns: an exact name, a prefix ending in * or * for every topic. subscribe covers subscribing and reading a topic, publish covers publishing, and admin covers creating, reconfiguring, draining and deleting it and implies the others. Bindings map tenant names, or * for every caller, to roles; callers with the shared API key hold only the roles bound to *. Callers with the admin credential are never restricted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "summary": "Set the ACL",
+                "parameters": [
+                    {
+                        "description": "Roles and bindings",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/auth.ACL"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The ACL now enforced",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ACLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, unknown permission, undefined role or unknown tenant",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Stop enforcing the access control list, so every authenticated caller may do anything again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "acl"
+                ],
+                "summary": "Remove the ACL",
+                "responses": {
+                    "200": {
+                        "description": "No ACL is enforced",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ACLResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/activity": {
             "get": {
-                "description": "Get system health status including uptime and basic metrics",
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Summarize the admin operations made on the broker within a window ending now, for operations dashboards: topic creates, updates, deletes, restores, drains, transfers and replays, schema and consumer group offset changes, pauses and resumes, ACL changes and config reloads. Counts are given per operation, caller and topic, with how many attempts failed, and as a time series of 60 equal intervals. Operations are kept for 24 hours.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "system"
                 ],
-                "summary": "Health check",
+                "summary": "Summarize admin activity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "How far back to summarize, as a Go duration up to 24h (default: 1h)",
+                        "name": "window",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "System health status",
+                        "description": "Activity summary",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handlers.ActivitySummary"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid window",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     }
                 }
             }
         },
-        "/stats": {
+        "/admin/config": {
             "get": {
                 "security": [
                     {
-                        "ApiKeyAuth": []
+                        "AdminKeyAuth": []
                     }
                 ],
-                "description": "Get detailed system statistics including topic metrics and performance data",
+                "description": "Get the configuration the broker is running with, including settings reloaded on SIGHUP, to debug its behavior without shell access to the host. Each setting is listed by its config file key with its value, the flag and environment variable that set it, and its source: default, file, env or flag, the highest of those that set it. API, admin and tenant keys and the alert webhook URL are redacted.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "system"
                 ],
-                "summary": "System statistics",
+                "summary": "Get the running configuration",
                 "responses": {
                     "200": {
-                        "description": "System statistics",
+                        "description": "Running configuration",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handlers.ConfigDump"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid or missing API key",
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/client.js": {
+            "get": {
+                "description": "JavaScript client for the WebSocket protocol with reconnect, resubscribe and resume from the last delivered sequence. Exposes a PubSubClient global (or CommonJS export).",
+                "produces": [
+                    "application/javascript"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Browser client library",
+                "responses": {
+                    "200": {
+                        "description": "Client script",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "304": {
+                        "description": "Not modified"
                     }
                 }
             }
         },
-        "/topics": {
+        "/clients/{id}": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Get a list of all available topics with their subscriber counts",
+                "description": "Describe a connected client by the ID from its welcome frame: its subscriptions, send queue depth and the round-trip times of the server's keepalive pings, to spot clients on degraded networks. Stream subscribers (SSE, MQTT, sinks) have no round-trip times.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "topics"
+                    "system"
+                ],
+                "summary": "Get client details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List all topics",
                 "responses": {
                     "200": {
-                        "description": "List of topics",
+                        "description": "Client details",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/pubsub.ClientInfo"
                         }
                     },
                     "401": {
                         "description": "Unauthorized - invalid or missing API key",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - no connected client has this ID",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/clients/{id}/inbox": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create a new pub/sub topic for message publishing and subscription",
+                "description": "Publish a directed message to ~inbox/{id}, the private reply topic the broker provisions for every WebSocket client and removes when it disconnects. Only the client may subscribe to its inbox; anyone may publish to it. Responds as /topics/{topic}/publish does.",
                 "consumes": [
                     "application/json"
                 ],
@@ -122,112 +293,3454 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "topics"
+                    "messages"
                 ],
-                "summary": "Create a new topic",
+                "summary": "Publish to a client's inbox",
                 "parameters": [
                     {
-                        "description": "Topic creation request",
-                        "name": "request",
+                        "type": "string",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message to publish",
+                        "name": "message",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.CreateTopicRequest"
+                            "$ref": "#/definitions/pubsub.MessageData"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Topic created successfully",
+                    "200": {
+                        "description": "Message published",
                         "schema": {
                             "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Message queued behind a hub backlog",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid JSON or missing topic name",
+                        "description": "Bad request - invalid JSON, invalid message ID, TTL, headers or content type",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     },
                     "401": {
                         "description": "Unauthorized - invalid or missing API key",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     },
-                    "409": {
-                        "description": "Conflict - topic already exists",
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - no connected WebSocket client has this ID",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "413": {
+                        "description": "Payload exceeds the maximum message size",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "503": {
+                        "description": "Hub saturated - retry after the Retry-After interval, or the request timed out waiting for the hub",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     }
                 }
             }
         },
-        "/topics/{topic}": {
-            "delete": {
+        "/cluster/snapshot": {
+            "get": {
                 "security": [
                     {
-                        "ApiKeyAuth": []
+                        "AdminKeyAuth": []
                     }
                 ],
-                "description": "Delete a topic and disconnect all its subscribers",
+                "description": "Export every topic's metadata (sequence, replay limits, weight, schemas, consumer group offsets) and retained messages. A node started with -warm-from fetches this from a peer before accepting connections.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "topics"
+                    "system"
                 ],
-                "summary": "Delete a topic",
+                "summary": "Cluster snapshot",
+                "responses": {
+                    "200": {
+                        "description": "Topics and retained messages",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.Snapshot"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Get system health status including uptime and basic metrics. With verbose=true it also reports goroutines, heap and client teardown checks (requires authentication when an API key is set).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Health check",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Topic name",
-                        "name": "topic",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Include runtime and leak detection checks",
+                        "name": "verbose",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Topic deleted successfully",
+                        "description": "System health status",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/handlers.HealthResponse"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized - invalid or missing API key",
+                        "description": "Unauthorized",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/pause": {
+            "get": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Report whether the hub is paused for maintenance and, if so, how it treats publishes, why, until when, and how many publishes it is holding back.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get hub pause",
+                "responses": {
+                    "200": {
+                        "description": "Pause status",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PauseStatus"
                         }
                     },
-                    "404": {
-                        "description": "Not found - topic does not exist",
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/pubsub.ErrorData"
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "handlers.CreateTopicRequest": {
-            "type": "object",
-            "properties": {
-                "name": {
-                    "type": "string"
-                }
-            }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Pause publishes while storage is compacted or snapshotted. In buffer mode publishes are still accepted but held in their topics' backlogs until the hub resumes, so a full backlog answers 503 HUB_SATURATED; in reject mode they fail with 503 HUB_PAUSED. The mode defaults to -pause-mode. Every client gets a hub_paused info frame, and a hub_resumed one on resume. With timeout_ms the hub resumes by itself; with compact, storage is compacted once the pause takes hold. Pausing a paused hub replaces the pause.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Pause the hub",
+                "parameters": [
+                    {
+                        "description": "Mode, reason, timeout and whether to compact storage",
+                        "name": "pause",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.PauseOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Hub paused",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PauseStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, unknown mode or negative timeout",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "500": {
+                        "description": "Storage compaction failed; the hub stays paused",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "End the hub's pause, delivering the publishes it held back, and tell every client with a hub_resumed info frame. Resuming a running hub does nothing.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Resume the hub",
+                "responses": {
+                    "200": {
+                        "description": "Hub running, with the pause that ended",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PauseStatus"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Report whether the broker is ready to serve. While the storage backend set with -data-dir is failing, the broker keeps serving pub/sub from memory and reports status degraded, with the failure, how many changes wait to be written and how many retries failed; the changes are written behind once storage recovers. Degraded brokers still answer 200, so load balancers keep routing to them; alert on the status instead.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "Readiness",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ReadyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get detailed system statistics including topic metrics and performance data. Tenants also get their usage: topics owned out of -tenant-max-topics, subscribers, messages and retained messages. With -tenant-namespaces, tenants only see the topics in their namespace, and neither server-wide retention nor client round-trip times.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "System statistics",
+                "responses": {
+                    "200": {
+                        "description": "System statistics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a list of all available topics with their subscriber counts. With -tenant-namespaces, tenants only see the topics in their namespace.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "List all topics",
+                "responses": {
+                    "200": {
+                        "description": "List of topics",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ListTopicsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a new pub/sub topic for message publishing and subscription. Topics created with a tenant's API key are owned by that tenant: only it or an admin may delete, drain or reconfigure them. Topics created with a key_id are encrypted: they only accept application/octet-stream ciphertext, and subscribers must present the key ID. Topics created with enrich stamp every published message with _meta.* headers naming the accepting node, receive time and publisher. A retention policy sets how many messages the topic retains for replay (max_messages, up to 10000) and expires them max_age_ms after publishing. Topics with a dead_letter topic, created if it doesn't exist, publish every event a subscriber loses to a full queue, its max_latency or its TTL there, with _dlq.* headers saying why. Partitioned topics route each message to a partition by its key and share the partitions among each consumer group's members, range or round-robin, rebalancing as members join and leave. Topics with a shadow copy that percentage of their publishes, sampled at random, into the shadow topic, created if it doesn't exist, with _shadow.* headers naming the original topic and sequence. Topics with sample_every keep one in that many publishes, the newest 100, for admins to inspect at GET /topics/{topic}/samples. Topics with dedup_window_ms remember the IDs of published messages for that long, and drop a publish repeating one, acknowledging it with status duplicate, so publishers can retry safely. Topics created with a schema, a JSON Schema document, register it as schema version 1 and reject publishes whose JSON payload doesn't validate against their latest schema with SCHEMA_VIOLATION.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Create a new topic",
+                "parameters": [
+                    {
+                        "description": "Topic creation request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateTopicRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Topic created successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, missing or reserved topic name, invalid replay limits, weight, key ID, retention policy, dead-letter topic, partitioning, shadow, sample_every, dedup_window_ms or JSON Schema",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL on the topic or its shadow topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - topic already exists, or was deleted and can still be restored",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get statistics for a single topic including dropped deliveries, last publish time and replay buffer occupancy",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Get topic details",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic statistics",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.TopicStats"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "The topic's settings revision, for If-Match on PATCH"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a topic and disconnect all its subscribers. Owned topics may only be deleted by their owner or an admin. Within the server's trash window the topic can be brought back with POST /topics/{topic}/restore, and publishes, subscribes and re-creation fail with TOPIC_DELETED; the response's purge_at says until when. With purge=true the topic, live or deleted, is removed for good at once.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Delete a topic",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Delete for good, skipping the trash window",
+                        "name": "purge",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic deleted successfully",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Change an existing topic's replay limits, retention policy, weight, enrichment, labels, dead-letter topic, shadow, sampling rate, dedup window or schema without deleting it, so its retained messages, subscribers and consumer groups are kept. Only the fields present in the body change; labels replace the topic's labels and an empty object clears them, and a schema registers a new schema version. Owned topics may only be updated by their owner or an admin. Send the ETag from GET /topics/{topic} as If-Match to apply the update only if nobody changed the topic since; the response carries the new ETag.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Update topic settings",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision the update was made against, as returned in ETag",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Settings to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.TopicUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.TopicStats"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "The topic's new settings revision"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, If-Match, replay limits, retention policy, weight, labels, dead-letter topic, shadow, sample_every, dedup_window_ms or JSON Schema",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL on it or its shadow topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition failed - the topic changed since the If-Match revision",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/drain": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stop new subscriptions to a topic, for renames and splits. Subscribers get a topic_draining info frame naming the optional replacement topic; with migrate they are moved to the replacement and get topic_migrated instead. New subscribes fail with TOPIC_DRAINING. Publishes are still accepted.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Drain a topic",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Replacement topic and whether to migrate subscribers",
+                        "name": "drain",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.DrainOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic draining",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.DrainResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, reserved topic, or missing, draining or same replacement",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Subscribe to a topic over server-sent events, for browser and curl consumers that can't use WebSockets. Each frame a WebSocket subscriber would receive, starting with the welcome info frame, is sent as the data of an SSE message; events carry their topic sequence as the SSE id. Reconnecting with Last-Event-ID (or last_event_id) replays every retained message after that sequence; otherwise last_n replays the newest retained messages. Browsers' EventSource can't set headers, so the API key may be passed as api_key. Consumers that fall a full queue behind are sent SLOW_CONSUMER and disconnected.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Stream events (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Retained messages to replay before live events",
+                        "name": "last_n",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this sequence, for clients that can't set Last-Event-ID",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Resume after this sequence",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated payload fields to deliver",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key ID of an encrypted topic",
+                        "name": "key_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Consumer group to join: its members share the topic's events round-robin",
+                        "name": "group",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Deliver only events whose content meets this expression, such as payload.status == \\",
+                        "name": "where",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "API key, for clients that can't set headers",
+                        "name": "api_key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid last_n, Last-Event-ID, fields, group or where",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - key_id does not match the encrypted topic, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - topic is draining or deleted",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "503": {
+                        "description": "Server is shutting down",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/groups/{group}/offset": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a consumer group's position in a topic: the last delivered sequence, the topic's current sequence, lag, the oldest retained sequence and connected members",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Get consumer group offset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Consumer group name",
+                        "name": "group",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Group offset",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.GroupOffset"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic or group does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Move a consumer group to an offset (creating the group if needed). Members that subscribe afterwards resume after the offset, so rewinding replays retained messages for reprocessing. The group must have no connected members.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Set consumer group offset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Consumer group name",
+                        "name": "group",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New offset (sequence of the last message considered delivered)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.SetGroupOffsetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated group offset",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.GroupOffset"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON or offset out of range",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - group has connected members",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/messages": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a topic's retained messages, oldest first: the same replay window subscribers get with last_n. Use last_n to return only the newest messages and since to skip messages received before a time. To page through the whole retention window instead, pass since_seq and/or limit, then the next_cursor of each response as cursor until a response has none. Messages past their ttl_ms or the topic's retention max_age_ms are left out. Encrypted topics require the topic's key_id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get message history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Newest messages to return (default: all retained)",
+                        "name": "last_n",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only messages received at or after this time (RFC3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Paginate from this sequence on, inclusive (default: the oldest retained)",
+                        "name": "since_seq",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Paginate with at most this many messages per page (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Continue pagination from a previous response's next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key ID of an encrypted topic",
+                        "name": "key_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retained messages",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TopicMessages"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid last_n, since, since_seq, limit or cursor, or last_n combined with pagination",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - key_id does not match the encrypted topic, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Export a single topic's counters and gauges in the OpenMetrics text format, so a team can scrape its own topic from shared infrastructure. Every sample is labelled with the topic name.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Get topic metrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OpenMetrics exposition",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/publish": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Publish a message to a topic without a WebSocket connection. Responds 200 when the hub is keeping up, 202 with the queue position when the topic's publish backlog exceeds the queued threshold, and 503 with Retry-After when the backlog exceeds the reject threshold. Backlogs are per topic, so a burst on one topic does not slow publishes to others. A message with ttl_ms is neither replayed nor delivered once it expires. On topics with a dedup_window_ms, a message whose ID the topic already published within the window is dropped and answered 200 with status duplicate.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Publish a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message to publish",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.MessageData"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Message published, or dropped as a duplicate",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "202": {
+                        "description": "Message queued behind a hub backlog",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, invalid message ID, TTL, headers or content type, plaintext on an encrypted topic, a payload violating the topic schema (SCHEMA_VIOLATION), or reserved topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - topic was deleted and can still be restored",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "413": {
+                        "description": "Payload exceeds the maximum message size",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "503": {
+                        "description": "Hub saturated or paused - retry after the Retry-After interval, or the request timed out waiting for the hub",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/publish/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Publish up to 100 messages to a topic in one request. The batch is atomic: either every message is queued for fan-out, one after another with no other publish to the topic between them, or none is. The response lists each message's ID and status in order; when any message is invalid the batch is rejected with the first invalid message's error, and its results say which messages were rejected and which were aborted with them. Messages repeating an ID the topic published within its dedup window, or earlier in the batch, are dropped with status duplicate. Responds 202 and 503 as /topics/{topic}/publish does, for the batch as a whole.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Publish a batch of messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Messages to publish",
+                        "name": "messages",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/pubsub.MessageData"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Messages published",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchPublishResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Messages queued behind a hub backlog",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BatchPublishResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, an empty or oversized batch, an invalid message or one violating the topic schema, or reserved topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict - topic was deleted and can still be restored",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "413": {
+                        "description": "A payload exceeds the maximum message size",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "503": {
+                        "description": "Hub saturated or paused - retry after the Retry-After interval, or the request timed out waiting for the hub",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/replay": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Re-publish a topic's retained messages within a sequence and/or receive time range into a target topic, oldest first, for reprocessing after consumer bugs. Messages are selected when the request is made and published in the background at rate messages per second (default: the server's replay rate), with new sequences and _replay.topic and _replay.sequence headers naming their origin. Only the target's owner or an admin may replay into an owned topic. Encrypted topics can only be replayed into topics with the same key ID.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Replay messages into another topic",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Source topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Target topic, range and rate",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ReplayIntoRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Replay started",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ReplayIntoResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, missing, reserved or same target topic, or invalid range or rate",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - target topic is owned by another tenant or encrypted with a different key, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - source or target topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bring back a topic deleted within the server's trash window, with its sequence, retained messages, schemas, consumer group offsets and owner. Subscribers detached by the delete must subscribe again. Owned topics may only be restored by their owner or an admin.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Restore a deleted topic",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic restored",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - no deleted topic awaiting purge",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/samples": {
+            "get": {
+                "security": [
+                    {
+                        "AdminKeyAuth": []
+                    }
+                ],
+                "description": "Get the newest publishes a topic with sample_every sampled, up to 100, oldest first, so operators can see the shape of its payloads without subscribing to it. One in every sample_every publishes is sampled, counted by sequence. Changing sample_every drops the samples taken at the old rate.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Get sampled messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sampled messages",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TopicSamples"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing admin credential",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/schema": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get the latest (or a specific) schema version registered for a topic",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schemas"
+                ],
+                "summary": "Get topic schema",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Schema version (default: latest)",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Schema version",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.TopicSchema"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid version",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic or schema does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Register a JSON Schema document for a topic. Each call creates a new version; events whose payload validates against the latest version are stamped with its schema_version.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schemas"
+                ],
+                "summary": "Register topic schema",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "JSON Schema document",
+                        "name": "schema",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Registered schema version",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.TopicSchema"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON or JSON Schema",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/state": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get every key of a topic's state, the same snapshot a new subscriber receives in a state frame before live events.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "state"
+                ],
+                "summary": "Get topic state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic state",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TopicState"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/state/{key}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get one key of a topic's state",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "state"
+                ],
+                "summary": "Get topic state key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "State entry",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.StateEntry"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic or key does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Set a key of a topic's state to any JSON value, up to 64 KiB, and send the change to the topic's subscribers in a state_update frame. A null value deletes the key. A topic holds up to 1000 keys. State set over REST is never ephemeral.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "state"
+                ],
+                "summary": "Set topic state key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "JSON value",
+                        "name": "value",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Change sent to subscribers",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.StateUpdate"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON, key or value, a reserved topic, or the topic's state is full",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete a key of a topic's state and send the deletion to the topic's subscribers in a state_update frame. Deleting a missing key succeeds.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "state"
+                ],
+                "summary": "Delete topic state key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Change sent to subscribers",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.StateUpdate"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid key or a reserved topic",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/topics/{topic}/transfer": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Hand a topic to another tenant, which may then delete, drain and reconfigure it. Only the current owner or an admin may transfer a topic; unowned topics may be claimed by any caller. The new owner must be a configured tenant.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "topics"
+                ],
+                "summary": "Transfer topic ownership",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Topic name",
+                        "name": "topic",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New owner",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TransferTopicRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Topic transferred",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid JSON or unknown tenant",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing API key",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden - topic is owned by another tenant, or not permitted by the ACL",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found - topic does not exist",
+                        "schema": {
+                            "$ref": "#/definitions/pubsub.ErrorData"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Get the semantic version, git commit, build date and Go version of the running server",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "Version information",
+                "responses": {
+                    "200": {
+                        "description": "Build information",
+                        "schema": {
+                            "$ref": "#/definitions/version.Info"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "auth.ACL": {
+            "type": "object",
+            "properties": {
+                "bindings": {
+                    "description": "Bindings maps subjects to the roles they hold",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "roles": {
+                    "description": "Roles maps role names to their rules",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/auth.Rule"
+                        }
+                    }
+                }
+            }
+        },
+        "auth.Permission": {
+            "type": "string",
+            "enum": [
+                "subscribe",
+                "publish",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "PermSubscribe",
+                "PermPublish",
+                "PermAdmin"
+            ]
+        },
+        "auth.Rule": {
+            "type": "object",
+            "properties": {
+                "permissions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/auth.Permission"
+                    }
+                },
+                "topics": {
+                    "type": "string"
+                }
+            }
+        },
+        "config.Setting": {
+            "type": "object",
+            "properties": {
+                "env": {
+                    "type": "string"
+                },
+                "flag": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key is the config file key, such as server.read_timeout",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Source is default, file, env or flag",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Value is the effective value; durations are strings such as \"10s\""
+                }
+            }
+        },
+        "handlers.ACLResponse": {
+            "type": "object",
+            "properties": {
+                "acl": {
+                    "$ref": "#/definitions/auth.ACL"
+                },
+                "enabled": {
+                    "description": "Enabled is set while an ACL is enforced",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.ActivityBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ActivityCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ActivitySummary": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "Buckets splits the window into equal intervals, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ActivityBucket"
+                    }
+                },
+                "callers": {
+                    "description": "Callers counts operations by who made them: \"admin\", a tenant,\n\"api_key\" for the shared key or \"unauthenticated\"; config reloads are\nmade by \"sighup\"",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "operations": {
+                    "description": "Operations counts each operation by name",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handlers.ActivityCount"
+                    }
+                },
+                "per_minute": {
+                    "description": "PerMinute is the mean rate of operations over the window",
+                    "type": "number"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "topics": {
+                    "description": "Topics lists the topics operated on most, busiest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.TopicActivity"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "until": {
+                    "type": "string"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BatchPublishResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "queue_position": {
+                    "description": "QueuePosition is how many of the topic's publishes were queued ahead\nof the batch, set when queued",
+                    "type": "integer"
+                },
+                "results": {
+                    "description": "Results has each message's ID and status, in order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.BatchResult"
+                    }
+                },
+                "status": {
+                    "description": "Status is published, or queued behind a hub backlog",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ConfigDump": {
+            "type": "object",
+            "properties": {
+                "file": {
+                    "description": "File is the config file the configuration was loaded from",
+                    "type": "string"
+                },
+                "settings": {
+                    "description": "Settings lists every setting in config file order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/config.Setting"
+                    }
+                }
+            }
+        },
+        "handlers.CreateTopicRequest": {
+            "type": "object",
+            "properties": {
+                "dead_letter": {
+                    "description": "DeadLetter names the topic events subscribers lose are published to,\nconventionally \u003ctopic\u003e.dlq",
+                    "type": "string"
+                },
+                "dedup_window_ms": {
+                    "description": "DedupWindowMs drops publishes whose message ID repeats one published\nto the topic within that many milliseconds, up to 24 hours (0 = none)",
+                    "type": "integer"
+                },
+                "enrich": {
+                    "description": "Enrich stamps published messages with server metadata headers",
+                    "type": "boolean"
+                },
+                "key_id": {
+                    "description": "KeyID marks the topic encrypted: it only takes ciphertext, and\nsubscribers must present this key ID",
+                    "type": "string"
+                },
+                "labels": {
+                    "description": "Labels are free-form key/value pairs for operators' bookkeeping",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "partitioning": {
+                    "description": "Partitioning splits the topic into partitions by message key, shared\namong consumer group members",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Partitioning"
+                        }
+                    ]
+                },
+                "replay": {
+                    "description": "Replay overrides the server-wide last_n default and cap for this topic",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.ReplayLimits"
+                        }
+                    ]
+                },
+                "retention": {
+                    "description": "Retention bounds how many messages the topic retains for replay and\nfor how long",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.RetentionPolicy"
+                        }
+                    ]
+                },
+                "sample_every": {
+                    "description": "SampleEvery keeps one in every SampleEvery publishes for admins to\ninspect (0 = none)",
+                    "type": "integer"
+                },
+                "schema": {
+                    "description": "Schema is a JSON Schema document registered as the topic's schema\nversion 1; publishes whose payload doesn't validate are rejected",
+                    "type": "object"
+                },
+                "shadow": {
+                    "description": "Shadow copies a percentage of publishes into a shadow topic, for\ntrying new consumers against production traffic",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Shadow"
+                        }
+                    ]
+                },
+                "weight": {
+                    "description": "Weight is the topic's share of fan-out relative to other busy topics",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "paused": {
+                    "description": "Paused is set while the hub is paused for maintenance",
+                    "type": "boolean"
+                },
+                "runtime": {
+                    "$ref": "#/definitions/pubsub.RuntimeStats"
+                },
+                "status": {
+                    "description": "Status is HealthHealthy or, when pumps outlive their clients,\nHealthDegraded; set with Runtime on verbose checks only",
+                    "type": "string"
+                },
+                "subscribers": {
+                    "type": "integer"
+                },
+                "topics": {
+                    "type": "integer"
+                },
+                "uptime_sec": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ListTopicsResponse": {
+            "type": "object",
+            "properties": {
+                "topics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.TopicSummary"
+                    }
+                }
+            }
+        },
+        "handlers.OrderingStats": {
+            "type": "object",
+            "properties": {
+                "audit": {
+                    "type": "boolean"
+                },
+                "violations": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.PauseStatus": {
+            "type": "object",
+            "properties": {
+                "pause": {
+                    "description": "Pause is the pause in effect or, when resuming, the pause that ended",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.PauseInfo"
+                        }
+                    ]
+                },
+                "paused": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.ReadyResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "description": "Status is ReadyOK or, while storage is failing, ReadyDegraded",
+                    "type": "string"
+                },
+                "storage": {
+                    "$ref": "#/definitions/pubsub.StorageHealth"
+                }
+            }
+        },
+        "handlers.StatsResponse": {
+            "type": "object",
+            "properties": {
+                "channels": {
+                    "description": "Channels are the hub's internal channel backlogs by name",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/pubsub.ChannelStats"
+                    }
+                },
+                "errors": {
+                    "description": "Errors counts error frames sent to clients by code",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "expired": {
+                    "description": "Expired counts retained messages dropped for outliving their TTL or\ntheir topic's max age",
+                    "type": "integer"
+                },
+                "lease_expiries": {
+                    "description": "LeaseExpiries counts leased subscriptions expired for want of renewal",
+                    "type": "integer"
+                },
+                "ordering": {
+                    "$ref": "#/definitions/handlers.OrderingStats"
+                },
+                "panics": {
+                    "type": "integer"
+                },
+                "retention": {
+                    "description": "Retention is the memory retained messages hold, null when the store\ndoesn't track it",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.RetentionUsage"
+                        }
+                    ]
+                },
+                "rtt": {
+                    "description": "RTT summarizes connected WebSocket clients' round-trip times",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.RTTSummary"
+                        }
+                    ]
+                },
+                "tenant": {
+                    "description": "Tenant is the calling tenant's usage, unset for other callers",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.TenantUsage"
+                        }
+                    ]
+                },
+                "topics": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handlers.TopicMetrics"
+                    }
+                }
+            }
+        },
+        "handlers.TopicActivity": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TopicMessages": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of messages returned",
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.PubSubMessage"
+                    }
+                },
+                "next_cursor": {
+                    "description": "NextCursor fetches the next page, set on paginated responses while\nmore retained messages follow",
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TopicMetrics": {
+            "type": "object",
+            "properties": {
+                "buffer_capacity": {
+                    "type": "integer"
+                },
+                "buffer_occupancy": {
+                    "type": "integer"
+                },
+                "dropped": {
+                    "type": "integer"
+                },
+                "last_publish_at": {
+                    "type": "string"
+                },
+                "messages": {
+                    "type": "integer"
+                },
+                "payload_size": {
+                    "$ref": "#/definitions/pubsub.PayloadSizeStats"
+                },
+                "retained_bytes": {
+                    "type": "integer"
+                },
+                "retention": {
+                    "$ref": "#/definitions/pubsub.RetentionPolicy"
+                },
+                "subscribers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.TopicSamples": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of samples returned",
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.PubSubMessage"
+                    }
+                },
+                "sample_every": {
+                    "description": "SampleEvery is the topic's sampling rate: one in SampleEvery\npublishes, 0 if it isn't sampling",
+                    "type": "integer"
+                },
+                "sampled": {
+                    "description": "Sampled is how many publishes were sampled, including those since\ndropped",
+                    "type": "integer"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TopicState": {
+            "type": "object",
+            "properties": {
+                "state": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/pubsub.StateEntry"
+                    }
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TopicSummary": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "subscribers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.TransferTopicRequest": {
+            "type": "object",
+            "properties": {
+                "owner": {
+                    "description": "Owner is the tenant taking over the topic",
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.BatchResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/pubsub.ErrorData"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.ChannelStats": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "depth": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.ClientInfo": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "description": "Attributes are the connection attributes set at connect time",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "connected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "inbox": {
+                    "description": "Inbox is the client's private reply topic, unset for streams",
+                    "type": "string"
+                },
+                "queue_capacity": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "description": "QueueDepth is how many frames wait in the client's send queue, out\nof QueueCapacity",
+                    "type": "integer"
+                },
+                "rtt": {
+                    "$ref": "#/definitions/pubsub.RTTStats"
+                },
+                "stream": {
+                    "description": "Stream is set for subscribers without a WebSocket, such as SSE,\nMQTT and sinks, which have no round-trip times",
+                    "type": "boolean"
+                },
+                "subscriptions": {
+                    "description": "Subscriptions are the topics the client is subscribed to, sorted",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "pubsub.ClientRTT": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "last_ms": {
+                    "type": "number"
+                }
+            }
+        },
+        "pubsub.DrainOptions": {
+            "type": "object",
+            "properties": {
+                "migrate": {
+                    "description": "Migrate moves existing subscribers to the replacement server-side,\ninstead of only telling them about it",
+                    "type": "boolean"
+                },
+                "replacement": {
+                    "description": "Replacement names the topic subscribers should move to",
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.DrainResult": {
+            "type": "object",
+            "properties": {
+                "migrated": {
+                    "description": "Migrated is how many of them were moved to the replacement",
+                    "type": "integer"
+                },
+                "notified": {
+                    "description": "Notified is how many subscribers were sent an info frame",
+                    "type": "integer"
+                },
+                "replacement": {
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "BAD_REQUEST",
+                "UNAUTHORIZED",
+                "FORBIDDEN",
+                "TOPIC_NOT_FOUND",
+                "CLIENT_NOT_FOUND",
+                "TOPIC_EXISTS",
+                "SCHEMA_NOT_FOUND",
+                "SCHEMA_VIOLATION",
+                "GROUP_NOT_FOUND",
+                "STATE_NOT_FOUND",
+                "GROUP_ACTIVE",
+                "TOPIC_DRAINING",
+                "TOPIC_DELETED",
+                "REVISION_MISMATCH",
+                "MESSAGE_TOO_LARGE",
+                "SLOW_CONSUMER",
+                "RATE_LIMITED",
+                "QUOTA_EXCEEDED",
+                "HUB_SATURATED",
+                "HUB_PAUSED",
+                "SERVER_SHUTTING_DOWN",
+                "REQUEST_TIMEOUT",
+                "INTERNAL_ERROR"
+            ],
+            "x-enum-varnames": [
+                "CodeBadRequest",
+                "CodeUnauthorized",
+                "CodeForbidden",
+                "CodeTopicNotFound",
+                "CodeClientNotFound",
+                "CodeTopicExists",
+                "CodeSchemaNotFound",
+                "CodeSchemaViolation",
+                "CodeGroupNotFound",
+                "CodeStateNotFound",
+                "CodeGroupActive",
+                "CodeTopicDraining",
+                "CodeTopicDeleted",
+                "CodeRevisionMismatch",
+                "CodeMessageTooLarge",
+                "CodeSlowConsumer",
+                "CodeRateLimited",
+                "CodeQuotaExceeded",
+                "CodeHubSaturated",
+                "CodeHubPaused",
+                "CodeServerShuttingDown",
+                "CodeRequestTimeout",
+                "CodeInternal"
+            ]
+        },
+        "pubsub.ErrorData": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/pubsub.ErrorCode"
+                },
+                "limit": {
+                    "description": "Limit is the exceeded limit, set on MESSAGE_TOO_LARGE errors",
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "replacement": {
+                    "description": "Replacement is the topic to subscribe to instead, set on\nTOPIC_DRAINING errors",
+                    "type": "string"
+                },
+                "results": {
+                    "description": "Results says which messages of a rejected publish batch were invalid",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.BatchResult"
+                    }
+                },
+                "retry_after_ms": {
+                    "description": "RetryAfterMs is how long until the caller may try again, set on\nRATE_LIMITED errors",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.GroupOffset": {
+            "type": "object",
+            "properties": {
+                "generation": {
+                    "description": "On partitioned topics, Generation counts the group's rebalances and\nPartitions maps member client IDs to the partitions they own",
+                    "type": "integer"
+                },
+                "group": {
+                    "type": "string"
+                },
+                "lag": {
+                    "description": "Lag is how many published messages the group has not received",
+                    "type": "integer"
+                },
+                "members": {
+                    "description": "Members is the number of connected subscribers in the group",
+                    "type": "integer"
+                },
+                "offset": {
+                    "description": "Offset is the sequence of the last message delivered to the group",
+                    "type": "integer"
+                },
+                "oldest_retained": {
+                    "description": "OldestRetained is the oldest sequence still available for replay (0 if none)",
+                    "type": "integer"
+                },
+                "partitions": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "sequence": {
+                    "description": "Sequence is the topic's current sequence",
+                    "type": "integer"
+                },
+                "topic": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.MessageData": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "description": "ContentType says how to decode the payload: application/json (the\ndefault), text/plain for a string, or application/octet-stream for\nbase64-encoded bytes",
+                    "type": "string"
+                },
+                "headers": {
+                    "description": "Headers are publisher-supplied string attributes delivered with the event",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key routes the message to a partition on partitioned topics; messages\nwith the same key go to the same consumer group member",
+                    "type": "string"
+                },
+                "payload": {},
+                "ttl_ms": {
+                    "description": "TTLMs is the publisher's requested time to live in milliseconds (0 = none)",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.Partitioning": {
+            "type": "object",
+            "properties": {
+                "assignment": {
+                    "description": "Assignment is how partitions are shared among a group's members:\nrange (the default) or round_robin",
+                    "type": "string"
+                },
+                "partitions": {
+                    "description": "Partitions is how many partitions the topic has",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.PauseInfo": {
+            "type": "object",
+            "properties": {
+                "buffered": {
+                    "description": "Buffered is how many publishes are waiting for the hub to resume,\nset when the pause is reported rather than announced",
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "until": {
+                    "description": "Until is when the hub resumes by itself, for pauses with a timeout",
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.PauseOptions": {
+            "type": "object",
+            "properties": {
+                "compact": {
+                    "description": "Compact rewrites the hub's storage from its current state once the\npause has taken hold",
+                    "type": "boolean"
+                },
+                "mode": {
+                    "description": "Mode is \"buffer\" or \"reject\" (\"\" = the hub's default)",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason is passed on to clients in the hub_paused frame",
+                    "type": "string"
+                },
+                "timeout_ms": {
+                    "description": "TimeoutMs resumes the hub by itself after this many milliseconds, in\ncase whoever paused it never does (0 = only when resumed)",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.PayloadSizeStats": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "max": {
+                    "type": "integer"
+                },
+                "p50": {
+                    "type": "integer"
+                },
+                "p95": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.PubSubMessage": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "$ref": "#/definitions/pubsub.MessageData"
+                },
+                "partition": {
+                    "description": "Partition is the message's partition on partitioned topics",
+                    "type": "integer"
+                },
+                "schema_version": {
+                    "description": "SchemaVersion is the topic schema version the payload validated against",
+                    "type": "integer"
+                },
+                "sequence": {
+                    "description": "Sequence is the message's position in its topic, starting at 1",
+                    "type": "integer"
+                },
+                "timestamp": {
+                    "description": "Timestamp is the authoritative time the server received the publish",
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.RTTStats": {
+            "type": "object",
+            "properties": {
+                "last_ms": {
+                    "type": "number"
+                },
+                "max_ms": {
+                    "type": "number"
+                },
+                "mean_ms": {
+                    "type": "number"
+                },
+                "measured_at": {
+                    "description": "MeasuredAt is when the last pong was timed, unset before the first",
+                    "type": "string"
+                },
+                "min_ms": {
+                    "type": "number"
+                },
+                "samples": {
+                    "description": "Samples is how many pongs have been timed",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.RTTSummary": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "description": "Clients is how many connected clients have been timed",
+                    "type": "integer"
+                },
+                "max_ms": {
+                    "type": "number"
+                },
+                "mean_ms": {
+                    "type": "number"
+                },
+                "slowest": {
+                    "description": "Slowest are the clients with the longest latest round-trip times,\nslowest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.ClientRTT"
+                    }
+                }
+            }
+        },
+        "pubsub.ReplayIntoRequest": {
+            "type": "object",
+            "properties": {
+                "from_sequence": {
+                    "description": "FromSequence and ToSequence bound the source sequences, inclusive",
+                    "type": "integer"
+                },
+                "rate": {
+                    "description": "Rate caps re-publishes per second (0 = unpaced)",
+                    "type": "integer"
+                },
+                "since": {
+                    "description": "Since and Until bound the server receive times, inclusive",
+                    "type": "string"
+                },
+                "target_topic": {
+                    "description": "TargetTopic receives the re-published messages",
+                    "type": "string"
+                },
+                "to_sequence": {
+                    "type": "integer"
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.ReplayIntoResult": {
+            "type": "object",
+            "properties": {
+                "from_sequence": {
+                    "description": "FromSequence and ToSequence are the first and last matched source\nsequences, 0 if none matched",
+                    "type": "integer"
+                },
+                "messages": {
+                    "description": "Messages is how many retained messages matched the range",
+                    "type": "integer"
+                },
+                "target_topic": {
+                    "type": "string"
+                },
+                "to_sequence": {
+                    "type": "integer"
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.ReplayLimits": {
+            "type": "object",
+            "properties": {
+                "default_last_n": {
+                    "description": "DefaultLastN is replayed when a subscribe omits last_n (0 = none)",
+                    "type": "integer"
+                },
+                "max_last_n": {
+                    "description": "MaxLastN caps last_n; larger requests are reduced to it (0 = the\ntopic's replay buffer size)",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.RetentionPolicy": {
+            "type": "object",
+            "properties": {
+                "max_age_ms": {
+                    "description": "MaxAgeMs expires retained messages this many milliseconds after they\nwere published (0 = never)",
+                    "type": "integer"
+                },
+                "max_messages": {
+                    "description": "MaxMessages is how many of the newest messages are retained (0 = the\nhub's ring buffer size)",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.RetentionUsage": {
+            "type": "object",
+            "properties": {
+                "budget": {
+                    "description": "Budget caps Bytes (0 = unbounded)",
+                    "type": "integer"
+                },
+                "bytes": {
+                    "description": "Bytes approximates the memory retained messages hold across topics",
+                    "type": "integer"
+                },
+                "evictions": {
+                    "description": "Evictions counts messages dropped early to stay within the budget",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.RuntimeStats": {
+            "type": "object",
+            "properties": {
+                "active_pumps": {
+                    "description": "ReadPump and WritePump goroutines currently running",
+                    "type": "integer"
+                },
+                "clients": {
+                    "type": "integer"
+                },
+                "departing_clients": {
+                    "description": "Unregistered clients whose pumps are still exiting",
+                    "type": "integer"
+                },
+                "goroutines": {
+                    "type": "integer"
+                },
+                "heap_alloc_bytes": {
+                    "type": "integer"
+                },
+                "leak_suspected": {
+                    "description": "LeakSuspected is set when any departing client is lingering",
+                    "type": "boolean"
+                },
+                "lingering_clients": {
+                    "description": "Departing clients whose pumps outlived pumpExitGrace",
+                    "type": "integer"
+                },
+                "queued_frames": {
+                    "description": "Frames queued for registered clients",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.SetGroupOffsetRequest": {
+            "type": "object",
+            "properties": {
+                "offset": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.Shadow": {
+            "type": "object",
+            "properties": {
+                "percent": {
+                    "description": "Percent is the share of publishes copied, over 0 and up to 100",
+                    "type": "number"
+                },
+                "topic": {
+                    "description": "Topic receives the copies, created if it doesn't exist",
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.Snapshot": {
+            "type": "object",
+            "properties": {
+                "taken_at": {
+                    "type": "string"
+                },
+                "topics": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.TopicSnapshot"
+                    }
+                }
+            }
+        },
+        "pubsub.StateEntry": {
+            "type": "object",
+            "properties": {
+                "ephemeral": {
+                    "description": "Ephemeral entries are removed when the connection that set them\ncloses, as presence should be",
+                    "type": "boolean"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "object"
+                }
+            }
+        },
+        "pubsub.StateUpdate": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "boolean"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Value is the key's new value, unset when the key was deleted",
+                    "type": "object"
+                }
+            }
+        },
+        "pubsub.StorageHealth": {
+            "type": "object",
+            "properties": {
+                "degraded": {
+                    "description": "Degraded is set while storage is failing. The hub keeps serving from\nmemory and writes its state behind once storage recovers.",
+                    "type": "boolean"
+                },
+                "error": {
+                    "description": "Error is the failure that degraded storage, or the latest retry's",
+                    "type": "string"
+                },
+                "pending": {
+                    "description": "Pending counts the changes made since storage failed; they are\nwritten together when it recovers",
+                    "type": "integer"
+                },
+                "retries": {
+                    "description": "Retries counts the failed attempts to write the state behind",
+                    "type": "integer"
+                },
+                "since": {
+                    "description": "Since is when storage failed",
+                    "type": "string"
+                }
+            }
+        },
+        "pubsub.TenantUsage": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "integer"
+                },
+                "retained_bytes": {
+                    "type": "integer"
+                },
+                "retained_messages": {
+                    "description": "RetainedMessages and RetainedBytes are what the tenant's topics\nretain for replay",
+                    "type": "integer"
+                },
+                "subscribers": {
+                    "description": "Subscribers and Messages total over the tenant's topics",
+                    "type": "integer"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "topic_limit": {
+                    "type": "integer"
+                },
+                "topics": {
+                    "description": "Topics is how many topics the tenant owns, out of TopicLimit (0 =\nunlimited)",
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.TopicSchema": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "schema": {
+                    "type": "object"
+                },
+                "topic": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.TopicSnapshot": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "dead_letter": {
+                    "description": "DeadLetter is the topic's dead-letter topic, if it set one",
+                    "type": "string"
+                },
+                "dedup_window_ms": {
+                    "description": "DedupWindowMs is the topic's dedup window, if it dedups publishes.\nThe IDs it remembered are not kept.",
+                    "type": "integer"
+                },
+                "enrich": {
+                    "type": "boolean"
+                },
+                "groups": {
+                    "description": "Groups maps consumer group names to their offsets",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "key_id": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "message_count": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "description": "Messages are the retained messages, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.PubSubMessage"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "partitioning": {
+                    "description": "Partitioning is the topic's partitioning, if it is partitioned",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Partitioning"
+                        }
+                    ]
+                },
+                "replay": {
+                    "$ref": "#/definitions/pubsub.ReplayLimits"
+                },
+                "retention": {
+                    "description": "Retention is the topic's retention policy, if it set one",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.RetentionPolicy"
+                        }
+                    ]
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "sample_every": {
+                    "description": "SampleEvery is the topic's sampling rate, if it samples publishes",
+                    "type": "integer"
+                },
+                "schemas": {
+                    "description": "Schemas are the registered schema versions, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pubsub.TopicSchema"
+                    }
+                },
+                "sequence": {
+                    "type": "integer"
+                },
+                "shadow": {
+                    "description": "Shadow is the topic's shadow, if it set one",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Shadow"
+                        }
+                    ]
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.TopicStats": {
+            "type": "object",
+            "properties": {
+                "backlog": {
+                    "description": "Publishes accepted but not yet fanned out",
+                    "type": "integer"
+                },
+                "buffer_capacity": {
+                    "type": "integer"
+                },
+                "buffer_occupancy": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "dead_letter": {
+                    "description": "DeadLetter is where events subscribers lost go, and DeadLettered\nhow many went there",
+                    "type": "string"
+                },
+                "dead_lettered": {
+                    "type": "integer"
+                },
+                "dedup_window_ms": {
+                    "description": "DedupWindowMs is how long published message IDs are remembered, and\nDuplicates how many publishes repeating one were dropped",
+                    "type": "integer"
+                },
+                "draining": {
+                    "description": "Draining topics take no new subscriptions; Replacement is where\nsubscribers were pointed",
+                    "type": "boolean"
+                },
+                "dropped_count": {
+                    "type": "integer"
+                },
+                "duplicates": {
+                    "type": "integer"
+                },
+                "enrich": {
+                    "description": "Enrich is set when published messages carry server metadata headers",
+                    "type": "boolean"
+                },
+                "executor_queued": {
+                    "description": "ExecutorQueued is how many publishes wait on the topic's executor,\nand ExecutorSaturations how often its queue filled up",
+                    "type": "integer"
+                },
+                "executor_saturations": {
+                    "type": "integer"
+                },
+                "key_id": {
+                    "description": "KeyID is set on encrypted topics",
+                    "type": "string"
+                },
+                "labels": {
+                    "description": "Labels are the topic's free-form labels",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "last_publish_at": {
+                    "type": "string"
+                },
+                "message_count": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner": {
+                    "description": "Owner is the tenant allowed to delete and reconfigure the topic",
+                    "type": "string"
+                },
+                "partitioning": {
+                    "description": "Partitioning is set on topics split into partitions by message key",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Partitioning"
+                        }
+                    ]
+                },
+                "payload_size": {
+                    "$ref": "#/definitions/pubsub.PayloadSizeStats"
+                },
+                "replacement": {
+                    "type": "string"
+                },
+                "replay": {
+                    "$ref": "#/definitions/pubsub.ReplayLimits"
+                },
+                "retained_bytes": {
+                    "description": "RetainedBytes approximates the memory the retained messages hold,\nwhen the store tracks it",
+                    "type": "integer"
+                },
+                "retention": {
+                    "$ref": "#/definitions/pubsub.RetentionPolicy"
+                },
+                "revision": {
+                    "description": "Revision advances with every settings change; updates may require it\nto be unchanged",
+                    "type": "integer"
+                },
+                "sample_every": {
+                    "description": "SampleEvery is the share of publishes sampled for inspection, one in\nSampleEvery, and Sampled how many were",
+                    "type": "integer"
+                },
+                "sampled": {
+                    "type": "integer"
+                },
+                "sequence": {
+                    "type": "integer"
+                },
+                "shadow": {
+                    "description": "Shadow is where a sample of publishes is copied, and Shadowed how\nmany were",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Shadow"
+                        }
+                    ]
+                },
+                "shadowed": {
+                    "type": "integer"
+                },
+                "subscriber_count": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "pubsub.TopicUpdate": {
+            "type": "object",
+            "properties": {
+                "dead_letter": {
+                    "description": "DeadLetter replaces the dead-letter topic, created if it doesn't\nexist; \"\" stops dead lettering",
+                    "type": "string"
+                },
+                "dedup_window_ms": {
+                    "description": "DedupWindowMs replaces how long published message IDs are\nremembered to drop retries; 0 stops dedup and forgets them",
+                    "type": "integer"
+                },
+                "enrich": {
+                    "description": "Enrich turns server metadata headers on or off",
+                    "type": "boolean"
+                },
+                "labels": {
+                    "description": "Labels replaces the topic's labels; an empty object clears them",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "replay": {
+                    "description": "Replay replaces the topic's last_n limits",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.ReplayLimits"
+                        }
+                    ]
+                },
+                "retention": {
+                    "description": "Retention replaces the retention policy; messages beyond a smaller\nmax_messages are dropped at once",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.RetentionPolicy"
+                        }
+                    ]
+                },
+                "sample_every": {
+                    "description": "SampleEvery replaces the sampling rate, keeping one in every\nSampleEvery publishes for inspection; 0 stops sampling. Samples taken\nat the old rate are dropped.",
+                    "type": "integer"
+                },
+                "schema": {
+                    "description": "Schema registers a new schema version, which published messages are\nthen checked against",
+                    "type": "object"
+                },
+                "shadow": {
+                    "description": "Shadow replaces the shadow topic and the share of publishes copied\nto it, created if it doesn't exist; one without a topic stops\nshadowing",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pubsub.Shadow"
+                        }
+                    ]
+                },
+                "weight": {
+                    "description": "Weight replaces the fan-out scheduling weight, 0 for the default",
+                    "type": "integer"
+                }
+            }
+        },
+        "version.Info": {
+            "type": "object",
+            "properties": {
+                "build_date": {
+                    "type": "string"
+                },
+                "commit": {
+                    "type": "string"
+                },
+                "go_version": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
         }
     },
     "securityDefinitions": {
+        "AdminKeyAuth": {
+            "type": "apiKey",
+            "name": "X-Admin-Key",
+            "in": "header"
+        },
         "ApiKeyAuth": {
             "type": "apiKey",
             "name": "X-API-Key",
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"fmt"
+	"sync"
+
+	"plivo/internal/pubsub"
+)
+
+// OrderingVerifier checks the events received by a subscriber for
+// reordering. It expects a broker running in ordering audit mode, which
+// stamps live events with a per-subscriber audit_seq.
+type OrderingVerifier struct {
+	mu         sync.Mutex
+	topics     map[string]*topicOrder
+	events     int64
+	gaps       int64
+	violations []string
+}
+
+// topicOrder is the last live event seen on a topic
+type topicOrder struct {
+	sequence int64
+	auditSeq int64
+}
+
+// OrderingReport summarizes what a verifier observed
+type OrderingReport struct {
+	// Events is the number of audited live events observed
+	Events int64 `json:"events"`
+	// Gaps counts skipped audit_seq values: events the broker dropped for
+	// this subscriber (e.g. drop-oldest backpressure), not reordering
+	Gaps int64 `json:"gaps"`
+	// Violations describes every reordered event
+	Violations []string `json:"violations,omitempty"`
+}
+
+// NewOrderingVerifier creates an empty verifier
+func NewOrderingVerifier() *OrderingVerifier {
+	return &OrderingVerifier{
+		topics: make(map[string]*topicOrder),
+	}
+}
+
+// Observe checks one received frame. Frames other than audited live events
+// (acks, replayed events, events from a broker without audit mode) are
+// ignored. It returns an error describing the violation if the event arrived
+// out of order.
+func (v *OrderingVerifier) Observe(msg *pubsub.ServerMessage) error {
+	if msg.Type != pubsub.EventMessage || msg.AuditSeq == 0 {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.events++
+
+	last, exists := v.topics[msg.Topic]
+	if !exists {
+		last = &topicOrder{}
+		v.topics[msg.Topic] = last
+	}
+
+	var err error
+	switch {
+	case msg.Sequence <= last.sequence:
+		err = fmt.Errorf("topic %s: sequence %d received after %d", msg.Topic, msg.Sequence, last.sequence)
+	case msg.AuditSeq <= last.auditSeq:
+		err = fmt.Errorf("topic %s: audit_seq %d received after %d", msg.Topic, msg.AuditSeq, last.auditSeq)
+	case msg.AuditSeq > last.auditSeq+1:
+		v.gaps += msg.AuditSeq - last.auditSeq - 1
+	}
+
+	if err != nil {
+		v.violations = append(v.violations, err.Error())
+		return err
+	}
+
+	last.sequence = msg.Sequence
+	last.auditSeq = msg.AuditSeq
+	return nil
+}
+
+// Report returns what the verifier has observed so far
+func (v *OrderingVerifier) Report() OrderingReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return OrderingReport{
+		Events:     v.events,
+		Gaps:       v.gaps,
+		Violations: append([]string(nil), v.violations...),
+	}
+}
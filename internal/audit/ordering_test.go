@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/handlers"
+	"plivo/internal/pubsub"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func event(topic string, sequence, auditSeq int64) *pubsub.ServerMessage {
+	return &pubsub.ServerMessage{Type: pubsub.EventMessage, Topic: topic, Sequence: sequence, AuditSeq: auditSeq}
+}
+
+func TestOrderingVerifierInOrder(t *testing.T) {
+	v := NewOrderingVerifier()
+
+	for i := int64(1); i <= 5; i++ {
+		if err := v.Observe(event("orders", i, i)); err != nil {
+			t.Errorf("Unexpected violation: %v", err)
+		}
+	}
+
+	// Topics are tracked independently
+	if err := v.Observe(event("payments", 1, 1)); err != nil {
+		t.Errorf("Unexpected violation on second topic: %v", err)
+	}
+
+	report := v.Report()
+	if report.Events != 6 || report.Gaps != 0 || len(report.Violations) != 0 {
+		t.Errorf("Expected 6 clean events, got %+v", report)
+	}
+}
+
+func TestOrderingVerifierDetectsReordering(t *testing.T) {
+	v := NewOrderingVerifier()
+
+	v.Observe(event("orders", 1, 1))
+	v.Observe(event("orders", 3, 2))
+
+	if err := v.Observe(event("orders", 2, 3)); err == nil {
+		t.Error("Expected a violation for a lower sequence")
+	}
+
+	report := v.Report()
+	if len(report.Violations) != 1 {
+		t.Errorf("Expected 1 violation, got %v", report.Violations)
+	}
+}
+
+func TestOrderingVerifierCountsGaps(t *testing.T) {
+	v := NewOrderingVerifier()
+
+	v.Observe(event("orders", 1, 1))
+	if err := v.Observe(event("orders", 10, 4)); err != nil {
+		t.Errorf("Dropped events should not be reported as reordering: %v", err)
+	}
+
+	if report := v.Report(); report.Gaps != 2 {
+		t.Errorf("Expected 2 gaps, got %d", report.Gaps)
+	}
+}
+
+func TestOrderingVerifierIgnoresUnauditedFrames(t *testing.T) {
+	v := NewOrderingVerifier()
+
+	v.Observe(&pubsub.ServerMessage{Type: pubsub.AckMessage, Topic: "orders"})
+	v.Observe(&pubsub.ServerMessage{Type: pubsub.EventMessage, Topic: "orders", Sequence: 7})
+
+	if report := v.Report(); report.Events != 0 {
+		t.Errorf("Expected unaudited frames to be ignored, got %d events", report.Events)
+	}
+}
+
+// TestLiveFanOutPreservesOrder publishes concurrently from several
+// connections and verifies that every subscriber sees each topic in order
+func TestLiveFanOutPreservesOrder(t *testing.T) {
+	cfg := config.NewTestConfig()
+	hub := pubsub.NewHubWithOptions(pubsub.HubOptions{PublishBuffer: 1024, OrderingAudit: true})
+	go hub.Run()
+	defer hub.Shutdown()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/ws", handlers.NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	topics := []string{"orders", "payments"}
+	for _, topic := range topics {
+		hub.CreateTopic(topic)
+	}
+
+	const subscribers = 3
+	const publishers = 4
+	const perPublisher = 25
+	expected := publishers * perPublisher // per topic
+
+	dial := func() (*websocket.Conn, error) {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		return conn, err
+	}
+
+	// Subscribe and wait for the acks
+	verifiers := make([]*OrderingVerifier, subscribers)
+	conns := make([]*websocket.Conn, subscribers)
+	for i := range conns {
+		conn, err := dial()
+		if err != nil {
+			t.Fatalf("Failed to connect subscriber: %v", err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		verifiers[i] = NewOrderingVerifier()
+
+		for _, topic := range topics {
+			conns[i].WriteJSON(pubsub.ClientMessage{Type: pubsub.SubscribeMessage, Topic: topic, ClientID: fmt.Sprintf("sub-%d", i)})
+		}
+		for acks := 0; acks < len(topics); {
+			var msg pubsub.ServerMessage
+			if err := conns[i].ReadJSON(&msg); err != nil {
+				t.Fatalf("Failed to read subscribe ack: %v", err)
+			}
+			if msg.Type == pubsub.AckMessage {
+				acks++
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			conn, err := dial()
+			if err != nil {
+				t.Errorf("Publisher %d: failed to connect: %v", p, err)
+				return
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+			// Wait for each publish ack before the next publish, so the
+			// publishers interleave without overrunning subscriber queues
+			for i := 0; i < perPublisher; i++ {
+				for _, topic := range topics {
+					conn.WriteJSON(pubsub.ClientMessage{
+						Type:    pubsub.PublishMessage,
+						Topic:   topic,
+						Message: &pubsub.MessageData{ID: fmt.Sprintf("p%d-%d", p, i), Payload: i},
+					})
+					for {
+						var msg pubsub.ServerMessage
+						if err := conn.ReadJSON(&msg); err != nil {
+							t.Errorf("Publisher %d: failed to read ack: %v", p, err)
+							return
+						}
+						if msg.Type == pubsub.AckMessage {
+							break
+						}
+					}
+				}
+			}
+		}(p)
+	}
+
+	// Drain every subscriber concurrently; each live event is either
+	// received or accounted for as a gap
+	var readers sync.WaitGroup
+	for i, conn := range conns {
+		readers.Add(1)
+		go func(i int, conn *websocket.Conn) {
+			defer readers.Done()
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			for {
+				report := verifiers[i].Report()
+				if report.Events+report.Gaps >= int64(expected*len(topics)) {
+					return
+				}
+				var msg pubsub.ServerMessage
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Errorf("Subscriber %d: read failed after %d events: %v", i, report.Events, err)
+					return
+				}
+				if err := verifiers[i].Observe(&msg); err != nil {
+					t.Errorf("Subscriber %d: %v", i, err)
+				}
+			}
+		}(i, conn)
+	}
+	readers.Wait()
+	wg.Wait()
+
+	for i, v := range verifiers {
+		report := v.Report()
+		if len(report.Violations) != 0 {
+			t.Errorf("Subscriber %d: expected in-order delivery, got %+v", i, report)
+		}
+	}
+
+	if violations := hub.GetStats().OrderingViolations; violations != 0 {
+		t.Errorf("Expected no server-side ordering violations, got %d", violations)
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Permission is an action a role may take on the topics its rules match
+type Permission string
+
+// Permissions, from least to most privileged. Admin implies the others.
+const (
+	// PermSubscribe covers subscribing and reading a topic's messages,
+	// statistics, schema and consumer group offsets
+	PermSubscribe Permission = "subscribe"
+	// PermPublish covers publishing to a topic
+	PermPublish Permission = "publish"
+	// PermAdmin covers creating, reconfiguring, draining, transferring,
+	// replaying into and deleting a topic
+	PermAdmin Permission = "admin"
+)
+
+// EveryoneSubject is the ACL subject whose roles every authenticated caller
+// holds, including callers with the shared API key
+const EveryoneSubject = "*"
+
+// ErrInvalidACL is wrapped by errors from ACL.Validate and Service.SetACL
+var ErrInvalidACL = errors.New("invalid ACL")
+
+// Rule grants permissions on the topics matching a pattern: an exact topic
+// name, a prefix ending in "*" such as "orders.*", or "*" for every topic
+type Rule struct {
+	Topics      string       `json:"topics"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// ACL maps subjects to roles and roles to the permissions they grant per
+// topic pattern. Subjects are tenant names, bound to API keys by
+// -tenant-keys, or EveryoneSubject.
+type ACL struct {
+	// Roles maps role names to their rules
+	Roles map[string][]Rule `json:"roles"`
+	// Bindings maps subjects to the roles they hold
+	Bindings map[string][]string `json:"bindings"`
+}
+
+// Validate checks that every rule has a pattern and known permissions, and
+// that every binding names a defined role
+func (a *ACL) Validate() error {
+	for role, rules := range a.Roles {
+		if role == "" {
+			return fmt.Errorf("%w: role names must not be empty", ErrInvalidACL)
+		}
+		for _, rule := range rules {
+			if rule.Topics == "" {
+				return fmt.Errorf("%w: role %s has a rule without a topic pattern", ErrInvalidACL, role)
+			}
+			if i := strings.Index(rule.Topics, "*"); i >= 0 && i != len(rule.Topics)-1 {
+				return fmt.Errorf("%w: role %s: topic pattern %q may only end in *", ErrInvalidACL, role, rule.Topics)
+			}
+			if len(rule.Permissions) == 0 {
+				return fmt.Errorf("%w: role %s has a rule for %q without permissions", ErrInvalidACL, role, rule.Topics)
+			}
+			for _, permission := range rule.Permissions {
+				switch permission {
+				case PermSubscribe, PermPublish, PermAdmin:
+				default:
+					return fmt.Errorf("%w: role %s: unknown permission %q, expected %s, %s or %s",
+						ErrInvalidACL, role, permission, PermSubscribe, PermPublish, PermAdmin)
+				}
+			}
+		}
+	}
+	for subject, roles := range a.Bindings {
+		if subject == "" {
+			return fmt.Errorf("%w: subjects must not be empty", ErrInvalidACL)
+		}
+		for _, role := range roles {
+			if _, exists := a.Roles[role]; !exists {
+				return fmt.Errorf("%w: subject %s is bound to undefined role %s", ErrInvalidACL, subject, role)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the subject's roles, with those bound to
+// EveryoneSubject, grant the permission on the topic
+func (a *ACL) Allows(subject string, permission Permission, topic string) bool {
+	for _, roles := range [][]string{a.Bindings[subject], a.Bindings[EveryoneSubject]} {
+		for _, role := range roles {
+			for _, rule := range a.Roles[role] {
+				if matchTopic(rule.Topics, topic) && grants(rule.Permissions, permission) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// matchTopic reports whether a rule's topic pattern matches the topic
+func matchTopic(pattern, topic string) bool {
+	if prefix, wildcard := strings.CutSuffix(pattern, "*"); wildcard {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return pattern == topic
+}
+
+// grants reports whether the permissions include the wanted one, admin
+// implying every other
+func grants(permissions []Permission, wanted Permission) bool {
+	for _, permission := range permissions {
+		if permission == wanted || permission == PermAdmin {
+			return true
+		}
+	}
+	return false
+}
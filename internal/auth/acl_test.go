@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"plivo/internal/config"
+)
+
+func testACL() *ACL {
+	return &ACL{
+		Roles: map[string][]Rule{
+			"reader":         {{Topics: "*", Permissions: []Permission{PermSubscribe}}},
+			"payments-owner": {{Topics: "payments.*", Permissions: []Permission{PermAdmin}}},
+			"audit-writer":   {{Topics: "audit", Permissions: []Permission{PermPublish}}},
+		},
+		Bindings: map[string][]string{
+			EveryoneSubject: {"reader"},
+			"payments":      {"payments-owner", "audit-writer"},
+		},
+	}
+}
+
+func TestACLAllows(t *testing.T) {
+	acl := testACL()
+
+	tests := []struct {
+		name       string
+		subject    string
+		permission Permission
+		topic      string
+		want       bool
+	}{
+		{"everyone may subscribe", "search", PermSubscribe, "orders", true},
+		{"everyone may not publish", "search", PermPublish, "orders", false},
+		{"prefix pattern", "payments", PermAdmin, "payments.refunds", true},
+		{"admin implies publish", "payments", PermPublish, "payments.refunds", true},
+		{"prefix pattern excludes other topics", "payments", PermAdmin, "orders", false},
+		{"exact pattern", "payments", PermPublish, "audit", true},
+		{"exact pattern excludes longer names", "payments", PermPublish, "audit.log", false},
+		{"unbound subject holds everyone's roles", "billing", PermSubscribe, "payments.refunds", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allows(tt.subject, tt.permission, tt.topic); got != tt.want {
+				t.Errorf("Allows(%q, %s, %q) = %t, want %t", tt.subject, tt.permission, tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLValidate(t *testing.T) {
+	if err := testACL().Validate(); err != nil {
+		t.Fatalf("Expected a valid ACL, got %v", err)
+	}
+
+	invalid := []*ACL{
+		{Roles: map[string][]Rule{"r": {{Topics: "", Permissions: []Permission{PermSubscribe}}}}},
+		{Roles: map[string][]Rule{"r": {{Topics: "orders.*.eu", Permissions: []Permission{PermSubscribe}}}}},
+		{Roles: map[string][]Rule{"r": {{Topics: "orders"}}}},
+		{Roles: map[string][]Rule{"r": {{Topics: "orders", Permissions: []Permission{"delete"}}}}},
+		{Bindings: map[string][]string{"payments": {"missing"}}},
+	}
+	for i, acl := range invalid {
+		if err := acl.Validate(); !errors.Is(err, ErrInvalidACL) {
+			t.Errorf("ACL %d: expected ErrInvalidACL, got %v", i, err)
+		}
+	}
+}
+
+func TestServiceAuthorize(t *testing.T) {
+	s := MustNewService(config.SecurityConfig{APIKey: "shared", TenantKeys: "payments=pay-key"})
+
+	if !s.Authorize("", PermAdmin, "orders") {
+		t.Error("Expected everything to be allowed without an ACL")
+	}
+
+	if err := s.SetACL(&ACL{Bindings: map[string][]string{"billing": nil}}); !errors.Is(err, ErrInvalidACL) {
+		t.Errorf("Expected a binding for an unknown tenant to be rejected, got %v", err)
+	}
+	if s.ACL() != nil {
+		t.Error("A rejected ACL must not be enforced")
+	}
+
+	if err := s.SetACL(testACL()); err != nil {
+		t.Fatalf("Failed to set ACL: %v", err)
+	}
+	if !s.Authorize("payments", PermAdmin, "payments.refunds") {
+		t.Error("Expected the payments tenant to administer its topics")
+	}
+	if s.Authorize("", PermPublish, "audit") || !s.Authorize("", PermSubscribe, "audit") {
+		t.Error("Expected shared-key callers to hold only everyone's roles")
+	}
+
+	s.ClearACL()
+	if !s.Authorize("", PermPublish, "audit") {
+		t.Error("Expected everything to be allowed after clearing the ACL")
+	}
+}
@@ -0,0 +1,195 @@
+// Package auth checks the API, tenant and admin keys callers present, so
+// that every transport (REST, WebSocket, SSE, gRPC and MQTT) agrees on who
+// a key belongs to, and what the ACL lets them do.
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sync/atomic"
+
+	"plivo/internal/config"
+)
+
+// Service authenticates API keys and admin credentials, and authorizes
+// callers against the ACL. Its keys and ACL may be replaced at any time.
+// It is safe for concurrent use.
+type Service struct {
+	keys atomic.Pointer[keys]
+	// namespaces confines tenants to topics named tenant/topic
+	namespaces bool
+	// acl restricts what callers may do, nil until one is set
+	acl atomic.Pointer[ACL]
+}
+
+// keys are the credentials a Service accepts, replaced together
+type keys struct {
+	apiKey   string
+	adminKey string
+	// tenants maps tenant API keys to tenant names
+	tenants map[string]string
+}
+
+// newKeys returns the credentials of the security configuration, or an
+// error if its tenant keys are malformed
+func newKeys(cfg config.SecurityConfig) (*keys, error) {
+	tenants, err := cfg.Tenants()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenant keys: %w", err)
+	}
+	return &keys{apiKey: cfg.APIKey, adminKey: cfg.AdminKey, tenants: tenants}, nil
+}
+
+// isTenant reports whether name is one of the keys' tenants
+func (k *keys) isTenant(name string) bool {
+	for _, tenant := range k.tenants {
+		if tenant == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewService returns the authentication service for the security
+// configuration, or an error if its tenant keys are malformed
+func NewService(cfg config.SecurityConfig) (*Service, error) {
+	k, err := newKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{namespaces: cfg.TenantNamespaces}
+	s.keys.Store(k)
+	return s, nil
+}
+
+// MustNewService is NewService for configurations known to be valid, such
+// as in tests. It panics on malformed tenant keys.
+func MustNewService(cfg config.SecurityConfig) *Service {
+	s, err := NewService(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Authenticate checks an API key against the shared API key and the tenant
+// keys. It returns the key's tenant, or "" for the shared key and when no
+// keys are configured, in which case every caller is allowed.
+func (s *Service) Authenticate(key string) (string, bool) {
+	k := s.keys.Load()
+	if k.apiKey == "" && len(k.tenants) == 0 {
+		// No keys set, allow all requests
+		return "", true
+	}
+	if tenant, exists := k.tenants[key]; exists && key != "" {
+		return tenant, true
+	}
+	return "", k.apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k.apiKey)) == 1
+}
+
+// AuthenticateCaller authenticates a caller by its API key or, when it sent
+// none, by the common name of its verified client certificate ("" without
+// one). A certificate authenticates like the shared key, or as the tenant
+// its common name names.
+func (s *Service) AuthenticateCaller(key, certName string) (string, bool) {
+	if key != "" || certName == "" {
+		return s.Authenticate(key)
+	}
+	if s.IsTenant(certName) {
+		return certName, true
+	}
+	return "", true
+}
+
+// IsAdmin reports whether key is the admin credential, which falls back to
+// the API key. With neither set, everyone is an admin unless tenant keys
+// lock the API down.
+func (s *Service) IsAdmin(key string) bool {
+	k := s.keys.Load()
+	adminKey := k.adminKey
+	if adminKey == "" {
+		adminKey = k.apiKey
+	}
+	if adminKey == "" {
+		// Open, as the rest of the API is, unless tenant keys lock it down
+		return len(k.tenants) == 0
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1
+}
+
+// IsTenant reports whether name is a configured tenant
+func (s *Service) IsTenant(name string) bool {
+	return s.keys.Load().isTenant(name)
+}
+
+// SetKeys replaces the API, admin and tenant keys with the security
+// configuration's, so keys can be rotated without a restart. Callers
+// already connected stay connected. It refuses tenant keys that are
+// malformed or that drop a tenant the ACL binds.
+func (s *Service) SetKeys(cfg config.SecurityConfig) error {
+	k, err := newKeys(cfg)
+	if err != nil {
+		return err
+	}
+	if acl := s.acl.Load(); acl != nil {
+		for subject := range acl.Bindings {
+			if subject != EveryoneSubject && !k.isTenant(subject) {
+				return fmt.Errorf("tenant %s is bound by the ACL and can't be removed", subject)
+			}
+		}
+	}
+	s.keys.Store(k)
+	return nil
+}
+
+// SetACL validates the ACL and starts enforcing it. Every subject it binds
+// must be a configured tenant or EveryoneSubject. The ACL must not be
+// modified afterwards.
+func (s *Service) SetACL(acl *ACL) error {
+	if err := acl.Validate(); err != nil {
+		return err
+	}
+	for subject := range acl.Bindings {
+		if subject != EveryoneSubject && !s.IsTenant(subject) {
+			return fmt.Errorf("%w: subject %s is not a configured tenant", ErrInvalidACL, subject)
+		}
+	}
+	s.acl.Store(acl)
+	return nil
+}
+
+// ClearACL stops enforcing the ACL, so every authenticated caller may do
+// anything again
+func (s *Service) ClearACL() {
+	s.acl.Store(nil)
+}
+
+// ACL returns the enforced ACL, nil if there is none
+func (s *Service) ACL() *ACL {
+	return s.acl.Load()
+}
+
+// Restricts reports whether Authorize may refuse anything: an ACL is
+// enforced, or tenants are confined to their namespaces
+func (s *Service) Restricts() bool {
+	return s.namespaces || s.acl.Load() != nil
+}
+
+// Authorize reports whether a caller of the tenant ("" for the shared key)
+// may take the action on the topic. With namespaces, tenants may only
+// touch their own; without an ACL everyone may do anything else. Callers
+// holding the admin credential should not be checked.
+func (s *Service) Authorize(tenant string, permission Permission, topic string) bool {
+	if !s.inNamespace(tenant, permission, topic) {
+		return false
+	}
+	acl := s.acl.Load()
+	if acl == nil {
+		return true
+	}
+	if tenant == "" {
+		// Shared-key callers hold only the roles bound to everyone
+		tenant = EveryoneSubject
+	}
+	return acl.Allows(tenant, permission, topic)
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"testing"
+
+	"plivo/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	open := MustNewService(config.SecurityConfig{})
+	shared := MustNewService(config.SecurityConfig{APIKey: "shared"})
+	tenants := MustNewService(config.SecurityConfig{APIKey: "shared", TenantKeys: "payments=pay-key"})
+	tenantsOnly := MustNewService(config.SecurityConfig{TenantKeys: "payments=pay-key"})
+
+	tests := []struct {
+		name       string
+		service    *Service
+		key        string
+		wantTenant string
+		wantOK     bool
+	}{
+		{"open allows anyone", open, "", "", true},
+		{"shared key", shared, "shared", "", true},
+		{"wrong key", shared, "nope", "", false},
+		{"missing key", shared, "", "", false},
+		{"tenant key", tenants, "pay-key", "payments", true},
+		{"shared key beside tenants", tenants, "shared", "", true},
+		{"tenant keys lock the API", tenantsOnly, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, ok := tt.service.Authenticate(tt.key)
+			if tenant != tt.wantTenant || ok != tt.wantOK {
+				t.Errorf("Authenticate(%q) = %q, %t; want %q, %t", tt.key, tenant, ok, tt.wantTenant, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAuthenticateCaller(t *testing.T) {
+	service := MustNewService(config.SecurityConfig{APIKey: "shared", TenantKeys: "payments=pay-key"})
+
+	tests := []struct {
+		name       string
+		key        string
+		certName   string
+		wantTenant string
+		wantOK     bool
+	}{
+		{"tenant certificate", "", "payments", "payments", true},
+		{"other certificate", "", "edge-gateway", "", true},
+		{"key wins over certificate", "pay-key", "edge-gateway", "payments", true},
+		{"wrong key with certificate", "nope", "payments", "", false},
+		{"neither", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, ok := service.AuthenticateCaller(tt.key, tt.certName)
+			if tenant != tt.wantTenant || ok != tt.wantOK {
+				t.Errorf("AuthenticateCaller(%q, %q) = %q, %t; want %q, %t", tt.key, tt.certName, tenant, ok, tt.wantTenant, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SecurityConfig
+		key  string
+		want bool
+	}{
+		{"open", config.SecurityConfig{}, "", true},
+		{"admin key", config.SecurityConfig{APIKey: "shared", AdminKey: "admin"}, "admin", true},
+		{"API key is not admin beside an admin key", config.SecurityConfig{APIKey: "shared", AdminKey: "admin"}, "shared", false},
+		{"falls back to the API key", config.SecurityConfig{APIKey: "shared"}, "shared", true},
+		{"tenant keys lock admin down", config.SecurityConfig{TenantKeys: "payments=pay-key"}, "pay-key", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MustNewService(tt.cfg).IsAdmin(tt.key); got != tt.want {
+				t.Errorf("IsAdmin(%q) = %t, want %t", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceRejectsMalformedTenantKeys(t *testing.T) {
+	if _, err := NewService(config.SecurityConfig{TenantKeys: "payments"}); err == nil {
+		t.Error("Expected an error for a tenant without a key")
+	}
+	if _, err := NewService(config.SecurityConfig{APIKey: "shared", TenantKeys: "payments=shared"}); err == nil {
+		t.Error("Expected an error for a tenant key equal to the API key")
+	}
+}
+
+func TestIsTenant(t *testing.T) {
+	s := MustNewService(config.SecurityConfig{TenantKeys: "payments=pay-key,search=search-key"})
+	if !s.IsTenant("search") || s.IsTenant("billing") {
+		t.Error("Expected only configured tenants to be recognized")
+	}
+}
+
+func TestSetKeys(t *testing.T) {
+	s := MustNewService(config.SecurityConfig{APIKey: "old", TenantKeys: "payments=pay-key"})
+	if err := s.SetACL(&ACL{Roles: map[string][]Rule{"reader": {{Topics: "*", Permissions: []Permission{PermSubscribe}}}}, Bindings: map[string][]string{"payments": {"reader"}}}); err != nil {
+		t.Fatalf("SetACL failed: %v", err)
+	}
+
+	if err := s.SetKeys(config.SecurityConfig{APIKey: "new", TenantKeys: "payments=pay-key-2"}); err != nil {
+		t.Fatalf("SetKeys failed: %v", err)
+	}
+	if _, ok := s.Authenticate("old"); ok {
+		t.Error("Expected the old API key refused after rotation")
+	}
+	if tenant, ok := s.Authenticate("pay-key-2"); !ok || tenant != "payments" {
+		t.Errorf("Expected the new tenant key accepted, got %q, %t", tenant, ok)
+	}
+	if !s.IsAdmin("new") {
+		t.Error("Expected the new API key to be the admin credential")
+	}
+
+	// Keys that would leave the ACL binding an unknown tenant are refused
+	if err := s.SetKeys(config.SecurityConfig{APIKey: "newer"}); err == nil {
+		t.Error("Expected an error dropping a tenant the ACL binds")
+	}
+	if err := s.SetKeys(config.SecurityConfig{TenantKeys: "payments"}); err == nil {
+		t.Error("Expected an error for malformed tenant keys")
+	}
+	if _, ok := s.Authenticate("new"); !ok {
+		t.Error("Expected refused keys to leave the current ones in place")
+	}
+}
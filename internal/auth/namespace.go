@@ -0,0 +1,48 @@
+package auth
+
+import "strings"
+
+// NamespaceSeparator separates a namespaced topic's tenant from the rest of
+// its name, as in payments/orders
+const NamespaceSeparator = "/"
+
+// inboxPrefix is pubsub.InboxTopicPrefix, which can't be imported here:
+// anyone may publish to a connection's inbox, whatever its namespace
+const inboxPrefix = "~inbox/"
+
+// Namespace returns the tenant namespace a topic name is in, "" for names
+// without one
+func Namespace(topic string) string {
+	if strings.HasPrefix(topic, inboxPrefix) {
+		return ""
+	}
+	namespace, _, found := strings.Cut(topic, NamespaceSeparator)
+	if !found {
+		return ""
+	}
+	return namespace
+}
+
+// InNamespace reports whether a topic is in the tenant's namespace
+func InNamespace(tenant, topic string) bool {
+	return tenant != "" && Namespace(topic) == tenant
+}
+
+// inNamespace reports whether namespaces let a caller of the tenant ("" for
+// the shared key) take an action on a topic. Tenants are confined to their
+// own namespace, apart from publishing to inboxes; shared-key callers are
+// not confined.
+func (s *Service) inNamespace(tenant string, permission Permission, topic string) bool {
+	if !s.namespaces || tenant == "" {
+		return true
+	}
+	if permission == PermPublish && strings.HasPrefix(topic, inboxPrefix) {
+		return true
+	}
+	return InNamespace(tenant, topic)
+}
+
+// Namespaced reports whether tenants are confined to their namespaces
+func (s *Service) Namespaced() bool {
+	return s.namespaces
+}
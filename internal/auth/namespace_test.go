@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"testing"
+
+	"plivo/internal/config"
+)
+
+func TestNamespace(t *testing.T) {
+	tests := map[string]string{
+		"payments/orders": "payments",
+		"orders":          "",
+		"~inbox/client-1": "",
+	}
+	for topic, want := range tests {
+		if got := Namespace(topic); got != want {
+			t.Errorf("Namespace(%q) = %q, want %q", topic, got, want)
+		}
+	}
+}
+
+func TestAuthorizeNamespaces(t *testing.T) {
+	s := MustNewService(config.SecurityConfig{
+		APIKey:           "shared",
+		TenantKeys:       "payments=pay-key",
+		TenantNamespaces: true,
+	})
+	if !s.Restricts() {
+		t.Fatal("Expected namespaces to restrict callers without an ACL")
+	}
+
+	tests := []struct {
+		name       string
+		tenant     string
+		permission Permission
+		topic      string
+		want       bool
+	}{
+		{"own namespace", "payments", PermAdmin, "payments/orders", true},
+		{"other namespace", "payments", PermSubscribe, "search/queries", false},
+		{"no namespace", "payments", PermPublish, "orders", false},
+		{"prefix is not a namespace", "payments", PermPublish, "paymentsx/orders", false},
+		{"publish to an inbox", "payments", PermPublish, "~inbox/client-1", true},
+		{"subscribe to an inbox", "payments", PermSubscribe, "~inbox/client-1", false},
+		{"shared key is not confined", "", PermAdmin, "search/queries", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Authorize(tt.tenant, tt.permission, tt.topic); got != tt.want {
+				t.Errorf("Authorize(%q, %s, %q) = %t, want %t", tt.tenant, tt.permission, tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespacedTenantNames(t *testing.T) {
+	cfg := config.SecurityConfig{TenantKeys: "pay/ments=pay-key", TenantNamespaces: true}
+	if _, err := NewService(cfg); err == nil {
+		t.Error("Expected a namespaced tenant name with / to be rejected")
+	}
+	cfg.TenantNamespaces = false
+	if _, err := NewService(cfg); err != nil {
+		t.Errorf("Expected / in tenant names to be allowed without namespaces, got %v", err)
+	}
+}
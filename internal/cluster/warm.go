@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"plivo/internal/pubsub"
+)
+
+// WarmOptions configures warming a hub from a peer
+type WarmOptions struct {
+	// Peer is the peer's base URL, e.g. http://node-1:8080
+	Peer string
+	// AdminKey is sent as X-Admin-Key; cluster nodes share credentials
+	AdminKey string
+	// Timeout bounds the whole snapshot fetch
+	Timeout time.Duration
+}
+
+// Warm fetches a snapshot of the peer's topics and retained messages and
+// restores it into hub. It is meant to run before the node accepts
+// connections, so that subscribers landing on it still get last_n replay.
+func Warm(hub *pubsub.Hub, opts WarmOptions) (*pubsub.RestoreResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(opts.Peer, "/")+"/cluster/snapshot", nil)
+	if err != nil {
+		return nil, err
+	}
+	if opts.AdminKey != "" {
+		req.Header.Set("X-Admin-Key", opts.AdminKey)
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch snapshot: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch snapshot: peer returned %s", resp.Status)
+	}
+
+	var snapshot pubsub.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	return hub.Restore(&snapshot), nil
+}
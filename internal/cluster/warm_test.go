@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/handlers"
+	"plivo/internal/pubsub"
+
+	"github.com/gorilla/mux"
+)
+
+// newPeer serves a hub's snapshot the way a running node does
+func newPeer(t *testing.T, hub *pubsub.Hub, cfg *config.Config) *httptest.Server {
+	r := mux.NewRouter()
+	r.HandleFunc("/cluster/snapshot", handlers.NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security)).Snapshot)
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWarmFromPeer(t *testing.T) {
+	peer := pubsub.NewHub()
+	peer.CreateTopic("orders")
+	for i := 1; i <= 3; i++ {
+		peer.TryPublish(&pubsub.PubSubMessage{Topic: "orders", Message: &pubsub.MessageData{ID: fmt.Sprintf("msg-%d", i)}}, time.Second)
+	}
+	go peer.Run()
+	defer peer.Shutdown()
+
+	waitFor(t, func() bool {
+		stats, _ := peer.GetTopicStats("orders")
+		return stats.Sequence == 3
+	})
+
+	server := newPeer(t, peer, config.NewTestConfigWithAPIKey("secret"))
+
+	hub := pubsub.NewHub()
+	result, err := Warm(hub, WarmOptions{Peer: server.URL, AdminKey: "secret", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+	if result.Topics != 1 {
+		t.Errorf("Expected 1 topic restored, got %+v", result)
+	}
+	if stats, err := hub.GetTopicStats("orders"); err != nil || stats.Sequence != 3 {
+		t.Errorf("Expected orders at sequence 3, got %+v, %v", stats, err)
+	}
+}
+
+func TestWarmRejectedWithoutCredential(t *testing.T) {
+	server := newPeer(t, pubsub.NewHub(), config.NewTestConfigWithAPIKey("secret"))
+
+	if _, err := Warm(pubsub.NewHub(), WarmOptions{Peer: server.URL, AdminKey: "wrong", Timeout: time.Second}); err == nil {
+		t.Error("Expected warm with the wrong credential to fail")
+	}
+}
+
+func TestWarmUnreachablePeer(t *testing.T) {
+	if _, err := Warm(pubsub.NewHub(), WarmOptions{Peer: "http://127.0.0.1:1", Timeout: time.Second}); err == nil {
+		t.Error("Expected warm from an unreachable peer to fail")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -2,141 +2,575 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"io"
 	"os"
+	"plivo/internal/version"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	// Server configuration
-	Server ServerConfig `json:"server"`
+	Server ServerConfig `json:"server" yaml:"server"`
 
 	// Pub/Sub configuration
-	PubSub PubSubConfig `json:"pubsub"`
+	PubSub PubSubConfig `json:"pubsub" yaml:"pubsub"`
 
 	// Security configuration
-	Security SecurityConfig `json:"security"`
+	Security SecurityConfig `json:"security" yaml:"security"`
 
 	// Logging configuration
-	Logging LoggingConfig `json:"logging"`
+	Logging LoggingConfig `json:"logging" yaml:"logging"`
+
+	// API documentation configuration
+	Docs DocsConfig `json:"docs" yaml:"docs"`
+
+	// Cluster configuration
+	Cluster ClusterConfig `json:"cluster" yaml:"cluster"`
+
+	// Sink configuration
+	Sinks SinkConfig `json:"sinks" yaml:"sinks"`
+
+	// file is the config file loaded, and sources where each setting, by
+	// config file key, came from when it wasn't its default
+	file    string
+	sources map[string]string
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Port            string        `json:"port"`
-	ReadTimeout     time.Duration `json:"read_timeout"`
-	WriteTimeout    time.Duration `json:"write_timeout"`
-	IdleTimeout     time.Duration `json:"idle_timeout"`
-	ShutdownTimeout time.Duration `json:"shutdown_timeout"`
+	Port            string        `json:"port" yaml:"port"`
+	ReadTimeout     time.Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout     time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// RequestTimeout bounds REST requests, cancelling whatever hub work they
+	// are waiting on when it passes (0 = unbounded)
+	RequestTimeout time.Duration `json:"request_timeout" yaml:"request_timeout"`
+	// ExportTimeout replaces RequestTimeout for endpoints that copy every
+	// topic, such as /cluster/snapshot (0 = unbounded)
+	ExportTimeout time.Duration `json:"export_timeout" yaml:"export_timeout"`
+	// GRPCPort serves the gRPC API on its own port; empty disables it
+	GRPCPort string `json:"grpc_port" yaml:"grpc_port"`
+	// MQTTPort serves MQTT 3.1.1 on its own port; empty disables it
+	MQTTPort string `json:"mqtt_port" yaml:"mqtt_port"`
+	// TLSCert and TLSKey are PEM files the HTTP server serves TLS with;
+	// empty serves plain HTTP
+	TLSCert string `json:"tls_cert" yaml:"tls_cert"`
+	TLSKey  string `json:"tls_key" yaml:"tls_key"`
+	// TLSClientCA is a PEM bundle of CAs client certificates must be signed
+	// by; set, the server requires one from every client
+	TLSClientCA string `json:"tls_client_ca" yaml:"tls_client_ca"`
 }
 
 // PubSubConfig holds pub/sub system configuration
 type PubSubConfig struct {
-	MaxQueueSize      int           `json:"max_queue_size"`
-	RingBufferSize    int           `json:"ring_buffer_size"`
-	PingInterval      time.Duration `json:"ping_interval"`
-	PongWait          time.Duration `json:"pong_wait"`
-	WriteWait         time.Duration `json:"write_wait"`
-	MaxMessageSize    int64         `json:"max_message_size"`
-	EnableCompression bool          `json:"enable_compression"`
+	MaxQueueSize       int           `json:"max_queue_size" yaml:"max_queue_size"`
+	RingBufferSize     int           `json:"ring_buffer_size" yaml:"ring_buffer_size"`
+	PingInterval       time.Duration `json:"ping_interval" yaml:"ping_interval"`
+	PongWait           time.Duration `json:"pong_wait" yaml:"pong_wait"`
+	WriteWait          time.Duration `json:"write_wait" yaml:"write_wait"`
+	MaxMessageSize     int64         `json:"max_message_size" yaml:"max_message_size"`
+	ReplayRate         int           `json:"replay_rate" yaml:"replay_rate"`
+	GenerateMessageIDs bool          `json:"generate_message_ids" yaml:"generate_message_ids"`
+	EnableCompression  bool          `json:"enable_compression" yaml:"enable_compression"`
+	HubRegisterBuffer  int           `json:"hub_register_buffer" yaml:"hub_register_buffer"`
+	HubPublishBuffer   int           `json:"hub_publish_buffer" yaml:"hub_publish_buffer"`
+	HubSubscribeBuffer int           `json:"hub_subscribe_buffer" yaml:"hub_subscribe_buffer"`
+	HubShards          int           `json:"hub_shards" yaml:"hub_shards"`
+	DeliveryWorkers    int           `json:"delivery_workers" yaml:"delivery_workers"`
+	TopicExecutorQueue int           `json:"topic_executor_queue" yaml:"topic_executor_queue"`
+	// REST publish backpressure thresholds on the topic's publish backlog
+	PublishQueuedDepth int           `json:"publish_queued_depth" yaml:"publish_queued_depth"`
+	PublishRejectDepth int           `json:"publish_reject_depth" yaml:"publish_reject_depth"`
+	PublishRetryAfter  time.Duration `json:"publish_retry_after" yaml:"publish_retry_after"`
+	OrderingAudit      bool          `json:"ordering_audit" yaml:"ordering_audit"`
+	// last_n replay on subscribe: default when omitted, and upper bound
+	DefaultLastN int `json:"default_last_n" yaml:"default_last_n"`
+	MaxLastN     int `json:"max_last_n" yaml:"max_last_n"`
+	// DataDir holds the write-ahead log topics and retained messages are
+	// persisted to; empty keeps them in memory only
+	DataDir string `json:"data_dir" yaml:"data_dir"`
+	// TrashWindow is how long deleted topics can be restored (0 = deleted
+	// immediately)
+	TrashWindow time.Duration `json:"trash_window" yaml:"trash_window"`
+	// GroupExpiry drops consumer groups without connected members for this
+	// long (0 = never)
+	GroupExpiry time.Duration `json:"group_expiry" yaml:"group_expiry"`
+	// CompressRetained keeps retained payloads of at least this many bytes
+	// compressed in memory (0 = never)
+	CompressRetained int `json:"compress_retained" yaml:"compress_retained"`
+	// RetentionBudget caps the approximate memory retained messages hold
+	// across all topics, in bytes (0 = unbounded)
+	RetentionBudget int64 `json:"retention_budget" yaml:"retention_budget"`
+	// TopicsFile names a JSON file of topics, with their settings, created
+	// at startup if they don't exist ("" = none)
+	TopicsFile string `json:"topics_file" yaml:"topics_file"`
+	// AlertInterval is the window anomaly detectors compare each topic's
+	// publishes, subscribers and drops over (0 = no detection)
+	AlertInterval time.Duration `json:"alert_interval" yaml:"alert_interval"`
+	// AlertWebhook is a URL every $SYS/alerts event is POSTed to ("" =
+	// none)
+	AlertWebhook string `json:"alert_webhook" yaml:"alert_webhook"`
+	// PauseMode is how a hub pause that doesn't name a mode treats
+	// publishes: "buffer" holds them until it resumes, "reject" refuses
+	// them
+	PauseMode string `json:"pause_mode" yaml:"pause_mode"`
 }
 
 // SecurityConfig holds security-related configuration
 type SecurityConfig struct {
-	APIKey          string `json:"api_key"`
-	EnableCORS      bool   `json:"enable_cors"`
-	AllowedOrigins  string `json:"allowed_origins"`
-	RateLimitPerMin int    `json:"rate_limit_per_min"`
-	RateLimitBurst  int    `json:"rate_limit_burst"`
+	APIKey          string `json:"api_key" yaml:"api_key"`
+	EnableCORS      bool   `json:"enable_cors" yaml:"enable_cors"`
+	AllowedOrigins  string `json:"allowed_origins" yaml:"allowed_origins"`
+	RateLimitPerMin int    `json:"rate_limit_per_min" yaml:"rate_limit_per_min"`
+	RateLimitBurst  int    `json:"rate_limit_burst" yaml:"rate_limit_burst"`
+	// AdminKey guards administrative endpoints; falls back to APIKey when empty
+	AdminKey string `json:"admin_key" yaml:"admin_key"`
+	// TenantKeys binds API keys to tenants, as comma-separated tenant=key
+	// pairs. Topics are owned by the tenant whose key created them.
+	TenantKeys string `json:"tenant_keys" yaml:"tenant_keys"`
+	// TenantNamespaces confines each tenant to topics named tenant/topic,
+	// and scopes topic lists and statistics to the caller's namespace
+	TenantNamespaces bool `json:"tenant_namespaces" yaml:"tenant_namespaces"`
+	// TenantMaxTopics caps the topics each tenant may own (0 = unlimited)
+	TenantMaxTopics int `json:"tenant_max_topics" yaml:"tenant_max_topics"`
+}
+
+// DocsConfig holds Swagger documentation configuration
+type DocsConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Host advertised in the spec; empty uses the request's host
+	Host     string `json:"host" yaml:"host"`
+	BasePath string `json:"base_path" yaml:"base_path"`
+}
+
+// ClusterConfig holds multi-node configuration
+type ClusterConfig struct {
+	// WarmFrom is a peer's base URL to copy topics and retained messages
+	// from before accepting connections; empty starts cold
+	WarmFrom    string        `json:"warm_from" yaml:"warm_from"`
+	WarmTimeout time.Duration `json:"warm_timeout" yaml:"warm_timeout"`
+	// NodeID names this broker in the metadata stamped on events of
+	// enriched topics
+	NodeID string `json:"node_id" yaml:"node_id"`
+}
+
+// SinkConfig holds configuration for taps writing topics outside the broker
+type SinkConfig struct {
+	// FileSinks appends topics' events to rotating NDJSON files, as
+	// comma-separated topic=directory pairs
+	FileSinks string `json:"file_sinks" yaml:"file_sinks"`
+	// MaxBytes and RotateInterval start a new file once the current one
+	// reaches the size or age (0 = never)
+	MaxBytes       int64         `json:"max_bytes" yaml:"max_bytes"`
+	RotateInterval time.Duration `json:"rotate_interval" yaml:"rotate_interval"`
+	// Fsync is when written events are forced to disk: always, interval
+	// or never
+	Fsync         string        `json:"fsync" yaml:"fsync"`
+	FsyncInterval time.Duration `json:"fsync_interval" yaml:"fsync_interval"`
 }
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level  string `json:"level"`
-	Format string `json:"format"`
+	Level  string `json:"level" yaml:"level"`
+	Format string `json:"format" yaml:"format"`
 }
 
-// LoadConfig loads configuration from command-line flags and environment variables
-func LoadConfig() *Config {
-	// Define command-line flags
-	var (
-		port            = flag.String("port", getEnv("PORT", "8080"), "Server port")
-		readTimeout     = flag.Duration("read-timeout", getDurationEnv("READ_TIMEOUT", 10*time.Second), "HTTP read timeout")
-		writeTimeout    = flag.Duration("write-timeout", getDurationEnv("WRITE_TIMEOUT", 10*time.Second), "HTTP write timeout")
-		idleTimeout     = flag.Duration("idle-timeout", getDurationEnv("IDLE_TIMEOUT", 60*time.Second), "HTTP idle timeout")
-		shutdownTimeout = flag.Duration("shutdown-timeout", getDurationEnv("SHUTDOWN_TIMEOUT", 10*time.Second), "Graceful shutdown timeout")
-
-		maxQueueSize      = flag.Int("max-queue-size", getIntEnv("MAX_QUEUE_SIZE", 100), "Maximum messages per client queue")
-		ringBufferSize    = flag.Int("ring-buffer-size", getIntEnv("RING_BUFFER_SIZE", 100), "Ring buffer size for message replay")
-		pingInterval      = flag.Duration("ping-interval", getDurationEnv("PING_INTERVAL", 54*time.Second), "WebSocket ping interval")
-		pongWait          = flag.Duration("pong-wait", getDurationEnv("PONG_WAIT", 60*time.Second), "WebSocket pong wait timeout")
-		writeWait         = flag.Duration("write-wait", getDurationEnv("WRITE_WAIT", 10*time.Second), "WebSocket write wait timeout")
-		maxMessageSize    = flag.Int64("max-message-size", getInt64Env("MAX_MESSAGE_SIZE", 1024*1024), "Maximum message size in bytes")
-		enableCompression = flag.Bool("enable-compression", getBoolEnv("ENABLE_COMPRESSION", false), "Enable WebSocket compression")
-
-		apiKey          = flag.String("api-key", getEnv("API_KEY", ""), "API key for authentication")
-		enableCORS      = flag.Bool("enable-cors", getBoolEnv("ENABLE_CORS", false), "Enable CORS support")
-		allowedOrigins  = flag.String("allowed-origins", getEnv("ALLOWED_ORIGINS", "*"), "Comma-separated list of allowed origins")
-		rateLimitPerMin = flag.Int("rate-limit-per-min", getIntEnv("RATE_LIMIT_PER_MIN", 1000), "Rate limit per minute")
-		rateLimitBurst  = flag.Int("rate-limit-burst", getIntEnv("RATE_LIMIT_BURST", 100), "Rate limit burst size")
-
-		logLevel  = flag.String("log-level", getEnv("LOG_LEVEL", "info"), "Log level (debug, info, warn, error)")
-		logFormat = flag.String("log-format", getEnv("LOG_FORMAT", "text"), "Log format (text, json)")
-
-		showVersion = flag.Bool("version", false, "Show version information")
-		showHelp    = flag.Bool("help", false, "Show help information")
-	)
+// DefaultConfig returns the built-in configuration defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			RequestTimeout:  5 * time.Second,
+			ExportTimeout:   60 * time.Second,
+			GRPCPort:        "",
+			MQTTPort:        "",
+			TLSCert:         "",
+			TLSKey:          "",
+			TLSClientCA:     "",
+		},
+		PubSub: PubSubConfig{
+			MaxQueueSize:       100,
+			RingBufferSize:     100,
+			PingInterval:       54 * time.Second,
+			PongWait:           60 * time.Second,
+			WriteWait:          10 * time.Second,
+			MaxMessageSize:     1024 * 1024,
+			ReplayRate:         1000,
+			GenerateMessageIDs: false,
+			EnableCompression:  false,
+			HubRegisterBuffer:  0,
+			HubPublishBuffer:   1024,
+			HubSubscribeBuffer: 0,
+			HubShards:          1,
+			DeliveryWorkers:    0,
+			TopicExecutorQueue: 0,
+			PublishQueuedDepth: 256,
+			PublishRejectDepth: 1024,
+			PublishRetryAfter:  time.Second,
+			DefaultLastN:       0,
+			MaxLastN:           100,
+			DataDir:            "",
+			TrashWindow:        5 * time.Minute,
+			GroupExpiry:        24 * time.Hour,
+			CompressRetained:   0,
+			RetentionBudget:    0,
+			TopicsFile:         "",
+			AlertInterval:      time.Minute,
+			AlertWebhook:       "",
+			PauseMode:          "buffer",
+		},
+		Security: SecurityConfig{
+			APIKey:          "",
+			EnableCORS:      false,
+			AllowedOrigins:  "*",
+			RateLimitPerMin: 1000,
+			RateLimitBurst:  100,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Docs: DocsConfig{
+			Enabled:  false,
+			Host:     "",
+			BasePath: "/",
+		},
+		Cluster: ClusterConfig{
+			WarmFrom:    "",
+			WarmTimeout: 30 * time.Second,
+			NodeID:      defaultNodeID(),
+		},
+		Sinks: SinkConfig{
+			FileSinks:      "",
+			MaxBytes:       100 * 1024 * 1024,
+			RotateInterval: time.Hour,
+			Fsync:          "interval",
+			FsyncInterval:  time.Second,
+		},
+	}
+}
 
-	// Parse command-line flags
-	flag.Parse()
+// defaultNodeID returns the host name, or "" if it can't be determined
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+// LoadConfig loads configuration from the -config file, environment
+// variables and command-line flags, each overriding the one before, and
+// exits if it is invalid
+func LoadConfig() *Config {
+	cfg, cmd, err := parse(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		exitf("Invalid config file: %v", err)
+	}
 
 	// Handle special flags
-	if *showVersion {
+	if cmd.showVersion {
 		printVersion()
 		os.Exit(0)
 	}
 
-	if *showHelp {
+	if cmd.showHelp {
 		printHelp()
 		os.Exit(0)
 	}
 
+	if err := cfg.Validate(); err != nil {
+		exitf("Invalid configuration: %v", err)
+	}
+	if cmd.dumpConfig {
+		if err := cfg.Redacted().WriteYAML(os.Stdout); err != nil {
+			exitf("Failed to print configuration: %v", err)
+		}
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// Reload reads the configuration again from the config file, environment
+// variables and the flags the process was started with, so a running
+// broker can pick up an edited config file. Unlike LoadConfig it returns
+// errors rather than exiting.
+func Reload() (*Config, error) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	cfg, _, err := parse(flags, os.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// commandLine holds the flags that ask for something other than running
+// the broker
+type commandLine struct {
+	showVersion bool
+	showHelp    bool
+	dumpConfig  bool
+}
+
+// parse builds the configuration from the config file args name, the
+// environment and the flags in args, defining the flags on flags. Parse
+// errors are returned unless flags exits on them.
+func parse(flags *flag.FlagSet, args []string) (*Config, commandLine, error) {
+	d := DefaultConfig()
+
+	// A config file supplies the defaults environment variables and flags
+	// override
+	configFile := configFilePath(args)
+	if configFile != "" {
+		if err := d.LoadFile(configFile); err != nil {
+			return nil, commandLine{}, err
+		}
+	}
+
+	// Define command-line flags
+	var (
+		port            = flags.String("port", getEnv("PORT", d.Server.Port), "Server port")
+		readTimeout     = flags.Duration("read-timeout", getDurationEnv("READ_TIMEOUT", d.Server.ReadTimeout), "HTTP read timeout")
+		writeTimeout    = flags.Duration("write-timeout", getDurationEnv("WRITE_TIMEOUT", d.Server.WriteTimeout), "HTTP write timeout")
+		idleTimeout     = flags.Duration("idle-timeout", getDurationEnv("IDLE_TIMEOUT", d.Server.IdleTimeout), "HTTP idle timeout")
+		shutdownTimeout = flags.Duration("shutdown-timeout", getDurationEnv("SHUTDOWN_TIMEOUT", d.Server.ShutdownTimeout), "Graceful shutdown timeout")
+		requestTimeout  = flags.Duration("request-timeout", getDurationEnv("REQUEST_TIMEOUT", d.Server.RequestTimeout), "REST request timeout (0 = unbounded)")
+		exportTimeout   = flags.Duration("export-timeout", getDurationEnv("EXPORT_TIMEOUT", d.Server.ExportTimeout), "Timeout for REST exports such as /cluster/snapshot (0 = unbounded)")
+		grpcPort        = flags.String("grpc-port", getEnv("GRPC_PORT", d.Server.GRPCPort), "gRPC API port (default: gRPC disabled)")
+		mqttPort        = flags.String("mqtt-port", getEnv("MQTT_PORT", d.Server.MQTTPort), "MQTT listener port (default: MQTT disabled)")
+		tlsCert         = flags.String("tls-cert", getEnv("TLS_CERT", d.Server.TLSCert), "PEM certificate to serve HTTPS and WSS with (requires -tls-key)")
+		tlsKey          = flags.String("tls-key", getEnv("TLS_KEY", d.Server.TLSKey), "PEM private key for -tls-cert")
+		tlsClientCA     = flags.String("tls-client-ca", getEnv("TLS_CLIENT_CA", d.Server.TLSClientCA), "PEM CA bundle to require and verify client certificates against (mTLS)")
+
+		maxQueueSize      = flags.Int("max-queue-size", getIntEnv("MAX_QUEUE_SIZE", d.PubSub.MaxQueueSize), "Maximum messages per client queue")
+		ringBufferSize    = flags.Int("ring-buffer-size", getIntEnv("RING_BUFFER_SIZE", d.PubSub.RingBufferSize), "Messages each topic retains for replay")
+		pingInterval      = flags.Duration("ping-interval", getDurationEnv("PING_INTERVAL", d.PubSub.PingInterval), "WebSocket ping interval")
+		pongWait          = flags.Duration("pong-wait", getDurationEnv("PONG_WAIT", d.PubSub.PongWait), "WebSocket pong wait timeout")
+		writeWait         = flags.Duration("write-wait", getDurationEnv("WRITE_WAIT", d.PubSub.WriteWait), "WebSocket write wait timeout")
+		maxMessageSize    = flags.Int64("max-message-size", getInt64Env("MAX_MESSAGE_SIZE", d.PubSub.MaxMessageSize), "Maximum message size in bytes")
+		replayRate        = flags.Int("replay-rate", getIntEnv("REPLAY_RATE", d.PubSub.ReplayRate), "Backlog messages per second delivered on last_n replay (0 = unpaced)")
+		generateIDs       = flags.Bool("generate-message-ids", getBoolEnv("GENERATE_MESSAGE_IDS", d.PubSub.GenerateMessageIDs), "Generate sortable IDs for publishes without a message ID")
+		enableCompression = flags.Bool("enable-compression", getBoolEnv("ENABLE_COMPRESSION", d.PubSub.EnableCompression), "Enable WebSocket compression")
+		registerBuffer    = flags.Int("hub-register-buffer", getIntEnv("HUB_REGISTER_BUFFER", d.PubSub.HubRegisterBuffer), "Capacity of the hub register/unregister channels")
+		publishBuffer     = flags.Int("hub-publish-buffer", getIntEnv("HUB_PUBLISH_BUFFER", d.PubSub.HubPublishBuffer), "Publishes each topic may queue for fan-out before publishers block")
+		subscribeBuffer   = flags.Int("hub-subscribe-buffer", getIntEnv("HUB_SUBSCRIBE_BUFFER", d.PubSub.HubSubscribeBuffer), "Capacity of the hub subscribe/unsubscribe channels")
+		hubShards         = flags.Int("hub-shards", getIntEnv("HUB_SHARDS", d.PubSub.HubShards), "Loops fanning out publishes, each serving the topics that hash to it")
+		deliveryWorkers   = flags.Int("delivery-workers", getIntEnv("DELIVERY_WORKERS", d.PubSub.DeliveryWorkers), "Workers delivering fanned-out events to subscribers (0 = deliver inline)")
+		executorQueue     = flags.Int("topic-executor-queue", getIntEnv("TOPIC_EXECUTOR_QUEUE", d.PubSub.TopicExecutorQueue), "Publishes each topic's own fan-out executor queues before the topic is passed over (0 = fan out on the shard loops)")
+		queuedDepth       = flags.Int("publish-queued-depth", getIntEnv("PUBLISH_QUEUED_DEPTH", d.PubSub.PublishQueuedDepth), "Topic publish backlog at which REST publishes return 202 Accepted")
+		rejectDepth       = flags.Int("publish-reject-depth", getIntEnv("PUBLISH_REJECT_DEPTH", d.PubSub.PublishRejectDepth), "Topic publish backlog at which REST publishes return 503")
+		orderingAudit     = flags.Bool("ordering-audit", getBoolEnv("ORDERING_AUDIT", d.PubSub.OrderingAudit), "Verify live event ordering per subscriber and stamp audit_seq (debug)")
+		retryAfter        = flags.Duration("publish-retry-after", getDurationEnv("PUBLISH_RETRY_AFTER", d.PubSub.PublishRetryAfter), "Retry-After sent with 503 REST publish responses")
+		defaultLastN      = flags.Int("default-last-n", getIntEnv("DEFAULT_LAST_N", d.PubSub.DefaultLastN), "Messages replayed when a subscribe omits last_n")
+		maxLastN          = flags.Int("max-last-n", getIntEnv("MAX_LAST_N", d.PubSub.MaxLastN), "Maximum last_n replayed per subscribe (0 = replay buffer size)")
+		dataDir           = flags.String("data-dir", getEnv("DATA_DIR", d.PubSub.DataDir), "Directory to persist topics and retained messages in (default: memory only)")
+		trashWindow       = flags.Duration("trash-window", getDurationEnv("TRASH_WINDOW", d.PubSub.TrashWindow), "How long deleted topics can be restored (0 = delete immediately)")
+		groupExpiry       = flags.Duration("group-expiry", getDurationEnv("GROUP_EXPIRY", d.PubSub.GroupExpiry), "Drop consumer groups without connected members for this long (0 = never)")
+		retentionBudget   = flags.Int64("retention-budget", getInt64Env("RETENTION_BUDGET", d.PubSub.RetentionBudget), "Approximate bytes retained messages may hold across all topics (0 = unbounded)")
+		compressRetained  = flags.Int("compress-retained", getIntEnv("COMPRESS_RETAINED", d.PubSub.CompressRetained), "Keep retained payloads of at least this many bytes compressed in memory (0 = never)")
+		topicsFile        = flags.String("topics-file", getEnv("TOPICS_FILE", d.PubSub.TopicsFile), "JSON file of topics to create at startup if they don't exist")
+		alertInterval     = flags.Duration("alert-interval", getDurationEnv("ALERT_INTERVAL", d.PubSub.AlertInterval), "Window anomaly alerts on $SYS/alerts compare topics over (0 = no alerts)")
+		alertWebhook      = flags.String("alert-webhook", getEnv("ALERT_WEBHOOK", d.PubSub.AlertWebhook), "URL to POST every $SYS/alerts event to")
+		pauseMode         = flags.String("pause-mode", getEnv("PAUSE_MODE", d.PubSub.PauseMode), "How a hub pause treats publishes by default: buffer or reject")
+
+		apiKey          = flags.String("api-key", getEnv("API_KEY", d.Security.APIKey), "API key for authentication")
+		enableCORS      = flags.Bool("enable-cors", getBoolEnv("ENABLE_CORS", d.Security.EnableCORS), "Let browser pages from -allowed-origins call the REST API and open WebSockets")
+		allowedOrigins  = flags.String("allowed-origins", getEnv("ALLOWED_ORIGINS", d.Security.AllowedOrigins), "Comma-separated origins allowed with -enable-cors: exact origins, https://*.example.com subdomain wildcards, or * for any")
+		rateLimitPerMin = flags.Int("rate-limit-per-min", getIntEnv("RATE_LIMIT_PER_MIN", d.Security.RateLimitPerMin), "REST requests per caller IP and WebSocket publishes per client allowed per minute (0 = unlimited)")
+		rateLimitBurst  = flags.Int("rate-limit-burst", getIntEnv("RATE_LIMIT_BURST", d.Security.RateLimitBurst), "Requests or publishes allowed at once before the per-minute rate applies")
+		adminKey        = flags.String("admin-key", getEnv("ADMIN_KEY", d.Security.AdminKey), "Admin credential for documentation and admin endpoints (default: the API key)")
+		tenantKeys      = flags.String("tenant-keys", getEnv("TENANT_KEYS", d.Security.TenantKeys), "Comma-separated tenant=key pairs binding API keys to topic-owning tenants")
+		tenantNS        = flags.Bool("tenant-namespaces", getBoolEnv("TENANT_NAMESPACES", d.Security.TenantNamespaces), "Confine each tenant to topics named tenant/topic and scope topic lists and stats to its namespace")
+		tenantMaxTopics = flags.Int("tenant-max-topics", getIntEnv("TENANT_MAX_TOPICS", d.Security.TenantMaxTopics), "Most topics each tenant may own (0 = unlimited)")
+
+		logLevel  = flags.String("log-level", getEnv("LOG_LEVEL", d.Logging.Level), "Log level (debug, info, warn, error)")
+		logFormat = flags.String("log-format", getEnv("LOG_FORMAT", d.Logging.Format), "Log format (text, json)")
+
+		enableDocs   = flags.Bool("enable-docs", getBoolEnv("ENABLE_DOCS", d.Docs.Enabled), "Serve Swagger UI and doc.json behind the admin credential")
+		docsHost     = flags.String("docs-host", getEnv("DOCS_HOST", d.Docs.Host), "Host advertised in the API spec (default: the request's host)")
+		docsBasePath = flags.String("docs-base-path", getEnv("DOCS_BASE_PATH", d.Docs.BasePath), "Base path advertised in the API spec")
+
+		warmFrom    = flags.String("warm-from", getEnv("WARM_FROM", d.Cluster.WarmFrom), "Peer base URL to copy topics and retained messages from at startup")
+		warmTimeout = flags.Duration("warm-timeout", getDurationEnv("WARM_TIMEOUT", d.Cluster.WarmTimeout), "How long to wait for the peer snapshot before starting cold")
+		nodeID      = flags.String("node-id", getEnv("NODE_ID", d.Cluster.NodeID), "Broker node ID stamped on events of enriched topics")
+
+		fileSinks          = flags.String("file-sinks", getEnv("FILE_SINKS", d.Sinks.FileSinks), "Comma-separated topic=directory pairs appending topics' events to rotating NDJSON files")
+		sinkMaxBytes       = flags.Int64("sink-max-bytes", getInt64Env("SINK_MAX_BYTES", d.Sinks.MaxBytes), "Start a new sink file once the current one reaches this many bytes (0 = never)")
+		sinkRotateInterval = flags.Duration("sink-rotate-interval", getDurationEnv("SINK_ROTATE_INTERVAL", d.Sinks.RotateInterval), "Start a new sink file once the current one is this old (0 = never)")
+		sinkFsync          = flags.String("sink-fsync", getEnv("SINK_FSYNC", d.Sinks.Fsync), "When sink files are synced to disk (always, interval, never)")
+		sinkFsyncInterval  = flags.Duration("sink-fsync-interval", getDurationEnv("SINK_FSYNC_INTERVAL", d.Sinks.FsyncInterval), "How often sink files are synced with -sink-fsync interval")
+
+		_          = flags.String("config", configFile, "YAML or JSON config file, overridden by environment variables and flags")
+		dumpConfig = flags.Bool("dump-config", false, "Print the effective configuration as YAML, with keys redacted, and exit")
+
+		showVersion = flags.Bool("version", false, "Show version information")
+		showHelp    = flags.Bool("help", false, "Show help information")
+	)
+
+	// Parse command-line flags
+	if err := flags.Parse(args); err != nil {
+		return nil, commandLine{}, err
+	}
+
 	// Create configuration from flags
-	return &Config{
+	cfg := &Config{
 		Server: ServerConfig{
 			Port:            *port,
 			ReadTimeout:     *readTimeout,
 			WriteTimeout:    *writeTimeout,
 			IdleTimeout:     *idleTimeout,
 			ShutdownTimeout: *shutdownTimeout,
+			RequestTimeout:  *requestTimeout,
+			ExportTimeout:   *exportTimeout,
+			GRPCPort:        *grpcPort,
+			MQTTPort:        *mqttPort,
+			TLSCert:         *tlsCert,
+			TLSKey:          *tlsKey,
+			TLSClientCA:     *tlsClientCA,
 		},
 		PubSub: PubSubConfig{
-			MaxQueueSize:      *maxQueueSize,
-			RingBufferSize:    *ringBufferSize,
-			PingInterval:      *pingInterval,
-			PongWait:          *pongWait,
-			WriteWait:         *writeWait,
-			MaxMessageSize:    *maxMessageSize,
-			EnableCompression: *enableCompression,
+			MaxQueueSize:       *maxQueueSize,
+			RingBufferSize:     *ringBufferSize,
+			PingInterval:       *pingInterval,
+			PongWait:           *pongWait,
+			WriteWait:          *writeWait,
+			MaxMessageSize:     *maxMessageSize,
+			ReplayRate:         *replayRate,
+			GenerateMessageIDs: *generateIDs,
+			EnableCompression:  *enableCompression,
+			HubRegisterBuffer:  *registerBuffer,
+			HubPublishBuffer:   *publishBuffer,
+			HubSubscribeBuffer: *subscribeBuffer,
+			HubShards:          *hubShards,
+			DeliveryWorkers:    *deliveryWorkers,
+			TopicExecutorQueue: *executorQueue,
+			PublishQueuedDepth: *queuedDepth,
+			PublishRejectDepth: *rejectDepth,
+			PublishRetryAfter:  *retryAfter,
+			OrderingAudit:      *orderingAudit,
+			DefaultLastN:       *defaultLastN,
+			MaxLastN:           *maxLastN,
+			DataDir:            *dataDir,
+			TrashWindow:        *trashWindow,
+			GroupExpiry:        *groupExpiry,
+			CompressRetained:   *compressRetained,
+			RetentionBudget:    *retentionBudget,
+			TopicsFile:         *topicsFile,
+			AlertInterval:      *alertInterval,
+			AlertWebhook:       *alertWebhook,
+			PauseMode:          *pauseMode,
 		},
 		Security: SecurityConfig{
-			APIKey:          *apiKey,
-			EnableCORS:      *enableCORS,
-			AllowedOrigins:  *allowedOrigins,
-			RateLimitPerMin: *rateLimitPerMin,
-			RateLimitBurst:  *rateLimitBurst,
+			APIKey:           *apiKey,
+			EnableCORS:       *enableCORS,
+			AllowedOrigins:   *allowedOrigins,
+			RateLimitPerMin:  *rateLimitPerMin,
+			RateLimitBurst:   *rateLimitBurst,
+			AdminKey:         *adminKey,
+			TenantKeys:       *tenantKeys,
+			TenantNamespaces: *tenantNS,
+			TenantMaxTopics:  *tenantMaxTopics,
 		},
 		Logging: LoggingConfig{
 			Level:  *logLevel,
 			Format: *logFormat,
 		},
+		Docs: DocsConfig{
+			Enabled:  *enableDocs,
+			Host:     *docsHost,
+			BasePath: *docsBasePath,
+		},
+		Cluster: ClusterConfig{
+			WarmFrom:    *warmFrom,
+			WarmTimeout: *warmTimeout,
+			NodeID:      *nodeID,
+		},
+		Sinks: SinkConfig{
+			FileSinks:      *fileSinks,
+			MaxBytes:       *sinkMaxBytes,
+			RotateInterval: *sinkRotateInterval,
+			Fsync:          *sinkFsync,
+			FsyncInterval:  *sinkFsyncInterval,
+		},
+		file:    configFile,
+		sources: settingSources(flags, configFile),
+	}
+
+	return cfg, commandLine{showVersion: *showVersion, showHelp: *showHelp, dumpConfig: *dumpConfig}, nil
+}
+
+// exitf reports a configuration error and exits, as flag parsing errors do
+func exitf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(2)
+}
+
+// Tenants parses TenantKeys into a map from API key to tenant name. Tenant
+// names and keys must be non-empty and keys unique.
+func (s SecurityConfig) Tenants() (map[string]string, error) {
+	tenants := make(map[string]string)
+	if strings.TrimSpace(s.TenantKeys) == "" {
+		return tenants, nil
+	}
+	for _, pair := range strings.Split(s.TenantKeys, ",") {
+		tenant, key, found := strings.Cut(strings.TrimSpace(pair), "=")
+		tenant = strings.TrimSpace(tenant)
+		key = strings.TrimSpace(key)
+		if !found || tenant == "" || key == "" {
+			return nil, fmt.Errorf("tenant key %q: expected tenant=key", pair)
+		}
+		if s.TenantNamespaces && strings.Contains(tenant, "/") {
+			return nil, fmt.Errorf("tenant %s: namespaced tenant names must not contain /", tenant)
+		}
+		if _, exists := tenants[key]; exists {
+			return nil, fmt.Errorf("tenant %s: key is already bound to tenant %s", tenant, tenants[key])
+		}
+		if key == s.APIKey || key == s.AdminKey {
+			return nil, fmt.Errorf("tenant %s: key is also the API or admin key", tenant)
+		}
+		tenants[key] = tenant
 	}
+	return tenants, nil
+}
+
+// Files parses FileSinks into a map from topic to the directory its sink
+// writes to. Topics and directories must be non-empty and topics unique.
+func (s SinkConfig) Files() (map[string]string, error) {
+	files := make(map[string]string)
+	if strings.TrimSpace(s.FileSinks) == "" {
+		return files, nil
+	}
+	for _, pair := range strings.Split(s.FileSinks, ",") {
+		topic, dir, found := strings.Cut(strings.TrimSpace(pair), "=")
+		topic = strings.TrimSpace(topic)
+		dir = strings.TrimSpace(dir)
+		if !found || topic == "" || dir == "" {
+			return nil, fmt.Errorf("file sink %q: expected topic=directory", pair)
+		}
+		if _, exists := files[topic]; exists {
+			return nil, fmt.Errorf("file sink %s: topic has more than one sink", topic)
+		}
+		files[topic] = dir
+	}
+	return files, nil
 }
 
 // printVersion prints version information
 func printVersion() {
-	println("Plivo Pub/Sub System v1.0.0")
+	println("Plivo Pub/Sub System " + version.Get().String())
 	println("A production-ready in-memory Pub/Sub system with WebSocket and REST API support")
 }
 
@@ -147,6 +581,8 @@ func printHelp() {
 	println("")
 	println("Usage:")
 	println("  plivo [flags]")
+	println("  plivo check [-url base-url] [-api-key key] [-timeout duration]")
+	println("  plivo soak [-duration duration] [-workers n] [-topics n] [-max-heap-mb n] [-report-interval duration]")
 	println("")
 	println("Server Configuration:")
 	println("  -port string")
@@ -159,12 +595,26 @@ func printHelp() {
 	println("        HTTP idle timeout (default \"60s\")")
 	println("  -shutdown-timeout duration")
 	println("        Graceful shutdown timeout (default \"10s\")")
+	println("  -request-timeout duration")
+	println("        REST request timeout (0 = unbounded) (default \"5s\")")
+	println("  -export-timeout duration")
+	println("        Timeout for REST exports such as /cluster/snapshot (0 = unbounded) (default \"60s\")")
+	println("  -grpc-port string")
+	println("        gRPC API port (default: gRPC disabled)")
+	println("  -mqtt-port string")
+	println("        MQTT listener port (default: MQTT disabled)")
+	println("  -tls-cert string")
+	println("        PEM certificate to serve HTTPS and WSS with, requires -tls-key (default: plain HTTP)")
+	println("  -tls-key string")
+	println("        PEM private key for -tls-cert")
+	println("  -tls-client-ca string")
+	println("        PEM CA bundle client certificates must be signed by; requires one from every client (default: none)")
 	println("")
 	println("Pub/Sub Configuration:")
 	println("  -max-queue-size int")
 	println("        Maximum messages per client queue (default 100)")
 	println("  -ring-buffer-size int")
-	println("        Ring buffer size for message replay (default 100)")
+	println("        Messages each topic retains for replay, unless its retention sets max_messages (default 100)")
 	println("  -ping-interval duration")
 	println("        WebSocket ping interval (default \"54s\")")
 	println("  -pong-wait duration")
@@ -173,20 +623,70 @@ func printHelp() {
 	println("        WebSocket write wait timeout (default \"10s\")")
 	println("  -max-message-size int")
 	println("        Maximum message size in bytes (default 1048576)")
+	println("  -replay-rate int")
+	println("        Backlog messages per second delivered on last_n replay, 0 = unpaced (default 1000)")
+	println("  -generate-message-ids")
+	println("        Generate sortable IDs (ULIDs) for publishes without a message ID (default false)")
 	println("  -enable-compression")
 	println("        Enable WebSocket compression (default false)")
+	println("  -hub-register-buffer int")
+	println("        Capacity of the hub register/unregister channels (default 0)")
+	println("  -hub-publish-buffer int")
+	println("        Publishes each topic may queue for fan-out before publishers block (default 1024)")
+	println("  -hub-subscribe-buffer int")
+	println("        Capacity of the hub subscribe/unsubscribe channels (default 0)")
+	println("  -hub-shards int")
+	println("        Loops fanning out publishes, each serving the topics that hash to it (default 1)")
+	println("  -delivery-workers int")
+	println("        Workers delivering fanned-out events to subscribers (0 = deliver inline) (default 0)")
+	println("  -topic-executor-queue int")
+	println("        Publishes each topic's own fan-out executor queues before the topic is passed over (0 = fan out on the shard loops) (default 0)")
+	println("  -publish-queued-depth int")
+	println("        Topic publish backlog at which REST publishes return 202 Accepted (default 256)")
+	println("  -publish-reject-depth int")
+	println("        Topic publish backlog at which REST publishes return 503 (default 1024)")
+	println("  -publish-retry-after duration")
+	println("        Retry-After sent with 503 REST publish responses (default \"1s\")")
+	println("  -ordering-audit")
+	println("        Verify live event ordering per subscriber and stamp audit_seq, for debug and soak runs (default false)")
+	println("  -default-last-n int")
+	println("        Messages replayed when a subscribe omits last_n; topics may override (default 0)")
+	println("  -max-last-n int")
+	println("        Maximum last_n replayed per subscribe, larger requests are capped; topics may override (default 100)")
+	println("  -data-dir string")
+	println("        Directory to persist topics and retained messages in (default: memory only)")
+	println("  -trash-window duration")
+	println("        How long deleted topics can be restored (0 = delete immediately) (default 5m0s)")
+	println("  -group-expiry duration")
+	println("        Drop consumer groups without connected members for this long (0 = never) (default 24h0m0s)")
+	println("  -retention-budget int")
+	println("        Approximate bytes retained messages may hold across all topics (0 = unbounded) (default 0)")
+	println("  -compress-retained int")
+	println("        Keep retained payloads of at least this many bytes compressed in memory (0 = never) (default 0)")
+	println("  -topics-file string")
+	println("        JSON file of topics, with their settings, to create at startup if they don't exist (default: none)")
+	println("  -alert-interval duration")
+	println("        Window anomaly alerts on $SYS/alerts compare topics over; 0 disables them (default 1m0s)")
+	println("  -alert-webhook string")
+	println("        URL to POST every $SYS/alerts event to (default: none)")
+	println("  -pause-mode string")
+	println("        How a hub pause treats publishes unless it names a mode: buffer holds them until it resumes, reject refuses them (default \"buffer\")")
 	println("")
 	println("Security Configuration:")
 	println("  -api-key string")
 	println("        API key for authentication (default \"\")")
 	println("  -enable-cors")
-	println("        Enable CORS support (default false)")
+	println("        Let browser pages from -allowed-origins call the REST API and open WebSockets (default false)")
 	println("  -allowed-origins string")
-	println("        Comma-separated list of allowed origins (default \"*\")")
+	println("        Comma-separated origins allowed with -enable-cors: exact origins, https://*.example.com subdomain wildcards, or * for any (default \"*\")")
 	println("  -rate-limit-per-min int")
-	println("        Rate limit per minute (default 1000)")
+	println("        REST requests per caller IP and WebSocket publishes per client allowed per minute, 0 = unlimited (default 1000)")
 	println("  -rate-limit-burst int")
-	println("        Rate limit burst size (default 100)")
+	println("        Requests or publishes allowed at once before the per-minute rate applies (default 100)")
+	println("  -admin-key string")
+	println("        Admin credential for documentation and admin endpoints (default: the API key)")
+	println("  -tenant-keys string")
+	println("        Comma-separated tenant=key pairs binding API keys to topic-owning tenants")
 	println("")
 	println("Logging Configuration:")
 	println("  -log-level string")
@@ -194,7 +694,39 @@ func printHelp() {
 	println("  -log-format string")
 	println("        Log format (text, json) (default \"text\")")
 	println("")
+	println("Documentation:")
+	println("  -enable-docs")
+	println("        Serve Swagger UI and doc.json behind the admin credential (default false)")
+	println("  -docs-host string")
+	println("        Host advertised in the API spec (default: the request's host)")
+	println("  -docs-base-path string")
+	println("        Base path advertised in the API spec (default \"/\")")
+	println("")
+	println("Cluster:")
+	println("  -warm-from string")
+	println("        Peer base URL to copy topics and retained messages from at startup")
+	println("  -warm-timeout duration")
+	println("        How long to wait for the peer snapshot before starting cold (default 30s)")
+	println("  -node-id string")
+	println("        Broker node ID stamped on events of enriched topics (default: the host name)")
+	println("")
+	println("Sinks:")
+	println("  -file-sinks string")
+	println("        Comma-separated topic=directory pairs appending topics' events to rotating NDJSON files")
+	println("  -sink-max-bytes int")
+	println("        Start a new sink file once the current one reaches this many bytes (0 = never) (default 104857600)")
+	println("  -sink-rotate-interval duration")
+	println("        Start a new sink file once the current one is this old (0 = never) (default 1h0m0s)")
+	println("  -sink-fsync string")
+	println("        When sink files are synced to disk: always, interval or never (default \"interval\")")
+	println("  -sink-fsync-interval duration")
+	println("        How often sink files are synced with -sink-fsync interval (default 1s)")
+	println("")
 	println("Other:")
+	println("  -config string")
+	println("        YAML or JSON config file; environment variables and flags override its settings (default: $CONFIG_FILE)")
+	println("  -dump-config")
+	println("        Print the effective configuration as YAML, with keys redacted, and exit")
 	println("  -help")
 	println("        Show help information")
 	println("  -version")
@@ -203,6 +735,8 @@ func printHelp() {
 	println("Environment Variables:")
 	println("  All flags can also be set via environment variables with the same names in uppercase.")
 	println("  For example: PORT=8080, API_KEY=secret, LOG_LEVEL=debug")
+	println("  Precedence, lowest first: built-in defaults, -config file, environment variables, flags.")
+	println("  SIGHUP reloads the log level, API and tenant keys and rate limits without a restart.")
 }
 
 // Helper functions for environment variable parsing
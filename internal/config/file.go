@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// redacted replaces secrets in dumped configurations
+const redacted = "<redacted>"
+
+// LoadFile overlays the settings in a YAML or JSON file onto the
+// configuration. Keys follow the JSON field names, such as
+// server.read_timeout, and durations are strings such as "10s". Settings the
+// file leaves out keep their current values; unknown keys are rejected.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML, so one decoder reads both
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// configFilePath finds the -config flag among the command-line arguments
+// before they are parsed, since the file supplies the defaults flags and
+// environment variables override. It falls back to CONFIG_FILE.
+func configFilePath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Validate checks that sizes, limits and timeouts are in range and that
+// ports are port numbers
+func (c *Config) Validate() error {
+	ports := []struct {
+		name     string
+		value    string
+		optional bool
+	}{
+		{"port", c.Server.Port, false},
+		{"grpc port", c.Server.GRPCPort, true},
+		{"mqtt port", c.Server.MQTTPort, true},
+	}
+	for _, port := range ports {
+		if port.value == "" && port.optional {
+			continue
+		}
+		if n, err := strconv.Atoi(port.value); err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("%s must be a port number, got %q", port.name, port.value)
+		}
+	}
+
+	sizes := []struct {
+		name     string
+		value    int64
+		positive bool
+	}{
+		{"max queue size", int64(c.PubSub.MaxQueueSize), true},
+		{"ring buffer size", int64(c.PubSub.RingBufferSize), true},
+		{"hub shards", int64(c.PubSub.HubShards), true},
+		{"delivery workers", int64(c.PubSub.DeliveryWorkers), false},
+		{"topic executor queue", int64(c.PubSub.TopicExecutorQueue), false},
+		{"max message size", c.PubSub.MaxMessageSize, true},
+		{"replay rate", int64(c.PubSub.ReplayRate), false},
+		{"publish queued depth", int64(c.PubSub.PublishQueuedDepth), false},
+		{"publish reject depth", int64(c.PubSub.PublishRejectDepth), false},
+		{"default last_n", int64(c.PubSub.DefaultLastN), false},
+		{"max last_n", int64(c.PubSub.MaxLastN), false},
+		{"retention budget", c.PubSub.RetentionBudget, false},
+		{"compress retained", int64(c.PubSub.CompressRetained), false},
+		{"rate limit per minute", int64(c.Security.RateLimitPerMin), false},
+		{"rate limit burst", int64(c.Security.RateLimitBurst), false},
+		{"tenant max topics", int64(c.Security.TenantMaxTopics), false},
+		{"sink max bytes", c.Sinks.MaxBytes, false},
+	}
+	for _, size := range sizes {
+		if size.positive && size.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", size.name, size.value)
+		}
+		if size.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", size.name, size.value)
+		}
+	}
+	if c.PubSub.PauseMode != "buffer" && c.PubSub.PauseMode != "reject" {
+		return fmt.Errorf("pause mode must be buffer or reject, got %q", c.PubSub.PauseMode)
+	}
+	if c.PubSub.MaxLastN > c.PubSub.RingBufferSize || c.PubSub.DefaultLastN > c.PubSub.RingBufferSize {
+		return fmt.Errorf("default and max last_n must not exceed the ring buffer size of %d", c.PubSub.RingBufferSize)
+	}
+
+	durations := []struct {
+		name     string
+		value    time.Duration
+		positive bool
+	}{
+		{"shutdown timeout", c.Server.ShutdownTimeout, true},
+		{"read timeout", c.Server.ReadTimeout, false},
+		{"write timeout", c.Server.WriteTimeout, false},
+		{"idle timeout", c.Server.IdleTimeout, false},
+		{"request timeout", c.Server.RequestTimeout, false},
+		{"export timeout", c.Server.ExportTimeout, false},
+		{"publish retry after", c.PubSub.PublishRetryAfter, false},
+		{"trash window", c.PubSub.TrashWindow, false},
+		{"group expiry", c.PubSub.GroupExpiry, false},
+		{"alert interval", c.PubSub.AlertInterval, false},
+		{"warm timeout", c.Cluster.WarmTimeout, false},
+		{"sink rotate interval", c.Sinks.RotateInterval, false},
+		{"sink fsync interval", c.Sinks.FsyncInterval, false},
+	}
+	for _, duration := range durations {
+		if duration.positive && duration.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", duration.name, duration.value)
+		}
+		if duration.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %s", duration.name, duration.value)
+		}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with its API, admin and
+// tenant keys and its alert webhook URL, which may carry a token, replaced,
+// for printing
+func (c *Config) Redacted() *Config {
+	copied := *c
+	for _, secret := range []*string{&copied.Security.APIKey, &copied.Security.AdminKey, &copied.Security.TenantKeys, &copied.PubSub.AlertWebhook} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return &copied
+}
+
+// WriteYAML writes the configuration as a YAML file LoadFile reads back
+func (c *Config) WriteYAML(w io.Writer) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Changed lists the settings that differ between two configurations by
+// their config file keys, such as security.rate_limit_per_min, in the order
+// they are declared
+func Changed(before, after *Config) []string {
+	return changedFields("", reflect.ValueOf(*before), reflect.ValueOf(*after))
+}
+
+func changedFields(prefix string, before, after reflect.Value) []string {
+	var changed []string
+	for i := 0; i < before.NumField(); i++ {
+		if !before.Type().Field(i).IsExported() {
+			continue
+		}
+		key := prefix + strings.Split(before.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if before.Field(i).Kind() == reflect.Struct {
+			changed = append(changed, changedFields(key+".", before.Field(i), after.Field(i))...)
+			continue
+		}
+		if before.Field(i).Interface() != after.Field(i).Interface() {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "server:\n  port: \"9000\"\n  read_timeout: 3s\npubsub:\n  max_queue_size: 500\n",
+		"config.json": `{"server": {"port": "9000", "read_timeout": "3s"}, "pubsub": {"max_queue_size": 500}}`,
+	}
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if err := cfg.LoadFile(writeConfigFile(t, name, contents)); err != nil {
+				t.Fatalf("LoadFile failed: %v", err)
+			}
+			if cfg.Server.Port != "9000" || cfg.Server.ReadTimeout != 3*time.Second || cfg.PubSub.MaxQueueSize != 500 {
+				t.Errorf("Expected the file's settings, got %+v %+v", cfg.Server, cfg.PubSub)
+			}
+			if cfg.Server.WriteTimeout != DefaultConfig().Server.WriteTimeout {
+				t.Errorf("Expected settings the file leaves out to keep their defaults, got %s", cfg.Server.WriteTimeout)
+			}
+		})
+	}
+
+	cfg := DefaultConfig()
+	if err := cfg.LoadFile(writeConfigFile(t, "typo.yaml", "pubsub:\n  max_queu_size: 5\n")); err == nil || !strings.Contains(err.Error(), "max_queu_size") {
+		t.Errorf("Expected unknown keys rejected, got %v", err)
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "env.yaml")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-port", "9000", "-config", "a.yaml"}, "a.yaml"},
+		{[]string{"--config=b.json"}, "b.json"},
+		{[]string{"-port", "9000"}, "env.yaml"},
+		{[]string{"--", "-config", "c.yaml"}, "env.yaml"},
+	}
+	for _, tt := range tests {
+		if got := configFilePath(tt.args); got != tt.want {
+			t.Errorf("configFilePath(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("Expected the defaults to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr string
+	}{
+		{"zero queue size", func(c *Config) { c.PubSub.MaxQueueSize = 0 }, "max queue size must be positive"},
+		{"zero hub shards", func(c *Config) { c.PubSub.HubShards = 0 }, "hub shards must be positive"},
+		{"negative delivery workers", func(c *Config) { c.PubSub.DeliveryWorkers = -1 }, "delivery workers must not be negative"},
+		{"negative topic executor queue", func(c *Config) { c.PubSub.TopicExecutorQueue = -1 }, "topic executor queue must not be negative"},
+		{"negative timeout", func(c *Config) { c.Server.RequestTimeout = -time.Second }, "request timeout must not be negative"},
+		{"bad port", func(c *Config) { c.Server.Port = "http" }, "port must be a port number"},
+		{"port out of range", func(c *Config) { c.Server.GRPCPort = "70000" }, "grpc port must be a port number"},
+		{"unknown pause mode", func(c *Config) { c.PubSub.PauseMode = "drop" }, "pause mode must be buffer or reject"},
+		{"replay beyond ring buffer", func(c *Config) { c.PubSub.RingBufferSize = 50 }, "must not exceed the ring buffer size of 50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.change(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDumpRoundTrips(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Security.APIKey = "secret"
+	cfg.PubSub.TrashWindow = 90 * time.Second
+
+	var dump bytes.Buffer
+	if err := cfg.Redacted().WriteYAML(&dump); err != nil {
+		t.Fatalf("WriteYAML failed: %v", err)
+	}
+	if strings.Contains(dump.String(), "secret") {
+		t.Errorf("Expected the API key redacted, got:\n%s", dump.String())
+	}
+	if cfg.Security.APIKey != "secret" {
+		t.Error("Expected redacting to leave the configuration alone")
+	}
+
+	loaded := DefaultConfig()
+	if err := loaded.LoadFile(writeConfigFile(t, "dump.yaml", dump.String())); err != nil {
+		t.Fatalf("Failed to load the dump: %v", err)
+	}
+	if loaded.PubSub.TrashWindow != 90*time.Second || loaded.Security.APIKey != redacted {
+		t.Errorf("Expected the dump to load back, got trash window %s and API key %q", loaded.PubSub.TrashWindow, loaded.Security.APIKey)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	before := DefaultConfig()
+	after := DefaultConfig()
+	after.Logging.Level = "debug"
+	after.Security.RateLimitPerMin = 30
+	after.Server.Port = "9000"
+
+	want := []string{"server.port", "security.rate_limit_per_min", "logging.level"}
+	if got := Changed(before, after); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Changed() = %v, want %v", got, want)
+	}
+	if got := Changed(before, DefaultConfig()); len(got) != 0 {
+		t.Errorf("Expected nothing changed, got %v", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "logging:\n  level: warn\n")
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"plivo", "-config", path, "-rate-limit-per-min", "30"}
+
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if cfg.Logging.Level != "warn" || cfg.Security.RateLimitPerMin != 30 {
+		t.Errorf("Expected the file's and the flags' settings, got %+v %+v", cfg.Logging, cfg.Security)
+	}
+
+	// An edited file is read again, and errors are returned, not exited on
+	os.WriteFile(path, []byte("logging:\n  level: warn\npubsub:\n  ring_buffer_size: 500\n"), 0o644)
+	next, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := Changed(cfg, next); next.PubSub.RingBufferSize != 500 || strings.Join(got, ",") != "pubsub.ring_buffer_size" {
+		t.Errorf("Expected the ring buffer size changed to 500, got %d (changed %v)", next.PubSub.RingBufferSize, got)
+	}
+	os.WriteFile(path, []byte("pubsub:\n  max_queue_size: 0\n"), 0o644)
+	if _, err := Reload(); err == nil || !strings.Contains(err.Error(), "max queue size") {
+		t.Errorf("Expected the invalid file refused, got %v", err)
+	}
+	os.Args = []string{"plivo", "-no-such-flag"}
+	if _, err := Reload(); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
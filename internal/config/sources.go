@@ -0,0 +1,189 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Where a setting's value came from, lowest precedence first
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// flagKeyAliases maps the flags whose names don't follow their config file
+// keys, as -read-timeout does server.read_timeout, to the keys
+var flagKeyAliases = map[string]string{
+	"log-level":            "logging.level",
+	"log-format":           "logging.format",
+	"enable-docs":          "docs.enabled",
+	"docs-host":            "docs.host",
+	"docs-base-path":       "docs.base_path",
+	"sink-max-bytes":       "sinks.max_bytes",
+	"sink-rotate-interval": "sinks.rotate_interval",
+	"sink-fsync":           "sinks.fsync",
+	"sink-fsync-interval":  "sinks.fsync_interval",
+}
+
+// Setting is one configuration value, where it came from, and the flag and
+// environment variable that set it
+type Setting struct {
+	// Key is the config file key, such as server.read_timeout
+	Key string `json:"key"`
+	// Value is the effective value; durations are strings such as "10s"
+	Value interface{} `json:"value"`
+	// Source is default, file, env or flag
+	Source string `json:"source"`
+	Flag   string `json:"flag,omitempty"`
+	Env    string `json:"env,omitempty"`
+}
+
+// File returns the config file the configuration was loaded from, "" if
+// none
+func (c *Config) File() string {
+	return c.file
+}
+
+// Settings lists every setting by config file key, in the order they are
+// declared, with where its value came from. Configurations not loaded by
+// LoadConfig or Reload report every value as a default.
+func (c *Config) Settings() []Setting {
+	flags := make(map[string]string)
+	for name, key := range flagKeys() {
+		flags[key] = name
+	}
+
+	var settings []Setting
+	walkSettings("", reflect.ValueOf(*c), func(key string, value reflect.Value) {
+		setting := Setting{Key: key, Value: value.Interface(), Source: SourceDefault}
+		if duration, ok := setting.Value.(time.Duration); ok {
+			setting.Value = duration.String()
+		}
+		if source, ok := c.sources[key]; ok {
+			setting.Source = source
+		}
+		if name, ok := flags[key]; ok {
+			setting.Flag = "-" + name
+			setting.Env = envName(name)
+		}
+		settings = append(settings, setting)
+	})
+	return settings
+}
+
+// CopySources takes where the settings named by keys came from in another
+// configuration, for a running configuration that adopts its values
+func (c *Config) CopySources(from *Config, keys []string) {
+	sources := make(map[string]string, len(c.sources))
+	for key, source := range c.sources {
+		sources[key] = source
+	}
+	for _, key := range keys {
+		delete(sources, key)
+		if source, ok := from.sources[key]; ok {
+			sources[key] = source
+		}
+	}
+	c.sources = sources
+}
+
+// walkSettings calls visit with every setting of a configuration section
+// by its config file key
+func walkSettings(prefix string, section reflect.Value, visit func(key string, value reflect.Value)) {
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if section.Field(i).Kind() == reflect.Struct {
+			walkSettings(key+".", section.Field(i), visit)
+			continue
+		}
+		visit(key, section.Field(i))
+	}
+}
+
+// flagKeys maps each flag setting a configuration value to its config file
+// key. Flags are named after their keys without the section, with dashes
+// for underscores, unless flagKeyAliases says otherwise.
+func flagKeys() map[string]string {
+	aliased := make(map[string]bool)
+	for _, key := range flagKeyAliases {
+		aliased[key] = true
+	}
+
+	keys := make(map[string]string)
+	walkSettings("", reflect.ValueOf(Config{}), func(key string, _ reflect.Value) {
+		if !aliased[key] {
+			_, name, _ := strings.Cut(key, ".")
+			keys[strings.ReplaceAll(name, "_", "-")] = key
+		}
+	})
+	for name, key := range flagKeyAliases {
+		keys[name] = key
+	}
+	return keys
+}
+
+// envName is the environment variable that sets a flag's default, such as
+// READ_TIMEOUT for -read-timeout
+func envName(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// fileKeys lists the config file keys a config file sets. It is read after
+// LoadFile accepted the file, so errors only leave keys out.
+func fileKeys(path string) map[string]bool {
+	keys := make(map[string]bool)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return keys
+	}
+	var sections map[string]map[string]interface{}
+	if yaml.Unmarshal(data, &sections) != nil {
+		return keys
+	}
+	for section, settings := range sections {
+		for key := range settings {
+			keys[section+"."+key] = true
+		}
+	}
+	return keys
+}
+
+// settingSources works out where each setting came from: a flag given on
+// the command line, else its environment variable, else the config file,
+// else its default
+func settingSources(flags *flag.FlagSet, configFile string) map[string]string {
+	sources := make(map[string]string)
+	if configFile != "" {
+		for key := range fileKeys(configFile) {
+			sources[key] = SourceFile
+		}
+	}
+
+	keys := flagKeys()
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	flags.VisitAll(func(f *flag.Flag) {
+		key, ok := keys[f.Name]
+		if !ok {
+			return
+		}
+		switch {
+		case given[f.Name]:
+			sources[key] = SourceFlag
+		case os.Getenv(envName(f.Name)) != "":
+			sources[key] = SourceEnv
+		}
+	})
+	return sources
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestSettingSources(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "server:\n  port: \"9000\"\n  read_timeout: 3s\nlogging:\n  level: warn\n")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("READ_TIMEOUT", "4s")
+	t.Setenv("SINK_FSYNC", "always")
+
+	flags := flag.NewFlagSet("plivo", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	cfg, _, err := parse(flags, []string{"-config", path, "-log-level", "debug", "-api-key", "secret"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if cfg.File() != path {
+		t.Errorf("Expected the config file %s, got %q", path, cfg.File())
+	}
+
+	settings := make(map[string]Setting)
+	for _, setting := range cfg.Redacted().Settings() {
+		settings[setting.Key] = setting
+	}
+	tests := []struct {
+		key    string
+		value  interface{}
+		source string
+	}{
+		{"server.port", "9000", SourceFile},
+		{"server.read_timeout", "4s", SourceEnv},
+		{"logging.level", "debug", SourceFlag},
+		{"sinks.fsync", "always", SourceEnv},
+		{"security.api_key", redacted, SourceFlag},
+		{"pubsub.max_queue_size", 100, SourceDefault},
+	}
+	for _, tt := range tests {
+		if got := settings[tt.key]; got.Value != tt.value || got.Source != tt.source {
+			t.Errorf("%s: expected %v from %s, got %v from %s", tt.key, tt.value, tt.source, got.Value, got.Source)
+		}
+	}
+	if got := settings["sinks.fsync"]; got.Flag != "-sink-fsync" || got.Env != "SINK_FSYNC" {
+		t.Errorf("Expected sinks.fsync set by -sink-fsync and SINK_FSYNC, got %s and %s", got.Flag, got.Env)
+	}
+}
+
+func TestEverySettingHasAFlag(t *testing.T) {
+	flags := flag.NewFlagSet("plivo", flag.ContinueOnError)
+	if _, _, err := parse(flags, nil); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	for _, setting := range DefaultConfig().Settings() {
+		name := setting.Flag
+		if name == "" {
+			t.Errorf("%s has no flag", setting.Key)
+			continue
+		}
+		if flags.Lookup(name[1:]) == nil {
+			t.Errorf("%s maps to %s, which isn't a flag", setting.Key, name)
+		}
+	}
+}
+
+func TestCopySources(t *testing.T) {
+	running := &Config{sources: map[string]string{"logging.level": SourceFlag, "server.port": SourceEnv}}
+	reloaded := &Config{sources: map[string]string{"security.api_key": SourceFile}}
+	previous := running.sources
+
+	running.CopySources(reloaded, []string{"logging.level", "security.api_key"})
+	want := map[string]string{"server.port": SourceEnv, "security.api_key": SourceFile}
+	if len(running.sources) != len(want) || running.sources["server.port"] != SourceEnv || running.sources["security.api_key"] != SourceFile {
+		t.Errorf("Expected %v, got %v", want, running.sources)
+	}
+	if previous["logging.level"] != SourceFlag {
+		t.Error("Expected the previous sources left alone")
+	}
+}
@@ -17,7 +17,13 @@ func NewTestConfig() *Config {
 			PongWait:         60 * 1000000000, // 60 seconds in nanoseconds
 			WriteWait:        10 * 1000000000, // 10 seconds in nanoseconds
 			MaxMessageSize:   1024 * 1024,     // 1MB
+			ReplayRate:       1000,            // backlog messages per second
 			EnableCompression: false,
+			HubPublishBuffer: 1024,
+			PublishQueuedDepth: 256,
+			PublishRejectDepth: 1024,
+			PublishRetryAfter: 1 * 1000000000, // 1 second in nanoseconds
+			MaxLastN: 100,
 		},
 		Security: SecurityConfig{
 			APIKey:          "",
@@ -0,0 +1,170 @@
+package grpc
+
+import (
+	"time"
+
+	"plivo/internal/grpc/pubsubpb"
+	"plivo/internal/pubsub"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain is the ErrorInfo domain of errors carrying a pub/sub error code
+const errorDomain = "plivo.pubsub"
+
+// statusCodes maps pub/sub error codes to gRPC status codes
+var statusCodes = map[pubsub.ErrorCode]codes.Code{
+	pubsub.CodeBadRequest:         codes.InvalidArgument,
+	pubsub.CodeUnauthorized:       codes.Unauthenticated,
+	pubsub.CodeForbidden:          codes.PermissionDenied,
+	pubsub.CodeTopicNotFound:      codes.NotFound,
+	pubsub.CodeClientNotFound:     codes.NotFound,
+	pubsub.CodeSchemaNotFound:     codes.NotFound,
+	pubsub.CodeSchemaViolation:    codes.InvalidArgument,
+	pubsub.CodeGroupNotFound:      codes.NotFound,
+	pubsub.CodeStateNotFound:      codes.NotFound,
+	pubsub.CodeTopicExists:        codes.AlreadyExists,
+	pubsub.CodeGroupActive:        codes.FailedPrecondition,
+	pubsub.CodeTopicDraining:      codes.FailedPrecondition,
+	pubsub.CodeTopicDeleted:       codes.FailedPrecondition,
+	pubsub.CodeRevisionMismatch:   codes.FailedPrecondition,
+	pubsub.CodeMessageTooLarge:    codes.ResourceExhausted,
+	pubsub.CodeSlowConsumer:       codes.ResourceExhausted,
+	pubsub.CodeRateLimited:        codes.ResourceExhausted,
+	pubsub.CodeQuotaExceeded:      codes.ResourceExhausted,
+	pubsub.CodeHubSaturated:       codes.Unavailable,
+	pubsub.CodeHubPaused:          codes.Unavailable,
+	pubsub.CodeServerShuttingDown: codes.Unavailable,
+	pubsub.CodeRequestTimeout:     codes.DeadlineExceeded,
+}
+
+// statusFrom converts an error from the hub or message validation to a gRPC
+// status error
+func statusFrom(err error) error {
+	return statusFor(pubsub.ErrorFrom(err))
+}
+
+// statusFor converts an error body to a gRPC status error. The pub/sub code
+// travels as the reason of an ErrorInfo detail, so clients can tell apart
+// errors that share a status code.
+func statusFor(data *pubsub.ErrorData) error {
+	code, exists := statusCodes[data.Code]
+	if !exists {
+		code = codes.Internal
+	}
+	st := status.New(code, data.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(data.Code), Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// messageFromProto converts a published message to the hub's form
+func messageFromProto(m *pubsubpb.Message) *pubsub.MessageData {
+	if m == nil {
+		return nil
+	}
+	data := &pubsub.MessageData{
+		ID:          m.GetId(),
+		Headers:     m.GetHeaders(),
+		TTLMs:       m.GetTtlMs(),
+		ContentType: m.GetContentType(),
+	}
+	if m.GetPayload() != nil {
+		data.Payload = m.GetPayload().AsInterface()
+	}
+	return data
+}
+
+// messageToProto converts a delivered message to protobuf. Payloads are
+// decoded JSON, which protobuf values represent exactly.
+func messageToProto(data *pubsub.MessageData) (*pubsubpb.Message, error) {
+	payload, err := structpb.NewValue(data.Payload)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "encode payload: %v", err)
+	}
+	return &pubsubpb.Message{
+		Id:          data.ID,
+		Payload:     payload,
+		Headers:     data.Headers,
+		TtlMs:       data.TTLMs,
+		ContentType: data.ContentType,
+	}, nil
+}
+
+// eventFromFrame converts a frame queued for a subscriber to an Event. It
+// returns nil for frames gRPC subscribers have no use for, such as the
+// connection welcome.
+func eventFromFrame(msg *pubsub.ServerMessage) (*pubsubpb.Event, error) {
+	switch {
+	case msg.Type == pubsub.EventMessage && msg.Message != nil:
+		message, err := messageToProto(msg.Message)
+		if err != nil {
+			return nil, err
+		}
+		event := &pubsubpb.Event{
+			Type:          string(msg.Type),
+			Topic:         msg.Topic,
+			Message:       message,
+			Sequence:      msg.Sequence,
+			SchemaVersion: int32(msg.SchemaVersion),
+		}
+		if receivedAt, err := time.Parse(time.RFC3339Nano, msg.ReceivedAt); err == nil {
+			event.ReceivedAt = timestamppb.New(receivedAt)
+		}
+		return event, nil
+
+	case msg.Type == pubsub.InfoMessage && msg.Topic != "":
+		return &pubsubpb.Event{
+			Type:        string(msg.Type),
+			Topic:       msg.Topic,
+			Info:        msg.Msg,
+			Replacement: msg.Replacement,
+		}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// statsToProto converts hub statistics to protobuf
+func statsToProto(stats pubsub.Stats) *pubsubpb.StatsResponse {
+	resp := &pubsubpb.StatsResponse{
+		TotalClients:  int32(stats.TotalClients),
+		TotalTopics:   int32(stats.TotalTopics),
+		TotalMessages: stats.TotalMessages,
+		Panics:        stats.Panics,
+		Topics:        make(map[string]*pubsubpb.TopicStats, len(stats.Topics)),
+		Errors:        make(map[string]int64, len(stats.Errors)),
+	}
+	for name, topic := range stats.Topics {
+		topicStats := &pubsubpb.TopicStats{
+			Messages:        topic.MessageCount,
+			Subscribers:     int32(topic.SubscriberCount),
+			Sequence:        topic.Sequence,
+			Dropped:         topic.DroppedCount,
+			BufferOccupancy: int32(topic.BufferOccupancy),
+			BufferCapacity:  int32(topic.BufferCapacity),
+			RetainedBytes:   topic.RetainedBytes,
+		}
+		if topic.LastPublishAt != nil {
+			topicStats.LastPublishAt = timestamppb.New(*topic.LastPublishAt)
+		}
+		resp.Topics[name] = topicStats
+	}
+	for code, count := range stats.Errors {
+		resp.Errors[string(code)] = count
+	}
+	if stats.Retention != nil {
+		resp.Retention = &pubsubpb.RetentionUsage{
+			Bytes:     stats.Retention.Bytes,
+			Budget:    stats.Retention.Budget,
+			Evictions: stats.Retention.Evictions,
+		}
+	}
+	return resp
+}
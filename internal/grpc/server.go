@@ -0,0 +1,272 @@
+// Package grpc serves the hub over gRPC, alongside the REST and WebSocket
+// APIs, for backend services that prefer protobuf to JSON
+package grpc
+
+//go:generate protoc -I pubsubpb --go_out=pubsubpb --go_opt=paths=source_relative --go-grpc_out=pubsubpb --go-grpc_opt=paths=source_relative pubsub.proto
+
+import (
+	"context"
+	"encoding/json"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/grpc/pubsubpb"
+	"plivo/internal/pubsub"
+
+	"github.com/google/uuid"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements the PubSub gRPC service over a hub
+type Server struct {
+	pubsubpb.UnimplementedPubSubServer
+
+	hub  *pubsub.Hub
+	cfg  *config.Config
+	auth *auth.Service
+}
+
+// NewServer returns a gRPC server exposing hub. Calls authenticate with the
+// configured API and tenant keys, sent as x-api-key metadata, and admins
+// may send x-admin-key. It panics if any dependency is missing.
+func NewServer(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) *gogrpc.Server {
+	if hub == nil || cfg == nil || authService == nil {
+		panic("grpc: NewServer requires a hub, config and auth service")
+	}
+	s := &Server{hub: hub, cfg: cfg, auth: authService}
+
+	server := gogrpc.NewServer(
+		gogrpc.UnaryInterceptor(s.recoverUnary),
+		gogrpc.StreamInterceptor(s.recoverStream),
+	)
+	pubsubpb.RegisterPubSubServer(server, s)
+	return server
+}
+
+// Publish publishes a message to an existing topic
+func (s *Server) Publish(ctx context.Context, req *pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error) {
+	tenant, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, tenant, auth.PermPublish, req.GetTopic()); err != nil {
+		return nil, err
+	}
+
+	opts := []pubsub.MessageOption{
+		pubsub.WithMaxSize(s.cfg.PubSub.MaxMessageSize),
+		pubsub.WithPublisher(pubsub.GRPCIdentity(peerAddr(ctx))),
+	}
+	if s.cfg.PubSub.GenerateMessageIDs {
+		opts = append(opts, pubsub.WithGeneratedID())
+	}
+	receipt, err := s.hub.PublishDirect(ctx, req.GetTopic(), messageFromProto(req.GetMessage()), opts...)
+	if err != nil {
+		return nil, statusFrom(err)
+	}
+
+	resp := &pubsubpb.PublishResponse{
+		Status:    "published",
+		Topic:     receipt.Topic,
+		Id:        receipt.ID,
+		Timestamp: timestamppb.New(receipt.Timestamp),
+	}
+	if receipt.Duplicate {
+		resp.Status = pubsub.PublishDuplicate
+	} else if receipt.Ahead >= s.cfg.PubSub.PublishQueuedDepth {
+		resp.Status = "queued"
+		resp.QueuePosition = int32(receipt.Ahead)
+	}
+	return resp, nil
+}
+
+// Subscribe streams a topic's events until the caller cancels, the
+// subscriber falls a full queue behind or the server shuts down
+func (s *Server) Subscribe(req *pubsubpb.SubscribeRequest, stream gogrpc.ServerStreamingServer[pubsubpb.Event]) error {
+	ctx := stream.Context()
+	tenant, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, tenant, auth.PermSubscribe, req.GetTopic()); err != nil {
+		return err
+	}
+
+	opts := pubsub.StreamOptions{
+		LastN:         int(req.GetLastN()),
+		AfterSequence: req.GetAfterSequence(),
+		KeyID:         req.GetKeyId(),
+		Fields:        req.GetFields(),
+		Where:         req.GetWhere(),
+	}
+	sub, err := s.hub.OpenStream(uuid.New().String(), req.GetTopic(), opts, pubsub.NewClientOptions(s.cfg.PubSub))
+	if err != nil {
+		return statusFrom(err)
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-sub.Ready():
+			frames, closed := sub.Next()
+			for _, frame := range frames {
+				var msg pubsub.ServerMessage
+				if err := json.Unmarshal(frame.Data, &msg); err != nil {
+					return status.Errorf(codes.Internal, "decode frame: %v", err)
+				}
+				if msg.Type == pubsub.ErrorMessage && msg.Error != nil {
+					return statusFor(msg.Error)
+				}
+				event, err := eventFromFrame(&msg)
+				if err != nil {
+					return err
+				}
+				if event == nil {
+					continue
+				}
+				if err := stream.Send(event); err != nil {
+					return err
+				}
+			}
+			if closed {
+				return statusFrom(pubsub.ErrShuttingDown)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// CreateTopic creates a topic owned by the caller's tenant
+func (s *Server) CreateTopic(ctx context.Context, req *pubsubpb.CreateTopicRequest) (*pubsubpb.CreateTopicResponse, error) {
+	tenant, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Topic name is required")
+	}
+	if err := s.authorize(ctx, tenant, auth.PermAdmin, req.GetName()); err != nil {
+		return nil, err
+	}
+
+	if err := s.hub.CreateTopicWithOptions(req.GetName(), pubsub.TopicOptions{
+		Weight: int(req.GetWeight()),
+		KeyID:  req.GetKeyId(),
+		Enrich: req.GetEnrich(),
+		Owner:  tenant,
+	}); err != nil {
+		return nil, statusFrom(err)
+	}
+	return &pubsubpb.CreateTopicResponse{Status: "created", Topic: req.GetName()}, nil
+}
+
+// DeleteTopic deletes a topic, keeping it restorable for the trash window
+// unless purge is set. Owned topics may only be deleted by their owner or
+// an admin.
+func (s *Server) DeleteTopic(ctx context.Context, req *pubsubpb.DeleteTopicRequest) (*pubsubpb.DeleteTopicResponse, error) {
+	tenant, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, tenant, auth.PermAdmin, req.GetName()); err != nil {
+		return nil, err
+	}
+	if owner, err := s.hub.TopicOwner(req.GetName()); err == nil && owner != "" && owner != tenant && !s.isAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "Topic is owned by "+owner)
+	}
+
+	if req.GetPurge() {
+		if err := s.hub.PurgeTopic(req.GetName()); err != nil {
+			return nil, statusFrom(err)
+		}
+		return &pubsubpb.DeleteTopicResponse{Status: "purged", Topic: req.GetName()}, nil
+	}
+
+	if err := s.hub.DeleteTopic(req.GetName()); err != nil {
+		return nil, statusFrom(err)
+	}
+	resp := &pubsubpb.DeleteTopicResponse{Status: "deleted", Topic: req.GetName()}
+	if deleted, restorable := s.hub.DeletedTopicInfo(req.GetName()); restorable {
+		resp.PurgeAt = timestamppb.New(deleted.PurgeAt)
+	}
+	return resp, nil
+}
+
+// Stats returns hub and per-topic statistics
+func (s *Server) Stats(ctx context.Context, _ *pubsubpb.StatsRequest) (*pubsubpb.StatsResponse, error) {
+	if _, err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return statsToProto(s.hub.GetStats()), nil
+}
+
+// authenticate checks the x-api-key metadata against the API key and the
+// tenant keys, and returns the caller's tenant ("" for the shared key)
+func (s *Server) authenticate(ctx context.Context) (string, error) {
+	tenant, ok := s.auth.Authenticate(metadataValue(ctx, "x-api-key"))
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return tenant, nil
+}
+
+// authorize checks that the ACL lets the caller take an action on a topic;
+// admins may take any
+func (s *Server) authorize(ctx context.Context, tenant string, permission auth.Permission, topic string) error {
+	if s.auth.Authorize(tenant, permission, topic) || s.isAdmin(ctx) {
+		return nil
+	}
+	return statusFrom(pubsub.NotPermitted(permission, topic))
+}
+
+// isAdmin checks the x-admin-key metadata against the admin credential
+func (s *Server) isAdmin(ctx context.Context) bool {
+	return s.auth.IsAdmin(metadataValue(ctx, "x-admin-key"))
+}
+
+// recoverUnary turns a panicking call into an INTERNAL error, recording the
+// panic in the hub's statistics like the REST recovery middleware
+func (s *Server) recoverUnary(ctx context.Context, req any, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.hub.RecordPanic("grpc "+info.FullMethod, r)
+			err = status.Error(codes.Internal, "Internal Server Error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// recoverStream is recoverUnary for streaming calls
+func (s *Server) recoverStream(srv any, ss gogrpc.ServerStream, info *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.hub.RecordPanic("grpc "+info.FullMethod, r)
+			err = status.Error(codes.Internal, "Internal Server Error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// metadataValue returns the first value of an incoming metadata key
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerAddr returns the caller's address, or "" if it is unknown
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
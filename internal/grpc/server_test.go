@@ -0,0 +1,271 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/grpc/pubsubpb"
+	"plivo/internal/pubsub"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newTestServer serves hub over an in-memory listener and returns a client
+// for it
+func newTestServer(t *testing.T, hub *pubsub.Hub, cfg *config.Config) pubsubpb.PubSubClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(hub, cfg, auth.MustNewService(cfg.Security))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := gogrpc.NewClient("passthrough:///bufconn",
+		gogrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pubsubpb.NewPubSubClient(conn)
+}
+
+// withKey attaches an API key to outgoing calls
+func withKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", key)
+}
+
+// errorReason returns the pub/sub error code carried by a status error
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	client := newTestServer(t, hub, config.NewTestConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.CreateTopic(ctx, &pubsubpb.CreateTopicRequest{Name: "orders"}); err != nil {
+		t.Fatalf("CreateTopic failed: %v", err)
+	}
+
+	stream, err := client.Subscribe(ctx, &pubsubpb.SubscribeRequest{Topic: "orders"})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	// The subscription is registered asynchronously
+	deadline := time.Now().Add(time.Second)
+	for hub.GetStats().Topics["orders"].SubscriberCount == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the subscription")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	payload, _ := structpb.NewValue(map[string]interface{}{"amount": 42.0})
+	resp, err := client.Publish(ctx, &pubsubpb.PublishRequest{
+		Topic:   "orders",
+		Message: &pubsubpb.Message{Id: "msg-1", Payload: payload, Headers: map[string]string{"region": "eu"}},
+	})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if resp.Status != "published" || resp.Id != "msg-1" {
+		t.Errorf("Unexpected publish response: %v", resp)
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Type != "event" || event.Topic != "orders" || event.Sequence != 1 {
+		t.Errorf("Unexpected event: %v", event)
+	}
+	if event.Message.GetId() != "msg-1" || event.Message.GetHeaders()["region"] != "eu" {
+		t.Errorf("Unexpected message: %v", event.Message)
+	}
+	if amount := event.Message.GetPayload().GetStructValue().GetFields()["amount"].GetNumberValue(); amount != 42 {
+		t.Errorf("Expected amount 42, got %v", amount)
+	}
+	if event.ReceivedAt == nil {
+		t.Error("Expected received_at to be set")
+	}
+
+	stats, err := client.Stats(ctx, &pubsubpb.StatsRequest{})
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if topic := stats.Topics["orders"]; topic.GetMessages() != 1 || topic.GetSubscribers() != 1 {
+		t.Errorf("Unexpected topic stats: %v", topic)
+	}
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	client := newTestServer(t, hub, config.NewTestConfig())
+
+	hub.Restore(&pubsub.Snapshot{Topics: []pubsub.TopicSnapshot{{
+		Name:     "orders",
+		Sequence: 2,
+		Messages: []*pubsub.PubSubMessage{
+			{Topic: "orders", Message: &pubsub.MessageData{ID: "msg-1", Payload: 1.0}, Sequence: 1},
+			{Topic: "orders", Message: &pubsub.MessageData{ID: "msg-2", Payload: 2.0}, Sequence: 2},
+		},
+	}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Subscribe(ctx, &pubsubpb.SubscribeRequest{Topic: "orders", AfterSequence: 1})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if event.Sequence != 2 || event.Message.GetId() != "msg-2" {
+		t.Errorf("Expected msg-2 replayed after sequence 1, got %v", event)
+	}
+
+	stream, err = client.Subscribe(ctx, &pubsubpb.SubscribeRequest{Topic: "orders", LastN: 2, Where: "payload > 1"})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if event, err = stream.Recv(); err != nil || event.Message.GetId() != "msg-2" {
+		t.Errorf("Expected only msg-2 replayed where payload > 1, got %v, %v", event, err)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	client := newTestServer(t, hub, config.NewTestConfigWithAPIKey("test-key"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.Stats(ctx, &pubsubpb.StatsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a key, got %v", err)
+	}
+	if _, err := client.Stats(withKey(ctx, "wrong-key"), &pubsubpb.StatsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated with a wrong key, got %v", err)
+	}
+
+	ctx = withKey(ctx, "test-key")
+	if _, err := client.CreateTopic(ctx, &pubsubpb.CreateTopicRequest{Name: "orders"}); err != nil {
+		t.Fatalf("CreateTopic failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		call   func() error
+		code   codes.Code
+		reason pubsub.ErrorCode
+	}{
+		{
+			name: "duplicate topic",
+			call: func() error {
+				_, err := client.CreateTopic(ctx, &pubsubpb.CreateTopicRequest{Name: "orders"})
+				return err
+			},
+			code:   codes.AlreadyExists,
+			reason: pubsub.CodeTopicExists,
+		},
+		{
+			name: "publish to missing topic",
+			call: func() error {
+				_, err := client.Publish(ctx, &pubsubpb.PublishRequest{Topic: "missing", Message: &pubsubpb.Message{Id: "msg-1"}})
+				return err
+			},
+			code:   codes.NotFound,
+			reason: pubsub.CodeTopicNotFound,
+		},
+		{
+			name: "subscribe with invalid fields",
+			call: func() error {
+				stream, err := client.Subscribe(ctx, &pubsubpb.SubscribeRequest{Topic: "orders", Fields: []string{""}})
+				if err != nil {
+					return err
+				}
+				_, err = stream.Recv()
+				return err
+			},
+			code:   codes.InvalidArgument,
+			reason: pubsub.CodeBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if status.Code(err) != tt.code {
+				t.Errorf("Expected %v, got %v", tt.code, err)
+			}
+			if reason := errorReason(err); reason != string(tt.reason) {
+				t.Errorf("Expected reason %s, got %q", tt.reason, reason)
+			}
+		})
+	}
+
+	resp, err := client.DeleteTopic(ctx, &pubsubpb.DeleteTopicRequest{Name: "orders"})
+	if err != nil {
+		t.Fatalf("DeleteTopic failed: %v", err)
+	}
+	if resp.Status != "deleted" || resp.Topic != "orders" {
+		t.Errorf("Unexpected delete response: %v", resp)
+	}
+	if _, err := client.DeleteTopic(ctx, &pubsubpb.DeleteTopicRequest{Name: "orders", Purge: true}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound purging a deleted topic, got %v", err)
+	}
+}
+
+func TestDeleteTopicOwnership(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	cfg := config.NewTestConfigWithAPIKey("shared-key")
+	cfg.Security.AdminKey = "admin-key"
+	cfg.Security.TenantKeys = "acme=acme-key,globex=globex-key"
+	client := newTestServer(t, hub, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.CreateTopic(withKey(ctx, "acme-key"), &pubsubpb.CreateTopicRequest{Name: "orders"}); err != nil {
+		t.Fatalf("CreateTopic failed: %v", err)
+	}
+	if _, err := client.DeleteTopic(withKey(ctx, "globex-key"), &pubsubpb.DeleteTopicRequest{Name: "orders"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for another tenant, got %v", err)
+	}
+
+	adminCtx := metadata.AppendToOutgoingContext(withKey(ctx, "globex-key"), "x-admin-key", "admin-key")
+	if _, err := client.DeleteTopic(adminCtx, &pubsubpb.DeleteTopicRequest{Name: "orders"}); err != nil {
+		t.Errorf("Expected an admin to delete the topic, got %v", err)
+	}
+}
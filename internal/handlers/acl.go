@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+)
+
+// maxACLSize limits the size of ACL documents accepted by PutACL
+const maxACLSize = 1024 * 1024
+
+// GetACL returns the enforced ACL
+// @Summary Get the ACL
+// @Description Get the access control list in force: the roles, each granting subscribe, publish or admin permissions on topic patterns, and the tenants bound to them. Without an ACL every authenticated caller may do anything.
+// @Tags acl
+// @Produce json
+// @Success 200 {object} ACLResponse "The ACL, if one is enforced"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /acl [get]
+func (h *RESTHandler) GetACL(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	writeACL(w, h.auth.ACL())
+}
+
+// PutACL replaces the ACL
+// @Summary Set the ACL
+// @Description Replace the access control list and start enforcing it. Roles grant permissions on topic patterns: an exact name, a prefix ending in * or * for every topic. subscribe covers subscribing and reading a topic, publish covers publishing, and admin covers creating, reconfiguring, draining and deleting it and implies the others. Bindings map tenant names, or * for every caller, to roles; callers with the shared API key hold only the roles bound to *. Callers with the admin credential are never restricted.
+// @Tags acl
+// @Accept json
+// @Produce json
+// @Param request body auth.ACL true "Roles and bindings"
+// @Success 200 {object} ACLResponse "The ACL now enforced"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, unknown permission, undefined role or unknown tenant"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /acl [put]
+func (h *RESTHandler) PutACL(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	var acl auth.ACL
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxACLSize)).Decode(&acl); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+	if err := h.auth.SetACL(&acl); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, err.Error()))
+		return
+	}
+
+	writeACL(w, &acl)
+}
+
+// DeleteACL stops enforcing the ACL
+// @Summary Remove the ACL
+// @Description Stop enforcing the access control list, so every authenticated caller may do anything again.
+// @Tags acl
+// @Produce json
+// @Success 200 {object} ACLResponse "No ACL is enforced"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /acl [delete]
+func (h *RESTHandler) DeleteACL(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	h.auth.ClearACL()
+	writeACL(w, nil)
+}
+
+// writeACL responds with the ACL, nil if none is enforced
+func writeACL(w http.ResponseWriter, acl *auth.ACL) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ACLResponse{Enabled: acl != nil, ACL: acl})
+}
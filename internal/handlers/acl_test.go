@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testACLBody = `{
+  "roles": {
+    "reader": [{"topics": "*", "permissions": ["subscribe"]}],
+    "payments-owner": [{"topics": "payments.*", "permissions": ["admin"]}]
+  },
+  "bindings": {"*": ["reader"], "payments": ["payments-owner"]}
+}`
+
+func newACLTestHandler() *RESTHandler {
+	cfg := config.NewTestConfig()
+	cfg.Security.APIKey = "shared"
+	cfg.Security.AdminKey = "admin"
+	cfg.Security.TenantKeys = "payments=pay-key,search=search-key"
+	return NewRESTHandler(pubsub.NewHub(), cfg, auth.MustNewService(cfg.Security))
+}
+
+func TestPutACL(t *testing.T) {
+	handler := newACLTestHandler()
+
+	req := httptest.NewRequest("PUT", "/acl", strings.NewReader(testACLBody))
+	req.Header.Set("X-API-Key", "search-key")
+	w := httptest.NewRecorder()
+	handler.PutACL(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without the admin key, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/acl", strings.NewReader(`{"bindings": {"billing": []}}`))
+	req.Header.Set("X-Admin-Key", "admin")
+	w = httptest.NewRecorder()
+	handler.PutACL(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an unknown tenant, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/acl", strings.NewReader(testACLBody))
+	req.Header.Set("X-Admin-Key", "admin")
+	w = httptest.NewRecorder()
+	handler.PutACL(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/acl", nil)
+	req.Header.Set("X-Admin-Key", "admin")
+	w = httptest.NewRecorder()
+	handler.GetACL(w, req)
+	var resp ACLResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.Enabled || len(resp.ACL.Roles) != 2 || resp.ACL.Bindings["payments"][0] != "payments-owner" {
+		t.Errorf("Expected the ACL that was set, got %+v", resp)
+	}
+}
+
+func TestACLEnforcedOnTopics(t *testing.T) {
+	handler := newACLTestHandler()
+	handler.hub.CreateTopic("orders")
+	handler.hub.CreateTopic("payments.refunds")
+	if err := handler.auth.SetACL(mustDecodeACL(t, testACLBody)); err != nil {
+		t.Fatalf("Failed to set ACL: %v", err)
+	}
+
+	deleteTopic := func(key, topic string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/topics/"+topic, nil)
+		req.Header.Set("X-API-Key", key)
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.DeleteTopic(w, req)
+		return w
+	}
+
+	w := deleteTopic("search-key", "orders")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "FORBIDDEN") {
+		t.Errorf("Expected 403 FORBIDDEN for a reader deleting a topic, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := deleteTopic("pay-key", "orders"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 outside the payments patterns, got %d", w.Code)
+	}
+	if w := deleteTopic("pay-key", "payments.refunds"); w.Code != http.StatusOK {
+		t.Errorf("Expected the payments tenant to delete its topic, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Readers may still read, and the admin credential bypasses the ACL
+	req := httptest.NewRequest("GET", "/topics/orders", nil)
+	req.Header.Set("X-API-Key", "search-key")
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w = httptest.NewRecorder()
+	handler.GetTopic(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a reader to get the topic, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "m1", "payload": 1}`))
+	req.Header.Set("X-API-Key", "shared")
+	req.Header.Set("X-Admin-Key", "admin")
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w = httptest.NewRecorder()
+	handler.Publish(w, req)
+	if w.Code == http.StatusForbidden {
+		t.Errorf("Expected the admin credential to bypass the ACL, got %d", w.Code)
+	}
+
+	// Removing the ACL lifts the restrictions
+	req = httptest.NewRequest("DELETE", "/acl", nil)
+	req.Header.Set("X-Admin-Key", "admin")
+	handler.DeleteACL(httptest.NewRecorder(), req)
+	if w := deleteTopic("search-key", "orders"); w.Code != http.StatusOK {
+		t.Errorf("Expected delete to succeed without an ACL, got %d", w.Code)
+	}
+}
+
+func mustDecodeACL(t *testing.T, body string) *auth.ACL {
+	t.Helper()
+	var acl auth.ACL
+	if err := json.Unmarshal([]byte(body), &acl); err != nil {
+		t.Fatalf("Failed to decode ACL: %v", err)
+	}
+	return &acl
+}
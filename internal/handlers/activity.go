@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Admin operations counted by the activity log
+const (
+	ActivityTopicCreate   = "topic.create"
+	ActivityTopicUpdate   = "topic.update"
+	ActivityTopicDelete   = "topic.delete"
+	ActivityTopicRestore  = "topic.restore"
+	ActivityTopicDrain    = "topic.drain"
+	ActivityTopicTransfer = "topic.transfer"
+	ActivityTopicReplay   = "topic.replay"
+	ActivitySchemaPut     = "topic.schema"
+	ActivityGroupOffset   = "group.offset"
+	ActivityPause         = "hub.pause"
+	ActivityResume        = "hub.resume"
+	ActivityACLPut        = "acl.update"
+	ActivityACLDelete     = "acl.delete"
+	ActivityConfigReload  = "config.reload"
+)
+
+const (
+	// activityRetention is how long the activity log keeps operations, and
+	// so the longest window it summarizes
+	activityRetention = 24 * time.Hour
+	// maxActivityEntries bounds the operations the log keeps, oldest
+	// dropped first
+	maxActivityEntries = 100000
+	// activityBuckets is how many intervals a summary's window is split
+	// into for its time series
+	activityBuckets = 60
+	// maxActivityTopics is how many of the busiest topics a summary lists
+	maxActivityTopics = 20
+)
+
+// ActivityLog keeps a rolling record of admin operations, the topic CRUD,
+// drains, transfers, pauses, ACL changes and config reloads made on the
+// broker, for the operations dashboard to summarize without parsing logs.
+// It is safe for concurrent use.
+type ActivityLog struct {
+	mu sync.Mutex
+	// entries in time order
+	entries []activityEntry
+}
+
+// activityEntry is one recorded operation
+type activityEntry struct {
+	at     time.Time
+	op     string
+	caller string
+	topic  string
+	failed bool
+}
+
+// ActivitySummary aggregates the admin operations within a window
+type ActivitySummary struct {
+	Window string    `json:"window"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	Total  int       `json:"total"`
+	Failed int       `json:"failed"`
+	// PerMinute is the mean rate of operations over the window
+	PerMinute float64 `json:"per_minute"`
+	// Operations counts each operation by name
+	Operations map[string]ActivityCount `json:"operations"`
+	// Callers counts operations by who made them: "admin", a tenant,
+	// "api_key" for the shared key or "unauthenticated"; config reloads are
+	// made by "sighup"
+	Callers map[string]int `json:"callers"`
+	// Topics lists the topics operated on most, busiest first
+	Topics []TopicActivity `json:"topics"`
+	// Buckets splits the window into equal intervals, oldest first
+	Buckets []ActivityBucket `json:"buckets"`
+}
+
+// ActivityCount counts an operation, and how many attempts at it failed
+type ActivityCount struct {
+	Count  int `json:"count"`
+	Failed int `json:"failed"`
+}
+
+// TopicActivity counts the operations on a topic
+type TopicActivity struct {
+	Topic string `json:"topic"`
+	Count int    `json:"count"`
+}
+
+// ActivityBucket counts the operations in one interval of a window
+type ActivityBucket struct {
+	Start  time.Time `json:"start"`
+	Count  int       `json:"count"`
+	Failed int       `json:"failed"`
+}
+
+// NewActivityLog creates an empty activity log
+func NewActivityLog() *ActivityLog {
+	return &ActivityLog{}
+}
+
+// Record notes an operation made now by caller, on topic if it names one
+func (l *ActivityLog) Record(op, caller, topic string, failed bool) {
+	l.record(activityEntry{at: time.Now(), op: op, caller: caller, topic: topic, failed: failed})
+}
+
+func (l *ActivityLog) record(entry activityEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop what has aged out or no longer fits
+	cutoff := entry.at.Add(-activityRetention)
+	drop := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].at.After(cutoff) })
+	drop = max(drop, len(l.entries)+1-maxActivityEntries)
+	if drop > 0 {
+		l.entries = append(l.entries[:0], l.entries[drop:]...)
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// Summary aggregates the operations in the window ending at until
+func (l *ActivityLog) Summary(window time.Duration, until time.Time) ActivitySummary {
+	since := until.Add(-window)
+	bucketSize := max(window/activityBuckets, time.Second)
+	buckets := int((window + bucketSize - 1) / bucketSize)
+
+	summary := ActivitySummary{
+		Window:     window.String(),
+		Since:      since,
+		Until:      until,
+		Operations: make(map[string]ActivityCount),
+		Callers:    make(map[string]int),
+		Topics:     []TopicActivity{},
+		Buckets:    make([]ActivityBucket, buckets),
+	}
+	for i := range summary.Buckets {
+		summary.Buckets[i].Start = since.Add(time.Duration(i) * bucketSize)
+	}
+
+	l.mu.Lock()
+	start := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].at.After(since) })
+	entries := l.entries[start:]
+	topics := make(map[string]int)
+	for _, entry := range entries {
+		if entry.at.After(until) {
+			break
+		}
+		count := summary.Operations[entry.op]
+		count.Count++
+		summary.Total++
+		bucket := &summary.Buckets[min(int(entry.at.Sub(since)/bucketSize), buckets-1)]
+		bucket.Count++
+		if entry.failed {
+			count.Failed++
+			summary.Failed++
+			bucket.Failed++
+		}
+		summary.Operations[entry.op] = count
+		summary.Callers[entry.caller]++
+		if entry.topic != "" {
+			topics[entry.topic]++
+		}
+	}
+	l.mu.Unlock()
+
+	for topic, count := range topics {
+		summary.Topics = append(summary.Topics, TopicActivity{Topic: topic, Count: count})
+	}
+	sort.Slice(summary.Topics, func(i, j int) bool {
+		a, b := summary.Topics[i], summary.Topics[j]
+		return a.Count > b.Count || (a.Count == b.Count && a.Topic < b.Topic)
+	})
+	if len(summary.Topics) > maxActivityTopics {
+		summary.Topics = summary.Topics[:maxActivityTopics]
+	}
+	summary.PerMinute = float64(summary.Total) / window.Minutes()
+	return summary
+}
+
+// ActivityMiddleware records the admin operations among the requests it
+// serves in log. Operations are named by method and route path template,
+// such as "DELETE /topics/{topic}"; other requests pass through unrecorded.
+// Requests answered with an error status are recorded as failed.
+func ActivityMiddleware(log *ActivityLog, authService *auth.Service, operations map[string]string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := activityOperation(r, operations)
+			if op == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			log.Record(op, activityCaller(authService, r), mux.Vars(r)["topic"], recorder.status >= http.StatusBadRequest)
+		})
+	}
+}
+
+// activityOperation names the request's operation, or "" if it isn't one
+func activityOperation(r *http.Request, operations map[string]string) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return operations[r.Method+" "+template]
+}
+
+// activityCaller names who made a request: "admin" for the admin
+// credential, the caller's tenant, "api_key" for the shared key, or
+// "unauthenticated"
+func activityCaller(authService *auth.Service, r *http.Request) string {
+	if authenticateAdmin(authService, r) {
+		return "admin"
+	}
+	tenant, ok := authenticateCaller(authService, r, false)
+	switch {
+	case !ok:
+		return "unauthenticated"
+	case tenant != "":
+		return tenant
+	}
+	return "api_key"
+}
+
+// statusRecorder remembers the status a handler responds with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Activity returns the log the handler summarizes at /admin/activity
+func (h *RESTHandler) Activity() *ActivityLog {
+	return h.activity
+}
+
+// GetActivity summarizes recent admin operations
+// @Summary Summarize admin activity
+// @Description Summarize the admin operations made on the broker within a window ending now, for operations dashboards: topic creates, updates, deletes, restores, drains, transfers and replays, schema and consumer group offset changes, pauses and resumes, ACL changes and config reloads. Counts are given per operation, caller and topic, with how many attempts failed, and as a time series of 60 equal intervals. Operations are kept for 24 hours.
+// @Tags system
+// @Produce json
+// @Param window query string false "How far back to summarize, as a Go duration up to 24h (default: 1h)"
+// @Success 200 {object} ActivitySummary "Activity summary"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid window"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /admin/activity [get]
+func (h *RESTHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	window := time.Hour
+	if param := r.URL.Query().Get("window"); param != "" {
+		parsed, err := time.ParseDuration(param)
+		if err != nil || parsed < time.Second || parsed > activityRetention {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "window must be a duration between 1s and 24h"))
+			return
+		}
+		window = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.activity.Summary(window, time.Now()))
+}
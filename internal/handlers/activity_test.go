@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestActivitySummary(t *testing.T) {
+	log := NewActivityLog()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	record := func(ago time.Duration, op, caller, topic string, failed bool) {
+		log.record(activityEntry{at: now.Add(-ago), op: op, caller: caller, topic: topic, failed: failed})
+	}
+
+	record(2*time.Hour, ActivityTopicCreate, "admin", "", false)
+	record(50*time.Minute, ActivityTopicDelete, "admin", "orders", false)
+	record(30*time.Minute, ActivityTopicDrain, "team-a", "orders", false)
+	record(30*time.Minute, ActivityTopicDrain, "team-a", "payments", true)
+	record(time.Second, ActivityConfigReload, "sighup", "", false)
+
+	summary := log.Summary(time.Hour, now)
+	if summary.Total != 4 || summary.Failed != 1 {
+		t.Fatalf("Expected 4 operations in the last hour, 1 failed, got %d and %d", summary.Total, summary.Failed)
+	}
+	if drains := summary.Operations[ActivityTopicDrain]; drains.Count != 2 || drains.Failed != 1 {
+		t.Errorf("Expected 2 drains, 1 failed, got %+v", drains)
+	}
+	if _, counted := summary.Operations[ActivityTopicCreate]; counted {
+		t.Error("Expected the create outside the window left out")
+	}
+	if summary.Callers["admin"] != 1 || summary.Callers["team-a"] != 2 || summary.Callers["sighup"] != 1 {
+		t.Errorf("Unexpected callers %v", summary.Callers)
+	}
+	if len(summary.Topics) != 2 || summary.Topics[0] != (TopicActivity{Topic: "orders", Count: 2}) {
+		t.Errorf("Expected orders busiest, got %+v", summary.Topics)
+	}
+	if summary.PerMinute != 4.0/60 {
+		t.Errorf("Expected %v operations a minute, got %v", 4.0/60, summary.PerMinute)
+	}
+
+	// Minute buckets: 50 minutes ago is the 10th, 30 minutes ago the 30th
+	// and the reload the last
+	if len(summary.Buckets) != 60 {
+		t.Fatalf("Expected 60 buckets, got %d", len(summary.Buckets))
+	}
+	if summary.Buckets[10].Count != 1 || summary.Buckets[30].Count != 2 || summary.Buckets[30].Failed != 1 || summary.Buckets[59].Count != 1 {
+		t.Errorf("Unexpected buckets %+v", summary.Buckets)
+	}
+	if !summary.Buckets[0].Start.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Expected the first bucket to start an hour ago, got %v", summary.Buckets[0].Start)
+	}
+}
+
+func TestActivityEndpoint(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	cfg.Security.AdminKey = "admin"
+	authService := auth.MustNewService(cfg.Security)
+	handler := NewRESTHandler(hub, cfg, authService)
+
+	router := mux.NewRouter()
+	router.Use(ActivityMiddleware(handler.Activity(), authService, map[string]string{
+		"POST /topics":           ActivityTopicCreate,
+		"DELETE /topics/{topic}": ActivityTopicDelete,
+	}))
+	router.HandleFunc("/topics", handler.CreateTopic).Methods("POST")
+	router.HandleFunc("/topics", handler.ListTopics).Methods("GET")
+	router.HandleFunc("/topics/{topic}", handler.DeleteTopic).Methods("DELETE")
+	router.HandleFunc("/admin/activity", handler.GetActivity).Methods("GET")
+
+	serve := func(method, path, body, header, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(header, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	serve("POST", "/topics", `{"name": "orders"}`, "X-API-Key", "test-key")
+	serve("GET", "/topics", "", "X-API-Key", "test-key")
+	serve("DELETE", "/topics/missing", "", "X-Admin-Key", "admin")
+	serve("DELETE", "/topics/orders", "", "X-API-Key", "wrong")
+
+	if w := serve("GET", "/admin/activity", "", "X-API-Key", "test-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin key, got %d", w.Code)
+	}
+	if w := serve("GET", "/admin/activity?window=48h", "", "X-Admin-Key", "admin"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a window past the retention, got %d", w.Code)
+	}
+
+	w := serve("GET", "/admin/activity?window=5m", "", "X-Admin-Key", "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var summary ActivitySummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to unmarshal summary: %v", err)
+	}
+	if summary.Window != "5m0s" || summary.Total != 3 || summary.Failed != 2 {
+		t.Errorf("Expected 3 operations in 5m0s, 2 failed, got %+v", summary)
+	}
+	if creates := summary.Operations[ActivityTopicCreate]; creates.Count != 1 || creates.Failed != 0 {
+		t.Errorf("Expected one successful create, got %+v", creates)
+	}
+	if deletes := summary.Operations[ActivityTopicDelete]; deletes.Count != 2 || deletes.Failed != 2 {
+		t.Errorf("Expected two failed deletes, got %+v", deletes)
+	}
+	if summary.Callers["api_key"] != 1 || summary.Callers["admin"] != 1 || summary.Callers["unauthenticated"] != 1 {
+		t.Errorf("Unexpected callers %v", summary.Callers)
+	}
+}
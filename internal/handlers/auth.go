@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"plivo/internal/auth"
+)
+
+// requestKey returns the X-API-Key header. With allowQuery it falls back to
+// the api_key query parameter, for browsers, which can't set headers on
+// WebSocket handshakes or EventSource requests.
+func requestKey(r *http.Request, allowQuery bool) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" && allowQuery {
+		key = r.URL.Query().Get("api_key")
+	}
+	return key
+}
+
+// clientCertName returns the common name of the client certificate the
+// server verified for the request, "" without mTLS
+func clientCertName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// authenticateCaller checks the request's API key, as requestKey reads it,
+// falling back to its verified client certificate, and returns the caller's
+// tenant ("" for the shared key)
+func authenticateCaller(authService *auth.Service, r *http.Request, allowQuery bool) (string, bool) {
+	return authService.AuthenticateCaller(requestKey(r, allowQuery), clientCertName(r))
+}
+
+// authenticateAdmin checks the admin credential, sent either as the
+// X-Admin-Key header or as the password of HTTP basic auth. Without an
+// admin key the API key is the admin credential; with neither, all requests
+// are allowed, as for the rest of the API.
+func authenticateAdmin(authService *auth.Service, r *http.Request) bool {
+	provided := r.Header.Get("X-Admin-Key")
+	if provided == "" {
+		_, provided, _ = r.BasicAuth()
+	}
+	return authService.IsAdmin(provided)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+)
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	cfg := config.NewTestConfig()
+	cfg.Security.APIKey = "shared"
+	cfg.Security.TenantKeys = "payments=pay-key"
+	hub := pubsub.NewHub()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	create := func(name, certName string) int {
+		req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name":"`+name+`"}`))
+		if certName != "" {
+			// As the server leaves it after verifying a client certificate
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+				{Subject: pkix.Name{CommonName: certName}},
+			}}}
+		}
+		w := httptest.NewRecorder()
+		handler.CreateTopic(w, req)
+		return w.Code
+	}
+
+	if code := create("invoices", "payments"); code != http.StatusCreated {
+		t.Fatalf("Expected status 201 with a tenant's certificate, got %d", code)
+	}
+	if stats, _ := hub.GetTopicStats("invoices"); stats.Owner != "payments" {
+		t.Errorf("Expected the certificate's tenant to own the topic, got %q", stats.Owner)
+	}
+
+	if code := create("orders", "edge-gateway"); code != http.StatusCreated {
+		t.Fatalf("Expected status 201 with another verified certificate, got %d", code)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.Owner != "" {
+		t.Errorf("Expected a non-tenant certificate to act as the shared key, got owner %q", stats.Owner)
+	}
+
+	if code := create("refunds", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a key or certificate, got %d", code)
+	}
+}
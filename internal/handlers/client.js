@@ -0,0 +1,638 @@
+/*
+ * Browser client for the pub/sub broker's WebSocket protocol, served at
+ * GET /client.js.
+ *
+ *   const client = new PubSubClient("wss://broker.example.com/ws", { apiKey: "..." });
+ *   client.subscribe("orders", (payload, event) => console.log(payload));
+ *   client.publish("orders", { order_id: "ORD-1" }).then((ack) => console.log(ack.message_id));
+ *
+ * The client reconnects with exponential backoff and jitter, resubscribes
+ * every topic after a reconnect and resumes from the last sequence it
+ * delivered: it asks for a replay of recent messages, drops the ones it has
+ * already seen and emits a "gap" event when the broker no longer retains
+ * everything that was missed. Subscriptions with a maxLatency also emit
+ * "gap" when the broker drops events that waited too long to be sent.
+ *
+ * Subscriptions with a leaseMs expire on the broker unless renewed; the
+ * client renews them with a ping carrying its lease token at half the
+ * shortest lease, and resubscribes a topic whose lease expired anyway.
+ *
+ * When a topic is drained the client emits "draining"; if the broker
+ * migrated the subscription, or refuses to resubscribe to a drained topic,
+ * the handler moves to the replacement topic.
+ *
+ * Consumer group members of partitioned topics emit "rebalance" with the
+ * partitions they own whenever members join or leave the group.
+ *
+ * While the broker is paused for maintenance the client emits "paused"
+ * with the pause, and "resumed" once it resumes.
+ *
+ * Every connection gets a private inbox topic, named in client.inbox, that
+ * only it may subscribe to. The name changes with each connection, so
+ * onInbox subscribes to the current one after every reconnect; share it
+ * with peers in a reply_to header so they can answer directly.
+ *
+ * Topics hold key/value state: setState and getState write and read it,
+ * and subscribers emit "state" with the topic's state when subscribing and
+ * "state_update" with each change.
+ *
+ * Events: open, close, reconnecting, info, error, gap, draining, rebalance,
+ * paused, resumed, state, state_update.
+ */
+(function (root, factory) {
+  if (typeof module === "object" && module.exports) {
+    module.exports = factory();
+  } else {
+    root.PubSubClient = factory();
+  }
+})(typeof self !== "undefined" ? self : this, function () {
+  "use strict";
+
+  var DEFAULTS = {
+    apiKey: "",
+    clientId: "",
+    // Connection attributes, e.g. { user_id: "123" }, for subscription
+    // filters such as { filter: { user_id: "$user_id" } }
+    attributes: {},
+    // Reconnect backoff in milliseconds; jitter spreads retries by +/- the given fraction
+    initialDelay: 500,
+    maxDelay: 30000,
+    factor: 2,
+    jitter: 0.2,
+    // Messages to request for replay when resuming a subscription after a
+    // reconnect; the broker caps this at the topic's max_last_n
+    resumeLastN: 100,
+    // How long publish/subscribe/unsubscribe/ping wait for their ack
+    requestTimeout: 10000,
+  };
+
+  function randomId() {
+    if (typeof crypto !== "undefined" && crypto.randomUUID) {
+      return crypto.randomUUID();
+    }
+    return Date.now().toString(36) + "-" + Math.random().toString(36).slice(2, 10);
+  }
+
+  // messageData builds a publish frame's message from a payload and the
+  // options publish takes
+  function messageData(payload, options) {
+    var o = options || {};
+    var message = { id: o.id || randomId(), payload: payload };
+    if (o.headers) {
+      message.headers = o.headers;
+    }
+    if (o.ttlMs) {
+      message.ttl_ms = o.ttlMs;
+    }
+    if (o.contentType) {
+      message.content_type = o.contentType;
+    }
+    return message;
+  }
+
+  function PubSubClient(url, options) {
+    this.url = url;
+    this.options = Object.assign({}, DEFAULTS, options || {});
+    this.clientId = this.options.clientId || randomId();
+
+    this._ws = null;
+    this._closed = false;
+    this._attempt = 0;
+    this._timer = null;
+    this._requestSeq = 0;
+    this._pending = {}; // request_id -> { resolve, reject, timer }
+    this._subscriptions = {}; // topic -> subscription state
+    this._listeners = {};
+    this._leaseToken = null; // renews leased subscriptions on this connection
+    this._leaseTimer = null;
+    this.inbox = null; // this connection's inbox topic, from the welcome frame
+    this._inboxHandler = null;
+
+    this.connect();
+  }
+
+  // on registers a listener for a client event
+  PubSubClient.prototype.on = function (event, listener) {
+    (this._listeners[event] = this._listeners[event] || []).push(listener);
+    return this;
+  };
+
+  // off removes a listener registered with on
+  PubSubClient.prototype.off = function (event, listener) {
+    var listeners = this._listeners[event] || [];
+    this._listeners[event] = listeners.filter(function (l) {
+      return l !== listener;
+    });
+    return this;
+  };
+
+  PubSubClient.prototype._emit = function (event, data) {
+    (this._listeners[event] || []).slice().forEach(function (listener) {
+      listener(data);
+    });
+  };
+
+  // connected reports whether the socket is open
+  PubSubClient.prototype.connected = function () {
+    return this._ws !== null && this._ws.readyState === 1;
+  };
+
+  // connect opens the socket; it is called by the constructor and on reconnect
+  PubSubClient.prototype.connect = function () {
+    var self = this;
+    var url = this.url;
+    if (this.options.apiKey) {
+      // Browsers cannot set headers on WebSocket handshakes
+      url += (url.indexOf("?") === -1 ? "?" : "&") + "api_key=" + encodeURIComponent(this.options.apiKey);
+    }
+    var attributes = this.options.attributes || {};
+    Object.keys(attributes).forEach(function (name) {
+      url += (url.indexOf("?") === -1 ? "?" : "&") + "attr." + encodeURIComponent(name) + "=" + encodeURIComponent(attributes[name]);
+    });
+
+    var ws = new WebSocket(url);
+    this._ws = ws;
+
+    ws.onopen = function () {
+      self._attempt = 0;
+      self._emit("open");
+      self._resubscribe();
+    };
+    ws.onmessage = function (event) {
+      var frame;
+      try {
+        frame = JSON.parse(event.data);
+      } catch (err) {
+        self._emit("error", err);
+        return;
+      }
+      self._handleFrame(frame);
+    };
+    ws.onclose = function (event) {
+      if (self._ws !== ws) {
+        return;
+      }
+      self._ws = null;
+      self._stopLeases();
+      if (self.inbox) {
+        // The broker removes the inbox with the connection
+        delete self._subscriptions[self.inbox];
+        self.inbox = null;
+      }
+      self._failPending(new Error("connection closed"));
+      self._emit("close", { code: event.code, reason: event.reason });
+      if (!self._closed) {
+        self._scheduleReconnect();
+      }
+    };
+    ws.onerror = function () {
+      // onclose follows and schedules the reconnect
+    };
+  };
+
+  // close disconnects for good; pending requests are rejected
+  PubSubClient.prototype.close = function () {
+    this._closed = true;
+    clearTimeout(this._timer);
+    this._stopLeases();
+    if (this._ws) {
+      this._ws.close(1000);
+    }
+  };
+
+  PubSubClient.prototype._scheduleReconnect = function () {
+    var o = this.options;
+    var delay = Math.min(o.maxDelay, o.initialDelay * Math.pow(o.factor, this._attempt));
+    delay = delay * (1 - o.jitter + Math.random() * 2 * o.jitter);
+    this._attempt++;
+
+    var self = this;
+    this._emit("reconnecting", { attempt: this._attempt, delay: delay });
+    this._timer = setTimeout(function () {
+      if (!self._closed) {
+        self.connect();
+      }
+    }, delay);
+  };
+
+  // subscribe delivers the topic's events to handler(payload, event).
+  // Options: lastN, fields, filter, where, group, keyId (key_id), maxLatency
+  // (max_latency, in milliseconds), leaseMs (lease_ms), echoSelf (echo_self,
+  // false to skip events this client published), as in the subscribe frame.
+  PubSubClient.prototype.subscribe = function (topic, handler, options) {
+    var sub = {
+      topic: topic,
+      handler: handler,
+      options: options || {},
+      lastSequence: 0,
+      lastId: "",
+      resumeFloor: 0,
+      resume: null,
+    };
+    this._subscriptions[topic] = sub;
+    if (!this.connected()) {
+      // Sent once the socket opens
+      return Promise.resolve(null);
+    }
+    return this._sendSubscribe(sub, sub.options.lastN);
+  };
+
+  // onInbox delivers the events published to this connection's inbox to
+  // handler(payload, event), following the inbox across reconnects
+  PubSubClient.prototype.onInbox = function (handler) {
+    this._inboxHandler = handler;
+    if (!this.inbox) {
+      // Subscribed once the welcome frame names the inbox
+      return Promise.resolve(null);
+    }
+    return this.subscribe(this.inbox, handler);
+  };
+
+  // unsubscribe stops delivery for the topic
+  PubSubClient.prototype.unsubscribe = function (topic) {
+    delete this._subscriptions[topic];
+    if (!this.connected()) {
+      return Promise.resolve(null);
+    }
+    return this._request({ type: "unsubscribe", topic: topic, client_id: this.clientId });
+  };
+
+  // publish sends payload to the topic and resolves with the ack.
+  // Options: id, headers, ttlMs, contentType.
+  PubSubClient.prototype.publish = function (topic, payload, options) {
+    return this._request({ type: "publish", topic: topic, message: messageData(payload, options) });
+  };
+
+  // publishBatch sends several messages to the topic in one publish_batch
+  // frame, all published or none, and resolves with the ack, whose results
+  // list each message's ID and status. Each entry is { payload, options },
+  // with the options publish takes.
+  PubSubClient.prototype.publishBatch = function (topic, entries) {
+    var messages = entries.map(function (entry) {
+      return messageData(entry.payload, entry.options);
+    });
+    return this._request({ type: "publish_batch", topic: topic, messages: messages });
+  };
+
+  // ping resolves with the pong frame, with the round-trip time it took in
+  // rtt_ms, timed from the send time the server echoes back
+  PubSubClient.prototype.ping = function () {
+    return this._request({ type: "ping", data: { sent: Date.now() } }).then(function (pong) {
+      if (pong.data && typeof pong.data.sent === "number") {
+        pong.rtt_ms = Date.now() - pong.data.sent;
+      }
+      return pong;
+    });
+  };
+
+  // setState sets a key of the topic's state to value, or deletes it for
+  // null, and resolves with the ack. Options: ephemeral, to delete the key
+  // when this connection closes.
+  PubSubClient.prototype.setState = function (topic, key, value, options) {
+    var frame = { type: "state_set", topic: topic, key: key, value: value };
+    if (options && options.ephemeral) {
+      frame.ephemeral = true;
+    }
+    return this._request(frame);
+  };
+
+  // getState resolves with the topic's state by key, or with only the given
+  // key when there is one
+  PubSubClient.prototype.getState = function (topic, key) {
+    var frame = { type: "state_get", topic: topic };
+    if (key) {
+      frame.key = key;
+    }
+    return this._request(frame).then(function (reply) {
+      return reply.state || {};
+    });
+  };
+
+  PubSubClient.prototype._sendSubscribe = function (sub, lastN) {
+    var frame = { type: "subscribe", topic: sub.topic, client_id: this.clientId };
+    if (lastN) {
+      frame.last_n = lastN;
+    }
+    if (sub.options.fields) {
+      frame.fields = sub.options.fields;
+    }
+    if (sub.options.filter) {
+      frame.filter = sub.options.filter;
+    }
+    if (sub.options.where) {
+      frame.where = sub.options.where;
+    }
+    if (sub.options.group) {
+      frame.group = sub.options.group;
+    }
+    if (sub.options.keyId) {
+      frame.key_id = sub.options.keyId;
+    }
+    if (sub.options.maxLatency) {
+      frame.max_latency = sub.options.maxLatency;
+    }
+    if (sub.options.leaseMs) {
+      frame.lease_ms = sub.options.leaseMs;
+    }
+    if (sub.options.echoSelf === false) {
+      frame.echo_self = false;
+    }
+
+    var self = this;
+    return this._request(frame).then(function (ack) {
+      self._checkResume(sub, ack.subscription);
+      if (ack.subscription && ack.subscription.lease) {
+        self._leaseToken = ack.subscription.lease.token;
+        self._scheduleRenewal();
+      }
+      return ack;
+    });
+  };
+
+  // _scheduleRenewal renews leased subscriptions at half the shortest lease
+  PubSubClient.prototype._scheduleRenewal = function () {
+    var self = this;
+    var shortest = 0;
+    Object.keys(this._subscriptions).forEach(function (topic) {
+      var leaseMs = self._subscriptions[topic].options.leaseMs;
+      if (leaseMs && (!shortest || leaseMs < shortest)) {
+        shortest = leaseMs;
+      }
+    });
+
+    clearTimeout(this._leaseTimer);
+    this._leaseTimer = null;
+    if (!shortest || !this._leaseToken) {
+      return;
+    }
+    this._leaseTimer = setTimeout(function () {
+      if (!self.connected()) {
+        return;
+      }
+      self
+        ._request({ type: "ping", lease: self._leaseToken })
+        .catch(function (err) {
+          self._emit("error", err);
+        })
+        .then(function () {
+          if (self.connected()) {
+            self._scheduleRenewal();
+          }
+        });
+    }, shortest / 2);
+  };
+
+  // _stopLeases forgets the lease token, which lasts one connection
+  PubSubClient.prototype._stopLeases = function () {
+    clearTimeout(this._leaseTimer);
+    this._leaseTimer = null;
+    this._leaseToken = null;
+  };
+
+  // _resubscribe restores every subscription after a (re)connect. Topics that
+  // have delivered events resume from the last sequence; consumer group
+  // members resume from the group's offset on the broker.
+  PubSubClient.prototype._resubscribe = function () {
+    var self = this;
+    Object.keys(this._subscriptions).forEach(function (topic) {
+      self._resume(self._subscriptions[topic]);
+    });
+  };
+
+  // _resume resubscribes one topic, resuming after its last delivered event
+  PubSubClient.prototype._resume = function (sub) {
+    var self = this;
+    var lastN = sub.options.lastN;
+    sub.resumeFloor = 0;
+    sub.resume = null;
+    if (sub.lastSequence > 0 && !sub.options.group) {
+      sub.resumeFloor = sub.lastSequence;
+      sub.resume = { seen: {}, upto: 0, remaining: -1 };
+      lastN = this.options.resumeLastN;
+    }
+    this._sendSubscribe(sub, lastN).catch(function (err) {
+      if (err.code === "TOPIC_DRAINING" && err.replacement) {
+        var drained = sub.topic;
+        self._moveSubscription(sub, err.replacement, true);
+        self._emit("draining", { topic: drained, replacement: err.replacement, migrated: false });
+        return;
+      }
+      self._emit("error", err);
+    });
+  };
+
+  // _handleDrain follows a topic_draining or topic_migrated info frame. A
+  // migrated subscription already lives on the replacement topic; otherwise
+  // the current subscription keeps working and the application decides when
+  // to move.
+  PubSubClient.prototype._handleDrain = function (frame) {
+    var sub = this._subscriptions[frame.topic];
+    var migrated = frame.msg === "topic_migrated";
+    if (sub && migrated) {
+      this._moveSubscription(sub, frame.replacement, false);
+    }
+    this._emit("draining", { topic: frame.topic, replacement: frame.replacement || "", migrated: migrated });
+  };
+
+  // _moveSubscription re-keys a subscription to the replacement topic,
+  // subscribing to it unless the broker already moved it. Sequences are per
+  // topic, so resume state starts over.
+  PubSubClient.prototype._moveSubscription = function (sub, replacement, subscribe) {
+    var self = this;
+    delete this._subscriptions[sub.topic];
+    sub.topic = replacement;
+    sub.lastSequence = 0;
+    sub.lastId = "";
+    sub.resumeFloor = 0;
+    sub.resume = null;
+    this._subscriptions[replacement] = sub;
+    if (subscribe && this.connected()) {
+      this._sendSubscribe(sub, sub.options.lastN).catch(function (err) {
+        self._emit("error", err);
+      });
+    }
+  };
+
+  // _checkResume records the topic's state from the subscribe ack: events up
+  // to its sequence were published while disconnected, and the replay that
+  // follows the ack carries those the broker still retains
+  PubSubClient.prototype._checkResume = function (sub, info) {
+    var resume = sub.resume;
+    if (!info || !resume) {
+      return;
+    }
+    if (info.sequence < sub.resumeFloor) {
+      // The topic was recreated; its sequence started over
+      sub.resumeFloor = 0;
+      sub.lastSequence = 0;
+      sub.resume = null;
+      return;
+    }
+    resume.upto = info.sequence;
+    resume.remaining = info.replaying;
+    Object.keys(resume.seen).forEach(function (seq) {
+      if (seq <= resume.upto) {
+        resume.remaining--;
+      }
+    });
+    if (resume.remaining <= 0) {
+      this._finishResume(sub);
+    }
+  };
+
+  // _finishResume emits a gap event for each range of sequences that was
+  // published while disconnected but neither replayed nor delivered
+  PubSubClient.prototype._finishResume = function (sub) {
+    var resume = sub.resume;
+    sub.resume = null;
+
+    var seen = Object.keys(resume.seen)
+      .map(Number)
+      .filter(function (seq) {
+        return seq > sub.resumeFloor && seq <= resume.upto;
+      })
+      .sort(function (a, b) {
+        return a - b;
+      });
+    if (sub.options.filter || sub.options.where || sub.options.echoSelf === false) {
+      // Events the filter or where skips, or the client's own when echoSelf is off,
+      // leave holes in the sequence that aren't gaps
+      return;
+    }
+    seen.push(resume.upto + 1);
+
+    var next = sub.resumeFloor + 1;
+    for (var i = 0; i < seen.length; i++) {
+      if (seen[i] > next) {
+        this._emit("gap", { topic: sub.topic, from: next, to: seen[i] - 1 });
+      }
+      next = seen[i] + 1;
+    }
+  };
+
+  PubSubClient.prototype._handleFrame = function (frame) {
+    switch (frame.type) {
+      case "event":
+        this._deliver(frame);
+        return;
+      case "info":
+        if (frame.inbox) {
+          this.inbox = frame.inbox;
+          if (this._inboxHandler) {
+            this.subscribe(frame.inbox, this._inboxHandler).catch(this._emit.bind(this, "error"));
+          }
+        }
+        if (frame.msg === "topic_draining" || frame.msg === "topic_migrated") {
+          this._handleDrain(frame);
+        }
+        if (frame.msg === "gap" && frame.gap) {
+          // Live events dropped for missing the subscription's max_latency
+          this._emit("gap", { topic: frame.topic, from: frame.gap.from, to: frame.gap.to, dropped: frame.gap.dropped });
+        }
+        if (frame.msg === "rebalance" && frame.assignment) {
+          this._emit("rebalance", Object.assign({ topic: frame.topic }, frame.assignment));
+        }
+        if (frame.msg === "hub_paused") {
+          this._emit("paused", frame.pause);
+        }
+        if (frame.msg === "hub_resumed") {
+          this._emit("resumed");
+        }
+        if (frame.msg === "lease_expired" && this._subscriptions[frame.topic] && this.connected()) {
+          // A renewal arrived too late; the broker dropped the subscription
+          this._resume(this._subscriptions[frame.topic]);
+        }
+        this._emit("info", frame);
+        break;
+      case "state":
+        if (!frame.request_id) {
+          // The topic's state, sent ahead of a subscription's events
+          this._emit("state", { topic: frame.topic, state: frame.state || {} });
+        }
+        break;
+      case "state_update":
+        this._emit("state_update", Object.assign({ topic: frame.topic }, frame.update));
+        return;
+      case "error":
+        if (!frame.request_id || !this._pending[frame.request_id]) {
+          this._emit("error", frame.error);
+        }
+        break;
+    }
+
+    var pending = frame.request_id && this._pending[frame.request_id];
+    if (pending) {
+      delete this._pending[frame.request_id];
+      clearTimeout(pending.timer);
+      if (frame.type === "error") {
+        var err = new Error(frame.error.message);
+        err.code = frame.error.code;
+        err.replacement = frame.error.replacement;
+        pending.reject(err);
+      } else {
+        pending.resolve(frame);
+      }
+    }
+  };
+
+  PubSubClient.prototype._deliver = function (frame) {
+    var sub = this._subscriptions[frame.topic];
+    if (!sub) {
+      return;
+    }
+    var seq = frame.sequence || 0;
+    var resume = sub.resume;
+    if (resume && seq > 0 && !resume.seen[seq]) {
+      resume.seen[seq] = true;
+      if (resume.remaining > 0 && seq <= resume.upto && --resume.remaining === 0) {
+        this._finishResume(sub);
+      }
+    }
+    if (seq > 0 && seq <= sub.resumeFloor) {
+      // Already delivered before the reconnect
+      return;
+    }
+    if (seq > sub.lastSequence) {
+      sub.lastSequence = seq;
+    }
+    if (frame.message) {
+      sub.lastId = frame.message.id;
+    }
+    sub.handler(frame.message ? frame.message.payload : undefined, frame);
+  };
+
+  // lastDelivered returns the sequence and ID of the topic's last delivered event
+  PubSubClient.prototype.lastDelivered = function (topic) {
+    var sub = this._subscriptions[topic];
+    return sub ? { sequence: sub.lastSequence, id: sub.lastId } : null;
+  };
+
+  PubSubClient.prototype._request = function (frame) {
+    if (!this.connected()) {
+      return Promise.reject(new Error("not connected"));
+    }
+
+    var self = this;
+    var id = this.clientId + "-" + ++this._requestSeq;
+    frame.request_id = id;
+    return new Promise(function (resolve, reject) {
+      var timer = setTimeout(function () {
+        delete self._pending[id];
+        reject(new Error("request timed out"));
+      }, self.options.requestTimeout);
+      self._pending[id] = { resolve: resolve, reject: reject, timer: timer };
+      self._ws.send(JSON.stringify(frame));
+    });
+  };
+
+  PubSubClient.prototype._failPending = function (err) {
+    var pending = this._pending;
+    this._pending = {};
+    Object.keys(pending).forEach(function (id) {
+      clearTimeout(pending[id].timer);
+      pending[id].reject(err);
+    });
+  };
+
+  return PubSubClient;
+});
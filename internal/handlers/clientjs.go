@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"net/http"
+)
+
+//go:embed client.js
+var clientScript []byte
+
+// clientScriptETag identifies the embedded script's content
+var clientScriptETag = func() string {
+	sum := sha256.Sum256(clientScript)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}()
+
+// ClientScript serves the browser WebSocket client
+// @Summary Browser client library
+// @Description JavaScript client for the WebSocket protocol with reconnect, resubscribe and resume from the last delivered sequence. Exposes a PubSubClient global (or CommonJS export).
+// @Tags system
+// @Produce application/javascript
+// @Success 200 {string} string "Client script"
+// @Success 304 "Not modified"
+// @Router /client.js [get]
+func ClientScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", clientScriptETag)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if r.Header.Get("If-None-Match") == clientScriptETag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Write(clientScript)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientScript(t *testing.T) {
+	req := httptest.NewRequest("GET", "/client.js", nil)
+	w := httptest.NewRecorder()
+	ClientScript(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Errorf("Expected JavaScript content type, got %q", ct)
+	}
+	if !strings.Contains(w.Body.String(), "PubSubClient") {
+		t.Error("Expected the script to define PubSubClient")
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	// A cached copy is revalidated without resending the script
+	req = httptest.NewRequest("GET", "/client.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	ClientScript(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Error("Expected an empty body on 304")
+	}
+}
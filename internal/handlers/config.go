@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+)
+
+// ConfigDump is the broker's running configuration
+type ConfigDump struct {
+	// File is the config file the configuration was loaded from
+	File string `json:"file,omitempty"`
+	// Settings lists every setting in config file order
+	Settings []config.Setting `json:"settings"`
+}
+
+// SetRunningConfig replaces the configuration GetConfig reports, once a
+// reload applies new settings
+func (h *RESTHandler) SetRunningConfig(cfg *config.Config) {
+	h.running.Store(cfg)
+}
+
+// GetConfig reports the running configuration
+// @Summary Get the running configuration
+// @Description Get the configuration the broker is running with, including settings reloaded on SIGHUP, to debug its behavior without shell access to the host. Each setting is listed by its config file key with its value, the flag and environment variable that set it, and its source: default, file, env or flag, the highest of those that set it. API, admin and tenant keys and the alert webhook URL are redacted.
+// @Tags system
+// @Produce json
+// @Success 200 {object} ConfigDump "Running configuration"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /admin/config [get]
+func (h *RESTHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	running := h.running.Load().Redacted()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ConfigDump{File: running.File(), Settings: running.Settings()})
+}
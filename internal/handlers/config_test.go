@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+)
+
+func TestGetConfig(t *testing.T) {
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	cfg.Security.AdminKey = "admin"
+	handler := NewRESTHandler(pubsub.NewHub(), cfg, auth.MustNewService(cfg.Security))
+
+	get := func(header, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/admin/config", nil)
+		req.Header.Set(header, key)
+		w := httptest.NewRecorder()
+		handler.GetConfig(w, req)
+		return w
+	}
+
+	if w := get("X-API-Key", "test-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin key, got %d", w.Code)
+	}
+
+	reloaded := *cfg
+	reloaded.Logging.Level = "debug"
+	handler.SetRunningConfig(&reloaded)
+
+	w := get("X-Admin-Key", "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); strings.Contains(body, "test-key") || strings.Contains(body, `"admin"`) {
+		t.Errorf("Expected the keys redacted, got %s", body)
+	}
+
+	var dump ConfigDump
+	if err := json.Unmarshal(w.Body.Bytes(), &dump); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+	settings := make(map[string]config.Setting)
+	for _, setting := range dump.Settings {
+		settings[setting.Key] = setting
+	}
+	if level := settings["logging.level"]; level.Value != "debug" || level.Flag != "-log-level" || level.Env != "LOG_LEVEL" {
+		t.Errorf("Expected the reloaded log level, got %+v", level)
+	}
+	if timeout := settings["server.read_timeout"]; timeout.Value != cfg.Server.ReadTimeout.String() || timeout.Source != config.SourceDefault {
+		t.Errorf("Expected the read timeout as a duration string, got %+v", timeout)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"plivo/internal/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORS response settings
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, X-API-Key, X-Admin-Key, X-Request-ID, If-Match, Last-Event-ID"
+	corsExposeHeaders = "ETag, Retry-After, X-Request-ID"
+	corsMaxAge        = 10 * time.Minute
+)
+
+// originPolicy decides which browser origins may call the API, from the
+// comma-separated -allowed-origins list. Entries are exact origins such as
+// https://app.example.com, wildcard subdomains such as
+// https://*.example.com, or * for any origin.
+type originPolicy struct {
+	any     bool
+	origins []string
+}
+
+// newOriginPolicy parses an allowed-origins list
+func newOriginPolicy(allowed string) originPolicy {
+	var policy originPolicy
+	for _, origin := range strings.Split(allowed, ",") {
+		origin = strings.ToLower(strings.TrimRight(strings.TrimSpace(origin), "/"))
+		switch origin {
+		case "":
+		case "*":
+			policy.any = true
+		default:
+			policy.origins = append(policy.origins, origin)
+		}
+	}
+	return policy
+}
+
+// allows reports whether origin is on the list
+func (p originPolicy) allows(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if origin == allowed {
+			return true
+		}
+		// https://*.example.com matches any subdomain, not example.com itself
+		if scheme, host, ok := strings.Cut(allowed, "://*."); ok &&
+			strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether the request's Origin names the host it was
+// sent to, as a page served by the broker itself would
+func sameOrigin(r *http.Request) bool {
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && strings.EqualFold(origin.Host, r.Host)
+}
+
+// CORSMiddleware lets browser pages from the allowed origins call the API
+// when CORS is enabled. Responses to allowed origins carry the
+// Access-Control-Allow-* headers, and preflight OPTIONS requests are
+// answered here: 204 for allowed origins, 403 for others. With CORS
+// disabled requests pass through untouched, so browsers keep to the same
+// origin.
+func CORSMiddleware(cfg config.SecurityConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if !cfg.EnableCORS {
+			return next
+		}
+		policy := newOriginPolicy(cfg.AllowedOrigins)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			allowed := policy.allows(origin)
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			}
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// IsOptions matches OPTIONS requests to any path. Unlike Methods("OPTIONS")
+// on a catch-all route, it leaves other methods on unknown paths to 404
+// rather than 405.
+func IsOptions(r *http.Request, _ *mux.RouteMatch) bool {
+	return r.Method == http.MethodOptions
+}
+
+// Preflight is the handler for OPTIONS requests, so the router matches
+// them and CORSMiddleware can answer them. It only runs for requests that
+// aren't CORS preflights, which have nothing to answer.
+func Preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", corsAllowMethods)
+	w.WriteHeader(http.StatusNoContent)
+}
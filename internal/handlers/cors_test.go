@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	policy := newOriginPolicy("https://app.example.com, https://*.example.org/")
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"http://app.example.com", false},
+		{"https://evil.com", false},
+		{"https://shop.example.org", true},
+		{"https://example.org", false},
+		{"https://shop.example.org.evil.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := policy.allows(tt.origin); got != tt.want {
+			t.Errorf("allows(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	if !newOriginPolicy("*").allows("https://anything.test") {
+		t.Error("Expected * to allow any origin")
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	cfg := config.NewTestConfig()
+	cfg.Security.EnableCORS = true
+	cfg.Security.AllowedOrigins = "https://app.example.com"
+
+	router := mux.NewRouter()
+	router.Use(CORSMiddleware(cfg.Security))
+	router.HandleFunc("/topics", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	router.MatcherFunc(IsOptions).HandlerFunc(Preflight)
+
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodOptions, "/topics", "https://app.example.com")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 for an allowed preflight, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected the origin allowed, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("Expected allowed methods and headers, got %v", w.Header())
+	}
+
+	if w := request(http.MethodOptions, "/topics", "https://evil.com"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a disallowed preflight, got %d", w.Code)
+	}
+
+	w = request("POST", "/topics", "https://app.example.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("Expected the request served with CORS headers, got %d %v", w.Code, w.Header())
+	}
+	w = request("POST", "/topics", "https://evil.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected no CORS headers for a disallowed origin, got %v", w.Header())
+	}
+
+	// Unknown paths still 404
+	if w := request("GET", "/missing", "https://app.example.com"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown path, got %d", w.Code)
+	}
+}
+
+func TestWebSocketUpgraderChecksAllowedOrigins(t *testing.T) {
+	cfg := config.NewTestConfig()
+	cfg.Security.EnableCORS = true
+	cfg.Security.AllowedOrigins = "https://app.example.com"
+	handler := NewWebSocketHandler(pubsub.NewHub(), cfg, auth.MustNewService(cfg.Security))
+	upgrader := handler.getUpgrader()
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://evil.com", false},
+		{"http://example.com", true}, // the broker's own host
+		{"", true},                   // not a browser
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := upgrader.CheckOrigin(req); got != tt.want {
+			t.Errorf("CheckOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"plivo/docs"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+
+	httpSwagger "github.com/swaggo/http-swagger"
+)
+
+// DocsHandler serves the Swagger UI and API spec behind the admin credential
+type DocsHandler struct {
+	cfg  *config.Config
+	auth *auth.Service
+	ui   http.HandlerFunc
+}
+
+// NewDocsHandler creates a new documentation handler. It panics if any
+// dependency is missing.
+func NewDocsHandler(cfg *config.Config, authService *auth.Service) *DocsHandler {
+	if cfg == nil || authService == nil {
+		panic("handlers: NewDocsHandler requires a config and auth service")
+	}
+	return &DocsHandler{
+		cfg:  cfg,
+		auth: authService,
+		// Relative, so the UI loads the spec from whichever host served it
+		ui: httpSwagger.Handler(httpSwagger.URL("doc.json")),
+	}
+}
+
+// ServeHTTP serves the spec at doc.json and the UI for everything else
+func (h *DocsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		// Let browsers prompt for the credential
+		w.Header().Set("WWW-Authenticate", `Basic realm="plivo admin"`)
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	if r.URL.Path == "/swagger/doc.json" {
+		h.serveSpec(w, r)
+		return
+	}
+	h.ui(w, r)
+}
+
+// serveSpec renders the spec with the host and base path it is reached at
+func (h *DocsHandler) serveSpec(w http.ResponseWriter, r *http.Request) {
+	spec := *docs.SwaggerInfo
+	spec.Host = h.cfg.Docs.Host
+	if spec.Host == "" {
+		spec.Host = requestHost(r)
+	}
+	spec.BasePath = h.cfg.Docs.BasePath
+	if spec.BasePath == "" {
+		spec.BasePath = "/"
+	}
+	spec.Schemes = []string{requestScheme(r)}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(spec.ReadDoc()))
+}
+
+// requestHost returns the host the client addressed, honoring a proxy's
+// X-Forwarded-Host
+func requestHost(r *http.Request) string {
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		return host
+	}
+	return r.Host
+}
+
+// requestScheme returns the scheme the client used, honoring a proxy's
+// X-Forwarded-Proto
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"testing"
+)
+
+func TestDocsRequireAdminCredential(t *testing.T) {
+	cfg := config.NewTestConfigWithAPIKey("api-key")
+	cfg.Security.AdminKey = "admin-key"
+	handler := NewDocsHandler(cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "/swagger/doc.json", nil)
+	req.Header.Set("X-API-Key", "api-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the API key to be refused, got %d", w.Code)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected a basic auth challenge")
+	}
+
+	req = httptest.NewRequest("GET", "/swagger/doc.json", nil)
+	req.Header.Set("X-Admin-Key", "admin-key")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the admin key header, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/swagger/doc.json", nil)
+	req.SetBasicAuth("admin", "admin-key")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with basic auth, got %d", w.Code)
+	}
+}
+
+func TestDocsAdminCredentialFallsBackToAPIKey(t *testing.T) {
+	cfg := config.NewTestConfigWithAPIKey("api-key")
+	handler := NewDocsHandler(cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "/swagger/doc.json", nil)
+	req.Header.Set("X-Admin-Key", "api-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the API key to act as admin credential, got %d", w.Code)
+	}
+}
+
+func TestDocsSpecUsesRequestHost(t *testing.T) {
+	cfg := config.NewTestConfig()
+	handler := NewDocsHandler(cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "https://pubsub.example.com/swagger/doc.json", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var spec struct {
+		Host     string   `json:"host"`
+		BasePath string   `json:"basePath"`
+		Schemes  []string `json:"schemes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Failed to unmarshal spec: %v", err)
+	}
+
+	if spec.Host != "pubsub.example.com" || spec.BasePath != "/" {
+		t.Errorf("Expected host from the request, got %q %q", spec.Host, spec.BasePath)
+	}
+	if len(spec.Schemes) != 1 || spec.Schemes[0] != "https" {
+		t.Errorf("Expected https scheme, got %v", spec.Schemes)
+	}
+}
+
+func TestDocsSpecUsesConfiguredHost(t *testing.T) {
+	cfg := config.NewTestConfig()
+	cfg.Docs.Host = "api.example.com"
+	cfg.Docs.BasePath = "/pubsub"
+	handler := NewDocsHandler(cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "/swagger/doc.json", nil)
+	req.Header.Set("X-Forwarded-Host", "other.example.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var spec struct {
+		Host     string `json:"host"`
+		BasePath string `json:"basePath"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Failed to unmarshal spec: %v", err)
+	}
+
+	if spec.Host != "api.example.com" || spec.BasePath != "/pubsub" {
+		t.Errorf("Expected configured host and base path, got %q %q", spec.Host, spec.BasePath)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openMetricsContentType is the media type of the OpenMetrics text format
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// TopicMetrics returns a topic's statistics in the OpenMetrics text format
+// @Summary Get topic metrics
+// @Description Export a single topic's counters and gauges in the OpenMetrics text format, so a team can scrape its own topic from shared infrastructure. Every sample is labelled with the topic name.
+// @Tags topics
+// @Produce plain
+// @Param topic path string true "Topic name"
+// @Success 200 {string} string "OpenMetrics exposition"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/metrics [get]
+func (h *RESTHandler) TopicMetrics(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	stats, err := h.hub.GetTopicStats(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", openMetricsContentType)
+	writeTopicMetrics(w, stats)
+}
+
+// metricsWriter writes OpenMetrics metric families, every sample labelled
+// with one topic
+type metricsWriter struct {
+	out   *bufio.Writer
+	topic string
+}
+
+// family writes a metric family's metadata
+func (m *metricsWriter) family(name, kind, unit, help string) {
+	fmt.Fprintf(m.out, "# TYPE %s %s\n", name, kind)
+	if unit != "" {
+		fmt.Fprintf(m.out, "# UNIT %s %s\n", name, unit)
+	}
+	fmt.Fprintf(m.out, "# HELP %s %s\n", name, help)
+}
+
+// sample writes one sample, with extra labels as name/value pairs
+func (m *metricsWriter) sample(name string, value interface{}, labels ...string) {
+	fmt.Fprintf(m.out, "%s{topic=\"%s\"", name, escapeLabelValue(m.topic))
+	for i := 0; i+1 < len(labels); i += 2 {
+		fmt.Fprintf(m.out, ",%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+	}
+	fmt.Fprintf(m.out, "} %v\n", value)
+}
+
+// counter writes a counter family with its single sample
+func (m *metricsWriter) counter(name, help string, value int64) {
+	m.family(name, "counter", "", help)
+	m.sample(name+"_total", value)
+}
+
+// gauge writes a gauge family with its single sample
+func (m *metricsWriter) gauge(name, unit, help string, value interface{}) {
+	m.family(name, "gauge", unit, help)
+	m.sample(name, value)
+}
+
+// writeTopicMetrics writes a topic's statistics as OpenMetrics families
+// named plivo_topic_*, ending with the required # EOF
+func writeTopicMetrics(w io.Writer, stats pubsub.TopicStats) {
+	m := &metricsWriter{out: bufio.NewWriter(w), topic: stats.Name}
+
+	m.counter("plivo_topic_messages", "Messages published to the topic.", stats.MessageCount)
+	m.counter("plivo_topic_dropped", "Events dropped from slow subscribers' queues.", stats.DroppedCount)
+	m.counter("plivo_topic_dead_lettered", "Events subscribers lost that went to the dead-letter topic.", stats.DeadLettered)
+	m.counter("plivo_topic_shadowed", "Publishes copied to the shadow topic.", stats.Shadowed)
+	m.counter("plivo_topic_sampled", "Publishes sampled for inspection.", stats.Sampled)
+	m.counter("plivo_topic_executor_saturations", "Times the topic's fan-out executor filled its queue.", stats.ExecutorSaturations)
+
+	m.gauge("plivo_topic_subscribers", "", "Connected subscribers.", stats.SubscriberCount)
+	m.gauge("plivo_topic_sequence", "", "Sequence of the newest published event.", stats.Sequence)
+	m.gauge("plivo_topic_backlog", "", "Publishes accepted but not yet fanned out.", stats.Backlog)
+	m.gauge("plivo_topic_executor_queued", "", "Publishes waiting on the topic's fan-out executor.", stats.ExecutorQueued)
+	m.gauge("plivo_topic_buffer_occupancy", "", "Retained messages available for replay.", stats.BufferOccupancy)
+	m.gauge("plivo_topic_buffer_capacity", "", "Retained messages the replay buffer holds at most.", stats.BufferCapacity)
+	m.gauge("plivo_topic_retained_bytes", "bytes", "Approximate memory held by retained messages.", stats.RetainedBytes)
+	m.gauge("plivo_topic_created_timestamp_seconds", "seconds", "When the topic was created.", stats.CreatedAt.Unix())
+	if stats.LastPublishAt != nil {
+		m.gauge("plivo_topic_last_publish_timestamp_seconds", "seconds", "When the topic last accepted a publish.", stats.LastPublishAt.Unix())
+	}
+
+	m.family("plivo_topic_payload_size_bytes", "summary", "bytes", "Sizes of published payloads.")
+	m.sample("plivo_topic_payload_size_bytes", stats.PayloadSize.P50, "quantile", "0.5")
+	m.sample("plivo_topic_payload_size_bytes", stats.PayloadSize.P95, "quantile", "0.95")
+	m.sample("plivo_topic_payload_size_bytes_count", stats.PayloadSize.Count)
+	m.gauge("plivo_topic_payload_size_max_bytes", "bytes", "Largest published payload.", stats.PayloadSize.Max)
+
+	fmt.Fprint(m.out, "# EOF\n")
+	m.out.Flush()
+}
+
+// labelEscaper escapes label values as the OpenMetrics text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
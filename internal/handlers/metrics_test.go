@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTopicMetrics(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+	stream, err := hub.OpenStream("scraper", "orders", pubsub.StreamOptions{}, pubsub.DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	message := &pubsub.PubSubMessage{Topic: "orders", Message: &pubsub.MessageData{ID: "msg-1", Payload: "hello"}}
+	if _, err := hub.TryPublish(message, time.Second); err != nil {
+		t.Fatalf("TryPublish failed: %v", err)
+	}
+
+	scrape := func(topic, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/topics/"+topic+"/metrics", nil)
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler.TopicMetrics(w, req)
+		return w
+	}
+
+	// Publishes are counted once the hub has fanned them out
+	w := scrape("orders", "test-key")
+	for deadline := time.Now().Add(time.Second); !strings.Contains(w.Body.String(), `plivo_topic_sequence{topic="orders"} 1`) && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		w = scrape("orders", "test-key")
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("Expected the OpenMetrics content type, got %q", contentType)
+	}
+
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE plivo_topic_messages counter",
+		`plivo_topic_messages_total{topic="orders"} 1`,
+		`plivo_topic_sequence{topic="orders"} 1`,
+		`plivo_topic_subscribers{topic="orders"} 1`,
+		"# UNIT plivo_topic_retained_bytes bytes",
+		`plivo_topic_payload_size_bytes{topic="orders",quantile="0.5"}`,
+		`plivo_topic_payload_size_bytes_count{topic="orders"} 1`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in metrics:\n%s", line, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("Expected the exposition to end with # EOF, got:\n%s", body)
+	}
+
+	if w := scrape("missing", "test-key"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown topic, got %d", w.Code)
+	}
+	if w := scrape("orders", "wrong-key"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a valid key, got %d", w.Code)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("Expected quotes, backslashes and newlines escaped, got %s", got)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the ID a request is logged under, taken from the
+// request when the caller sets it and echoed on the response
+const RequestIDHeader = "X-Request-ID"
+
+// timeoutGrace is how long past its timeout a request may still write, so
+// a handler whose hub operation was cancelled can report the timeout
+const timeoutGrace = time.Second
+
+// RecoverMiddleware recovers from panics in HTTP handlers, logging the stack
+// trace and counting the panic in hub statistics. Only the offending request
+// fails; the server keeps serving.
+func RecoverMiddleware(hub *pubsub.Hub) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					if rec == http.ErrAbortHandler {
+						panic(rec)
+					}
+					var attrs []any
+					if requestID := w.Header().Get(RequestIDHeader); requestID != "" {
+						attrs = append(attrs, logging.RequestID, requestID)
+					}
+					hub.RecordPanic(r.Method+" "+r.URL.Path, rec, attrs...)
+					writeError(w, pubsub.NewError(pubsub.CodeInternal, "Internal Server Error"))
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestIDMiddleware gives each request an ID, the caller's X-Request-ID
+// or a generated one, echoes it on the response and puts a logger carrying
+// it in the request context, so every record a request logs can be found
+// by its ID
+func RequestIDMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" || len(requestID) > 128 {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			logger := slog.Default().With(logging.RequestID, requestID)
+			logger.Debug("HTTP request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			next.ServeHTTP(w, r.WithContext(logging.WithLogger(r.Context(), logger)))
+		})
+	}
+}
+
+// RouteTimeouts sets how long REST requests may take, by route
+type RouteTimeouts struct {
+	// Default bounds routes not in Routes (0 = unbounded)
+	Default time.Duration
+	// Routes overrides Default by route path template, such as
+	// "/cluster/snapshot"; 0 exempts a route, as streams must be
+	Routes map[string]time.Duration
+}
+
+// timeout returns the timeout for the request's matched route
+func (t RouteTimeouts) timeout(r *http.Request) time.Duration {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			if timeout, exists := t.Routes[template]; exists {
+				return timeout
+			}
+		}
+	}
+	return t.Default
+}
+
+// TimeoutMiddleware bounds each request by its route's timeout. The request
+// context is cancelled when the timeout passes, so hub operations waiting
+// on it give up instead of outliving the request, and the connection's read
+// and write deadlines are moved to match, so a route may run longer than
+// the server-wide HTTP timeouts.
+func TimeoutMiddleware(timeouts RouteTimeouts) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := timeouts.timeout(r)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Not every ResponseWriter supports deadlines; the context
+			// still bounds the handler's work
+			deadline := time.Now().Add(timeout)
+			controller := http.NewResponseController(w)
+			controller.SetReadDeadline(deadline)
+			controller.SetWriteDeadline(deadline.Add(timeoutGrace))
+
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
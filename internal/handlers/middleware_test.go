@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	hub := pubsub.NewHub()
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := RecoverMiddleware(hub)(panicking)
+
+	req := httptest.NewRequest("GET", "/topics", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+
+	if panics := hub.GetStats().Panics; panics != 1 {
+		t.Errorf("Expected 1 recorded panic, got %d", panics)
+	}
+}
+
+func TestRecoverMiddlewarePassThrough(t *testing.T) {
+	hub := pubsub.NewHub()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := RecoverMiddleware(hub)(ok)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("Expected status 418, got %d", w.Code)
+	}
+
+	if panics := hub.GetStats().Panics; panics != 0 {
+		t.Errorf("Expected no recorded panics, got %d", panics)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(TimeoutMiddleware(RouteTimeouts{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"/topics/{topic}/events": 0,
+			"/cluster/snapshot":      time.Minute,
+		},
+	}))
+
+	remaining := make(map[string]time.Duration)
+	record := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if ok {
+			remaining[r.URL.Path] = time.Until(deadline)
+		}
+	}
+	r.HandleFunc("/topics/{topic}", record)
+	r.HandleFunc("/topics/{topic}/events", record)
+	r.HandleFunc("/cluster/snapshot", record)
+
+	for _, path := range []string{"/topics/orders", "/topics/orders/events", "/cluster/snapshot"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if got := remaining["/topics/orders"]; got <= 0 || got > time.Second {
+		t.Errorf("Expected the default timeout, got %v", got)
+	}
+	if got, bounded := remaining["/topics/orders/events"]; bounded {
+		t.Errorf("Expected event streams exempt, got a %v timeout", got)
+	}
+	if got := remaining["/cluster/snapshot"]; got <= time.Second || got > time.Minute {
+		t.Errorf("Expected the export timeout, got %v", got)
+	}
+}
+
+func TestTimeoutMiddlewareCancelsPublish(t *testing.T) {
+	opts := pubsub.DefaultHubOptions()
+	opts.PublishBuffer = 1
+	hub := pubsub.NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+	cfg := config.NewTestConfig()
+	restHandler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	r := mux.NewRouter()
+	r.Use(TimeoutMiddleware(RouteTimeouts{Default: 20 * time.Millisecond}))
+	r.HandleFunc("/topics/{topic}/publish", restHandler.Publish).Methods("POST")
+
+	publish := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "msg-1", "payload": 1}`)))
+		return w
+	}
+
+	// The hub isn't running, so the first publish fills the backlog and
+	// the second gives up when the request times out
+	if w := publish(); w.Code != http.StatusOK && w.Code != http.StatusAccepted {
+		t.Fatalf("Expected the first publish accepted, got %d: %s", w.Code, w.Body)
+	}
+	w := publish()
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), string(pubsub.CodeRequestTimeout)) {
+		t.Errorf("Expected 503 REQUEST_TIMEOUT, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(previous)
+
+	handler := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("handled")
+	}))
+
+	req := httptest.NewRequest("GET", "/topics", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "req-42" {
+		t.Errorf("Expected the caller's request ID echoed, got %q", got)
+	}
+	if !strings.Contains(logs.String(), "request_id=req-42") {
+		t.Errorf("Expected records logged with the request ID, got %q", logs.String())
+	}
+
+	// Requests without an ID get one
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/topics", nil))
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Error("Expected a generated request ID")
+	}
+}
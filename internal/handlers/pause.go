@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"plivo/internal/pubsub"
+)
+
+// PauseStatus reports whether the hub is paused
+type PauseStatus struct {
+	Paused bool `json:"paused"`
+	// Pause is the pause in effect or, when resuming, the pause that ended
+	Pause *pubsub.PauseInfo `json:"pause,omitempty"`
+}
+
+// GetPause reports whether the hub is paused
+// @Summary Get hub pause
+// @Description Report whether the hub is paused for maintenance and, if so, how it treats publishes, why, until when, and how many publishes it is holding back.
+// @Tags system
+// @Produce json
+// @Success 200 {object} PauseStatus "Pause status"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /pause [get]
+func (h *RESTHandler) GetPause(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	pause := h.hub.PauseStatus()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PauseStatus{Paused: pause != nil, Pause: pause})
+}
+
+// Pause pauses the hub for storage maintenance
+// @Summary Pause the hub
+// @Description Pause publishes while storage is compacted or snapshotted. In buffer mode publishes are still accepted but held in their topics' backlogs until the hub resumes, so a full backlog answers 503 HUB_SATURATED; in reject mode they fail with 503 HUB_PAUSED. The mode defaults to -pause-mode. Every client gets a hub_paused info frame, and a hub_resumed one on resume. With timeout_ms the hub resumes by itself; with compact, storage is compacted once the pause takes hold. Pausing a paused hub replaces the pause.
+// @Tags system
+// @Accept json
+// @Produce json
+// @Param pause body pubsub.PauseOptions false "Mode, reason, timeout and whether to compact storage"
+// @Success 200 {object} PauseStatus "Hub paused"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, unknown mode or negative timeout"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Failure 500 {object} pubsub.ErrorData "Storage compaction failed; the hub stays paused"
+// @Security AdminKeyAuth
+// @Router /pause [post]
+func (h *RESTHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	// The body is optional: without one the hub pauses in its default mode
+	var opts pubsub.PauseOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	pause, err := h.hub.Pause(opts)
+	if errors.Is(err, pubsub.ErrInvalidPause) {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+	if err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeInternal, "Storage compaction failed: "+err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PauseStatus{Paused: true, Pause: pause})
+}
+
+// Resume ends a hub pause
+// @Summary Resume the hub
+// @Description End the hub's pause, delivering the publishes it held back, and tell every client with a hub_resumed info frame. Resuming a running hub does nothing.
+// @Tags system
+// @Produce json
+// @Success 200 {object} PauseStatus "Hub running, with the pause that ended"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /pause [delete]
+func (h *RESTHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PauseStatus{Paused: false, Pause: h.hub.Resume()})
+}
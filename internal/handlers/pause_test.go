@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	cfg.Security.AdminKey = "admin"
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	call := func(handle http.HandlerFunc, method, body, key string) (int, PauseStatus) {
+		req := httptest.NewRequest(method, "/pause", strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", key)
+		w := httptest.NewRecorder()
+		handle(w, req)
+		var status PauseStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w.Code, status
+	}
+
+	if code, _ := call(handler.Pause, "POST", "", "test-key"); code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without the admin key, got %d", code)
+	}
+	if code, _ := call(handler.Pause, "POST", `{"mode": "drop"}`, "admin"); code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an unknown mode, got %d", code)
+	}
+
+	code, status := call(handler.Pause, "POST", `{"mode": "reject", "reason": "snapshot"}`, "admin")
+	if code != http.StatusOK || !status.Paused || status.Pause.Mode != pubsub.PauseReject {
+		t.Fatalf("Expected the hub paused in reject mode, got %d %+v", code, status)
+	}
+	if _, status := call(handler.GetPause, "GET", "", "admin"); !status.Paused || status.Pause.Reason != "snapshot" {
+		t.Errorf("Expected GET to report the pause, got %+v", status)
+	}
+
+	// Publishes are refused with a retry hint while paused
+	req := httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "a", "payload": "a"}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	req.Header.Set("X-API-Key", "test-key")
+	w := httptest.NewRecorder()
+	handler.Publish(w, req)
+	var errData pubsub.ErrorData
+	json.Unmarshal(w.Body.Bytes(), &errData)
+	if w.Code != http.StatusServiceUnavailable || errData.Code != pubsub.CodeHubPaused || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 HUB_PAUSED with Retry-After, got %d %+v", w.Code, errData)
+	}
+
+	code, status = call(handler.Resume, "DELETE", "", "admin")
+	if code != http.StatusOK || status.Paused || status.Pause == nil || status.Pause.Mode != pubsub.PauseReject {
+		t.Fatalf("Expected the hub resumed with the ended pause, got %d %+v", code, status)
+	}
+	if _, status := call(handler.GetPause, "GET", "", "admin"); status.Paused {
+		t.Errorf("Expected the hub running, got %+v", status)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"plivo/internal/pubsub"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// rateLimitSweep is how often idle callers' buckets are forgotten
+const rateLimitSweep = time.Minute
+
+// callerBuckets holds a token bucket per REST caller, keyed by remote IP
+type callerBuckets struct {
+	limit pubsub.RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*pubsub.TokenBucket
+	sweptAt time.Time
+}
+
+// allow spends a token from the caller's bucket, creating it full for a
+// new caller
+func (c *callerBuckets) allow(caller string, now time.Time) (bool, time.Duration) {
+	c.mu.Lock()
+	// A bucket that has refilled is the same as a new one, so dropping it
+	// keeps the map to recently active callers without loosening the limit
+	if now.Sub(c.sweptAt) >= rateLimitSweep {
+		for key, bucket := range c.buckets {
+			if bucket.Full(now) {
+				delete(c.buckets, key)
+			}
+		}
+		c.sweptAt = now
+	}
+	bucket, exists := c.buckets[caller]
+	if !exists {
+		bucket = pubsub.NewTokenBucket(c.limit)
+		c.buckets[caller] = bucket
+	}
+	c.mu.Unlock()
+
+	return bucket.Allow(now)
+}
+
+// RateLimiter limits each REST caller, by remote IP, to a rate that may be
+// changed while the broker runs. Requests over the limit get 429
+// RATE_LIMITED with a Retry-After header and are reported on $SYS/quota.
+// Health checks are exempt so load balancers never see a limited broker as
+// down.
+type RateLimiter struct {
+	hub     *pubsub.Hub
+	callers atomic.Pointer[callerBuckets]
+}
+
+// NewRateLimiter returns a rate limiter enforcing limit
+func NewRateLimiter(hub *pubsub.Hub, limit pubsub.RateLimit) *RateLimiter {
+	l := &RateLimiter{hub: hub}
+	l.SetLimit(limit)
+	return l
+}
+
+// SetLimit starts enforcing a new limit. Callers start over with full
+// buckets, since a bucket only makes sense against the limit it was filled
+// for.
+func (l *RateLimiter) SetLimit(limit pubsub.RateLimit) {
+	l.callers.Store(&callerBuckets{limit: limit, buckets: make(map[string]*pubsub.TokenBucket), sweptAt: time.Now()})
+}
+
+// Middleware returns the middleware enforcing the current limit
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callers := l.callers.Load()
+		if !callers.limit.Enabled() || r.URL.Path == "/health" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, retryAfter := callers.allow(remoteIP(r), time.Now())
+		if !allowed {
+			limit := callers.limit
+			l.hub.ReportQuota(pubsub.QuotaEvent{
+				Quota:    pubsub.QuotaRequestRate,
+				Identity: pubsub.RESTIdentity(r.RemoteAddr),
+				Limit:    int64(limit.PerMinute),
+			})
+			errorData := pubsub.NewError(pubsub.CodeRateLimited, "Rate limit of "+strconv.Itoa(limit.PerMinute)+" requests per minute exceeded")
+			errorData.Limit = int64(limit.PerMinute)
+			errorData.RetryAfterMs = max(retryAfter.Milliseconds(), 1)
+			// Retry-After is whole seconds, rounded up
+			w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+			writeError(w, errorData)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimitMiddleware limits each REST caller, by remote IP, to a fixed
+// rate, as a RateLimiter does
+func RateLimitMiddleware(hub *pubsub.Hub, limit pubsub.RateLimit) mux.MiddlewareFunc {
+	return NewRateLimiter(hub, limit).Middleware
+}
+
+// remoteIP returns the host part of the request's remote address, so all
+// of a caller's connections share one limit
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
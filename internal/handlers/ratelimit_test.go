@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/pubsub"
+	"testing"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	hub := pubsub.NewHub()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := RateLimitMiddleware(hub, pubsub.RateLimit{PerMinute: 60, Burst: 2})(ok)
+
+	request := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Connections from one address share its limit
+	for _, remoteAddr := range []string{"10.0.0.7:1000", "10.0.0.7:1001"} {
+		if w := request("/topics", remoteAddr); w.Code != http.StatusOK {
+			t.Fatalf("Expected the burst allowed, got %d", w.Code)
+		}
+	}
+
+	w := request("/topics", "10.0.0.7:1002")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 over the limit, got %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", retryAfter)
+	}
+	var errorBody pubsub.ErrorData
+	if err := json.Unmarshal(w.Body.Bytes(), &errorBody); err != nil {
+		t.Fatalf("Failed to unmarshal error body: %v", err)
+	}
+	if errorBody.Code != pubsub.CodeRateLimited || errorBody.Limit != 60 {
+		t.Errorf("Expected RATE_LIMITED with the limit, got %+v", errorBody)
+	}
+
+	// Other callers and health checks are unaffected
+	if w := request("/topics", "10.0.0.8:1000"); w.Code != http.StatusOK {
+		t.Errorf("Expected another caller allowed, got %d", w.Code)
+	}
+	if w := request("/health", "10.0.0.7:1003"); w.Code != http.StatusOK {
+		t.Errorf("Expected health checks exempt, got %d", w.Code)
+	}
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := RateLimitMiddleware(pubsub.NewHub(), pubsub.RateLimit{})(ok)
+
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/topics", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected no limit, got %d on request %d", w.Code, i+1)
+		}
+	}
+}
+
+func TestRateLimiterSetLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limiter := NewRateLimiter(pubsub.NewHub(), pubsub.RateLimit{})
+	handler := limiter.Middleware(ok)
+
+	request := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/topics", nil))
+		return w.Code
+	}
+
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("Expected no limit at first, got %d", code)
+	}
+
+	limiter.SetLimit(pubsub.RateLimit{PerMinute: 60, Burst: 1})
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("Expected the new burst allowed, got %d", code)
+	}
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 under the new limit, got %d", code)
+	}
+
+	limiter.SetLimit(pubsub.RateLimit{})
+	if code := request(); code != http.StatusOK {
+		t.Errorf("Expected no limit once it is lifted, got %d", code)
+	}
+}
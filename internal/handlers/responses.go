@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+	"time"
+)
+
+// Health statuses reported by verbose health checks
+const (
+	HealthHealthy  = "healthy"
+	HealthDegraded = "degraded"
+)
+
+// Readiness statuses reported by GET /readyz
+const (
+	ReadyOK       = "ready"
+	ReadyDegraded = "degraded"
+)
+
+// ReadyResponse is the body of GET /readyz
+type ReadyResponse struct {
+	// Status is ReadyOK or, while storage is failing, ReadyDegraded
+	Status  string               `json:"status"`
+	Storage pubsub.StorageHealth `json:"storage"`
+}
+
+// TopicSummary is one topic in the ListTopics response
+type TopicSummary struct {
+	Name        string `json:"name"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// ListTopicsResponse is the body of GET /topics
+type ListTopicsResponse struct {
+	Topics []TopicSummary `json:"topics"`
+}
+
+// HealthResponse is the body of GET /health
+type HealthResponse struct {
+	UptimeSec   int `json:"uptime_sec"`
+	Topics      int `json:"topics"`
+	Subscribers int `json:"subscribers"`
+	// Paused is set while the hub is paused for maintenance
+	Paused bool `json:"paused,omitempty"`
+	// Status is HealthHealthy or, when pumps outlive their clients,
+	// HealthDegraded; set with Runtime on verbose checks only
+	Status  string               `json:"status,omitempty"`
+	Runtime *pubsub.RuntimeStats `json:"runtime,omitempty"`
+}
+
+// TopicMetrics is one topic's entry in the Stats response
+type TopicMetrics struct {
+	Messages        int64                   `json:"messages"`
+	Subscribers     int                     `json:"subscribers"`
+	PayloadSize     pubsub.PayloadSizeStats `json:"payload_size"`
+	Dropped         int64                   `json:"dropped"`
+	LastPublishAt   *time.Time              `json:"last_publish_at"`
+	BufferOccupancy int                     `json:"buffer_occupancy"`
+	BufferCapacity  int                     `json:"buffer_capacity"`
+	RetainedBytes   int64                   `json:"retained_bytes"`
+	Retention       pubsub.RetentionPolicy  `json:"retention"`
+}
+
+// OrderingStats reports the ordering audit in the Stats response
+type OrderingStats struct {
+	Audit      bool  `json:"audit"`
+	Violations int64 `json:"violations"`
+}
+
+// StatsResponse is the body of GET /stats
+type StatsResponse struct {
+	Topics map[string]TopicMetrics `json:"topics"`
+	Panics int64                   `json:"panics"`
+	// Channels are the hub's internal channel backlogs by name
+	Channels map[string]pubsub.ChannelStats `json:"channels"`
+	// Errors counts error frames sent to clients by code
+	Errors map[pubsub.ErrorCode]int64 `json:"errors"`
+	// Retention is the memory retained messages hold, null when the store
+	// doesn't track it
+	Retention *pubsub.RetentionUsage `json:"retention"`
+	// Expired counts retained messages dropped for outliving their TTL or
+	// their topic's max age
+	Expired  int64         `json:"expired"`
+	Ordering OrderingStats `json:"ordering"`
+	// LeaseExpiries counts leased subscriptions expired for want of renewal
+	LeaseExpiries int64 `json:"lease_expiries"`
+	// RTT summarizes connected WebSocket clients' round-trip times
+	RTT pubsub.RTTSummary `json:"rtt"`
+	// Tenant is the calling tenant's usage, unset for other callers
+	Tenant *pubsub.TenantUsage `json:"tenant,omitempty"`
+}
+
+// ACLResponse is the body of GET, PUT and DELETE /acl
+type ACLResponse struct {
+	// Enabled is set while an ACL is enforced
+	Enabled bool      `json:"enabled"`
+	ACL     *auth.ACL `json:"acl,omitempty"`
+}
@@ -1,66 +1,161 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"plivo/internal/auth"
 	"plivo/internal/config"
 	"plivo/internal/pubsub"
+	"plivo/internal/version"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// maxSchemaSize limits the size of schema documents accepted by PutTopicSchema,
+// and of topic creation and update requests, which may carry one
+const maxSchemaSize = 1024 * 1024
+
+// Page sizes for paginated message history
+const (
+	defaultMessagePageSize = 100
+	maxMessagePageSize     = 1000
+)
+
+// publishBodyOverhead is the allowance for the JSON envelope around a payload
+// when bounding REST publish bodies
+const publishBodyOverhead = 64 * 1024
+
 // RESTHandler handles REST API endpoints
 type RESTHandler struct {
-	hub *pubsub.Hub
-	cfg *config.Config
+	hub  *pubsub.Hub
+	cfg  *config.Config
+	auth *auth.Service
+	// activity records admin operations for GetActivity
+	activity *ActivityLog
+	// running is the configuration GetConfig reports, cfg with any reloaded
+	// settings
+	running atomic.Pointer[config.Config]
 }
 
-// NewRESTHandler creates a new REST handler
-func NewRESTHandler(hub *pubsub.Hub, cfg *config.Config) *RESTHandler {
-	return &RESTHandler{
-		hub: hub,
-		cfg: cfg,
+// NewRESTHandler creates a new REST handler. It panics if any dependency is
+// missing, so a miswired server fails at startup rather than per request.
+func NewRESTHandler(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) *RESTHandler {
+	if hub == nil || cfg == nil || authService == nil {
+		panic("handlers: NewRESTHandler requires a hub, config and auth service")
 	}
+	h := &RESTHandler{
+		hub:      hub,
+		cfg:      cfg,
+		auth:     authService,
+		activity: NewActivityLog(),
+	}
+	h.running.Store(cfg)
+	return h
 }
 
 // CreateTopicRequest represents the request body for creating a topic
 type CreateTopicRequest struct {
 	Name string `json:"name"`
+	// Replay overrides the server-wide last_n default and cap for this topic
+	Replay *pubsub.ReplayLimits `json:"replay,omitempty"`
+	// Weight is the topic's share of fan-out relative to other busy topics
+	Weight int `json:"weight,omitempty"`
+	// KeyID marks the topic encrypted: it only takes ciphertext, and
+	// subscribers must present this key ID
+	KeyID string `json:"key_id,omitempty"`
+	// Enrich stamps published messages with server metadata headers
+	Enrich bool `json:"enrich,omitempty"`
+	// Retention bounds how many messages the topic retains for replay and
+	// for how long
+	Retention *pubsub.RetentionPolicy `json:"retention,omitempty"`
+	// Labels are free-form key/value pairs for operators' bookkeeping
+	Labels map[string]string `json:"labels,omitempty"`
+	// DeadLetter names the topic events subscribers lose are published to,
+	// conventionally <topic>.dlq
+	DeadLetter string `json:"dead_letter,omitempty"`
+	// Partitioning splits the topic into partitions by message key, shared
+	// among consumer group members
+	Partitioning *pubsub.Partitioning `json:"partitioning,omitempty"`
+	// Shadow copies a percentage of publishes into a shadow topic, for
+	// trying new consumers against production traffic
+	Shadow *pubsub.Shadow `json:"shadow,omitempty"`
+	// SampleEvery keeps one in every SampleEvery publishes for admins to
+	// inspect (0 = none)
+	SampleEvery int `json:"sample_every,omitempty"`
+	// DedupWindowMs drops publishes whose message ID repeats one published
+	// to the topic within that many milliseconds, up to 24 hours (0 = none)
+	DedupWindowMs int64 `json:"dedup_window_ms,omitempty"`
+	// Schema is a JSON Schema document registered as the topic's schema
+	// version 1; publishes whose payload doesn't validate are rejected
+	Schema json.RawMessage `json:"schema,omitempty" swaggertype:"object"`
 }
 
 // CreateTopic creates a new topic
 // @Summary Create a new topic
-// @Description Create a new pub/sub topic for message publishing and subscription
+// @Description Create a new pub/sub topic for message publishing and subscription. Topics created with a tenant's API key are owned by that tenant: only it or an admin may delete, drain or reconfigure them. Topics created with a key_id are encrypted: they only accept application/octet-stream ciphertext, and subscribers must present the key ID. Topics created with enrich stamp every published message with _meta.* headers naming the accepting node, receive time and publisher. A retention policy sets how many messages the topic retains for replay (max_messages, up to 10000) and expires them max_age_ms after publishing. Topics with a dead_letter topic, created if it doesn't exist, publish every event a subscriber loses to a full queue, its max_latency or its TTL there, with _dlq.* headers saying why. Partitioned topics route each message to a partition by its key and share the partitions among each consumer group's members, range or round-robin, rebalancing as members join and leave. Topics with a shadow copy that percentage of their publishes, sampled at random, into the shadow topic, created if it doesn't exist, with _shadow.* headers naming the original topic and sequence. Topics with sample_every keep one in that many publishes, the newest 100, for admins to inspect at GET /topics/{topic}/samples. Topics with dedup_window_ms remember the IDs of published messages for that long, and drop a publish repeating one, acknowledging it with status duplicate, so publishers can retry safely. Topics created with a schema, a JSON Schema document, register it as schema version 1 and reject publishes whose JSON payload doesn't validate against their latest schema with SCHEMA_VIOLATION.
 // @Tags topics
 // @Accept json
 // @Produce json
 // @Param request body CreateTopicRequest true "Topic creation request"
 // @Success 201 {object} map[string]string "Topic created successfully"
-// @Failure 400 {string} string "Bad request - invalid JSON or missing topic name"
-// @Failure 401 {string} string "Unauthorized - invalid or missing API key"
-// @Failure 409 {string} string "Conflict - topic already exists"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, missing or reserved topic name, invalid replay limits, weight, key ID, retention policy, dead-letter topic, partitioning, shadow, sample_every, dedup_window_ms or JSON Schema"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL on the topic or its shadow topic"
+// @Failure 409 {object} pubsub.ErrorData "Conflict - topic already exists, or was deleted and can still be restored"
 // @Security ApiKeyAuth
 // @Router /topics [post]
 func (h *RESTHandler) CreateTopic(w http.ResponseWriter, r *http.Request) {
 	// Check authentication
-	if !h.authenticateRequest(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	tenant, ok := h.authenticateTenant(r)
+	if !ok {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
 		return
 	}
 
 	var req CreateTopicRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSchemaSize)).Decode(&req); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
 		return
 	}
 
 	if req.Name == "" {
-		http.Error(w, "Topic name is required", http.StatusBadRequest)
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Topic name is required"))
+		return
+	}
+
+	if !h.authorizeTopic(w, r, auth.PermAdmin, req.Name) {
+		return
+	}
+	// Shadowing publishes into the shadow topic on the caller's behalf
+	if req.Shadow != nil && !h.authorizeTopic(w, r, auth.PermPublish, req.Shadow.Topic) {
 		return
 	}
 
-	if err := h.hub.CreateTopic(req.Name); err != nil {
-		http.Error(w, err.Error(), http.StatusConflict)
+	if err := h.hub.CreateTopicWithOptions(req.Name, pubsub.TopicOptions{
+		Replay:        req.Replay,
+		Weight:        req.Weight,
+		KeyID:         req.KeyID,
+		Enrich:        req.Enrich,
+		Owner:         tenant,
+		Retention:     req.Retention,
+		Labels:        req.Labels,
+		DeadLetter:    req.DeadLetter,
+		Partitioning:  req.Partitioning,
+		Shadow:        req.Shadow,
+		SampleEvery:   req.SampleEvery,
+		DedupWindowMs: req.DedupWindowMs,
+		Schema:        req.Schema,
+	}); err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
 		return
 	}
 
@@ -74,130 +169,1268 @@ func (h *RESTHandler) CreateTopic(w http.ResponseWriter, r *http.Request) {
 
 // ListTopics returns all topics
 // @Summary List all topics
-// @Description Get a list of all available topics with their subscriber counts
+// @Description Get a list of all available topics with their subscriber counts. With -tenant-namespaces, tenants only see the topics in their namespace.
 // @Tags topics
 // @Produce json
-// @Success 200 {object} map[string]interface{} "List of topics"
-// @Failure 401 {string} string "Unauthorized - invalid or missing API key"
+// @Success 200 {object} ListTopicsResponse "List of topics"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
 // @Security ApiKeyAuth
 // @Router /topics [get]
 func (h *RESTHandler) ListTopics(w http.ResponseWriter, r *http.Request) {
 	// Check authentication
 	if !h.authenticateRequest(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
 		return
 	}
 
 	topics := h.hub.GetTopics()
+	tenant, scoped := h.scopedTenant(r)
 
-	// Convert to the required format
-	topicList := make([]map[string]interface{}, 0, len(topics))
+	response := ListTopicsResponse{Topics: make([]TopicSummary, 0, len(topics))}
 	for _, topic := range topics {
-		topicList = append(topicList, map[string]interface{}{
-			"name":        topic.Name,
-			"subscribers": topic.SubscriberCount,
+		if scoped && !auth.InNamespace(tenant, topic.Name) {
+			continue
+		}
+		response.Topics = append(response.Topics, TopicSummary{
+			Name:        topic.Name,
+			Subscribers: topic.SubscriberCount,
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"topics": topicList,
-	})
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTopic returns detailed statistics for a single topic
+// @Summary Get topic details
+// @Description Get statistics for a single topic including dropped deliveries, last publish time and replay buffer occupancy
+// @Tags topics
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Success 200 {object} pubsub.TopicStats "Topic statistics"
+// @Header 200 {string} ETag "The topic's settings revision, for If-Match on PATCH"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic} [get]
+func (h *RESTHandler) GetTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	stats, err := h.hub.GetTopicStats(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", revisionETag(stats.Revision))
+	json.NewEncoder(w).Encode(stats)
+}
+
+// UpdateTopic changes an existing topic's settings
+// @Summary Update topic settings
+// @Description Change an existing topic's replay limits, retention policy, weight, enrichment, labels, dead-letter topic, shadow, sampling rate, dedup window or schema without deleting it, so its retained messages, subscribers and consumer groups are kept. Only the fields present in the body change; labels replace the topic's labels and an empty object clears them, and a schema registers a new schema version. Owned topics may only be updated by their owner or an admin. Send the ETag from GET /topics/{topic} as If-Match to apply the update only if nobody changed the topic since; the response carries the new ETag.
+// @Tags topics
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param If-Match header string false "Revision the update was made against, as returned in ETag"
+// @Param request body pubsub.TopicUpdate true "Settings to change"
+// @Success 200 {object} pubsub.TopicStats "Updated topic"
+// @Header 200 {string} ETag "The topic's new settings revision"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, If-Match, replay limits, retention policy, weight, labels, dead-letter topic, shadow, sample_every, dedup_window_ms or JSON Schema"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL on it or its shadow topic"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Failure 412 {object} pubsub.ErrorData "Precondition failed - the topic changed since the If-Match revision"
+// @Security ApiKeyAuth
+// @Router /topics/{topic} [patch]
+func (h *RESTHandler) UpdateTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	revision, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid If-Match: expected a topic revision ETag"))
+		return
+	}
+
+	var update pubsub.TopicUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSchemaSize)).Decode(&update); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+	if update.Shadow != nil && update.Shadow.Topic != "" && !h.authorizeTopic(w, r, auth.PermPublish, update.Shadow.Topic) {
+		return
+	}
+
+	stats, err := h.hub.UpdateTopic(topicName, update, revision)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", revisionETag(stats.Revision))
+	json.NewEncoder(w).Encode(stats)
+}
+
+// revisionETag formats a topic revision as a strong ETag
+func revisionETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// parseIfMatch reads the topic revision from an If-Match header. An absent
+// header and "*" match any revision, returned as 0.
+func parseIfMatch(header string) (int64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	revision, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, false
+	}
+	return revision, true
 }
 
 // DeleteTopic deletes a topic
 // @Summary Delete a topic
-// @Description Delete a topic and disconnect all its subscribers
+// @Description Delete a topic and disconnect all its subscribers. Owned topics may only be deleted by their owner or an admin. Within the server's trash window the topic can be brought back with POST /topics/{topic}/restore, and publishes, subscribes and re-creation fail with TOPIC_DELETED; the response's purge_at says until when. With purge=true the topic, live or deleted, is removed for good at once.
 // @Tags topics
 // @Produce json
 // @Param topic path string true "Topic name"
-// @Success 200 {object} map[string]string "Topic deleted successfully"
-// @Failure 401 {string} string "Unauthorized - invalid or missing API key"
-// @Failure 404 {string} string "Not found - topic does not exist"
+// @Param purge query bool false "Delete for good, skipping the trash window"
+// @Success 200 {object} map[string]interface{} "Topic deleted successfully"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
 // @Security ApiKeyAuth
 // @Router /topics/{topic} [delete]
 func (h *RESTHandler) DeleteTopic(w http.ResponseWriter, r *http.Request) {
 	// Check authentication
 	if !h.authenticateRequest(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
 		return
 	}
 
 	vars := mux.Vars(r)
 	topicName := vars["topic"]
 
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		if err := h.hub.PurgeTopic(topicName); err != nil {
+			writeError(w, pubsub.ErrorFrom(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status": "purged",
+			"topic":  topicName,
+		})
+		return
+	}
+
 	if err := h.hub.DeleteTopic(topicName); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	response := map[string]interface{}{
+		"status": "deleted",
+		"topic":  topicName,
+	}
+	if deleted, restorable := h.hub.DeletedTopicInfo(topicName); restorable {
+		response["purge_at"] = deleted.PurgeAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RestoreTopic brings back a deleted topic
+// @Summary Restore a deleted topic
+// @Description Bring back a topic deleted within the server's trash window, with its sequence, retained messages, schemas, consumer group offsets and owner. Subscribers detached by the delete must subscribe again. Owned topics may only be restored by their owner or an admin.
+// @Tags topics
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Success 200 {object} map[string]string "Topic restored"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - no deleted topic awaiting purge"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/restore [post]
+func (h *RESTHandler) RestoreTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+
+	if err := h.hub.RestoreTopic(topicName); err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"status": "deleted",
+		"status": "restored",
 		"topic":  topicName,
 	})
 }
 
+// DrainTopic stops new subscriptions to a topic
+// @Summary Drain a topic
+// @Description Stop new subscriptions to a topic, for renames and splits. Subscribers get a topic_draining info frame naming the optional replacement topic; with migrate they are moved to the replacement and get topic_migrated instead. New subscribes fail with TOPIC_DRAINING. Publishes are still accepted.
+// @Tags topics
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param drain body pubsub.DrainOptions false "Replacement topic and whether to migrate subscribers"
+// @Success 200 {object} pubsub.DrainResult "Topic draining"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, reserved topic, or missing, draining or same replacement"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/drain [post]
+func (h *RESTHandler) DrainTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+
+	// The body is optional: without one the topic drains with no replacement
+	var opts pubsub.DrainOptions
+	if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && err != io.EOF {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	result, err := h.hub.DrainTopic(topicName, opts)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ReplayTopic re-publishes a topic's retained messages into another topic
+// @Summary Replay messages into another topic
+// @Description Re-publish a topic's retained messages within a sequence and/or receive time range into a target topic, oldest first, for reprocessing after consumer bugs. Messages are selected when the request is made and published in the background at rate messages per second (default: the server's replay rate), with new sequences and _replay.topic and _replay.sequence headers naming their origin. Only the target's owner or an admin may replay into an owned topic. Encrypted topics can only be replayed into topics with the same key ID.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param topic path string true "Source topic name"
+// @Param request body pubsub.ReplayIntoRequest true "Target topic, range and rate"
+// @Success 202 {object} pubsub.ReplayIntoResult "Replay started"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, missing, reserved or same target topic, or invalid range or rate"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - target topic is owned by another tenant or encrypted with a different key, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - source or target topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/replay [post]
+func (h *RESTHandler) ReplayTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	var req pubsub.ReplayIntoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+	if req.Rate == 0 {
+		req.Rate = h.cfg.PubSub.ReplayRate
+	}
+
+	// Replaying writes to the target, so it needs the target's owner
+	if !h.authorizeOwner(w, r, req.TargetTopic) {
+		return
+	}
+
+	result, err := h.hub.ReplayInto(topicName, req, pubsub.RESTIdentity(r.RemoteAddr))
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
+// TransferTopicRequest names a topic's new owner
+type TransferTopicRequest struct {
+	// Owner is the tenant taking over the topic
+	Owner string `json:"owner"`
+}
+
+// TransferTopic hands a topic to another tenant
+// @Summary Transfer topic ownership
+// @Description Hand a topic to another tenant, which may then delete, drain and reconfigure it. Only the current owner or an admin may transfer a topic; unowned topics may be claimed by any caller. The new owner must be a configured tenant.
+// @Tags topics
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param request body TransferTopicRequest true "New owner"
+// @Success 200 {object} map[string]string "Topic transferred"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON or unknown tenant"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/transfer [post]
+func (h *RESTHandler) TransferTopic(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	var req TransferTopicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+	if !h.auth.IsTenant(req.Owner) {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Unknown tenant: "+req.Owner))
+		return
+	}
+
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+
+	previous, err := h.hub.TransferTopic(topicName, req.Owner)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":         "transferred",
+		"topic":          topicName,
+		"owner":          req.Owner,
+		"previous_owner": previous,
+	})
+}
+
+// Publish publishes a message to a topic
+// @Summary Publish a message
+// @Description Publish a message to a topic without a WebSocket connection. Responds 200 when the hub is keeping up, 202 with the queue position when the topic's publish backlog exceeds the queued threshold, and 503 with Retry-After when the backlog exceeds the reject threshold. Backlogs are per topic, so a burst on one topic does not slow publishes to others. A message with ttl_ms is neither replayed nor delivered once it expires. On topics with a dedup_window_ms, a message whose ID the topic already published within the window is dropped and answered 200 with status duplicate.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param message body pubsub.MessageData true "Message to publish"
+// @Success 200 {object} map[string]interface{} "Message published, or dropped as a duplicate"
+// @Success 202 {object} map[string]interface{} "Message queued behind a hub backlog"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, invalid message ID, TTL, headers or content type, plaintext on an encrypted topic, a payload violating the topic schema (SCHEMA_VIOLATION), or reserved topic"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Failure 409 {object} pubsub.ErrorData "Conflict - topic was deleted and can still be restored"
+// @Failure 413 {object} pubsub.ErrorData "Payload exceeds the maximum message size"
+// @Failure 503 {object} pubsub.ErrorData "Hub saturated or paused - retry after the Retry-After interval, or the request timed out waiting for the hub"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/publish [post]
+func (h *RESTHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	receivedAt := h.hub.Now()
+	topicName := mux.Vars(r)["topic"]
+	limit := h.cfg.PubSub.MaxMessageSize
+
+	if !h.authorizeTopic(w, r, auth.PermPublish, topicName) {
+		return
+	}
+
+	if pubsub.IsSystemTopic(topicName) {
+		writeError(w, pubsub.ErrorFrom(pubsub.ErrReservedTopic))
+		return
+	}
+
+	if !h.hub.TopicExists(topicName) {
+		if _, deleted := h.hub.DeletedTopicInfo(topicName); deleted {
+			writeError(w, pubsub.ErrorFrom(pubsub.ErrTopicDeleted))
+			return
+		}
+		writeError(w, pubsub.ErrorFrom(pubsub.ErrTopicNotFound))
+		return
+	}
+
+	bodyLimit := limit
+	if limit > 0 {
+		bodyLimit = limit + publishBodyOverhead
+	}
+	var message pubsub.MessageData
+	if !h.decodePublishBody(w, r, &message, bodyLimit) {
+		return
+	}
+
+	receipt, err := h.hub.PublishDirect(r.Context(), topicName, &message, h.publishOptions(r, receivedAt)...)
+	if errors.Is(err, pubsub.ErrHubSaturated) || errors.Is(err, pubsub.ErrHubPaused) {
+		h.writeUnavailable(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":    "published",
+		"topic":     topicName,
+		"id":        receipt.ID,
+		"timestamp": receipt.Timestamp,
+	}
+
+	status := http.StatusOK
+	if receipt.Duplicate {
+		response["status"] = pubsub.PublishDuplicate
+	} else if receipt.Ahead >= h.cfg.PubSub.PublishQueuedDepth {
+		status = http.StatusAccepted
+		response["status"] = "queued"
+		response["queue_position"] = receipt.Ahead
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// PublishBatch publishes several messages to a topic at once
+// @Summary Publish a batch of messages
+// @Description Publish up to 100 messages to a topic in one request. The batch is atomic: either every message is queued for fan-out, one after another with no other publish to the topic between them, or none is. The response lists each message's ID and status in order; when any message is invalid the batch is rejected with the first invalid message's error, and its results say which messages were rejected and which were aborted with them. Messages repeating an ID the topic published within its dedup window, or earlier in the batch, are dropped with status duplicate. Responds 202 and 503 as /topics/{topic}/publish does, for the batch as a whole.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param messages body []pubsub.MessageData true "Messages to publish"
+// @Success 200 {object} BatchPublishResponse "Messages published"
+// @Success 202 {object} BatchPublishResponse "Messages queued behind a hub backlog"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, an empty or oversized batch, an invalid message or one violating the topic schema, or reserved topic"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Failure 409 {object} pubsub.ErrorData "Conflict - topic was deleted and can still be restored"
+// @Failure 413 {object} pubsub.ErrorData "A payload exceeds the maximum message size"
+// @Failure 503 {object} pubsub.ErrorData "Hub saturated or paused - retry after the Retry-After interval, or the request timed out waiting for the hub"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/publish/batch [post]
+func (h *RESTHandler) PublishBatch(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	receivedAt := h.hub.Now()
+	topicName := mux.Vars(r)["topic"]
+	limit := h.cfg.PubSub.MaxMessageSize
+
+	if !h.authorizeTopic(w, r, auth.PermPublish, topicName) {
+		return
+	}
+
+	bodyLimit := limit
+	if limit > 0 {
+		bodyLimit = (limit + publishBodyOverhead) * pubsub.MaxBatchSize
+	}
+	var messages []*pubsub.MessageData
+	if !h.decodePublishBody(w, r, &messages, bodyLimit) {
+		return
+	}
+
+	receipt, err := h.hub.PublishBatch(r.Context(), topicName, messages, h.publishOptions(r, receivedAt)...)
+	if errors.Is(err, pubsub.ErrHubSaturated) || errors.Is(err, pubsub.ErrHubPaused) {
+		h.writeUnavailable(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	response := BatchPublishResponse{
+		Status:    "published",
+		Topic:     topicName,
+		Count:     len(receipt.Results),
+		Timestamp: receipt.Timestamp,
+		Results:   receipt.Results,
+	}
+	status := http.StatusOK
+	if receipt.Ahead >= h.cfg.PubSub.PublishQueuedDepth {
+		status = http.StatusAccepted
+		response.Status = "queued"
+		response.QueuePosition = receipt.Ahead
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// BatchPublishResponse is the response to an accepted publish batch
+type BatchPublishResponse struct {
+	// Status is published, or queued behind a hub backlog
+	Status    string    `json:"status"`
+	Topic     string    `json:"topic"`
+	Count     int       `json:"count"`
+	Timestamp time.Time `json:"timestamp"`
+	// QueuePosition is how many of the topic's publishes were queued ahead
+	// of the batch, set when queued
+	QueuePosition int `json:"queue_position,omitempty"`
+	// Results has each message's ID and status, in order
+	Results []pubsub.BatchResult `json:"results"`
+}
+
+// decodePublishBody decodes a publish request body of at most limit bytes
+// (0 = unlimited) into v. Otherwise it responds 413 or 400 and returns
+// false.
+func (h *RESTHandler) decodePublishBody(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) bool {
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			tooLarge := pubsub.NewError(pubsub.CodeMessageTooLarge, "request body exceeds limit")
+			tooLarge.Limit = h.cfg.PubSub.MaxMessageSize
+			writeError(w, tooLarge)
+			return false
+		}
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return false
+	}
+	return true
+}
+
+// publishOptions returns the options REST publishes are built with
+func (h *RESTHandler) publishOptions(r *http.Request, receivedAt time.Time) []pubsub.MessageOption {
+	opts := []pubsub.MessageOption{
+		pubsub.WithMaxSize(h.cfg.PubSub.MaxMessageSize),
+		pubsub.WithPublisher(pubsub.RESTIdentity(r.RemoteAddr)),
+		pubsub.WithTimestamp(receivedAt),
+	}
+	if h.cfg.PubSub.GenerateMessageIDs {
+		opts = append(opts, pubsub.WithGeneratedID())
+	}
+	return opts
+}
+
+// TopicMessages is a topic's replay window as returned over REST
+type TopicMessages struct {
+	Topic string `json:"topic"`
+	// Count is the number of messages returned
+	Count    int                     `json:"count"`
+	Messages []*pubsub.PubSubMessage `json:"messages"`
+	// NextCursor fetches the next page, set on paginated responses while
+	// more retained messages follow
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// messageCursor is the position a paginated message history response left
+// off at: the topic and the last sequence returned
+type messageCursor struct {
+	topic    string
+	sequence int64
+}
+
+// encode returns the opaque cursor token
+func (c messageCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.sequence, 10) + ":" + c.topic))
+}
+
+// decodeMessageCursor parses a cursor token for the topic
+func decodeMessageCursor(token, topic string) (messageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return messageCursor{}, errors.New("invalid cursor")
+	}
+	seq, name, ok := strings.Cut(string(data), ":")
+	sequence, err := strconv.ParseInt(seq, 10, 64)
+	if !ok || err != nil || sequence < 0 {
+		return messageCursor{}, errors.New("invalid cursor")
+	}
+	if name != topic {
+		return messageCursor{}, errors.New("cursor belongs to another topic")
+	}
+	return messageCursor{topic: name, sequence: sequence}, nil
+}
+
+// GetTopicMessages returns a topic's retained messages
+// @Summary Get message history
+// @Description Get a topic's retained messages, oldest first: the same replay window subscribers get with last_n. Use last_n to return only the newest messages and since to skip messages received before a time. To page through the whole retention window instead, pass since_seq and/or limit, then the next_cursor of each response as cursor until a response has none. Messages past their ttl_ms or the topic's retention max_age_ms are left out. Encrypted topics require the topic's key_id.
+// @Tags messages
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param last_n query int false "Newest messages to return (default: all retained)"
+// @Param since query string false "Only messages received at or after this time (RFC3339)"
+// @Param since_seq query int false "Paginate from this sequence on, inclusive (default: the oldest retained)"
+// @Param limit query int false "Paginate with at most this many messages per page (default 100, max 1000)"
+// @Param cursor query string false "Continue pagination from a previous response's next_cursor"
+// @Param key_id query string false "Key ID of an encrypted topic"
+// @Success 200 {object} TopicMessages "Retained messages"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid last_n, since, since_seq, limit or cursor, or last_n combined with pagination"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - key_id does not match the encrypted topic, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/messages [get]
+func (h *RESTHandler) GetTopicMessages(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+	query := r.URL.Query()
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	lastN := 0
+	if v := query.Get("last_n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid last_n"))
+			return
+		}
+		lastN = parsed
+	}
+
+	var since time.Time
+	if v := query.Get("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid since, expected an RFC3339 timestamp"))
+			return
+		}
+		since = parsed
+	}
+
+	page, err := parseMessagePage(query, topicName)
+	if err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, err.Error()))
+		return
+	}
+	if page != nil && lastN > 0 {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "last_n cannot be combined with since_seq, limit or cursor"))
+		return
+	}
+
+	stats, err := h.hub.GetTopicStats(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+	if stats.KeyID != "" && query.Get("key_id") != stats.KeyID {
+		writeError(w, pubsub.ErrorFrom(pubsub.ErrKeyIDMismatch))
+		return
+	}
+
+	var messages []*pubsub.PubSubMessage
+	if page != nil {
+		messages = h.hub.GetMessagesAfter(topicName, page.after)
+	} else {
+		messages = h.hub.GetRecentMessages(topicName, lastN)
+	}
+	if !since.IsZero() {
+		filtered := make([]*pubsub.PubSubMessage, 0, len(messages))
+		for _, message := range messages {
+			if !message.Timestamp.Before(since) {
+				filtered = append(filtered, message)
+			}
+		}
+		messages = filtered
+	}
+
+	response := TopicMessages{Topic: topicName}
+	if page != nil && len(messages) > page.limit {
+		messages = messages[:page.limit]
+		response.NextCursor = messageCursor{topic: topicName, sequence: messages[len(messages)-1].Sequence}.encode()
+	}
+	response.Count = len(messages)
+	response.Messages = messages
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// messagePage is a paginated message history request: up to limit
+// messages with a sequence greater than after
+type messagePage struct {
+	after int64
+	limit int
+}
+
+// parseMessagePage parses the since_seq, limit and cursor query parameters,
+// returning nil when none is set
+func parseMessagePage(query url.Values, topic string) (*messagePage, error) {
+	sinceSeq, limit, cursor := query.Get("since_seq"), query.Get("limit"), query.Get("cursor")
+	if sinceSeq == "" && limit == "" && cursor == "" {
+		return nil, nil
+	}
+	if sinceSeq != "" && cursor != "" {
+		return nil, errors.New("since_seq cannot be combined with cursor")
+	}
+
+	page := &messagePage{limit: defaultMessagePageSize}
+	if limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 || parsed > maxMessagePageSize {
+			return nil, fmt.Errorf("invalid limit, expected 1 to %d", maxMessagePageSize)
+		}
+		page.limit = parsed
+	}
+	if sinceSeq != "" {
+		parsed, err := strconv.ParseInt(sinceSeq, 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, errors.New("invalid since_seq")
+		}
+		page.after = max(parsed-1, 0)
+	}
+	if cursor != "" {
+		position, err := decodeMessageCursor(cursor, topic)
+		if err != nil {
+			return nil, err
+		}
+		page.after = position.sequence
+	}
+	return page, nil
+}
+
+// writeError responds with a JSON error body and the HTTP status for its code
+func writeError(w http.ResponseWriter, data *pubsub.ErrorData) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(data.Code.HTTPStatus())
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeUnavailable responds 503 to a publish the hub can't take now, with
+// a Retry-After hint
+func (h *RESTHandler) writeUnavailable(w http.ResponseWriter, err error) {
+	retryAfter := int((h.cfg.PubSub.PublishRetryAfter + time.Second - 1) / time.Second)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeError(w, pubsub.ErrorFrom(err))
+}
+
+// PutTopicSchema registers a new schema version for a topic
+// @Summary Register topic schema
+// @Description Register a JSON Schema document for a topic. Each call creates a new version; events whose payload validates against the latest version are stamped with its schema_version.
+// @Tags schemas
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param schema body object true "JSON Schema document"
+// @Success 200 {object} pubsub.TopicSchema "Registered schema version"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON or JSON Schema"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - topic is owned by another tenant, or not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/schema [put]
+func (h *RESTHandler) PutTopicSchema(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeOwner(w, r, topicName) {
+		return
+	}
+
+	doc, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSchemaSize))
+	if err != nil || !json.Valid(doc) {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	schema, err := h.hub.SetTopicSchema(topicName, doc)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+// GetTopicSchema returns a topic's schema
+// @Summary Get topic schema
+// @Description Get the latest (or a specific) schema version registered for a topic
+// @Tags schemas
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param version query int false "Schema version (default: latest)"
+// @Success 200 {object} pubsub.TopicSchema "Schema version"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid version"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic or schema does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/schema [get]
+func (h *RESTHandler) GetTopicSchema(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid version"))
+			return
+		}
+		version = parsed
+	}
+
+	schema, err := h.hub.GetTopicSchema(topicName, version)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+// GetGroupOffset returns a consumer group's offset in a topic
+// @Summary Get consumer group offset
+// @Description Get a consumer group's position in a topic: the last delivered sequence, the topic's current sequence, lag, the oldest retained sequence and connected members
+// @Tags groups
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param group path string true "Consumer group name"
+// @Success 200 {object} pubsub.GroupOffset "Group offset"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic or group does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/groups/{group}/offset [get]
+func (h *RESTHandler) GetGroupOffset(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, vars["topic"]) {
+		return
+	}
+
+	offset, err := h.hub.GetGroupOffset(vars["topic"], vars["group"])
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offset)
+}
+
+// SetGroupOffset moves a consumer group's offset in a topic
+// @Summary Set consumer group offset
+// @Description Move a consumer group to an offset (creating the group if needed). Members that subscribe afterwards resume after the offset, so rewinding replays retained messages for reprocessing. The group must have no connected members.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param group path string true "Consumer group name"
+// @Param request body pubsub.SetGroupOffsetRequest true "New offset (sequence of the last message considered delivered)"
+// @Success 200 {object} pubsub.GroupOffset "Updated group offset"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON or offset out of range"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Failure 409 {object} pubsub.ErrorData "Conflict - group has connected members"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/groups/{group}/offset [post]
+func (h *RESTHandler) SetGroupOffset(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, vars["topic"]) {
+		return
+	}
+
+	var req pubsub.SetGroupOffsetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	offset, err := h.hub.SetGroupOffset(vars["topic"], vars["group"], req.Offset)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(offset)
+}
+
 // Health returns system health status
 // @Summary Health check
-// @Description Get system health status including uptime and basic metrics
+// @Description Get system health status including uptime and basic metrics. With verbose=true it also reports goroutines, heap and client teardown checks (requires authentication when an API key is set).
 // @Tags system
 // @Produce json
-// @Success 200 {object} map[string]interface{} "System health status"
+// @Param verbose query bool false "Include runtime and leak detection checks"
+// @Success 200 {object} HealthResponse "System health status"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized"
 // @Router /health [get]
 func (h *RESTHandler) Health(w http.ResponseWriter, r *http.Request) {
-	// Health endpoint doesn't require authentication
+	// Health endpoint doesn't require authentication, but runtime details do
+	verbose := r.URL.Query().Get("verbose") == "true"
+	if verbose && !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
 	stats := h.hub.GetStats()
+	response := HealthResponse{
+		UptimeSec:   int(stats.Uptime.Seconds()),
+		Topics:      stats.TotalTopics,
+		Subscribers: stats.TotalClients,
+		Paused:      h.hub.PauseStatus() != nil,
+	}
+
+	if verbose {
+		runtimeStats := h.hub.GetRuntimeStats()
+		response.Runtime = &runtimeStats
+		response.Status = HealthHealthy
+		if runtimeStats.LeakSuspected {
+			response.Status = HealthDegraded
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"uptime_sec":  int(stats.Uptime.Seconds()),
-		"topics":      stats.TotalTopics,
-		"subscribers": stats.TotalClients,
-	})
+	json.NewEncoder(w).Encode(response)
+}
+
+// Ready reports whether the broker is serving, and whether degraded
+// @Summary Readiness check
+// @Description Report whether the broker is ready to serve. While the storage backend set with -data-dir is failing, the broker keeps serving pub/sub from memory and reports status degraded, with the failure, how many changes wait to be written and how many retries failed; the changes are written behind once storage recovers. Degraded brokers still answer 200, so load balancers keep routing to them; alert on the status instead.
+// @Tags system
+// @Produce json
+// @Success 200 {object} ReadyResponse "Readiness"
+// @Router /readyz [get]
+func (h *RESTHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	// Readiness doesn't require authentication, like health
+	response := ReadyResponse{Status: ReadyOK, Storage: h.hub.StorageHealth()}
+	if response.Storage.Degraded {
+		response.Status = ReadyDegraded
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Version returns build information
+// @Summary Version information
+// @Description Get the semantic version, git commit, build date and Go version of the running server
+// @Tags system
+// @Produce json
+// @Success 200 {object} version.Info "Build information"
+// @Router /version [get]
+func (h *RESTHandler) Version(w http.ResponseWriter, r *http.Request) {
+	// Version endpoint doesn't require authentication
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version.Get())
+}
+
+// Snapshot exports topics and retained messages for warming a peer
+// @Summary Cluster snapshot
+// @Description Export every topic's metadata (sequence, replay limits, weight, schemas, consumer group offsets) and retained messages. A node started with -warm-from fetches this from a peer before accepting connections.
+// @Tags system
+// @Produce json
+// @Success 200 {object} pubsub.Snapshot "Topics and retained messages"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Security AdminKeyAuth
+// @Router /cluster/snapshot [get]
+func (h *RESTHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.hub.Snapshot())
 }
 
 // Stats returns system statistics
 // @Summary System statistics
-// @Description Get detailed system statistics including topic metrics and performance data
+// @Description Get detailed system statistics including topic metrics and performance data. Tenants also get their usage: topics owned out of -tenant-max-topics, subscribers, messages and retained messages. With -tenant-namespaces, tenants only see the topics in their namespace, and neither server-wide retention nor client round-trip times.
 // @Tags system
 // @Produce json
-// @Success 200 {object} map[string]interface{} "System statistics"
-// @Failure 401 {string} string "Unauthorized - invalid or missing API key"
+// @Success 200 {object} StatsResponse "System statistics"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
 // @Security ApiKeyAuth
 // @Router /stats [get]
 func (h *RESTHandler) Stats(w http.ResponseWriter, r *http.Request) {
 	// Check authentication
 	if !h.authenticateRequest(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
 		return
 	}
 
-	topics := h.hub.GetTopics()
+	stats := h.hub.GetStats()
+	tenant, scoped := h.scopedTenant(r)
 
-	// Convert to the required format
-	topicStats := make(map[string]map[string]interface{})
-	for name, topic := range topics {
-		topicStats[name] = map[string]interface{}{
-			"messages":    topic.MessageCount,
-			"subscribers": topic.SubscriberCount,
+	response := StatsResponse{
+		Topics:    make(map[string]TopicMetrics, len(stats.Topics)),
+		Panics:    stats.Panics,
+		Channels:  stats.Channels,
+		Errors:    stats.Errors,
+		Retention: stats.Retention,
+		Expired:   stats.ExpiredMessages,
+		Ordering: OrderingStats{
+			Audit:      stats.OrderingAudit,
+			Violations: stats.OrderingViolations,
+		},
+		LeaseExpiries: stats.LeaseExpiries,
+	}
+	if stats.RTT != nil {
+		response.RTT = *stats.RTT
+	}
+	if caller, _ := h.authenticateTenant(r); caller != "" && !authenticateAdmin(h.auth, r) {
+		usage := h.hub.TenantUsage(caller)
+		response.Tenant = &usage
+	}
+	if scoped {
+		response.Retention = nil
+		response.RTT = pubsub.RTTSummary{Slowest: []pubsub.ClientRTT{}}
+	}
+	for name, topic := range stats.Topics {
+		if scoped && !auth.InNamespace(tenant, name) {
+			continue
+		}
+		response.Topics[name] = TopicMetrics{
+			Messages:        topic.MessageCount,
+			Subscribers:     topic.SubscriberCount,
+			PayloadSize:     topic.PayloadSize,
+			Dropped:         topic.DroppedCount,
+			LastPublishAt:   topic.LastPublishAt,
+			BufferOccupancy: topic.BufferOccupancy,
+			BufferCapacity:  topic.BufferCapacity,
+			RetainedBytes:   topic.RetainedBytes,
+			Retention:       topic.Retention,
 		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"topics": topicStats,
-	})
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetClient returns a connected client's subscriptions, send queue and
+// round-trip times
+// @Summary Get client details
+// @Description Describe a connected client by the ID from its welcome frame: its subscriptions, send queue depth and the round-trip times of the server's keepalive pings, to spot clients on degraded networks. Stream subscribers (SSE, MQTT, sinks) have no round-trip times.
+// @Tags system
+// @Produce json
+// @Param id path string true "Client ID"
+// @Success 200 {object} pubsub.ClientInfo "Client details"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 404 {object} pubsub.ErrorData "Not found - no connected client has this ID"
+// @Security ApiKeyAuth
+// @Router /clients/{id} [get]
+func (h *RESTHandler) GetClient(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	info, err := h.hub.GetClient(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// PublishInbox publishes a message to a connected client's inbox
+// @Summary Publish to a client's inbox
+// @Description Publish a directed message to ~inbox/{id}, the private reply topic the broker provisions for every WebSocket client and removes when it disconnects. Only the client may subscribe to its inbox; anyone may publish to it. Responds as /topics/{topic}/publish does.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path string true "Client ID"
+// @Param message body pubsub.MessageData true "Message to publish"
+// @Success 200 {object} map[string]interface{} "Message published"
+// @Success 202 {object} map[string]interface{} "Message queued behind a hub backlog"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, invalid message ID, TTL, headers or content type"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - no connected WebSocket client has this ID"
+// @Failure 413 {object} pubsub.ErrorData "Payload exceeds the maximum message size"
+// @Failure 503 {object} pubsub.ErrorData "Hub saturated - retry after the Retry-After interval, or the request timed out waiting for the hub"
+// @Security ApiKeyAuth
+// @Router /clients/{id}/inbox [post]
+func (h *RESTHandler) PublishInbox(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	// Resolve the client, so an unknown one isn't reported as a missing topic
+	info, err := h.hub.GetClient(mux.Vars(r)["id"])
+	if err == nil && info.Inbox == "" {
+		err = pubsub.ErrClientNotFound
+	}
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	h.Publish(w, mux.SetURLVars(r, map[string]string{"topic": info.Inbox}))
 }
 
 // authenticateRequest checks X-API-Key header
 func (h *RESTHandler) authenticateRequest(r *http.Request) bool {
-	apiKey := h.cfg.Security.APIKey
-	if apiKey == "" {
-		// No API key set, allow all requests
+	_, ok := h.authenticateTenant(r)
+	return ok
+}
+
+// authenticateTenant checks the X-API-Key header against the API key and
+// the tenant keys, or the caller's verified client certificate, and returns
+// the caller's tenant ("" for the shared key)
+func (h *RESTHandler) authenticateTenant(r *http.Request) (string, bool) {
+	return authenticateCaller(h.auth, r, false)
+}
+
+// authorizeTopic checks that the ACL and tenant namespaces let the caller
+// take an action on a topic. Admins may take any action, as may everyone
+// while neither restricts anything. Inboxes are only readable over
+// WebSocket, by their connection. Otherwise it responds 403 and returns
+// false.
+func (h *RESTHandler) authorizeTopic(w http.ResponseWriter, r *http.Request, permission auth.Permission, topicName string) bool {
+	if pubsub.IsInboxTopic(topicName) && permission != auth.PermPublish {
+		writeError(w, pubsub.ErrorFrom(pubsub.ErrInboxPrivate))
+		return false
+	}
+	if !h.auth.Restricts() || authenticateAdmin(h.auth, r) {
 		return true
 	}
+	// SSE callers may send their key as a query parameter
+	tenant, _ := authenticateCaller(h.auth, r, true)
+	if h.auth.Authorize(tenant, permission, topicName) {
+		return true
+	}
+	writeError(w, pubsub.ErrorFrom(pubsub.NotPermitted(permission, topicName)))
+	return false
+}
 
-	providedKey := r.Header.Get("X-API-Key")
-	return providedKey == apiKey
+// scopedTenant returns the tenant whose namespace the caller's topic lists
+// and statistics are confined to, and false for admins, shared-key callers
+// and while tenants aren't namespaced
+func (h *RESTHandler) scopedTenant(r *http.Request) (string, bool) {
+	if !h.auth.Namespaced() || authenticateAdmin(h.auth, r) {
+		return "", false
+	}
+	tenant, _ := h.authenticateTenant(r)
+	return tenant, tenant != ""
+}
+
+// authorizeOwner checks that the caller may delete or reconfigure a topic:
+// the ACL must grant it admin on the topic, and the topic must be unowned
+// or owned by the caller's tenant, unless the caller is an admin. Otherwise
+// it responds 403 and returns false. Missing topics are left to the handler
+// to report.
+func (h *RESTHandler) authorizeOwner(w http.ResponseWriter, r *http.Request, topicName string) bool {
+	if !h.authorizeTopic(w, r, auth.PermAdmin, topicName) {
+		return false
+	}
+	owner, err := h.hub.TopicOwner(topicName)
+	if err != nil || owner == "" || authenticateAdmin(h.auth, r) {
+		return true
+	}
+	if tenant, _ := h.authenticateTenant(r); tenant == owner {
+		return true
+	}
+	writeError(w, pubsub.NewError(pubsub.CodeForbidden, "Topic is owned by "+owner))
+	return false
 }
@@ -1,19 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"plivo/internal/auth"
 	"plivo/internal/config"
 	"plivo/internal/pubsub"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
 func TestNewRESTHandler(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewRESTHandler(hub, cfg)
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	if handler == nil {
 		t.Fatal("NewRESTHandler() returned nil")
@@ -28,12 +37,21 @@ func TestNewRESTHandler(t *testing.T) {
 	}
 }
 
+func TestNewRESTHandlerRequiresAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected NewRESTHandler to panic without an auth service")
+		}
+	}()
+	NewRESTHandler(pubsub.NewHub(), config.NewTestConfig(), nil)
+}
+
 // TestCreateTopic removed - was expecting wrong status codes
 
 func TestListTopics(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewRESTHandler(hub, cfg)
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Create some topics
 	hub.CreateTopic("topic1")
@@ -66,10 +84,66 @@ func TestListTopics(t *testing.T) {
 
 // TestDeleteTopic removed - was expecting wrong status codes
 
+// unavailableStorage is storage that stops taking writes once down is set
+type unavailableStorage struct {
+	down bool
+}
+
+func (s *unavailableStorage) write() error {
+	if s.down {
+		return errors.New("disk unavailable")
+	}
+	return nil
+}
+
+func (s *unavailableStorage) Load() (*pubsub.Snapshot, error)           { return &pubsub.Snapshot{}, nil }
+func (s *unavailableStorage) SaveTopic(pubsub.TopicSnapshot) error      { return s.write() }
+func (s *unavailableStorage) AppendMessage(*pubsub.PubSubMessage) error { return s.write() }
+func (s *unavailableStorage) DeleteTopic(string) error                  { return s.write() }
+func (s *unavailableStorage) Compact(*pubsub.Snapshot) error            { return s.write() }
+func (s *unavailableStorage) Close() error                              { return nil }
+
+func TestReady(t *testing.T) {
+	hub := pubsub.NewHub()
+	storage := &unavailableStorage{}
+	if _, err := hub.Recover(storage); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	ready := func() ReadyResponse {
+		w := httptest.NewRecorder()
+		handler.Ready(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var response ReadyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	if response := ready(); response.Status != ReadyOK || response.Storage.Degraded {
+		t.Errorf("Expected ready, got %+v", response)
+	}
+
+	storage.down = true
+	hub.CreateTopic("orders")
+	response := ready()
+	if response.Status != ReadyDegraded || response.Storage.Error != "disk unavailable" || response.Storage.Pending != 1 {
+		t.Errorf("Expected degraded with the topic creation pending, got %+v", response)
+	}
+	if !hub.TopicExists("orders") {
+		t.Error("Expected the topic created in memory")
+	}
+}
+
 func TestHealth(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewRESTHandler(hub, cfg)
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -98,7 +172,7 @@ func TestHealth(t *testing.T) {
 func TestStats(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewRESTHandler(hub, cfg)
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Create some topics
 	hub.CreateTopic("topic1")
@@ -128,6 +202,43 @@ func TestStats(t *testing.T) {
 	}
 }
 
+func TestResponsesDecodeStrictly(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	hub.CreateTopic("orders")
+
+	// Typed clients must be able to decode every field the server sends
+	decode := func(handle http.HandlerFunc, path string, into interface{}) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handle(w, httptest.NewRequest("GET", path, nil))
+		decoder := json.NewDecoder(w.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(into); err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+	}
+
+	var topics ListTopicsResponse
+	decode(handler.ListTopics, "/topics", &topics)
+	if len(topics.Topics) != 1 || topics.Topics[0].Name != "orders" {
+		t.Errorf("Expected the orders topic listed, got %+v", topics)
+	}
+
+	var health HealthResponse
+	decode(handler.Health, "/health?verbose=true", &health)
+	if health.Topics != 1 || health.Status != HealthHealthy || health.Runtime == nil {
+		t.Errorf("Expected a healthy verbose report for 1 topic, got %+v", health)
+	}
+
+	var stats StatsResponse
+	decode(handler.Stats, "/stats", &stats)
+	if topic, exists := stats.Topics["orders"]; !exists || topic.BufferCapacity != 100 {
+		t.Errorf("Expected orders stats with a 100-message buffer, got %+v", stats.Topics)
+	}
+}
+
 // TestAuthentication removed - was expecting wrong status codes
 
 // TestNoAuthenticationWhenKeyNotSet removed - was expecting wrong status codes
@@ -135,7 +246,7 @@ func TestStats(t *testing.T) {
 func TestHealthEndpointNoAuth(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewRESTHandler(hub, cfg)
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Set API key
 	os.Setenv("API_KEY", "test-key")
@@ -152,6 +263,1177 @@ func TestHealthEndpointNoAuth(t *testing.T) {
 	}
 }
 
+func TestHealthVerbose(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "/health?verbose=true", nil)
+	w := httptest.NewRecorder()
+	handler.Health(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Status  string              `json:"status"`
+		Runtime pubsub.RuntimeStats `json:"runtime"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Status != "healthy" || response.Runtime.Goroutines == 0 || response.Runtime.LeakSuspected {
+		t.Errorf("Expected healthy runtime checks, got %+v", response)
+	}
+}
+
+func TestHealthVerboseRequiresAuth(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("GET", "/health?verbose=true", nil)
+	w := httptest.NewRecorder()
+	handler.Health(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without API key, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/health?verbose=true", nil)
+	req.Header.Set("X-API-Key", "test-key")
+	w = httptest.NewRecorder()
+	handler.Health(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with API key, got %d", w.Code)
+	}
+}
+
 // TestContentTypeValidation removed - was expecting wrong status codes
 
 // TestConcurrentRequests removed - was expecting wrong status codes
+
+func TestVersion(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	// Version endpoint should not require authentication
+	req := httptest.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+
+	handler.Version(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Errorf("Failed to unmarshal response: %v", err)
+	}
+
+	requiredFields := []string{"version", "commit", "build_date", "go_version"}
+	for _, field := range requiredFields {
+		if _, exists := response[field]; !exists {
+			t.Errorf("Response missing required field: %s", field)
+		}
+	}
+}
+
+func TestGetTopic(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("topic1")
+
+	req := httptest.NewRequest("GET", "/topics/topic1", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "topic1"})
+	w := httptest.NewRecorder()
+
+	handler.GetTopic(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Errorf("Failed to unmarshal response: %v", err)
+	}
+
+	requiredFields := []string{"name", "message_count", "subscriber_count", "dropped_count", "buffer_occupancy", "buffer_capacity"}
+	for _, field := range requiredFields {
+		if _, exists := response[field]; !exists {
+			t.Errorf("Response missing required field: %s", field)
+		}
+	}
+
+	// Unknown topic
+	req = httptest.NewRequest("GET", "/topics/missing", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "missing"})
+	w = httptest.NewRecorder()
+
+	handler.GetTopic(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	var errorBody pubsub.ErrorData
+	if err := json.Unmarshal(w.Body.Bytes(), &errorBody); err != nil {
+		t.Fatalf("Failed to unmarshal error body: %v", err)
+	}
+	if errorBody.Code != pubsub.CodeTopicNotFound {
+		t.Errorf("Expected code TOPIC_NOT_FOUND, got %s", errorBody.Code)
+	}
+}
+
+func TestCreateTopicWithReplayLimits(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	body := `{"name": "orders", "replay": {"default_last_n": 5, "max_last_n": 20}}`
+	req := httptest.NewRequest("POST", "/topics", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	stats, err := hub.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Replay.DefaultLastN != 5 || stats.Replay.MaxLastN != 20 {
+		t.Errorf("Expected replay limits 5/20, got %+v", stats.Replay)
+	}
+
+	body = `{"name": "payments", "replay": {"max_last_n": 500}}`
+	req = httptest.NewRequest("POST", "/topics", strings.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a cap beyond the replay buffer, got %d", w.Code)
+	}
+}
+
+func TestCreateTopicWithWeight(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "payments", "weight": 4}`))
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if stats, _ := hub.GetTopicStats("payments"); stats.Weight != 4 {
+		t.Errorf("Expected weight 4, got %d", stats.Weight)
+	}
+
+	req = httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "orders", "weight": -1}`))
+	w = httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative weight, got %d", w.Code)
+	}
+}
+
+func TestEncryptedTopic(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "payroll", "key_id": "key-2024"}`))
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if stats, _ := hub.GetTopicStats("payroll"); stats.KeyID != "key-2024" {
+		t.Errorf("Expected key ID key-2024, got %q", stats.KeyID)
+	}
+
+	publish := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/payroll/publish", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"topic": "payroll"})
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		return w
+	}
+
+	if w := publish(`{"id": "msg-1", "payload": {"salary": 1}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a plaintext publish, got %d", w.Code)
+	}
+	if w := publish(`{"id": "msg-2", "payload": "c2VjcmV0", "content_type": "application/octet-stream"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for ciphertext, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTopicSchemaEndpoints(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+
+	// Register a schema
+	req := httptest.NewRequest("PUT", "/topics/orders/schema", strings.NewReader(`{"type": "object"}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w := httptest.NewRecorder()
+
+	handler.PutTopicSchema(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var registered map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &registered); err != nil {
+		t.Errorf("Failed to unmarshal response: %v", err)
+	}
+	if registered["version"] != float64(1) {
+		t.Errorf("Expected version 1, got %v", registered["version"])
+	}
+
+	// Fetch it back
+	req = httptest.NewRequest("GET", "/topics/orders/schema?version=1", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w = httptest.NewRecorder()
+
+	handler.GetTopicSchema(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	// Invalid schema document
+	req = httptest.NewRequest("PUT", "/topics/orders/schema", strings.NewReader(`{"type": 42}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w = httptest.NewRecorder()
+
+	handler.PutTopicSchema(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid schema, got %d", w.Code)
+	}
+
+	// Unknown topic
+	req = httptest.NewRequest("PUT", "/topics/missing/schema", strings.NewReader(`{"type": "object"}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "missing"})
+	w = httptest.NewRecorder()
+
+	handler.PutTopicSchema(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown topic, got %d", w.Code)
+	}
+}
+
+func TestGroupOffsetEndpoints(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+
+	// Unknown group
+	req := httptest.NewRequest("GET", "/topics/orders/groups/billing/offset", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders", "group": "billing"})
+	w := httptest.NewRecorder()
+
+	handler.GetGroupOffset(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown group, got %d", w.Code)
+	}
+
+	// Create the group at offset 0
+	req = httptest.NewRequest("POST", "/topics/orders/groups/billing/offset", strings.NewReader(`{"offset": 0}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders", "group": "billing"})
+	w = httptest.NewRecorder()
+
+	handler.SetGroupOffset(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Offset beyond the topic sequence
+	req = httptest.NewRequest("POST", "/topics/orders/groups/billing/offset", strings.NewReader(`{"offset": 10}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders", "group": "billing"})
+	w = httptest.NewRecorder()
+
+	handler.SetGroupOffset(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for out-of-range offset, got %d", w.Code)
+	}
+
+	// Read it back
+	req = httptest.NewRequest("GET", "/topics/orders/groups/billing/offset", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders", "group": "billing"})
+	w = httptest.NewRecorder()
+
+	handler.GetGroupOffset(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var offset pubsub.GroupOffset
+	if err := json.Unmarshal(w.Body.Bytes(), &offset); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if offset.Group != "billing" || offset.Offset != 0 {
+		t.Errorf("Expected billing group at offset 0, got %+v", offset)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	opts := pubsub.DefaultHubOptions()
+	clock := pubsub.NewManualClock(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	opts.Clock = clock
+	hub := pubsub.NewHubWithOptions(opts)
+	cfg := config.NewTestConfig()
+	cfg.PubSub.MaxMessageSize = 64
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+
+	publish := func(topic, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/"+topic+"/publish", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		return w
+	}
+
+	w := publish("orders", `{"id": "msg-1", "payload": {"order_id": "ORD-1"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response["id"] != "msg-1" || response["status"] != "published" || response["timestamp"] == nil {
+		t.Errorf("Unexpected publish response: %v", response)
+	}
+	// Received by the hub's clock, as WebSocket publishes are
+	if response["timestamp"] != "2025-01-15T10:00:00Z" {
+		t.Errorf("Expected the hub's clock time, got %v", response["timestamp"])
+	}
+
+	if w := publish("missing", `{"id": "msg-2", "payload": "x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown topic, got %d", w.Code)
+	}
+
+	if w := publish("orders", `{"payload": "no id"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for missing message ID, got %d", w.Code)
+	}
+
+	if w := publish("$SYS/echo", `{"id": "msg-3", "payload": "x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for reserved topic, got %d", w.Code)
+	}
+
+	if w := publish("orders", `{"id": "msg-5", "payload": 1, "headers": {"_meta": "x"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for reserved header, got %d", w.Code)
+	}
+
+	if w := publish("orders", `{"id": "msg-6", "payload": 1, "ttl_ms": -1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for negative TTL, got %d", w.Code)
+	}
+
+	w = publish("orders", `{"id": "msg-4", "payload": "`+strings.Repeat("x", 100)+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413 for oversized payload, got %d", w.Code)
+	}
+
+	var errorData pubsub.ErrorData
+	if err := json.Unmarshal(w.Body.Bytes(), &errorData); err != nil {
+		t.Fatalf("Failed to unmarshal error body: %v", err)
+	}
+	if errorData.Code != "MESSAGE_TOO_LARGE" || errorData.Limit != 64 {
+		t.Errorf("Expected MESSAGE_TOO_LARGE with limit 64, got %+v", errorData)
+	}
+}
+
+func TestPublishDuplicate(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "orders", "dedup_window_ms": 60000}`))
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	statuses := make([]interface{}, 2)
+	for i := range statuses {
+		req := httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "msg-1", "payload": 1}`))
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		statuses[i] = response["status"]
+	}
+	if statuses[0] != "published" || statuses[1] != "duplicate" {
+		t.Errorf("Expected the retry reported as a duplicate, got %v", statuses)
+	}
+}
+
+func TestPublishSchemaViolation(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	body := `{"name": "orders", "schema": {"type": "object", "required": ["order_id"], "properties": {"order_id": {"type": "string"}}}}`
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, httptest.NewRequest("POST", "/topics", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	publish := func(id, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "`+id+`", "payload": `+payload+`}`))
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		return w
+	}
+	if w := publish("msg-1", `{"order_id": "ORD-1"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected a conforming payload published, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = publish("msg-2", `{"order_id": 7}`)
+	var errorData pubsub.ErrorData
+	json.Unmarshal(w.Body.Bytes(), &errorData)
+	if w.Code != http.StatusBadRequest || errorData.Code != pubsub.CodeSchemaViolation {
+		t.Errorf("Expected a 400 SCHEMA_VIOLATION, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(errorData.Message, "/order_id") {
+		t.Errorf("Expected the error to name the offending field, got %q", errorData.Message)
+	}
+
+	w = httptest.NewRecorder()
+	handler.CreateTopic(w, httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "payments", "schema": {"type": 42}}`)))
+	if w.Code != http.StatusBadRequest || hub.TopicExists("payments") {
+		t.Errorf("Expected an invalid schema to fail creation, got %d", w.Code)
+	}
+}
+
+func TestPublishBatch(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+
+	publish := func(topic, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/"+topic+"/publish/batch", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.PublishBatch(w, req)
+		return w
+	}
+
+	w := publish("orders", `[{"id": "m1", "payload": 1}, {"id": "m2", "payload": 2}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response BatchPublishResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Status != "published" || response.Count != 2 || response.Results[1].ID != "m2" || response.Results[1].Status != pubsub.BatchPublished {
+		t.Errorf("Unexpected batch response: %+v", response)
+	}
+	if depth, _ := hub.PublishBacklog("orders"); depth != 2 {
+		t.Errorf("Expected 2 publishes queued, got %d", depth)
+	}
+
+	// One invalid message rejects the whole batch
+	w = publish("orders", `[{"id": "m3", "payload": 3}, {"payload": "no id"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	var errorData pubsub.ErrorData
+	if err := json.Unmarshal(w.Body.Bytes(), &errorData); err != nil {
+		t.Fatalf("Failed to unmarshal error body: %v", err)
+	}
+	if len(errorData.Results) != 2 || errorData.Results[0].Status != pubsub.BatchAborted || errorData.Results[1].Status != pubsub.BatchRejected {
+		t.Errorf("Expected m3 aborted and the second message rejected, got %+v", errorData.Results)
+	}
+	if depth, _ := hub.PublishBacklog("orders"); depth != 2 {
+		t.Errorf("Expected nothing more queued, got %d", depth)
+	}
+
+	if w := publish("orders", `[]`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty batch, got %d", w.Code)
+	}
+	if w := publish("orders", `{"id": "m4", "payload": 4}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a body that isn't an array, got %d", w.Code)
+	}
+	if w := publish("missing", `[{"id": "m5", "payload": 5}]`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown topic, got %d", w.Code)
+	}
+}
+
+func TestPublishBackpressure(t *testing.T) {
+	// The hub isn't running, so every publish stays in the backlog
+	hub := pubsub.NewHubWithOptions(pubsub.HubOptions{PublishBuffer: 8, PublishRejectDepth: 4})
+	cfg := config.NewTestConfig()
+	cfg.PubSub.PublishQueuedDepth = 2
+	cfg.PubSub.PublishRetryAfter = 1500 * time.Millisecond
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+
+	publish := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "`+id+`", "payload": 1}`))
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		w := httptest.NewRecorder()
+		handler.Publish(w, req)
+		return w
+	}
+
+	for _, id := range []string{"msg-1", "msg-2"} {
+		if w := publish(id); w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 below the queued threshold, got %d", w.Code)
+		}
+	}
+
+	w := publish("msg-3")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 above the queued threshold, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["status"] != "queued" || response["queue_position"] != float64(2) {
+		t.Errorf("Expected queued response at position 2, got %v", response)
+	}
+
+	publish("msg-4")
+
+	w = publish("msg-5")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 at the reject threshold, got %d", w.Code)
+	}
+
+	// Retry-After is rounded up to whole seconds
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", retryAfter)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	hub := pubsub.NewHub()
+	hub.CreateTopic("orders")
+	cfg := config.NewTestConfigWithAPIKey("secret")
+	cfg.Security.AdminKey = "admin"
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	// The API key alone isn't enough once an admin key is set
+	req := httptest.NewRequest("GET", "/cluster/snapshot", nil)
+	req.Header.Set("X-API-Key", "secret")
+	w := httptest.NewRecorder()
+	handler.Snapshot(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin key, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/cluster/snapshot", nil)
+	req.Header.Set("X-Admin-Key", "admin")
+	w = httptest.NewRecorder()
+	handler.Snapshot(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var snapshot pubsub.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+	if len(snapshot.Topics) != 1 || snapshot.Topics[0].Name != "orders" {
+		t.Errorf("Expected the orders topic in the snapshot, got %+v", snapshot.Topics)
+	}
+}
+
+func TestDrainTopic(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+
+	req := httptest.NewRequest("POST", "/topics/orders/drain", strings.NewReader(`{"replacement": "orders-v2", "migrate": true}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w := httptest.NewRecorder()
+	handler.DrainTopic(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result pubsub.DrainResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result.Topic != "orders" || result.Replacement != "orders-v2" {
+		t.Errorf("Expected orders draining to orders-v2, got %+v", result)
+	}
+
+	// Draining the replacement back to a draining topic is rejected
+	req = httptest.NewRequest("POST", "/topics/orders-v2/drain", strings.NewReader(`{"replacement": "orders"}`))
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders-v2"})
+	w = httptest.NewRecorder()
+	handler.DrainTopic(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a draining replacement, got %d", w.Code)
+	}
+
+	// The body is optional
+	req = httptest.NewRequest("POST", "/topics/missing/drain", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "missing"})
+	w = httptest.NewRecorder()
+	handler.DrainTopic(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing topic, got %d", w.Code)
+	}
+}
+
+func TestTopicOwnership(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfigWithAPIKey("shared-key")
+	cfg.Security.AdminKey = "admin-key"
+	cfg.Security.TenantKeys = "payments=pay-key,search=search-key"
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	request := func(method, path, topic, apiKey, body string) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		if topic != "" {
+			req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		}
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, request("POST", "/topics", "", "pay-key", `{"name": "invoices"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if stats, _ := hub.GetTopicStats("invoices"); stats.Owner != "payments" {
+		t.Errorf("Expected owner payments, got %q", stats.Owner)
+	}
+
+	// Other tenants and the shared key may not delete or transfer it
+	for _, key := range []string{"search-key", "shared-key"} {
+		w = httptest.NewRecorder()
+		handler.DeleteTopic(w, request("DELETE", "/topics/invoices", "invoices", key, ""))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 deleting with %s, got %d", key, w.Code)
+		}
+		w = httptest.NewRecorder()
+		handler.TransferTopic(w, request("POST", "/topics/invoices/transfer", "invoices", key, `{"owner": "search"}`))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 transferring with %s, got %d", key, w.Code)
+		}
+	}
+
+	// Transfers must name a configured tenant
+	w = httptest.NewRecorder()
+	handler.TransferTopic(w, request("POST", "/topics/invoices/transfer", "invoices", "pay-key", `{"owner": "nobody"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown tenant, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.TransferTopic(w, request("POST", "/topics/invoices/transfer", "invoices", "pay-key", `{"owner": "search"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["owner"] != "search" || response["previous_owner"] != "payments" {
+		t.Errorf("Expected transfer from payments to search, got %v", response)
+	}
+
+	// The previous owner lost access; admins keep it
+	w = httptest.NewRecorder()
+	handler.PutTopicSchema(w, request("PUT", "/topics/invoices/schema", "invoices", "pay-key", `{"type": "object"}`))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for the previous owner, got %d", w.Code)
+	}
+
+	req := request("DELETE", "/topics/invoices", "invoices", "shared-key", "")
+	req.Header.Set("X-Admin-Key", "admin-key")
+	w = httptest.NewRecorder()
+	handler.DeleteTopic(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an admin delete, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUpdateTopic(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	hub.CreateTopic("orders")
+
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/topics/orders", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.UpdateTopic(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest("GET", "/topics/orders", nil)
+	req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+	w := httptest.NewRecorder()
+	handler.GetTopic(w, req)
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\" for a new topic, got %q", etag)
+	}
+
+	w = patch(etag, `{"weight": 4, "labels": {"team": "checkout"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats pubsub.TopicStats
+	json.Unmarshal(w.Body.Bytes(), &stats)
+	if stats.Weight != 4 || stats.Labels["team"] != "checkout" || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("Expected the update applied at revision 2, got %+v with ETag %s", stats, w.Header().Get("ETag"))
+	}
+
+	// A second writer holding the old ETag is refused
+	w = patch(etag, `{"weight": 2}`)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a stale If-Match, got %d", w.Code)
+	}
+	var errorBody pubsub.ErrorData
+	json.Unmarshal(w.Body.Bytes(), &errorBody)
+	if errorBody.Code != pubsub.CodeRevisionMismatch {
+		t.Errorf("Expected code REVISION_MISMATCH, got %s", errorBody.Code)
+	}
+
+	for _, tt := range []struct{ ifMatch, body string }{
+		{"W/\"2\"", `{"weight": 2}`},
+		{"", `{"weight": -1}`},
+		{"", `{"labels": {"": "x"}}`},
+		{"", `not json`},
+	} {
+		if w = patch(tt.ifMatch, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for If-Match %q and %s, got %d", tt.ifMatch, tt.body, w.Code)
+		}
+	}
+
+	// Without If-Match, or with *, updates apply unconditionally
+	if w = patch("*", `{"enrich": true}`); w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Errorf("Expected status 200 at revision 3, got %d with ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestDeleteAndRestoreTopic(t *testing.T) {
+	opts := pubsub.DefaultHubOptions()
+	opts.TrashWindow = time.Minute
+	hub := pubsub.NewHubWithOptions(opts)
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	hub.CreateTopic("orders")
+
+	request := func(method, path string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{"topic": "orders"})
+	}
+
+	w := httptest.NewRecorder()
+	handler.DeleteTopic(w, request("DELETE", "/topics/orders"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["status"] != "deleted" || response["purge_at"] == nil {
+		t.Errorf("Expected a deleted topic with purge_at, got %v", response)
+	}
+
+	// Publishing to the deleted topic names why it fails
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/topics/orders/publish", strings.NewReader(`{"id": "m1", "payload": 1}`)), map[string]string{"topic": "orders"})
+	w = httptest.NewRecorder()
+	handler.Publish(w, req)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "TOPIC_DELETED") {
+		t.Errorf("Expected 409 TOPIC_DELETED, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.RestoreTopic(w, request("POST", "/topics/orders/restore"))
+	if w.Code != http.StatusOK || !hub.TopicExists("orders") {
+		t.Fatalf("Expected the topic restored, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Purging skips the trash
+	w = httptest.NewRecorder()
+	handler.DeleteTopic(w, request("DELETE", "/topics/orders?purge=true"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	handler.RestoreTopic(w, request("POST", "/topics/orders/restore"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring a purged topic, got %d", w.Code)
+	}
+}
+
+func TestReplayTopic(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-retry")
+
+	replay := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/topics/orders/replay", strings.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		w := httptest.NewRecorder()
+		handler.ReplayTopic(w, req)
+		return w
+	}
+
+	w := replay(`{"target_topic": "orders-retry", "from_sequence": 1}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var result pubsub.ReplayIntoResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result.Topic != "orders" || result.TargetTopic != "orders-retry" || result.Messages != 0 {
+		t.Errorf("Expected an empty replay of orders into orders-retry, got %+v", result)
+	}
+
+	if w := replay(`{"target_topic": "orders-retry", "from_sequence": 9, "to_sequence": 3}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a reversed range, got %d", w.Code)
+	}
+	if w := replay(`{"target_topic": "missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing target, got %d", w.Code)
+	}
+}
+
+func TestGetTopicMessages(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	start := time.Now().Add(-time.Minute)
+	snapshot := &pubsub.Snapshot{Topics: []pubsub.TopicSnapshot{
+		{Name: "orders", Sequence: 3},
+		{Name: "sealed", KeyID: "key-1"},
+	}}
+	for i := 1; i <= 3; i++ {
+		snapshot.Topics[0].Messages = append(snapshot.Topics[0].Messages, &pubsub.PubSubMessage{
+			Topic:     "orders",
+			Message:   &pubsub.MessageData{ID: fmt.Sprintf("msg-%d", i), Payload: i},
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Sequence:  int64(i),
+		})
+	}
+	hub.Restore(snapshot)
+
+	get := func(topic, query string) (*httptest.ResponseRecorder, TopicMessages) {
+		req := httptest.NewRequest("GET", "/topics/"+topic+"/messages?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.GetTopicMessages(w, req)
+		var result TopicMessages
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"msg-1", "msg-2", "msg-3"}},
+		{"last_n=2", []string{"msg-2", "msg-3"}},
+		{"since=" + url.QueryEscape(start.Add(2*time.Second).Format(time.RFC3339Nano)), []string{"msg-2", "msg-3"}},
+		{"last_n=1&since=" + url.QueryEscape(start.Format(time.RFC3339Nano)), []string{"msg-3"}},
+	}
+	for _, tt := range tests {
+		w, result := get("orders", tt.query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", tt.query, w.Code, w.Body.String())
+		}
+		ids := make([]string, len(result.Messages))
+		for i, message := range result.Messages {
+			ids[i] = message.Message.ID
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) || result.Count != len(tt.want) {
+			t.Errorf("%q: expected %v, got %v (count %d)", tt.query, tt.want, ids, result.Count)
+		}
+	}
+
+	for query, status := range map[string]int{"last_n=-1": http.StatusBadRequest, "since=yesterday": http.StatusBadRequest} {
+		if w, _ := get("orders", query); w.Code != status {
+			t.Errorf("%q: expected status %d, got %d", query, status, w.Code)
+		}
+	}
+	if w, _ := get("missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing topic, got %d", w.Code)
+	}
+	if w, _ := get("sealed", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without the topic's key ID, got %d", w.Code)
+	}
+	if w, _ := get("sealed", "key_id=key-1"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the topic's key ID, got %d", w.Code)
+	}
+}
+
+func TestGetTopicMessagesPagination(t *testing.T) {
+	hub := pubsub.NewHub()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	snapshot := &pubsub.Snapshot{Topics: []pubsub.TopicSnapshot{{Name: "orders", Sequence: 5}, {Name: "audit"}}}
+	for i := 1; i <= 5; i++ {
+		snapshot.Topics[0].Messages = append(snapshot.Topics[0].Messages, &pubsub.PubSubMessage{
+			Topic:     "orders",
+			Message:   &pubsub.MessageData{ID: fmt.Sprintf("msg-%d", i), Payload: i},
+			Timestamp: time.Now(),
+			Sequence:  int64(i),
+		})
+	}
+	hub.Restore(snapshot)
+
+	get := func(topic, query string) (*httptest.ResponseRecorder, TopicMessages) {
+		req := httptest.NewRequest("GET", "/topics/"+topic+"/messages?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.GetTopicMessages(w, req)
+		var result TopicMessages
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	var ids []string
+	query := "since_seq=2&limit=2"
+	for pages := 0; query != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("Pagination did not end")
+		}
+		w, result := get("orders", query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		for _, message := range result.Messages {
+			ids = append(ids, message.Message.ID)
+		}
+		query = ""
+		if result.NextCursor != "" {
+			query = "limit=2&cursor=" + url.QueryEscape(result.NextCursor)
+		}
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]string{"msg-2", "msg-3", "msg-4", "msg-5"}) {
+		t.Errorf("Expected messages 2 to 5 across pages, got %v", ids)
+	}
+
+	if _, result := get("orders", "limit=10"); result.Count != 5 || result.NextCursor != "" {
+		t.Errorf("Expected one page of 5 messages, got %d with cursor %q", result.Count, result.NextCursor)
+	}
+
+	_, first := get("orders", "limit=1")
+	for _, query := range []string{
+		"limit=0",
+		"limit=1001",
+		"since_seq=-1",
+		"cursor=garbage!",
+		"last_n=2&limit=2",
+		"since_seq=1&cursor=" + url.QueryEscape(first.NextCursor),
+	} {
+		if w, _ := get("orders", query); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, w.Code)
+		}
+	}
+	if w, _ := get("audit", "cursor="+url.QueryEscape(first.NextCursor)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for another topic's cursor, got %d", w.Code)
+	}
+}
+
+func TestGetTopicMessagesPublishedWithoutSubscribers(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	// Nobody subscribes to the topic before or after these publishes
+	hub.CreateTopic("orders")
+	for i := 1; i <= 3; i++ {
+		if _, err := hub.PublishDirect(context.Background(), "orders", &pubsub.MessageData{ID: fmt.Sprintf("msg-%d", i)}); err != nil {
+			t.Fatalf("PublishDirect failed: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for stats, _ := hub.GetTopicStats("orders"); stats.Sequence != 3; stats, _ = hub.GetTopicStats("orders") {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the publishes")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var ids []string
+	query := "limit=2"
+	for pages := 0; query != ""; pages++ {
+		if pages > 2 {
+			t.Fatal("Pagination did not end")
+		}
+		req := httptest.NewRequest("GET", "/topics/orders/messages?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		w := httptest.NewRecorder()
+		handler.GetTopicMessages(w, req)
+		var result TopicMessages
+		if err := json.Unmarshal(w.Body.Bytes(), &result); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("%q: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		for _, message := range result.Messages {
+			ids = append(ids, message.Message.ID)
+		}
+		query = ""
+		if result.NextCursor != "" {
+			query = "limit=2&cursor=" + url.QueryEscape(result.NextCursor)
+		}
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]string{"msg-1", "msg-2", "msg-3"}) {
+		t.Errorf("Expected every message in the history, got %v", ids)
+	}
+
+	if stats, _ := hub.GetTopicStats("orders"); stats.MessageCount != 3 {
+		t.Errorf("Expected a message count of 3, got %d", stats.MessageCount)
+	}
+}
+
+func TestGetClient(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	hub.CreateTopic("orders")
+	stream, err := hub.OpenStream("client-1", "orders", pubsub.StreamOptions{}, pubsub.DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/clients/"+id, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		handler.GetClient(w, req)
+		return w
+	}
+
+	w := get("client-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var info pubsub.ClientInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if info.ID != "client-1" || !info.Stream || len(info.Subscriptions) != 1 || info.Subscriptions[0] != "orders" {
+		t.Errorf("Expected the stream's details, got %+v", info)
+	}
+
+	if w := get("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown client, got %d", w.Code)
+	}
+}
+
+func TestPublishInbox(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	client := pubsub.NewClient(hub, nil, "client-1", pubsub.DefaultClientOptions())
+	if err := hub.RegisterClient(client); err != nil {
+		t.Fatalf("RegisterClient failed: %v", err)
+	}
+	hub.CreateTopic("orders")
+	stream, err := hub.OpenStream("stream-1", "orders", pubsub.StreamOptions{}, pubsub.DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	publish := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/clients/"+id+"/inbox", strings.NewReader(`{"id":"reply-1","payload":"pong"}`))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		handler.PublishInbox(w, req)
+		return w
+	}
+
+	if w := publish("client-1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	deadline := time.Now().Add(time.Second)
+	for hub.GetTopics()[pubsub.InboxTopic("client-1")].Sequence != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the publish to reach the inbox")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, id := range []string{"stream-1", "missing"} {
+		if w := publish(id); w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 publishing to %s, got %d", id, w.Code)
+		}
+	}
+
+	inbox := pubsub.InboxTopic("client-1")
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/topics/"+inbox+"/messages", nil), map[string]string{"topic": inbox})
+	w := httptest.NewRecorder()
+	handler.GetTopicMessages(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 reading an inbox over REST, got %d", w.Code)
+	}
+}
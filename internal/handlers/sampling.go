@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"plivo/internal/pubsub"
+
+	"github.com/gorilla/mux"
+)
+
+// TopicSamples is a topic's sampled publishes
+type TopicSamples struct {
+	Topic string `json:"topic"`
+	// SampleEvery is the topic's sampling rate: one in SampleEvery
+	// publishes, 0 if it isn't sampling
+	SampleEvery int `json:"sample_every"`
+	// Sampled is how many publishes were sampled, including those since
+	// dropped
+	Sampled int64 `json:"sampled"`
+	// Count is the number of samples returned
+	Count    int                     `json:"count"`
+	Messages []*pubsub.PubSubMessage `json:"messages"`
+}
+
+// GetTopicSamples returns a topic's sampled publishes
+// @Summary Get sampled messages
+// @Description Get the newest publishes a topic with sample_every sampled, up to 100, oldest first, so operators can see the shape of its payloads without subscribing to it. One in every sample_every publishes is sampled, counted by sequence. Changing sample_every drops the samples taken at the old rate.
+// @Tags topics
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Success 200 {object} TopicSamples "Sampled messages"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing admin credential"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security AdminKeyAuth
+// @Router /topics/{topic}/samples [get]
+func (h *RESTHandler) GetTopicSamples(w http.ResponseWriter, r *http.Request) {
+	if !authenticateAdmin(h.auth, r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+	stats, err := h.hub.GetTopicStats(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+	messages, err := h.hub.TopicSamples(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TopicSamples{
+		Topic:       topicName,
+		SampleEvery: stats.SampleEvery,
+		Sampled:     stats.Sampled,
+		Count:       len(messages),
+		Messages:    messages,
+	})
+}
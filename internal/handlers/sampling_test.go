@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestGetTopicSamples(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	cfg.Security.AdminKey = "admin"
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name": "orders", "sample_every": 2}`))
+	req.Header.Set("X-API-Key", "test-key")
+	w := httptest.NewRecorder()
+	handler.CreateTopic(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if _, err := hub.TryPublish(&pubsub.PubSubMessage{Topic: "orders", Message: &pubsub.MessageData{ID: id, Payload: id}}, time.Second); err != nil {
+			t.Fatalf("TryPublish failed: %v", err)
+		}
+	}
+
+	get := func(key string) (int, TopicSamples) {
+		req := httptest.NewRequest("GET", "/topics/orders/samples", nil)
+		req = mux.SetURLVars(req, map[string]string{"topic": "orders"})
+		req.Header.Set("X-Admin-Key", key)
+		w := httptest.NewRecorder()
+		handler.GetTopicSamples(w, req)
+		var samples TopicSamples
+		json.Unmarshal(w.Body.Bytes(), &samples)
+		return w.Code, samples
+	}
+
+	if code, _ := get("test-key"); code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without the admin key, got %d", code)
+	}
+
+	// Publishes are sampled once the hub has fanned them out
+	code, samples := get("admin")
+	for deadline := time.Now().Add(time.Second); samples.Count < 2 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		code, samples = get("admin")
+	}
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if samples.SampleEvery != 2 || samples.Count != 2 || samples.Messages[0].Message.ID != "b" || samples.Messages[1].Message.ID != "d" {
+		t.Errorf("Expected the second and fourth publishes, got %+v", samples)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// StreamEvents streams a topic's events as server-sent events
+// @Summary Stream events (SSE)
+// @Description Subscribe to a topic over server-sent events, for browser and curl consumers that can't use WebSockets. Each frame a WebSocket subscriber would receive, starting with the welcome info frame, is sent as the data of an SSE message; events carry their topic sequence as the SSE id. Reconnecting with Last-Event-ID (or last_event_id) replays every retained message after that sequence; otherwise last_n replays the newest retained messages. Browsers' EventSource can't set headers, so the API key may be passed as api_key. Consumers that fall a full queue behind are sent SLOW_CONSUMER and disconnected.
+// @Tags messages
+// @Produce text/event-stream
+// @Param topic path string true "Topic name"
+// @Param last_n query int false "Retained messages to replay before live events"
+// @Param last_event_id query int false "Resume after this sequence, for clients that can't set Last-Event-ID"
+// @Param Last-Event-ID header int false "Resume after this sequence"
+// @Param fields query string false "Comma-separated payload fields to deliver"
+// @Param key_id query string false "Key ID of an encrypted topic"
+// @Param group query string false "Consumer group to join: its members share the topic's events round-robin"
+// @Param where query string false "Deliver only events whose content meets this expression, such as payload.status == \"shipped\""
+// @Param api_key query string false "API key, for clients that can't set headers"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid last_n, Last-Event-ID, fields, group or where"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - key_id does not match the encrypted topic, or not permitted by the ACL"
+// @Failure 409 {object} pubsub.ErrorData "Conflict - topic is draining or deleted"
+// @Failure 503 {object} pubsub.ErrorData "Server is shutting down"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/events [get]
+func (h *RESTHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	// EventSource can't set headers, so accept the key as a query parameter
+	if _, ok := authenticateCaller(h.auth, r, true); !ok {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+	query := r.URL.Query()
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	var opts pubsub.StreamOptions
+	if v := query.Get("last_n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid last_n"))
+			return
+		}
+		opts.LastN = parsed
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || parsed < 0 {
+			writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid Last-Event-ID, expected a topic sequence"))
+			return
+		}
+		opts.AfterSequence = parsed
+	}
+	if v := query.Get("fields"); v != "" {
+		opts.Fields = strings.Split(v, ",")
+	}
+	opts.KeyID = query.Get("key_id")
+	opts.Group = query.Get("group")
+	opts.Where = query.Get("where")
+
+	if _, ok := w.(http.Flusher); !ok {
+		writeError(w, pubsub.NewError(pubsub.CodeInternal, "Streaming is not supported"))
+		return
+	}
+
+	clientOpts := pubsub.NewClientOptions(h.cfg.PubSub)
+	stream, err := h.hub.OpenStream(uuid.New().String(), topicName, opts, clientOpts)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Proxies such as nginx would otherwise buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	keepalive := time.NewTicker(clientOpts.PingInterval)
+	defer keepalive.Stop()
+
+	// The stream outlives the server's write timeout, so each write gets
+	// its own deadline
+	controller := http.NewResponseController(w)
+	write := func(data string) bool {
+		controller.SetWriteDeadline(time.Now().Add(clientOpts.WriteWait))
+		if _, err := fmt.Fprint(w, data); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+	for {
+		select {
+		case <-stream.Ready():
+			frames, closed := stream.Next()
+			var b strings.Builder
+			for _, frame := range frames {
+				if frame.Sequence > 0 {
+					fmt.Fprintf(&b, "id: %d\n", frame.Sequence)
+				}
+				fmt.Fprintf(&b, "data: %s\n\n", frame.Data)
+			}
+			if b.Len() > 0 && !write(b.String()) {
+				return
+			}
+			if closed {
+				return
+			}
+
+		case <-keepalive.C:
+			if !write(": keepalive\n\n") {
+				return
+			}
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStreamEvents(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	router := mux.NewRouter()
+	router.HandleFunc("/topics/{topic}/events", handler.StreamEvents).Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	hub.Restore(&pubsub.Snapshot{Topics: []pubsub.TopicSnapshot{{
+		Name:     "orders",
+		Sequence: 2,
+		Messages: []*pubsub.PubSubMessage{
+			{Topic: "orders", Message: &pubsub.MessageData{ID: "msg-1", Payload: 1}, Sequence: 1},
+			{Topic: "orders", Message: &pubsub.MessageData{ID: "msg-2", Payload: 2}, Sequence: 2},
+		},
+	}}})
+
+	if resp, err := http.Get(server.URL + "/topics/orders/events"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without a key, got %v, %v", resp, err)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/topics/orders/events?api_key=test-key", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected a 200 event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	// expect reads lines until one starts with prefix
+	expect := func(prefix string) string {
+		t.Helper()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("Stream ended waiting for %q", prefix)
+				}
+				if strings.HasPrefix(line, prefix) {
+					return line
+				}
+			case <-timeout:
+				t.Fatalf("Timed out waiting for %q", prefix)
+			}
+		}
+	}
+
+	expect("retry: ")
+	if welcome := expect("data: "); !strings.Contains(welcome, `"type":"info"`) {
+		t.Errorf("Expected the welcome frame first, got %s", welcome)
+	}
+
+	// Only the message after Last-Event-ID is replayed, then live events
+	expect("id: 2")
+	if event := expect("data: "); !strings.Contains(event, `"id":"msg-2"`) {
+		t.Errorf("Expected msg-2 replayed, got %s", event)
+	}
+	message, _ := pubsub.NewMessage("orders", 3, pubsub.WithID("msg-3"))
+	hub.TryPublish(message, time.Second)
+	expect("id: 3")
+	if event := expect("data: "); !strings.Contains(event, `"id":"msg-3"`) {
+		t.Errorf("Expected live msg-3, got %s", event)
+	}
+}
+
+func TestStreamEventsRejections(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	cfg := config.NewTestConfig()
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	hub.CreateTopicWithOptions("sealed", pubsub.TopicOptions{KeyID: "key-1"})
+
+	for query, status := range map[string]int{
+		"/topics/sealed/events":                  http.StatusForbidden,
+		"/topics/sealed/events?last_n=x":         http.StatusBadRequest,
+		"/topics/sealed/events?last_event_id=-1": http.StatusBadRequest,
+	} {
+		req := mux.SetURLVars(httptest.NewRequest("GET", query, nil), map[string]string{"topic": "sealed"})
+		w := httptest.NewRecorder()
+		handler.StreamEvents(w, req)
+		if w.Code != status {
+			t.Errorf("%s: expected status %d, got %d", query, status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"plivo/internal/auth"
+	"plivo/internal/pubsub"
+
+	"github.com/gorilla/mux"
+)
+
+// TopicState is a topic's key/value state
+type TopicState struct {
+	Topic string                       `json:"topic"`
+	State map[string]pubsub.StateEntry `json:"state"`
+}
+
+// GetTopicState returns a topic's state
+// @Summary Get topic state
+// @Description Get every key of a topic's state, the same snapshot a new subscriber receives in a state frame before live events.
+// @Tags state
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Success 200 {object} TopicState "Topic state"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/state [get]
+func (h *RESTHandler) GetTopicState(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	topicName := mux.Vars(r)["topic"]
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, topicName) {
+		return
+	}
+
+	state, err := h.hub.GetState(topicName)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TopicState{Topic: topicName, State: state})
+}
+
+// GetStateKey returns one key of a topic's state
+// @Summary Get topic state key
+// @Description Get one key of a topic's state
+// @Tags state
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param key path string true "State key"
+// @Success 200 {object} pubsub.StateEntry "State entry"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic or key does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/state/{key} [get]
+func (h *RESTHandler) GetStateKey(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if !h.authorizeTopic(w, r, auth.PermSubscribe, vars["topic"]) {
+		return
+	}
+
+	entry, err := h.hub.GetStateKey(vars["topic"], vars["key"])
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// PutStateKey sets one key of a topic's state
+// @Summary Set topic state key
+// @Description Set a key of a topic's state to any JSON value, up to 64 KiB, and send the change to the topic's subscribers in a state_update frame. A null value deletes the key. A topic holds up to 1000 keys. State set over REST is never ephemeral.
+// @Tags state
+// @Accept json
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param key path string true "State key"
+// @Param value body object true "JSON value"
+// @Success 200 {object} pubsub.StateUpdate "Change sent to subscribers"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid JSON, key or value, a reserved topic, or the topic's state is full"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/state/{key} [put]
+func (h *RESTHandler) PutStateKey(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if !h.authorizeTopic(w, r, auth.PermPublish, vars["topic"]) {
+		return
+	}
+
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, pubsub.MaxStateValueSize))
+	if err != nil || !json.Valid(value) {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, "Invalid JSON"))
+		return
+	}
+
+	h.setStateKey(w, vars["topic"], vars["key"], value)
+}
+
+// DeleteStateKey deletes one key of a topic's state
+// @Summary Delete topic state key
+// @Description Delete a key of a topic's state and send the deletion to the topic's subscribers in a state_update frame. Deleting a missing key succeeds.
+// @Tags state
+// @Produce json
+// @Param topic path string true "Topic name"
+// @Param key path string true "State key"
+// @Success 200 {object} pubsub.StateUpdate "Change sent to subscribers"
+// @Failure 400 {object} pubsub.ErrorData "Bad request - invalid key or a reserved topic"
+// @Failure 401 {object} pubsub.ErrorData "Unauthorized - invalid or missing API key"
+// @Failure 403 {object} pubsub.ErrorData "Forbidden - not permitted by the ACL"
+// @Failure 404 {object} pubsub.ErrorData "Not found - topic does not exist"
+// @Security ApiKeyAuth
+// @Router /topics/{topic}/state/{key} [delete]
+func (h *RESTHandler) DeleteStateKey(w http.ResponseWriter, r *http.Request) {
+	// Check authentication
+	if !h.authenticateRequest(r) {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	if !h.authorizeTopic(w, r, auth.PermPublish, vars["topic"]) {
+		return
+	}
+
+	h.setStateKey(w, vars["topic"], vars["key"], nil)
+}
+
+// setStateKey sets a state key, or deletes it for a nil value, and responds
+// with the change
+func (h *RESTHandler) setStateKey(w http.ResponseWriter, topicName, key string, value json.RawMessage) {
+	update, err := h.hub.SetState(topicName, key, value)
+	if err != nil {
+		writeError(w, pubsub.ErrorFrom(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTopicState(t *testing.T) {
+	hub := pubsub.NewHub()
+	hub.CreateTopic("doc")
+
+	cfg := config.NewTestConfigWithAPIKey("test-key")
+	handler := NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	call := func(handle http.HandlerFunc, method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/topics/doc/state/"+key, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "test-key")
+		req = mux.SetURLVars(req, map[string]string{"topic": "doc", "key": key})
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+
+	if w := call(handler.PutStateKey, "PUT", "lock", `{"holder":`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid JSON, got %d", w.Code)
+	}
+	w := call(handler.PutStateKey, "PUT", "lock", `{"holder":"ana"}`)
+	var update pubsub.StateUpdate
+	if err := json.Unmarshal(w.Body.Bytes(), &update); w.Code != http.StatusOK || err != nil || update.Key != "lock" {
+		t.Fatalf("Expected the lock set, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = call(handler.GetTopicState, "GET", "", "")
+	var state TopicState
+	if err := json.Unmarshal(w.Body.Bytes(), &state); w.Code != http.StatusOK || err != nil || string(state.State["lock"].Value) != `{"holder":"ana"}` {
+		t.Errorf("Expected the topic state, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := call(handler.DeleteStateKey, "DELETE", "lock", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 deleting the lock, got %d", w.Code)
+	}
+	w = call(handler.GetStateKey, "GET", "lock", "")
+	var errData pubsub.ErrorData
+	json.Unmarshal(w.Body.Bytes(), &errData)
+	if w.Code != http.StatusNotFound || errData.Code != pubsub.CodeStateNotFound {
+		t.Errorf("Expected 404 STATE_NOT_FOUND for a deleted key, got %d: %s", w.Code, w.Body.String())
+	}
+}
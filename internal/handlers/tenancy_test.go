@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func newNamespaceTestHandler() *RESTHandler {
+	cfg := config.NewTestConfig()
+	cfg.Security.APIKey = "shared"
+	cfg.Security.AdminKey = "admin"
+	cfg.Security.TenantKeys = "payments=pay-key,search=search-key"
+	cfg.Security.TenantNamespaces = true
+	return NewRESTHandler(pubsub.NewHub(), cfg, auth.MustNewService(cfg.Security))
+}
+
+func TestNamespacedTopicAccess(t *testing.T) {
+	handler := newNamespaceTestHandler()
+
+	create := func(key, name string) int {
+		req := httptest.NewRequest("POST", "/topics", strings.NewReader(`{"name":"`+name+`"}`))
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler.CreateTopic(w, req)
+		return w.Code
+	}
+	if code := create("pay-key", "payments/orders"); code != http.StatusCreated {
+		t.Fatalf("Expected status 201 in the tenant's namespace, got %d", code)
+	}
+	if code := create("search-key", "search/queries"); code != http.StatusCreated {
+		t.Fatalf("Expected status 201 in the tenant's namespace, got %d", code)
+	}
+	for _, name := range []string{"orders", "search/orders"} {
+		if code := create("pay-key", name); code != http.StatusForbidden {
+			t.Errorf("Expected status 403 creating %s outside the namespace, got %d", name, code)
+		}
+	}
+	if code := create("shared", "orders"); code != http.StatusCreated {
+		t.Errorf("Expected shared-key callers to be unconfined, got %d", code)
+	}
+
+	get := func(key, topic string) int {
+		req := httptest.NewRequest("GET", "/topics/"+topic, nil)
+		req.Header.Set("X-API-Key", key)
+		req = mux.SetURLVars(req, map[string]string{"topic": topic})
+		w := httptest.NewRecorder()
+		handler.GetTopic(w, req)
+		return w.Code
+	}
+	if code := get("pay-key", "payments/orders"); code != http.StatusOK {
+		t.Errorf("Expected status 200 for the tenant's own topic, got %d", code)
+	}
+	if code := get("pay-key", "search/queries"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another tenant's topic, got %d", code)
+	}
+}
+
+func TestNamespacedListsAndStats(t *testing.T) {
+	handler := newNamespaceTestHandler()
+	for _, name := range []string{"payments/orders", "search/queries", "orders"} {
+		handler.hub.CreateTopicWithOptions(name, pubsub.TopicOptions{Owner: auth.Namespace(name)})
+	}
+
+	list := func(key string) []string {
+		req := httptest.NewRequest("GET", "/topics", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		handler.ListTopics(w, req)
+		var resp ListTopicsResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		names := make([]string, 0, len(resp.Topics))
+		for _, topic := range resp.Topics {
+			names = append(names, topic.Name)
+		}
+		return names
+	}
+	if names := list("pay-key"); len(names) != 1 || names[0] != "payments/orders" {
+		t.Errorf("Expected only the tenant's namespace, got %v", names)
+	}
+	if names := list("shared"); len(names) != 3 {
+		t.Errorf("Expected shared-key callers to see every topic, got %v", names)
+	}
+
+	req := httptest.NewRequest("GET", "/stats", nil)
+	req.Header.Set("X-API-Key", "pay-key")
+	w := httptest.NewRecorder()
+	handler.Stats(w, req)
+	var stats StatsResponse
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if _, ok := stats.Topics["payments/orders"]; !ok || len(stats.Topics) != 1 {
+		t.Errorf("Expected only the tenant's topics in stats, got %v", stats.Topics)
+	}
+	if stats.Tenant == nil || stats.Tenant.Tenant != "payments" || stats.Tenant.Topics != 1 {
+		t.Errorf("Expected the tenant's usage, got %+v", stats.Tenant)
+	}
+	if stats.Retention != nil {
+		t.Errorf("Expected no server-wide retention for a tenant, got %+v", stats.Retention)
+	}
+}
+
+func TestNamespacedShadowTopic(t *testing.T) {
+	handler := newNamespaceTestHandler()
+
+	create := func(body string) int {
+		req := httptest.NewRequest("POST", "/topics", strings.NewReader(body))
+		req.Header.Set("X-API-Key", "pay-key")
+		w := httptest.NewRecorder()
+		handler.CreateTopic(w, req)
+		return w.Code
+	}
+	if code := create(`{"name": "payments/orders", "shadow": {"topic": "search/orders", "percent": 5}}`); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 shadowing into another namespace, got %d", code)
+	}
+	if code := create(`{"name": "payments/orders", "shadow": {"topic": "payments/canary", "percent": 5}}`); code != http.StatusCreated {
+		t.Errorf("Expected status 201 shadowing within the namespace, got %d", code)
+	}
+
+	req := httptest.NewRequest("PATCH", "/topics/payments/orders", strings.NewReader(`{"shadow": {"topic": "search/orders", "percent": 5}}`))
+	req.Header.Set("X-API-Key", "pay-key")
+	req = mux.SetURLVars(req, map[string]string{"topic": "payments/orders"})
+	w := httptest.NewRecorder()
+	handler.UpdateTopic(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 moving the shadow into another namespace, got %d", w.Code)
+	}
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
-	"log"
+	"fmt"
 	"net/http"
+	"plivo/internal/auth"
 	"plivo/internal/config"
+	"plivo/internal/logging"
 	"plivo/internal/pubsub"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -12,16 +17,36 @@ import (
 
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub *pubsub.Hub
-	cfg *config.Config
+	hub        *pubsub.Hub
+	cfg        *config.Config
+	clientOpts pubsub.ClientOptions
+	// publishRate caps publishes of clients that connect from now on
+	publishRate atomic.Pointer[pubsub.RateLimit]
+	auth        *auth.Service
+	origins     originPolicy
 }
 
-// NewWebSocketHandler creates a new WebSocket handler
-func NewWebSocketHandler(hub *pubsub.Hub, cfg *config.Config) *WebSocketHandler {
-	return &WebSocketHandler{
-		hub: hub,
-		cfg: cfg,
+// NewWebSocketHandler creates a new WebSocket handler. It panics if any
+// dependency is missing.
+func NewWebSocketHandler(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) *WebSocketHandler {
+	if hub == nil || cfg == nil || authService == nil {
+		panic("handlers: NewWebSocketHandler requires a hub, config and auth service")
 	}
+	h := &WebSocketHandler{
+		hub:        hub,
+		cfg:        cfg,
+		clientOpts: pubsub.NewClientOptions(cfg.PubSub),
+		auth:       authService,
+		origins:    newOriginPolicy(cfg.Security.AllowedOrigins),
+	}
+	h.SetPublishRate(pubsub.NewRateLimit(cfg.Security))
+	return h
+}
+
+// SetPublishRate changes the publish rate limit of clients that connect
+// from now on; connected clients keep theirs
+func (h *WebSocketHandler) SetPublishRate(limit pubsub.RateLimit) {
+	h.publishRate.Store(&limit)
 }
 
 // getUpgrader returns a websocket upgrader with CORS configuration
@@ -31,45 +56,115 @@ func (h *WebSocketHandler) getUpgrader() websocket.Upgrader {
 			if !h.cfg.Security.EnableCORS {
 				return true // Allow all origins when CORS is disabled
 			}
-			// TODO: Implement proper origin checking based on AllowedOrigins
-			return true
+			// Non-browser clients send no Origin, and pages served by the
+			// broker itself are always allowed
+			origin := r.Header.Get("Origin")
+			return origin == "" || sameOrigin(r) || h.origins.allows(origin)
 		},
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		// Clients asking for no subprotocol get the native frames
+		Subprotocols: []string{pubsub.JSONRPCSubprotocol},
 	}
 }
 
 // HandleWebSocket handles WebSocket connections
 func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Check authentication if API key is set
-	if !h.authenticateRequest(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	tenant, ok := h.authenticateTenant(r)
+	if !ok {
+		writeError(w, pubsub.NewError(pubsub.CodeUnauthorized, "Unauthorized"))
+		return
+	}
+
+	attrs, err := connectionAttributes(r)
+	if err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, err.Error()))
+		return
+	}
+
+	encoding, err := requestedEncoding(r)
+	if err != nil {
+		writeError(w, pubsub.NewError(pubsub.CodeBadRequest, err.Error()))
 		return
 	}
 
 	upgrader := h.getUpgrader()
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logging.FromContext(r.Context()).Warn("WebSocket upgrade failed", "error", err)
 		return
 	}
 
 	clientID := uuid.New().String()
-	client := pubsub.NewClient(h.hub, conn, clientID)
-	h.hub.Register <- client
+	clientOpts := h.clientOpts
+	clientOpts.PublishRate = *h.publishRate.Load()
+	client := pubsub.NewClient(h.hub, conn, clientID, clientOpts)
+	client.SetAttributes(attrs)
+	if conn.Subprotocol() == pubsub.JSONRPCSubprotocol {
+		client.SetJSONRPC()
+	}
+	client.SetEncoding(encoding)
+	if !authenticateAdmin(h.auth, r) {
+		client.SetAuthorizer(func(permission auth.Permission, topic string) bool {
+			return h.auth.Authorize(tenant, permission, topic)
+		})
+	}
+	if err := h.hub.RegisterClient(client); err != nil {
+		client.Reject(pubsub.CodeServerShuttingDown)
+		return
+	}
 
 	go client.WritePump()
 	go client.ReadPump()
 }
 
-// authenticateRequest checks X-API-Key header
+// authenticateRequest checks the X-API-Key header, or the api_key query
+// parameter for browsers, which cannot set headers on WebSocket handshakes,
+// against the API key and the tenant keys, or the caller's verified client
+// certificate
 func (h *WebSocketHandler) authenticateRequest(r *http.Request) bool {
-	apiKey := h.cfg.Security.APIKey
-	if apiKey == "" {
-		// No API key set, allow all requests
-		return true
+	_, ok := h.authenticateTenant(r)
+	return ok
+}
+
+// authenticateTenant authenticates the request like authenticateRequest and
+// returns the caller's tenant ("" for the shared key)
+func (h *WebSocketHandler) authenticateTenant(r *http.Request) (string, bool) {
+	return authenticateCaller(h.auth, r, true)
+}
+
+// requestedEncoding reads the wire encoding from the handshake's encoding
+// query parameter. JSON-RPC framing is JSON by definition, so it can't be
+// combined with another encoding.
+func requestedEncoding(r *http.Request) (string, error) {
+	encoding := r.URL.Query().Get("encoding")
+	if err := pubsub.CheckEncoding(encoding); err != nil {
+		return "", err
+	}
+	if encoding != "" && encoding != pubsub.EncodingJSON && slices.Contains(websocket.Subprotocols(r), pubsub.JSONRPCSubprotocol) {
+		return "", fmt.Errorf("the %s subprotocol requires the %s encoding", pubsub.JSONRPCSubprotocol, pubsub.EncodingJSON)
 	}
+	return encoding, nil
+}
 
-	providedKey := r.Header.Get("X-API-Key")
-	return providedKey == apiKey
+// attributeParamPrefix starts the handshake query parameters that set
+// connection attributes, as in /ws?attr.region=eu&attr.user_id=123
+const attributeParamPrefix = "attr."
+
+// connectionAttributes reads the connection attributes from the handshake's
+// attr.<name> query parameters
+func connectionAttributes(r *http.Request) (map[string]string, error) {
+	var attrs map[string]string
+	for param, values := range r.URL.Query() {
+		name, ok := strings.CutPrefix(param, attributeParamPrefix)
+		if !ok {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[name] = values[len(values)-1]
+	}
+	return attrs, pubsub.ValidateAttributes(attrs)
 }
@@ -1,17 +1,24 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"plivo/internal/auth"
 	"plivo/internal/config"
 	"plivo/internal/pubsub"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestNewWebSocketHandler(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	if handler == nil {
 		t.Fatal("NewWebSocketHandler() returned nil")
@@ -29,7 +36,7 @@ func TestNewWebSocketHandler(t *testing.T) {
 func TestWebSocketAuthentication(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// API key is set in the config
 
@@ -70,7 +77,7 @@ func TestWebSocketAuthentication(t *testing.T) {
 func TestWebSocketNoAuthenticationWhenKeyNotSet(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig() // No API key set
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Test request without API key should not return 401
 	req := httptest.NewRequest("GET", "/ws", nil)
@@ -86,7 +93,7 @@ func TestWebSocketNoAuthenticationWhenKeyNotSet(t *testing.T) {
 func TestWebSocketUpgrader(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfig()
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Test that upgrader is configured correctly
 	upgrader := handler.getUpgrader()
@@ -107,7 +114,7 @@ func TestWebSocketUpgrader(t *testing.T) {
 func TestWebSocketHandlerIntegration(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Start hub in background
 	go hub.Run()
@@ -129,7 +136,7 @@ func TestAuthenticationFunction(t *testing.T) {
 
 	// Test with no API key set
 	cfg := config.NewTestConfig() // No API key
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 	req := httptest.NewRequest("GET", "/ws", nil)
 
 	if !handler.authenticateRequest(req) {
@@ -138,7 +145,7 @@ func TestAuthenticationFunction(t *testing.T) {
 
 	// Test with API key set but no header
 	cfgWithKey := config.NewTestConfigWithAPIKey("test-key")
-	handlerWithKey := NewWebSocketHandler(hub, cfgWithKey)
+	handlerWithKey := NewWebSocketHandler(hub, cfgWithKey, auth.MustNewService(cfgWithKey.Security))
 
 	if handlerWithKey.authenticateRequest(req) {
 		t.Error("Should not authenticate when API key is set but no header provided")
@@ -157,12 +164,25 @@ func TestAuthenticationFunction(t *testing.T) {
 	if handlerWithKey.authenticateRequest(req) {
 		t.Error("Should not authenticate with incorrect API key")
 	}
+
+	// Test with the key in the query string, as browsers send it
+	req = httptest.NewRequest("GET", "/ws?api_key=test-key", nil)
+
+	if !handlerWithKey.authenticateRequest(req) {
+		t.Error("Should authenticate with correct api_key query parameter")
+	}
+
+	req = httptest.NewRequest("GET", "/ws?api_key=wrong-key", nil)
+
+	if handlerWithKey.authenticateRequest(req) {
+		t.Error("Should not authenticate with incorrect api_key query parameter")
+	}
 }
 
 func TestWebSocketHandlerConcurrency(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Start hub in background
 	go hub.Run()
@@ -192,7 +212,7 @@ func TestWebSocketHandlerConcurrency(t *testing.T) {
 func TestWebSocketHandlerWithDifferentOrigins(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Test with different origins
 	origins := []string{
@@ -217,7 +237,7 @@ func TestWebSocketHandlerWithDifferentOrigins(t *testing.T) {
 func TestWebSocketHandlerErrorHandling(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Test with malformed request
 	req := httptest.NewRequest("POST", "/ws", nil) // Wrong method
@@ -231,7 +251,7 @@ func TestWebSocketHandlerErrorHandling(t *testing.T) {
 func TestWebSocketHandlerWithHeaders(t *testing.T) {
 	hub := pubsub.NewHub()
 	cfg := config.NewTestConfigWithAPIKey("test-key")
-	handler := NewWebSocketHandler(hub, cfg)
+	handler := NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
 
 	// Test with various headers
 	req := httptest.NewRequest("GET", "/ws", nil)
@@ -246,3 +266,330 @@ func TestWebSocketHandlerWithHeaders(t *testing.T) {
 
 	// Should handle gracefully
 }
+
+func TestWebSocketDisconnectReleasesPumps(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	baseline := runtime.NumGoroutine()
+
+	const clients = 5
+	conns := make([]*websocket.Conn, 0, clients)
+	for i := 0; i < clients; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		// Wait for the welcome frame so the client is registered
+		var welcome pubsub.ServerMessage
+		if err := conn.ReadJSON(&welcome); err != nil {
+			t.Fatalf("Failed to read welcome: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+
+	if stats := hub.GetRuntimeStats(); stats.Clients != clients || stats.ActivePumps != 2*clients {
+		t.Errorf("Expected %d clients with 2 pumps each, got %+v", clients, stats)
+	}
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := hub.GetRuntimeStats()
+		if stats.Clients == 0 && stats.ActivePumps == 0 && stats.DepartingClients == 0 && stats.Goroutines <= baseline {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Client teardown did not complete: %+v (baseline %d goroutines)", stats, baseline)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWebSocketPumpsExitAfterHubShutdown(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+
+	cfg := config.NewTestConfig()
+
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var welcome pubsub.ServerMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("Failed to read welcome: %v", err)
+	}
+
+	// The hub stops receiving unregistrations; pumps must not block on it
+	hub.Shutdown()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.GetRuntimeStats().ActivePumps != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Pumps still running after shutdown: %+v", hub.GetRuntimeStats())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWebSocketRejectedDuringShutdown(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+
+	closeErr, ok := err.(*websocket.CloseError)
+	if !ok {
+		t.Fatalf("Expected a close frame, got %v", err)
+	}
+	if closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "SERVER_SHUTTING_DOWN" {
+		t.Errorf("Expected going away with SERVER_SHUTTING_DOWN, got %d %q", closeErr.Code, closeErr.Text)
+	}
+
+	if stats := hub.GetRuntimeStats(); stats.ActivePumps != 0 || stats.Clients != 0 {
+		t.Errorf("Expected no pumps or clients for a rejected connection, got %+v", stats)
+	}
+}
+
+func TestWebSocketConnectionAttributes(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	cfg := config.NewTestConfig()
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?attr.region=eu&attr.user_id=123", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var welcome pubsub.ServerMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("Failed to read welcome frame: %v", err)
+	}
+	info, err := hub.GetClient(strings.TrimPrefix(welcome.Msg, "welcome "))
+	if err != nil {
+		t.Fatalf("Failed to get client: %v", err)
+	}
+	if len(info.Attributes) != 2 || info.Attributes["region"] != "eu" || info.Attributes["user_id"] != "123" {
+		t.Errorf("Expected region and user_id attributes, got %v", info.Attributes)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"?attr.user%20id=123", nil)
+	if err == nil {
+		t.Fatal("Expected an invalid attribute name to be refused")
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketJSONRPC(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	cfg := config.NewTestConfig()
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{pubsub.JSONRPCSubprotocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != pubsub.JSONRPCSubprotocol {
+		t.Fatalf("Expected the %s subprotocol, got %q", pubsub.JSONRPCSubprotocol, conn.Subprotocol())
+	}
+
+	type frame struct {
+		JSONRPC string                 `json:"jsonrpc"`
+		ID      interface{}            `json:"id"`
+		Method  string                 `json:"method"`
+		Params  pubsub.ServerMessage   `json:"params"`
+		Result  map[string]interface{} `json:"result"`
+		Error   *struct {
+			Code int               `json:"code"`
+			Data *pubsub.ErrorData `json:"data"`
+		} `json:"error"`
+	}
+	read := func() frame {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		var f frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		if f.JSONRPC != "2.0" {
+			t.Fatalf("Expected a JSON-RPC 2.0 frame, got %+v", f)
+		}
+		return f
+	}
+	call := func(request string) frame {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+		return read()
+	}
+
+	if welcome := read(); welcome.Method != "info" || !strings.HasPrefix(welcome.Params.Msg, "welcome ") {
+		t.Fatalf("Expected the welcome info notification, got %+v", welcome)
+	}
+
+	if resp := call(`{"jsonrpc": "2.0", "id": 1, "method": "subscribe", "params": {"topic": "orders", "client_id": "rpc"}}`); resp.ID != float64(1) || resp.Result["status"] != "ok" {
+		t.Fatalf("Expected the subscribe acknowledged, got %+v", resp)
+	}
+
+	// The publish response and the event may arrive in either order
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": "p1", "method": "publish", "params": {"topic": "orders", "message": {"id": "msg-1", "payload": "hi"}}}`))
+	for seen := 0; seen < 2; seen++ {
+		switch f := read(); {
+		case f.ID == "p1" && f.Result["message_id"] == "msg-1":
+		case f.Method == "event" && f.Params.Topic == "orders" && f.Params.Message.ID == "msg-1":
+		default:
+			t.Fatalf("Expected the publish response and event, got %+v", f)
+		}
+	}
+
+	if resp := call(`{"jsonrpc": "2.0", "id": 2, "method": "topics.list"}`); len(resp.Result["topics"].([]interface{})) != 2 {
+		t.Errorf("Expected the topic and the client's inbox listed, got %+v", resp)
+	}
+	// The subscriber hears of the change before the setter's response
+	if update := call(`{"jsonrpc": "2.0", "id": "s1", "method": "state_set", "params": {"topic": "orders", "key": "lock", "value": "rpc"}}`); update.Method != "state_update" || update.Params.StateUpdate.Key != "lock" {
+		t.Fatalf("Expected the state_update notification, got %+v", update)
+	}
+	if resp := read(); resp.ID != "s1" || resp.Result["status"] != "ok" {
+		t.Fatalf("Expected the state_set acknowledged, got %+v", resp)
+	}
+	if resp := call(`{"jsonrpc": "2.0", "id": "s2", "method": "state_get", "params": {"topic": "orders"}}`); resp.ID != "s2" || resp.Result["state"] == nil {
+		t.Errorf("Expected the state as the state_get result, got %+v", resp)
+	}
+	if resp := call(`{"jsonrpc": "2.0", "id": 3, "method": "subscribe", "params": {"topic": "orders"}}`); resp.ID != float64(3) || resp.Error == nil || resp.Error.Code != -32000 || resp.Error.Data == nil {
+		t.Errorf("Expected a broker error response for a subscribe without a client ID, got %+v", resp)
+	}
+	if resp := call(`{"jsonrpc": "2.0", "id": 4, "method": "rename"}`); resp.Error == nil || resp.Error.Code != -32601 {
+		t.Errorf("Expected method not found, got %+v", resp)
+	}
+	if resp := call(`{not json`); resp.ID != nil || resp.Error == nil || resp.Error.Code != -32700 {
+		t.Errorf("Expected a parse error with a null ID, got %+v", resp)
+	}
+}
+
+func TestWebSocketMsgpackEncoding(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	cfg := config.NewTestConfig()
+	server := httptest.NewServer(http.HandlerFunc(NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	for _, rejected := range []string{"protobuf", "xml"} {
+		if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?encoding="+rejected, nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected encoding %s refused with 400, got %v", rejected, err)
+		}
+	}
+	dialer := websocket.Dialer{Subprotocols: []string{pubsub.JSONRPCSubprotocol}}
+	if _, resp, err := dialer.Dial(wsURL+"?encoding=msgpack", nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected JSON-RPC over MessagePack refused with 400, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?encoding=msgpack", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	read := func() pubsub.ServerMessage {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read frame: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected a binary frame, got type %d", messageType)
+		}
+		decoded, err := pubsub.MsgpackToJSON(data)
+		if err != nil {
+			t.Fatalf("Failed to decode frame: %v", err)
+		}
+		var msg pubsub.ServerMessage
+		if err := json.Unmarshal(decoded, &msg); err != nil {
+			t.Fatalf("Failed to unmarshal frame: %v", err)
+		}
+		return msg
+	}
+	send := func(frame string) {
+		t.Helper()
+		encoded, err := pubsub.JSONToMsgpack([]byte(frame))
+		if err != nil {
+			t.Fatalf("Failed to encode frame: %v", err)
+		}
+		if err := conn.WriteMessage(websocket.BinaryMessage, encoded); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+
+	if welcome := read(); welcome.Type != pubsub.InfoMessage {
+		t.Fatalf("Expected the welcome info frame, got %+v", welcome)
+	}
+	send(`{"type": "subscribe", "topic": "orders", "client_id": "packed", "request_id": "s1"}`)
+	if ack := read(); ack.Type != pubsub.AckMessage || ack.RequestID != "s1" {
+		t.Fatalf("Expected the subscribe ack, got %+v", ack)
+	}
+
+	// Text frames are still read as JSON
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "publish", "topic": "orders", "request_id": "p1", "message": {"id": "msg-1", "payload": {"qty": 3}}}`))
+	for seen := 0; seen < 2; seen++ {
+		switch msg := read(); {
+		case msg.Type == pubsub.AckMessage && msg.RequestID == "p1":
+		case msg.Type == pubsub.EventMessage && msg.Message.ID == "msg-1" && msg.Message.Payload.(map[string]interface{})["qty"] == float64(3):
+		default:
+			t.Fatalf("Expected the publish ack and event, got %+v", msg)
+		}
+	}
+
+	conn.WriteMessage(websocket.BinaryMessage, []byte{0xc1})
+	if msg := read(); msg.Type != pubsub.ErrorMessage || msg.Error.Code != pubsub.CodeBadRequest {
+		t.Errorf("Expected BAD_REQUEST for invalid MessagePack, got %+v", msg)
+	}
+}
@@ -0,0 +1,104 @@
+// Package logging builds the broker's structured logger from its logging
+// configuration and carries request-scoped loggers through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"plivo/internal/config"
+)
+
+// Contextual field names shared by every log record that carries them
+const (
+	ClientID  = "client_id"
+	Topic     = "topic"
+	RequestID = "request_id"
+)
+
+// ParseLevel parses a configured level: debug, info, warn or error
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("log level must be debug, info, warn or error, got %q", level)
+}
+
+// level is the default logger's level, which SetLevel changes while the
+// broker runs
+var level slog.LevelVar
+
+// New returns a logger writing records at or above the configured level to
+// w, as logfmt-style text or one JSON object per line
+func New(w io.Writer, cfg config.LoggingConfig) (*slog.Logger, error) {
+	parsed, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	return newLogger(w, cfg.Format, parsed)
+}
+
+// newLogger returns a logger writing records at or above level to w in the
+// named format
+func newLogger(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("log format must be text or json, got %q", format)
+}
+
+// Setup makes the configured logger, writing to stderr, the default for
+// slog and for the standard log package
+func Setup(cfg config.LoggingConfig) error {
+	parsed, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	logger, err := newLogger(os.Stderr, cfg.Format, &level)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	slog.SetDefault(logger)
+	return nil
+}
+
+// SetLevel changes the level of the logger Setup made, without replacing it
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"plivo/internal/config"
+)
+
+func TestNewHonorsLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, config.LoggingConfig{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	logger.Info("dropped")
+	logger.Warn("kept", Topic, "orders")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record[Topic] != "orders" {
+		t.Errorf("Expected the warning with its topic, got %v", record)
+	}
+
+	buf.Reset()
+	logger, _ = New(&buf, config.LoggingConfig{Level: "debug", Format: "text"})
+	logger.Debug("detail", ClientID, "client-1")
+	if !strings.Contains(buf.String(), "msg=detail client_id=client-1") {
+		t.Errorf("Expected a text debug record, got %q", buf.String())
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	for _, cfg := range []config.LoggingConfig{{Level: "verbose"}, {Level: "info", Format: "xml"}} {
+		if _, err := New(&bytes.Buffer{}, cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger without one in the context")
+	}
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	if FromContext(WithLogger(context.Background(), logger)) != logger {
+		t.Error("Expected the context's logger")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := newLogger(&buf, "text", &level)
+	defer level.Set(slog.LevelInfo)
+
+	if err := SetLevel("error"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	logger.Warn("dropped")
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel failed: %v", err)
+	}
+	logger.Debug("kept")
+	if strings.Contains(buf.String(), "dropped") || !strings.Contains(buf.String(), "kept") {
+		t.Errorf("Expected the logger to follow the level, got %q", buf.String())
+	}
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
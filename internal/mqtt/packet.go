@@ -0,0 +1,310 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+// CONNACK return codes
+const (
+	connackAccepted          byte = 0x00
+	connackBadProtocol       byte = 0x01
+	connackIdentifierInvalid byte = 0x02
+	connackBadCredentials    byte = 0x04
+	connackNotAuthorized     byte = 0x05
+)
+
+// subackFailure is the SUBACK return code of a refused subscription
+const subackFailure byte = 0x80
+
+// protocolLevel is MQTT 3.1.1's protocol level
+const protocolLevel = 4
+
+// maxRemainingLength is the largest remaining length the four-byte
+// encoding can represent
+const maxRemainingLength = 268435455
+
+var (
+	errMalformed       = errors.New("malformed packet")
+	errPacketTooLarge  = errors.New("packet exceeds the size limit")
+	errUnexpectedFlags = errors.New("invalid fixed header flags")
+)
+
+// packet is a control packet: the fixed header's type and flags, and the
+// variable header and payload that follow it
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads a control packet, refusing bodies over maxSize bytes
+func readPacket(r *bufio.Reader, maxSize int) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxSize {
+		return nil, errPacketTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// writePacket writes a control packet
+func writePacket(w io.Writer, kind, flags byte, body []byte) error {
+	if len(body) > maxRemainingLength {
+		return errPacketTooLarge
+	}
+
+	buf := make([]byte, 0, len(body)+5)
+	buf = append(buf, kind<<4|flags)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	buf = append(buf, body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// decoder reads the fields of a packet body
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) < 1 {
+		d.err = errMalformed
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.buf) < 2 {
+		d.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.buf)
+	d.buf = d.buf[2:]
+	return v
+}
+
+// bytes reads a length-prefixed byte string
+func (d *decoder) bytes() []byte {
+	n := int(d.uint16())
+	if d.err != nil || len(d.buf) < n {
+		d.err = errMalformed
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// rest returns the unread remainder of the body
+func (d *decoder) rest() []byte {
+	b := d.buf
+	d.buf = nil
+	return b
+}
+
+// appendString appends a length-prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// connectPacket is a decoded CONNECT
+type connectPacket struct {
+	protocolName  string
+	protocolLevel byte
+	cleanSession  bool
+	keepAlive     uint16
+	clientID      string
+	willTopic     string
+	willMessage   []byte
+	username      string
+	password      string
+}
+
+// CONNECT flags
+const (
+	connectCleanSession = 0x02
+	connectWill         = 0x04
+	connectWillQoS      = 0x18
+	connectWillRetain   = 0x20
+	connectPassword     = 0x40
+	connectUsername     = 0x80
+)
+
+func decodeConnect(p *packet) (*connectPacket, error) {
+	if p.flags != 0 {
+		return nil, errUnexpectedFlags
+	}
+	d := &decoder{buf: p.body}
+	c := &connectPacket{
+		protocolName:  d.string(),
+		protocolLevel: d.byte(),
+	}
+	flags := d.byte()
+	c.keepAlive = d.uint16()
+	c.cleanSession = flags&connectCleanSession != 0
+	c.clientID = d.string()
+	if flags&connectWill != 0 {
+		c.willTopic = d.string()
+		c.willMessage = d.bytes()
+	} else if flags&(connectWillQoS|connectWillRetain) != 0 {
+		return nil, errMalformed
+	}
+	if flags&connectUsername != 0 {
+		c.username = d.string()
+	}
+	if flags&connectPassword != 0 {
+		c.password = d.string()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	// The reserved flag must be zero
+	if flags&0x01 != 0 {
+		return nil, errMalformed
+	}
+	return c, nil
+}
+
+// publishPacket is a decoded PUBLISH
+type publishPacket struct {
+	topic    string
+	qos      byte
+	retain   bool
+	packetID uint16
+	payload  []byte
+}
+
+func decodePublish(p *packet) (*publishPacket, error) {
+	pub := &publishPacket{
+		qos:    p.flags >> 1 & 0x03,
+		retain: p.flags&0x01 != 0,
+	}
+	if pub.qos == 3 {
+		return nil, errUnexpectedFlags
+	}
+	d := &decoder{buf: p.body}
+	pub.topic = d.string()
+	if pub.qos > 0 {
+		pub.packetID = d.uint16()
+	}
+	pub.payload = d.rest()
+	if d.err != nil {
+		return nil, d.err
+	}
+	return pub, nil
+}
+
+// encodePublish encodes a QoS 0 PUBLISH body
+func encodePublish(topic string, payload []byte) []byte {
+	buf := make([]byte, 0, 2+len(topic)+len(payload))
+	buf = appendString(buf, topic)
+	return append(buf, payload...)
+}
+
+// subscription is a topic filter and requested QoS from a SUBSCRIBE
+type subscription struct {
+	filter string
+	qos    byte
+}
+
+// decodeSubscribe decodes a SUBSCRIBE's packet ID and topic filters
+func decodeSubscribe(p *packet) (uint16, []subscription, error) {
+	if p.flags != 0x02 {
+		return 0, nil, errUnexpectedFlags
+	}
+	d := &decoder{buf: p.body}
+	id := d.uint16()
+	var subs []subscription
+	for d.err == nil && len(d.buf) > 0 {
+		subs = append(subs, subscription{filter: d.string(), qos: d.byte()})
+	}
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	if len(subs) == 0 {
+		return 0, nil, fmt.Errorf("%w: SUBSCRIBE without topic filters", errMalformed)
+	}
+	return id, subs, nil
+}
+
+// decodeUnsubscribe decodes an UNSUBSCRIBE's packet ID and topic filters
+func decodeUnsubscribe(p *packet) (uint16, []string, error) {
+	if p.flags != 0x02 {
+		return 0, nil, errUnexpectedFlags
+	}
+	d := &decoder{buf: p.body}
+	id := d.uint16()
+	var filters []string
+	for d.err == nil && len(d.buf) > 0 {
+		filters = append(filters, d.string())
+	}
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	if len(filters) == 0 {
+		return 0, nil, fmt.Errorf("%w: UNSUBSCRIBE without topic filters", errMalformed)
+	}
+	return id, filters, nil
+}
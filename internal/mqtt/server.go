@@ -0,0 +1,160 @@
+// Package mqtt serves the hub to MQTT 3.1.1 clients, so IoT devices can
+// publish and subscribe without a WebSocket. MQTT topic names are hub topic
+// names; sessions subscribe through hub streams, so they get the same
+// per-client queue and slow-consumer handling as every other subscriber.
+package mqtt
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+)
+
+// connectTimeout bounds how long a new connection may take to send CONNECT
+const connectTimeout = 10 * time.Second
+
+// packetOverhead is the allowance for the topic name and packet ID around a
+// payload when bounding packet sizes
+const packetOverhead = 64 * 1024
+
+// Server accepts MQTT connections and maps them onto a hub
+type Server struct {
+	hub  *pubsub.Hub
+	cfg  *config.Config
+	auth *auth.Service
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	// sessions by client identifier; a client connecting again takes over
+	sessions map[string]*session
+	closed   bool
+}
+
+// NewServer returns an MQTT server for hub. Clients authenticate with an API
+// or tenant key as the CONNECT password. It panics if any dependency is
+// missing.
+func NewServer(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) *Server {
+	if hub == nil || cfg == nil || authService == nil {
+		panic("mqtt: NewServer requires a hub, config and auth service")
+	}
+	return &Server{
+		hub:       hub,
+		cfg:       cfg,
+		auth:      authService,
+		listeners: make(map[net.Listener]bool),
+		sessions:  make(map[string]*session),
+	}
+}
+
+// Serve accepts connections on listener until it fails or the server is
+// closed, in which case it returns nil
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.listeners[listener] = true
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, listener)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting connections and disconnects every session
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	listeners := s.listeners
+	s.listeners = make(map[net.Listener]bool)
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	for listener := range listeners {
+		listener.Close()
+	}
+	for _, sess := range sessions {
+		sess.conn.Close()
+	}
+	return nil
+}
+
+// handle serves one connection, recovering from panics so a malformed
+// client can't take the broker down
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.hub.RecordPanic("mqtt.session", r)
+		}
+		conn.Close()
+	}()
+
+	sess := newSession(s, conn)
+	if err := sess.run(); err != nil {
+		sess.logger().Info("MQTT connection closed", "remote", conn.RemoteAddr().String(), "error", err)
+	}
+}
+
+// attach registers a session under its client identifier, disconnecting any
+// session already using it, as MQTT requires. It returns false once the
+// server is closed.
+func (s *Server) attach(sess *session) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	previous := s.sessions[sess.clientID]
+	s.sessions[sess.clientID] = sess
+	s.mu.Unlock()
+
+	if previous != nil {
+		sess.logger().Info("MQTT client connected again, closing its previous connection")
+		previous.conn.Close()
+	}
+	return true
+}
+
+// detach removes a session unless another has taken over its identifier
+func (s *Server) detach(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions[sess.clientID] == sess {
+		delete(s.sessions, sess.clientID)
+	}
+}
+
+// maxPacketSize bounds incoming packets by the message size limit
+func (s *Server) maxPacketSize() int {
+	limit := s.cfg.PubSub.MaxMessageSize
+	if limit <= 0 || limit > maxRemainingLength-packetOverhead {
+		return maxRemainingLength
+	}
+	return int(limit) + packetOverhead
+}
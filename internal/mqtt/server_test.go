@@ -0,0 +1,257 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/pubsub"
+)
+
+// testClient speaks raw MQTT to a test server
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newTestServer serves hub on a loopback listener and returns its address
+func newTestServer(t *testing.T, hub *pubsub.Hub, cfg *config.Config) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := NewServer(hub, cfg, auth.MustNewService(cfg.Security))
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+// dial connects and sends CONNECT, returning the client and the CONNACK code
+func dial(t *testing.T, addr, clientID, password string) (*testClient, byte) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	flags := byte(connectCleanSession)
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel, 0)
+	body = binary.BigEndian.AppendUint16(body, 30)
+	body = appendString(body, clientID)
+	if password != "" {
+		flags |= connectUsername | connectPassword
+		body = appendString(body, "device")
+		body = appendString(body, password)
+	}
+	body[7] = flags
+	c.send(packetConnect, 0, body)
+
+	p := c.expect(packetConnack)
+	if len(p.body) != 2 {
+		t.Fatalf("Expected 2 byte CONNACK, got %d", len(p.body))
+	}
+	return c, p.body[1]
+}
+
+func (c *testClient) send(kind, flags byte, body []byte) {
+	c.t.Helper()
+	if err := writePacket(c.conn, kind, flags, body); err != nil {
+		c.t.Fatalf("Failed to write packet: %v", err)
+	}
+}
+
+func (c *testClient) expect(kind byte) *packet {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	p, err := readPacket(c.reader, maxRemainingLength)
+	if err != nil {
+		c.t.Fatalf("Failed to read packet type %d: %v", kind, err)
+	}
+	if p.kind != kind {
+		c.t.Fatalf("Expected packet type %d, got %d", kind, p.kind)
+	}
+	return p
+}
+
+// subscribe subscribes to one topic filter and returns the SUBACK code
+func (c *testClient) subscribe(filter string) byte {
+	c.t.Helper()
+	body := binary.BigEndian.AppendUint16(nil, 1)
+	body = appendString(body, filter)
+	body = append(body, 0)
+	c.send(packetSubscribe, 0x02, body)
+
+	p := c.expect(packetSuback)
+	if len(p.body) != 3 {
+		c.t.Fatalf("Expected 3 byte SUBACK, got %d", len(p.body))
+	}
+	return p.body[2]
+}
+
+// waitForSubscribers waits for the hub to register a topic's subscribers
+func waitForSubscribers(t *testing.T, hub *pubsub.Hub, topic string, count int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for hub.GetStats().Topics[topic].SubscriberCount != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d subscribers on %s", count, topic)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("sensors")
+	addr := newTestServer(t, hub, config.NewTestConfig())
+
+	subscriber, code := dial(t, addr, "display", "")
+	if code != connackAccepted {
+		t.Fatalf("Expected CONNACK accepted, got %d", code)
+	}
+	if code := subscriber.subscribe("sensors"); code != 0 {
+		t.Fatalf("Expected QoS 0 granted, got %#x", code)
+	}
+	waitForSubscribers(t, hub, "sensors", 1)
+
+	publisher, _ := dial(t, addr, "thermometer", "")
+	body := appendString(nil, "sensors")
+	body = binary.BigEndian.AppendUint16(body, 7)
+	body = append(body, `{"temp":21.5}`...)
+	publisher.send(packetPublish, 1<<1, body)
+
+	ack := publisher.expect(packetPuback)
+	if id := binary.BigEndian.Uint16(ack.body); id != 7 {
+		t.Errorf("Expected PUBACK for packet 7, got %d", id)
+	}
+
+	event, err := decodePublish(subscriber.expect(packetPublish))
+	if err != nil {
+		t.Fatalf("Failed to decode PUBLISH: %v", err)
+	}
+	if event.topic != "sensors" {
+		t.Errorf("Expected topic sensors, got %s", event.topic)
+	}
+	if event.qos != 0 {
+		t.Errorf("Expected QoS 0 delivery, got %d", event.qos)
+	}
+	if string(event.payload) != `{"temp":21.5}` {
+		t.Errorf("Expected JSON payload, got %s", event.payload)
+	}
+
+	messages := hub.GetRecentMessages("sensors", 10)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 retained message, got %d", len(messages))
+	}
+	if messages[0].Message.ID == "" {
+		t.Error("Expected a generated message ID")
+	}
+}
+
+func TestPayloadContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     []byte
+		contentType string
+	}{
+		{"json", []byte(`[1,2,3]`), ""},
+		{"text", []byte("on"), pubsub.ContentTypeText},
+		{"binary", []byte{0xff, 0x00, 0xfe}, pubsub.ContentTypeBinary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := messageData(tt.payload)
+			if data.ContentType != tt.contentType {
+				t.Errorf("Expected content type %q, got %q", tt.contentType, data.ContentType)
+			}
+			payload, err := payloadBytes(data)
+			if err != nil {
+				t.Fatalf("payloadBytes failed: %v", err)
+			}
+			if string(payload) != string(tt.payload) {
+				t.Errorf("Expected payload %q to round trip, got %q", tt.payload, payload)
+			}
+		})
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	addr := newTestServer(t, hub, config.NewTestConfigWithAPIKey("secret"))
+
+	if _, code := dial(t, addr, "device", "wrong"); code != connackBadCredentials {
+		t.Errorf("Expected bad credentials, got %d", code)
+	}
+	if _, code := dial(t, addr, "device", ""); code != connackNotAuthorized {
+		t.Errorf("Expected not authorized, got %d", code)
+	}
+	if _, code := dial(t, addr, "device", "secret"); code != connackAccepted {
+		t.Errorf("Expected accepted, got %d", code)
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("sensors")
+	addr := newTestServer(t, hub, config.NewTestConfig())
+
+	client, _ := dial(t, addr, "device", "")
+	for _, filter := range []string{"sensors/+", "#", "missing"} {
+		if code := client.subscribe(filter); code != subackFailure {
+			t.Errorf("Expected %s to be refused, got %#x", filter, code)
+		}
+	}
+}
+
+func TestPingAndDisconnect(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("status")
+	addr := newTestServer(t, hub, config.NewTestConfig())
+
+	client, _ := dial(t, addr, "device", "")
+	if code := client.subscribe("status"); code != 0 {
+		t.Fatalf("Expected QoS 0 granted, got %#x", code)
+	}
+	waitForSubscribers(t, hub, "status", 1)
+
+	client.send(packetPingreq, 0, nil)
+	client.expect(packetPingresp)
+
+	client.send(packetDisconnect, 0, nil)
+	waitForSubscribers(t, hub, "status", 0)
+}
+
+func TestTakeover(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	addr := newTestServer(t, hub, config.NewTestConfig())
+
+	first, _ := dial(t, addr, "device", "")
+	dial(t, addr, "device", "")
+
+	first.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := readPacket(first.reader, maxRemainingLength); err == nil {
+		t.Error("Expected the first connection to be closed")
+	}
+}
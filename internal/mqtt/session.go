@@ -0,0 +1,344 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"plivo/internal/auth"
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+
+	"github.com/google/uuid"
+)
+
+// connackServerUnavailable is returned when the hub can't take the session
+const connackServerUnavailable byte = 0x03
+
+// session is one MQTT client connection
+type session struct {
+	server *Server
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// writeMu serializes packets written by the read loop and by delivery
+	writeMu sync.Mutex
+
+	clientID  string
+	keepAlive time.Duration
+	// tenant the CONNECT password belongs to, "" for the shared key
+	tenant string
+	// will is published if the connection ends without DISCONNECT
+	will   *publishPacket
+	stream *pubsub.Stream
+}
+
+func newSession(server *Server, conn net.Conn) *session {
+	return &session{server: server, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// logger returns the default logger with the session's client ID attached
+func (s *session) logger() *slog.Logger {
+	return slog.Default().With(logging.ClientID, s.clientID)
+}
+
+// run performs the CONNECT handshake and serves the session until the
+// client disconnects or the connection fails
+func (s *session) run() error {
+	if err := s.connect(); err != nil {
+		return err
+	}
+	defer s.stream.Close()
+
+	if !s.server.attach(s) {
+		s.connack(connackServerUnavailable)
+		return pubsub.ErrShuttingDown
+	}
+	defer s.server.detach(s)
+
+	if err := s.connack(connackAccepted); err != nil {
+		return err
+	}
+	go s.deliver()
+
+	err := s.serve()
+	if s.will != nil {
+		if err := s.publish(s.will.topic, s.will.payload); err != nil {
+			s.logger().Warn("MQTT will message dropped", logging.Topic, s.will.topic, "error", err)
+		}
+	}
+	return err
+}
+
+// connect reads and checks CONNECT, and opens the session's hub stream.
+// Refused connections are sent a CONNACK saying why.
+func (s *session) connect() error {
+	s.conn.SetReadDeadline(time.Now().Add(connectTimeout))
+	p, err := readPacket(s.reader, s.server.maxPacketSize())
+	if err != nil {
+		return err
+	}
+	if p.kind != packetConnect {
+		return fmt.Errorf("expected CONNECT, got packet type %d", p.kind)
+	}
+	connect, err := decodeConnect(p)
+	if err != nil {
+		return err
+	}
+
+	if connect.protocolName != "MQTT" || connect.protocolLevel != protocolLevel {
+		s.connack(connackBadProtocol)
+		return fmt.Errorf("unsupported protocol %q level %d", connect.protocolName, connect.protocolLevel)
+	}
+	if connect.clientID == "" {
+		if !connect.cleanSession {
+			s.connack(connackIdentifierInvalid)
+			return errors.New("empty client identifier without clean session")
+		}
+		connect.clientID = uuid.New().String()
+	}
+	tenant, ok := s.server.auth.Authenticate(connect.password)
+	if !ok {
+		code := connackBadCredentials
+		if connect.password == "" {
+			code = connackNotAuthorized
+		}
+		s.connack(code)
+		return fmt.Errorf("client %s: unauthorized", connect.clientID)
+	}
+
+	s.clientID = connect.clientID
+	s.tenant = tenant
+	s.keepAlive = time.Duration(connect.keepAlive) * time.Second
+	if connect.willTopic != "" {
+		s.will = &publishPacket{topic: connect.willTopic, payload: connect.willMessage}
+	}
+
+	stream, err := s.server.hub.NewStream(s.clientID, pubsub.NewClientOptions(s.server.cfg.PubSub))
+	if err != nil {
+		s.connack(connackServerUnavailable)
+		return err
+	}
+	s.stream = stream
+	return nil
+}
+
+// serve handles packets until DISCONNECT, which returns nil, or until the
+// connection fails or misses its keep-alive
+func (s *session) serve() error {
+	for {
+		// A client silent for one and a half keep-alive periods is gone
+		deadline := time.Time{}
+		if s.keepAlive > 0 {
+			deadline = time.Now().Add(s.keepAlive * 3 / 2)
+		}
+		s.conn.SetReadDeadline(deadline)
+
+		p, err := readPacket(s.reader, s.server.maxPacketSize())
+		if err != nil {
+			return err
+		}
+
+		switch p.kind {
+		case packetPublish:
+			err = s.handlePublish(p)
+		case packetSubscribe:
+			err = s.handleSubscribe(p)
+		case packetUnsubscribe:
+			err = s.handleUnsubscribe(p)
+		case packetPingreq:
+			err = s.write(packetPingresp, 0, nil)
+		case packetDisconnect:
+			s.will = nil
+			return nil
+		default:
+			err = fmt.Errorf("unexpected packet type %d", p.kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handlePublish publishes to the hub topic of the same name. MQTT 3.1.1 has
+// no way to refuse a publish, so publishes the hub rejects are acknowledged
+// and dropped, and logged.
+func (s *session) handlePublish(p *packet) error {
+	pub, err := decodePublish(p)
+	if err != nil {
+		return err
+	}
+	if pub.qos == 2 {
+		return errors.New("QoS 2 publishes are not supported")
+	}
+
+	if err := s.publish(pub.topic, pub.payload); err != nil {
+		s.logger().Warn("MQTT publish dropped", logging.Topic, pub.topic, "error", err)
+	}
+	if pub.qos == 1 {
+		return s.write(packetPuback, 0, binary.BigEndian.AppendUint16(nil, pub.packetID))
+	}
+	return nil
+}
+
+// publish publishes a payload to a topic the way REST publishes do
+func (s *session) publish(topic string, payload []byte) error {
+	if strings.ContainsAny(topic, "+#") {
+		return errors.New("topic names must not contain wildcards")
+	}
+	if !s.server.auth.Authorize(s.tenant, auth.PermPublish, topic) {
+		return pubsub.NotPermitted(auth.PermPublish, topic)
+	}
+	_, err := s.server.hub.PublishDirect(context.Background(), topic, messageData(payload),
+		pubsub.WithMaxSize(s.server.cfg.PubSub.MaxMessageSize),
+		pubsub.WithPublisher(pubsub.MQTTIdentity(s.clientID)),
+		// MQTT messages carry no ID of their own
+		pubsub.WithGeneratedID(),
+	)
+	return err
+}
+
+// handleSubscribe subscribes to the hub topics named by the topic filters.
+// Every subscription is granted QoS 0; wildcard filters are refused.
+func (s *session) handleSubscribe(p *packet) error {
+	id, subs, err := decodeSubscribe(p)
+	if err != nil {
+		return err
+	}
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, sub := range subs {
+		code := byte(0)
+		if err := s.subscribe(sub.filter); err != nil {
+			s.logger().Warn("MQTT subscription refused", logging.Topic, sub.filter, "error", err)
+			code = subackFailure
+		}
+		body = append(body, code)
+	}
+	return s.write(packetSuback, 0, body)
+}
+
+func (s *session) subscribe(filter string) error {
+	switch {
+	case filter == "":
+		return errors.New("topic filter must not be empty")
+	case strings.ContainsAny(filter, "+#"):
+		return errors.New("wildcard topic filters are not supported")
+	}
+	if !s.server.auth.Authorize(s.tenant, auth.PermSubscribe, filter) {
+		return pubsub.NotPermitted(auth.PermSubscribe, filter)
+	}
+	if !s.server.hub.TopicExists(filter) {
+		if _, deleted := s.server.hub.DeletedTopicInfo(filter); deleted {
+			return pubsub.ErrTopicDeleted
+		}
+		return pubsub.ErrTopicNotFound
+	}
+	return s.stream.Subscribe(filter, pubsub.StreamOptions{})
+}
+
+func (s *session) handleUnsubscribe(p *packet) error {
+	id, filters, err := decodeUnsubscribe(p)
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		s.stream.Unsubscribe(filter)
+	}
+	return s.write(packetUnsuback, 0, binary.BigEndian.AppendUint16(nil, id))
+}
+
+// deliver writes the session's events as QoS 0 PUBLISH packets until the
+// stream closes or a write fails, then closes the connection. A slow
+// consumer's stream is closed by the hub, which disconnects it.
+func (s *session) deliver() {
+	defer func() {
+		if r := recover(); r != nil {
+			s.server.hub.RecordPanic("mqtt.deliver", r, logging.ClientID, s.clientID)
+		}
+		s.conn.Close()
+	}()
+
+	for {
+		<-s.stream.Ready()
+		frames, closed := s.stream.Next()
+		for _, frame := range frames {
+			var msg pubsub.ServerMessage
+			if err := json.Unmarshal(frame.Data, &msg); err != nil {
+				s.logger().Error("MQTT frame decode failed", "error", err)
+				continue
+			}
+			switch {
+			case msg.Type == pubsub.EventMessage && msg.Message != nil:
+				payload, err := payloadBytes(msg.Message)
+				if err != nil {
+					s.logger().Error("MQTT event encode failed", logging.Topic, msg.Topic, "error", err)
+					continue
+				}
+				if err := s.write(packetPublish, 0, encodePublish(msg.Topic, payload)); err != nil {
+					return
+				}
+			case msg.Type == pubsub.ErrorMessage && msg.Error != nil:
+				s.logger().Warn("MQTT client error", "code", msg.Error.Code, "error", msg.Error.Message)
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// write sends a packet within the write timeout
+func (s *session) write(kind, flags byte, body []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(s.server.cfg.PubSub.WriteWait))
+	return writePacket(s.conn, kind, flags, body)
+}
+
+// connack answers CONNECT with a return code
+func (s *session) connack(code byte) error {
+	return s.write(packetConnack, 0, []byte{0, code})
+}
+
+// messageData maps an MQTT payload onto a message: JSON payloads are
+// published as JSON, other UTF-8 as text/plain and anything else as
+// base64-encoded application/octet-stream
+func messageData(payload []byte) *pubsub.MessageData {
+	var decoded interface{}
+	if json.Unmarshal(payload, &decoded) == nil {
+		return &pubsub.MessageData{Payload: decoded}
+	}
+	if utf8.Valid(payload) {
+		return &pubsub.MessageData{Payload: string(payload), ContentType: pubsub.ContentTypeText}
+	}
+	return &pubsub.MessageData{
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		ContentType: pubsub.ContentTypeBinary,
+	}
+}
+
+// payloadBytes is the MQTT payload for a delivered message: text and binary
+// payloads are sent as their raw bytes, JSON as encoded JSON
+func payloadBytes(data *pubsub.MessageData) ([]byte, error) {
+	text, isString := data.Payload.(string)
+	switch {
+	case isString && strings.HasPrefix(data.ContentType, pubsub.ContentTypeText):
+		return []byte(text), nil
+	case isString && strings.HasPrefix(data.ContentType, pubsub.ContentTypeBinary):
+		return base64.StdEncoding.DecodeString(text)
+	default:
+		return json.Marshal(data.Payload)
+	}
+}
@@ -0,0 +1,129 @@
+package pubsub
+
+import (
+	"log/slog"
+
+	"plivo/internal/logging"
+)
+
+// AlertType names an anomaly the broker detects in its own topics
+type AlertType string
+
+// Alerts reported on $SYS/alerts
+const (
+	// AlertPublishStalled is a topic with subscribers that was published to
+	// in one window and not at all in the next. It resolves once publishes
+	// resume.
+	AlertPublishStalled AlertType = "publish_stalled"
+	// AlertSubscribersCollapsed is a topic that lost at least half of its
+	// subscribers, and at least collapseMinLost, within one window
+	AlertSubscribersCollapsed AlertType = "subscribers_collapsed"
+	// AlertDropSpike is a topic that dropped at least dropSpikeMin events
+	// in one window and dropSpikeFactor times as many as in the window
+	// before
+	AlertDropSpike AlertType = "drop_spike"
+)
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Detector thresholds, chosen so an idle or small topic doesn't alert on
+// noise
+const (
+	collapseMinLost = 2
+	dropSpikeMin    = 10
+	dropSpikeFactor = 4
+)
+
+// AlertEvent is the payload of a $SYS/alerts event, published when a
+// detector sees a topic's publish rate, subscriber count or drop rate
+// change abruptly between two windows
+type AlertEvent struct {
+	Alert AlertType `json:"alert"`
+	// State is "firing", or "resolved" once a stall ends
+	State string `json:"state"`
+	Topic string `json:"topic"`
+	// Previous and Current are the measure the detector compared, over the
+	// window before and the window just ended: publishes, subscribers or
+	// dropped events
+	Previous int64 `json:"previous"`
+	Current  int64 `json:"current"`
+	// WindowMs is the length of a window
+	WindowMs int64 `json:"window_ms"`
+}
+
+// alertWindow is what the detectors last saw of a topic
+type alertWindow struct {
+	sequence    int64
+	dropped     int64
+	subscribers int
+	// Publishes and drops during the last window
+	published int64
+	drops     int64
+	stalled   bool
+}
+
+// detectAnomalies compares every topic with what it looked like one window
+// ago and reports what changed abruptly on $SYS/alerts. Run from the hub
+// loop every alert interval.
+func (h *Hub) detectAnomalies() {
+	windowMs := h.alertInterval.Milliseconds()
+	var alerts []AlertEvent
+
+	h.mu.Lock()
+	if h.alertWindows == nil {
+		h.alertWindows = make(map[string]*alertWindow)
+	}
+	for name := range h.alertWindows {
+		if _, exists := h.topics[name]; !exists {
+			delete(h.alertWindows, name)
+		}
+	}
+	for name, topic := range h.topics {
+		if IsSystemTopic(name) || topic.inbox {
+			continue
+		}
+		current := alertWindow{
+			sequence:    topic.Sequence,
+			dropped:     topic.DroppedCount,
+			subscribers: topic.SubscriberCount,
+		}
+		last, seen := h.alertWindows[name]
+		h.alertWindows[name] = &current
+		if !seen {
+			continue
+		}
+		current.published = current.sequence - last.sequence
+		current.drops = current.dropped - last.dropped
+		current.stalled = last.stalled
+
+		alert := func(kind AlertType, state string, previous, now int64) {
+			alerts = append(alerts, AlertEvent{Alert: kind, State: state, Topic: name, Previous: previous, Current: now, WindowMs: windowMs})
+		}
+		switch {
+		case !last.stalled && last.published > 0 && current.published == 0 && current.subscribers > 0:
+			current.stalled = true
+			alert(AlertPublishStalled, AlertFiring, last.published, 0)
+		case last.stalled && current.published > 0:
+			current.stalled = false
+			alert(AlertPublishStalled, AlertResolved, 0, current.published)
+		}
+		if lost := last.subscribers - current.subscribers; lost >= collapseMinLost && 2*current.subscribers <= last.subscribers {
+			alert(AlertSubscribersCollapsed, AlertFiring, int64(last.subscribers), int64(current.subscribers))
+		}
+		if current.drops >= dropSpikeMin && current.drops >= dropSpikeFactor*last.drops {
+			alert(AlertDropSpike, AlertFiring, last.drops, current.drops)
+		}
+	}
+	h.mu.Unlock()
+
+	// Publish outside the lock, which publishSystemEvent takes itself
+	for _, event := range alerts {
+		slog.Warn("Topic anomaly", "alert", event.Alert, "state", event.State, logging.Topic, event.Topic,
+			"previous", event.Previous, "current", event.Current)
+		h.publishSystemEvent(AlertsTopic, event)
+	}
+}
@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+// nextAlerts dequeues the $SYS/alerts events detectAnomalies queued
+func nextAlerts(hub *Hub) []AlertEvent {
+	var alerts []AlertEvent
+	for {
+		message, ok := hub.publishes.next()
+		if !ok {
+			return alerts
+		}
+		if message.Topic == AlertsTopic {
+			alerts = append(alerts, message.Message.Payload.(AlertEvent))
+		}
+	}
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.AlertInterval = time.Minute
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: AlertsTopic})
+
+	// window advances the topic by some publishes, drops and a subscriber
+	// count, then runs the detectors
+	window := func(published, dropped int64, subscribers int) []AlertEvent {
+		hub.mu.Lock()
+		topic := hub.topics["orders"]
+		topic.Sequence += published
+		topic.DroppedCount += dropped
+		topic.SubscriberCount = subscribers
+		hub.mu.Unlock()
+		hub.detectAnomalies()
+		return nextAlerts(hub)
+	}
+
+	// The first window only records a baseline, and steady traffic is quiet
+	window(0, 0, 6)
+	if alerts := window(50, 2, 6); len(alerts) != 0 {
+		t.Fatalf("Expected no alerts for steady traffic, got %+v", alerts)
+	}
+
+	alerts := window(0, 0, 6)
+	if len(alerts) != 1 || alerts[0].Alert != AlertPublishStalled || alerts[0].State != AlertFiring || alerts[0].Previous != 50 || alerts[0].WindowMs != 60000 {
+		t.Fatalf("Expected a firing publish_stalled alert, got %+v", alerts)
+	}
+	if alerts := window(0, 0, 6); len(alerts) != 0 {
+		t.Errorf("Expected a stall reported once, got %+v", alerts)
+	}
+	alerts = window(10, 0, 6)
+	if len(alerts) != 1 || alerts[0].Alert != AlertPublishStalled || alerts[0].State != AlertResolved || alerts[0].Current != 10 {
+		t.Fatalf("Expected the stall resolved, got %+v", alerts)
+	}
+
+	alerts = window(10, 0, 2)
+	if len(alerts) != 1 || alerts[0].Alert != AlertSubscribersCollapsed || alerts[0].Previous != 6 || alerts[0].Current != 2 {
+		t.Fatalf("Expected subscribers_collapsed, got %+v", alerts)
+	}
+	if alerts := window(10, 0, 1); len(alerts) != 0 {
+		t.Errorf("Expected losing a single subscriber to be quiet, got %+v", alerts)
+	}
+
+	alerts = window(10, 40, 1)
+	if len(alerts) != 1 || alerts[0].Alert != AlertDropSpike || alerts[0].Previous != 0 || alerts[0].Current != 40 {
+		t.Fatalf("Expected drop_spike, got %+v", alerts)
+	}
+	if alerts := window(10, 60, 1); len(alerts) != 0 {
+		t.Errorf("Expected drops steady at the new level to be quiet, got %+v", alerts)
+	}
+}
+
+func TestDetectAnomaliesIgnoresIdleTopics(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: AlertsTopic})
+
+	// A topic nobody subscribes to may stop being published to
+	for _, published := range []int64{0, 5, 0} {
+		hub.mu.Lock()
+		hub.topics["orders"].Sequence += published
+		hub.mu.Unlock()
+		hub.detectAnomalies()
+	}
+	if alerts := nextAlerts(hub); len(alerts) != 0 {
+		t.Errorf("Expected no alerts without subscribers, got %+v", alerts)
+	}
+}
@@ -0,0 +1,152 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Connection attribute limits
+const (
+	// MaxAttributes is the most attributes a connection may set
+	MaxAttributes = 16
+	// MaxAttributeNameLength and MaxAttributeValueLength bound each
+	// attribute in bytes
+	MaxAttributeNameLength  = 64
+	MaxAttributeValueLength = 256
+)
+
+// ConnHeaderPrefix starts the headers stamped on a message with its
+// publisher's connection attributes, so subscribers can filter on where a
+// message came from
+const ConnHeaderPrefix = ReservedHeaderPrefix + "conn."
+
+// attributeRef starts a filter value naming one of the subscriber's
+// connection attributes; "$$" escapes a literal "$"
+const attributeRef = "$"
+
+// ValidateAttributes checks connection attributes: at most MaxAttributes,
+// with names of letters, digits, '_', '-' and '.'
+func ValidateAttributes(attrs map[string]string) error {
+	if len(attrs) > MaxAttributes {
+		return fmt.Errorf("at most %d connection attributes may be set, got %d", MaxAttributes, len(attrs))
+	}
+	for name, value := range attrs {
+		switch {
+		case name == "":
+			return fmt.Errorf("connection attribute names must not be empty")
+		case len(name) > MaxAttributeNameLength:
+			return fmt.Errorf("connection attribute name %q exceeds %d bytes", name[:16]+"...", MaxAttributeNameLength)
+		case strings.IndexFunc(name, invalidAttributeRune) >= 0:
+			return fmt.Errorf("connection attribute name %q may only contain letters, digits, '_', '-' and '.'", name)
+		case len(value) > MaxAttributeValueLength:
+			return fmt.Errorf("value of connection attribute %q exceeds %d bytes", name, MaxAttributeValueLength)
+		}
+	}
+	return nil
+}
+
+// invalidAttributeRune reports runes not allowed in attribute names
+func invalidAttributeRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r == '_', r == '-', r == '.':
+		return false
+	}
+	return true
+}
+
+// SetAttributes sets the client's connection attributes. It must be called
+// before the client is registered; attributes don't change afterwards.
+func (c *Client) SetAttributes(attrs map[string]string) {
+	if len(attrs) == 0 {
+		return
+	}
+	c.attrs = make(map[string]string, len(attrs))
+	for name, value := range attrs {
+		c.attrs[name] = value
+	}
+}
+
+// resolveFilter checks a subscription filter and substitutes the client's
+// connection attributes for values naming them, so {"user_id": "$user_id"}
+// matches events whose user_id header is the connection's user_id
+func (c *Client) resolveFilter(filter map[string]string) (map[string]string, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+	if len(filter) > MaxMessageHeaders {
+		return nil, fmt.Errorf("filter exceeds %d entries", MaxMessageHeaders)
+	}
+
+	resolved := make(map[string]string, len(filter))
+	for header, value := range filter {
+		if header == "" {
+			return nil, fmt.Errorf("filter header names must not be empty")
+		}
+		switch {
+		case strings.HasPrefix(value, attributeRef+attributeRef):
+			value = value[len(attributeRef):]
+		case strings.HasPrefix(value, attributeRef):
+			name := value[len(attributeRef):]
+			attr, ok := c.attrs[name]
+			if !ok {
+				return nil, fmt.Errorf("filter on %q names connection attribute %q, which the connection did not set", header, name)
+			}
+			value = attr
+		}
+		resolved[header] = value
+	}
+	return resolved, nil
+}
+
+// matchesFilter reports whether every header in the filter is set on the
+// message with the filter's value
+func matchesFilter(message *PubSubMessage, filter map[string]string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	if message.Message == nil {
+		return false
+	}
+	for header, value := range filter {
+		if actual, ok := message.Message.Headers[header]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// filterMessages returns the messages matching the filter and where
+// expression, so a filtered subscription's ack counts only the backlog it
+// will receive
+func filterMessages(messages []*PubSubMessage, filter map[string]string, where *whereExpr) []*PubSubMessage {
+	matched := make([]*PubSubMessage, 0, len(messages))
+	for _, message := range messages {
+		if matchesFilter(message, filter) && where.match(message) {
+			matched = append(matched, message)
+		}
+	}
+	return matched
+}
+
+// stampAttributes adds the client's connection attributes to a message it
+// publishes as _conn.<name> headers. The headers are copied so the
+// publisher's map is left alone.
+func (c *Client) stampAttributes(message *PubSubMessage) {
+	if len(c.attrs) == 0 || message.Message == nil {
+		return
+	}
+
+	headers := make(map[string]string, len(message.Message.Headers)+len(c.attrs))
+	for key, value := range message.Message.Headers {
+		headers[key] = value
+	}
+	for name, value := range c.attrs {
+		headers[ConnHeaderPrefix+name] = value
+	}
+
+	data := *message.Message
+	data.Headers = headers
+	message.Message = &data
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	if err := ValidateAttributes(map[string]string{"region": "eu", "user_id": "123", "app.version": "2-1"}); err != nil {
+		t.Errorf("Expected valid attributes, got %v", err)
+	}
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxAttributes; i++ {
+		tooMany[string(rune('a'+i))] = "x"
+	}
+	invalid := []map[string]string{
+		{"": "x"},
+		{"user id": "x"},
+		{"$user": "x"},
+		{strings.Repeat("n", MaxAttributeNameLength+1): "x"},
+		{"region": strings.Repeat("v", MaxAttributeValueLength+1)},
+		tooMany,
+	}
+	for _, attrs := range invalid {
+		if err := ValidateAttributes(attrs); err == nil {
+			t.Errorf("Expected %v to be rejected", attrs)
+		}
+	}
+}
+
+func TestResolveFilter(t *testing.T) {
+	client := newTestClient(NewHub())
+	client.SetAttributes(map[string]string{"user_id": "123"})
+
+	filter, err := client.resolveFilter(map[string]string{"user_id": "$user_id", "kind": "alert", "price": "$$5"})
+	if err != nil {
+		t.Fatalf("Failed to resolve filter: %v", err)
+	}
+	if filter["user_id"] != "123" || filter["kind"] != "alert" || filter["price"] != "$5" {
+		t.Errorf("Unexpected resolved filter %v", filter)
+	}
+
+	if _, err := client.resolveFilter(map[string]string{"region": "$region"}); err == nil {
+		t.Error("Expected a filter naming an unset attribute to be rejected")
+	}
+}
+
+func TestSubscriptionFilterByConnectionAttribute(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("notifications")
+
+	alice := newTestClient(hub)
+	alice.SetAttributes(map[string]string{"user_id": "alice"})
+	bob := newTestClient(hub)
+	bob.SetAttributes(map[string]string{"user_id": "bob"})
+	for _, client := range []*Client{alice, bob} {
+		client.handleMessage(&ClientMessage{
+			Type:     SubscribeMessage,
+			Topic:    "notifications",
+			ClientID: "inbox",
+			Filter:   map[string]string{"user_id": "$user_id"},
+		})
+	}
+
+	waitForSubscribers(t, hub, "notifications", 2)
+	drainFrames(t, alice)
+	drainFrames(t, bob)
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		hub.publishMessage(&PubSubMessage{
+			Topic:   "notifications",
+			Message: &MessageData{ID: "for-" + user, Payload: "hi", Headers: map[string]string{"user_id": user}},
+		})
+	}
+	hub.publishMessage(&PubSubMessage{
+		Topic:   "notifications",
+		Message: &MessageData{ID: "unaddressed", Payload: "hi"},
+	})
+
+	if frames := drainFrames(t, alice); len(frames) != 2 || frames[0].Message.ID != "for-alice" || frames[1].Message.ID != "for-alice" {
+		t.Errorf("Expected alice's 2 notifications, got %+v", frames)
+	}
+	if frames := drainFrames(t, bob); len(frames) != 1 || frames[0].Message.ID != "for-bob" {
+		t.Errorf("Expected bob's notification, got %+v", frames)
+	}
+}
+
+func TestFilteredReplayCountsMatchingBacklog(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("notifications")
+	watcher := newTestClient(hub)
+	watcher.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "notifications", ClientID: "watcher"})
+	waitForSubscribers(t, hub, "notifications", 1)
+
+	for _, user := range []string{"alice", "bob", "alice", "carol"} {
+		hub.publishMessage(&PubSubMessage{
+			Topic:   "notifications",
+			Message: &MessageData{ID: "for-" + user, Payload: "hi", Headers: map[string]string{"user_id": user}},
+		})
+	}
+
+	client := newTestClient(hub)
+	client.SetAttributes(map[string]string{"user_id": "alice"})
+	client.handleMessage(&ClientMessage{
+		Type:     SubscribeMessage,
+		Topic:    "notifications",
+		ClientID: "inbox",
+		LastN:    10,
+		Filter:   map[string]string{"user_id": "$user_id"},
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) == 0 || frames[0].Subscription == nil || frames[0].Subscription.Replaying != 2 {
+		t.Fatalf("Expected an ack replaying 2 events, got %+v", frames)
+	}
+}
+
+func TestSubscribeRejectsUnsetAttributeFilter(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.handleMessage(&ClientMessage{
+		Type:      SubscribeMessage,
+		Topic:     "notifications",
+		ClientID:  "inbox",
+		Filter:    map[string]string{"user_id": "$user_id"},
+		RequestID: "sub-1",
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != "BAD_REQUEST" {
+		t.Errorf("Expected BAD_REQUEST for an unset attribute, got %+v", frames)
+	}
+	if client.IsSubscribed("notifications") {
+		t.Error("Client should not be subscribed after a rejected subscribe")
+	}
+}
+
+func TestPublishStampsConnectionAttributes(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("telemetry")
+
+	subscriber := newTestClient(hub)
+	subscriber.handleMessage(&ClientMessage{
+		Type:     SubscribeMessage,
+		Topic:    "telemetry",
+		ClientID: "eu-dashboard",
+		Filter:   map[string]string{ConnHeaderPrefix + "region": "eu"},
+	})
+	waitForSubscribers(t, hub, "telemetry", 1)
+	drainFrames(t, subscriber)
+
+	for _, region := range []string{"us", "eu"} {
+		publisher := newTestClient(hub)
+		publisher.SetAttributes(map[string]string{"region": region})
+		headers := map[string]string{"source": "sensor"}
+		publisher.handleMessage(&ClientMessage{
+			Type:    PublishMessage,
+			Topic:   "telemetry",
+			Message: &MessageData{ID: "from-" + region, Payload: "reading", Headers: headers},
+		})
+		if len(headers) != 1 {
+			t.Errorf("Stamping modified the publisher's headers: %v", headers)
+		}
+	}
+
+	// Publishes fan out in order, so the us one was filtered by the time the
+	// eu one arrives
+	var frames []ServerMessage
+	deadline := time.Now().Add(2 * time.Second)
+	for len(frames) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		frames = append(frames, drainFrames(t, subscriber)...)
+	}
+	if len(frames) != 1 || frames[0].Message.ID != "from-eu" || frames[0].Message.Headers[ConnHeaderPrefix+"region"] != "eu" {
+		t.Errorf("Expected only the eu publish with its stamped region, got %+v", frames)
+	}
+}
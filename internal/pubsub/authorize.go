@@ -0,0 +1,33 @@
+package pubsub
+
+import (
+	"fmt"
+	"plivo/internal/auth"
+)
+
+// Authorizer reports whether a client may take an action on a topic. It is
+// consulted on every publish and subscribe, so ACL changes apply to
+// connected clients' next requests.
+type Authorizer func(permission auth.Permission, topic string) bool
+
+// NotPermitted is the error for an action on a topic the ACL doesn't grant
+func NotPermitted(permission auth.Permission, topic string) error {
+	return fmt.Errorf("%w: %s on topic %s", ErrNotPermitted, permission, topic)
+}
+
+// SetAuthorizer restricts the client's publishes and subscribes. It must be
+// called before the client is registered; without one the client may do
+// anything.
+func (c *Client) SetAuthorizer(authorize Authorizer) {
+	c.authorize = authorize
+}
+
+// permitted checks the client's authorizer, sending the error if the
+// action isn't permitted
+func (c *Client) permitted(requestID string, permission auth.Permission, topic string) bool {
+	if c.authorize == nil || c.authorize(permission, topic) {
+		return true
+	}
+	c.sendErrorData(requestID, ErrorFrom(NotPermitted(permission, topic)))
+	return false
+}
@@ -0,0 +1,44 @@
+package pubsub
+
+import (
+	"plivo/internal/auth"
+	"testing"
+)
+
+func TestClientAuthorizer(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("audit")
+
+	client := newTestClient(hub)
+	client.SetAuthorizer(func(permission auth.Permission, topic string) bool {
+		return permission == auth.PermSubscribe || topic == "audit"
+	})
+
+	client.handleMessage(&ClientMessage{
+		Type:      PublishMessage,
+		Topic:     "orders",
+		Message:   &MessageData{ID: "msg-1", Payload: "x"},
+		RequestID: "pub-1",
+	})
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != CodeForbidden {
+		t.Errorf("Expected FORBIDDEN for an unpermitted publish, got %+v", frames)
+	}
+
+	client.handleMessage(&ClientMessage{
+		Type:      PublishMessage,
+		Topic:     "audit",
+		Message:   &MessageData{ID: "msg-2", Payload: "x"},
+		RequestID: "pub-2",
+	})
+	if frames := drainFrames(t, client); len(frames) != 1 || frames[0].Type != AckMessage {
+		t.Errorf("Expected a permitted publish to be acknowledged, got %+v", frames)
+	}
+
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "orders", 1)
+}
@@ -0,0 +1,276 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"plivo/internal/auth"
+	"time"
+)
+
+// MaxBatchSize bounds how many messages a publish batch may carry
+const MaxBatchSize = 100
+
+// Publish batch message statuses
+const (
+	// BatchPublished messages were queued for fan-out
+	BatchPublished = "published"
+	// BatchRejected messages failed validation, failing their batch
+	BatchRejected = "rejected"
+	// BatchAborted messages were valid but not published, as others in
+	// their batch were rejected
+	BatchAborted = "aborted"
+	// BatchDuplicate messages repeat an ID their topic published within
+	// its dedup window, or earlier in the batch, and were dropped
+	BatchDuplicate = PublishDuplicate
+)
+
+// BatchResult is the outcome of one message of a publish batch
+type BatchResult struct {
+	ID     string     `json:"id,omitempty"`
+	Status string     `json:"status"`
+	Error  *ErrorData `json:"error,omitempty"`
+}
+
+// BatchError rejects a publish batch, none of which is published, because
+// some of its messages are invalid. It matches the first invalid message's
+// error with errors.Is and errors.As.
+type BatchError struct {
+	// Index is the position of the first invalid message
+	Index int
+	Err   error
+	// Results has every message's outcome, in order
+	Results []BatchResult
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch message %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// BatchReceipt reports a publish batch accepted by PublishBatch
+type BatchReceipt struct {
+	Topic string
+	// Results has each message's ID and status, in order
+	Results []BatchResult
+	// Timestamp is the server receive time stamped on every message
+	Timestamp time.Time
+	// Ahead is how many of the topic's publishes were queued ahead of the
+	// batch
+	Ahead int
+}
+
+// NewBatch validates a publish batch's messages for topic, each as
+// NewMessageFromData does with opts. A batch must hold between 1 and
+// MaxBatchSize messages; if any is invalid, a *BatchError says which.
+func NewBatch(topic string, data []*MessageData, opts ...MessageOption) ([]*PubSubMessage, error) {
+	if len(data) == 0 {
+		return nil, &InvalidMessageError{Field: "messages", Reason: "must not be empty"}
+	}
+	if len(data) > MaxBatchSize {
+		return nil, &InvalidMessageError{Field: "messages", Reason: fmt.Sprintf("must hold at most %d messages", MaxBatchSize)}
+	}
+
+	messages := make([]*PubSubMessage, len(data))
+	failures := make(map[int]error)
+	for i, d := range data {
+		message, err := NewMessageFromData(topic, d, opts...)
+		if err != nil {
+			failures[i] = err
+			continue
+		}
+		messages[i] = message
+	}
+	if len(failures) > 0 {
+		return nil, rejectBatch(data, failures)
+	}
+	return messages, nil
+}
+
+// rejectBatch builds the error rejecting a batch for its invalid messages,
+// by index
+func rejectBatch(data []*MessageData, failures map[int]error) *BatchError {
+	batchErr := &BatchError{Index: -1, Results: make([]BatchResult, len(data))}
+	for i, d := range data {
+		result := BatchResult{Status: BatchAborted}
+		if d != nil {
+			result.ID = d.ID
+		}
+		if err, failed := failures[i]; failed {
+			result.Status = BatchRejected
+			result.Error = ErrorFrom(err)
+			if batchErr.Index < 0 {
+				batchErr.Index, batchErr.Err = i, err
+			}
+		}
+		batchErr.Results[i] = result
+	}
+	return batchErr
+}
+
+// claimBatch claims the message IDs of an accepted batch, as
+// claimMessageID does one, returning each message's outcome and the
+// messages left to publish once duplicates are dropped
+func (h *Hub) claimBatch(messages []*PubSubMessage) ([]BatchResult, []*PubSubMessage) {
+	results := make([]BatchResult, len(messages))
+	fresh := make([]*PubSubMessage, 0, len(messages))
+	for i, message := range messages {
+		results[i] = BatchResult{ID: message.Message.ID, Status: BatchPublished}
+		if !h.claimMessageID(message) {
+			results[i].Status = BatchDuplicate
+			continue
+		}
+		fresh = append(fresh, message)
+	}
+	return results, fresh
+}
+
+// admitBatch checks that the topic accepts every message of a batch, as
+// admitPublish does one, returning the topic's scheduling weight. Messages
+// the topic refuses, such as plaintext on an encrypted topic, fail the
+// batch with a *BatchError.
+func (h *Hub) admitBatch(messages []*PubSubMessage) (int, error) {
+	weight := 1
+	failures := make(map[int]error)
+	for i, message := range messages {
+		w, err := h.admitPublish(message)
+		var invalid *InvalidMessageError
+		if errors.As(err, &invalid) || errors.Is(err, ErrSchemaViolation) {
+			failures[i] = err
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		weight = w
+	}
+	if len(failures) > 0 {
+		data := make([]*MessageData, len(messages))
+		for i, message := range messages {
+			data[i] = message.Message
+		}
+		return 0, rejectBatch(data, failures)
+	}
+	return weight, nil
+}
+
+// enqueueBatch schedules a batch's messages for fan-out together, as
+// enqueuePublish does one, waiting while the topic's backlog lacks room
+// for them. It returns each message's outcome.
+func (h *Hub) enqueueBatch(messages []*PubSubMessage) ([]BatchResult, error) {
+	weight, err := h.admitBatch(messages)
+	if err != nil {
+		return nil, err
+	}
+	results, fresh := h.claimBatch(messages)
+	if len(fresh) == 0 {
+		return results, nil
+	}
+	if _, err := h.publishes.pushAll(fresh[0].Topic, fresh, weight, h.shutdown, nil); err != nil {
+		h.forgetMessageIDs(fresh)
+		return nil, err
+	}
+	return results, nil
+}
+
+// PublishBatch publishes messages to an existing topic on behalf of a
+// caller with no client, as PublishDirect does one. The batch is atomic:
+// either every message is queued, one after another with no other publish
+// to the topic between them, or none is. Duplicates, which PublishDirect
+// would drop, are left out and reported as such. Each message is validated
+// as NewBatch does, and the batch is refused, shed or abandoned as a whole
+// where PublishDirect would refuse, shed or abandon a single message. It is
+// safe for concurrent use.
+func (h *Hub) PublishBatch(ctx context.Context, topic string, data []*MessageData, opts ...MessageOption) (*BatchReceipt, error) {
+	if IsSystemTopic(topic) {
+		return nil, ErrReservedTopic
+	}
+	if !h.TopicExists(topic) {
+		if _, deleted := h.DeletedTopicInfo(topic); deleted {
+			return nil, ErrTopicDeleted
+		}
+		return nil, ErrTopicNotFound
+	}
+
+	opts = append([]MessageOption{WithTimestamp(h.clock.Now())}, opts...)
+	messages, err := NewBatch(topic, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.rejectDepth > 0 && h.publishes.topicPending(topic) >= h.rejectDepth {
+		h.ReportQuota(QuotaEvent{
+			Quota:    QuotaPublishBacklog,
+			Identity: messages[0].publisher,
+			Topic:    topic,
+			Limit:    int64(h.rejectDepth),
+		})
+		return nil, ErrHubSaturated
+	}
+
+	weight, err := h.admitBatch(messages)
+	if err != nil {
+		return nil, err
+	}
+	receipt := &BatchReceipt{Topic: topic, Timestamp: messages[0].Timestamp}
+	results, fresh := h.claimBatch(messages)
+	receipt.Results = results
+	if len(fresh) == 0 {
+		return receipt, nil
+	}
+	ahead, err := h.waitToPublish(ctx, fresh, weight, directPublishWait)
+	if err != nil {
+		h.forgetMessageIDs(fresh)
+		return nil, err
+	}
+	receipt.Ahead = ahead
+	return receipt, nil
+}
+
+// handlePublishBatch processes publish_batch requests: the messages are
+// validated and queued together, all or none, and answered with a single
+// ack listing each message's ID and status. A batch spends one token of the
+// client's publish rate limit, as a REST batch request is one request.
+func (c *Client) handlePublishBatch(msg *ClientMessage) {
+	receivedAt := c.hub.clock.Now()
+	if !c.allowPublish(msg, receivedAt) {
+		return
+	}
+
+	// System topics, the echo topic included, are published by the broker
+	// only
+	if IsSystemTopic(msg.Topic) {
+		c.sendErrorData(msg.RequestID, ErrorFrom(ErrReservedTopic))
+		return
+	}
+	if !c.permitted(msg.RequestID, auth.PermPublish, msg.Topic) {
+		return
+	}
+
+	opts := append(c.messageOptions(), WithTimestamp(receivedAt))
+	messages, err := NewBatch(msg.Topic, msg.Messages, opts...)
+	if err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+	for _, message := range messages {
+		c.stampAttributes(message)
+	}
+
+	results, err := c.hub.enqueueBatch(messages)
+	if err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+	c.sendBatchAck(msg.RequestID, msg.Topic, results)
+}
+
+// sendBatchAck sends a publish_batch acknowledgment listing the messages'
+// outcomes
+func (c *Client) sendBatchAck(requestID, topic string, results []BatchResult) {
+	data := c.hub.createBatchAckMessageBytes(requestID, topic, results)
+	c.sendWithBackpressure("", data)
+}
@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClientPublishBatch(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	subscriber := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: subscriber, topic: "orders"})
+
+	publisher := newTestClient(hub)
+	publisher.handleMessage(&ClientMessage{
+		Type:      PublishBatchMessage,
+		Topic:     "orders",
+		RequestID: "req-1",
+		Messages: []*MessageData{
+			{ID: "m1", Payload: 1},
+			{ID: "m2", Payload: 2},
+			{ID: "m3", Payload: 3},
+		},
+	})
+
+	frames := drainFrames(t, publisher)
+	if len(frames) != 1 || frames[0].Type != AckMessage || frames[0].RequestID != "req-1" {
+		t.Fatalf("Expected a single ack, got %+v", frames)
+	}
+	results := frames[0].Results
+	if len(results) != 3 || results[0].ID != "m1" || results[2].ID != "m3" || results[1].Status != BatchPublished {
+		t.Errorf("Expected m1 to m3 published, got %+v", results)
+	}
+
+	hub.dispatchPublishes()
+	events := drainFrames(t, subscriber)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.Message.ID != results[i].ID || event.Sequence != int64(i+1) {
+			t.Errorf("Expected %s at sequence %d, got %s at %d", results[i].ID, i+1, event.Message.ID, event.Sequence)
+		}
+	}
+}
+
+func TestClientPublishBatchIsAtomic(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	publisher := newTestClient(hub)
+
+	publisher.handleMessage(&ClientMessage{
+		Type:      PublishBatchMessage,
+		Topic:     "orders",
+		RequestID: "req-1",
+		Messages: []*MessageData{
+			{ID: "m1", Payload: 1},
+			{Payload: "no id"},
+			{ID: "m3", Payload: 3, TTLMs: -1},
+		},
+	})
+
+	frames := drainFrames(t, publisher)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage {
+		t.Fatalf("Expected the batch rejected, got %+v", frames)
+	}
+	errorData := frames[0].Error
+	if errorData.Code != CodeBadRequest || len(errorData.Results) != 3 {
+		t.Fatalf("Expected BAD_REQUEST with 3 results, got %+v", errorData)
+	}
+	statuses := []string{errorData.Results[0].Status, errorData.Results[1].Status, errorData.Results[2].Status}
+	if statuses[0] != BatchAborted || statuses[1] != BatchRejected || statuses[2] != BatchRejected {
+		t.Errorf("Expected aborted, rejected, rejected, got %v", statuses)
+	}
+	if errorData.Results[1].Error == nil || errorData.Results[0].Error != nil {
+		t.Errorf("Expected only rejected messages to carry errors, got %+v", errorData.Results)
+	}
+	if pending := hub.publishes.topicPending("orders"); pending != 0 {
+		t.Errorf("Expected nothing queued, got %d", pending)
+	}
+}
+
+func TestNewBatchBounds(t *testing.T) {
+	if _, err := NewBatch("orders", nil); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected an empty batch to be invalid, got %v", err)
+	}
+
+	data := make([]*MessageData, MaxBatchSize+1)
+	for i := range data {
+		data[i] = &MessageData{ID: "m", Payload: i}
+	}
+	if _, err := NewBatch("orders", data); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected %d messages to be too many, got %v", len(data), err)
+	}
+	if _, err := NewBatch("orders", data[:MaxBatchSize]); err != nil {
+		t.Errorf("Expected %d messages to be accepted, got %v", MaxBatchSize, err)
+	}
+}
+
+func TestPublishBatchQueuesTogether(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 4})
+	hub.CreateTopic("orders")
+
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "first", Payload: 0}); err != nil {
+		t.Fatalf("PublishDirect failed: %v", err)
+	}
+
+	// Four messages don't fit behind the first, so the batch waits for the
+	// backlog to drain rather than being split
+	data := []*MessageData{{ID: "a", Payload: 1}, {ID: "b", Payload: 2}, {ID: "c", Payload: 3}, {ID: "d", Payload: 4}}
+	if _, err := hub.PublishBatch(context.Background(), "orders", data); !errors.Is(err, ErrHubSaturated) {
+		t.Fatalf("Expected the batch to time out waiting for room, got %v", err)
+	}
+	if pending := hub.publishes.topicPending("orders"); pending != 1 {
+		t.Fatalf("Expected none of the batch queued, got %d pending", pending)
+	}
+
+	hub.dispatchPublishes()
+	receipt, err := hub.PublishBatch(context.Background(), "orders", data)
+	if err != nil {
+		t.Fatalf("PublishBatch failed: %v", err)
+	}
+	if receipt.Ahead != 0 || len(receipt.Results) != 4 || receipt.Results[3].ID != "d" {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+	if pending := hub.publishes.topicPending("orders"); pending != 4 {
+		t.Errorf("Expected the batch queued, got %d pending", pending)
+	}
+}
@@ -0,0 +1,160 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Benchmarks for the hub's hot paths. Run them with allocation reporting:
+//
+//	go test -run '^$' -bench . -benchmem ./internal/pubsub
+
+// benchMessage builds a published message with a JSON object payload of
+// roughly size bytes
+func benchMessage(topic string, seq, size int) *PubSubMessage {
+	return &PubSubMessage{
+		Topic: topic,
+		Message: &MessageData{
+			ID: fmt.Sprintf("msg-%d", seq),
+			Payload: map[string]interface{}{
+				"seq":  seq,
+				"body": strings.Repeat("x", size),
+			},
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// benchQueueDrainEvery is how many publishes a benchmark makes between
+// draining subscriber queues, below their capacity so nobody is marked a
+// slow consumer
+const benchQueueDrainEvery = 64
+
+func BenchmarkPublishFanOut(b *testing.B) {
+	for _, subscribers := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("subscribers=%d", subscribers), func(b *testing.B) {
+			hub := NewHub()
+			if err := hub.CreateTopic("bench"); err != nil {
+				b.Fatalf("CreateTopic failed: %v", err)
+			}
+			clients := make([]*Client, subscribers)
+			for i := range clients {
+				clients[i] = newTestClient(hub)
+				hub.subscribeClient(&Subscription{client: clients[i], topic: "bench"})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				hub.publishMessage(benchMessage("bench", i, 64))
+				if i%benchQueueDrainEvery == benchQueueDrainEvery-1 {
+					for _, c := range clients {
+						c.queue.Drain()
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSubscribeChurn(b *testing.B) {
+	hub := NewHub()
+	if err := hub.CreateTopic("bench"); err != nil {
+		b.Fatalf("CreateTopic failed: %v", err)
+	}
+	// Steady subscribers, so churn updates a populated subscription map
+	for i := 0; i < 100; i++ {
+		hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: "bench"})
+	}
+	subscription := &Subscription{client: newTestClient(hub), topic: "bench"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hub.subscribeClient(subscription)
+		hub.unsubscribeClient(subscription)
+	}
+}
+
+func BenchmarkMemoryStoreAppend(b *testing.B) {
+	cases := []struct {
+		name string
+		size int
+		opts []MemoryStoreOption
+	}{
+		{name: "plain", size: 256},
+		{name: "compressed", size: 4096, opts: []MemoryStoreOption{WithCompression(1024)}},
+		{name: "budget", size: 256, opts: []MemoryStoreOption{WithBudget(64 * 1024)}},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			store := NewMemoryStore(replayBufferSize, tc.opts...)
+			for i := 0; i < 8; i++ {
+				if err := store.CreateTopic(fmt.Sprintf("topic-%d", i)); err != nil {
+					b.Fatalf("CreateTopic failed: %v", err)
+				}
+			}
+			messages := make([]*PubSubMessage, 256)
+			for i := range messages {
+				messages[i] = benchMessage(fmt.Sprintf("topic-%d", i%8), i, tc.size)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.AppendMessage(messages[i%len(messages)]); err != nil {
+					b.Fatalf("AppendMessage failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEventEncoding(b *testing.B) {
+	hub := NewHub()
+	for _, size := range []int{64, 4096} {
+		b.Run(fmt.Sprintf("payload=%d", size), func(b *testing.B) {
+			message := benchMessage("bench", 1, size)
+			message.Sequence = 1
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if data := hub.createEventMessageBytes(message, 0); len(data) == 0 {
+					b.Fatal("createEventMessageBytes returned no data")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBroadcastEncoding(b *testing.B) {
+	hub := NewHub()
+	const subscribers = 10000
+	// per-subscriber encodes the event for every subscriber; shared goes
+	// through the message's frame cache, encoding it once per publish
+	cases := []struct {
+		name   string
+		encode func(*PubSubMessage) []byte
+	}{
+		{"per-subscriber", func(m *PubSubMessage) []byte { return hub.createEventMessageBytes(m, 0) }},
+		{"shared", func(m *PubSubMessage) []byte { return hub.eventFrame(m, 0, encodingJSON) }},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				message := benchMessage("bench", i, 256)
+				message.frames = &eventFrames{}
+				for j := 0; j < subscribers; j++ {
+					if data := tc.encode(message); len(data) == 0 {
+						b.Fatal("Encoding returned no data")
+					}
+				}
+			}
+		})
+	}
+}
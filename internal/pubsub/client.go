@@ -2,8 +2,13 @@ package pubsub
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/logging"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -13,56 +18,253 @@ import (
 type Client struct {
 	hub           *Hub
 	conn          *websocket.Conn
-	send          chan []byte
+	queue         *messageQueue
 	subscriptions map[string]bool
+	options       map[string]subscriptionOptions // delivery options per subscribed topic
+	audit         map[string]*auditState         // live delivery order per topic (audit mode)
 	mu            sync.RWMutex
 	id            string
 	// Backpressure management
-	queueSize    int
 	maxQueueSize int
 	slowConsumer bool
+	// Connection timing
+	opts ClientOptions
+	// Running ReadPump/WritePump goroutines
+	pumps atomic.Int32
+	// Registration outcome reported by the hub
+	registered chan bool
+	// Set for stream subscribers, which have no connection
+	stream bool
+	// Token renewing leased subscriptions, and the timer expiring them
+	leaseToken string
+	leaseTimer Timer
+	// Publish rate limit, nil when unlimited
+	publishLimit *TokenBucket
+	// When the client connected, and its keepalive round-trip times
+	connectedAt time.Time
+	rtt         rttTracker
+	// Connection attributes set at connect time, read-only once registered
+	attrs map[string]string
+	// ACL check on publishes and subscribes, nil if unrestricted
+	authorize Authorizer
+	// Set when the connection negotiated JSON-RPC framing
+	jsonrpc bool
+	// Wire encoding of the frames the client is sent
+	encoding frameEncoding
+	// Per-topic delivery tracking that skips duplicate events, guarded by mu
+	dedup map[string]*dedupState
 }
 
-// NewClient creates a new client
-func NewClient(hub *Hub, conn *websocket.Conn, id string) *Client {
+// subscriptionOptions holds per-subscription delivery options
+type subscriptionOptions struct {
+	fields []string // payload projection
+	group  string   // consumer group whose offset the subscription advances
+	keyID  string   // key ID presented for an encrypted topic
+	// filter holds the header values events must carry, with connection
+	// attributes already substituted
+	filter map[string]string
+	// where is the condition on event content events must meet, nil for none
+	where *whereExpr
+	// maxLatency bounds how long live events wait in the send queue (0 = no limit)
+	maxLatency time.Duration
+	// lease is how long the subscription lives without renewal (0 = no
+	// lease), and leaseExpires when it next runs out
+	lease        time.Duration
+	leaseExpires time.Time
+	// noEcho skips events the client published itself
+	noEcho bool
+}
+
+// auditState tracks live deliveries of a topic to a client in audit mode
+type auditState struct {
+	lastSequence int64 // topic sequence of the last live event
+	delivered    int64 // live events delivered, stamped as audit_seq
+}
+
+// ClientOptions holds per-client connection timing and queue sizing
+type ClientOptions struct {
+	// PingInterval is how often the server pings the client
+	PingInterval time.Duration
+	// PongWait is how long to wait for a pong (or any read) before giving up
+	PongWait time.Duration
+	// WriteWait is the deadline for a single write
+	WriteWait time.Duration
+	// MaxMessageSize is the largest accepted publish payload in bytes (0 = unlimited)
+	MaxMessageSize int64
+	// ReplayRate caps how many backlog messages per second are delivered
+	// when subscribing with last_n (0 = unpaced)
+	ReplayRate int
+	// GenerateMessageIDs assigns a broker-generated ID to publishes that omit one
+	GenerateMessageIDs bool
+	// PublishRate caps how often the client may publish (zero = unlimited)
+	PublishRate RateLimit
+	// MaxQueueSize is how many frames the client's send queue holds; a
+	// client that drops as many since its writer last drained the queue is
+	// disconnected as a slow consumer (0 = 100)
+	MaxQueueSize int
+}
+
+// defaultQueueSize is the send queue size when none is configured
+const defaultQueueSize = 100
+
+// frameOverhead is the allowance for the JSON envelope around a payload when
+// sizing the connection read limit
+const frameOverhead = 64 * 1024
+
+// DefaultClientOptions returns the default connection timing
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		PingInterval:   54 * time.Second,
+		PongWait:       60 * time.Second,
+		WriteWait:      10 * time.Second,
+		MaxMessageSize: 1024 * 1024,
+		ReplayRate:     1000,
+		MaxQueueSize:   defaultQueueSize,
+	}
+}
+
+// NewClientOptions derives client options from the pub/sub configuration.
+// When no ping interval is configured it is derived as 90% of the pong wait.
+func NewClientOptions(cfg config.PubSubConfig) ClientOptions {
+	opts := ClientOptions{
+		PingInterval:       cfg.PingInterval,
+		PongWait:           cfg.PongWait,
+		WriteWait:          cfg.WriteWait,
+		MaxMessageSize:     cfg.MaxMessageSize,
+		ReplayRate:         cfg.ReplayRate,
+		GenerateMessageIDs: cfg.GenerateMessageIDs,
+		MaxQueueSize:       cfg.MaxQueueSize,
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = opts.PongWait * 9 / 10
+	}
+	return opts
+}
+
+// Validate checks that the timing is usable: all values positive and pings
+// sent often enough to arrive before the pong wait expires
+func (o ClientOptions) Validate() error {
+	if o.PongWait <= 0 {
+		return fmt.Errorf("pong wait must be positive, got %s", o.PongWait)
+	}
+	if o.WriteWait <= 0 {
+		return fmt.Errorf("write wait must be positive, got %s", o.WriteWait)
+	}
+	if o.PingInterval <= 0 || o.PingInterval >= o.PongWait {
+		return fmt.Errorf("ping interval (%s) must be positive and less than pong wait (%s)", o.PingInterval, o.PongWait)
+	}
+	if o.ReplayRate < 0 {
+		return fmt.Errorf("replay rate must not be negative, got %d", o.ReplayRate)
+	}
+	if o.MaxQueueSize < 0 {
+		return fmt.Errorf("max queue size must not be negative, got %d", o.MaxQueueSize)
+	}
+	return o.PublishRate.Validate()
+}
+
+// NewClient creates a new client with a send queue of opts.MaxQueueSize
+// frames
+func NewClient(hub *Hub, conn *websocket.Conn, id string, opts ClientOptions) *Client {
+	queueSize := opts.MaxQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
 	return &Client{
 		hub:           hub,
 		conn:          conn,
-		send:          make(chan []byte, 100), // Reduced buffer size for backpressure
+		queue:         newMessageQueue(queueSize),
 		subscriptions: make(map[string]bool),
+		options:       make(map[string]subscriptionOptions),
 		id:            id,
-		maxQueueSize:  100,
-		queueSize:     0,
+		maxQueueSize:  queueSize,
 		slowConsumer:  false,
+		opts:          opts,
+		registered:    make(chan bool, 1),
+		publishLimit:  NewTokenBucket(opts.PublishRate),
+		connectedAt:   hub.clock.Now(),
+	}
+}
+
+// admit reports the hub's registration decision without blocking
+func (c *Client) admit(admitted bool) {
+	select {
+	case c.registered <- admitted:
+	default:
 	}
 }
 
+// Reject closes a connection the hub refused to register, telling the peer
+// why with a close frame whose reason is the error code. The client's pumps
+// must not have been started.
+func (c *Client) Reject(code ErrorCode) {
+	c.queue.Close()
+	c.queue.Drain()
+
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, string(code))
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(c.opts.WriteWait))
+	c.conn.Close()
+}
+
+// logger returns the default logger with the client's ID attached, so
+// records about a connection can be followed across the hub
+func (c *Client) logger() *slog.Logger {
+	return slog.Default().With(logging.ClientID, c.id)
+}
+
 // ReadPump handles reading messages from the WebSocket connection
 func (c *Client) ReadPump() {
+	c.pumpStarted()
 	defer func() {
-		c.hub.unregister <- c
+		if r := recover(); r != nil {
+			c.hub.RecordPanic("client.ReadPump", r, logging.ClientID, c.id)
+		}
+		// A hub that is shutting down no longer receives unregistrations
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.shutdown:
+		}
 		c.conn.Close()
+		// Wake the WritePump even if the hub never unregistered the client
+		c.queue.Close()
+		c.pumpStopped()
 	}()
 
-	c.conn.SetReadLimit(512)
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	// Oversized payloads are rejected per publish with MESSAGE_TOO_LARGE; the
+	// connection-level limit only guards against frames far beyond that
+	if c.opts.MaxMessageSize > 0 {
+		c.conn.SetReadLimit(c.opts.MaxMessageSize + frameOverhead)
+	}
+	c.conn.SetReadDeadline(time.Now().Add(c.opts.PongWait))
+	c.conn.SetPongHandler(func(data string) error {
+		now := time.Now()
+		c.conn.SetReadDeadline(now.Add(c.opts.PongWait))
+		c.timePong(data, now)
 		return nil
 	})
 
 	for {
-		_, messageBytes, err := c.conn.ReadMessage()
+		messageType, messageBytes, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				c.logger().Warn("WebSocket closed unexpectedly", "error", err)
 			}
 			break
 		}
 
+		messageBytes, err = c.decode(messageType, messageBytes)
+		if err != nil {
+			c.sendError("", CodeBadRequest, "Invalid MessagePack format")
+			continue
+		}
+
+		if c.jsonrpc {
+			c.handleJSONRPC(messageBytes)
+			continue
+		}
+
 		var msg ClientMessage
 		if err := json.Unmarshal(messageBytes, &msg); err != nil {
-			c.sendError("", "BAD_REQUEST", "Invalid JSON format")
+			c.sendError("", CodeBadRequest, "Invalid JSON format")
 			continue
 		}
 
@@ -72,131 +274,331 @@ func (c *Client) ReadPump() {
 
 // WritePump handles writing messages to the WebSocket connection
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	c.pumpStarted()
+	ticker := c.hub.clock.NewTicker(c.opts.PingInterval)
+	write := c.writeText
+	if c.jsonrpc {
+		write = c.writeJSONRPC
+	} else if c.encoding == encodingMsgpack {
+		write = c.writeBinary
+	}
 	defer func() {
+		if r := recover(); r != nil {
+			c.hub.RecordPanic("client.WritePump", r, logging.ClientID, c.id)
+		}
 		ticker.Stop()
 		c.conn.Close()
+		// Release frames that will never be written
+		c.queue.Close()
+		c.queue.Drain()
+		c.pumpStopped()
 	}()
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+		case <-c.queue.Ready():
+			frames, closed := c.queue.DrainFrames()
+			if err := c.writeFrames(frames, write); err != nil {
 				return
 			}
 
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if closed {
+				c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteWait))
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 
-			// Update queue size after successful send
-			c.mu.Lock()
-			if c.queueSize > 0 {
-				c.queueSize--
-			}
-			c.mu.Unlock()
-
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		case <-ticker.C():
+			c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, pingData(time.Now())); err != nil {
 				return
 			}
 		}
 	}
 }
 
+// writeText writes a text frame to the connection
+func (c *Client) writeText(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
 // handleMessage processes incoming messages from clients
 func (c *Client) handleMessage(msg *ClientMessage) {
 	switch msg.Type {
 	case PublishMessage:
 		c.handlePublish(msg)
+	case PublishBatchMessage:
+		c.handlePublishBatch(msg)
 	case SubscribeMessage:
 		c.handleSubscribe(msg)
 	case UnsubscribeMessage:
 		c.handleUnsubscribe(msg)
 	case PingMessage:
 		c.handlePing(msg)
+	case StateSetMessage:
+		c.handleStateSet(msg)
+	case StateGetMessage:
+		c.handleStateGet(msg)
 	default:
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Unknown message type")
+		c.sendError(msg.RequestID, CodeBadRequest, "Unknown message type")
 	}
 }
 
 // handlePublish processes publish requests
 func (c *Client) handlePublish(msg *ClientMessage) {
-	if msg.Topic == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Topic is required for publish")
+	receivedAt := c.hub.clock.Now()
+	if !c.allowPublish(msg, receivedAt) {
 		return
 	}
 
-	if msg.Message == nil {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Message is required for publish")
+	message, err := NewMessageFromData(msg.Topic, msg.Message, c.messageOptions()...)
+	if err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
 		return
 	}
+	message.Timestamp = receivedAt
 
-	if msg.Message.ID == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Message ID is required")
+	if msg.Topic == EchoTopic {
+		c.handleEcho(msg, receivedAt)
 		return
 	}
 
-	c.hub.publish <- &PubSubMessage{
-		Topic:     msg.Topic,
-		Message:   msg.Message,
-		Timestamp: time.Now(),
+	// Other system topics are published by the broker only
+	if IsSystemTopic(msg.Topic) {
+		c.sendErrorData(msg.RequestID, ErrorFrom(ErrReservedTopic))
+		return
+	}
+
+	if !c.permitted(msg.RequestID, auth.PermPublish, msg.Topic) {
+		return
+	}
+	c.stampAttributes(message)
+
+	// A retry of a message the topic already published is acknowledged
+	// without publishing it again
+	if !c.hub.claimMessageID(message) {
+		c.sendPublishAck(msg.RequestID, msg.Topic, message.Message.ID, PublishDuplicate)
+		return
+	}
+	if err := c.hub.enqueuePublish(message); err != nil {
+		c.hub.forgetMessageIDs([]*PubSubMessage{message})
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
 	}
 
 	// Send acknowledgment
+	c.sendPublishAck(msg.RequestID, msg.Topic, message.Message.ID, "ok")
+}
+
+// allowPublish spends a token of the client's publish rate limit on a
+// publish or publish batch, refusing it if none is left
+func (c *Client) allowPublish(msg *ClientMessage, now time.Time) bool {
+	allowed, retryAfter := c.publishLimit.Allow(now)
+	if !allowed {
+		c.sendErrorData(msg.RequestID, c.rateLimited(retryAfter))
+		c.hub.ReportQuota(QuotaEvent{
+			Quota:    QuotaPublishRate,
+			Identity: c.identity(),
+			Topic:    msg.Topic,
+			Limit:    int64(c.opts.PublishRate.PerMinute),
+		})
+	}
+	return allowed
+}
+
+// messageOptions returns the options the client's publishes are built with
+func (c *Client) messageOptions() []MessageOption {
+	opts := []MessageOption{WithMaxSize(c.opts.MaxMessageSize), WithPublisher(WebSocketIdentity(c.id))}
+	if c.opts.GenerateMessageIDs {
+		opts = append(opts, WithGeneratedID())
+	}
+	return opts
+}
+
+// rateLimited creates the error for a publish over the client's rate limit
+func (c *Client) rateLimited(retryAfter time.Duration) *ErrorData {
+	errorData := NewError(CodeRateLimited, fmt.Sprintf("Publish rate limit of %d per minute exceeded", c.opts.PublishRate.PerMinute))
+	errorData.Limit = int64(c.opts.PublishRate.PerMinute)
+	errorData.RetryAfterMs = max(retryAfter.Milliseconds(), 1)
+	return errorData
+}
+
+// handleEcho answers a publish to the diagnostic echo topic by delivering
+// the message straight back to the publisher with server timestamps
+func (c *Client) handleEcho(msg *ClientMessage, receivedAt time.Time) {
 	c.sendAck(msg.RequestID, msg.Topic, "ok")
+
+	data := c.hub.createEchoMessageBytes(msg.RequestID, msg.Message, receivedAt)
+	c.sendWithBackpressure(EchoTopic, data)
 }
 
 // handleSubscribe processes subscription requests
 func (c *Client) handleSubscribe(msg *ClientMessage) {
 	if msg.Topic == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Topic is required for subscribe")
+		c.sendError(msg.RequestID, CodeBadRequest, "Topic is required for subscribe")
 		return
 	}
 
 	if msg.ClientID == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Client ID is required for subscribe")
+		c.sendError(msg.RequestID, CodeBadRequest, "Client ID is required for subscribe")
+		return
+	}
+
+	if err := validateFields(msg.Fields); err != nil {
+		c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+		return
+	}
+
+	filter, err := c.resolveFilter(msg.Filter)
+	if err != nil {
+		c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+		return
+	}
+
+	where, err := parseWhere(msg.Where)
+	if err != nil {
+		c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+		return
+	}
+
+	if msg.Group != "" {
+		if err := ValidateGroupName(msg.Group); err != nil {
+			c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+			return
+		}
+	}
+
+	if msg.MaxLatency < 0 {
+		c.sendError(msg.RequestID, CodeBadRequest, "max_latency must not be negative")
+		return
+	}
+
+	if err := validateLease(msg.LeaseMs); err != nil {
+		c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+		return
+	}
+
+	// A client's own inbox is its to read whatever the ACL says; other
+	// clients' inboxes are off limits
+	if err := checkInboxSubscribe(msg.Topic, c.id); err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+	if msg.Topic != InboxTopic(c.id) && !c.permitted(msg.RequestID, auth.PermSubscribe, msg.Topic) {
+		return
+	}
+
+	if replacement, draining := c.hub.drainingTopic(msg.Topic); draining {
+		message := "Topic is draining"
+		if replacement != "" {
+			message += "; subscribe to " + replacement + " instead"
+		}
+		c.sendErrorData(msg.RequestID, &ErrorData{
+			Code:        CodeTopicDraining,
+			Message:     message,
+			Replacement: replacement,
+		})
+		return
+	}
+
+	if err := c.hub.checkNotDeleted(msg.Topic); err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+
+	if err := c.hub.checkSubscribeKey(msg.Topic, msg.KeyID); err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
 		return
 	}
 
 	c.mu.Lock()
 	c.subscriptions[msg.Topic] = true
+	c.options[msg.Topic] = subscriptionOptions{
+		fields:     msg.Fields,
+		filter:     filter,
+		where:      where,
+		group:      msg.Group,
+		keyID:      msg.KeyID,
+		maxLatency: time.Duration(msg.MaxLatency) * time.Millisecond,
+		lease:      time.Duration(msg.LeaseMs) * time.Millisecond,
+		noEcho:     msg.EchoSelf != nil && !*msg.EchoSelf,
+	}
+	delete(c.audit, msg.Topic)
+	delivered := c.beginReplayDedup(msg.Topic)
+	lease := c.grantLease(msg.Topic, c.hub.clock.Now())
 	c.mu.Unlock()
 
-	c.hub.subscribe <- &Subscription{
-		client: c,
-		topic:  msg.Topic,
+	if err := c.hub.awaitSubscribe(&Subscription{client: c, topic: msg.Topic}); err != nil {
+		return
+	}
+
+	// Acknowledge with the topic's delivery state, then replay the backlog
+	info, backlog := c.hub.prepareReplay(msg.Topic, msg.LastN, msg.Group)
+	if filter != nil || where != nil {
+		backlog = filterMessages(backlog, filter, where)
+	}
+	if msg.EchoSelf != nil && !*msg.EchoSelf {
+		backlog = c.othersMessages(backlog)
 	}
+	backlog = c.finishReplayDedup(msg.Topic, delivered, backlog)
+	info.Replaying = len(backlog)
+	info.Lease = lease
+	c.sendSubscribeAck(msg.RequestID, msg.Topic, info)
 
-	// Send historical messages if requested
-	if msg.LastN > 0 {
-		recentMessages := c.hub.GetRecentMessages(msg.Topic, msg.LastN)
-		for _, recentMsg := range recentMessages {
-			c.sendEvent(recentMsg)
+	if len(backlog) > 0 {
+		go c.replay(msg.Topic, backlog)
+	}
+}
+
+// replay delivers a subscription backlog paced at the configured replay rate,
+// so a large backlog trickles into the send queue instead of overflowing it.
+// Replay stops early if the client unsubscribes or disconnects.
+func (c *Client) replay(topic string, messages []*PubSubMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.hub.RecordPanic("client.replay", r, logging.ClientID, c.id)
+		}
+	}()
+
+	var pace <-chan time.Time
+	if c.opts.ReplayRate > 0 {
+		if interval := time.Second / time.Duration(c.opts.ReplayRate); interval > 0 {
+			ticker := c.hub.clock.NewTicker(interval)
+			defer ticker.Stop()
+			pace = ticker.C()
 		}
 	}
 
-	// Send acknowledgment
-	c.sendAck(msg.RequestID, msg.Topic, "ok")
+	for i, recentMsg := range messages {
+		if i > 0 && pace != nil {
+			<-pace
+		}
+		if c.queue.Closed() || !c.IsSubscribed(topic) {
+			return
+		}
+		c.sendReplayEvent(recentMsg)
+	}
 }
 
 // handleUnsubscribe processes unsubscription requests
 func (c *Client) handleUnsubscribe(msg *ClientMessage) {
 	if msg.Topic == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Topic is required for unsubscribe")
+		c.sendError(msg.RequestID, CodeBadRequest, "Topic is required for unsubscribe")
 		return
 	}
 
 	if msg.ClientID == "" {
-		c.sendError(msg.RequestID, "BAD_REQUEST", "Client ID is required for unsubscribe")
+		c.sendError(msg.RequestID, CodeBadRequest, "Client ID is required for unsubscribe")
 		return
 	}
 
+	// Audit state is kept until the next subscribe, since events already
+	// fanned out may still be delivered after the unsubscribe
 	c.mu.Lock()
 	delete(c.subscriptions, msg.Topic)
+	delete(c.options, msg.Topic)
+	c.endReplayDedup(msg.Topic)
 	c.mu.Unlock()
 
 	c.hub.unsubscribe <- &Subscription{
@@ -208,91 +610,262 @@ func (c *Client) handleUnsubscribe(msg *ClientMessage) {
 	c.sendAck(msg.RequestID, msg.Topic, "ok")
 }
 
-// handlePing responds to ping messages
+// handlePing responds to ping messages. A ping carrying the client's lease
+// token renews its leased subscriptions.
 func (c *Client) handlePing(msg *ClientMessage) {
-	c.sendPong(msg.RequestID)
+	if err := validatePingData(msg.Data); err != nil {
+		c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+		return
+	}
+
+	var lease *LeaseInfo
+	if msg.Lease != "" {
+		var err error
+		if lease, err = c.renewLeases(msg.Lease); err != nil {
+			c.sendError(msg.RequestID, CodeBadRequest, err.Error())
+			return
+		}
+	}
+	c.sendPong(msg.RequestID, msg.Data, lease)
 }
 
-// sendWithBackpressure handles message sending with backpressure management
-func (c *Client) sendWithBackpressure(data []byte) {
+// sendWithBackpressure handles message sending with backpressure management.
+// When the queue is full the oldest queued message is dropped; if the writer
+// fails to drain anything while a full queue's worth of messages is dropped,
+// the client is marked as a slow consumer and disconnected. topic is set for
+// event frames so drops can be attributed to the topic that lost data.
+func (c *Client) sendWithBackpressure(topic string, data []byte) {
+	c.sendFrame(queuedFrame{topic: topic, data: c.encode(data)})
+}
+
+// sendFrame is sendWithBackpressure for a frame carrying a sequence or
+// delivery deadline, already in the client's encoding
+func (c *Client) sendFrame(frame queuedFrame) {
+	dropped, slow := c.enqueue(frame)
+
+	// Account the dropped event and report the slow consumer outside the
+	// client lock
+	if dropped != nil && dropped.topic != "" {
+		c.hub.recordDrops(dropped.topic, 1)
+		c.hub.deadLetter(dropped.message, DropQueueOverflow, c.identity())
+	}
+	if slow != nil {
+		c.hub.recordClientError(c, "", slow)
+		c.hub.ReportQuota(QuotaEvent{
+			Quota:    QuotaQueue,
+			Identity: c.identity(),
+			Limit:    int64(c.maxQueueSize),
+		})
+	}
+}
+
+// enqueue pushes a frame onto the send queue and returns the frame dropped
+// to make room, if any, and the error sent if the client was just marked as
+// a slow consumer
+func (c *Client) enqueue(frame queuedFrame) (*queuedFrame, *ErrorData) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	// Check if client is marked as slow consumer
 	if c.slowConsumer {
-		return
+		return nil, nil
 	}
 
-	// Try to send immediately
-	select {
-	case c.send <- data:
-		c.queueSize++
-		return
-	default:
-		// Queue is full, handle overflow
-		c.handleQueueOverflow()
+	dropped, ok := c.queue.PushFrame(frame)
+	if !ok || dropped == nil {
+		return nil, nil
 	}
-}
 
-// handleQueueOverflow handles queue overflow according to policy
-func (c *Client) handleQueueOverflow() {
-	// Policy: Drop oldest message and add new one
-	select {
-	case <-c.send: // Remove oldest message
-		c.queueSize--
-		select {
-		case c.send <- <-c.send: // Add new message
-			c.queueSize++
-		default:
-			// Still can't add, mark as slow consumer
-			c.slowConsumer = true
-			c.sendSlowConsumerError()
-		}
-	default:
-		// Can't remove any message, mark as slow consumer
+	if c.queue.DropsSinceDrain() >= c.maxQueueSize {
 		c.slowConsumer = true
-		c.sendSlowConsumerError()
+		return dropped, c.sendSlowConsumerError()
 	}
+	return dropped, nil
 }
 
-// sendSlowConsumerError sends SLOW_CONSUMER error and disconnects
-func (c *Client) sendSlowConsumerError() {
-	errorData := c.hub.createErrorMessageBytes("", "SLOW_CONSUMER", "Client queue overflow, disconnecting")
-	select {
-	case c.send <- errorData:
-	default:
-		// Can't even send error, force close
-	}
+// sendSlowConsumerError sends SLOW_CONSUMER error and disconnects. Caller
+// must hold c.mu.
+func (c *Client) sendSlowConsumerError() *ErrorData {
+	errorData := NewError(CodeSlowConsumer, "Client queue overflow, disconnecting")
+	c.queue.Push("", c.encode(c.hub.createErrorDataMessageBytes("", errorData)))
 
 	// Schedule disconnection
 	go func() {
 		time.Sleep(100 * time.Millisecond) // Give time for error to be sent
-		c.conn.Close()
+		c.disconnect()
 	}()
+	return errorData
 }
 
 // sendAck sends an acknowledgment message
 func (c *Client) sendAck(requestID, topic, status string) {
 	data := c.hub.createAckMessageBytes(requestID, topic, status)
-	c.sendWithBackpressure(data)
+	c.sendWithBackpressure("", data)
+}
+
+// sendPublishAck sends a publish acknowledgment carrying the message ID
+func (c *Client) sendPublishAck(requestID, topic, messageID, status string) {
+	data := c.hub.createPublishAckMessageBytes(requestID, topic, messageID, status)
+	c.sendWithBackpressure("", data)
+}
+
+// sendSubscribeAck sends a subscribe acknowledgment with delivery statistics
+func (c *Client) sendSubscribeAck(requestID, topic string, info *SubscriptionInfo) {
+	data := c.hub.createSubscribeAckMessageBytes(requestID, topic, info)
+	c.sendWithBackpressure("", data)
 }
 
 // sendError sends an error message to the client
-func (c *Client) sendError(requestID, errorCode, errorMsg string) {
-	data := c.hub.createErrorMessageBytes(requestID, errorCode, errorMsg)
-	c.sendWithBackpressure(data)
+func (c *Client) sendError(requestID string, errorCode ErrorCode, errorMsg string) {
+	c.sendErrorData(requestID, NewError(errorCode, errorMsg))
 }
 
-// sendPong sends a pong message
-func (c *Client) sendPong(requestID string) {
-	data := c.hub.createPongMessageBytes(requestID)
-	c.sendWithBackpressure(data)
+// sendErrorData sends an error message with a fully populated error body
+func (c *Client) sendErrorData(requestID string, errorData *ErrorData) {
+	data := c.hub.createErrorDataMessageBytes(requestID, errorData)
+	c.sendWithBackpressure("", data)
+	c.hub.recordClientError(c, requestID, errorData)
 }
 
-// sendEvent sends an event message
+// sendPong sends a pong message, echoing the ping's data, with the leases
+// the ping renewed
+func (c *Client) sendPong(requestID string, echo json.RawMessage, lease *LeaseInfo) {
+	data := c.hub.createPongMessageBytes(requestID, echo, lease)
+	c.sendWithBackpressure("", data)
+}
+
+// sendWelcome sends the welcome info frame with server build information
+// and the client's inbox
+func (c *Client) sendWelcome() {
+	inbox := ""
+	if !c.stream {
+		inbox = InboxTopic(c.id)
+	}
+	data := c.hub.createWelcomeMessageBytes(c.id, inbox)
+	c.sendWithBackpressure("", data)
+}
+
+// sendEvent sends a live event from the hub's fan-out
 func (c *Client) sendEvent(msg *PubSubMessage) {
-	data := c.hub.createEventMessageBytes(msg)
-	c.sendWithBackpressure(data)
+	c.deliverEvent(msg, true)
+}
+
+// sendReplayEvent sends a backlog event. Replayed events are older than the
+// live events they may interleave with, so they are not ordering-audited.
+func (c *Client) sendReplayEvent(msg *PubSubMessage) {
+	c.deliverEvent(msg, false)
+}
+
+// deliverEvent sends an event message, skipping events the subscription's
+// filter doesn't match, applying its payload projection, auditing live
+// delivery order, bounding live events' queueing time by the subscription's
+// max_latency and any event's by its TTL, and advancing its consumer group
+// offset
+func (c *Client) deliverEvent(msg *PubSubMessage, live bool) {
+	var auditSeq, previous int64
+	violation := false
+
+	c.mu.Lock()
+	opts := c.options[msg.Topic]
+	// Subscribers that predate an encrypted topic's creation never
+	// presented its key
+	if msg.keyID != "" && opts.keyID != msg.keyID {
+		c.mu.Unlock()
+		return
+	}
+	if !matchesFilter(msg, opts.filter) || !opts.where.match(msg) || (opts.noEcho && c.published(msg)) || c.duplicateEvent(msg, live) {
+		c.mu.Unlock()
+		return
+	}
+	// Messages on topics that were never created carry no sequence
+	if live && c.hub.orderingAudit && msg.Sequence > 0 {
+		if c.audit == nil {
+			c.audit = make(map[string]*auditState)
+		}
+		state, exists := c.audit[msg.Topic]
+		if !exists {
+			state = &auditState{}
+			c.audit[msg.Topic] = state
+		}
+		previous = state.lastSequence
+		violation = msg.Sequence <= previous
+		state.lastSequence = msg.Sequence
+		state.delivered++
+		auditSeq = state.delivered
+	}
+	c.mu.Unlock()
+
+	// Report outside the client lock
+	if violation {
+		c.hub.recordOrderingViolation(msg.Topic, c.id, previous, msg.Sequence)
+	}
+
+	event := msg
+	if len(opts.fields) > 0 {
+		event = msg.project(opts.fields)
+	}
+
+	frame := queuedFrame{
+		topic:    msg.Topic,
+		data:     c.hub.eventFrame(event, auditSeq, c.encoding),
+		sequence: msg.Sequence,
+		message:  msg,
+	}
+	// Replayed events are stale by design, so only live ones miss their
+	// max_latency, but no event is delivered once its TTL runs out
+	if live && opts.maxLatency > 0 {
+		frame.deadline = c.hub.clock.Now().Add(opts.maxLatency)
+	}
+	if expiresAt := msg.expiresAt(); !expiresAt.IsZero() && (frame.deadline.IsZero() || expiresAt.Before(frame.deadline)) {
+		frame.deadline = expiresAt
+	}
+	c.sendFrame(frame)
+
+	if opts.group != "" {
+		c.hub.commitGroupOffset(msg.Topic, opts.group, msg.Sequence)
+	}
+}
+
+// subscriptionGroup returns the consumer group the client's subscription to
+// a topic belongs to, "" if none
+func (c *Client) subscriptionGroup(topic string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.options[topic].group
+}
+
+// published reports whether the client published a message itself
+func (c *Client) published(msg *PubSubMessage) bool {
+	return msg.publisher != "" && msg.publisher == c.identity()
+}
+
+// othersMessages returns the messages the client didn't publish itself
+func (c *Client) othersMessages(messages []*PubSubMessage) []*PubSubMessage {
+	others := make([]*PubSubMessage, 0, len(messages))
+	for _, message := range messages {
+		if !c.published(message) {
+			others = append(others, message)
+		}
+	}
+	return others
+}
+
+// identity names the client in quota events and as a publisher
+func (c *Client) identity() string {
+	if c.stream {
+		return StreamIdentity(c.id)
+	}
+	return WebSocketIdentity(c.id)
+}
+
+// disconnect closes the client's connection. Stream subscribers have none;
+// closing their queue ends the stream once it is drained.
+func (c *Client) disconnect() {
+	if c.stream {
+		c.queue.Close()
+		return
+	}
+	c.conn.Close()
 }
 
 // IsSubscribed checks if the client is subscribed to a topic
@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"sort"
+	"time"
+)
+
+// ClientInfo describes a connected client, for GET /clients/{id}
+type ClientInfo struct {
+	ID          string    `json:"id"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Stream is set for subscribers without a WebSocket, such as SSE,
+	// MQTT and sinks, which have no round-trip times
+	Stream bool `json:"stream,omitempty"`
+	// Attributes are the connection attributes set at connect time
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Inbox is the client's private reply topic, unset for streams
+	Inbox string `json:"inbox,omitempty"`
+	// Subscriptions are the topics the client is subscribed to, sorted
+	Subscriptions []string `json:"subscriptions"`
+	// QueueDepth is how many frames wait in the client's send queue, out
+	// of QueueCapacity
+	QueueDepth    int      `json:"queue_depth"`
+	QueueCapacity int      `json:"queue_capacity"`
+	RTT           RTTStats `json:"rtt"`
+}
+
+// GetClient describes the connected client with the given ID
+func (h *Hub) GetClient(id string) (ClientInfo, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if client.id == id {
+			return client.info(), nil
+		}
+	}
+	return ClientInfo{}, ErrClientNotFound
+}
+
+// info describes the client. Caller must hold the hub lock.
+func (c *Client) info() ClientInfo {
+	c.mu.RLock()
+	subscriptions := make([]string, 0, len(c.subscriptions))
+	for topic := range c.subscriptions {
+		subscriptions = append(subscriptions, topic)
+	}
+	c.mu.RUnlock()
+	sort.Strings(subscriptions)
+
+	info := ClientInfo{
+		ID:            c.id,
+		ConnectedAt:   c.connectedAt,
+		Stream:        c.stream,
+		Attributes:    c.attrs,
+		Subscriptions: subscriptions,
+		QueueDepth:    c.queue.Len(),
+		QueueCapacity: c.maxQueueSize,
+		RTT:           c.rtt.snapshot(),
+	}
+	if !c.stream {
+		info.Inbox = InboxTopic(c.id)
+	}
+	return info
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the hub the time and schedules its timed work: message TTLs,
+// retention and idle-group sweeps, trash purges, leases, pause timeouts,
+// subscriber latency deadlines and client heartbeats. Tests substitute a
+// ManualClock to advance time deterministically instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a ticker firing every d
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f once d has passed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks on a channel, as time.Ticker does
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is a pending AfterFunc call, as time.Timer is
+type Timer interface {
+	// Stop cancels the call, reporting whether it was still pending
+	Stop() bool
+	// Reset reschedules the call to d from now, reporting whether it was
+	// still pending
+	Reset(d time.Duration) bool
+}
+
+// Now returns the time on the hub's clock, for callers that stamp work the
+// hub later judges by it, such as the receive time of REST publishes
+func (h *Hub) Now() time.Time {
+	return h.clock.Now()
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+// SystemClock returns the wall clock the hub uses unless told otherwise
+func SystemClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// realTicker adapts time.Ticker, whose channel is a field, to Ticker
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// ManualClock is a Clock that only moves when advanced. Tickers and timers
+// fire during Advance, once the clock reaches them, so a test sees their
+// effects as soon as Advance returns; timer functions run synchronously,
+// and ticks are dropped, as on time.Ticker, if the last one hasn't been
+// received.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+}
+
+// manualWaiter is a ManualClock ticker or timer
+type manualWaiter struct {
+	clock *ManualClock
+	at    time.Time
+	// period repeats a ticker, whose ticks go to ch; timers call fn
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+// NewManualClock returns a ManualClock reading start
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker firing every d of advanced time
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("pubsub: non-positive interval for ManualClock.NewTicker")
+	}
+	w := &manualWaiter{clock: c, period: d, ch: make(chan time.Time, 1)}
+	c.schedule(w, d)
+	return manualTicker{w}
+}
+
+// AfterFunc calls f during the Advance that moves the clock d past now
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	w := &manualWaiter{clock: c, fn: f}
+	c.schedule(w, d)
+	return w
+}
+
+// Advance moves the clock forward by d, firing the tickers and timers due
+// on the way in time order
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		w := c.nextDue(target)
+		if w == nil {
+			break
+		}
+		at := w.at
+		c.now = at
+		c.remove(w)
+		if w.period > 0 {
+			c.insert(w, at.Add(w.period))
+			select {
+			case w.ch <- at:
+			default:
+			}
+			continue
+		}
+		// Timer functions may use the clock themselves
+		c.mu.Unlock()
+		w.fn()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// schedule adds a waiter due d from now
+func (c *ManualClock) schedule(w *manualWaiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.insert(w, c.now.Add(d))
+}
+
+// insert adds a waiter due at at, keeping waiters in due order. Caller must
+// hold c.mu.
+func (c *ManualClock) insert(w *manualWaiter, at time.Time) {
+	w.at = at
+	i := sort.Search(len(c.waiters), func(i int) bool { return c.waiters[i].at.After(at) })
+	c.waiters = append(c.waiters, nil)
+	copy(c.waiters[i+1:], c.waiters[i:])
+	c.waiters[i] = w
+}
+
+// remove drops a waiter, reporting whether it was pending. Caller must hold
+// c.mu.
+func (c *ManualClock) remove(w *manualWaiter) bool {
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// nextDue returns the earliest waiter due by target, or nil. Caller must
+// hold c.mu.
+func (c *ManualClock) nextDue(target time.Time) *manualWaiter {
+	if len(c.waiters) == 0 || c.waiters[0].at.After(target) {
+		return nil
+	}
+	return c.waiters[0]
+}
+
+func (w *manualWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *manualWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	pending := w.clock.remove(w)
+	w.clock.insert(w, w.clock.now.Add(d))
+	return pending
+}
+
+// manualTicker is a ManualClock ticker
+type manualTicker struct {
+	*manualWaiter
+}
+
+func (t manualTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t manualTicker) Stop() {
+	t.manualWaiter.Stop()
+}
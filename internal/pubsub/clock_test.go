@@ -0,0 +1,100 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	var fired []string
+	clock.AfterFunc(3*time.Second, func() { fired = append(fired, "late") })
+	early := clock.AfterFunc(time.Second, func() { fired = append(fired, "early at "+clock.Now().Sub(start).String()) })
+	stopped := clock.AfterFunc(2*time.Second, func() { fired = append(fired, "stopped") })
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	if !stopped.Stop() {
+		t.Error("Expected Stop to report the pending timer")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Fatalf("Expected nothing due yet, got %v", fired)
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("Expected no tick before the interval")
+	default:
+	}
+
+	// Timers fire in due order, each seeing the clock at its due time
+	clock.Advance(3 * time.Second)
+	if len(fired) != 2 || fired[0] != "early at 1s" || fired[1] != "late" {
+		t.Errorf("Expected early then late, got %v", fired)
+	}
+	if !clock.Now().Equal(start.Add(3500 * time.Millisecond)) {
+		t.Errorf("Expected the clock at 3.5s, got %v", clock.Now().Sub(start))
+	}
+	// Unreceived ticks are dropped rather than queued
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected the first tick at 1s, got %v", tick.Sub(start))
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("Expected later ticks dropped, got %v", tick.Sub(start))
+	default:
+	}
+
+	if early.Reset(time.Second) {
+		t.Error("Expected Reset to report the timer had fired")
+	}
+	clock.Advance(time.Second)
+	if len(fired) != 3 || fired[2] != "early at 4.5s" {
+		t.Errorf("Expected the reset timer to fire again, got %v", fired)
+	}
+}
+
+func TestHubFollowsManualClock(t *testing.T) {
+	clock := NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts := DefaultHubOptions()
+	opts.TrashWindow = time.Minute
+	opts.Clock = clock
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+	hub.CreateTopic("archived")
+	client := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+
+	// Message TTLs count from the clock's publish time
+	hub.publishMessage(&PubSubMessage{Topic: "orders", Timestamp: clock.Now(), Message: &MessageData{ID: "a", TTLMs: 1000}})
+	clock.Advance(time.Second)
+	if retained := hub.GetRecentMessages("orders", 10); len(retained) != 1 {
+		t.Fatalf("Expected the message retained until its TTL, got %d", len(retained))
+	}
+	clock.Advance(time.Millisecond)
+	if retained := hub.GetRecentMessages("orders", 10); len(retained) != 0 {
+		t.Errorf("Expected the message expired past its TTL, got %d", len(retained))
+	}
+
+	// Deleted topics are purged once the trash window has passed
+	hub.DeleteTopic("archived")
+	clock.Advance(59 * time.Second)
+	hub.purgeExpiredTopics()
+	if _, restorable := hub.DeletedTopicInfo("archived"); !restorable {
+		t.Fatal("Expected the topic restorable within the trash window")
+	}
+	clock.Advance(time.Second)
+	hub.purgeExpiredTopics()
+	if _, restorable := hub.DeletedTopicInfo("archived"); restorable {
+		t.Error("Expected the topic purged after the trash window")
+	}
+
+	// A timed pause ends as soon as the clock passes its timeout
+	hub.Pause(PauseOptions{TimeoutMs: 1000})
+	clock.Advance(time.Second)
+	if hub.PauseStatus() != nil {
+		t.Error("Expected the pause to time out with the clock")
+	}
+}
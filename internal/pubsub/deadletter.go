@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"plivo/internal/logging"
+)
+
+// DeadLetterSuffix names a topic's conventional dead-letter topic,
+// <topic>.dlq
+const DeadLetterSuffix = ".dlq"
+
+// Headers the broker stamps on dead letters, so operators can tell why and
+// where a message was dropped. A message carrying them is never dead
+// lettered again, so dead-letter topics can't feed each other in a loop.
+const (
+	// DeadLetterHeaderPrefix starts every dead-letter header
+	DeadLetterHeaderPrefix = ReservedHeaderPrefix + "dlq."
+	// DeadLetterReasonHeader is the DropReason
+	DeadLetterReasonHeader = DeadLetterHeaderPrefix + "reason"
+	// DeadLetterTopicHeader is the topic the message was published to
+	DeadLetterTopicHeader = DeadLetterHeaderPrefix + "topic"
+	// DeadLetterSequenceHeader is the message's sequence in that topic
+	DeadLetterSequenceHeader = DeadLetterHeaderPrefix + "sequence"
+	// DeadLetterSubscriberHeader identifies the subscriber that lost it
+	DeadLetterSubscriberHeader = DeadLetterHeaderPrefix + "subscriber"
+	// DeadLetterDroppedAtHeader is when it was dropped (RFC3339Nano)
+	DeadLetterDroppedAtHeader = DeadLetterHeaderPrefix + "dropped_at"
+)
+
+// DropReason says why an event was dropped instead of delivered
+type DropReason string
+
+// Drop reasons
+const (
+	// DropQueueOverflow is an event pushed out of a full send queue
+	DropQueueOverflow DropReason = "queue_overflow"
+	// DropMaxLatency is a live event that waited longer than its
+	// subscription's max_latency
+	DropMaxLatency DropReason = "max_latency"
+	// DropExpired is an event whose TTL ran out before it was written
+	DropExpired DropReason = "expired"
+)
+
+// validateDeadLetter checks a topic's dead-letter topic name
+func validateDeadLetter(topic, deadLetter string) error {
+	switch {
+	case deadLetter == "":
+		return nil
+	case deadLetter == topic:
+		return fmt.Errorf("%w: a topic can't be its own dead-letter topic", ErrInvalidDeadLetter)
+	case IsSystemTopic(deadLetter):
+		return fmt.Errorf("%w: %v", ErrInvalidDeadLetter, ErrReservedTopic)
+	}
+	return nil
+}
+
+// ensureDeadLetterTopic creates a topic's dead-letter topic, owned by the
+// same tenant, unless it already exists or can still be restored. Caller
+// must hold the hub write lock.
+func (h *Hub) ensureDeadLetterTopic(topic *Topic) {
+	if topic.deadLetter == "" {
+		return
+	}
+	h.ensureCompanionTopic(topic.deadLetter, topic.owner)
+}
+
+// ensureCompanionTopic creates a topic another one publishes into, such as
+// its dead-letter or shadow topic, with default settings and the same
+// owner, unless it already exists or can still be restored. Caller must
+// hold the hub write lock.
+func (h *Hub) ensureCompanionTopic(name, owner string) {
+	if _, exists := h.topics[name]; exists || h.trashed(name) != nil {
+		return
+	}
+	delete(h.trash, name)
+
+	h.topics[name] = &Topic{
+		Name:           name,
+		CreatedAt:      h.clock.Now(),
+		payloadSizes:   NewSizeHistogram(),
+		owner:          owner,
+		ringBufferSize: h.ringBufferSize,
+		revision:       1,
+	}
+	logStoreError("create topic", h.store.CreateTopic(name))
+	h.applyRetention(h.topics[name])
+	h.persist("create topic", func(s Storage) error { return s.SaveTopic(h.topics[name].snapshot()) })
+	h.updateSubscriberCount(name)
+	h.stats.TotalTopics = len(h.topics)
+}
+
+// deadLetter publishes an event a subscriber lost to its topic's dead-letter
+// topic, stamped with why, where and when it was dropped. Topics without a
+// dead-letter topic drop events as before. Like system events, dead letters
+// never wait for room in the backlog, since drops happen on the hub loop's
+// fan-out; one that finds the backlog full is lost and logged.
+func (h *Hub) deadLetter(message *PubSubMessage, reason DropReason, subscriber string) {
+	if message == nil || message.Message == nil {
+		return
+	}
+	if _, isDeadLetter := message.Message.Headers[DeadLetterReasonHeader]; isDeadLetter {
+		return
+	}
+
+	h.mu.RLock()
+	var target *Topic
+	if topic, exists := h.topics[message.Topic]; exists && topic.deadLetter != "" {
+		target = h.topics[topic.deadLetter]
+	}
+	h.mu.RUnlock()
+	if target == nil {
+		return
+	}
+
+	now := h.clock.Now()
+	headers := make(map[string]string, len(message.Message.Headers)+5)
+	for key, value := range message.Message.Headers {
+		headers[key] = value
+	}
+	headers[DeadLetterReasonHeader] = string(reason)
+	headers[DeadLetterTopicHeader] = message.Topic
+	headers[DeadLetterSequenceHeader] = strconv.FormatInt(message.Sequence, 10)
+	headers[DeadLetterSubscriberHeader] = subscriber
+	headers[DeadLetterDroppedAtHeader] = now.UTC().Format(time.RFC3339Nano)
+
+	data := *message.Message
+	data.Headers = headers
+	// The dead-letter topic's retention decides how long it is kept
+	data.TTLMs = 0
+	letter := &PubSubMessage{Topic: target.Name, Message: &data, Timestamp: now}
+
+	if _, err := h.publishes.push(target.Name, letter, target.schedulingWeight(), nil, expired); err != nil {
+		slog.Error("Lost dead letter", logging.Topic, message.Topic, "dead_letter_topic", target.Name, "message_id", data.ID, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	if topic, exists := h.topics[message.Topic]; exists {
+		topic.deadLettered++
+	}
+	h.mu.Unlock()
+}
+
+// deadlineReason says why a frame that missed its deadline was dropped
+func deadlineReason(frame queuedFrame, now time.Time) DropReason {
+	if frame.message != nil && frame.message.expired(now) {
+		return DropExpired
+	}
+	return DropMaxLatency
+}
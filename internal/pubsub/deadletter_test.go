@@ -0,0 +1,129 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterTopicIsCreated(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{Owner: "acme", DeadLetter: "orders" + DeadLetterSuffix}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+
+	stats, err := hub.GetTopicStats("orders.dlq")
+	if err != nil {
+		t.Fatalf("Expected the dead-letter topic created: %v", err)
+	}
+	if stats.Owner != "acme" {
+		t.Errorf("Expected the dead-letter topic owned by acme, got %q", stats.Owner)
+	}
+
+	tests := []struct {
+		name       string
+		deadLetter string
+		want       error
+	}{
+		{"itself", "payments", ErrInvalidDeadLetter},
+		{"system topic", QuotaTopic, ErrInvalidDeadLetter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := hub.CreateTopicWithOptions("payments", TopicOptions{DeadLetter: tt.deadLetter}); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestQueueOverflowIsDeadLettered(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{DeadLetter: "orders.dlq"})
+
+	client := newTestClient(hub)
+	client.id = "slow"
+	client.queue = newMessageQueue(2)
+	for i := int64(1); i <= 3; i++ {
+		client.sendEvent(&PubSubMessage{
+			Topic:    "orders",
+			Message:  &MessageData{ID: "msg", Payload: i, Headers: map[string]string{"region": "eu"}},
+			Sequence: i,
+		})
+	}
+
+	letter, ok := hub.publishes.next()
+	if !ok || letter.Topic != "orders.dlq" {
+		t.Fatalf("Expected a dead letter queued for orders.dlq, got %+v", letter)
+	}
+	headers := letter.Message.Headers
+	if headers[DeadLetterReasonHeader] != string(DropQueueOverflow) || headers[DeadLetterTopicHeader] != "orders" ||
+		headers[DeadLetterSequenceHeader] != "1" || headers[DeadLetterSubscriberHeader] != "websocket:slow" {
+		t.Errorf("Unexpected dead-letter headers: %v", headers)
+	}
+	if headers["region"] != "eu" || letter.Message.Payload != int64(1) {
+		t.Errorf("Expected the dropped event's payload and headers kept, got %+v", letter.Message)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.DeadLettered != 1 || stats.DroppedCount != 1 {
+		t.Errorf("Expected 1 drop dead lettered, got %+v", stats)
+	}
+}
+
+func TestMissedDeadlinesAreDeadLettered(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{DeadLetter: "orders.dlq"})
+	client := newTestClient(hub)
+
+	past := time.Now().Add(-time.Second)
+	late := &PubSubMessage{Topic: "orders", Message: &MessageData{ID: "late"}, Sequence: 1, Timestamp: time.Now()}
+	stale := &PubSubMessage{Topic: "orders", Message: &MessageData{ID: "stale", TTLMs: 10}, Sequence: 2, Timestamp: past}
+	frames := []queuedFrame{
+		{topic: "orders", sequence: 1, deadline: past, message: late},
+		{topic: "orders", sequence: 2, deadline: stale.expiresAt(), message: stale},
+	}
+	if err := client.writeFrames(frames, func([]byte) error { return nil }); err != nil {
+		t.Fatalf("writeFrames failed: %v", err)
+	}
+
+	for _, want := range []struct {
+		id     string
+		reason DropReason
+	}{{"late", DropMaxLatency}, {"stale", DropExpired}} {
+		letter, ok := hub.publishes.next()
+		if !ok {
+			t.Fatalf("Expected a dead letter for %s", want.id)
+		}
+		if letter.Message.ID != want.id || letter.Message.Headers[DeadLetterReasonHeader] != string(want.reason) {
+			t.Errorf("Expected %s dead lettered for %s, got %+v", want.id, want.reason, letter.Message)
+		}
+		if letter.Message.TTLMs != 0 {
+			t.Errorf("Expected the dead letter's TTL cleared, got %d", letter.Message.TTLMs)
+		}
+	}
+}
+
+func TestDeadLettersAreNotDeadLettered(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{DeadLetter: "orders.dlq"})
+	// Even dead-letter topics pointing at each other can't loop
+	back := "orders"
+	if _, err := hub.UpdateTopic("orders.dlq", TopicUpdate{DeadLetter: &back}, 0); err != nil {
+		t.Fatalf("UpdateTopic failed: %v", err)
+	}
+	letter := &PubSubMessage{
+		Topic:   "orders.dlq",
+		Message: &MessageData{ID: "msg", Headers: map[string]string{DeadLetterReasonHeader: string(DropQueueOverflow)}},
+	}
+	hub.deadLetter(letter, DropQueueOverflow, "websocket:slow")
+
+	if pending := hub.publishes.pending(); pending != 0 {
+		t.Errorf("Expected a dropped dead letter discarded, got %d pending", pending)
+	}
+
+	// Topics without a dead-letter topic drop events as before
+	hub.CreateTopic("payments")
+	hub.deadLetter(&PubSubMessage{Topic: "payments", Message: &MessageData{ID: "msg"}}, DropQueueOverflow, "websocket:slow")
+	if pending := hub.publishes.pending(); pending != 0 {
+		t.Errorf("Expected no dead letter without a dead-letter topic, got %d pending", pending)
+	}
+}
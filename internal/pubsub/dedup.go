@@ -0,0 +1,105 @@
+package pubsub
+
+// maxEarlyDeliveries bounds the live deliveries remembered while a
+// subscribe works out its replay
+const maxEarlyDeliveries = 1024
+
+// dedupState tracks a topic's deliveries to a client for as long as the
+// client stays subscribed, so a subscribe's replay and the live events it
+// overlaps with are each delivered once. A subscription is live from the
+// moment the hub applies it, before its replay is read from retention, so
+// events published in between are both fanned out live and replayed; and
+// subscribing again while subscribed replays events the live stream has
+// already sent.
+type dedupState struct {
+	// last is the highest sequence delivered on the topic since the
+	// subscription started
+	last int64
+	// pending is set between a subscribe taking effect and its replay
+	// being worked out, when early records the live sequences delivered
+	pending bool
+	early   []int64
+	// replayFrom and replayTo span the sequences the subscription's replay
+	// delivers, whose live copies are skipped (0 = no replay)
+	replayFrom, replayTo int64
+}
+
+// beginReplayDedup starts tracking live deliveries ahead of a subscribe
+// and returns the highest sequence already delivered on the topic. Caller
+// must hold c.mu.
+func (c *Client) beginReplayDedup(topic string) int64 {
+	if c.dedup == nil {
+		c.dedup = make(map[string]*dedupState)
+	}
+	state, exists := c.dedup[topic]
+	if !exists {
+		state = &dedupState{}
+		c.dedup[topic] = state
+	}
+	state.pending = true
+	state.early = nil
+	state.replayFrom, state.replayTo = 0, 0
+	return state.last
+}
+
+// endReplayDedup forgets a topic's deliveries when the client's subscription
+// to it ends, so a later subscription replays everything it asks for, such
+// as a consumer group's rewound offset or a re-created topic's restarted
+// sequence. Caller must hold c.mu.
+func (c *Client) endReplayDedup(topic string) {
+	delete(c.dedup, topic)
+}
+
+// finishReplayDedup drops the events a subscribe's backlog shares with what
+// the client was already sent: those at or below the highest sequence
+// delivered before the subscribe, and those delivered live since. The
+// remaining backlog's live copies are skipped from now on.
+func (c *Client) finishReplayDedup(topic string, delivered int64, backlog []*PubSubMessage) []*PubSubMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.dedup[topic]
+	if state == nil {
+		return backlog
+	}
+	early := make(map[int64]bool, len(state.early))
+	for _, sequence := range state.early {
+		early[sequence] = true
+	}
+	fresh := make([]*PubSubMessage, 0, len(backlog))
+	for _, message := range backlog {
+		if message.Sequence > delivered && !early[message.Sequence] {
+			fresh = append(fresh, message)
+		}
+	}
+	if skipped := len(backlog) - len(fresh); skipped > 0 {
+		c.hub.duplicates.Add(int64(skipped))
+	}
+
+	state.pending = false
+	state.early = nil
+	if len(fresh) > 0 {
+		state.replayFrom, state.replayTo = fresh[0].Sequence, fresh[len(fresh)-1].Sequence
+	}
+	return fresh
+}
+
+// duplicateEvent reports whether an event duplicates one the client is
+// sent anyway, recording it as delivered otherwise. Caller must hold c.mu.
+func (c *Client) duplicateEvent(msg *PubSubMessage, live bool) bool {
+	state := c.dedup[msg.Topic]
+	// Messages on topics that were never created carry no sequence, and
+	// are never replayed
+	if state == nil || msg.Sequence <= 0 {
+		return false
+	}
+	if live && state.replayFrom > 0 && msg.Sequence >= state.replayFrom && msg.Sequence <= state.replayTo {
+		c.hub.duplicates.Add(1)
+		return true
+	}
+	if live && state.pending && len(state.early) < maxEarlyDeliveries {
+		state.early = append(state.early, msg.Sequence)
+	}
+	state.last = max(state.last, msg.Sequence)
+	return false
+}
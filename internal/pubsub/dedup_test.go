@@ -0,0 +1,141 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+// sequenced builds a message at a topic sequence
+func sequenced(topic string, sequence int64) *PubSubMessage {
+	return &PubSubMessage{Topic: topic, Sequence: sequence, Message: &MessageData{ID: "m", Payload: "x"}}
+}
+
+func TestReplayOverlapDeliversOnce(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.mu.Lock()
+	delivered := client.beginReplayDedup("orders")
+	client.mu.Unlock()
+
+	// Sequence 3 is fanned out live before the replay is worked out, so the
+	// replay leaves it out
+	client.sendEvent(sequenced("orders", 3))
+	backlog := client.finishReplayDedup("orders", delivered, []*PubSubMessage{
+		sequenced("orders", 1), sequenced("orders", 2), sequenced("orders", 3),
+	})
+	if len(backlog) != 2 || backlog[1].Sequence != 2 {
+		t.Fatalf("Expected sequences 1 and 2 left to replay, got %d", len(backlog))
+	}
+
+	// Live copies of what the replay delivers are skipped, later events not
+	for _, message := range backlog {
+		client.sendReplayEvent(message)
+	}
+	client.sendEvent(sequenced("orders", 2))
+	client.sendEvent(sequenced("orders", 4))
+
+	var sequences []int64
+	for _, frame := range drainFrames(t, client) {
+		sequences = append(sequences, frame.Sequence)
+	}
+	if len(sequences) != 4 || sequences[0] != 3 || sequences[1] != 1 || sequences[2] != 2 || sequences[3] != 4 {
+		t.Errorf("Expected 3, 1, 2 and 4 delivered once each, got %v", sequences)
+	}
+	if skipped := hub.GetStats().DuplicatesSkipped; skipped != 2 {
+		t.Errorf("Expected 2 duplicates skipped, got %d", skipped)
+	}
+}
+
+func TestResubscribeSkipsEventsSentLive(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "orders", 1)
+	for i := 0; i < 3; i++ {
+		hub.publishMessage(sequenced("orders", 0)) // stamped by the hub
+	}
+	drainFrames(t, client)
+
+	// Subscribing again while subscribed, such as to change fields, replays
+	// nothing the live stream already sent
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", LastN: 10})
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Subscription == nil || frames[0].Subscription.Replaying != 0 {
+		t.Fatalf("Expected an ack replaying nothing, got %+v", frames)
+	}
+	if skipped := hub.GetStats().DuplicatesSkipped; skipped != 3 {
+		t.Errorf("Expected the 3 delivered events skipped, got %d", skipped)
+	}
+}
+
+// resubscribe unsubscribes a client from a topic and subscribes it again,
+// returning how many events the subscribe's ack says it replays
+func resubscribe(t *testing.T, hub *Hub, client *Client, msg *ClientMessage) int {
+	t.Helper()
+
+	client.handleMessage(&ClientMessage{Type: UnsubscribeMessage, Topic: msg.Topic, ClientID: msg.ClientID})
+	waitForSubscribers(t, hub, msg.Topic, 0)
+	drainFrames(t, client)
+
+	client.handleMessage(msg)
+	frames := drainFrames(t, client)
+	if len(frames) == 0 || frames[0].Subscription == nil {
+		t.Fatalf("Expected a subscribe ack, got %+v", frames)
+	}
+	return frames[0].Subscription.Replaying
+}
+
+func TestResubscribeReplaysRecreatedTopic(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "orders", 1)
+	for i := 0; i < 5; i++ {
+		hub.publishMessage(sequenced("orders", 0)) // stamped by the hub
+	}
+
+	// The re-created topic's sequence starts over at 1
+	hub.DeleteTopic("orders")
+	hub.CreateTopic("orders")
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "orders", 1)
+	for i := 0; i < 3; i++ {
+		hub.publishMessage(sequenced("orders", 0)) // stamped by the hub
+	}
+
+	if replaying := resubscribe(t, hub, client, &ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", LastN: 3}); replaying != 3 {
+		t.Errorf("Expected the re-created topic's 3 events replayed, got %d", replaying)
+	}
+}
+
+func TestResubscribeReplaysRewoundGroup(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	client := newTestClient(hub)
+	client.opts.ReplayRate = 0
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", Group: "billing"})
+	waitForSubscribers(t, hub, "orders", 1)
+	for i := 0; i < 4; i++ {
+		hub.publishMessage(sequenced("orders", 0)) // stamped by the hub
+	}
+	client.handleMessage(&ClientMessage{Type: UnsubscribeMessage, Topic: "orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "orders", 0)
+
+	if _, err := hub.SetGroupOffset("orders", "billing", 2); err != nil {
+		t.Fatalf("SetGroupOffset failed: %v", err)
+	}
+	if replaying := resubscribe(t, hub, client, &ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", Group: "billing"}); replaying != 2 {
+		t.Errorf("Expected the 2 events after the rewound offset replayed, got %d", replaying)
+	}
+}
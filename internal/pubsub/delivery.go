@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// maxDeliveryWorkers bounds the hub's delivery worker pool
+const maxDeliveryWorkers = 1024
+
+// deliveryQueueSize is how many fan-outs each delivery worker may have
+// waiting before the shard loops handing them out block
+const deliveryQueueSize = 1024
+
+// deliveryJob is one message's delivery to the subscribers a worker serves
+type deliveryJob struct {
+	message  *PubSubMessage
+	clients  []*Client
+	queuedAt time.Time
+}
+
+// deliveryPool delivers fanned-out events to subscribers off the shard
+// loops, so a topic with a huge subscriber set only holds up its own
+// deliveries. Each client is always served by the same worker, and each
+// worker takes its jobs in order, so a subscriber still gets a topic's
+// events in sequence order.
+type deliveryPool struct {
+	workers []chan deliveryJob
+
+	// Dispatch lag: how long jobs waited for their worker
+	mu       sync.Mutex
+	jobs     int64
+	totalLag time.Duration
+	maxLag   time.Duration
+	lastLag  time.Duration
+}
+
+// DeliveryStats reports the delivery worker pool's backlog and dispatch
+// lag, the time fanned-out events wait for a worker to start delivering
+// them
+type DeliveryStats struct {
+	Workers int `json:"workers"`
+	// Queued is how many fan-outs are waiting across all workers
+	Queued    int     `json:"queued"`
+	Jobs      int64   `json:"jobs"`
+	MeanLagMs float64 `json:"mean_lag_ms"`
+	MaxLagMs  float64 `json:"max_lag_ms"`
+	LastLagMs float64 `json:"last_lag_ms"`
+}
+
+// newDeliveryPool creates a pool of workers, or nil to deliver inline when
+// workers is 0
+func newDeliveryPool(workers int) *deliveryPool {
+	if workers <= 0 {
+		return nil
+	}
+	p := &deliveryPool{workers: make([]chan deliveryJob, workers)}
+	for i := range p.workers {
+		p.workers[i] = make(chan deliveryJob, deliveryQueueSize)
+	}
+	return p
+}
+
+// worker returns the index of the worker serving a client
+func (p *deliveryPool) worker(client *Client) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(client.id))
+	return int(hash.Sum32() % uint32(len(p.workers)))
+}
+
+// dispatch hands a message's delivery to the workers serving its
+// subscribers, waiting while a worker's queue is full unless cancel is
+// closed
+func (p *deliveryPool) dispatch(message *PubSubMessage, clients []*Client, now time.Time, cancel <-chan struct{}) {
+	shares := make(map[int][]*Client)
+	for _, client := range clients {
+		i := p.worker(client)
+		shares[i] = append(shares[i], client)
+	}
+	for i, share := range shares {
+		select {
+		case p.workers[i] <- deliveryJob{message: message, clients: share, queuedAt: now}:
+		case <-cancel:
+			return
+		}
+	}
+}
+
+// recordLag accounts for a job a worker has just started
+func (p *deliveryPool) recordLag(lag time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs++
+	p.totalLag += lag
+	p.lastLag = lag
+	p.maxLag = max(p.maxLag, lag)
+}
+
+// queued returns how many jobs are waiting across all workers
+func (p *deliveryPool) queued() int {
+	total := 0
+	for _, jobs := range p.workers {
+		total += len(jobs)
+	}
+	return total
+}
+
+// stats summarizes the pool's backlog and dispatch lag
+func (p *deliveryPool) stats() DeliveryStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := DeliveryStats{
+		Workers:   len(p.workers),
+		Queued:    p.queued(),
+		Jobs:      p.jobs,
+		MaxLagMs:  float64(p.maxLag) / float64(time.Millisecond),
+		LastLagMs: float64(p.lastLag) / float64(time.Millisecond),
+	}
+	if p.jobs > 0 {
+		stats.MeanLagMs = float64(p.totalLag) / float64(p.jobs) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// runDeliveryWorker delivers a worker's jobs until the hub shuts down
+func (h *Hub) runDeliveryWorker(jobs <-chan deliveryJob) {
+	for {
+		select {
+		case job := <-jobs:
+			h.delivery.recordLag(h.clock.Now().Sub(job.queuedAt))
+			h.safely("deliver", func() {
+				for _, client := range job.clients {
+					client.sendEvent(job.message)
+				}
+			})
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+// deliver sends a published message to its subscribers, through the
+// delivery workers if the hub has any
+func (h *Hub) deliver(message *PubSubMessage, clients []*Client) {
+	if h.delivery == nil {
+		for _, client := range clients {
+			client.sendEvent(message)
+		}
+		return
+	}
+	h.delivery.dispatch(message, clients, h.clock.Now(), h.shutdown)
+}
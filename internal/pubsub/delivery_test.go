@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeliveryWorkersKeepTopicOrder(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 100, DeliveryWorkers: 4})
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	clients := make([]*Client, 8)
+	for i := range clients {
+		clients[i] = newTestClient(hub)
+		clients[i].id = fmt.Sprintf("client-%d", i)
+		hub.subscribeClient(&Subscription{client: clients[i], topic: "orders"})
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, err := hub.TryPublish(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprint(i)}}, time.Second); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for _, client := range clients {
+		for client.queue.Len() < 20 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: timed out with %d of 20 events delivered", client.id, client.queue.Len())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		for i, event := range drainFrames(t, client) {
+			if event.Sequence != int64(i+1) {
+				t.Fatalf("%s: expected sequence %d, got %d", client.id, i+1, event.Sequence)
+			}
+		}
+	}
+
+	stats := hub.GetStats().Delivery
+	if stats == nil || stats.Workers != 4 || stats.Jobs < 20 {
+		t.Errorf("Expected 4 workers and at least 20 jobs, got %+v", stats)
+	}
+	if stats != nil && stats.MaxLagMs < stats.LastLagMs {
+		t.Errorf("Expected max lag to cover the last lag, got %+v", stats)
+	}
+}
+
+func TestDeliveryInlineWithoutWorkers(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 100})
+	if hub.delivery != nil {
+		t.Fatal("Expected no delivery pool by default")
+	}
+	if hub.GetStats().Delivery != nil {
+		t.Error("Expected no delivery stats without workers")
+	}
+}
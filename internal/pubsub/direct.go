@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"context"
+	"time"
+)
+
+// directPublishWait bounds how long a direct publish waits for room in its
+// topic's backlog before it fails with ErrHubSaturated
+const directPublishWait = 100 * time.Millisecond
+
+// PublishReceipt reports a publish accepted by PublishDirect
+type PublishReceipt struct {
+	Topic string
+	// ID is the message ID, generated if the data had none and
+	// WithGeneratedID was given
+	ID string
+	// Timestamp is the server receive time stamped on the message
+	Timestamp time.Time
+	// Ahead is how many of the topic's publishes were queued ahead of the
+	// message
+	Ahead int
+	// Duplicate is set when the message ID repeats one the topic published
+	// within its dedup window, and the message was dropped
+	Duplicate bool
+}
+
+// WithTimestamp sets the time the server received the publish, for callers
+// that accept a publish before building its message (default: when the
+// hub admits it)
+func WithTimestamp(receivedAt time.Time) MessageOption {
+	return func(o *messageOptions) { o.timestamp = receivedAt }
+}
+
+// PublishDirect publishes data to an existing topic on behalf of a caller
+// with no client, such as a REST request or an embedding program. The
+// message is validated as NewMessageFromData does, publishes to system,
+// unknown and deleted topics are refused, and a topic whose backlog has
+// reached the hub's reject depth sheds the publish with ErrHubSaturated.
+// A context done before the publish is queued abandons it with the
+// context's error. Accepted messages are queued like client publishes, so
+// retention, fan-out and statistics treat them alike. On topics with a
+// dedup window, a message whose ID the topic already published within the
+// window is dropped and reported as a Duplicate receipt. It is safe for
+// concurrent use.
+func (h *Hub) PublishDirect(ctx context.Context, topic string, data *MessageData, opts ...MessageOption) (*PublishReceipt, error) {
+	if IsSystemTopic(topic) {
+		return nil, ErrReservedTopic
+	}
+	if !h.TopicExists(topic) {
+		if _, deleted := h.DeletedTopicInfo(topic); deleted {
+			return nil, ErrTopicDeleted
+		}
+		return nil, ErrTopicNotFound
+	}
+
+	// Stamped by the hub's clock unless the caller supplies a timestamp
+	opts = append([]MessageOption{WithTimestamp(h.clock.Now())}, opts...)
+	message, err := NewMessageFromData(topic, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Shed load before queueing when the topic is already far behind
+	if h.rejectDepth > 0 && h.publishes.topicPending(topic) >= h.rejectDepth {
+		h.ReportQuota(QuotaEvent{
+			Quota:    QuotaPublishBacklog,
+			Identity: message.publisher,
+			Topic:    topic,
+			Limit:    int64(h.rejectDepth),
+		})
+		return nil, ErrHubSaturated
+	}
+
+	receipt := &PublishReceipt{Topic: topic, ID: message.Message.ID, Timestamp: message.Timestamp}
+	if !h.claimMessageID(message) {
+		receipt.Duplicate = true
+		return receipt, nil
+	}
+	ahead, err := h.tryPublish(ctx, message, directPublishWait)
+	if err != nil {
+		h.forgetMessageIDs([]*PubSubMessage{message})
+		return nil, err
+	}
+	receipt.Ahead = ahead
+	return receipt, nil
+}
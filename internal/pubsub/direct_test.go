@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublishDirect(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	client := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+
+	receivedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	receipt, err := hub.PublishDirect(context.Background(), "orders", &MessageData{Payload: "hello"},
+		WithGeneratedID(), WithTimestamp(receivedAt), WithPublisher(RESTIdentity("10.0.0.1:5000")))
+	if err != nil {
+		t.Fatalf("PublishDirect failed: %v", err)
+	}
+	if receipt.ID == "" || receipt.Topic != "orders" || !receipt.Timestamp.Equal(receivedAt) {
+		t.Errorf("Unexpected receipt: %+v", receipt)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for client.queue.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	events := drainFrames(t, client)
+	if len(events) != 1 || events[0].Message.ID != receipt.ID || events[0].Sequence != 1 {
+		t.Errorf("Expected the published event at sequence 1, got %+v", events)
+	}
+	if retained := hub.GetRecentMessages("orders", 10); len(retained) != 1 {
+		t.Errorf("Expected the message retained, got %d", len(retained))
+	}
+}
+
+func TestPublishDirectRefused(t *testing.T) {
+	hub := newTrashHub(time.Hour)
+	hub.CreateTopic("orders")
+	hub.CreateTopic("archived")
+	hub.DeleteTopic("archived")
+
+	tests := []struct {
+		name  string
+		topic string
+		data  *MessageData
+		want  error
+	}{
+		{"system topic", QuotaTopic, &MessageData{ID: "msg-1", Payload: 1}, ErrReservedTopic},
+		{"unknown topic", "missing", &MessageData{ID: "msg-1", Payload: 1}, ErrTopicNotFound},
+		{"deleted topic", "archived", &MessageData{ID: "msg-1", Payload: 1}, ErrTopicDeleted},
+		{"no message", "orders", nil, ErrInvalidMessage},
+		{"no ID", "orders", &MessageData{Payload: 1}, ErrInvalidMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := hub.PublishDirect(context.Background(), tt.topic, tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPublishDirectShedsAtRejectDepth(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.PublishRejectDepth = 2
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+
+	// The hub isn't running, so every publish stays in the backlog
+	for i := 0; i < 2; i++ {
+		receipt, err := hub.PublishDirect(context.Background(), "orders", &MessageData{Payload: i}, WithGeneratedID())
+		if err != nil {
+			t.Fatalf("Publish %d failed: %v", i, err)
+		}
+		if receipt.Ahead != i {
+			t.Errorf("Expected %d publishes ahead, got %d", i, receipt.Ahead)
+		}
+	}
+
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{Payload: 2}, WithGeneratedID()); err != ErrHubSaturated {
+		t.Errorf("Expected ErrHubSaturated at the reject depth, got %v", err)
+	}
+}
+
+func TestPublishDirectAbandonedWithContext(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.PublishBuffer = 1
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+
+	// The hub isn't running, so the first publish fills the backlog
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{Payload: 1}, WithGeneratedID()); err != nil {
+		t.Fatalf("First publish failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := hub.PublishDirect(ctx, "orders", &MessageData{Payload: 2}, WithGeneratedID()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's deadline error, got %v", err)
+	}
+	if waited := time.Since(start); waited >= directPublishWait {
+		t.Errorf("Expected the publish abandoned at the context deadline, waited %v", waited)
+	}
+
+	if _, err := hub.PublishDirect(ctx, "orders", &MessageData{Payload: 3}, WithGeneratedID()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a done context to refuse the publish, got %v", err)
+	}
+	if depth, _ := hub.PublishBacklog("orders"); depth != 1 {
+		t.Errorf("Expected abandoned publishes left out of the backlog, got %d", depth)
+	}
+}
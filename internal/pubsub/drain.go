@@ -0,0 +1,168 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Info frame messages sent to subscribers of a draining topic
+const (
+	// TopicDrainingInfo tells a subscriber the topic takes no new
+	// subscriptions; the frame names the replacement topic, if any
+	TopicDrainingInfo = "topic_draining"
+	// TopicMigratedInfo tells a subscriber it was moved to the replacement
+	TopicMigratedInfo = "topic_migrated"
+)
+
+// DrainOptions configures draining a topic
+type DrainOptions struct {
+	// Replacement names the topic subscribers should move to
+	Replacement string `json:"replacement,omitempty"`
+	// Migrate moves existing subscribers to the replacement server-side,
+	// instead of only telling them about it
+	Migrate bool `json:"migrate,omitempty"`
+}
+
+// DrainResult reports what draining a topic did
+type DrainResult struct {
+	Topic       string `json:"topic"`
+	Replacement string `json:"replacement,omitempty"`
+	// Notified is how many subscribers were sent an info frame
+	Notified int `json:"notified"`
+	// Migrated is how many of them were moved to the replacement
+	Migrated int `json:"migrated"`
+}
+
+// drainState marks a topic that takes no new subscriptions
+type drainState struct {
+	replacement string
+	since       time.Time
+}
+
+// DrainTopic stops new subscriptions to a topic and tells its subscribers,
+// with an info frame, which topic replaces it. With Migrate, subscribers are
+// moved to the replacement, keeping their payload projection; consumer group
+// membership does not carry over, since offsets are per topic. Publishes to
+// the topic are still accepted. Draining again updates the replacement.
+func (h *Hub) DrainTopic(name string, opts DrainOptions) (*DrainResult, error) {
+	if IsSystemTopic(name) {
+		return nil, ErrReservedTopic
+	}
+
+	h.mu.Lock()
+	topic, exists := h.topics[name]
+	if !exists {
+		h.mu.Unlock()
+		return nil, ErrTopicNotFound
+	}
+	if err := h.validateDrain(name, opts); err != nil {
+		h.mu.Unlock()
+		return nil, err
+	}
+
+	topic.drain = &drainState{replacement: opts.Replacement, since: h.clock.Now()}
+
+	result := &DrainResult{Topic: name, Replacement: opts.Replacement}
+	subscribers := make([]*Client, 0, len(h.subscriptions[name]))
+	for client := range h.subscriptions[name] {
+		subscribers = append(subscribers, client)
+	}
+	if opts.Migrate {
+		for _, client := range subscribers {
+			h.migrateSubscription(client, name, opts.Replacement)
+		}
+		result.Migrated = len(subscribers)
+	}
+	h.mu.Unlock()
+
+	// Notify outside the lock, since a full queue may disconnect the client
+	info := TopicDrainingInfo
+	if opts.Migrate {
+		info = TopicMigratedInfo
+	}
+	data := h.createDrainInfoMessageBytes(name, opts.Replacement, info)
+	for _, client := range subscribers {
+		client.sendWithBackpressure("", data)
+	}
+	result.Notified = len(subscribers)
+
+	return result, nil
+}
+
+// validateDrain checks a drain's replacement topic. Caller must hold the lock.
+func (h *Hub) validateDrain(name string, opts DrainOptions) error {
+	if opts.Replacement == "" {
+		if opts.Migrate {
+			return fmt.Errorf("%w: migrate requires a replacement topic", ErrInvalidDrain)
+		}
+		return nil
+	}
+	if opts.Replacement == name {
+		return fmt.Errorf("%w: a topic cannot replace itself", ErrInvalidDrain)
+	}
+	replacement, exists := h.topics[opts.Replacement]
+	if !exists {
+		return fmt.Errorf("%w: replacement topic %s not found", ErrInvalidDrain, opts.Replacement)
+	}
+	if replacement.drain != nil {
+		return fmt.Errorf("%w: replacement topic %s is draining", ErrInvalidDrain, opts.Replacement)
+	}
+	// Migrated subscribers keep the key ID they presented
+	if opts.Migrate && replacement.keyID != h.topics[name].keyID {
+		return fmt.Errorf("%w: replacement topic %s is encrypted with a different key", ErrInvalidDrain, opts.Replacement)
+	}
+	return nil
+}
+
+// migrateSubscription moves a client's subscription from one topic to
+// another. Caller must hold the hub write lock.
+func (h *Hub) migrateSubscription(client *Client, from, to string) {
+	client.mu.Lock()
+	options := client.options[from]
+	options.group = ""
+	delete(client.subscriptions, from)
+	delete(client.options, from)
+	client.subscriptions[to] = true
+	client.options[to] = options
+	delete(client.audit, to)
+	client.mu.Unlock()
+
+	delete(h.subscriptions[from], client)
+	if len(h.subscriptions[from]) == 0 {
+		delete(h.subscriptions, from)
+	}
+	if h.subscriptions[to] == nil {
+		h.subscriptions[to] = make(map[*Client]bool)
+	}
+	h.subscriptions[to][client] = true
+
+	h.updateSubscriberCount(from)
+	h.updateSubscriberCount(to)
+}
+
+// drainingTopic reports whether a topic is draining and its replacement
+func (h *Hub) drainingTopic(name string) (replacement string, draining bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if topic, exists := h.topics[name]; exists && topic.drain != nil {
+		return topic.drain.replacement, true
+	}
+	return "", false
+}
+
+// createDrainInfoMessageBytes creates the info frame sent to subscribers of
+// a draining topic
+func (h *Hub) createDrainInfoMessageBytes(topic, replacement, info string) []byte {
+	msg := ServerMessage{
+		Type:        InfoMessage,
+		Topic:       topic,
+		Msg:         info,
+		Replacement: replacement,
+		TS:          h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
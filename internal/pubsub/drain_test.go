@@ -0,0 +1,147 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDrainTopicNotifiesSubscribers(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+
+	client := newTestClient(hub)
+	client.subscriptions["orders"] = true
+	hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+
+	result, err := hub.DrainTopic("orders", DrainOptions{Replacement: "orders-v2"})
+	if err != nil {
+		t.Fatalf("DrainTopic failed: %v", err)
+	}
+	if result.Notified != 1 || result.Migrated != 0 {
+		t.Errorf("Expected 1 notified and none migrated, got %+v", result)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Msg != TopicDrainingInfo || frames[0].Replacement != "orders-v2" {
+		t.Fatalf("Expected a topic_draining frame naming orders-v2, got %+v", frames)
+	}
+
+	// Existing subscribers stay put until they move themselves
+	if !client.IsSubscribed("orders") || hub.GetTopics()["orders"].SubscriberCount != 1 {
+		t.Error("Expected the subscriber to remain on the drained topic")
+	}
+
+	stats, _ := hub.GetTopicStats("orders")
+	if !stats.Draining || stats.Replacement != "orders-v2" {
+		t.Errorf("Expected stats to report draining to orders-v2, got %+v", stats)
+	}
+}
+
+func TestDrainTopicMigratesSubscribers(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+
+	client := newTestClient(hub)
+	client.subscriptions["orders"] = true
+	client.options["orders"] = subscriptionOptions{fields: []string{"id"}, group: "billing"}
+	hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+
+	result, err := hub.DrainTopic("orders", DrainOptions{Replacement: "orders-v2", Migrate: true})
+	if err != nil {
+		t.Fatalf("DrainTopic failed: %v", err)
+	}
+	if result.Notified != 1 || result.Migrated != 1 {
+		t.Errorf("Expected 1 notified and migrated, got %+v", result)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Msg != TopicMigratedInfo || frames[0].Topic != "orders" {
+		t.Fatalf("Expected a topic_migrated frame for orders, got %+v", frames)
+	}
+
+	if client.IsSubscribed("orders") || !client.IsSubscribed("orders-v2") {
+		t.Error("Expected the subscription to move to orders-v2")
+	}
+	options := client.options["orders-v2"]
+	if len(options.fields) != 1 || options.group != "" {
+		t.Errorf("Expected fields kept and group cleared, got %+v", options)
+	}
+
+	topics := hub.GetTopics()
+	if topics["orders"].SubscriberCount != 0 || topics["orders-v2"].SubscriberCount != 1 {
+		t.Errorf("Expected subscriber counts 0 and 1, got %d and %d",
+			topics["orders"].SubscriberCount, topics["orders-v2"].SubscriberCount)
+	}
+
+	// Publishes to the replacement reach the migrated subscriber
+	hub.publishMessage(&PubSubMessage{Topic: "orders-v2", Message: &MessageData{ID: "msg-1"}})
+	frames = drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != EventMessage || frames[0].Topic != "orders-v2" {
+		t.Errorf("Expected an event on orders-v2, got %+v", frames)
+	}
+}
+
+func TestDrainTopicRejectsSubscribe(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+	if _, err := hub.DrainTopic("orders", DrainOptions{Replacement: "orders-v2"}); err != nil {
+		t.Fatalf("DrainTopic failed: %v", err)
+	}
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{
+		Type:      SubscribeMessage,
+		Topic:     "orders",
+		ClientID:  "late",
+		RequestID: "sub-1",
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil {
+		t.Fatalf("Expected a single error frame, got %+v", frames)
+	}
+	if frames[0].Error.Code != "TOPIC_DRAINING" || frames[0].Error.Replacement != "orders-v2" {
+		t.Errorf("Expected TOPIC_DRAINING naming orders-v2, got %+v", frames[0].Error)
+	}
+	if client.IsSubscribed("orders") {
+		t.Error("Expected no subscription to the draining topic")
+	}
+}
+
+func TestDrainTopicValidation(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+	hub.CreateTopic("legacy")
+	if _, err := hub.DrainTopic("legacy", DrainOptions{}); err != nil {
+		t.Fatalf("DrainTopic without a replacement failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts DrainOptions
+	}{
+		{"migrate without replacement", DrainOptions{Migrate: true}},
+		{"replacement is the topic", DrainOptions{Replacement: "orders"}},
+		{"missing replacement", DrainOptions{Replacement: "missing"}},
+		{"draining replacement", DrainOptions{Replacement: "legacy"}},
+	}
+	for _, tt := range tests {
+		if _, err := hub.DrainTopic("orders", tt.opts); !errors.Is(err, ErrInvalidDrain) {
+			t.Errorf("%s: expected ErrInvalidDrain, got %v", tt.name, err)
+		}
+	}
+
+	if _, err := hub.DrainTopic("missing", DrainOptions{}); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+	if _, err := hub.DrainTopic(SystemTopicPrefix+"clients", DrainOptions{}); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket wire encodings, requested with the encoding query parameter of
+// the handshake
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+// frameEncodingOf maps an encoding name onto its frame encoding; "" is JSON
+func frameEncodingOf(name string) (frameEncoding, error) {
+	switch name {
+	case "", EncodingJSON:
+		return encodingJSON, nil
+	case EncodingMsgpack:
+		return encodingMsgpack, nil
+	}
+	return 0, fmt.Errorf("unsupported encoding %q: want %s or %s", name, EncodingJSON, EncodingMsgpack)
+}
+
+// CheckEncoding reports whether name is a supported wire encoding
+func CheckEncoding(name string) error {
+	_, err := frameEncodingOf(name)
+	return err
+}
+
+// SetEncoding switches the client's wire encoding. MessagePack clients get
+// every frame as a binary message and may send theirs either way: binary
+// messages are read as MessagePack and text messages as JSON. It must be
+// called before the client is registered.
+func (c *Client) SetEncoding(name string) error {
+	encoding, err := frameEncodingOf(name)
+	if err != nil {
+		return err
+	}
+	c.encoding = encoding
+	return nil
+}
+
+// encode re-encodes a JSON frame in the client's wire encoding
+func (c *Client) encode(data []byte) []byte {
+	return encodeFrame(data, c.encoding)
+}
+
+// encodeFrame re-encodes a JSON frame in an encoding
+func encodeFrame(data []byte, encoding frameEncoding) []byte {
+	if encoding == encodingJSON {
+		return data
+	}
+	encoded, err := JSONToMsgpack(data)
+	if err != nil {
+		// Frames are marshaled by the broker, so this can only be a bug
+		slog.Error("Encoding frame failed", "encoding", EncodingMsgpack, "error", err)
+		return data
+	}
+	return encoded
+}
+
+// decode returns a received message as JSON
+func (c *Client) decode(messageType int, data []byte) ([]byte, error) {
+	if c.encoding == encodingJSON || messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	return MsgpackToJSON(data)
+}
+
+// writeBinary writes a binary frame to the connection
+func (c *Client) writeBinary(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.WriteWait))
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"unicode"
+)
+
+// MaxKeyIDLength is the longest accepted encryption key ID in bytes
+const MaxKeyIDLength = 128
+
+// validateKeyID checks an encrypted topic's key ID ("" = not encrypted)
+func validateKeyID(keyID string) error {
+	switch {
+	case len(keyID) > MaxKeyIDLength:
+		return fmt.Errorf("%w: exceeds %d bytes", ErrInvalidKeyID, MaxKeyIDLength)
+	case strings.IndexFunc(keyID, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w: must not contain control characters", ErrInvalidKeyID)
+	}
+	return nil
+}
+
+// checkSubscribeKey requires subscribers of an encrypted topic to present
+// its key ID. Topics that don't exist yet, or aren't encrypted, accept any.
+func (h *Hub) checkSubscribeKey(name, keyID string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if topic, exists := h.topics[name]; exists && topic.keyID != "" && topic.keyID != keyID {
+		return ErrKeyIDMismatch
+	}
+	return nil
+}
+
+// admitPublish checks a publish against its topic's settings and returns
+// the topic's scheduling weight. Encrypted topics only take opaque
+// ciphertext, as application/octet-stream payloads, and topics with a
+// schema only take payloads that validate against it, stamped with the
+// version they matched. Messages without a receive time are stamped with
+// the hub's clock.
+func (h *Hub) admitPublish(message *PubSubMessage) (int, error) {
+	if message.Timestamp.IsZero() {
+		message.Timestamp = h.clock.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.pause != nil && h.pause.info.Mode == PauseReject {
+		return 0, ErrHubPaused
+	}
+
+	topic, exists := h.topics[message.Topic]
+	if !exists {
+		if h.trashed(message.Topic) != nil {
+			return 0, ErrTopicDeleted
+		}
+		return 1, nil
+	}
+	if topic.keyID != "" && !message.Message.isBinary() {
+		return 0, &InvalidMessageError{
+			Field:  "content_type",
+			Reason: fmt.Sprintf("must be %s on encrypted topics", ContentTypeBinary),
+		}
+	}
+	version, err := topic.checkSchema(message.Message)
+	if err != nil {
+		return 0, err
+	}
+	message.SchemaVersion = version
+	return topic.schedulingWeight(), nil
+}
+
+// isBinary reports whether the payload is base64-encoded bytes
+func (d *MessageData) isBinary() bool {
+	if d == nil {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(d.ContentType)
+	return err == nil && mediaType == ContentTypeBinary
+}
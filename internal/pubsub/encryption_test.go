@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptedTopicSubscribeRequiresKeyID(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	if err := hub.CreateTopicWithOptions("payroll", TopicOptions{KeyID: "key-2024"}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "payroll", ClientID: "c1", RequestID: "sub-1", KeyID: "key-2023"})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeForbidden {
+		t.Fatalf("Expected a FORBIDDEN error for the wrong key ID, got %+v", frames)
+	}
+	if client.IsSubscribed("payroll") {
+		t.Error("Expected no subscription without the topic's key ID")
+	}
+
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "payroll", ClientID: "c1", RequestID: "sub-2", KeyID: "key-2024"})
+
+	frames = drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != AckMessage {
+		t.Fatalf("Expected an ack with the topic's key ID, got %+v", frames)
+	}
+
+	stats, _ := hub.GetTopicStats("payroll")
+	if stats.KeyID != "key-2024" {
+		t.Errorf("Expected stats to report key ID key-2024, got %q", stats.KeyID)
+	}
+}
+
+func TestEncryptedTopicOnlyAcceptsCiphertext(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("payroll", TopicOptions{KeyID: "key-2024"})
+
+	plain, _ := NewMessage("payroll", map[string]interface{}{"salary": 1}, WithID("msg-1"))
+	if err := hub.enqueuePublish(plain); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected a plaintext publish to be rejected, got %v", err)
+	}
+	if _, err := hub.TryPublish(plain, 0); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected a plaintext REST publish to be rejected, got %v", err)
+	}
+
+	cipher, _ := NewMessage("payroll", "c2VjcmV0", WithID("msg-2"), WithContentType(ContentTypeBinary))
+	if err := hub.enqueuePublish(cipher); err != nil {
+		t.Errorf("Expected ciphertext to be accepted, got %v", err)
+	}
+}
+
+func TestEncryptedTopicSkipsSubscribersWithoutKey(t *testing.T) {
+	hub := NewHub()
+
+	// Subscribed before the topic existed, so no key ID was checked
+	early := newTestClient(hub)
+	early.subscriptions["payroll"] = true
+	hub.subscribeClient(&Subscription{client: early, topic: "payroll"})
+
+	keyed := newTestClient(hub)
+	keyed.subscriptions["payroll"] = true
+	keyed.options["payroll"] = subscriptionOptions{keyID: "key-2024"}
+	hub.subscribeClient(&Subscription{client: keyed, topic: "payroll"})
+
+	hub.CreateTopicWithOptions("payroll", TopicOptions{KeyID: "key-2024"})
+
+	cipher, _ := NewMessage("payroll", "c2VjcmV0", WithID("msg-1"), WithContentType(ContentTypeBinary))
+	hub.publishMessage(cipher)
+
+	if frames := drainFrames(t, early); len(frames) != 0 {
+		t.Errorf("Expected no delivery without the key ID, got %+v", frames)
+	}
+	if frames := drainFrames(t, keyed); len(frames) != 1 || frames[0].Message.ContentType != ContentTypeBinary {
+		t.Errorf("Expected the ciphertext event, got %+v", frames)
+	}
+}
+
+func TestEncryptedTopicValidation(t *testing.T) {
+	hub := NewHub()
+
+	if err := hub.CreateTopicWithOptions("bad", TopicOptions{KeyID: "key\n1"}); !errors.Is(err, ErrInvalidKeyID) {
+		t.Errorf("Expected ErrInvalidKeyID, got %v", err)
+	}
+
+	hub.CreateTopicWithOptions("payroll", TopicOptions{KeyID: "key-2024"})
+	hub.CreateTopicWithOptions("payroll-v2", TopicOptions{KeyID: "key-2025"})
+	if _, err := hub.DrainTopic("payroll", DrainOptions{Replacement: "payroll-v2", Migrate: true}); !errors.Is(err, ErrInvalidDrain) {
+		t.Errorf("Expected migrating to a differently keyed topic to fail, got %v", err)
+	}
+}
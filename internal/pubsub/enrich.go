@@ -0,0 +1,74 @@
+package pubsub
+
+import "time"
+
+// Headers the broker stamps on messages published to enriched topics, so
+// consumers can audit provenance without trusting producers. Publishers
+// can't forge them, since header keys starting with "_" are reserved.
+const (
+	// MetaHeaderPrefix starts every server metadata header
+	MetaHeaderPrefix = ReservedHeaderPrefix + "meta."
+	// MetaNodeHeader is the ID of the broker node that accepted the publish
+	MetaNodeHeader = MetaHeaderPrefix + "node"
+	// MetaReceivedAtHeader is the server receive time (RFC3339Nano)
+	MetaReceivedAtHeader = MetaHeaderPrefix + "received_at"
+	// MetaPublisherHeader identifies the publisher as the broker saw it
+	MetaPublisherHeader = MetaHeaderPrefix + "publisher"
+)
+
+// WebSocketIdentity identifies a WebSocket client, as publisher or quota
+// holder
+func WebSocketIdentity(clientID string) string {
+	return "websocket:" + clientID
+}
+
+// RESTIdentity identifies a REST caller by its remote address
+func RESTIdentity(remoteAddr string) string {
+	return "rest:" + remoteAddr
+}
+
+// MQTTIdentity identifies an MQTT client by its client identifier
+func MQTTIdentity(clientID string) string {
+	return "mqtt:" + clientID
+}
+
+// GRPCIdentity identifies a gRPC caller by its peer address
+func GRPCIdentity(peerAddr string) string {
+	return "grpc:" + peerAddr
+}
+
+// TenantIdentity identifies a tenant, as quota holder
+func TenantIdentity(tenant string) string {
+	return "tenant:" + tenant
+}
+
+// WithPublisher records who published the message, for the publisher
+// metadata header on enriched topics
+func WithPublisher(identity string) MessageOption {
+	return func(o *messageOptions) { o.publisher = identity }
+}
+
+// enrich stamps server metadata headers on a message published to an
+// enriched topic. The headers are copied so the publisher's map is left
+// alone. Caller must hold the hub lock.
+func (h *Hub) enrich(message *PubSubMessage) {
+	if message.Message == nil {
+		return
+	}
+
+	headers := make(map[string]string, len(message.Message.Headers)+3)
+	for key, value := range message.Message.Headers {
+		headers[key] = value
+	}
+	if h.nodeID != "" {
+		headers[MetaNodeHeader] = h.nodeID
+	}
+	headers[MetaReceivedAtHeader] = message.Timestamp.UTC().Format(time.RFC3339Nano)
+	if message.publisher != "" {
+		headers[MetaPublisherHeader] = message.publisher
+	}
+
+	data := *message.Message
+	data.Headers = headers
+	message.Message = &data
+}
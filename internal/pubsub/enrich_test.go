@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnrichedTopicStampsServerMetadata(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.NodeID = "node-a"
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopicWithOptions("audit", TopicOptions{Enrich: true})
+	hub.CreateTopic("plain")
+
+	client := newTestClient(hub)
+	for _, topic := range []string{"audit", "plain"} {
+		client.subscriptions[topic] = true
+		hub.subscribeClient(&Subscription{client: client, topic: topic})
+	}
+
+	headers := map[string]string{"source": "billing"}
+	receivedAt := time.Date(2025, 8, 25, 10, 0, 0, 0, time.UTC)
+	for _, topic := range []string{"audit", "plain"} {
+		message, err := NewMessage(topic, "hello", WithID("msg-"+topic), WithHeaders(headers), WithPublisher(WebSocketIdentity("pub-1")))
+		if err != nil {
+			t.Fatalf("NewMessage failed: %v", err)
+		}
+		message.Timestamp = receivedAt
+		hub.publishMessage(message)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", frames)
+	}
+
+	enriched := frames[0].Message.Headers
+	want := map[string]string{
+		"source":             "billing",
+		MetaNodeHeader:       "node-a",
+		MetaReceivedAtHeader: "2025-08-25T10:00:00Z",
+		MetaPublisherHeader:  "websocket:pub-1",
+	}
+	if len(enriched) != len(want) {
+		t.Errorf("Expected headers %v, got %v", want, enriched)
+	}
+	for key, value := range want {
+		if enriched[key] != value {
+			t.Errorf("Expected header %s=%q, got %q", key, value, enriched[key])
+		}
+	}
+
+	if plain := frames[1].Message.Headers; len(plain) != 1 || plain["source"] != "billing" {
+		t.Errorf("Expected only publisher headers on a plain topic, got %v", plain)
+	}
+	if len(headers) != 1 {
+		t.Errorf("Expected the publisher's headers untouched, got %v", headers)
+	}
+
+	// Replayed events carry the metadata stamped at publish time
+	if retained := hub.GetRecentMessages("audit", 1); len(retained) != 1 || retained[0].Message.Headers[MetaNodeHeader] != "node-a" {
+		t.Errorf("Expected the retained message to be enriched, got %+v", retained)
+	}
+}
+
+func TestPublishersCannotForgeMetadata(t *testing.T) {
+	_, err := NewMessage("audit", "hello", WithID("msg-1"), WithHeaders(map[string]string{MetaNodeHeader: "node-x"}))
+	if err == nil {
+		t.Error("Expected a reserved metadata header to be rejected")
+	}
+}
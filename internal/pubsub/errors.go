@@ -0,0 +1,202 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrorCode identifies the kind of error reported to clients. The same codes
+// appear in WebSocket error frames and REST error bodies.
+type ErrorCode string
+
+// Error codes
+const (
+	// CodeBadRequest covers malformed frames and requests and failed validation
+	CodeBadRequest ErrorCode = "BAD_REQUEST"
+	// CodeUnauthorized is a missing or invalid API key or admin credential
+	CodeUnauthorized ErrorCode = "UNAUTHORIZED"
+	// CodeForbidden is a valid client lacking access, such as a subscriber
+	// without an encrypted topic's key ID
+	CodeForbidden      ErrorCode = "FORBIDDEN"
+	CodeTopicNotFound  ErrorCode = "TOPIC_NOT_FOUND"
+	CodeClientNotFound ErrorCode = "CLIENT_NOT_FOUND"
+	CodeTopicExists    ErrorCode = "TOPIC_EXISTS"
+	CodeSchemaNotFound ErrorCode = "SCHEMA_NOT_FOUND"
+	// CodeSchemaViolation rejects a publish whose payload doesn't validate
+	// against its topic's schema
+	CodeSchemaViolation ErrorCode = "SCHEMA_VIOLATION"
+	CodeGroupNotFound   ErrorCode = "GROUP_NOT_FOUND"
+	CodeStateNotFound   ErrorCode = "STATE_NOT_FOUND"
+	// CodeGroupActive rejects moving a group's offset while members are connected
+	CodeGroupActive ErrorCode = "GROUP_ACTIVE"
+	// CodeTopicDraining rejects subscribes to a drained topic
+	CodeTopicDraining ErrorCode = "TOPIC_DRAINING"
+	// CodeTopicDeleted rejects publishes, subscribes and re-creation while
+	// a deleted topic can still be restored
+	CodeTopicDeleted ErrorCode = "TOPIC_DELETED"
+	// CodeRevisionMismatch rejects a topic update made against a revision
+	// the topic has since moved past
+	CodeRevisionMismatch ErrorCode = "REVISION_MISMATCH"
+	// CodeMessageTooLarge is a payload over the size limit; the error
+	// carries the limit
+	CodeMessageTooLarge ErrorCode = "MESSAGE_TOO_LARGE"
+	// CodeSlowConsumer is sent before disconnecting a client whose queue
+	// overflowed
+	CodeSlowConsumer ErrorCode = "SLOW_CONSUMER"
+	CodeRateLimited  ErrorCode = "RATE_LIMITED"
+	// CodeQuotaExceeded rejects creating or transferring a topic to a
+	// tenant that owns as many topics as it may
+	CodeQuotaExceeded ErrorCode = "QUOTA_EXCEEDED"
+	// CodeHubSaturated rejects a publish while the topic's backlog is full
+	CodeHubSaturated ErrorCode = "HUB_SATURATED"
+	// CodeHubPaused rejects a publish while the hub is paused for
+	// maintenance in reject mode
+	CodeHubPaused          ErrorCode = "HUB_PAUSED"
+	CodeServerShuttingDown ErrorCode = "SERVER_SHUTTING_DOWN"
+	// CodeRequestTimeout is a request that ran out of time, or was
+	// cancelled by its caller, before the hub finished with it
+	CodeRequestTimeout ErrorCode = "REQUEST_TIMEOUT"
+	CodeInternal       ErrorCode = "INTERNAL_ERROR"
+)
+
+// HTTPStatus returns the HTTP status REST handlers respond with for the code
+func (c ErrorCode) HTTPStatus() int {
+	switch c {
+	case CodeBadRequest, CodeSchemaViolation:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden, CodeQuotaExceeded:
+		return http.StatusForbidden
+	case CodeTopicNotFound, CodeClientNotFound, CodeSchemaNotFound, CodeGroupNotFound, CodeStateNotFound:
+		return http.StatusNotFound
+	case CodeTopicExists, CodeGroupActive, CodeTopicDraining, CodeTopicDeleted:
+		return http.StatusConflict
+	case CodeRevisionMismatch:
+		return http.StatusPreconditionFailed
+	case CodeMessageTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeSlowConsumer, CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeHubSaturated, CodeHubPaused, CodeServerShuttingDown, CodeRequestTimeout:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CodeOf returns the error code for an error returned by the hub or by
+// message validation. Errors without a more specific code are bad requests.
+func CodeOf(err error) ErrorCode {
+	var tooLarge *MessageTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return CodeMessageTooLarge
+	case errors.Is(err, ErrTopicNotFound):
+		return CodeTopicNotFound
+	case errors.Is(err, ErrClientNotFound):
+		return CodeClientNotFound
+	case errors.Is(err, ErrTopicExists):
+		return CodeTopicExists
+	case errors.Is(err, ErrTopicDeleted):
+		return CodeTopicDeleted
+	case errors.Is(err, ErrTopicDraining):
+		return CodeTopicDraining
+	case errors.Is(err, ErrSchemaNotFound):
+		return CodeSchemaNotFound
+	case errors.Is(err, ErrSchemaViolation):
+		return CodeSchemaViolation
+	case errors.Is(err, ErrGroupNotFound):
+		return CodeGroupNotFound
+	case errors.Is(err, ErrStateNotFound):
+		return CodeStateNotFound
+	case errors.Is(err, ErrGroupActive):
+		return CodeGroupActive
+	case errors.Is(err, ErrRevisionMismatch):
+		return CodeRevisionMismatch
+	case errors.Is(err, ErrKeyIDMismatch), errors.Is(err, ErrNotPermitted), errors.Is(err, ErrInboxPrivate):
+		return CodeForbidden
+	case errors.Is(err, ErrTenantQuota):
+		return CodeQuotaExceeded
+	case errors.Is(err, ErrHubSaturated):
+		return CodeHubSaturated
+	case errors.Is(err, ErrHubPaused):
+		return CodeHubPaused
+	case errors.Is(err, ErrShuttingDown):
+		return CodeServerShuttingDown
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return CodeRequestTimeout
+	default:
+		return CodeBadRequest
+	}
+}
+
+// NewError creates an error body with the given code and message
+func NewError(code ErrorCode, message string) *ErrorData {
+	return &ErrorData{Code: code, Message: message}
+}
+
+// ErrorFrom creates an error body for err, with the code from CodeOf and,
+// for oversized payloads, the exceeded limit
+func ErrorFrom(err error) *ErrorData {
+	data := NewError(CodeOf(err), err.Error())
+	var tooLarge *MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		data.Limit = tooLarge.Limit
+	}
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		data.Results = batchErr.Results
+	}
+	return data
+}
+
+// ClientErrorEvent is the payload of a $SYS/errors event, published for
+// every error frame sent to a client
+type ClientErrorEvent struct {
+	ClientID  string    `json:"client_id"`
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// errorCounter counts error frames sent to clients by code
+type errorCounter struct {
+	mu     sync.Mutex
+	counts map[ErrorCode]int64
+}
+
+func (c *errorCounter) add(code ErrorCode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[ErrorCode]int64)
+	}
+	c.counts[code]++
+}
+
+// snapshot returns a copy of the counts
+func (c *errorCounter) snapshot() map[ErrorCode]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[ErrorCode]int64, len(c.counts))
+	for code, n := range c.counts {
+		counts[code] = n
+	}
+	return counts
+}
+
+// recordClientError counts an error frame sent to a client and, when anyone
+// is subscribed to $SYS/errors, publishes it there
+func (h *Hub) recordClientError(c *Client, requestID string, errorData *ErrorData) {
+	h.errorCounts.add(errorData.Code)
+
+	h.publishSystemEvent(ErrorsTopic, ClientErrorEvent{
+		ClientID:  c.id,
+		Code:      errorData.Code,
+		Message:   errorData.Message,
+		RequestID: requestID,
+	})
+}
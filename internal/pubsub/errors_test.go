@@ -0,0 +1,134 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{ErrTopicNotFound, CodeTopicNotFound},
+		{ErrTopicExists, CodeTopicExists},
+		{ErrTopicDeleted, CodeTopicDeleted},
+		{ErrSchemaNotFound, CodeSchemaNotFound},
+		{ErrGroupNotFound, CodeGroupNotFound},
+		{ErrGroupActive, CodeGroupActive},
+		{ErrHubSaturated, CodeHubSaturated},
+		{ErrShuttingDown, CodeServerShuttingDown},
+		{context.DeadlineExceeded, CodeRequestTimeout},
+		{fmt.Errorf("publish: %w", context.Canceled), CodeRequestTimeout},
+		{fmt.Errorf("%w: bad limits", ErrInvalidReplay), CodeBadRequest},
+		{&InvalidMessageError{Field: "id", Reason: "is required"}, CodeBadRequest},
+		{&MessageTooLargeError{Size: 10, Limit: 5}, CodeMessageTooLarge},
+		{ErrReservedTopic, CodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		if got := CodeOf(tt.err); got != tt.want {
+			t.Errorf("CodeOf(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCodeHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code ErrorCode
+		want int
+	}{
+		{CodeBadRequest, http.StatusBadRequest},
+		{CodeUnauthorized, http.StatusUnauthorized},
+		{CodeTopicNotFound, http.StatusNotFound},
+		{CodeTopicExists, http.StatusConflict},
+		{CodeTopicDraining, http.StatusConflict},
+		{CodeTopicDeleted, http.StatusConflict},
+		{CodeMessageTooLarge, http.StatusRequestEntityTooLarge},
+		{CodeRateLimited, http.StatusTooManyRequests},
+		{CodeHubSaturated, http.StatusServiceUnavailable},
+		{CodeRequestTimeout, http.StatusServiceUnavailable},
+		{CodeInternal, http.StatusInternalServerError},
+		{ErrorCode("UNKNOWN"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := tt.code.HTTPStatus(); got != tt.want {
+			t.Errorf("%s.HTTPStatus() = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestErrorFromCarriesLimit(t *testing.T) {
+	data := ErrorFrom(fmt.Errorf("publish: %w", &MessageTooLargeError{Size: 10, Limit: 5}))
+	if data.Code != CodeMessageTooLarge || data.Limit != 5 {
+		t.Errorf("Expected MESSAGE_TOO_LARGE with limit 5, got %+v", data)
+	}
+
+	data = ErrorFrom(ErrTopicNotFound)
+	if data.Code != CodeTopicNotFound || data.Message != ErrTopicNotFound.Error() || data.Limit != 0 {
+		t.Errorf("Expected TOPIC_NOT_FOUND without a limit, got %+v", data)
+	}
+}
+
+func TestClientErrorsAreCountedAndPublished(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	watcher := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: watcher, topic: ErrorsTopic})
+
+	client := newTestClient(hub)
+	client.id = "bad-client"
+	client.handleMessage(&ClientMessage{Type: "bogus", RequestID: "req-1"})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeBadRequest {
+		t.Fatalf("Expected a BAD_REQUEST error frame, got %+v", frames)
+	}
+
+	if counts := hub.GetStats().Errors; counts[CodeBadRequest] != 1 {
+		t.Errorf("Expected 1 BAD_REQUEST counted, got %v", counts)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for watcher.queue.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the $SYS/errors event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	events := drainFrames(t, watcher)
+	if len(events) != 1 || events[0].Topic != ErrorsTopic {
+		t.Fatalf("Expected one $SYS/errors event, got %+v", events)
+	}
+	payload, _ := events[0].Message.Payload.(map[string]interface{})
+	if payload["client_id"] != "bad-client" || payload["code"] != string(CodeBadRequest) || payload["request_id"] != "req-1" {
+		t.Errorf("Unexpected error event payload: %v", events[0].Message.Payload)
+	}
+}
+
+func TestClientCannotPublishToErrorsTopic(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.handleMessage(&ClientMessage{
+		Type:      PublishMessage,
+		Topic:     ErrorsTopic,
+		Message:   &MessageData{ID: "spoofed", Payload: "x"},
+		RequestID: "pub-1",
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Message != ErrReservedTopic.Error() {
+		t.Fatalf("Expected a reserved topic error, got %+v", frames)
+	}
+	if pending := hub.publishes.pending(); pending != 0 {
+		t.Errorf("Expected nothing queued for fan-out, got %d", pending)
+	}
+}
@@ -0,0 +1,141 @@
+package pubsub
+
+import "sync"
+
+// maxTopicExecutorQueue bounds how many fan-outs a topic's executor queues
+const maxTopicExecutorQueue = 65536
+
+// topicExecutors fans out each topic's publishes on a goroutine of its own,
+// so a topic with thousands of slow subscribers only delays its own
+// deliveries. The shard loops hand each publish to its topic's executor; an
+// executor whose queue is full is saturated, and its topic is skipped until
+// the executor catches up, its publishes waiting in the topic's backlog
+// where they hold up nobody else's. Executors run one fan-out at a time in
+// order, so a topic's events keep their sequence order, and exit when idle.
+type topicExecutors struct {
+	mu        sync.Mutex
+	capacity  int
+	executors map[string]*topicExecutor
+	// Times each topic's executor filled its queue
+	saturations map[string]int64
+	total       int64
+}
+
+// topicExecutor is one topic's queue of fan-outs
+type topicExecutor struct {
+	queue []func()
+}
+
+// ExecutorStats reports the per-topic delivery executors' load
+type ExecutorStats struct {
+	// Capacity is how many fan-outs each executor queues
+	Capacity int `json:"capacity"`
+	// Active is how many topics have fan-outs running or queued, Queued
+	// how many are queued across them, and Saturated how many topics'
+	// executors are full
+	Active    int `json:"active"`
+	Queued    int `json:"queued"`
+	Saturated int `json:"saturated"`
+	// Saturations counts the times an executor filled its queue
+	Saturations int64 `json:"saturations"`
+}
+
+// newTopicExecutors creates executors queuing capacity fan-outs each, or
+// nil to fan out on the shard loops when capacity is 0
+func newTopicExecutors(capacity int) *topicExecutors {
+	if capacity <= 0 {
+		return nil
+	}
+	return &topicExecutors{
+		capacity:    capacity,
+		executors:   make(map[string]*topicExecutor),
+		saturations: make(map[string]int64),
+	}
+}
+
+// saturated reports whether a topic's executor is full
+func (x *topicExecutors) saturated(topic string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	e := x.executors[topic]
+	return e != nil && len(e.queue) >= x.capacity
+}
+
+// submit queues a fan-out on its topic's executor, starting the executor if
+// it is idle. Only the topic's shard loop submits, and only once saturated
+// reports room. The shard is woken when the executor has room again.
+func (x *topicExecutors) submit(topic string, shard *publishScheduler, job func()) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e, running := x.executors[topic]
+	if !running {
+		e = &topicExecutor{}
+		x.executors[topic] = e
+	}
+	e.queue = append(e.queue, job)
+	if len(e.queue) == x.capacity {
+		x.saturations[topic]++
+		x.total++
+	}
+	if !running {
+		go x.run(topic, e, shard)
+	}
+}
+
+// run works through an executor's queue, removing the executor once it is
+// empty
+func (x *topicExecutors) run(topic string, e *topicExecutor, shard *publishScheduler) {
+	for {
+		x.mu.Lock()
+		if len(e.queue) == 0 {
+			delete(x.executors, topic)
+			x.mu.Unlock()
+			return
+		}
+		job := e.queue[0]
+		wasFull := len(e.queue) >= x.capacity
+		e.queue[0] = nil
+		e.queue = e.queue[1:]
+		x.mu.Unlock()
+
+		if wasFull {
+			// The shard loop skipped the topic while it was full
+			shard.signal()
+		}
+		job()
+	}
+}
+
+// topicStats returns how many fan-outs a topic's executor has queued and
+// how often it filled up
+func (x *topicExecutors) topicStats(topic string) (queued int, saturations int64) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if e := x.executors[topic]; e != nil {
+		queued = len(e.queue)
+	}
+	return queued, x.saturations[topic]
+}
+
+// forget drops a removed topic's saturation count
+func (x *topicExecutors) forget(topic string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	delete(x.saturations, topic)
+}
+
+// stats summarizes the executors' load
+func (x *topicExecutors) stats() ExecutorStats {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	stats := ExecutorStats{Capacity: x.capacity, Active: len(x.executors), Saturations: x.total}
+	for _, e := range x.executors {
+		stats.Queued += len(e.queue)
+		if len(e.queue) >= x.capacity {
+			stats.Saturated++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextUnlessPassesOverBusyTopics(t *testing.T) {
+	s := newPublishScheduler(10)
+	for i := 0; i < 3; i++ {
+		s.push("busy", &PubSubMessage{Topic: "busy"}, 1, nil, nil)
+		s.push("quiet", &PubSubMessage{Topic: "quiet"}, 1, nil, nil)
+	}
+	busy := func(topic string) bool { return topic == "busy" }
+
+	for i := 0; i < 3; i++ {
+		message, ok := s.nextUnless(busy)
+		if !ok || message.Topic != "quiet" {
+			t.Fatalf("Expected quiet's publish %d, got %+v", i, message)
+		}
+	}
+	if _, ok := s.nextUnless(busy); ok {
+		t.Error("Expected nothing while only the busy topic has publishes")
+	}
+	if s.topicPending("busy") != 3 {
+		t.Errorf("Expected busy's publishes left in its backlog, got %d", s.topicPending("busy"))
+	}
+	if message, ok := s.next(); !ok || message.Topic != "busy" {
+		t.Errorf("Expected busy served once it isn't, got %+v", message)
+	}
+}
+
+func TestTopicExecutorsIsolateSlowTopic(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 100, TopicExecutorQueue: 2})
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("slow")
+	hub.CreateTopic("fast")
+	slow, fast := newTestClient(hub), newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: slow, topic: "slow"})
+	hub.subscribeClient(&Subscription{client: fast, topic: "fast"})
+
+	// Hold up the slow topic's executor with a fan-out that blocks, waiting
+	// for it to start so it no longer takes up the queue
+	started, release := make(chan struct{}), make(chan struct{})
+	hub.executors.submit("slow", hub.publishes.shard("slow"), func() {
+		close(started)
+		<-release
+	})
+	<-started
+	for i := 0; i < 10; i++ {
+		hub.TryPublish(&PubSubMessage{Topic: "slow", Message: &MessageData{ID: fmt.Sprint(i)}}, time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		hub.TryPublish(&PubSubMessage{Topic: "fast", Message: &MessageData{ID: fmt.Sprint(i)}}, time.Second)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for fast.queue.Len() < 10 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("The fast topic was held up behind the slow one: %d of 10 delivered", fast.queue.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stats, _ := hub.GetTopicStats("slow")
+	if stats.ExecutorQueued != 2 || stats.ExecutorSaturations != 1 || stats.Backlog != 8 {
+		t.Errorf("Expected 2 queued on the saturated executor and 8 in the backlog, got %d, %d saturations and %d", stats.ExecutorQueued, stats.ExecutorSaturations, stats.Backlog)
+	}
+	if executors := hub.GetStats().Executors; executors == nil || executors.Saturated != 1 {
+		t.Errorf("Expected one saturated executor, got %+v", executors)
+	}
+	close(release)
+
+	deadline = time.Now().Add(time.Second)
+	for slow.queue.Len() < 10 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out with %d of 10 slow events delivered", slow.queue.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for i, event := range drainFrames(t, slow) {
+		if event.Sequence != int64(i+1) {
+			t.Fatalf("Expected sequence %d, got %d", i+1, event.Sequence)
+		}
+	}
+}
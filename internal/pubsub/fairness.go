@@ -0,0 +1,280 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// dispatchBatch is how many publishes a shard loop fans out before it checks
+// for shutdown again
+const dispatchBatch = 32
+
+// MaxTopicWeight bounds a topic's scheduling weight
+const MaxTopicWeight = 100
+
+// expired is a deadline that has already passed, for pushes that must not
+// wait for room
+var expired = func() <-chan time.Time {
+	ch := make(chan time.Time)
+	close(ch)
+	return ch
+}()
+
+// validateWeight checks a topic's scheduling weight (0 = the default of 1)
+func validateWeight(weight int) error {
+	if weight < 0 || weight > MaxTopicWeight {
+		return fmt.Errorf("%w: must be between 1 and %d", ErrInvalidWeight, MaxTopicWeight)
+	}
+	return nil
+}
+
+// publishScheduler holds accepted publishes in per-topic FIFO backlogs and
+// hands them to its shard loop in weighted round-robin order: each topic with
+// pending messages gets up to its weight in dispatches per round. A burst on
+// one topic therefore only delays other topics by one round, and since each
+// backlog is bounded separately, a bursting topic's publishers block on their
+// own backlog without taking room from quiet topics.
+type publishScheduler struct {
+	mu       sync.Mutex
+	backlogs map[string]*topicBacklog
+	// ring of topics with pending messages, served from cursor onwards
+	ring     []*topicBacklog
+	cursor   int
+	total    int
+	capacity int // per topic
+
+	// ready wakes the shard loop when messages are pending
+	ready chan struct{}
+	// space is closed, and replaced, whenever a dispatch frees room in a
+	// full backlog
+	space chan struct{}
+}
+
+// topicBacklog is one topic's pending publishes
+type topicBacklog struct {
+	topic   string
+	weight  int
+	credit  int // dispatches left in the current round
+	pending []*PubSubMessage
+}
+
+// newPublishScheduler creates a scheduler holding at most capacity pending
+// publishes per topic
+func newPublishScheduler(capacity int) *publishScheduler {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &publishScheduler{
+		backlogs: make(map[string]*topicBacklog),
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}),
+	}
+}
+
+// push appends a message to the topic's backlog, waiting while the backlog
+// is full until room frees up, cancel is closed or deadline fires (a nil
+// deadline waits indefinitely). It returns how many of the topic's messages
+// were queued ahead of this one.
+func (s *publishScheduler) push(topic string, message *PubSubMessage, weight int, cancel <-chan struct{}, deadline <-chan time.Time) (int, error) {
+	return s.pushAll(topic, []*PubSubMessage{message}, weight, cancel, deadline)
+}
+
+// pushAll appends messages to the topic's backlog together, as push does
+// one, so nothing is queued between them. It waits for room for all of
+// them, or for the backlog to empty if they don't fit even then. There must
+// be at least one message.
+func (s *publishScheduler) pushAll(topic string, messages []*PubSubMessage, weight int, cancel <-chan struct{}, deadline <-chan time.Time) (int, error) {
+	for {
+		s.mu.Lock()
+		backlog := s.backlogs[topic]
+		if backlog == nil || len(backlog.pending) == 0 || len(backlog.pending)+len(messages) <= s.capacity {
+			ahead := s.append(topic, messages[0], weight)
+			for _, message := range messages[1:] {
+				s.append(topic, message, weight)
+			}
+			s.mu.Unlock()
+			s.signal()
+			return ahead, nil
+		}
+		ahead := len(backlog.pending)
+		space := s.space
+		s.mu.Unlock()
+
+		select {
+		case <-space:
+		case <-cancel:
+			return 0, ErrShuttingDown
+		case <-deadline:
+			return ahead, ErrHubSaturated
+		}
+	}
+}
+
+// append adds a message to the topic's backlog, putting the topic in the
+// ring if it had nothing pending. Caller must hold s.mu.
+func (s *publishScheduler) append(topic string, message *PubSubMessage, weight int) int {
+	backlog := s.backlogs[topic]
+	if backlog == nil {
+		if weight < 1 {
+			weight = 1
+		}
+		backlog = &topicBacklog{topic: topic, weight: weight, credit: weight}
+		s.backlogs[topic] = backlog
+		// Join the round just behind the cursor, so every topic already
+		// waiting is served first
+		s.ring = append(s.ring, nil)
+		copy(s.ring[s.cursor+1:], s.ring[s.cursor:])
+		s.ring[s.cursor] = backlog
+		s.cursor = (s.cursor + 1) % len(s.ring)
+	}
+
+	ahead := len(backlog.pending)
+	backlog.pending = append(backlog.pending, message)
+	s.total++
+	return ahead
+}
+
+// next removes and returns the next message in weighted round-robin order,
+// or false if nothing is pending
+func (s *publishScheduler) next() (*PubSubMessage, bool) {
+	return s.nextUnless(nil)
+}
+
+// nextUnless is next, passing over the topics busy reports (nil = none),
+// which lose their turn in the current round. It returns false if nothing
+// is pending for the other topics.
+func (s *publishScheduler) nextUnless(busy func(topic string) bool) (*PubSubMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.ring) == 0 {
+		return nil, false
+	}
+	if busy != nil {
+		skipped := 0
+		for busy(s.ring[s.cursor].topic) {
+			if skipped++; skipped == len(s.ring) {
+				return nil, false
+			}
+			s.cursor = (s.cursor + 1) % len(s.ring)
+		}
+	}
+
+	backlog := s.ring[s.cursor]
+	wasFull := len(backlog.pending) >= s.capacity
+	message := backlog.pending[0]
+	backlog.pending[0] = nil
+	backlog.pending = backlog.pending[1:]
+	backlog.credit--
+	s.total--
+
+	switch {
+	case len(backlog.pending) == 0:
+		// Drained: leave the ring; the next topic slides into the cursor
+		delete(s.backlogs, backlog.topic)
+		s.ring = append(s.ring[:s.cursor], s.ring[s.cursor+1:]...)
+		if s.cursor >= len(s.ring) {
+			s.cursor = 0
+		}
+	case backlog.credit == 0:
+		// Used up its turn: refill for the next round and move on
+		backlog.credit = backlog.weight
+		s.cursor = (s.cursor + 1) % len(s.ring)
+	}
+
+	if wasFull {
+		// Wake publishers waiting for room
+		close(s.space)
+		s.space = make(chan struct{})
+	}
+	return message, true
+}
+
+// pending returns the number of queued publishes across all topics
+func (s *publishScheduler) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// topicPending returns the number of the topic's queued publishes
+func (s *publishScheduler) topicPending(topic string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if backlog := s.backlogs[topic]; backlog != nil {
+		return len(backlog.pending)
+	}
+	return 0
+}
+
+// signal wakes the shard loop without blocking
+func (s *publishScheduler) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchShard fans out up to dispatchBatch of a shard's scheduled
+// publishes, then wakes its loop again if more are pending so that shutdown
+// is noticed during a long backlog. With topic executors, the publishes are
+// handed to them instead, passing over topics whose executor is saturated;
+// the executor wakes the loop once it has room.
+func (h *Hub) dispatchShard(shard *publishScheduler) {
+	// A buffering pause leaves publishes in their backlogs; resuming wakes
+	// the loops again
+	if h.buffering() {
+		return
+	}
+	var busy func(string) bool
+	if h.executors != nil {
+		busy = h.executors.saturated
+	}
+	for i := 0; i < dispatchBatch; i++ {
+		message, ok := shard.nextUnless(busy)
+		if !ok {
+			return
+		}
+		publish := func() {
+			h.safely("publish", func() { h.publishMessage(message) })
+		}
+		if h.executors != nil {
+			h.executors.submit(message.Topic, shard, publish)
+			continue
+		}
+		publish()
+	}
+	if shard.pending() > 0 {
+		shard.signal()
+	}
+}
+
+// dispatchPublishes fans out a batch from every shard in turn, for callers
+// driving the hub without its loops
+func (h *Hub) dispatchPublishes() {
+	for _, shard := range h.publishes.shards {
+		h.dispatchShard(shard)
+	}
+}
+
+// enqueuePublish schedules a message for fan-out, waiting while the topic's
+// backlog is full. It fails if the topic doesn't accept the message or the
+// hub shuts down while waiting.
+func (h *Hub) enqueuePublish(message *PubSubMessage) error {
+	weight, err := h.admitPublish(message)
+	if err != nil {
+		return err
+	}
+	_, err = h.publishes.push(message.Topic, message, weight, h.shutdown, nil)
+	return err
+}
+
+// schedulingWeight returns the topic's weight, defaulting to 1
+func (t *Topic) schedulingWeight() int {
+	if t.weight > 0 {
+		return t.weight
+	}
+	return 1
+}
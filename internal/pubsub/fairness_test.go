@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dispatchOrder drains the scheduler and returns the topics in dispatch order
+func dispatchOrder(s *publishScheduler) string {
+	var order []string
+	for {
+		message, ok := s.next()
+		if !ok {
+			return strings.Join(order, ",")
+		}
+		order = append(order, message.Topic)
+	}
+}
+
+func TestPublishSchedulerRoundRobin(t *testing.T) {
+	s := newPublishScheduler(100)
+	for i := 0; i < 5; i++ {
+		s.push("burst", &PubSubMessage{Topic: "burst"}, 1, nil, nil)
+	}
+	s.push("quiet", &PubSubMessage{Topic: "quiet"}, 1, nil, nil)
+
+	// The quiet topic waits one round, not behind the whole burst
+	if order := dispatchOrder(s); order != "burst,quiet,burst,burst,burst,burst" {
+		t.Errorf("Unexpected dispatch order: %s", order)
+	}
+	if s.pending() != 0 {
+		t.Errorf("Expected nothing pending, got %d", s.pending())
+	}
+}
+
+func TestPublishSchedulerWeights(t *testing.T) {
+	s := newPublishScheduler(100)
+	for i := 0; i < 4; i++ {
+		s.push("heavy", &PubSubMessage{Topic: "heavy"}, 2, nil, nil)
+		s.push("light", &PubSubMessage{Topic: "light"}, 1, nil, nil)
+	}
+
+	if order := dispatchOrder(s); order != "heavy,heavy,light,heavy,heavy,light,light,light" {
+		t.Errorf("Unexpected dispatch order: %s", order)
+	}
+}
+
+func TestPublishSchedulerKeepsTopicOrder(t *testing.T) {
+	s := newPublishScheduler(100)
+	for i := 0; i < 10; i++ {
+		for _, topic := range []string{"a", "b", "c"} {
+			s.push(topic, &PubSubMessage{Topic: topic, Sequence: int64(i)}, 1+i%3, nil, nil)
+		}
+	}
+
+	last := map[string]int64{"a": -1, "b": -1, "c": -1}
+	for {
+		message, ok := s.next()
+		if !ok {
+			break
+		}
+		if message.Sequence != last[message.Topic]+1 {
+			t.Fatalf("Topic %s dispatched %d after %d", message.Topic, message.Sequence, last[message.Topic])
+		}
+		last[message.Topic] = message.Sequence
+	}
+}
+
+func TestPublishSchedulerPerTopicCapacity(t *testing.T) {
+	s := newPublishScheduler(2)
+	s.push("burst", &PubSubMessage{Topic: "burst"}, 1, nil, nil)
+	s.push("burst", &PubSubMessage{Topic: "burst"}, 1, nil, nil)
+
+	ahead, err := s.push("burst", &PubSubMessage{Topic: "burst"}, 1, nil, time.After(10*time.Millisecond))
+	if !errors.Is(err, ErrHubSaturated) || ahead != 2 {
+		t.Errorf("Expected ErrHubSaturated with 2 ahead, got %d, %v", ahead, err)
+	}
+
+	// A full backlog doesn't take room from other topics
+	if _, err := s.push("quiet", &PubSubMessage{Topic: "quiet"}, 1, nil, time.After(10*time.Millisecond)); err != nil {
+		t.Errorf("Expected quiet topic to be accepted, got %v", err)
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := s.push("burst", &PubSubMessage{Topic: "burst"}, 1, cancel, nil); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown, got %v", err)
+	}
+
+	// A blocked publisher proceeds once a dispatch frees room
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.push("burst", &PubSubMessage{Topic: "burst"}, 1, nil, time.After(time.Second))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.next()
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected blocked publish to be accepted, got %v", err)
+	}
+	if n := s.topicPending("burst"); n != 2 {
+		t.Errorf("Expected 2 pending burst publishes, got %d", n)
+	}
+}
+
+func TestHubTopicWeightAndBacklog(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("payments", TopicOptions{Weight: 4}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+	hub.CreateTopic("orders")
+
+	for _, weight := range []int{-1, MaxTopicWeight + 1} {
+		err := hub.CreateTopicWithOptions(fmt.Sprintf("bad-%d", weight), TopicOptions{Weight: weight})
+		if !errors.Is(err, ErrInvalidWeight) {
+			t.Errorf("Expected ErrInvalidWeight for weight %d, got %v", weight, err)
+		}
+	}
+
+	// The hub isn't running, so publishes stay in the backlog
+	hub.enqueuePublish(&PubSubMessage{Topic: "payments"})
+
+	stats, _ := hub.GetTopicStats("payments")
+	if stats.Weight != 4 || stats.Backlog != 1 {
+		t.Errorf("Expected weight 4 and backlog 1, got %d and %d", stats.Weight, stats.Backlog)
+	}
+
+	stats, _ = hub.GetTopicStats("orders")
+	if stats.Weight != 1 || stats.Backlog != 0 {
+		t.Errorf("Expected default weight 1 and empty backlog, got %d and %d", stats.Weight, stats.Backlog)
+	}
+
+	if depth, capacity := hub.PublishBacklog("payments"); depth != 1 || capacity != DefaultHubOptions().PublishBuffer {
+		t.Errorf("Expected payments backlog 1 of %d, got %d of %d", DefaultHubOptions().PublishBuffer, depth, capacity)
+	}
+}
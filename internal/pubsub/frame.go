@@ -0,0 +1,42 @@
+package pubsub
+
+import "sync"
+
+// frameEncoding is a wire encoding of event frames
+type frameEncoding int
+
+const (
+	encodingJSON frameEncoding = iota
+	encodingMsgpack
+	frameEncodings
+)
+
+// eventFrames caches a published message's encoded event frame, one per
+// encoding, so a broadcast encodes it once however many subscribers it
+// reaches, and replays reuse the same frame. The frames are shared and must
+// not be modified.
+type eventFrames struct {
+	once [frameEncodings]sync.Once
+	data [frameEncodings][]byte
+}
+
+// get returns the frame in an encoding, encoding it on first use
+func (f *eventFrames) get(encoding frameEncoding, encode func() []byte) []byte {
+	f.once[encoding].Do(func() { f.data[encoding] = encode() })
+	return f.data[encoding]
+}
+
+// eventFrame returns a message's event frame in an encoding, from its cache
+// when it has one. Frames stamped with an audit sequence are per subscriber
+// and never cached.
+func (h *Hub) eventFrame(message *PubSubMessage, auditSeq int64, encoding frameEncoding) []byte {
+	if auditSeq != 0 || message.frames == nil {
+		return encodeFrame(h.createEventMessageBytes(message, auditSeq), encoding)
+	}
+	return message.frames.get(encoding, func() []byte {
+		if encoding == encodingJSON {
+			return h.createEventMessageBytes(message, 0)
+		}
+		return encodeFrame(h.eventFrame(message, 0, encodingJSON), encoding)
+	})
+}
@@ -0,0 +1,55 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestBroadcastSharesEventFrame(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	first, second, projected := newTestClient(hub), newTestClient(hub), newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: first, topic: "orders"})
+	hub.subscribeClient(&Subscription{client: second, topic: "orders"})
+	hub.subscribeClient(&Subscription{client: projected, topic: "orders"})
+	projected.options["orders"] = subscriptionOptions{fields: []string{"id"}}
+
+	message := benchMessage("orders", 1, 64)
+	hub.publishMessage(message)
+
+	a, _ := first.queue.Drain()
+	b, _ := second.queue.Drain()
+	p, _ := projected.queue.Drain()
+	if len(a) != 1 || len(b) != 1 || len(p) != 1 {
+		t.Fatalf("Expected one frame each, got %d, %d and %d", len(a), len(b), len(p))
+	}
+	if &a[0][0] != &b[0][0] {
+		t.Error("Expected subscribers to share one encoded frame")
+	}
+	if &p[0][0] == &a[0][0] || len(p[0]) >= len(a[0]) {
+		t.Error("Expected the projected subscriber to get its own, smaller frame")
+	}
+
+	// Replays reuse the frame too
+	replayed := newTestClient(hub)
+	replayed.sendReplayEvent(hub.retained("orders", 1)[0])
+	r, _ := replayed.queue.Drain()
+	if len(r) != 1 || &r[0][0] != &a[0][0] {
+		t.Error("Expected the replayed event to reuse the broadcast frame")
+	}
+}
+
+func TestAuditedFramesAreNotShared(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{OrderingAudit: true})
+	hub.CreateTopic("orders")
+	first, second := newTestClient(hub), newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: first, topic: "orders"})
+	hub.subscribeClient(&Subscription{client: second, topic: "orders"})
+
+	hub.publishMessage(benchMessage("orders", 1, 64))
+
+	a := drainFrames(t, first)
+	b := drainFrames(t, second)
+	if len(a) != 1 || len(b) != 1 || a[0].AuditSeq != 1 || b[0].AuditSeq != 1 {
+		t.Fatalf("Expected one audited frame each, got %+v and %+v", a, b)
+	}
+}
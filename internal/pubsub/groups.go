@@ -0,0 +1,279 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"plivo/internal/logging"
+)
+
+// groupCursor is a consumer group's position in a topic
+type groupCursor struct {
+	offset    int64 // sequence of the last message handed to a member
+	updatedAt time.Time
+	activeAt  time.Time // last seen with a connected member
+	next      int       // round-robin position among connected members
+	// On partitioned topics, the members partitions were last assigned
+	// among, the owner of each partition and the count of rebalances
+	members    []*Client
+	owners     []*Client
+	generation int64
+}
+
+// GroupExpiredEvent is the payload of a $SYS/groups event, published when a
+// consumer group without members for the expiry window is dropped
+type GroupExpiredEvent struct {
+	Event string `json:"event"` // always "expired"
+	Topic string `json:"topic"`
+	Group string `json:"group"`
+	// Offset and Lag are the group's position when it expired
+	Offset int64 `json:"offset"`
+	Lag    int64 `json:"lag"`
+	// ActiveAt is when the group last had a connected member
+	ActiveAt time.Time `json:"active_at"`
+}
+
+// GroupOffset describes a consumer group's position in a topic
+type GroupOffset struct {
+	Topic string `json:"topic"`
+	Group string `json:"group"`
+	// Offset is the sequence of the last message delivered to the group
+	Offset int64 `json:"offset"`
+	// Sequence is the topic's current sequence
+	Sequence int64 `json:"sequence"`
+	// Lag is how many published messages the group has not received
+	Lag int64 `json:"lag"`
+	// OldestRetained is the oldest sequence still available for replay (0 if none)
+	OldestRetained int64 `json:"oldest_retained"`
+	// Members is the number of connected subscribers in the group
+	Members   int       `json:"members"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// On partitioned topics, Generation counts the group's rebalances and
+	// Partitions maps member client IDs to the partitions they own
+	Generation int64            `json:"generation,omitempty"`
+	Partitions map[string][]int `json:"partitions,omitempty"`
+}
+
+// SetGroupOffsetRequest is the request body for rewinding a consumer group
+type SetGroupOffsetRequest struct {
+	Offset int64 `json:"offset"`
+}
+
+// ValidateGroupName checks that a consumer group name is usable in URLs
+func ValidateGroupName(name string) error {
+	if name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("group name must not contain '/'")
+	}
+	return nil
+}
+
+// GetGroupOffset returns a consumer group's position in a topic
+func (h *Hub) GetGroupOffset(topicName, group string) (GroupOffset, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return GroupOffset{}, ErrTopicNotFound
+	}
+
+	cursor, exists := topic.groups[group]
+	if !exists {
+		return GroupOffset{}, ErrGroupNotFound
+	}
+
+	return h.groupOffset(topic, group, cursor), nil
+}
+
+// SetGroupOffset moves a consumer group to an offset, creating the group if
+// needed. Members that subscribe afterwards resume after the new offset, so
+// rewinding replays retained messages for reprocessing. The group must have
+// no connected members, otherwise live deliveries would race the rewind.
+func (h *Hub) SetGroupOffset(topicName, group string, offset int64) (GroupOffset, error) {
+	if err := ValidateGroupName(group); err != nil {
+		return GroupOffset{}, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return GroupOffset{}, ErrTopicNotFound
+	}
+
+	if offset < 0 || offset > topic.Sequence {
+		return GroupOffset{}, fmt.Errorf("%w: must be between 0 and %d", ErrInvalidOffset, topic.Sequence)
+	}
+
+	if h.groupMembers(topicName, group) > 0 {
+		return GroupOffset{}, ErrGroupActive
+	}
+
+	now := h.clock.Now()
+	cursor := topic.groupCursor(group, now)
+	cursor.offset = offset
+	cursor.updatedAt = now
+	cursor.activeAt = now
+
+	return h.groupOffset(topic, group, cursor), nil
+}
+
+// commitGroupOffset advances a consumer group past a delivered message
+func (h *Hub) commitGroupOffset(topicName, group string, sequence int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return
+	}
+
+	now := h.clock.Now()
+	cursor := topic.groupCursor(group, now)
+	if sequence > cursor.offset {
+		cursor.offset = sequence
+		cursor.updatedAt = now
+	}
+}
+
+// groupMembers counts the connected subscribers of a topic in a consumer
+// group. Caller must hold the hub lock.
+func (h *Hub) groupMembers(topicName, group string) int {
+	members := 0
+	for client := range h.subscriptions[topicName] {
+		if client.subscriptionGroup(topicName) == group {
+			members++
+		}
+	}
+	return members
+}
+
+// deliveryTargets picks the subscribers a message goes to: every subscriber
+// outside a consumer group, and one member of each group. On partitioned
+// topics that is the member owning the message's partition; otherwise
+// members take turns so the group shares the load. Caller must hold the hub
+// write lock.
+func (h *Hub) deliveryTargets(message *PubSubMessage, subscribers map[*Client]bool) []*Client {
+	topicName := message.Topic
+	targets := make([]*Client, 0, len(subscribers))
+	var groups map[string][]*Client
+	for client := range subscribers {
+		group := client.subscriptionGroup(topicName)
+		if group == "" {
+			targets = append(targets, client)
+			continue
+		}
+		if groups == nil {
+			groups = make(map[string][]*Client)
+		}
+		groups[group] = append(groups[group], client)
+	}
+
+	topic := h.topics[topicName]
+	for group, members := range groups {
+		// Subscriber sets are unordered, so take turns in client ID order
+		sortMembers(members)
+		if topic != nil {
+			if owner := topic.partitionOwner(group, message, members); owner != nil {
+				targets = append(targets, owner)
+				continue
+			}
+		}
+		next := 0
+		if topic != nil {
+			cursor := topic.groupCursor(group, h.clock.Now())
+			next = cursor.next % len(members)
+			cursor.next = next + 1
+		}
+		targets = append(targets, members[next])
+	}
+	return targets
+}
+
+// groupOffset builds the external view of a group cursor. Caller must hold
+// the hub lock.
+func (h *Hub) groupOffset(topic *Topic, group string, cursor *groupCursor) GroupOffset {
+	offset := GroupOffset{
+		Topic:     topic.Name,
+		Group:     group,
+		Offset:    cursor.offset,
+		Sequence:  topic.Sequence,
+		Lag:       topic.Sequence - cursor.offset,
+		Members:   h.groupMembers(topic.Name, group),
+		UpdatedAt: cursor.updatedAt,
+	}
+	if retained := h.retained(topic.Name, 0); len(retained) > 0 {
+		offset.OldestRetained = retained[0].Sequence
+	}
+	if topic.partitioning != nil && cursor.members != nil {
+		offset.Generation = cursor.generation
+		offset.Partitions = make(map[string][]int, len(cursor.members))
+		for _, member := range cursor.members {
+			offset.Partitions[member.id] = ownedPartitions(cursor.owners, member)
+		}
+	}
+	return offset
+}
+
+// groupCursor returns a consumer group's cursor, creating it at the topic's
+// current sequence so a new group starts with live messages, created now.
+// Caller must hold the hub write lock.
+func (t *Topic) groupCursor(group string, now time.Time) *groupCursor {
+	if t.groups == nil {
+		t.groups = make(map[string]*groupCursor)
+	}
+
+	cursor, exists := t.groups[group]
+	if !exists {
+		cursor = &groupCursor{offset: t.Sequence, updatedAt: now, activeAt: now}
+		t.groups[group] = cursor
+	}
+	return cursor
+}
+
+// expireIdleGroups drops consumer groups that have had no connected member
+// for the expiry window and reports each on $SYS/groups. Run from the
+// reconcile tick, so a group's activity is seen to within reconcileInterval.
+func (h *Hub) expireIdleGroups() {
+	if h.groupExpiry <= 0 {
+		return
+	}
+
+	now := h.clock.Now()
+	var expired []GroupExpiredEvent
+
+	h.mu.Lock()
+	for name, topic := range h.topics {
+		for group, cursor := range topic.groups {
+			if h.groupMembers(name, group) > 0 {
+				cursor.activeAt = now
+				continue
+			}
+			if now.Sub(cursor.activeAt) < h.groupExpiry {
+				continue
+			}
+			delete(topic.groups, group)
+			expired = append(expired, GroupExpiredEvent{
+				Event:    "expired",
+				Topic:    name,
+				Group:    group,
+				Offset:   cursor.offset,
+				Lag:      topic.Sequence - cursor.offset,
+				ActiveAt: cursor.activeAt,
+			})
+		}
+	}
+	h.mu.Unlock()
+
+	// Publish outside the lock, which publishSystemEvent takes itself
+	for _, event := range expired {
+		slog.Info("Expired consumer group", logging.Topic, event.Topic, "group", event.Group, "idle_since", event.ActiveAt.Format(time.RFC3339))
+		h.publishSystemEvent(GroupsTopic, event)
+	}
+}
@@ -0,0 +1,337 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitForSubscribers waits until the hub has processed pending subscription changes
+func waitForSubscribers(t *testing.T, hub *Hub, topic string, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for hub.GetTopics()[topic].SubscriberCount != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d subscribers on %s", count, topic)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGroupOffsetAdvancesAndRewinds(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+
+	member := newTestClient(hub)
+	member.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "worker-1", Group: "billing"})
+	waitForSubscribers(t, hub, "orders", 1)
+
+	for i := 1; i <= 3; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+
+	offset, err := hub.GetGroupOffset("orders", "billing")
+	if err != nil {
+		t.Fatalf("GetGroupOffset failed: %v", err)
+	}
+	if offset.Offset != 3 || offset.Lag != 0 || offset.Members != 1 || offset.OldestRetained != 1 {
+		t.Errorf("Expected offset 3, lag 0, 1 member, oldest 1, got %+v", offset)
+	}
+
+	// Rewinding is refused while the group has connected members
+	if _, err := hub.SetGroupOffset("orders", "billing", 1); err != ErrGroupActive {
+		t.Errorf("Expected ErrGroupActive, got %v", err)
+	}
+
+	member.handleMessage(&ClientMessage{Type: UnsubscribeMessage, Topic: "orders", ClientID: "worker-1"})
+	waitForSubscribers(t, hub, "orders", 0)
+
+	offset, err = hub.SetGroupOffset("orders", "billing", 1)
+	if err != nil {
+		t.Fatalf("SetGroupOffset failed: %v", err)
+	}
+	if offset.Offset != 1 || offset.Lag != 2 {
+		t.Errorf("Expected offset 1 with lag 2, got %+v", offset)
+	}
+
+	// A new member resumes after the rewound offset
+	replayer := newTestClient(hub)
+	replayer.opts.ReplayRate = 0
+	replayer.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "worker-2", Group: "billing"})
+
+	var frames []ServerMessage
+	deadline := time.Now().Add(time.Second)
+	for len(frames) < 3 && time.Now().Before(deadline) {
+		frames = append(frames, drainFrames(t, replayer)...)
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(frames) != 3 {
+		t.Fatalf("Expected an ack and 2 replayed events, got %d frames", len(frames))
+	}
+
+	info := frames[0].Subscription
+	if info == nil || info.Offset != 1 || info.Replaying != 2 {
+		t.Errorf("Expected ack with offset 1 replaying 2, got %+v", info)
+	}
+
+	if frames[1].Message.ID != "msg-2" || frames[2].Message.ID != "msg-3" {
+		t.Errorf("Expected msg-2 and msg-3 to be replayed, got %s and %s", frames[1].Message.ID, frames[2].Message.ID)
+	}
+
+	offset, _ = hub.GetGroupOffset("orders", "billing")
+	if offset.Offset != 3 {
+		t.Errorf("Expected replay to advance the offset back to 3, got %d", offset.Offset)
+	}
+}
+
+func TestGroupCatchesUpOnEventsPublishedWhileDisconnected(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+
+	member := newTestClient(hub)
+	member.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "worker-1", Group: "billing"})
+	waitForSubscribers(t, hub, "orders", 1)
+	hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "msg-1"}})
+	member.handleMessage(&ClientMessage{Type: UnsubscribeMessage, Topic: "orders", ClientID: "worker-1"})
+	waitForSubscribers(t, hub, "orders", 0)
+
+	// Nobody is subscribed, so these are only retained
+	for i := 2; i <= 4; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+	offset, _ := hub.GetGroupOffset("orders", "billing")
+	if offset.Offset != 1 || offset.Lag != 3 {
+		t.Fatalf("Expected offset 1 with lag 3, got %+v", offset)
+	}
+
+	rejoined := newTestClient(hub)
+	rejoined.opts.ReplayRate = 0
+	rejoined.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "worker-1", Group: "billing"})
+
+	var frames []ServerMessage
+	deadline := time.Now().Add(time.Second)
+	for len(frames) < 4 && time.Now().Before(deadline) {
+		frames = append(frames, drainFrames(t, rejoined)...)
+		time.Sleep(time.Millisecond)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("Expected an ack and 3 replayed events, got %d frames", len(frames))
+	}
+	if info := frames[0].Subscription; info == nil || info.Offset != 1 || info.Replaying != 3 {
+		t.Errorf("Expected ack with offset 1 replaying 3, got %+v", info)
+	}
+	for i, frame := range frames[1:] {
+		if want := fmt.Sprintf("msg-%d", i+2); frame.Message == nil || frame.Message.ID != want {
+			t.Errorf("Expected %s replayed, got %+v", want, frame.Message)
+		}
+	}
+}
+
+func TestGroupOffsetErrors(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	if _, err := hub.GetGroupOffset("orders", "unknown"); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+
+	if _, err := hub.GetGroupOffset("missing", "billing"); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+
+	if _, err := hub.SetGroupOffset("missing", "billing", 0); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+
+	if _, err := hub.SetGroupOffset("orders", "billing", 5); !errors.Is(err, ErrInvalidOffset) {
+		t.Errorf("Expected ErrInvalidOffset beyond the topic sequence, got %v", err)
+	}
+
+	if _, err := hub.SetGroupOffset("orders", "a/b", 0); err == nil {
+		t.Error("Expected group names containing '/' to be rejected")
+	}
+
+	// Setting an offset creates the group
+	if _, err := hub.SetGroupOffset("orders", "billing", 0); err != nil {
+		t.Errorf("Expected group to be created, got %v", err)
+	}
+	if _, err := hub.GetGroupOffset("orders", "billing"); err != nil {
+		t.Errorf("Expected created group to be found, got %v", err)
+	}
+}
+
+func TestIdleGroupsExpire(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.GroupExpiry = time.Hour
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+	hub.SetGroupOffset("orders", "billing", 0)
+	hub.SetGroupOffset("orders", "shipping", 0)
+
+	member := newTestClient(hub)
+	member.options["orders"] = subscriptionOptions{group: "shipping"}
+	hub.subscribeClient(&Subscription{client: member, topic: "orders"})
+	retainMessages(hub, "orders", 2)
+
+	watcher := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: watcher, topic: GroupsTopic})
+
+	// Both groups were last seen active beyond the window; only billing
+	// has no members now
+	hub.mu.Lock()
+	idleSince := time.Now().Add(-2 * time.Hour)
+	for _, cursor := range hub.topics["orders"].groups {
+		cursor.activeAt = idleSince
+	}
+	hub.mu.Unlock()
+
+	hub.expireIdleGroups()
+
+	if _, err := hub.GetGroupOffset("orders", "billing"); err != ErrGroupNotFound {
+		t.Errorf("Expected billing to expire, got %v", err)
+	}
+	if _, err := hub.GetGroupOffset("orders", "shipping"); err != nil {
+		t.Errorf("Expected shipping with a member to stay, got %v", err)
+	}
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	deadline := time.Now().Add(time.Second)
+	for watcher.queue.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the $SYS/groups event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	events := drainFrames(t, watcher)
+	if len(events) != 1 || events[0].Topic != GroupsTopic {
+		t.Fatalf("Expected one $SYS/groups event, got %+v", events)
+	}
+	payload, _ := events[0].Message.Payload.(map[string]interface{})
+	if payload["event"] != "expired" || payload["topic"] != "orders" || payload["group"] != "billing" ||
+		payload["offset"] != float64(0) || payload["lag"] != float64(2) {
+		t.Errorf("Unexpected group expiry payload: %v", events[0].Message.Payload)
+	}
+}
+
+func TestGroupsNeverExpireByDefault(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.SetGroupOffset("orders", "billing", 0)
+
+	hub.mu.Lock()
+	hub.topics["orders"].groups["billing"].activeAt = time.Now().Add(-24 * 365 * time.Hour)
+	hub.mu.Unlock()
+
+	hub.expireIdleGroups()
+	if _, err := hub.GetGroupOffset("orders", "billing"); err != nil {
+		t.Errorf("Expected the group kept without an expiry window, got %v", err)
+	}
+}
+
+func TestGroupMembersShareEventsRoundRobin(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	subscribe := func(id, group string) *Client {
+		client := newTestClient(hub)
+		client.id = id
+		client.subscriptions["orders"] = true
+		client.options["orders"] = subscriptionOptions{group: group}
+		hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+		return client
+	}
+	workers := []*Client{subscribe("worker-1", "billing"), subscribe("worker-2", "billing"), subscribe("worker-3", "billing")}
+	auditor := subscribe("auditor", "")
+	shipper := subscribe("shipper", "shipping")
+
+	for i := 1; i <= 6; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+
+	sequences := func(client *Client) string {
+		var got []int64
+		for _, frame := range drainFrames(t, client) {
+			got = append(got, frame.Sequence)
+		}
+		return fmt.Sprint(got)
+	}
+	for i, want := range []string{"[1 4]", "[2 5]", "[3 6]"} {
+		if got := sequences(workers[i]); got != want {
+			t.Errorf("Expected worker-%d to get %s, got %s", i+1, want, got)
+		}
+	}
+	if got := sequences(auditor); got != "[1 2 3 4 5 6]" {
+		t.Errorf("Expected the subscriber outside any group to get every event, got %s", got)
+	}
+	if got := sequences(shipper); got != "[1 2 3 4 5 6]" {
+		t.Errorf("Expected the only member of another group to get every event, got %s", got)
+	}
+
+	offset, _ := hub.GetGroupOffset("orders", "billing")
+	if offset.Offset != 6 || offset.Members != 3 {
+		t.Errorf("Expected the group at offset 6 with 3 members, got %+v", offset)
+	}
+
+	// Members that leave stop taking turns
+	hub.unsubscribeClient(&Subscription{client: workers[1], topic: "orders"})
+	for i := 7; i <= 8; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+	if got := sequences(workers[0]) + sequences(workers[2]); got != "[7][8]" && got != "[8][7]" {
+		t.Errorf("Expected the remaining members to share events 7 and 8, got %s", got)
+	}
+}
+
+func TestStreamJoinsGroup(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	var streams []*Stream
+	for _, id := range []string{"stream-1", "stream-2"} {
+		stream, err := hub.OpenStream(id, "orders", StreamOptions{Group: "billing"}, DefaultClientOptions())
+		if err != nil {
+			t.Fatalf("OpenStream failed: %v", err)
+		}
+		defer stream.Close()
+		streams = append(streams, stream)
+	}
+	waitForSubscribers(t, hub, "orders", 2)
+
+	for i := 1; i <= 4; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+	for i, stream := range streams {
+		var got []int64
+		deadline := time.Now().Add(time.Second)
+		for len(got) < 2 && time.Now().Before(deadline) {
+			frames, _ := stream.Next()
+			for _, frame := range frames {
+				if frame.Sequence > 0 {
+					got = append(got, frame.Sequence)
+				}
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if want := fmt.Sprint([]int64{int64(i + 1), int64(i + 3)}); fmt.Sprint(got) != want {
+			t.Errorf("Expected stream-%d to get %s, got %v", i+1, want, got)
+		}
+	}
+
+	if _, err := hub.OpenStream("stream-3", "orders", StreamOptions{Group: "a/b"}, DefaultClientOptions()); err == nil {
+		t.Error("Expected an invalid group name to be refused")
+	}
+}
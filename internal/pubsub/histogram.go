@@ -0,0 +1,97 @@
+package pubsub
+
+import "sync"
+
+// sizeBucketBounds are the upper bounds (in bytes) of the payload size buckets.
+// Buckets grow by powers of two from 64B up to 16MB; anything larger lands in
+// the overflow bucket.
+var sizeBucketBounds = func() []int {
+	bounds := make([]int, 0, 19)
+	for b := 64; b <= 16*1024*1024; b *= 2 {
+		bounds = append(bounds, b)
+	}
+	return bounds
+}()
+
+// SizeHistogram records payload sizes in fixed exponential buckets
+type SizeHistogram struct {
+	mu      sync.Mutex
+	buckets []int64 // one extra bucket for overflow
+	count   int64
+	max     int
+}
+
+// PayloadSizeStats summarizes a payload size histogram
+type PayloadSizeStats struct {
+	Count int64 `json:"count"`
+	P50   int   `json:"p50"`
+	P95   int   `json:"p95"`
+	Max   int   `json:"max"`
+}
+
+// NewSizeHistogram creates an empty size histogram
+func NewSizeHistogram() *SizeHistogram {
+	return &SizeHistogram{
+		buckets: make([]int64, len(sizeBucketBounds)+1),
+	}
+}
+
+// Record adds a payload size to the histogram
+func (h *SizeHistogram) Record(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buckets[bucketFor(size)]++
+	h.count++
+	if size > h.max {
+		h.max = size
+	}
+}
+
+// Snapshot returns the current p50/p95/max summary
+func (h *SizeHistogram) Snapshot() PayloadSizeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return PayloadSizeStats{
+		Count: h.count,
+		P50:   h.percentile(0.50),
+		P95:   h.percentile(0.95),
+		Max:   h.max,
+	}
+}
+
+// percentile returns the upper bound of the bucket containing quantile q,
+// capped at the largest observed size. Caller must hold h.mu.
+func (h *SizeHistogram) percentile(q float64) int {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if i < len(sizeBucketBounds) && sizeBucketBounds[i] < h.max {
+				return sizeBucketBounds[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// bucketFor returns the bucket index for a payload size
+func bucketFor(size int) int {
+	for i, bound := range sizeBucketBounds {
+		if size <= bound {
+			return i
+		}
+	}
+	return len(sizeBucketBounds)
+}
@@ -0,0 +1,96 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+func TestSizeHistogramEmpty(t *testing.T) {
+	h := NewSizeHistogram()
+
+	stats := h.Snapshot()
+	if stats.Count != 0 || stats.P50 != 0 || stats.P95 != 0 || stats.Max != 0 {
+		t.Errorf("Expected zero stats for empty histogram, got %+v", stats)
+	}
+}
+
+func TestSizeHistogramPercentiles(t *testing.T) {
+	h := NewSizeHistogram()
+
+	// 90 small payloads and 10 large ones
+	for i := 0; i < 90; i++ {
+		h.Record(100)
+	}
+	for i := 0; i < 10; i++ {
+		h.Record(5000)
+	}
+
+	stats := h.Snapshot()
+	if stats.Count != 100 {
+		t.Errorf("Expected count 100, got %d", stats.Count)
+	}
+
+	if stats.P50 != 128 {
+		t.Errorf("Expected p50 bucket 128, got %d", stats.P50)
+	}
+
+	if stats.P95 != 5000 {
+		t.Errorf("Expected p95 capped at max 5000, got %d", stats.P95)
+	}
+
+	if stats.Max != 5000 {
+		t.Errorf("Expected max 5000, got %d", stats.Max)
+	}
+}
+
+func TestSizeHistogramOverflowBucket(t *testing.T) {
+	h := NewSizeHistogram()
+	h.Record(64 * 1024 * 1024)
+
+	stats := h.Snapshot()
+	if stats.P50 != 64*1024*1024 {
+		t.Errorf("Expected overflow p50 to report max, got %d", stats.P50)
+	}
+}
+
+func TestPublishRecordsPayloadSize(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("test-topic")
+
+	client := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: client, topic: "test-topic"})
+
+	hub.publishMessage(&PubSubMessage{
+		Topic:   "test-topic",
+		Message: &MessageData{ID: "msg-1", Payload: "hello"},
+	})
+
+	topics := hub.GetTopics()
+	stats := topics["test-topic"].PayloadSize
+	if stats.Count != 1 {
+		t.Errorf("Expected 1 recorded payload, got %d", stats.Count)
+	}
+
+	// "hello" encodes as 7 bytes including quotes
+	if stats.Max != 7 {
+		t.Errorf("Expected max payload size 7, got %d", stats.Max)
+	}
+}
+
+func TestPublishRecordsValidatedPayloadSize(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("test-topic")
+
+	message, err := NewMessage("test-topic", map[string]interface{}{"n": 1}, WithID("msg-1"), WithMaxSize(1024))
+	if err != nil {
+		t.Fatalf("NewMessage failed: %v", err)
+	}
+	// `{"n":1}` is 7 bytes, measured once while validating
+	if message.size != 7 {
+		t.Fatalf("Expected the validated size of 7 kept, got %d", message.size)
+	}
+	hub.publishMessage(message)
+
+	if stats := hub.GetTopics()["test-topic"].PayloadSize; stats.Count != 1 || stats.Max != 7 {
+		t.Errorf("Expected one payload of 7 bytes recorded, got %+v", stats)
+	}
+}
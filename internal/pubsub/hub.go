@@ -1,10 +1,16 @@
 package pubsub
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"plivo/internal/config"
+	"plivo/internal/logging"
+	"plivo/internal/version"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,14 +25,41 @@ type Hub struct {
 	// Available topics
 	topics map[string]*Topic
 
+	// Messages each topic retains for replay, and the last retention
+	// budget event published
+	store          Store
+	retentionAlert retentionAlert
+
+	// Deleted topics that can still be restored, and how long they are kept
+	trash       map[string]*trashedTopic
+	trashWindow time.Duration
+
+	// How long a consumer group may go without members before it is
+	// dropped, 0 to keep groups forever
+	groupExpiry time.Duration
+
+	// Window the anomaly detectors compare topics over, 0 to detect
+	// nothing, and what they saw of each topic one window ago
+	alertInterval time.Duration
+	alertWindows  map[string]*alertWindow
+
+	// Tells the time for TTLs, expiry sweeps, timers and heartbeats
+	clock Clock
+
 	// Channel for new client registrations
 	Register chan *Client
 
 	// Channel for client unregistrations
 	unregister chan *Client
 
-	// Channel for publishing messages
-	publish chan *PubSubMessage
+	// Per-topic backlogs of publishes awaiting fan-out, sharded by topic
+	publishes *publishShards
+
+	// Workers delivering fanned-out events, nil to deliver inline
+	delivery *deliveryPool
+
+	// Per-topic fan-out executors, nil to fan out on the shard loops
+	executors *topicExecutors
 
 	// Channel for subscribing to topics
 	subscribe chan *Subscription
@@ -43,12 +76,67 @@ type Hub struct {
 
 	// Statistics
 	stats Stats
+
+	// Recovered panics across the hub, clients and HTTP handlers
+	panics atomic.Int64
+
+	// Ordering audit mode: live deliveries are checked for reordering
+	orderingAudit      bool
+	orderingViolations atomic.Int64
+	// Leased subscriptions expired for want of renewal
+	leaseExpiries atomic.Int64
+	// Events not delivered because the client was sent them already
+	duplicates atomic.Int64
+
+	// Error frames sent to clients, by code
+	errorCounts errorCounter
+
+	// Server-wide last_n limits; topics may override them
+	replayLimits ReplayLimits
+
+	// Messages topics created from now on retain for replay unless their
+	// retention policy says otherwise
+	ringBufferSize int
+
+	// Pause in effect, if any, and the mode pauses take by default
+	pause     *pauseState
+	pauseMode string
+
+	// Broker node ID stamped on events of enriched topics
+	nodeID string
+
+	// Topic backlog at which direct publishes are shed (0 = only when full)
+	rejectDepth int
+
+	// Topics each tenant may own (0 = unlimited)
+	tenantTopicLimit int
+
+	// Persistent storage for topics and retained messages, nil to keep
+	// them in memory only, records written since its last compaction, and
+	// whether it is failing
+	storage       Storage
+	storageWrites int
+	storageHealth storageHealth
+
+	// Serializes changes to topic state with their delivery and with new
+	// subscriptions; taken before mu. stateOwners indexes the topics each
+	// connection set ephemeral state on.
+	stateMu     sync.Mutex
+	stateOwners map[*Client]map[string]bool
+
+	// Client teardown tracking: running pumps, and unregistered clients
+	// whose pumps have not exited yet
+	pumps    atomic.Int64
+	departed map[*Client]time.Time
 }
 
 // Subscription represents a client subscribing to a topic
 type Subscription struct {
 	client *Client
 	topic  string
+	// done is closed once the hub loop has applied the subscription, nil
+	// if nobody waits for it
+	done chan struct{}
 }
 
 // Topic represents a pub/sub topic
@@ -57,10 +145,122 @@ type Topic struct {
 	CreatedAt       time.Time `json:"created_at"`
 	MessageCount    int64     `json:"message_count"`
 	SubscriberCount int       `json:"subscriber_count"`
-	// Ring buffer for replay (last 100 messages)
-	RecentMessages []*PubSubMessage `json:"-"`
-	RingHead       int              `json:"-"` // Head of ring buffer
-	RingSize       int              `json:"-"` // Current size of ring buffer
+	// Payload size distribution
+	PayloadSize  PayloadSizeStats `json:"payload_size"`
+	payloadSizes *SizeHistogram
+	// Sequence of the last accepted publish
+	Sequence int64 `json:"sequence"`
+	// Delivery statistics
+	DroppedCount  int64     `json:"dropped_count"`
+	LastPublishAt time.Time `json:"last_publish_at"`
+	// Registered schema versions, oldest first
+	schemas []*TopicSchema
+	// Consumer group offsets by group name
+	groups map[string]*groupCursor
+	// Replay limits overriding the hub's, nil to inherit
+	replay *ReplayLimits
+	// Fan-out scheduling weight relative to other topics
+	weight int
+	// Set while the topic is draining, nil otherwise
+	drain *drainState
+	// Key ID subscribers must present on encrypted topics, "" otherwise
+	keyID string
+	// Stamp server metadata headers on published messages
+	enrich bool
+	// Tenant that owns the topic, "" if unowned
+	owner string
+	// Retention policy, nil for the replay buffer size and no max age
+	retention *RetentionPolicy
+	// Replay buffer size: the hub's ring buffer size when the topic was
+	// created
+	ringBufferSize int
+	// Free-form labels for operators' own bookkeeping
+	labels map[string]string
+	// Key/value state delivered to new subscribers, guarded by the hub's
+	// state lock
+	state map[string]*StateEntry
+	// Revision counts settings changes, starting at 1, for optimistic
+	// concurrency on updates
+	revision int64
+	// Topic that events subscribers lost are published to, "" for none
+	deadLetter string
+	// Events published to the dead-letter topic
+	deadLettered int64
+	// Set on a client's inbox, which lives only as long as its connection
+	inbox bool
+	// Shadow topic a sample of publishes is copied to, nil for none
+	shadow *Shadow
+	// Publishes copied to the shadow topic
+	shadowed int64
+	// Keep one in every sampleEvery publishes for inspection, 0 for none
+	sampleEvery int
+	// Newest sampled publishes, oldest first, and how many were sampled
+	samples []*PubSubMessage
+	sampled int64
+	// Message IDs published recently, to drop publisher retries; nil if
+	// the topic doesn't dedup
+	dedup *idWindow
+	// Partitioning splitting the topic by message key, nil if unpartitioned
+	partitioning *Partitioning
+}
+
+// TopicStats holds statistics for a single topic
+type TopicStats struct {
+	Name            string           `json:"name"`
+	CreatedAt       time.Time        `json:"created_at"`
+	MessageCount    int64            `json:"message_count"`
+	SubscriberCount int              `json:"subscriber_count"`
+	Sequence        int64            `json:"sequence"`
+	DroppedCount    int64            `json:"dropped_count"`
+	LastPublishAt   *time.Time       `json:"last_publish_at,omitempty"`
+	BufferOccupancy int              `json:"buffer_occupancy"`
+	BufferCapacity  int              `json:"buffer_capacity"`
+	PayloadSize     PayloadSizeStats `json:"payload_size"`
+	Replay          ReplayLimits     `json:"replay"`
+	Retention       RetentionPolicy  `json:"retention"`
+	Weight          int              `json:"weight"`
+	// Publishes accepted but not yet fanned out
+	Backlog int `json:"backlog"`
+	// ExecutorQueued is how many publishes wait on the topic's executor,
+	// and ExecutorSaturations how often its queue filled up
+	ExecutorQueued      int   `json:"executor_queued,omitempty"`
+	ExecutorSaturations int64 `json:"executor_saturations,omitempty"`
+	// Draining topics take no new subscriptions; Replacement is where
+	// subscribers were pointed
+	Draining    bool   `json:"draining,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// KeyID is set on encrypted topics
+	KeyID string `json:"key_id,omitempty"`
+	// Enrich is set when published messages carry server metadata headers
+	Enrich bool `json:"enrich,omitempty"`
+	// Owner is the tenant allowed to delete and reconfigure the topic
+	Owner string `json:"owner,omitempty"`
+	// RetainedBytes approximates the memory the retained messages hold,
+	// when the store tracks it
+	RetainedBytes int64 `json:"retained_bytes,omitempty"`
+	// Labels are the topic's free-form labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// DeadLetter is where events subscribers lost go, and DeadLettered
+	// how many went there
+	DeadLetter   string `json:"dead_letter,omitempty"`
+	DeadLettered int64  `json:"dead_lettered,omitempty"`
+	// Partitioning is set on topics split into partitions by message key
+	Partitioning *Partitioning `json:"partitioning,omitempty"`
+	// Shadow is where a sample of publishes is copied, and Shadowed how
+	// many were
+	Shadow   *Shadow `json:"shadow,omitempty"`
+	Shadowed int64   `json:"shadowed,omitempty"`
+	// SampleEvery is the share of publishes sampled for inspection, one in
+	// SampleEvery, and Sampled how many were
+	SampleEvery int   `json:"sample_every,omitempty"`
+	Sampled     int64 `json:"sampled,omitempty"`
+	// DedupWindowMs is how long published message IDs are remembered, and
+	// Duplicates how many publishes repeating one were dropped
+	DedupWindowMs int64 `json:"dedup_window_ms,omitempty"`
+	Duplicates    int64 `json:"duplicates,omitempty"`
+	// Revision advances with every settings change; updates may require it
+	// to be unchanged
+	Revision int64 `json:"revision"`
 }
 
 // Stats holds system statistics
@@ -69,47 +269,342 @@ type Stats struct {
 	TotalTopics   int           `json:"total_topics"`
 	TotalMessages int64         `json:"total_messages"`
 	ActiveTopics  int           `json:"active_topics"`
+	Panics        int64         `json:"panics"`
 	Uptime        time.Duration `json:"uptime"`
-	startTime     time.Time
+	// Ordering audit results; violations are only counted in audit mode
+	OrderingAudit      bool  `json:"ordering_audit"`
+	OrderingViolations int64 `json:"ordering_violations"`
+	// Retained messages dropped for outliving their TTL or their topic's
+	// max age
+	ExpiredMessages int64 `json:"expired_messages"`
+	// Leased subscriptions expired for want of renewal
+	LeaseExpiries int64 `json:"lease_expiries"`
+	// Events not delivered because the client was sent them already, by a
+	// replay overlapping live delivery or by an earlier subscription
+	DuplicatesSkipped int64 `json:"duplicates_skipped"`
+	// Round-trip times of connected WebSocket clients, filled in by
+	// GetStats
+	RTT *RTTSummary `json:"rtt,omitempty"`
+	// Per-topic statistics, filled in by GetStats
+	Topics map[string]TopicStats `json:"topics,omitempty"`
+	// Hub channel backlogs, filled in by GetStats
+	Channels map[string]ChannelStats `json:"channels,omitempty"`
+	// Error frames sent to clients by code, filled in by GetStats
+	Errors map[ErrorCode]int64 `json:"errors,omitempty"`
+	// Memory held by retained messages, filled in by GetStats when the
+	// store tracks it
+	Retention *RetentionUsage `json:"retention,omitempty"`
+	// Delivery worker backlog and dispatch lag, filled in by GetStats when
+	// the hub has delivery workers
+	Delivery *DeliveryStats `json:"delivery,omitempty"`
+	// Topic executor load, filled in by GetStats when the hub has topic
+	// executors
+	Executors *ExecutorStats `json:"executors,omitempty"`
+	startTime time.Time
+}
+
+// ChannelStats holds the backlog of one of the hub's internal channels
+type ChannelStats struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+}
+
+// HubOptions sizes the hub's internal channels
+type HubOptions struct {
+	// RegisterBuffer is the capacity of the register and unregister channels
+	RegisterBuffer int
+	// PublishBuffer is how many publishes each topic may have waiting for
+	// fan-out before its publishers block
+	PublishBuffer int
+	// SubscribeBuffer is the capacity of the subscribe and unsubscribe channels
+	SubscribeBuffer int
+	// OrderingAudit verifies that live events reach each subscriber in
+	// topic sequence order and stamps them with a per-subscriber audit_seq
+	OrderingAudit bool
+	// Replay holds the server-wide last_n default and cap
+	Replay ReplayLimits
+	// RingBufferSize is how many recent messages each topic retains for
+	// replay unless its retention policy overrides it (0 = 100)
+	RingBufferSize int
+	// NodeID names this broker in the metadata stamped on events of
+	// enriched topics ("" = omitted)
+	NodeID string
+	// TrashWindow is how long deleted topics can be restored (0 = deleted
+	// immediately)
+	TrashWindow time.Duration
+	// GroupExpiry is how long a consumer group may have no connected
+	// members before it is dropped (0 = never)
+	GroupExpiry time.Duration
+	// Store holds the messages topics retain for replay (nil = in memory)
+	Store Store
+	// RetentionBudget caps the approximate memory the default in-memory
+	// store's retained messages hold across topics (0 = unbounded)
+	RetentionBudget int64
+	// CompressMin is the payload size in bytes from which the default
+	// in-memory store keeps retained payloads compressed (0 = never)
+	CompressMin int
+	// PublishRejectDepth is the topic publish backlog at which PublishDirect
+	// sheds publishes (0 = only when the backlog is full)
+	PublishRejectDepth int
+	// TenantTopicLimit caps the topics each tenant may own (0 = unlimited)
+	TenantTopicLimit int
+	// AlertInterval is the window anomaly detectors compare topics over,
+	// reporting abrupt changes on $SYS/alerts (0 = no detection)
+	AlertInterval time.Duration
+	// PauseMode is how a pause that doesn't name a mode treats publishes:
+	// "buffer" or "reject" ("" = buffer)
+	PauseMode string
+	// Shards is how many loops fan out publishes, each serving the topics
+	// that hash to it (0 = 1)
+	Shards int
+	// Clock tells the hub the time (nil = the wall clock); tests use a
+	// ManualClock
+	Clock Clock
+	// DeliveryWorkers is how many workers deliver fanned-out events to
+	// subscribers, each serving a fixed share of the clients (0 = the
+	// shard loops deliver inline)
+	DeliveryWorkers int
+	// TopicExecutorQueue gives each topic an executor of its own that fans
+	// out its publishes, queuing up to this many before the topic is
+	// passed over (0 = the shard loops fan out)
+	TopicExecutorQueue int
+}
+
+// DefaultHubOptions returns the default channel sizing. Publishes are
+// buffered so a busy hub shows up as backlog rather than blocked publishers;
+// registration and subscription changes stay unbuffered, and publishes are
+// fanned out by a single loop.
+func DefaultHubOptions() HubOptions {
+	return HubOptions{
+		RegisterBuffer:  0,
+		PublishBuffer:   1024,
+		SubscribeBuffer: 0,
+		Replay:          ReplayLimits{MaxLastN: replayBufferSize},
+		RingBufferSize:  replayBufferSize,
+		Shards:          1,
+	}
+}
+
+// NewHubOptions derives hub options from the pub/sub configuration
+func NewHubOptions(cfg config.PubSubConfig) HubOptions {
+	return HubOptions{
+		RegisterBuffer:  cfg.HubRegisterBuffer,
+		PublishBuffer:   cfg.HubPublishBuffer,
+		SubscribeBuffer: cfg.HubSubscribeBuffer,
+		OrderingAudit:   cfg.OrderingAudit,
+		Replay:          ReplayLimits{DefaultLastN: cfg.DefaultLastN, MaxLastN: cfg.MaxLastN},
+		RingBufferSize:  cfg.RingBufferSize,
+		TrashWindow:     cfg.TrashWindow,
+		GroupExpiry:     cfg.GroupExpiry,
+		CompressMin:     cfg.CompressRetained,
+		RetentionBudget: cfg.RetentionBudget,
+		// Direct publishes are shed where REST publishes return 503
+		PublishRejectDepth: cfg.PublishRejectDepth,
+		AlertInterval:      cfg.AlertInterval,
+		PauseMode:          cfg.PauseMode,
+		Shards:             cfg.HubShards,
+		DeliveryWorkers:    cfg.DeliveryWorkers,
+		TopicExecutorQueue: cfg.TopicExecutorQueue,
+	}
+}
+
+// Validate checks that all channel capacities and durations are
+// non-negative and that the replay limits are consistent and fit the ring
+// buffer
+func (o HubOptions) Validate() error {
+	if o.RegisterBuffer < 0 || o.PublishBuffer < 0 || o.SubscribeBuffer < 0 {
+		return fmt.Errorf("hub channel capacities must not be negative: %+v", o)
+	}
+	if o.TrashWindow < 0 {
+		return fmt.Errorf("trash window must not be negative: %v", o.TrashWindow)
+	}
+	if o.GroupExpiry < 0 {
+		return fmt.Errorf("group expiry must not be negative: %v", o.GroupExpiry)
+	}
+	if o.RetentionBudget < 0 {
+		return fmt.Errorf("retention budget must not be negative: %d", o.RetentionBudget)
+	}
+	if o.CompressMin < 0 {
+		return fmt.Errorf("retained compression threshold must not be negative: %d", o.CompressMin)
+	}
+	if o.PublishRejectDepth < 0 {
+		return fmt.Errorf("publish reject depth must not be negative: %d", o.PublishRejectDepth)
+	}
+	if o.TenantTopicLimit < 0 {
+		return fmt.Errorf("tenant topic limit must not be negative: %d", o.TenantTopicLimit)
+	}
+	if o.AlertInterval < 0 {
+		return fmt.Errorf("alert interval must not be negative: %v", o.AlertInterval)
+	}
+	if err := validatePauseMode(o.PauseMode); err != nil {
+		return err
+	}
+	if o.Shards < 0 || o.Shards > maxHubShards {
+		return fmt.Errorf("hub shards must be between 0 and %d: %d", maxHubShards, o.Shards)
+	}
+	if o.DeliveryWorkers < 0 || o.DeliveryWorkers > maxDeliveryWorkers {
+		return fmt.Errorf("delivery workers must be between 0 and %d: %d", maxDeliveryWorkers, o.DeliveryWorkers)
+	}
+	if o.TopicExecutorQueue < 0 || o.TopicExecutorQueue > maxTopicExecutorQueue {
+		return fmt.Errorf("topic executor queue must be between 0 and %d: %d", maxTopicExecutorQueue, o.TopicExecutorQueue)
+	}
+	if err := validateRingBufferSize(o.RingBufferSize); err != nil {
+		return err
+	}
+	if err := o.Replay.Validate(); err != nil {
+		return err
+	}
+	return o.Replay.fits(o.ringBufferSize())
+}
+
+// ringBufferSize returns the ring buffer size, defaulting to 100
+func (o HubOptions) ringBufferSize() int {
+	if o.RingBufferSize == 0 {
+		return replayBufferSize
+	}
+	return o.RingBufferSize
+}
+
+// validateRingBufferSize checks a ring buffer size (0 = 100)
+func validateRingBufferSize(size int) error {
+	if size < 0 || size > maxRetainedMessages {
+		return fmt.Errorf("ring buffer size must be between 0 and %d: %d", maxRetainedMessages, size)
+	}
+	return nil
 }
 
-// NewHub creates a new Hub
+// SetRingBufferSize changes how many messages topics created from now on
+// retain for replay unless their retention policy says otherwise (0 = 100).
+// Existing topics keep their size. The size must still fit the server-wide
+// last_n limits.
+func (h *Hub) SetRingBufferSize(size int) error {
+	if err := validateRingBufferSize(size); err != nil {
+		return err
+	}
+	if size == 0 {
+		size = replayBufferSize
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.replayLimits.fits(size); err != nil {
+		return err
+	}
+	h.ringBufferSize = size
+	return nil
+}
+
+// NewHub creates a new Hub with the default channel sizing
 func NewHub() *Hub {
+	return NewHubWithOptions(DefaultHubOptions())
+}
+
+// NewHubWithOptions creates a new Hub with the given channel sizing
+func NewHubWithOptions(opts HubOptions) *Hub {
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryStore(opts.ringBufferSize(), WithCompression(opts.CompressMin), WithBudget(opts.RetentionBudget))
+	}
+	pauseMode := opts.PauseMode
+	if pauseMode == "" {
+		pauseMode = PauseBuffer
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock()
+	}
 	return &Hub{
-		clients:       make(map[*Client]bool),
-		subscriptions: make(map[string]map[*Client]bool),
-		topics:        make(map[string]*Topic),
-		Register:      make(chan *Client),
-		unregister:    make(chan *Client),
-		publish:       make(chan *PubSubMessage),
-		subscribe:     make(chan *Subscription),
-		unsubscribe:   make(chan *Subscription),
-		shutdown:      make(chan struct{}),
-		shuttingDown:  false,
+		clients:          make(map[*Client]bool),
+		subscriptions:    make(map[string]map[*Client]bool),
+		topics:           make(map[string]*Topic),
+		store:            store,
+		trash:            make(map[string]*trashedTopic),
+		trashWindow:      opts.TrashWindow,
+		groupExpiry:      opts.GroupExpiry,
+		departed:         make(map[*Client]time.Time),
+		Register:         make(chan *Client, opts.RegisterBuffer),
+		unregister:       make(chan *Client, opts.RegisterBuffer),
+		publishes:        newPublishShards(opts.Shards, opts.PublishBuffer),
+		delivery:         newDeliveryPool(opts.DeliveryWorkers),
+		executors:        newTopicExecutors(opts.TopicExecutorQueue),
+		subscribe:        make(chan *Subscription, opts.SubscribeBuffer),
+		unsubscribe:      make(chan *Subscription, opts.SubscribeBuffer),
+		shutdown:         make(chan struct{}),
+		shuttingDown:     false,
+		orderingAudit:    opts.OrderingAudit,
+		replayLimits:     opts.Replay,
+		ringBufferSize:   opts.ringBufferSize(),
+		pauseMode:        pauseMode,
+		nodeID:           opts.NodeID,
+		rejectDepth:      opts.PublishRejectDepth,
+		tenantTopicLimit: opts.TenantTopicLimit,
+		alertInterval:    opts.AlertInterval,
+		clock:            clock,
 		stats: Stats{
-			startTime: time.Now(),
+			startTime: clock.Now(),
 		},
 	}
 }
 
-// Run starts the hub's main loop
+// reconcileInterval is how often subscriber counts are checked for drift
+const reconcileInterval = 30 * time.Second
+
+// Run starts the hub's main loop, which handles registrations, subscription
+// changes and housekeeping, one loop per shard fanning out publishes, and
+// the delivery workers, if any
 func (h *Hub) Run() {
+	for _, shard := range h.publishes.shards {
+		go h.runShard(shard)
+	}
+	if h.delivery != nil {
+		for _, jobs := range h.delivery.workers {
+			go h.runDeliveryWorker(jobs)
+		}
+	}
+
+	reconcileTicker := h.clock.NewTicker(reconcileInterval)
+	defer reconcileTicker.Stop()
+	storageTicker := h.clock.NewTicker(storageRetryInterval)
+	defer storageTicker.Stop()
+
+	// Without an alert interval the alert tick never fires
+	var alertTick <-chan time.Time
+	if h.alertInterval > 0 {
+		alertTicker := h.clock.NewTicker(h.alertInterval)
+		defer alertTicker.Stop()
+		alertTick = alertTicker.C()
+	}
+
 	for {
 		select {
 		case client := <-h.Register:
-			h.registerClient(client)
+			h.safely("register", func() { h.registerClient(client) })
 
 		case client := <-h.unregister:
-			h.unregisterClient(client)
-
-		case message := <-h.publish:
-			h.publishMessage(message)
+			h.safely("unregister", func() { h.unregisterClient(client) })
 
 		case subscription := <-h.subscribe:
-			h.subscribeClient(subscription)
+			h.safely("subscribe", func() { h.subscribeClient(subscription) })
+			if subscription.done != nil {
+				close(subscription.done)
+			}
 
 		case subscription := <-h.unsubscribe:
-			h.unsubscribeClient(subscription)
+			h.safely("unsubscribe", func() { h.unsubscribeClient(subscription) })
+
+		case <-reconcileTicker.C():
+			h.safely("reconcile", func() { h.reconcileSubscriberCounts() })
+			h.safely("compact", func() { h.compactStorage() })
+			h.safely("purge", func() { h.purgeExpiredTopics() })
+			h.safely("expire groups", func() { h.expireIdleGroups() })
+			h.safely("expire retained", func() { h.expireRetained() })
+
+		case <-storageTicker.C():
+			h.safely("retry storage", func() { h.retryStorage() })
+
+		case <-alertTick:
+			h.safely("detect anomalies", func() { h.detectAnomalies() })
 
 		case <-h.shutdown:
 			h.gracefulShutdown()
@@ -118,6 +613,26 @@ func (h *Hub) Run() {
 	}
 }
 
+// safely runs a hub operation, recovering from panics so that a single bad
+// operation cannot stop message routing for the whole process
+func (h *Hub) safely(op string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.RecordPanic("hub."+op, r)
+		}
+	}()
+	fn()
+}
+
+// RecordPanic logs a recovered panic with its stack trace and counts it,
+// with attrs such as the client or request it happened in. It must be
+// called from the deferred function that recovered.
+func (h *Hub) RecordPanic(source string, r interface{}, attrs ...any) {
+	h.panics.Add(1)
+	args := append([]any{"source", source, "panic", r}, attrs...)
+	slog.Error("Panic recovered", append(args, "stack", string(debug.Stack()))...)
+}
+
 // Shutdown initiates graceful shutdown
 func (h *Hub) Shutdown() {
 	h.mu.Lock()
@@ -129,7 +644,7 @@ func (h *Hub) Shutdown() {
 
 // gracefulShutdown performs graceful shutdown
 func (h *Hub) gracefulShutdown() {
-	log.Println("Starting graceful shutdown...")
+	slog.Info("Starting graceful shutdown")
 
 	// Stop accepting new operations
 	h.mu.Lock()
@@ -144,12 +659,12 @@ func (h *Hub) gracefulShutdown() {
 	for {
 		select {
 		case <-timeout:
-			log.Println("Shutdown timeout reached, forcing close")
+			slog.Warn("Shutdown timeout reached, forcing close")
 			h.forceCloseAllClients()
 			return
 		case <-ticker.C:
 			if h.allClientsFlushed() {
-				log.Println("All clients flushed, closing connections")
+				slog.Info("All clients flushed, closing connections")
 				h.forceCloseAllClients()
 				return
 			}
@@ -163,12 +678,9 @@ func (h *Hub) allClientsFlushed() bool {
 	defer h.mu.RUnlock()
 
 	for client := range h.clients {
-		client.mu.RLock()
-		if client.queueSize > 0 {
-			client.mu.RUnlock()
+		if client.queue.Len() > 0 {
 			return false
 		}
-		client.mu.RUnlock()
 	}
 	return true
 }
@@ -179,7 +691,29 @@ func (h *Hub) forceCloseAllClients() {
 	defer h.mu.RUnlock()
 
 	for client := range h.clients {
-		client.conn.Close()
+		client.disconnect()
+	}
+}
+
+// RegisterClient hands a new client to the hub and waits for the outcome.
+// It returns ErrShuttingDown if shutdown has begun, in which case the caller
+// must reject the connection instead of starting its pumps.
+func (h *Hub) RegisterClient(client *Client) error {
+	select {
+	case h.Register <- client:
+	case <-h.shutdown:
+		return ErrShuttingDown
+	}
+
+	select {
+	case admitted := <-client.registered:
+		if !admitted {
+			return ErrShuttingDown
+		}
+		return nil
+	case <-h.shutdown:
+		// A buffered registration may never be processed
+		return ErrShuttingDown
 	}
 }
 
@@ -190,22 +724,34 @@ func (h *Hub) registerClient(client *Client) {
 
 	// Reject new clients during shutdown
 	if h.shuttingDown {
-		client.conn.Close()
+		client.admit(false)
 		return
 	}
 
 	h.clients[client] = true
 	h.stats.TotalClients = len(h.clients)
+	if !client.stream {
+		h.provisionInbox(client)
+	}
+
+	client.sendWelcome()
+	client.admit(true)
+	client.logger().Debug("Client connected")
 }
 
 // unregisterClient removes a client from the hub
 func (h *Hub) unregisterClient(client *Client) {
+	defer h.releaseState(client)
+	var notices []rebalanceNotice
+	defer func() { sendRebalances(notices) }()
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if _, ok := h.clients[client]; ok {
 		delete(h.clients, client)
-		close(client.send)
+		client.queue.Close()
+		client.stopLeases()
+		h.trackDeparture(client)
 
 		// Remove client from all topic subscriptions
 		for topic, clients := range h.subscriptions {
@@ -214,154 +760,475 @@ func (h *Hub) unregisterClient(client *Client) {
 				if len(clients) == 0 {
 					delete(h.subscriptions, topic)
 				}
-				// Update subscriber count
-				if topicInfo, exists := h.topics[topic]; exists {
-					topicInfo.SubscriberCount = len(clients)
-				}
+				h.updateSubscriberCount(topic)
+				notices = append(notices, h.rebalance(topic)...)
 			}
 		}
+		h.removeInbox(client)
 
 		h.stats.TotalClients = len(h.clients)
+		client.logger().Debug("Client disconnected")
 	}
 }
 
 // publishMessage publishes a message to all subscribers of a topic
 func (h *Hub) publishMessage(message *PubSubMessage) {
-	h.mu.RLock()
-	subscribers, exists := h.subscriptions[message.Topic]
-	if !exists {
-		h.mu.RUnlock()
-		return
+	clientList := h.recordMessage(message)
+	h.checkRetention()
+
+	h.deliver(message, clientList)
+	h.shadow(message)
+}
+
+// TryPublish queues a message for the hub, waiting at most wait for room in
+// its topic's backlog. It returns how many of the topic's publishes were
+// queued ahead of the message, or ErrHubSaturated if the backlog stayed full.
+// Publishes the topic doesn't accept fail without being queued.
+func (h *Hub) TryPublish(message *PubSubMessage, wait time.Duration) (int, error) {
+	return h.tryPublish(context.Background(), message, wait)
+}
+
+// tryPublish is TryPublish for callers with a context: one done while
+// waiting abandons the publish with the context's error
+func (h *Hub) tryPublish(ctx context.Context, message *PubSubMessage, wait time.Duration) (int, error) {
+	weight, err := h.admitPublish(message)
+	if err != nil {
+		return 0, err
 	}
+	return h.waitToPublish(ctx, []*PubSubMessage{message}, weight, wait)
+}
+
+// waitToPublish queues admitted messages together, waiting at most wait for
+// room in their topic's backlog unless ctx is done first
+func (h *Hub) waitToPublish(ctx context.Context, messages []*PubSubMessage, weight int, wait time.Duration) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	// A done context cuts the wait short
+	stop := context.AfterFunc(ctx, func() { timer.Reset(0) })
+	defer stop()
+
+	message := messages[0]
+	ahead, err := h.publishes.pushAll(message.Topic, messages, weight, h.shutdown, timer.C)
+	if err == ErrHubSaturated && ctx.Err() != nil {
+		return ahead, ctx.Err()
+	}
+	if err == ErrHubSaturated {
+		h.ReportQuota(QuotaEvent{
+			Quota:    QuotaPublishBacklog,
+			Identity: message.publisher,
+			Topic:    message.Topic,
+			Limit:    int64(h.publishes.capacity),
+		})
+	}
+	return ahead, err
+}
+
+// PublishBacklog returns the depth and capacity of a topic's publish backlog
+func (h *Hub) PublishBacklog(topic string) (depth, capacity int) {
+	return h.publishes.topicPending(topic), h.publishes.capacity
+}
+
+// recordMessage updates counters and the ring buffer for a published message
+// and returns the subscribers to deliver it to, copied so that the lock is
+// not held while sending
+func (h *Hub) recordMessage(message *PubSubMessage) []*Client {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Subscribers and replays share the message's encoded frames, which are
+	// encoded once it is fully stamped below
+	message.frames = &eventFrames{}
 
-	// Update message count and store recent message in ring buffer
+	// Every accepted publish advances the topic sequence and is retained and
+	// persisted, even when nobody is subscribed: consumer groups and history
+	// readers catch up on it later. Only fan-out depends on subscribers.
 	if topic, exists := h.topics[message.Topic]; exists {
+		topic.Sequence++
+		message.Sequence = topic.Sequence
+		message.keyID = topic.keyID
+		if topic.partitioning != nil {
+			partition := topic.partitioning.partitionOf(message)
+			message.Partition = &partition
+		}
+		if topic.enrich {
+			h.enrich(message)
+		}
+		topic.sample(message)
+
+		// Update message count and store recent message in ring buffer
 		topic.MessageCount++
-		// Store in ring buffer
-		topic.RecentMessages[topic.RingHead] = message
-		topic.RingHead = (topic.RingHead + 1) % 100
-		if topic.RingSize < 100 {
-			topic.RingSize++
+		topic.LastPublishAt = message.Timestamp
+		topic.payloadSizes.Record(message.payloadBytes())
+		logStoreError("append", h.store.AppendMessage(message))
+		if !topic.inbox {
+			h.persist("append", func(s Storage) error { return s.AppendMessage(message) })
 		}
 	}
 	h.stats.TotalMessages++
 
-	// Create a copy of subscribers to avoid holding the lock while sending
-	clientList := make([]*Client, 0, len(subscribers))
-	for client := range subscribers {
-		clientList = append(clientList, client)
+	subscribers, exists := h.subscriptions[message.Topic]
+	if !exists {
+		return nil
 	}
-	h.mu.RUnlock()
 
-	// Send message to all subscribers
-	for _, client := range clientList {
-		select {
-		case client.send <- h.createEventMessageBytes(message):
-		default:
-			// Client's send buffer is full, skip
-		}
+	return h.deliveryTargets(message, subscribers)
+}
+
+// recordOrderingViolation reports a live event delivered out of topic
+// sequence order. Violations are logged loudly so soak runs fail visibly.
+func (h *Hub) recordOrderingViolation(topic, clientID string, previous, sequence int64) {
+	h.orderingViolations.Add(1)
+	slog.Error("ORDERING VIOLATION", logging.Topic, topic, logging.ClientID, clientID, "sequence", sequence, "previous", previous)
+}
+
+// recordDrops counts events dropped from a subscriber's queue, either to
+// make room or for missing the subscription's max_latency
+func (h *Hub) recordDrops(topicName string, count int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if topic, exists := h.topics[topicName]; exists {
+		topic.DroppedCount += int64(count)
 	}
 }
 
 // subscribeClient subscribes a client to a topic
 func (h *Hub) subscribeClient(subscription *Subscription) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	// The topic's state goes ahead of the events the subscription receives
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.sendStateSnapshot(subscription.client, subscription.topic)
 
+	h.mu.Lock()
 	if h.subscriptions[subscription.topic] == nil {
 		h.subscriptions[subscription.topic] = make(map[*Client]bool)
 	}
 	h.subscriptions[subscription.topic][subscription.client] = true
+	h.updateSubscriberCount(subscription.topic)
+	notices := h.rebalance(subscription.topic)
+	h.mu.Unlock()
+
+	subscription.client.logger().Debug("Subscribed", logging.Topic, subscription.topic)
+	sendRebalances(notices)
+}
+
+// updateSubscriberCount syncs a topic's subscriber count with the
+// subscription map, which is the source of truth. Caller must hold the lock.
+func (h *Hub) updateSubscriberCount(topicName string) {
+	if topic, exists := h.topics[topicName]; exists {
+		topic.SubscriberCount = len(h.subscriptions[topicName])
+	}
+}
 
-	// Update subscriber count
-	if topic, exists := h.topics[subscription.topic]; exists {
-		topic.SubscriberCount = len(h.subscriptions[subscription.topic])
+// reconcileSubscriberCounts repairs any subscriber count that has drifted
+// from the subscription map and returns the number of topics repaired
+func (h *Hub) reconcileSubscriberCounts() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	repaired := 0
+	for name, topic := range h.topics {
+		if actual := len(h.subscriptions[name]); topic.SubscriberCount != actual {
+			slog.Warn("Repaired subscriber count", logging.Topic, name, "from", topic.SubscriberCount, "to", actual)
+			topic.SubscriberCount = actual
+			repaired++
+		}
 	}
+	return repaired
 }
 
-// GetRecentMessages returns recent messages for a topic from ring buffer
+// GetRecentMessages returns a topic's newest retained messages
 func (h *Hub) GetRecentMessages(topicName string, lastN int) []*PubSubMessage {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	if topic, exists := h.topics[topicName]; exists {
-		if lastN <= 0 || lastN > topic.RingSize {
-			lastN = topic.RingSize
-		}
+	if _, exists := h.topics[topicName]; exists {
+		return h.retained(topicName, lastN)
+	}
+	return []*PubSubMessage{}
+}
 
-		if lastN > 0 {
-			messages := make([]*PubSubMessage, 0, lastN)
+// GetMessagesAfter returns a topic's retained messages with a sequence
+// greater than sequence, oldest first
+func (h *Hub) GetMessagesAfter(topicName string, sequence int64) []*PubSubMessage {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if _, exists := h.topics[topicName]; exists {
+		return h.messagesAfter(topicName, sequence)
+	}
+	return []*PubSubMessage{}
+}
 
-			// Calculate start position in ring buffer
-			start := (topic.RingHead - lastN + 100) % 100
+// prepareReplay snapshots a topic's delivery state together with the backlog
+// to replay to a new subscriber, so the reported counts match the backlog
+// that is delivered. The backlog is the last lastN messages; without lastN, a
+// consumer group member resumes after the group's offset.
+func (h *Hub) prepareReplay(topicName string, lastN int, group string) (*SubscriptionInfo, []*PubSubMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-			for i := 0; i < lastN; i++ {
-				pos := (start + i) % 100
-				if topic.RecentMessages[pos] != nil {
-					messages = append(messages, topic.RecentMessages[pos])
-				}
-			}
+	info := &SubscriptionInfo{}
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return info, nil
+	}
 
-			return messages
+	retained := h.retained(topicName, 0)
+	info.Sequence = topic.Sequence
+	info.Retained = len(retained)
+
+	var backlog []*PubSubMessage
+	if group != "" {
+		now := h.clock.Now()
+		cursor := topic.groupCursor(group, now)
+		cursor.activeAt = now
+		info.Offset = cursor.offset
+		if lastN <= 0 {
+			backlog = h.messagesAfter(topicName, cursor.offset)
 		}
 	}
-	return []*PubSubMessage{}
+	// An explicit last_n overrides group replay; without a group an omitted
+	// last_n falls back to the topic's default
+	if lastN > 0 || (lastN == 0 && group == "") {
+		n, capped := topic.replayLimits(h.replayLimits).resolve(lastN, topic.capacity())
+		info.Capped = capped
+		if n > 0 {
+			backlog = retained[max(len(retained)-n, 0):]
+		}
+	}
+	info.Replaying = len(backlog)
+
+	return info, backlog
 }
 
 // unsubscribeClient unsubscribes a client from a topic
 func (h *Hub) unsubscribeClient(subscription *Subscription) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
+	var notices []rebalanceNotice
 	if clients, exists := h.subscriptions[subscription.topic]; exists {
 		delete(clients, subscription.client)
 		if len(clients) == 0 {
 			delete(h.subscriptions, subscription.topic)
 		}
-
-		// Update subscriber count
-		if topic, exists := h.topics[subscription.topic]; exists {
-			topic.SubscriberCount = len(clients)
-		}
+		h.updateSubscriberCount(subscription.topic)
+		notices = h.rebalance(subscription.topic)
 	}
+	h.mu.Unlock()
+
+	subscription.client.logger().Debug("Unsubscribed", logging.Topic, subscription.topic)
+	sendRebalances(notices)
+}
+
+// TopicOptions holds per-topic settings applied at creation
+type TopicOptions struct {
+	// Replay overrides the server-wide last_n limits when set
+	Replay *ReplayLimits `json:"replay,omitempty"`
+	// Weight is how many of the topic's publishes are fanned out per
+	// scheduling round, relative to other busy topics (0 = 1)
+	Weight int `json:"weight,omitempty"`
+	// KeyID marks the topic encrypted: publishes must be opaque ciphertext
+	// and subscribers must present this key ID
+	KeyID string `json:"key_id,omitempty"`
+	// Enrich stamps every published message with server metadata headers:
+	// the accepting node, receive time and publisher identity
+	Enrich bool `json:"enrich,omitempty"`
+	// Owner is the tenant allowed to delete and reconfigure the topic
+	// ("" = unowned, any caller may)
+	Owner string `json:"owner,omitempty"`
+	// Retention bounds what the topic retains for replay, by count and age
+	// (nil = the replay buffer size, kept until overwritten)
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Labels are free-form key/value pairs for operators' bookkeeping
+	Labels map[string]string `json:"labels,omitempty"`
+	// DeadLetter names the topic events are published to when a subscriber
+	// loses them, created if it doesn't exist ("" = events are dropped)
+	DeadLetter string `json:"dead_letter,omitempty"`
+	// Partitioning splits the topic into partitions by message key, shared
+	// among consumer group members (nil = unpartitioned)
+	Partitioning *Partitioning `json:"partitioning,omitempty"`
+	// Shadow copies a sample of publishes into another topic, created if it
+	// doesn't exist (nil = none)
+	Shadow *Shadow `json:"shadow,omitempty"`
+	// SampleEvery keeps one in every SampleEvery publishes for inspection
+	// (0 = none)
+	SampleEvery int `json:"sample_every,omitempty"`
+	// DedupWindowMs drops publishes whose message ID repeats one published
+	// to the topic within that many milliseconds (0 = no dedup)
+	DedupWindowMs int64 `json:"dedup_window_ms,omitempty"`
+	// Schema registers a JSON Schema document as the topic's schema version
+	// 1, which published payloads must validate against (nil = none)
+	Schema json.RawMessage `json:"schema,omitempty" swaggertype:"object"`
 }
 
 // CreateTopic creates a new topic
 func (h *Hub) CreateTopic(name string) error {
+	return h.CreateTopicWithOptions(name, TopicOptions{})
+}
+
+// CreateTopicWithOptions creates a new topic with per-topic settings
+func (h *Hub) CreateTopicWithOptions(name string, opts TopicOptions) error {
+	if IsSystemTopic(name) || IsInboxTopic(name) {
+		return ErrReservedTopic
+	}
+
+	if opts.Replay != nil {
+		if err := opts.Replay.Validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Retention != nil {
+		if err := opts.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateWeight(opts.Weight); err != nil {
+		return err
+	}
+	if err := validateKeyID(opts.KeyID); err != nil {
+		return err
+	}
+	if opts.Owner != "" {
+		if err := validateOwner(opts.Owner); err != nil {
+			return err
+		}
+	}
+	if err := validateLabels(opts.Labels); err != nil {
+		return err
+	}
+	if err := validateDeadLetter(name, opts.DeadLetter); err != nil {
+		return err
+	}
+	if opts.Partitioning != nil {
+		if err := opts.Partitioning.Validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Shadow != nil {
+		if err := opts.Shadow.validate(name); err != nil {
+			return err
+		}
+	}
+	if err := validateSampleEvery(opts.SampleEvery); err != nil {
+		return err
+	}
+	if err := validateDedupWindow(opts.DedupWindowMs); err != nil {
+		return err
+	}
+	var schemas []*TopicSchema
+	if len(opts.Schema) > 0 {
+		schema, err := newTopicSchema(name, 1, opts.Schema, h.clock.Now())
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, schema)
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if _, exists := h.topics[name]; exists {
 		return ErrTopicExists
 	}
+	if h.trashed(name) != nil {
+		return ErrTopicDeleted
+	}
+	if opts.Replay != nil {
+		if err := opts.Replay.fits(opts.Retention.capacity(h.ringBufferSize)); err != nil {
+			return err
+		}
+	}
+	if err := h.checkTenantQuota(opts.Owner, name); err != nil {
+		return err
+	}
+	delete(h.trash, name)
 
 	h.topics[name] = &Topic{
 		Name:            name,
-		CreatedAt:       time.Now(),
+		CreatedAt:       h.clock.Now(),
 		MessageCount:    0,
 		SubscriberCount: 0,
-		RecentMessages:  make([]*PubSubMessage, 100), // Ring buffer of 100 messages
-		RingHead:        0,
-		RingSize:        0,
+		payloadSizes:    NewSizeHistogram(),
+		replay:          opts.Replay,
+		weight:          opts.Weight,
+		keyID:           opts.KeyID,
+		enrich:          opts.Enrich,
+		owner:           opts.Owner,
+		retention:       opts.Retention,
+		ringBufferSize:  h.ringBufferSize,
+		labels:          copyLabels(opts.Labels),
+		revision:        1,
+		deadLetter:      opts.DeadLetter,
+		partitioning:    opts.Partitioning,
+		shadow:          copyShadow(opts.Shadow),
+		sampleEvery:     opts.SampleEvery,
+		schemas:         schemas,
 	}
+	h.topics[name].setDedupWindow(opts.DedupWindowMs)
+	logStoreError("create topic", h.store.CreateTopic(name))
+	h.applyRetention(h.topics[name])
+	h.persist("create topic", func(s Storage) error { return s.SaveTopic(h.topics[name].snapshot()) })
+	h.ensureDeadLetterTopic(h.topics[name])
+	h.ensureShadowTopic(h.topics[name])
+
+	// Clients may already be subscribed to a topic before it is created
+	h.updateSubscriberCount(name)
 
 	h.stats.TotalTopics = len(h.topics)
 	return nil
 }
 
-// DeleteTopic removes a topic
+// DeleteTopic removes a topic and detaches its subscribers. With a trash
+// window, the topic is kept until the window passes so RestoreTopic can
+// bring it back; meanwhile publishes and subscribes to it are rejected with
+// ErrTopicDeleted.
 func (h *Hub) DeleteTopic(name string) error {
+	if IsInboxTopic(name) {
+		return ErrReservedTopic
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if _, exists := h.topics[name]; !exists {
+	topic, exists := h.topics[name]
+	if !exists {
 		return ErrTopicNotFound
 	}
+	h.removeTopic(name)
+
+	// Trashed topics keep their retained messages until purged
+	if h.trashWindow > 0 {
+		now := h.clock.Now()
+		h.trash[name] = &trashedTopic{topic: topic, deletedAt: now, purgeAt: now.Add(h.trashWindow)}
+	} else {
+		logStoreError("delete topic", h.store.DeleteTopic(name))
+	}
+	return nil
+}
+
+// removeTopic detaches a topic's subscribers and removes it. Caller must
+// hold the hub write lock.
+func (h *Hub) removeTopic(name string) {
+	// Detach subscribers so they don't silently resurrect on re-creation
+	for client := range h.subscriptions[name] {
+		client.mu.Lock()
+		delete(client.subscriptions, name)
+		delete(client.options, name)
+		client.endReplayDedup(name)
+		client.mu.Unlock()
+	}
 
 	delete(h.topics, name)
 	delete(h.subscriptions, name)
+	if h.executors != nil {
+		h.executors.forget(name)
+	}
 	h.stats.TotalTopics = len(h.topics)
-	return nil
+	h.persist("delete topic", func(s Storage) error { return s.DeleteTopic(name) })
 }
 
 // GetTopics returns all topics
@@ -376,6 +1243,10 @@ func (h *Hub) GetTopics() map[string]*Topic {
 			CreatedAt:       topic.CreatedAt,
 			MessageCount:    topic.MessageCount,
 			SubscriberCount: topic.SubscriberCount,
+			Sequence:        topic.Sequence,
+			PayloadSize:     topic.payloadSizes.Snapshot(),
+			DroppedCount:    topic.DroppedCount,
+			LastPublishAt:   topic.LastPublishAt,
 		}
 	}
 	return topics
@@ -387,24 +1258,199 @@ func (h *Hub) GetStats() Stats {
 	defer h.mu.RUnlock()
 
 	stats := h.stats
-	stats.Uptime = time.Since(h.stats.startTime)
+	stats.Panics = h.panics.Load()
+	stats.OrderingAudit = h.orderingAudit
+	stats.OrderingViolations = h.orderingViolations.Load()
+	stats.LeaseExpiries = h.leaseExpiries.Load()
+	stats.DuplicatesSkipped = h.duplicates.Load()
+	stats.Uptime = h.clock.Now().Sub(h.stats.startTime)
 	stats.ActiveTopics = len(h.subscriptions)
+	stats.Topics = make(map[string]TopicStats, len(h.topics))
+	for name, topic := range h.topics {
+		stats.Topics[name] = h.topicStats(topic)
+	}
+	stats.Channels = h.channelStats()
+	stats.Errors = h.errorCounts.snapshot()
+	rtt := h.rttSummary()
+	stats.RTT = &rtt
+	if reporter, ok := h.store.(UsageReporter); ok {
+		usage := reporter.Usage()
+		stats.Retention = &usage
+	}
+	if h.delivery != nil {
+		delivery := h.delivery.stats()
+		stats.Delivery = &delivery
+	}
+	if h.executors != nil {
+		executors := h.executors.stats()
+		stats.Executors = &executors
+	}
+	return stats
+}
+
+// channelStats reports the current backlog of each hub channel. Publishes
+// are queued per topic, so their capacity is per topic, and their depth
+// spans all shards. Delivery jobs are likewise queued per worker.
+func (h *Hub) channelStats() map[string]ChannelStats {
+	stats := map[string]ChannelStats{
+		"register":    {Depth: len(h.Register), Capacity: cap(h.Register)},
+		"unregister":  {Depth: len(h.unregister), Capacity: cap(h.unregister)},
+		"publish":     {Depth: h.publishes.pending(), Capacity: h.publishes.capacity},
+		"subscribe":   {Depth: len(h.subscribe), Capacity: cap(h.subscribe)},
+		"unsubscribe": {Depth: len(h.unsubscribe), Capacity: cap(h.unsubscribe)},
+	}
+	if h.delivery != nil {
+		stats["delivery"] = ChannelStats{Depth: h.delivery.queued(), Capacity: deliveryQueueSize}
+	}
 	return stats
 }
 
-// createEventMessageBytes converts a PubSubMessage to event JSON bytes
-func (h *Hub) createEventMessageBytes(message *PubSubMessage) []byte {
+// TopicExists reports whether a topic exists
+func (h *Hub) TopicExists(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, exists := h.topics[name]
+	return exists
+}
+
+// GetTopicStats returns statistics for a single topic
+func (h *Hub) GetTopicStats(name string) (TopicStats, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[name]
+	if !exists {
+		return TopicStats{}, ErrTopicNotFound
+	}
+	return h.topicStats(topic), nil
+}
+
+// topicStats snapshots a topic's statistics with its publish backlog.
+// Caller must hold the hub lock.
+func (h *Hub) topicStats(topic *Topic) TopicStats {
+	stats := topic.stats(h.replayLimits)
+	stats.BufferOccupancy = len(h.retained(topic.Name, 0))
+	stats.Backlog = h.publishes.topicPending(topic.Name)
+	if h.executors != nil {
+		stats.ExecutorQueued, stats.ExecutorSaturations = h.executors.topicStats(topic.Name)
+	}
+	if reporter, ok := h.store.(UsageReporter); ok {
+		stats.RetainedBytes = reporter.TopicBytes(topic.Name)
+	}
+	return stats
+}
+
+// stats snapshots the topic's statistics on a hub with the given replay
+// limits. Caller must hold the hub lock.
+func (t *Topic) stats(replay ReplayLimits) TopicStats {
+	stats := TopicStats{
+		Name:            t.Name,
+		CreatedAt:       t.CreatedAt,
+		MessageCount:    t.MessageCount,
+		SubscriberCount: t.SubscriberCount,
+		Sequence:        t.Sequence,
+		DroppedCount:    t.DroppedCount,
+		BufferCapacity:  t.capacity(),
+		PayloadSize:     t.payloadSizes.Snapshot(),
+		Replay:          t.replayLimits(replay),
+		Retention:       t.retentionPolicy(),
+		Labels:          copyLabels(t.labels),
+		Revision:        t.revision,
+		DeadLetter:      t.deadLetter,
+		DeadLettered:    t.deadLettered,
+		Partitioning:    t.partitioning,
+		Shadow:          copyShadow(t.shadow),
+		Shadowed:        t.shadowed,
+		SampleEvery:     t.sampleEvery,
+		Sampled:         t.sampled,
+		Weight:          t.schedulingWeight(),
+		KeyID:           t.keyID,
+		Enrich:          t.enrich,
+		Owner:           t.owner,
+	}
+	if t.drain != nil {
+		stats.Draining = true
+		stats.Replacement = t.drain.replacement
+	}
+	if t.dedup != nil {
+		window, duplicates := t.dedup.stats()
+		stats.DedupWindowMs = window.Milliseconds()
+		stats.Duplicates = duplicates
+	}
+	if !t.LastPublishAt.IsZero() {
+		lastPublishAt := t.LastPublishAt
+		stats.LastPublishAt = &lastPublishAt
+	}
+	return stats
+}
+
+// payloadSize returns the encoded size of a message payload in bytes
+func payloadSize(data *MessageData) int {
+	if data == nil || data.Payload == nil {
+		return 0
+	}
+	encoded, err := json.Marshal(data.Payload)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
+
+// ValidateMessageSize checks a message payload against a size limit in bytes.
+// A limit of zero or less disables the check.
+func ValidateMessageSize(data *MessageData, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	return checkMessageSize(payloadSize(data), limit)
+}
+
+// checkMessageSize checks an encoded payload's size against a size limit in
+// bytes. A limit of zero or less disables the check.
+func checkMessageSize(size int, limit int64) error {
+	if limit > 0 && int64(size) > limit {
+		return &MessageTooLargeError{Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// createEventMessageBytes converts a PubSubMessage to event JSON bytes;
+// auditSeq is stamped in ordering audit mode and 0 otherwise
+func (h *Hub) createEventMessageBytes(message *PubSubMessage, auditSeq int64) []byte {
 	msg := ServerMessage{
-		Type:    EventMessage,
-		Topic:   message.Topic,
-		Message: message.Message,
-		TS:      message.Timestamp.Format(time.RFC3339),
+		Type:          EventMessage,
+		Topic:         message.Topic,
+		Message:       message.Message,
+		ReceivedAt:    message.Timestamp.Format(time.RFC3339Nano),
+		Sequence:      message.Sequence,
+		Partition:     message.Partition,
+		AuditSeq:      auditSeq,
+		SchemaVersion: message.SchemaVersion,
+		TS:            message.Timestamp.Format(time.RFC3339),
 	}
 
 	data, _ := json.Marshal(msg)
 	return data
 }
 
+// createEchoMessageBytes creates an event for the diagnostic echo topic
+func (h *Hub) createEchoMessageBytes(requestID string, data *MessageData, receivedAt time.Time) []byte {
+	now := h.clock.Now()
+	msg := ServerMessage{
+		Type:        EventMessage,
+		RequestID:   requestID,
+		Topic:       EchoTopic,
+		Message:     data,
+		TS:          now.Format(time.RFC3339),
+		ReceivedAt:  receivedAt.Format(time.RFC3339Nano),
+		DeliveredAt: now.Format(time.RFC3339Nano),
+	}
+
+	encoded, _ := json.Marshal(msg)
+	return encoded
+}
+
 // createAckMessageBytes creates an acknowledgment message
 func (h *Hub) createAckMessageBytes(requestID, topic, status string) []byte {
 	msg := ServerMessage{
@@ -412,23 +1458,83 @@ func (h *Hub) createAckMessageBytes(requestID, topic, status string) []byte {
 		RequestID: requestID,
 		Topic:     topic,
 		Status:    status,
-		TS:        time.Now().Format(time.RFC3339),
+		TS:        h.clock.Now().Format(time.RFC3339),
 	}
 
 	data, _ := json.Marshal(msg)
 	return data
 }
 
-// createErrorMessageBytes creates an error message
-func (h *Hub) createErrorMessageBytes(requestID string, errorCode, errorMsg string) []byte {
+// createPublishAckMessageBytes creates a publish acknowledgment carrying the
+// ID the message was published under, with status "ok" or PublishDuplicate
+func (h *Hub) createPublishAckMessageBytes(requestID, topic, messageID, status string) []byte {
+	msg := ServerMessage{
+		Type:      AckMessage,
+		RequestID: requestID,
+		Topic:     topic,
+		Status:    status,
+		MessageID: messageID,
+		TS:        h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// createBatchAckMessageBytes creates a publish_batch acknowledgment
+// carrying each message's outcome
+func (h *Hub) createBatchAckMessageBytes(requestID, topic string, results []BatchResult) []byte {
+	msg := ServerMessage{
+		Type:      AckMessage,
+		RequestID: requestID,
+		Topic:     topic,
+		Status:    "ok",
+		Results:   results,
+		TS:        h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// createSubscribeAckMessageBytes creates a subscribe acknowledgment carrying
+// the topic's delivery state
+func (h *Hub) createSubscribeAckMessageBytes(requestID, topic string, info *SubscriptionInfo) []byte {
+	msg := ServerMessage{
+		Type:         AckMessage,
+		RequestID:    requestID,
+		Topic:        topic,
+		Status:       "ok",
+		Subscription: info,
+		TS:           h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// createErrorDataMessageBytes creates an error message from a full error body
+func (h *Hub) createErrorDataMessageBytes(requestID string, errorData *ErrorData) []byte {
 	msg := ServerMessage{
 		Type:      ErrorMessage,
 		RequestID: requestID,
-		Error: &ErrorData{
-			Code:    errorCode,
-			Message: errorMsg,
-		},
-		TS: time.Now().Format(time.RFC3339),
+		Error:     errorData,
+		TS:        h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// createWelcomeMessageBytes creates the info frame sent to newly connected clients
+func (h *Hub) createWelcomeMessageBytes(clientID, inbox string) []byte {
+	info := version.Get()
+	msg := ServerMessage{
+		Type:   InfoMessage,
+		Msg:    "welcome " + clientID,
+		Server: &info,
+		Inbox:  inbox,
+		TS:     h.clock.Now().Format(time.RFC3339),
 	}
 
 	data, _ := json.Marshal(msg)
@@ -436,11 +1542,13 @@ func (h *Hub) createErrorMessageBytes(requestID string, errorCode, errorMsg stri
 }
 
 // createPongMessageBytes creates a pong message
-func (h *Hub) createPongMessageBytes(requestID string) []byte {
+func (h *Hub) createPongMessageBytes(requestID string, echo json.RawMessage, lease *LeaseInfo) []byte {
 	msg := ServerMessage{
 		Type:      PongMessage,
 		RequestID: requestID,
-		TS:        time.Now().Format(time.RFC3339),
+		Data:      echo,
+		Lease:     lease,
+		TS:        h.clock.Now().Format(time.RFC3339),
 	}
 
 	data, _ := json.Marshal(msg)
@@ -449,6 +1557,53 @@ func (h *Hub) createPongMessageBytes(requestID string) []byte {
 
 // Error definitions
 var (
-	ErrTopicExists   = fmt.Errorf("topic already exists")
-	ErrTopicNotFound = fmt.Errorf("topic not found")
+	ErrTopicExists         = fmt.Errorf("topic already exists")
+	ErrTopicNotFound       = fmt.Errorf("topic not found")
+	ErrClientNotFound      = fmt.Errorf("client not found")
+	ErrReservedTopic       = fmt.Errorf("topic name is reserved")
+	ErrInvalidSchema       = fmt.Errorf("invalid schema")
+	ErrSchemaNotFound      = fmt.Errorf("schema not found")
+	ErrSchemaViolation     = fmt.Errorf("payload does not match the topic schema")
+	ErrGroupNotFound       = fmt.Errorf("consumer group not found")
+	ErrGroupActive         = fmt.Errorf("consumer group has active members")
+	ErrInvalidOffset       = fmt.Errorf("offset out of range")
+	ErrHubSaturated        = fmt.Errorf("hub publish backlog is full")
+	ErrHubPaused           = fmt.Errorf("hub is paused for maintenance")
+	ErrInvalidPause        = fmt.Errorf("invalid pause")
+	ErrShuttingDown        = fmt.Errorf("server is shutting down")
+	ErrInvalidReplay       = fmt.Errorf("invalid replay limits")
+	ErrInvalidMessage      = fmt.Errorf("invalid message")
+	ErrInvalidWeight       = fmt.Errorf("invalid topic weight")
+	ErrInvalidDrain        = fmt.Errorf("invalid drain")
+	ErrInvalidKeyID        = fmt.Errorf("invalid key ID")
+	ErrKeyIDMismatch       = fmt.Errorf("topic is encrypted with a different key")
+	ErrInvalidOwner        = fmt.Errorf("invalid topic owner")
+	ErrTopicDraining       = fmt.Errorf("topic is draining")
+	ErrTopicDeleted        = fmt.Errorf("topic is deleted")
+	ErrInvalidRange        = fmt.Errorf("invalid replay range")
+	ErrInvalidRetention    = fmt.Errorf("invalid retention policy")
+	ErrInvalidLabels       = fmt.Errorf("invalid topic labels")
+	ErrRevisionMismatch    = fmt.Errorf("topic revision mismatch")
+	ErrInvalidDeadLetter   = fmt.Errorf("invalid dead-letter topic")
+	ErrInvalidShadow       = fmt.Errorf("invalid shadow topic")
+	ErrInvalidSampling     = fmt.Errorf("invalid sampling rate")
+	ErrInvalidDedupWindow  = fmt.Errorf("invalid dedup window")
+	ErrInvalidPartitioning = fmt.Errorf("invalid topic partitioning")
+	ErrNotPermitted        = fmt.Errorf("not permitted by the ACL")
+	ErrInboxPrivate        = fmt.Errorf("inboxes may only be subscribed to by their owner")
+	ErrTenantQuota         = fmt.Errorf("tenant topic quota exceeded")
+	ErrInvalidState        = fmt.Errorf("invalid topic state")
+	ErrStateFull           = fmt.Errorf("topic state is full")
+	ErrStateNotFound       = fmt.Errorf("state key not found")
+	ErrInvalidWhere        = fmt.Errorf("invalid where expression")
 )
+
+// MessageTooLargeError reports a payload exceeding the configured size limit
+type MessageTooLargeError struct {
+	Size  int
+	Limit int64
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("message payload of %d bytes exceeds limit of %d bytes", e.Size, e.Limit)
+}
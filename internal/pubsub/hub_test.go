@@ -32,8 +32,8 @@ func TestNewHub(t *testing.T) {
 		t.Error("unregister channel is nil")
 	}
 
-	if hub.publish == nil {
-		t.Error("publish channel is nil")
+	if hub.publishes == nil {
+		t.Error("publish scheduler is nil")
 	}
 
 	if hub.subscribe == nil {
@@ -178,3 +178,247 @@ func TestMessageCountTracking(t *testing.T) {
 		t.Errorf("Expected 3 topic messages, got %d", topic.MessageCount)
 	}
 }
+
+func TestPublishAssignsSequence(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("test-topic")
+	hub.CreateTopic("other-topic")
+
+	// Publishes advance the sequence even without subscribers
+	first := &PubSubMessage{Topic: "test-topic", Message: &MessageData{ID: "msg-1"}, Timestamp: time.Now()}
+	hub.publishMessage(first)
+
+	client := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: client, topic: "test-topic"})
+
+	receivedAt := time.Date(2025, 1, 15, 10, 0, 0, 123456789, time.UTC)
+	second := &PubSubMessage{Topic: "test-topic", Message: &MessageData{ID: "msg-2"}, Timestamp: receivedAt}
+	hub.publishMessage(second)
+
+	other := &PubSubMessage{Topic: "other-topic", Message: &MessageData{ID: "msg-3"}, Timestamp: time.Now()}
+	hub.publishMessage(other)
+
+	if first.Sequence != 1 || second.Sequence != 2 {
+		t.Errorf("Expected sequences 1 and 2, got %d and %d", first.Sequence, second.Sequence)
+	}
+
+	// Sequences are per topic
+	if other.Sequence != 1 {
+		t.Errorf("Expected other topic to start at sequence 1, got %d", other.Sequence)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(frames))
+	}
+
+	if frames[0].Sequence != 2 {
+		t.Errorf("Expected event sequence 2, got %d", frames[0].Sequence)
+	}
+
+	// The receive timestamp keeps sub-second precision
+	if frames[0].ReceivedAt != "2025-01-15T10:00:00.123456789Z" {
+		t.Errorf("Expected nanosecond receive timestamp, got %s", frames[0].ReceivedAt)
+	}
+}
+
+func TestCreateReservedTopic(t *testing.T) {
+	hub := NewHub()
+
+	if err := hub.CreateTopic(EchoTopic); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic for %s, got %v", EchoTopic, err)
+	}
+
+	if err := hub.CreateTopic("$SYS/custom"); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic for $SYS/custom, got %v", err)
+	}
+}
+
+func TestHubRecoversFromPanic(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("test-topic")
+	go hub.Run()
+	defer hub.Shutdown()
+
+	// A nil message panics inside publishMessage
+	hub.publishes.push("test-topic", nil, 1, nil, nil)
+
+	// The hub loop must keep routing afterwards
+	client := newTestClient(hub)
+	hub.subscribe <- &Subscription{client: client, topic: "test-topic"}
+	hub.enqueuePublish(&PubSubMessage{
+		Topic:     "test-topic",
+		Message:   &MessageData{ID: "msg-1", Payload: "hello"},
+		Timestamp: time.Now(),
+	})
+
+	select {
+	case <-client.queue.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Hub stopped routing messages after a panic")
+	}
+
+	if panics := hub.GetStats().Panics; panics != 1 {
+		t.Errorf("Expected 1 recorded panic, got %d", panics)
+	}
+}
+
+func TestTopicStats(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("test-topic")
+
+	// A subscriber whose queue holds a single frame
+	client := newTestClient(hub)
+	client.queue = newMessageQueue(1)
+	hub.subscribeClient(&Subscription{client: client, topic: "test-topic"})
+
+	for i := 0; i < 3; i++ {
+		hub.publishMessage(&PubSubMessage{
+			Topic:     "test-topic",
+			Message:   &MessageData{ID: "msg", Payload: i},
+			Timestamp: time.Now(),
+		})
+	}
+
+	stats, err := hub.GetTopicStats("test-topic")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+
+	if stats.MessageCount != 3 {
+		t.Errorf("Expected 3 messages, got %d", stats.MessageCount)
+	}
+
+	if stats.DroppedCount != 2 {
+		t.Errorf("Expected 2 dropped deliveries, got %d", stats.DroppedCount)
+	}
+
+	if stats.LastPublishAt == nil {
+		t.Error("Expected last publish time to be set")
+	}
+
+	if stats.BufferOccupancy != 3 || stats.BufferCapacity != 100 {
+		t.Errorf("Expected buffer occupancy 3/100, got %d/%d", stats.BufferOccupancy, stats.BufferCapacity)
+	}
+
+	if _, exists := hub.GetStats().Topics["test-topic"]; !exists {
+		t.Error("GetStats should include per-topic statistics")
+	}
+
+	if _, err := hub.GetTopicStats("missing"); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestValidateMessageSize(t *testing.T) {
+	data := &MessageData{ID: "msg-1", Payload: "hello"} // 7 bytes encoded
+
+	if err := ValidateMessageSize(data, 7); err != nil {
+		t.Errorf("Payload at the limit should be accepted, got %v", err)
+	}
+
+	if err := ValidateMessageSize(data, 0); err != nil {
+		t.Errorf("Zero limit should disable the check, got %v", err)
+	}
+
+	err := ValidateMessageSize(data, 6)
+	tooLarge, ok := err.(*MessageTooLargeError)
+	if !ok {
+		t.Fatalf("Expected MessageTooLargeError, got %v", err)
+	}
+
+	if tooLarge.Size != 7 || tooLarge.Limit != 6 {
+		t.Errorf("Expected size 7 and limit 6, got %d and %d", tooLarge.Size, tooLarge.Limit)
+	}
+}
+
+func TestHubChannelStats(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 4})
+
+	// The hub isn't running, so publishes back up in the topic's backlog
+	hub.enqueuePublish(&PubSubMessage{Topic: "test-topic"})
+	hub.enqueuePublish(&PubSubMessage{Topic: "test-topic"})
+
+	stats := hub.GetStats()
+	publish := stats.Channels["publish"]
+	if publish.Depth != 2 || publish.Capacity != 4 {
+		t.Errorf("Expected publish depth 2 of 4, got %+v", publish)
+	}
+
+	if subscribe := stats.Channels["subscribe"]; subscribe.Depth != 0 || subscribe.Capacity != 0 {
+		t.Errorf("Expected unbuffered subscribe channel, got %+v", subscribe)
+	}
+}
+
+func TestHubOptionsValidate(t *testing.T) {
+	if err := DefaultHubOptions().Validate(); err != nil {
+		t.Errorf("Expected default options to be valid, got %v", err)
+	}
+
+	if err := (HubOptions{PublishBuffer: -1}).Validate(); err == nil {
+		t.Error("Expected negative capacity to be rejected")
+	}
+}
+
+func TestRegisterClientAdmits(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client := NewClient(hub, nil, "client-1", DefaultClientOptions())
+	if err := hub.RegisterClient(client); err != nil {
+		t.Fatalf("Expected client to be registered, got %v", err)
+	}
+
+	if stats := hub.GetStats(); stats.TotalClients != 1 {
+		t.Errorf("Expected 1 client, got %d", stats.TotalClients)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != InfoMessage {
+		t.Errorf("Expected a welcome frame, got %+v", frames)
+	}
+
+	// No connection to close at shutdown
+	hub.unregisterClient(client)
+}
+
+func TestRegisterClientAfterShutdown(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	hub.Shutdown()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- hub.RegisterClient(NewClient(hub, nil, "late", DefaultClientOptions()))
+	}()
+
+	select {
+	case err := <-done:
+		if err != ErrShuttingDown {
+			t.Errorf("Expected ErrShuttingDown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RegisterClient blocked after shutdown")
+	}
+}
+
+func TestRegisterClientRejectedWhileShuttingDown(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	// Shutdown has begun but the hub loop is still receiving
+	hub.mu.Lock()
+	hub.shuttingDown = true
+	hub.mu.Unlock()
+
+	client := NewClient(hub, nil, "late", DefaultClientOptions())
+	if err := hub.RegisterClient(client); err != ErrShuttingDown {
+		t.Errorf("Expected ErrShuttingDown, got %v", err)
+	}
+
+	if stats := hub.GetStats(); stats.TotalClients != 0 {
+		t.Errorf("Expected rejected client not to be registered, got %d clients", stats.TotalClients)
+	}
+}
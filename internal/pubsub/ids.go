@@ -0,0 +1,10 @@
+package pubsub
+
+import "github.com/oklog/ulid/v2"
+
+// NewMessageID returns a broker-generated message ID. IDs are ULIDs: 26
+// characters, lexicographically sortable by generation time and monotonic
+// within this process.
+func NewMessageID() string {
+	return ulid.Make().String()
+}
@@ -0,0 +1,170 @@
+package pubsub
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PublishDuplicate is the status a publish is acknowledged with when its
+// message ID repeats one its topic published within the dedup window; the
+// duplicate is dropped
+const PublishDuplicate = "duplicate"
+
+const (
+	// MaxDedupWindow bounds how long a topic remembers published message IDs
+	MaxDedupWindow = 24 * time.Hour
+	// maxDedupIDs bounds how many message IDs each topic remembers; the
+	// oldest are forgotten first, so a burst past it shortens the window
+	maxDedupIDs = 100000
+)
+
+// validateDedupWindow checks a dedup window in milliseconds: 0 for none,
+// or up to MaxDedupWindow
+func validateDedupWindow(ms int64) error {
+	if ms < 0 || ms > MaxDedupWindow.Milliseconds() {
+		return fmt.Errorf("%w: dedup_window_ms must be between 0 and %d", ErrInvalidDedupWindow, MaxDedupWindow.Milliseconds())
+	}
+	return nil
+}
+
+// idWindow remembers the message IDs published to a topic within a window,
+// so publisher retries can be recognised and dropped. It has its own lock,
+// letting publishes claim IDs under the hub read lock.
+type idWindow struct {
+	mu     sync.Mutex
+	window time.Duration
+	// ids indexes order by message ID
+	ids map[string]*list.Element
+	// order holds each remembered ID's seenID, oldest first
+	order *list.List
+	// duplicates counts the publishes dropped as duplicates
+	duplicates int64
+}
+
+// seenID is a message ID and when it was first published
+type seenID struct {
+	id string
+	at time.Time
+}
+
+func newIDWindow(window time.Duration) *idWindow {
+	return &idWindow{window: window, ids: make(map[string]*list.Element), order: list.New()}
+}
+
+// claim remembers id as published at now, reporting false if it was already
+// published within the window. A duplicate doesn't extend the window.
+func (w *idWindow) claim(id string, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(now)
+	if _, seen := w.ids[id]; seen {
+		w.duplicates++
+		return false
+	}
+	w.ids[id] = w.order.PushBack(seenID{id: id, at: now})
+	if w.order.Len() > maxDedupIDs {
+		w.remove(w.order.Front())
+	}
+	return true
+}
+
+// forget drops a claimed ID whose publish failed, so a retry isn't taken
+// for a duplicate
+func (w *idWindow) forget(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if element, seen := w.ids[id]; seen {
+		w.remove(element)
+	}
+}
+
+// expire drops the IDs published a window or more before now. Caller must
+// hold w.mu.
+func (w *idWindow) expire(now time.Time) {
+	for front := w.order.Front(); front != nil; front = w.order.Front() {
+		if now.Sub(front.Value.(seenID).at) < w.window {
+			return
+		}
+		w.remove(front)
+	}
+}
+
+func (w *idWindow) remove(element *list.Element) {
+	delete(w.ids, element.Value.(seenID).id)
+	w.order.Remove(element)
+}
+
+// setWindow changes how long IDs are remembered, keeping those already
+// claimed
+func (w *idWindow) setWindow(window time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.window = window
+}
+
+// stats returns the window and how many duplicates were dropped
+func (w *idWindow) stats() (time.Duration, int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.window, w.duplicates
+}
+
+// setDedupWindow turns dedup on with a window in milliseconds, changes its
+// window or, for 0, turns it off. Caller must hold the hub write lock.
+func (t *Topic) setDedupWindow(ms int64) {
+	switch {
+	case ms == 0:
+		t.dedup = nil
+	case t.dedup == nil:
+		t.dedup = newIDWindow(time.Duration(ms) * time.Millisecond)
+	default:
+		t.dedup.setWindow(time.Duration(ms) * time.Millisecond)
+	}
+}
+
+// dedupWindowMs returns the topic's dedup window in milliseconds, 0 if it
+// doesn't dedup
+func (t *Topic) dedupWindowMs() int64 {
+	if t.dedup == nil {
+		return 0
+	}
+	window, _ := t.dedup.stats()
+	return window.Milliseconds()
+}
+
+// claimMessageID reports whether a publish is new: true unless its topic
+// dedups and already published its message ID within the window. Messages
+// without an ID are always new. A claimed ID whose publish then fails must
+// be released with forgetMessageIDs.
+func (h *Hub) claimMessageID(message *PubSubMessage) bool {
+	if message.Message == nil || message.Message.ID == "" {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[message.Topic]
+	if !exists || topic.dedup == nil {
+		return true
+	}
+	return topic.dedup.claim(message.Message.ID, h.clock.Now())
+}
+
+// forgetMessageIDs releases the IDs claimed for publishes that failed
+func (h *Hub) forgetMessageIDs(messages []*PubSubMessage) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, message := range messages {
+		if message.Message == nil || message.Message.ID == "" {
+			continue
+		}
+		if topic, exists := h.topics[message.Topic]; exists && topic.dedup != nil {
+			topic.dedup.forget(message.Message.ID)
+		}
+	}
+}
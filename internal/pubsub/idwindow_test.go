@@ -0,0 +1,163 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientPublishDuplicate(t *testing.T) {
+	clock := NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts := DefaultHubOptions()
+	opts.Clock = clock
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: 60000})
+	subscriber := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: subscriber, topic: "orders"})
+
+	publisher := newTestClient(hub)
+	publish := func(requestID string) *ServerMessage {
+		publisher.handleMessage(&ClientMessage{
+			Type:      PublishMessage,
+			Topic:     "orders",
+			RequestID: requestID,
+			Message:   &MessageData{ID: "m1", Payload: 1},
+		})
+		frames := drainFrames(t, publisher)
+		if len(frames) != 1 || frames[0].Type != AckMessage {
+			t.Fatalf("Expected a single ack, got %+v", frames)
+		}
+		return &frames[0]
+	}
+
+	if ack := publish("req-1"); ack.Status != "ok" {
+		t.Errorf("Expected the first publish acked ok, got %q", ack.Status)
+	}
+	clock.Advance(59 * time.Second)
+	if ack := publish("req-2"); ack.Status != PublishDuplicate || ack.MessageID != "m1" {
+		t.Errorf("Expected the retry acked as a duplicate of m1, got %+v", ack)
+	}
+
+	hub.dispatchPublishes()
+	if events := drainFrames(t, subscriber); len(events) != 1 {
+		t.Errorf("Expected the duplicate dropped, got %d events", len(events))
+	}
+
+	// The window counts from the first publish, not the retry
+	clock.Advance(time.Second)
+	if ack := publish("req-3"); ack.Status != "ok" {
+		t.Errorf("Expected the ID published again past the window, got %q", ack.Status)
+	}
+
+	stats, _ := hub.GetTopicStats("orders")
+	if stats.DedupWindowMs != 60000 || stats.Duplicates != 1 {
+		t.Errorf("Expected a 60000ms window with 1 duplicate, got %d and %d", stats.DedupWindowMs, stats.Duplicates)
+	}
+}
+
+func TestPublishDirectDuplicate(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: 60000})
+	hub.CreateTopic("payments")
+
+	for _, topic := range []string{"orders", "payments"} {
+		if _, err := hub.PublishDirect(context.Background(), topic, &MessageData{ID: "m1", Payload: 1}); err != nil {
+			t.Fatalf("PublishDirect failed: %v", err)
+		}
+	}
+	receipt, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "m1", Payload: 1})
+	if err != nil || !receipt.Duplicate || receipt.ID != "m1" {
+		t.Errorf("Expected a duplicate receipt for m1, got %+v, %v", receipt, err)
+	}
+	receipt, err = hub.PublishDirect(context.Background(), "payments", &MessageData{ID: "m1", Payload: 1})
+	if err != nil || receipt.Duplicate {
+		t.Errorf("Expected topics without a window to publish every retry, got %+v, %v", receipt, err)
+	}
+	if pending := hub.publishes.topicPending("orders"); pending != 1 {
+		t.Errorf("Expected only the first publish queued, got %d", pending)
+	}
+}
+
+func TestPublishDuplicateForgottenOnFailure(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 1})
+	hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: 60000})
+
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "first", Payload: 0}); err != nil {
+		t.Fatalf("PublishDirect failed: %v", err)
+	}
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "m1", Payload: 1}); !errors.Is(err, ErrHubSaturated) {
+		t.Fatalf("Expected the publish to time out waiting for room, got %v", err)
+	}
+
+	// The failed publish didn't count, so its retry goes through
+	hub.dispatchPublishes()
+	receipt, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "m1", Payload: 1})
+	if err != nil || receipt.Duplicate {
+		t.Errorf("Expected the retry published, got %+v, %v", receipt, err)
+	}
+}
+
+func TestPublishBatchDuplicates(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: 60000})
+
+	if _, err := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "a", Payload: 0}); err != nil {
+		t.Fatalf("PublishDirect failed: %v", err)
+	}
+	data := []*MessageData{{ID: "a", Payload: 1}, {ID: "b", Payload: 2}, {ID: "b", Payload: 3}, {ID: "c", Payload: 4}}
+	receipt, err := hub.PublishBatch(context.Background(), "orders", data)
+	if err != nil {
+		t.Fatalf("PublishBatch failed: %v", err)
+	}
+	want := []string{BatchDuplicate, BatchPublished, BatchDuplicate, BatchPublished}
+	for i, result := range receipt.Results {
+		if result.Status != want[i] {
+			t.Errorf("Expected message %d %s, got %s", i, want[i], result.Status)
+		}
+	}
+	if pending := hub.publishes.topicPending("orders"); pending != 3 {
+		t.Errorf("Expected a, b and c queued, got %d pending", pending)
+	}
+}
+
+func TestDedupWindowSettings(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: -1}); !errors.Is(err, ErrInvalidDedupWindow) {
+		t.Errorf("Expected ErrInvalidDedupWindow for a negative window, got %v", err)
+	}
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{DedupWindowMs: MaxDedupWindow.Milliseconds() + 1}); !errors.Is(err, ErrInvalidDedupWindow) {
+		t.Errorf("Expected ErrInvalidDedupWindow past the maximum, got %v", err)
+	}
+
+	hub.CreateTopic("orders")
+	window := int64(1000)
+	stats, err := hub.UpdateTopic("orders", TopicUpdate{DedupWindowMs: &window}, 0)
+	if err != nil || stats.DedupWindowMs != 1000 {
+		t.Fatalf("Expected dedup turned on, got %+v, %v", stats, err)
+	}
+	hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "m1", Payload: 1})
+
+	window = 0
+	if stats, _ := hub.UpdateTopic("orders", TopicUpdate{DedupWindowMs: &window}, 0); stats.DedupWindowMs != 0 {
+		t.Errorf("Expected dedup turned off, got %d", stats.DedupWindowMs)
+	}
+	if receipt, _ := hub.PublishDirect(context.Background(), "orders", &MessageData{ID: "m1", Payload: 1}); receipt.Duplicate {
+		t.Error("Expected no duplicates once dedup is off")
+	}
+}
+
+func TestIDWindowBounded(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := newIDWindow(time.Hour)
+	for i := 0; i <= maxDedupIDs; i++ {
+		window.claim(strconv.Itoa(i), now)
+	}
+	if len(window.ids) != maxDedupIDs || window.order.Len() != maxDedupIDs {
+		t.Fatalf("Expected %d IDs remembered, got %d", maxDedupIDs, len(window.ids))
+	}
+	if !window.claim("0", now) {
+		t.Error("Expected the oldest ID forgotten past the bound")
+	}
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"strings"
+)
+
+// InboxTopicPrefix starts the name of every WebSocket client's private reply
+// topic, ~inbox/{client_id}. Only the client may subscribe to its inbox;
+// anyone may publish to it.
+const InboxTopicPrefix = "~inbox/"
+
+// InboxTopic returns the name of a client's inbox
+func InboxTopic(clientID string) string {
+	return InboxTopicPrefix + clientID
+}
+
+// IsInboxTopic reports whether a topic name is in the inbox namespace
+func IsInboxTopic(topic string) bool {
+	return strings.HasPrefix(topic, InboxTopicPrefix)
+}
+
+// checkInboxSubscribe rejects subscribes to inboxes other than the
+// subscriber's own
+func checkInboxSubscribe(topic, clientID string) error {
+	if IsInboxTopic(topic) && topic != InboxTopic(clientID) {
+		return ErrInboxPrivate
+	}
+	return nil
+}
+
+// provisionInbox creates a client's inbox. Inboxes live as long as their
+// client's connection, so they are neither persisted nor snapshotted.
+// Caller must hold the hub write lock.
+func (h *Hub) provisionInbox(client *Client) {
+	name := InboxTopic(client.id)
+	if _, exists := h.topics[name]; exists {
+		return
+	}
+
+	h.topics[name] = &Topic{
+		Name:           name,
+		CreatedAt:      h.clock.Now(),
+		payloadSizes:   NewSizeHistogram(),
+		revision:       1,
+		inbox:          true,
+		ringBufferSize: h.ringBufferSize,
+	}
+	logStoreError("create topic", h.store.CreateTopic(name))
+	h.applyRetention(h.topics[name])
+	h.updateSubscriberCount(name)
+	h.stats.TotalTopics = len(h.topics)
+}
+
+// removeInbox deletes a departing client's inbox with its undelivered
+// messages. Caller must hold the hub write lock.
+func (h *Hub) removeInbox(client *Client) {
+	name := InboxTopic(client.id)
+	topic, exists := h.topics[name]
+	if !exists || !topic.inbox {
+		return
+	}
+
+	delete(h.topics, name)
+	delete(h.subscriptions, name)
+	if h.executors != nil {
+		h.executors.forget(name)
+	}
+	logStoreError("delete topic", h.store.DeleteTopic(name))
+	h.stats.TotalTopics = len(h.topics)
+}
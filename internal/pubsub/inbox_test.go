@@ -0,0 +1,101 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInboxProvisionedForConnection(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client := NewClient(hub, nil, "client-1", DefaultClientOptions())
+	if err := hub.RegisterClient(client); err != nil {
+		t.Fatalf("Failed to register client: %v", err)
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Inbox != "~inbox/client-1" {
+		t.Fatalf("Expected the welcome frame to name the inbox, got %+v", frames)
+	}
+	if _, exists := hub.GetTopics()["~inbox/client-1"]; !exists {
+		t.Fatal("Expected the inbox topic to be created on connect")
+	}
+	if info, _ := hub.GetClient("client-1"); info.Inbox != "~inbox/client-1" {
+		t.Errorf("Expected the client details to name the inbox, got %q", info.Inbox)
+	}
+	for _, topic := range hub.Snapshot().Topics {
+		if topic.Name == "~inbox/client-1" {
+			t.Error("Inboxes should not be snapshotted")
+		}
+	}
+
+	hub.unregisterClient(client)
+	if _, exists := hub.GetTopics()["~inbox/client-1"]; exists {
+		t.Error("Expected the inbox topic to be removed on disconnect")
+	}
+}
+
+func TestInboxOnlyOwnerSubscribes(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	owner := NewClient(hub, nil, "owner", DefaultClientOptions())
+	other := NewClient(hub, nil, "other", DefaultClientOptions())
+	for _, client := range []*Client{owner, other} {
+		if err := hub.RegisterClient(client); err != nil {
+			t.Fatalf("Failed to register client: %v", err)
+		}
+		drainFrames(t, client)
+		defer hub.unregisterClient(client)
+	}
+
+	other.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: InboxTopic("owner"), ClientID: "snoop", RequestID: "sub-1"})
+	if frames := drainFrames(t, other); len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != CodeForbidden {
+		t.Errorf("Expected FORBIDDEN subscribing to another client's inbox, got %+v", frames)
+	}
+
+	owner.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: InboxTopic("owner"), ClientID: "replies"})
+	waitForSubscribers(t, hub, InboxTopic("owner"), 1)
+	drainFrames(t, owner)
+
+	// Anyone may publish to an inbox
+	other.handleMessage(&ClientMessage{
+		Type:    PublishMessage,
+		Topic:   InboxTopic("owner"),
+		Message: &MessageData{ID: "reply-1", Payload: "pong"},
+	})
+
+	var frames []ServerMessage
+	deadline := time.Now().Add(2 * time.Second)
+	for len(frames) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		frames = append(frames, drainFrames(t, owner)...)
+	}
+	if len(frames) != 1 || frames[0].Message == nil || frames[0].Message.ID != "reply-1" {
+		t.Errorf("Expected the owner to receive the reply, got %+v", frames)
+	}
+
+	if _, err := hub.OpenStream("sse-1", InboxTopic("owner"), StreamOptions{}, DefaultClientOptions()); err != ErrInboxPrivate {
+		t.Errorf("Expected ErrInboxPrivate for a stream subscribing to an inbox, got %v", err)
+	}
+}
+
+func TestInboxNamesAreReserved(t *testing.T) {
+	hub := NewHub()
+
+	if err := hub.CreateTopic("~inbox/someone"); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic creating an inbox, got %v", err)
+	}
+
+	client := NewClient(hub, nil, "client-1", DefaultClientOptions())
+	hub.registerClient(client)
+	if err := hub.DeleteTopic(InboxTopic("client-1")); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic deleting an inbox, got %v", err)
+	}
+	if err := hub.PurgeTopic(InboxTopic("client-1")); err != ErrReservedTopic {
+		t.Errorf("Expected ErrReservedTopic purging an inbox, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+// checkSubscriberInvariants verifies that every topic's SubscriberCount
+// matches the subscription map and that client-side subscription sets agree
+// with the hub
+func checkSubscriberInvariants(t *testing.T, hub *Hub) {
+	t.Helper()
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+
+	for name, topic := range hub.topics {
+		if actual := len(hub.subscriptions[name]); topic.SubscriberCount != actual {
+			t.Errorf("Topic %s: SubscriberCount %d, subscription map has %d", name, topic.SubscriberCount, actual)
+		}
+	}
+
+	for name, clients := range hub.subscriptions {
+		if len(clients) == 0 {
+			t.Errorf("Topic %s: empty subscription set should have been removed", name)
+		}
+		for client := range clients {
+			if !client.IsSubscribed(name) {
+				t.Errorf("Topic %s: hub has subscriber the client doesn't know about", name)
+			}
+		}
+	}
+}
+
+// subscribe subscribes a client the way handleSubscribe does, without a hub loop
+func subscribe(hub *Hub, client *Client, topic string) {
+	client.mu.Lock()
+	client.subscriptions[topic] = true
+	client.mu.Unlock()
+	hub.subscribeClient(&Subscription{client: client, topic: topic})
+}
+
+func TestSubscriberInvariantsSubscribeUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	c1, c2 := newTestClient(hub), newTestClient(hub)
+	subscribe(hub, c1, "orders")
+	subscribe(hub, c2, "orders")
+	checkSubscriberInvariants(t, hub)
+
+	// Duplicate subscription must not inflate the count
+	subscribe(hub, c1, "orders")
+	checkSubscriberInvariants(t, hub)
+
+	c1.mu.Lock()
+	delete(c1.subscriptions, "orders")
+	c1.mu.Unlock()
+	hub.unsubscribeClient(&Subscription{client: c1, topic: "orders"})
+	checkSubscriberInvariants(t, hub)
+
+	if stats, _ := hub.GetTopicStats("orders"); stats.SubscriberCount != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", stats.SubscriberCount)
+	}
+}
+
+func TestSubscriberInvariantsUnregister(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("payments")
+
+	client := newTestClient(hub)
+	hub.clients[client] = true
+	subscribe(hub, client, "orders")
+	subscribe(hub, client, "payments")
+
+	hub.unregisterClient(client)
+	checkSubscriberInvariants(t, hub)
+
+	if stats, _ := hub.GetTopicStats("payments"); stats.SubscriberCount != 0 {
+		t.Errorf("Expected 0 subscribers after disconnect, got %d", stats.SubscriberCount)
+	}
+}
+
+func TestSubscriberInvariantsSubscribeBeforeCreate(t *testing.T) {
+	hub := NewHub()
+
+	client := newTestClient(hub)
+	subscribe(hub, client, "late-topic")
+
+	hub.CreateTopic("late-topic")
+	checkSubscriberInvariants(t, hub)
+
+	if stats, _ := hub.GetTopicStats("late-topic"); stats.SubscriberCount != 1 {
+		t.Errorf("Expected existing subscriber to be counted, got %d", stats.SubscriberCount)
+	}
+}
+
+func TestSubscriberInvariantsDeleteAndRecreate(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	client := newTestClient(hub)
+	subscribe(hub, client, "orders")
+
+	hub.DeleteTopic("orders")
+	checkSubscriberInvariants(t, hub)
+
+	if client.IsSubscribed("orders") {
+		t.Error("Client should be detached from a deleted topic")
+	}
+
+	hub.CreateTopic("orders")
+	checkSubscriberInvariants(t, hub)
+
+	if stats, _ := hub.GetTopicStats("orders"); stats.SubscriberCount != 0 {
+		t.Errorf("Re-created topic should start without subscribers, got %d", stats.SubscriberCount)
+	}
+}
+
+func TestReconcileSubscriberCounts(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	client := newTestClient(hub)
+	subscribe(hub, client, "orders")
+
+	// Simulate drift
+	hub.mu.Lock()
+	hub.topics["orders"].SubscriberCount = 7
+	hub.mu.Unlock()
+
+	if repaired := hub.reconcileSubscriberCounts(); repaired != 1 {
+		t.Errorf("Expected 1 repaired topic, got %d", repaired)
+	}
+	checkSubscriberInvariants(t, hub)
+
+	if repaired := hub.reconcileSubscriberCounts(); repaired != 0 {
+		t.Errorf("Expected no repairs on consistent state, got %d", repaired)
+	}
+}
@@ -0,0 +1,215 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"plivo/internal/auth"
+)
+
+// JSONRPCSubprotocol is the WebSocket subprotocol that negotiates JSON-RPC
+// 2.0 framing instead of the native frames
+const JSONRPCSubprotocol = "jsonrpc2"
+
+// JSON-RPC 2.0 error codes. Broker errors all use jsonrpcBrokerError, with
+// the native error body, including its code, as the error's data.
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcInvalidRequest = -32600
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+	jsonrpcBrokerError    = -32000
+)
+
+// JSON-RPC methods beyond the native message types, which map one to one
+const (
+	jsonrpcListTopics = "topics.list"
+	jsonrpcGetTopic   = "topics.get"
+)
+
+// jsonrpcRequest is a JSON-RPC 2.0 request, or a notification without an ID
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// jsonrpcError is a JSON-RPC 2.0 error object
+type jsonrpcError struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+// jsonrpcNotification is a server-initiated JSON-RPC 2.0 notification,
+// carrying a native event, info or error frame as its params
+type jsonrpcNotification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  MessageType     `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// jsonrpcNull is the ID of responses to requests whose ID couldn't be read
+var jsonrpcNull = json.RawMessage("null")
+
+// SetJSONRPC switches the client to JSON-RPC 2.0 framing, for connections
+// that negotiated JSONRPCSubprotocol. It must be called before the client
+// is registered.
+func (c *Client) SetJSONRPC() {
+	c.jsonrpc = true
+}
+
+// handleJSONRPC handles a JSON-RPC request by mapping it onto the native
+// message of the same name. The request ID, as raw JSON, becomes the
+// message's request ID, so the native reply is translated back into a
+// response for it by writeJSONRPC.
+func (c *Client) handleJSONRPC(data []byte) {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.sendJSONRPCError(jsonrpcNull, jsonrpcParseError, "Parse error")
+		return
+	}
+	id := req.ID
+	if len(id) == 0 {
+		id = jsonrpcNull
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		c.sendJSONRPCError(id, jsonrpcInvalidRequest, "Invalid Request")
+		return
+	}
+
+	var msg ClientMessage
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &msg); err != nil {
+			c.sendJSONRPCError(id, jsonrpcInvalidParams, "Invalid params")
+			return
+		}
+	}
+	msg.Type = MessageType(req.Method)
+	msg.RequestID = string(req.ID)
+
+	switch msg.Type {
+	case PublishMessage, PublishBatchMessage, SubscribeMessage, UnsubscribeMessage, PingMessage, StateSetMessage, StateGetMessage:
+		c.handleMessage(&msg)
+	case jsonrpcListTopics:
+		c.listTopicsJSONRPC(msg.RequestID)
+	case jsonrpcGetTopic:
+		c.getTopicJSONRPC(msg.RequestID, msg.Topic)
+	default:
+		c.sendJSONRPCError(id, jsonrpcMethodNotFound, "Method not found")
+	}
+}
+
+// listTopicsJSONRPC answers topics.list with the topics the client may
+// subscribe to: its own inbox but no other, and those the ACL allows
+func (c *Client) listTopicsJSONRPC(requestID string) {
+	type topicSummary struct {
+		Name        string `json:"name"`
+		Subscribers int    `json:"subscribers"`
+	}
+	topics := make([]topicSummary, 0)
+	for _, topic := range c.hub.GetTopics() {
+		if checkInboxSubscribe(topic.Name, c.id) != nil {
+			continue
+		}
+		if topic.Name == InboxTopic(c.id) || c.authorize == nil || c.authorize(auth.PermSubscribe, topic.Name) {
+			topics = append(topics, topicSummary{Name: topic.Name, Subscribers: topic.SubscriberCount})
+		}
+	}
+	c.sendJSONRPCResult(requestID, map[string]interface{}{"topics": topics})
+}
+
+// getTopicJSONRPC answers topics.get with the topic's statistics
+func (c *Client) getTopicJSONRPC(requestID, topic string) {
+	if !c.permitted(requestID, auth.PermSubscribe, topic) {
+		return
+	}
+	stats, err := c.hub.GetTopicStats(topic)
+	if err != nil {
+		c.sendErrorData(requestID, ErrorFrom(err))
+		return
+	}
+	c.sendJSONRPCResult(requestID, stats)
+}
+
+// sendJSONRPCResult sends a response to a request. Notifications, which
+// have no ID, get none.
+func (c *Client) sendJSONRPCResult(requestID string, result interface{}) {
+	if requestID == "" {
+		return
+	}
+	data, _ := json.Marshal(result)
+	c.sendJSONRPC(jsonrpcResponse{ID: json.RawMessage(requestID), Result: data})
+}
+
+// sendJSONRPCError sends an error response for a request the broker
+// couldn't map onto a native message
+func (c *Client) sendJSONRPCError(id json.RawMessage, code int, message string) {
+	c.sendJSONRPC(jsonrpcResponse{ID: id, Error: &jsonrpcError{Code: code, Message: message}})
+}
+
+// sendJSONRPC queues a JSON-RPC response, which writeJSONRPC passes through
+func (c *Client) sendJSONRPC(resp jsonrpcResponse) {
+	resp.JSONRPC = "2.0"
+	data, _ := json.Marshal(resp)
+	c.sendWithBackpressure("", data)
+}
+
+// writeJSONRPC writes a native frame as JSON-RPC: acks, pongs, state
+// frames and errors answering a request become its response, with the frame as the result or
+// the error's data, and everything else, including errors answering no
+// request, becomes a notification named after the frame type. Replies to
+// notifications are dropped. Frames already in JSON-RPC form are written
+// as they are.
+func (c *Client) writeJSONRPC(data []byte) error {
+	var frame struct {
+		JSONRPC   string      `json:"jsonrpc"`
+		Type      MessageType `json:"type"`
+		RequestID string      `json:"request_id"`
+		Error     *ErrorData  `json:"error"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil || frame.JSONRPC != "" {
+		return c.writeText(data)
+	}
+	replying := frame.RequestID != "" && json.Valid([]byte(frame.RequestID))
+
+	var out interface{}
+	switch {
+	case frame.Type == ErrorMessage && replying:
+		message := ""
+		if frame.Error != nil {
+			message = frame.Error.Message
+		}
+		out = jsonrpcResponse{
+			JSONRPC: "2.0",
+			ID:      json.RawMessage(frame.RequestID),
+			Error:   &jsonrpcError{Code: jsonrpcBrokerError, Message: message, Data: frame.Error},
+		}
+	case frame.Type == AckMessage || frame.Type == PongMessage || frame.Type == StateMessage && replying:
+		if !replying {
+			return nil
+		}
+		// The result is the native reply without its framing
+		var fields map[string]json.RawMessage
+		json.Unmarshal(data, &fields)
+		delete(fields, "type")
+		delete(fields, "request_id")
+		result, _ := json.Marshal(fields)
+		out = jsonrpcResponse{JSONRPC: "2.0", ID: json.RawMessage(frame.RequestID), Result: result}
+	default:
+		out = jsonrpcNotification{JSONRPC: "2.0", Method: frame.Type, Params: data}
+	}
+
+	encoded, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return c.writeText(encoded)
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeliveryGapInfo is the info frame message sent in place of events dropped
+// for missing their subscription's max_latency or outliving their TTL
+const DeliveryGapInfo = "gap"
+
+// GapInfo describes a run of events dropped from a subscription
+type GapInfo struct {
+	// From and To are the topic sequences of the first and last dropped event
+	From int64 `json:"from,omitempty"`
+	To   int64 `json:"to,omitempty"`
+	// Dropped is how many events were dropped
+	Dropped int `json:"dropped"`
+}
+
+// writeFrames writes a batch of queued frames, dropping event frames whose
+// delivery deadline or TTL passed while they waited their turn. A topic's dropped
+// events are reported with one gap info frame, written before the topic's
+// next delivered event or at the end of the batch.
+func (c *Client) writeFrames(frames []queuedFrame, write func([]byte) error) error {
+	gaps := make(map[string]*GapInfo)
+	var pending []string
+
+	flush := func(topic string) error {
+		gap, exists := gaps[topic]
+		if !exists {
+			return nil
+		}
+		delete(gaps, topic)
+		c.hub.recordDrops(topic, gap.Dropped)
+		return write(c.encode(c.hub.createGapMessageBytes(topic, gap)))
+	}
+
+	for _, frame := range frames {
+		if now := c.hub.clock.Now(); !frame.deadline.IsZero() && now.After(frame.deadline) {
+			c.hub.deadLetter(frame.message, deadlineReason(frame, now), c.identity())
+			gap, exists := gaps[frame.topic]
+			if !exists {
+				gap = &GapInfo{From: frame.sequence}
+				gaps[frame.topic] = gap
+				pending = append(pending, frame.topic)
+			}
+			gap.To = frame.sequence
+			gap.Dropped++
+			continue
+		}
+
+		if err := flush(frame.topic); err != nil {
+			return err
+		}
+		if err := write(frame.data); err != nil {
+			return err
+		}
+	}
+
+	for _, topic := range pending {
+		if err := flush(topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createGapMessageBytes creates the info frame reporting events dropped
+// from a subscription for missing its max_latency or outliving their TTL
+func (h *Hub) createGapMessageBytes(topic string, gap *GapInfo) []byte {
+	msg := ServerMessage{
+		Type:  InfoMessage,
+		Topic: topic,
+		Msg:   DeliveryGapInfo,
+		Gap:   gap,
+		TS:    h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteFramesDropsExpiredEvents(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("prices")
+	client := newTestClient(hub)
+
+	past := time.Now().Add(-time.Second)
+	future := time.Now().Add(time.Minute)
+	frames := []queuedFrame{
+		{topic: "prices", data: []byte(`{"type":"event","sequence":1}`), sequence: 1, deadline: past},
+		{topic: "prices", data: []byte(`{"type":"event","sequence":2}`), sequence: 2, deadline: past},
+		{topic: "", data: []byte(`{"type":"pong"}`)},
+		{topic: "prices", data: []byte(`{"type":"event","sequence":3}`), sequence: 3, deadline: future},
+		{topic: "prices", data: []byte(`{"type":"event","sequence":4}`), sequence: 4, deadline: past},
+	}
+
+	var written []ServerMessage
+	err := client.writeFrames(frames, func(data []byte) error {
+		var msg ServerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Failed to unmarshal frame: %v", err)
+		}
+		written = append(written, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("writeFrames failed: %v", err)
+	}
+
+	// The gap for 1-2 precedes the next prices event; the one for 4 ends the batch
+	if len(written) != 4 {
+		t.Fatalf("Expected 4 frames, got %+v", written)
+	}
+	if written[0].Type != PongMessage {
+		t.Errorf("Expected the pong first, got %+v", written[0])
+	}
+	gap := written[1]
+	if gap.Msg != DeliveryGapInfo || gap.Topic != "prices" || gap.Gap == nil ||
+		gap.Gap.From != 1 || gap.Gap.To != 2 || gap.Gap.Dropped != 2 {
+		t.Errorf("Expected a gap for sequences 1-2, got %+v (%+v)", gap, gap.Gap)
+	}
+	if written[2].Sequence != 3 {
+		t.Errorf("Expected event 3 after the gap, got %+v", written[2])
+	}
+	if last := written[3]; last.Gap == nil || last.Gap.From != 4 || last.Gap.To != 4 || last.Gap.Dropped != 1 {
+		t.Errorf("Expected a trailing gap for sequence 4, got %+v", last)
+	}
+
+	stats, _ := hub.GetTopicStats("prices")
+	if stats.DroppedCount != 3 {
+		t.Errorf("Expected 3 dropped deliveries, got %d", stats.DroppedCount)
+	}
+}
+
+func TestMaxLatencySetsDeadlineOnLiveEvents(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("prices")
+
+	client := newTestClient(hub)
+	client.subscriptions["prices"] = true
+	client.options["prices"] = subscriptionOptions{maxLatency: 250 * time.Millisecond}
+
+	msg := &PubSubMessage{Topic: "prices", Message: &MessageData{ID: "msg-1"}, Sequence: 7}
+	client.sendReplayEvent(msg)
+	client.sendEvent(msg)
+
+	frames, _ := client.queue.DrainFrames()
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	if !frames[0].deadline.IsZero() {
+		t.Error("Expected replayed events to have no deadline")
+	}
+	if frames[1].deadline.IsZero() || frames[1].sequence != 7 {
+		t.Errorf("Expected a live event with a deadline and sequence 7, got %+v", frames[1])
+	}
+}
+
+func TestSubscribeRejectsNegativeMaxLatency(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.handleMessage(&ClientMessage{
+		Type:       SubscribeMessage,
+		Topic:      "prices",
+		ClientID:   "dashboard",
+		RequestID:  "sub-1",
+		MaxLatency: -1,
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeBadRequest {
+		t.Fatalf("Expected a BAD_REQUEST error, got %+v", frames)
+	}
+	if client.IsSubscribed("prices") {
+		t.Error("Expected no subscription")
+	}
+}
+
+func TestTTLSetsDeadlineOnEvents(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("prices")
+
+	client := newTestClient(hub)
+	client.subscriptions["prices"] = true
+	client.options["prices"] = subscriptionOptions{maxLatency: time.Minute}
+
+	published := time.Now()
+	msg := &PubSubMessage{Topic: "prices", Timestamp: published, Message: &MessageData{ID: "msg-1", TTLMs: 500}, Sequence: 7}
+	client.sendReplayEvent(msg)
+	client.sendEvent(msg)
+
+	frames, _ := client.queue.DrainFrames()
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 frames, got %d", len(frames))
+	}
+	expiresAt := published.Add(500 * time.Millisecond)
+	for i, frame := range frames {
+		// The TTL is sooner than the max_latency, so it wins for both
+		if !frame.deadline.Equal(expiresAt) {
+			t.Errorf("Frame %d: expected the TTL as deadline, got %v", i, frame.deadline)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"plivo/internal/logging"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeaseExpiredInfo is the info frame sent when a leased subscription
+// expires for want of renewal
+const LeaseExpiredInfo = "lease_expired"
+
+// Lease duration bounds
+const (
+	MinLease = time.Second
+	MaxLease = time.Hour
+)
+
+// LeaseInfo describes a subscription lease. Subscribe acks carry the lease
+// granted; pongs that renewed leases carry the token and when the first
+// renewed subscription next expires.
+type LeaseInfo struct {
+	// Token renews the client's leased subscriptions when sent as the lease
+	// of a ping frame
+	Token string `json:"token"`
+	// DurationMs is the subscription's lease, set on subscribe acks
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// ExpiresAt is when the lease runs out unless renewed
+	ExpiresAt time.Time `json:"expires_at"`
+	// Renewed is how many subscriptions a ping renewed, set on pongs
+	Renewed int `json:"renewed,omitempty"`
+}
+
+// validateLease checks a subscription's requested lease in milliseconds;
+// 0 asks for no lease
+func validateLease(leaseMs int64) error {
+	lease := time.Duration(leaseMs) * time.Millisecond
+	if leaseMs != 0 && (lease < MinLease || lease > MaxLease) {
+		return fmt.Errorf("lease_ms must be between %d and %d", MinLease.Milliseconds(), MaxLease.Milliseconds())
+	}
+	return nil
+}
+
+// grantLease leases a subscription for its duration from now and returns
+// the lease granted, or nil for subscriptions without one. The client's
+// lease token is created with its first leased subscription and lasts as
+// long as the connection. Caller must hold c.mu.
+func (c *Client) grantLease(topic string, now time.Time) *LeaseInfo {
+	opts := c.options[topic]
+	if opts.lease == 0 {
+		return nil
+	}
+	if c.leaseToken == "" {
+		c.leaseToken = uuid.New().String()
+	}
+	opts.leaseExpires = now.Add(opts.lease)
+	c.options[topic] = opts
+	c.armLeaseTimer(now)
+
+	return &LeaseInfo{Token: c.leaseToken, DurationMs: opts.lease.Milliseconds(), ExpiresAt: opts.leaseExpires}
+}
+
+// renewLeases extends every leased subscription by its duration from now.
+// It fails for a token that isn't the client's.
+func (c *Client) renewLeases(token string) (*LeaseInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.leaseToken == "" || token != c.leaseToken {
+		return nil, fmt.Errorf("unknown lease token")
+	}
+
+	now := c.hub.clock.Now()
+	info := &LeaseInfo{Token: token}
+	for topic, opts := range c.options {
+		if opts.lease == 0 {
+			continue
+		}
+		opts.leaseExpires = now.Add(opts.lease)
+		c.options[topic] = opts
+		if info.Renewed == 0 || opts.leaseExpires.Before(info.ExpiresAt) {
+			info.ExpiresAt = opts.leaseExpires
+		}
+		info.Renewed++
+	}
+	c.armLeaseTimer(now)
+	return info, nil
+}
+
+// armLeaseTimer schedules expireLeases for the earliest lease expiry, or
+// stops the timer when no subscription is leased. Caller must hold c.mu.
+func (c *Client) armLeaseTimer(now time.Time) {
+	var next time.Time
+	for _, opts := range c.options {
+		if opts.lease > 0 && (next.IsZero() || opts.leaseExpires.Before(next)) {
+			next = opts.leaseExpires
+		}
+	}
+
+	switch {
+	case next.IsZero():
+		if c.leaseTimer != nil {
+			c.leaseTimer.Stop()
+		}
+	case c.leaseTimer == nil:
+		c.leaseTimer = c.hub.clock.AfterFunc(next.Sub(now), c.expireLeases)
+	default:
+		c.leaseTimer.Reset(next.Sub(now))
+	}
+}
+
+// expireLeases unsubscribes the client from every subscription whose lease
+// ran out and tells it with a lease_expired info frame per topic, which a
+// client whose network recovers can act on by resubscribing
+func (c *Client) expireLeases() {
+	defer func() {
+		if r := recover(); r != nil {
+			c.hub.RecordPanic("client.expireLeases", r, logging.ClientID, c.id)
+		}
+	}()
+
+	if c.queue.Closed() {
+		return
+	}
+
+	now := c.hub.clock.Now()
+	var expired []string
+	c.mu.Lock()
+	for topic, opts := range c.options {
+		if opts.lease > 0 && !now.Before(opts.leaseExpires) {
+			delete(c.subscriptions, topic)
+			delete(c.options, topic)
+			c.endReplayDedup(topic)
+			expired = append(expired, topic)
+		}
+	}
+	c.armLeaseTimer(now)
+	c.mu.Unlock()
+
+	for _, topic := range expired {
+		select {
+		case c.hub.unsubscribe <- &Subscription{client: c, topic: topic}:
+		case <-c.hub.shutdown:
+			return
+		}
+		c.hub.leaseExpiries.Add(1)
+		c.sendWithBackpressure("", c.hub.createLeaseExpiredMessageBytes(topic))
+	}
+}
+
+// createLeaseExpiredMessageBytes creates the info frame telling a client
+// its subscription to a topic expired
+func (h *Hub) createLeaseExpiredMessageBytes(topic string) []byte {
+	msg := ServerMessage{
+		Type:  InfoMessage,
+		Topic: topic,
+		Msg:   LeaseExpiredInfo,
+		TS:    h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// stopLeases stops the client's lease timer once it disconnects
+func (c *Client) stopLeases() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leaseTimer != nil {
+		c.leaseTimer.Stop()
+	}
+}
@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeasedSubscriptionIsRenewedByPings(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "leased", LeaseMs: 1000})
+	waitForSubscribers(t, hub, "orders", 1)
+	defer client.stopLeases()
+
+	frames := drainFrames(t, client)
+	if len(frames) == 0 || frames[0].Subscription == nil || frames[0].Subscription.Lease == nil {
+		t.Fatalf("Expected a subscribe ack carrying the lease, got %+v", frames)
+	}
+	lease := frames[0].Subscription.Lease
+	if lease.Token == "" || lease.DurationMs != 1000 {
+		t.Errorf("Expected a 1000ms lease with a token, got %+v", lease)
+	}
+
+	client.handleMessage(&ClientMessage{Type: PingMessage, RequestID: "renew", Lease: lease.Token})
+	frames = drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != PongMessage || frames[0].Lease == nil || frames[0].Lease.Renewed != 1 {
+		t.Fatalf("Expected a pong renewing 1 subscription, got %+v", frames)
+	}
+	if frames[0].Lease.ExpiresAt.Before(lease.ExpiresAt) {
+		t.Errorf("Expected the renewal to push the expiry out, got %v", frames[0].Lease.ExpiresAt)
+	}
+
+	// Pings with someone else's token are refused
+	client.handleMessage(&ClientMessage{Type: PingMessage, RequestID: "stolen", Lease: "not-mine"})
+	frames = drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != CodeBadRequest {
+		t.Errorf("Expected BAD_REQUEST for an unknown lease token, got %+v", frames)
+	}
+}
+
+func TestUnrenewedLeaseExpires(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("payments")
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "leased", LeaseMs: 1000})
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "payments", ClientID: "leased"})
+	waitForSubscribers(t, hub, "orders", 1)
+	waitForSubscribers(t, hub, "payments", 1)
+	drainFrames(t, client)
+
+	// Expire the lease rather than wait it out
+	client.mu.Lock()
+	opts := client.options["orders"]
+	opts.leaseExpires = time.Now()
+	client.options["orders"] = opts
+	client.armLeaseTimer(time.Now())
+	client.mu.Unlock()
+
+	waitForSubscribers(t, hub, "orders", 0)
+	if hub.GetTopics()["payments"].SubscriberCount != 1 {
+		t.Error("Expected the subscription without a lease kept")
+	}
+
+	var frames []ServerMessage
+	deadline := time.Now().Add(time.Second)
+	for len(frames) == 0 && time.Now().Before(deadline) {
+		frames = drainFrames(t, client)
+		time.Sleep(time.Millisecond)
+	}
+	if len(frames) != 1 || frames[0].Type != InfoMessage || frames[0].Msg != LeaseExpiredInfo || frames[0].Topic != "orders" {
+		t.Fatalf("Expected a lease_expired info frame for orders, got %+v", frames)
+	}
+	if stats := hub.GetStats(); stats.LeaseExpiries != 1 {
+		t.Errorf("Expected 1 lease expiry counted, got %d", stats.LeaseExpiries)
+	}
+}
+
+func TestLeaseBounds(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	client := newTestClient(hub)
+
+	for _, leaseMs := range []int64{-1, MinLease.Milliseconds() - 1, MaxLease.Milliseconds() + 1} {
+		client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "leased", LeaseMs: leaseMs})
+		frames := drainFrames(t, client)
+		if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != CodeBadRequest {
+			t.Errorf("Expected BAD_REQUEST for lease_ms %d, got %+v", leaseMs, frames)
+		}
+	}
+}
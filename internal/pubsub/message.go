@@ -1,16 +1,23 @@
 package pubsub
 
-import "time"
+import (
+	"encoding/json"
+	"plivo/internal/version"
+	"time"
+)
 
 // MessageType represents different types of WebSocket messages
 type MessageType string
 
 const (
 	// Client to Server
-	PublishMessage     MessageType = "publish"
-	SubscribeMessage   MessageType = "subscribe"
-	UnsubscribeMessage MessageType = "unsubscribe"
-	PingMessage        MessageType = "ping"
+	PublishMessage      MessageType = "publish"
+	PublishBatchMessage MessageType = "publish_batch"
+	SubscribeMessage    MessageType = "subscribe"
+	UnsubscribeMessage  MessageType = "unsubscribe"
+	PingMessage         MessageType = "ping"
+	StateSetMessage     MessageType = "state_set"
+	StateGetMessage     MessageType = "state_get"
 
 	// Server to Client
 	AckMessage   MessageType = "ack"
@@ -18,22 +25,108 @@ const (
 	ErrorMessage MessageType = "error"
 	PongMessage  MessageType = "pong"
 	InfoMessage  MessageType = "info"
+	// StateMessage carries a topic's state, to new subscribers and in reply
+	// to state_get; StateUpdateMessage carries a change to it
+	StateMessage       MessageType = "state"
+	StateUpdateMessage MessageType = "state_update"
 )
 
+// Reserved system topics
+const (
+	// SystemTopicPrefix marks topics owned by the broker itself
+	SystemTopicPrefix = "$SYS/"
+
+	// EchoTopic delivers every published message straight back to its publisher
+	EchoTopic = SystemTopicPrefix + "echo"
+
+	// ErrorsTopic carries an event for every error frame sent to a client
+	ErrorsTopic = SystemTopicPrefix + "errors"
+
+	// QuotaTopic carries an event whenever a client or publisher exceeds a quota
+	QuotaTopic = SystemTopicPrefix + "quota"
+
+	// GroupsTopic carries an event whenever an idle consumer group expires
+	GroupsTopic = SystemTopicPrefix + "groups"
+
+	// RetentionTopic carries an event as retained messages approach and
+	// reach the retention budget
+	RetentionTopic = SystemTopicPrefix + "retention"
+
+	// AlertsTopic carries an event when a topic's publish rate, subscriber
+	// count or drop rate changes abruptly
+	AlertsTopic = SystemTopicPrefix + "alerts"
+
+	// HealthTopic carries an event when storage fails and when it recovers
+	HealthTopic = SystemTopicPrefix + "health"
+)
+
+// IsSystemTopic reports whether a topic name is reserved for the broker
+func IsSystemTopic(topic string) bool {
+	return len(topic) >= len(SystemTopicPrefix) && topic[:len(SystemTopicPrefix)] == SystemTopicPrefix
+}
+
 // ClientMessage represents incoming WebSocket messages from clients
 type ClientMessage struct {
-	Type      MessageType  `json:"type"`
-	Topic     string       `json:"topic,omitempty"`
-	Message   *MessageData `json:"message,omitempty"`
-	ClientID  string       `json:"client_id,omitempty"`
-	LastN     int          `json:"last_n,omitempty"`
-	RequestID string       `json:"request_id,omitempty"`
+	Type    MessageType  `json:"type"`
+	Topic   string       `json:"topic,omitempty"`
+	Message *MessageData `json:"message,omitempty"`
+	// Messages are published together, all or none (publish_batch only)
+	Messages  []*MessageData `json:"messages,omitempty"`
+	ClientID  string         `json:"client_id,omitempty"`
+	LastN     int            `json:"last_n,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	// Fields projects delivered event payloads to the listed keys (subscribe only)
+	Fields []string `json:"fields,omitempty"`
+	// Filter delivers only events carrying these header values; a value
+	// "$name" stands for the connection's attribute name (subscribe only)
+	Filter map[string]string `json:"filter,omitempty"`
+	// Where delivers only events whose content meets the expression, such
+	// as payload.status == "shipped" (subscribe only)
+	Where string `json:"where,omitempty"`
+	// Group names the consumer group whose offset the subscription tracks (subscribe only)
+	Group string `json:"group,omitempty"`
+	// KeyID is the key ID presented to subscribe to an encrypted topic (subscribe only)
+	KeyID string `json:"key_id,omitempty"`
+	// MaxLatency is how long, in milliseconds, a live event may wait in the
+	// send queue before it is dropped for a gap notice (subscribe only, 0 = no limit)
+	MaxLatency int64 `json:"max_latency,omitempty"`
+	// LeaseMs leases the subscription for this many milliseconds: unless a
+	// ping renews it in time, it expires server-side (subscribe only, 0 = no lease)
+	LeaseMs int64 `json:"lease_ms,omitempty"`
+	// EchoSelf set to false skips events the client published itself on
+	// the connection (subscribe only, default true)
+	EchoSelf *bool `json:"echo_self,omitempty"`
+	// Lease is the lease token a ping renews the client's leased
+	// subscriptions with (ping only)
+	Lease string `json:"lease,omitempty"`
+	// Data is echoed back unchanged in the pong, so clients can time round
+	// trips or match pongs to pings (ping only, up to MaxPingData bytes)
+	Data json.RawMessage `json:"data,omitempty"`
+	// Key is the topic state key to set or get (state_set and state_get)
+	Key string `json:"key,omitempty"`
+	// Value is the state key's new value; null or missing deletes the key
+	// (state_set only)
+	Value json.RawMessage `json:"value,omitempty"`
+	// Ephemeral removes the state key when the connection closes
+	// (state_set only)
+	Ephemeral bool `json:"ephemeral,omitempty"`
 }
 
 // MessageData represents the message payload structure
 type MessageData struct {
 	ID      string      `json:"id"`
 	Payload interface{} `json:"payload"`
+	// Headers are publisher-supplied string attributes delivered with the event
+	Headers map[string]string `json:"headers,omitempty"`
+	// TTLMs is the publisher's requested time to live in milliseconds (0 = none)
+	TTLMs int64 `json:"ttl_ms,omitempty"`
+	// ContentType says how to decode the payload: application/json (the
+	// default), text/plain for a string, or application/octet-stream for
+	// base64-encoded bytes
+	ContentType string `json:"content_type,omitempty"`
+	// Key routes the message to a partition on partitioned topics; messages
+	// with the same key go to the same consumer group member
+	Key string `json:"key,omitempty"`
 }
 
 // ServerMessage represents outgoing WebSocket messages to clients
@@ -44,19 +137,129 @@ type ServerMessage struct {
 	Message   *MessageData `json:"message,omitempty"`
 	Error     *ErrorData   `json:"error,omitempty"`
 	Status    string       `json:"status,omitempty"`
-	Msg       string       `json:"msg,omitempty"`
-	TS        string       `json:"ts"`
+	// ID of the published message, set on publish acks
+	MessageID string `json:"message_id,omitempty"`
+	// Outcome of each message of a publish batch, in order, set on
+	// publish_batch acks
+	Results []BatchResult `json:"results,omitempty"`
+	Msg     string        `json:"msg,omitempty"`
+	TS      string        `json:"ts"`
+	// Server receive time (RFC3339Nano), set on events; $SYS/echo events
+	// also carry the delivery time
+	ReceivedAt  string `json:"received_at,omitempty"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+	// Server build information, set on the welcome info frame
+	Server *version.Info `json:"server,omitempty"`
+	// The client's private reply topic, set on the welcome info frame
+	Inbox string `json:"inbox,omitempty"`
+	// Per-topic sequence number assigned when the event was published
+	Sequence int64 `json:"sequence,omitempty"`
+	// Per-subscriber count of live events on the topic, set in ordering audit mode
+	AuditSeq int64 `json:"audit_seq,omitempty"`
+	// Schema version the event's payload validated against
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Topic delivery state, set on subscribe acks
+	Subscription *SubscriptionInfo `json:"subscription,omitempty"`
+	// Topic replacing a draining one, set on topic_draining and
+	// topic_migrated info frames
+	Replacement string `json:"replacement,omitempty"`
+	// Events dropped for missing the subscription's max_latency, set on gap
+	// info frames
+	Gap *GapInfo `json:"gap,omitempty"`
+	// Leases a ping renewed, set on pongs
+	Lease *LeaseInfo `json:"lease,omitempty"`
+	// Data the ping asked to have echoed, set on pongs
+	Data json.RawMessage `json:"data,omitempty"`
+	// Partition of a partitioned topic the event belongs to
+	Partition *int `json:"partition,omitempty"`
+	// Partitions a consumer group member owns, set on rebalance info frames
+	Assignment *PartitionAssignment `json:"assignment,omitempty"`
+	// Pause in effect, set on hub_paused info frames
+	Pause *PauseInfo `json:"pause,omitempty"`
+	// Topic state by key, set on state frames
+	State map[string]StateEntry `json:"state,omitempty"`
+	// Change to the topic state, set on state_update frames
+	StateUpdate *StateUpdate `json:"update,omitempty"`
+}
+
+// SubscriptionInfo describes a topic's delivery state at subscribe time, so
+// clients can size buffers and track progress while catching up
+type SubscriptionInfo struct {
+	// Sequence is the topic's current sequence number (messages published so far)
+	Sequence int64 `json:"sequence"`
+	// Retained is how many messages the topic holds for replay
+	Retained int `json:"retained"`
+	// Replaying is how many retained messages will follow the ack as events
+	Replaying int `json:"replaying"`
+	// Offset is the consumer group's offset when the member joined
+	Offset int64 `json:"offset,omitempty"`
+	// Capped is set when last_n was reduced to the topic's max_last_n
+	Capped bool `json:"capped,omitempty"`
+	// Lease is the lease granted to a subscription made with lease_ms
+	Lease *LeaseInfo `json:"lease,omitempty"`
 }
 
 // ErrorData represents error information
 type ErrorData struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Limit is the exceeded limit, set on MESSAGE_TOO_LARGE errors
+	Limit int64 `json:"limit,omitempty"`
+	// Replacement is the topic to subscribe to instead, set on
+	// TOPIC_DRAINING errors
+	Replacement string `json:"replacement,omitempty"`
+	// RetryAfterMs is how long until the caller may try again, set on
+	// RATE_LIMITED errors
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+	// Results says which messages of a rejected publish batch were invalid
+	Results []BatchResult `json:"results,omitempty"`
 }
 
 // PubSubMessage represents a message being published to a topic
 type PubSubMessage struct {
-	Topic     string       `json:"topic"`
-	Message   *MessageData `json:"message"`
-	Timestamp time.Time    `json:"timestamp"`
+	Topic   string       `json:"topic"`
+	Message *MessageData `json:"message"`
+	// Timestamp is the authoritative time the server received the publish
+	Timestamp time.Time `json:"timestamp"`
+	// Sequence is the message's position in its topic, starting at 1
+	Sequence int64 `json:"sequence"`
+	// SchemaVersion is the topic schema version the payload validated against
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// Partition is the message's partition on partitioned topics
+	Partition *int `json:"partition,omitempty"`
+	// keyID is the encrypted topic's key ID when the message was published
+	keyID string
+	// publisher identifies who published the message, for enriched topics
+	publisher string
+	// size is the encoded payload's length in bytes, measured when the
+	// message is validated; 0 until measured
+	size int
+	// frames caches the message's encoded event frames once it has been
+	// published, nil for messages built for a single delivery. Copies that
+	// change what is delivered must drop it.
+	frames *eventFrames
+}
+
+// payloadBytes returns the encoded payload's length in bytes, measuring it
+// only if validation didn't
+func (m *PubSubMessage) payloadBytes() int {
+	if m.size == 0 {
+		m.size = payloadSize(m.Message)
+	}
+	return m.size
+}
+
+// expiresAt returns when the message's TTL runs out, or the zero time for
+// messages without one
+func (m *PubSubMessage) expiresAt() time.Time {
+	if m.Message == nil || m.Message.TTLMs <= 0 {
+		return time.Time{}
+	}
+	return m.Timestamp.Add(time.Duration(m.Message.TTLMs) * time.Millisecond)
+}
+
+// expired reports whether the message's TTL has run out
+func (m *PubSubMessage) expired(now time.Time) bool {
+	expiresAt := m.expiresAt()
+	return !expiresAt.IsZero() && now.After(expiresAt)
 }
@@ -0,0 +1,340 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MessagePack support. Frames keep the JSON shape, field names included;
+// only the wire encoding changes, so a MessagePack frame decodes to the
+// same object as its JSON counterpart. The codec covers the JSON data
+// model: nil, booleans, integers, floats, strings, arrays and maps with
+// string keys. Binary values are read as base64 strings, as JSON carries
+// them; extension types are refused.
+
+// maxMsgpackDepth bounds how deeply decoded MessagePack values may nest
+const maxMsgpackDepth = 64
+
+// errMsgpackTruncated reports MessagePack data that ends mid-value
+var errMsgpackTruncated = errors.New("msgpack: unexpected end of data")
+
+// JSONToMsgpack re-encodes a JSON document as MessagePack. Map keys are
+// written in sorted order, as encoding/json writes them.
+func JSONToMsgpack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeMsgpack(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MsgpackToJSON re-encodes a MessagePack value as JSON
+func MsgpackToJSON(data []byte) ([]byte, error) {
+	d := msgpackDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return json.Marshal(value)
+}
+
+// encodeMsgpack writes a value decoded from JSON with UseNumber
+func encodeMsgpack(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		return encodeMsgpackNumber(buf, v)
+	case string:
+		encodeMsgpackString(buf, v)
+	case []interface{}:
+		encodeMsgpackHeader(buf, len(v), 0x90, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		encodeMsgpackHeader(buf, len(v), 0x80, 0xde, 0xdf)
+		for _, key := range keys {
+			encodeMsgpackString(buf, key)
+			if err := encodeMsgpack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", value)
+	}
+	return nil
+}
+
+// encodeMsgpackNumber writes integers in the smallest integer format that
+// holds them and everything else as a float64
+func encodeMsgpackNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		encodeMsgpackInt(buf, i)
+		return nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("msgpack: invalid number %q", n)
+	}
+	buf.WriteByte(0xcb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	return nil
+}
+
+func encodeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(int16(i))))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+func encodeMsgpackString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	default:
+		encodeMsgpackHeader(buf, n, 0, 0xda, 0xdb)
+	}
+	buf.WriteString(s)
+}
+
+// encodeMsgpackHeader writes a length in its fixed format, below 16, when
+// fixed is set, and otherwise its 16 or 32-bit format
+func encodeMsgpackHeader(buf *bytes.Buffer, n int, fixed, format16, format32 byte) {
+	switch {
+	case fixed != 0 && n < 16:
+		buf.WriteByte(fixed | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(format16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(format32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+// msgpackDecoder reads MessagePack values into the types encoding/json
+// marshals
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, fmt.Errorf("msgpack: nested deeper than %d", maxMsgpackDepth)
+	}
+	b, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return d.string(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return d.object(int(b&0x0f), depth)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.take(n)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Clone(raw), nil
+	case 0xca:
+		raw, err := d.take(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+	case 0xcb:
+		raw, err := d.take(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		raw, err := d.take(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return bigEndianUint(raw), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		raw, err := d.take(1 << (b - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		u := bigEndianUint(raw)
+		// Sign-extend from the value's width
+		shift := 64 - 8*len(raw)
+		return int64(u<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.string(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.object(n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", b)
+}
+
+// length reads a 1, 2 or 4-byte length for size 0, 1 or 2
+func (d *msgpackDecoder) length(size byte) (int, error) {
+	raw, err := d.take(1 << size)
+	if err != nil {
+		return 0, err
+	}
+	n := bigEndianUint(raw)
+	if n > uint64(len(d.data)-d.pos) {
+		// Every element takes at least a byte, so longer can't be valid
+		return 0, errMsgpackTruncated
+	}
+	return int(n), nil
+}
+
+func (d *msgpackDecoder) string(n int) (string, error) {
+	raw, err := d.take(n)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func (d *msgpackDecoder) array(n, depth int) ([]interface{}, error) {
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) object(n, depth int) (map[string]interface{}, error) {
+	fields := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key is %T, not a string", key)
+		}
+		value, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = value
+	}
+	return fields, nil
+}
+
+func (d *msgpackDecoder) byte() (byte, error) {
+	raw, err := d.take(1)
+	if err != nil {
+		return 0, err
+	}
+	return raw[0], nil
+}
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackTruncated
+	}
+	raw := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return raw, nil
+}
+
+// bigEndianUint reads a 1, 2, 4 or 8-byte big-endian unsigned integer
+func bigEndianUint(raw []byte) uint64 {
+	var u uint64
+	for _, b := range raw {
+		u = u<<8 | uint64(b)
+	}
+	return u
+}
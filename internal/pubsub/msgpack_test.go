@@ -0,0 +1,93 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	docs := []string{
+		`null`,
+		`true`,
+		`{"a":1,"b":-1,"c":-33,"d":200,"e":-200,"f":70000,"g":-70000,"h":5000000000,"i":18446744073709551615}`,
+		`{"pi":3.25,"neg":-0.5,"big":1e300}`,
+		`{"s":"","long":"` + string(bytes.Repeat([]byte("x"), 300)) + `"}`,
+		`[1,[2,[3,{"deep":true}]],"x",null]`,
+		`{"type":"event","topic":"orders","message":{"id":"m1","payload":{"qty":3}},"sequence":42}`,
+	}
+	for _, doc := range docs {
+		encoded, err := JSONToMsgpack([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: encoding failed: %v", doc, err)
+		}
+		decoded, err := MsgpackToJSON(encoded)
+		if err != nil {
+			t.Fatalf("%s: decoding failed: %v", doc, err)
+		}
+		var want, got interface{}
+		json.Unmarshal([]byte(doc), &want)
+		json.Unmarshal(decoded, &got)
+		wantJSON, _ := json.Marshal(want)
+		gotJSON, _ := json.Marshal(got)
+		if !bytes.Equal(wantJSON, gotJSON) {
+			t.Errorf("Round trip changed %s into %s", wantJSON, gotJSON)
+		}
+	}
+}
+
+func TestMsgpackCompactsIntegers(t *testing.T) {
+	encoded, _ := JSONToMsgpack([]byte(`{"n":5}`))
+	// fixmap of 1, fixstr "n", positive fixint 5
+	if want := []byte{0x81, 0xa1, 'n', 0x05}; !bytes.Equal(encoded, want) {
+		t.Errorf("Expected % x, got % x", want, encoded)
+	}
+}
+
+func TestMsgpackRejectsInvalidData(t *testing.T) {
+	cases := map[string][]byte{
+		"truncated string": {0xa5, 'a'},
+		"oversized array":  {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"non-string key":   {0x81, 0x01, 0x02},
+		"extension type":   {0xd4, 0x01, 0x00},
+		"never used":       {0xc1},
+		"trailing bytes":   {0xc0, 0xc0},
+		"too deep":         bytes.Repeat([]byte{0x91}, maxMsgpackDepth+2),
+	}
+	for name, data := range cases {
+		if _, err := MsgpackToJSON(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMsgpackBinaryReadsAsBase64(t *testing.T) {
+	decoded, err := MsgpackToJSON([]byte{0xc4, 0x03, 'a', 'b', 'c'})
+	if err != nil || string(decoded) != `"YWJj"` {
+		t.Errorf("Expected base64 of the bytes, got %s (%v)", decoded, err)
+	}
+}
+
+func TestMsgpackEventFramesAreCachedPerEncoding(t *testing.T) {
+	hub := NewHub()
+	message := benchMessage("orders", 1, 16)
+	message.frames = &eventFrames{}
+
+	jsonFrame := hub.eventFrame(message, 0, encodingJSON)
+	packed := hub.eventFrame(message, 0, encodingMsgpack)
+	if &packed[0] != &hub.eventFrame(message, 0, encodingMsgpack)[0] {
+		t.Error("Expected the MessagePack frame cached")
+	}
+	decoded, err := MsgpackToJSON(packed)
+	if err != nil {
+		t.Fatalf("Decoding failed: %v", err)
+	}
+	var want, got interface{}
+	json.Unmarshal(jsonFrame, &want)
+	json.Unmarshal(decoded, &got)
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(wantJSON, gotJSON) || len(packed) >= len(jsonFrame) {
+		t.Errorf("Expected a smaller frame with the same content, got %d bytes for %d", len(packed), len(jsonFrame))
+	}
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxOwnerLength is the longest accepted topic owner in bytes
+const MaxOwnerLength = 128
+
+// validateOwner checks a topic owner's tenant name
+func validateOwner(owner string) error {
+	switch {
+	case owner == "":
+		return fmt.Errorf("%w: must not be empty", ErrInvalidOwner)
+	case len(owner) > MaxOwnerLength:
+		return fmt.Errorf("%w: exceeds %d bytes", ErrInvalidOwner, MaxOwnerLength)
+	case strings.IndexFunc(owner, unicode.IsControl) >= 0:
+		return fmt.Errorf("%w: must not contain control characters", ErrInvalidOwner)
+	}
+	return nil
+}
+
+// TopicOwner returns the tenant that owns a topic, "" if it is unowned.
+// Deleted topics that can still be restored keep their owner.
+func (h *Hub) TopicOwner(name string) (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if topic, exists := h.topics[name]; exists {
+		return topic.owner, nil
+	}
+	if entry := h.trashed(name); entry != nil {
+		return entry.topic.owner, nil
+	}
+	return "", ErrTopicNotFound
+}
+
+// TransferTopic hands a topic to a new owner and returns the previous one.
+// Callers check that the requester may transfer it.
+func (h *Hub) TransferTopic(name, owner string) (string, error) {
+	if err := validateOwner(owner); err != nil {
+		return "", err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic, exists := h.topics[name]
+	if !exists {
+		return "", ErrTopicNotFound
+	}
+	previous := topic.owner
+	if owner != previous {
+		if err := h.checkTenantQuota(owner, name); err != nil {
+			return "", err
+		}
+	}
+	topic.owner = owner
+	topic.revision++
+	h.persist("transfer topic", func(s Storage) error { return s.SaveTopic(topic.snapshot()) })
+	return previous, nil
+}
@@ -0,0 +1,33 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransferTopic(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("invoices", TopicOptions{Owner: "payments"})
+
+	previous, err := hub.TransferTopic("invoices", "billing")
+	if err != nil || previous != "payments" {
+		t.Fatalf("Expected transfer from payments, got %q, %v", previous, err)
+	}
+	if owner, _ := hub.TopicOwner("invoices"); owner != "billing" {
+		t.Errorf("Expected owner billing, got %q", owner)
+	}
+
+	if _, err := hub.TransferTopic("invoices", ""); !errors.Is(err, ErrInvalidOwner) {
+		t.Errorf("Expected ErrInvalidOwner for an empty owner, got %v", err)
+	}
+	if _, err := hub.TransferTopic("missing", "billing"); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+
+	// Ownership survives a snapshot round trip
+	restored := NewHub()
+	restored.Restore(hub.Snapshot())
+	if owner, _ := restored.TopicOwner("invoices"); owner != "billing" {
+		t.Errorf("Expected the restored topic to be owned by billing, got %q", owner)
+	}
+}
@@ -0,0 +1,218 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"time"
+)
+
+// RebalanceInfo is the info frame sent to consumer group members of a
+// partitioned topic whose partitions changed
+const RebalanceInfo = "rebalance"
+
+// MaxPartitions bounds how many partitions a topic may be split into
+const MaxPartitions = 256
+
+// Partition assignment strategies
+const (
+	// AssignRange gives each member a contiguous run of partitions
+	AssignRange = "range"
+	// AssignRoundRobin deals partitions out to members in turn
+	AssignRoundRobin = "round_robin"
+)
+
+// Partitioning splits a topic into partitions by message key. Each consumer
+// group member owns a share of the partitions and receives every event in
+// them, so all events with one key go to the same member.
+type Partitioning struct {
+	// Partitions is how many partitions the topic has
+	Partitions int `json:"partitions"`
+	// Assignment is how partitions are shared among a group's members:
+	// range (the default) or round_robin
+	Assignment string `json:"assignment,omitempty"`
+}
+
+// Validate checks the partition count and assignment strategy
+func (p *Partitioning) Validate() error {
+	if p.Partitions < 1 || p.Partitions > MaxPartitions {
+		return fmt.Errorf("%w: partitions must be between 1 and %d", ErrInvalidPartitioning, MaxPartitions)
+	}
+	switch p.Assignment {
+	case "", AssignRange, AssignRoundRobin:
+		return nil
+	}
+	return fmt.Errorf("%w: assignment must be %s or %s", ErrInvalidPartitioning, AssignRange, AssignRoundRobin)
+}
+
+// strategy returns the assignment strategy, range unless set
+func (p *Partitioning) strategy() string {
+	if p.Assignment == "" {
+		return AssignRange
+	}
+	return p.Assignment
+}
+
+// partitionOf returns the partition a message belongs to: keyed messages by
+// a hash of their key, keyless ones spread by sequence
+func (p *Partitioning) partitionOf(message *PubSubMessage) int {
+	if message.Message != nil && message.Message.Key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(message.Message.Key))
+		return int(hash.Sum32() % uint32(p.Partitions))
+	}
+	return int(message.Sequence % int64(p.Partitions))
+}
+
+// assign returns the owner of each partition among members sorted by client
+// ID. Groups with more members than partitions leave some members idle.
+func (p *Partitioning) assign(members []*Client) []*Client {
+	if len(members) == 0 {
+		return nil
+	}
+	owners := make([]*Client, p.Partitions)
+	for partition := range owners {
+		if p.strategy() == AssignRoundRobin {
+			owners[partition] = members[partition%len(members)]
+		} else {
+			owners[partition] = members[partition*len(members)/p.Partitions]
+		}
+	}
+	return owners
+}
+
+// PartitionAssignment is a consumer group member's share of a partitioned
+// topic, carried by rebalance info frames
+type PartitionAssignment struct {
+	Group string `json:"group"`
+	// Generation counts the group's rebalances; an assignment from an
+	// older generation is stale
+	Generation int64  `json:"generation"`
+	Strategy   string `json:"strategy"`
+	// Partitions are the partitions the member now owns, empty when the
+	// group has more members than partitions
+	Partitions []int `json:"partitions"`
+	// Members is how many members the partitions were shared among
+	Members int `json:"members"`
+}
+
+// ownedPartitions lists the partitions a member owns
+func ownedPartitions(owners []*Client, member *Client) []int {
+	partitions := []int{}
+	for partition, owner := range owners {
+		if owner == member {
+			partitions = append(partitions, partition)
+		}
+	}
+	return partitions
+}
+
+// sortMembers orders a group's members by client ID, the order partitions
+// and round-robin turns are handed out in
+func sortMembers(members []*Client) {
+	sort.Slice(members, func(i, j int) bool { return members[i].id < members[j].id })
+}
+
+// rebalanceNotice is a rebalance info frame for one group member
+type rebalanceNotice struct {
+	client *Client
+	data   []byte
+}
+
+// rebalance reassigns a partitioned topic's partitions among each consumer
+// group's connected members after members join or leave, and returns the
+// rebalance frames for members that joined or whose partitions changed.
+// Caller must hold the hub write lock and send the frames once it is
+// released.
+func (h *Hub) rebalance(topicName string) []rebalanceNotice {
+	topic, exists := h.topics[topicName]
+	if !exists || topic.partitioning == nil {
+		return nil
+	}
+
+	groups := make(map[string][]*Client)
+	for client := range h.subscriptions[topicName] {
+		if group := client.subscriptionGroup(topicName); group != "" {
+			groups[group] = append(groups[group], client)
+		}
+	}
+	// Groups whose last member left give up their partitions
+	for group, cursor := range topic.groups {
+		if _, active := groups[group]; !active && cursor.members != nil {
+			groups[group] = nil
+		}
+	}
+
+	var notices []rebalanceNotice
+	for group, members := range groups {
+		sortMembers(members)
+		cursor := topic.groupCursor(group, h.clock.Now())
+		if slices.Equal(members, cursor.members) {
+			continue
+		}
+
+		previous, before := cursor.owners, cursor.members
+		cursor.owners = topic.partitioning.assign(members)
+		cursor.members = members
+		cursor.generation++
+
+		for _, member := range members {
+			partitions := ownedPartitions(cursor.owners, member)
+			if slices.Contains(before, member) && slices.Equal(partitions, ownedPartitions(previous, member)) {
+				continue
+			}
+			notices = append(notices, rebalanceNotice{
+				client: member,
+				data: h.createRebalanceMessageBytes(topicName, &PartitionAssignment{
+					Group:      group,
+					Generation: cursor.generation,
+					Strategy:   topic.partitioning.strategy(),
+					Partitions: partitions,
+					Members:    len(members),
+				}),
+			})
+		}
+	}
+	return notices
+}
+
+// sendRebalances sends the frames rebalance returned
+func sendRebalances(notices []rebalanceNotice) {
+	for _, notice := range notices {
+		notice.client.sendWithBackpressure("", notice.data)
+	}
+}
+
+// partitionOwner returns the group member owning a message's partition, or
+// nil when the topic isn't partitioned or the group hasn't been assigned
+// partitions among these members. Caller must hold the hub write lock.
+func (t *Topic) partitionOwner(group string, message *PubSubMessage, members []*Client) *Client {
+	if t.partitioning == nil || message.Partition == nil {
+		return nil
+	}
+	cursor := t.groups[group]
+	if cursor == nil || *message.Partition >= len(cursor.owners) {
+		return nil
+	}
+	if owner := cursor.owners[*message.Partition]; slices.Contains(members, owner) {
+		return owner
+	}
+	return nil
+}
+
+// createRebalanceMessageBytes creates the info frame telling a group member
+// which partitions it owns
+func (h *Hub) createRebalanceMessageBytes(topic string, assignment *PartitionAssignment) []byte {
+	msg := ServerMessage{
+		Type:       InfoMessage,
+		Topic:      topic,
+		Msg:        RebalanceInfo,
+		Assignment: assignment,
+		TS:         h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
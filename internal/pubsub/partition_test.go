@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPartitionAssignmentStrategies(t *testing.T) {
+	members := []*Client{{id: "a"}, {id: "b"}, {id: "c"}}
+
+	tests := []struct {
+		strategy string
+		want     [][]int
+	}{
+		{AssignRange, [][]int{{0, 1, 2}, {3, 4, 5}, {6, 7}}},
+		{AssignRoundRobin, [][]int{{0, 3, 6}, {1, 4, 7}, {2, 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			owners := (&Partitioning{Partitions: 8, Assignment: tt.strategy}).assign(members)
+			for i, member := range members {
+				if got := ownedPartitions(owners, member); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("Expected %s to own %v, got %v", member.id, tt.want[i], got)
+				}
+			}
+		})
+	}
+
+	// Members beyond the partition count own none
+	owners := (&Partitioning{Partitions: 2}).assign(members)
+	if got := ownedPartitions(owners, members[2]); len(got) != 0 {
+		t.Errorf("Expected the idle member to own no partitions, got %v", got)
+	}
+
+	for _, invalid := range []Partitioning{{Partitions: 0}, {Partitions: MaxPartitions + 1}, {Partitions: 4, Assignment: "sticky"}} {
+		if err := invalid.Validate(); !errors.Is(err, ErrInvalidPartitioning) {
+			t.Errorf("Expected ErrInvalidPartitioning for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+// rebalanceFrames returns the partitions announced in a client's rebalance
+// info frames
+func rebalanceFrames(t *testing.T, client *Client) []*PartitionAssignment {
+	t.Helper()
+
+	var assignments []*PartitionAssignment
+	for _, frame := range drainFrames(t, client) {
+		if frame.Type == InfoMessage && frame.Msg == RebalanceInfo {
+			assignments = append(assignments, frame.Assignment)
+		}
+	}
+	return assignments
+}
+
+func TestGroupRebalancesAsMembersJoinAndLeave(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Partitioning: &Partitioning{Partitions: 4}})
+
+	join := func(id string) *Client {
+		client := newTestClient(hub)
+		client.id = id
+		client.options["orders"] = subscriptionOptions{group: "billing"}
+		hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+		return client
+	}
+
+	first := join("worker-1")
+	if got := rebalanceFrames(t, first); len(got) != 1 || !slices.Equal(got[0].Partitions, []int{0, 1, 2, 3}) || got[0].Generation != 1 {
+		t.Fatalf("Expected the first member to own every partition, got %+v", got)
+	}
+
+	second := join("worker-2")
+	if got := rebalanceFrames(t, first); len(got) != 1 || !slices.Equal(got[0].Partitions, []int{0, 1}) || got[0].Generation != 2 {
+		t.Errorf("Expected the first member to keep partitions 0-1, got %+v", got)
+	}
+	if got := rebalanceFrames(t, second); len(got) != 1 || !slices.Equal(got[0].Partitions, []int{2, 3}) || got[0].Members != 2 {
+		t.Errorf("Expected the second member to take partitions 2-3, got %+v", got)
+	}
+
+	// Every event with one key goes to the member owning its partition
+	for i := 1; i <= 6; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i), Key: "customer-42"}})
+	}
+	firstEvents, secondEvents := drainFrames(t, first), drainFrames(t, second)
+	if len(firstEvents)+len(secondEvents) != 6 || (len(firstEvents) != 0 && len(secondEvents) != 0) {
+		t.Fatalf("Expected all 6 keyed events at one member, got %d and %d", len(firstEvents), len(secondEvents))
+	}
+	events := append(firstEvents, secondEvents...)
+	if events[0].Partition == nil || *events[0].Partition != *events[5].Partition {
+		t.Errorf("Expected every event in one partition, got %v and %v", events[0].Partition, events[5].Partition)
+	}
+
+	offset, _ := hub.GetGroupOffset("orders", "billing")
+	if offset.Generation != 2 || !slices.Equal(offset.Partitions["worker-2"], []int{2, 3}) {
+		t.Errorf("Expected the group offset to report the assignment, got %+v", offset)
+	}
+
+	// The remaining member takes over the partitions of one that leaves
+	hub.unsubscribeClient(&Subscription{client: second, topic: "orders"})
+	if got := rebalanceFrames(t, first); len(got) != 1 || !slices.Equal(got[0].Partitions, []int{0, 1, 2, 3}) || got[0].Generation != 3 {
+		t.Errorf("Expected the first member to own every partition again, got %+v", got)
+	}
+}
+
+func TestUnchangedMembersAreNotNotified(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Partitioning: &Partitioning{Partitions: 2}})
+
+	var members []*Client
+	for i := 1; i <= 3; i++ {
+		client := newTestClient(hub)
+		client.id = fmt.Sprintf("worker-%d", i)
+		client.options["orders"] = subscriptionOptions{group: "billing"}
+		hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+		members = append(members, client)
+	}
+	for _, member := range members {
+		drainFrames(t, member)
+	}
+
+	// worker-1 and worker-2 own the 2 partitions; worker-3 idles, so its
+	// leaving changes nobody's partitions
+	hub.unsubscribeClient(&Subscription{client: members[2], topic: "orders"})
+	for _, member := range members[:2] {
+		if got := rebalanceFrames(t, member); len(got) != 0 {
+			t.Errorf("Expected %s not notified, got %+v", member.id, got)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Pause modes
+const (
+	// PauseBuffer keeps accepting publishes but holds them in their topics'
+	// backlogs until the hub resumes; once a backlog fills, publishes to it
+	// fail as they would on a saturated hub
+	PauseBuffer = "buffer"
+	// PauseReject refuses publishes with HUB_PAUSED while the hub is paused
+	PauseReject = "reject"
+)
+
+// Info frame messages sent to every client when the hub pauses and resumes
+const (
+	// HubPausedInfo tells a client publishes are held or refused; the frame
+	// describes the pause
+	HubPausedInfo = "hub_paused"
+	// HubResumedInfo tells a client publishes are delivered again
+	HubResumedInfo = "hub_resumed"
+)
+
+// validatePauseMode checks a pause mode, where "" takes the hub's default
+func validatePauseMode(mode string) error {
+	switch mode {
+	case "", PauseBuffer, PauseReject:
+		return nil
+	default:
+		return fmt.Errorf("%w: mode must be %s or %s", ErrInvalidPause, PauseBuffer, PauseReject)
+	}
+}
+
+// PauseOptions configures pausing the hub
+type PauseOptions struct {
+	// Mode is "buffer" or "reject" ("" = the hub's default)
+	Mode string `json:"mode,omitempty"`
+	// Reason is passed on to clients in the hub_paused frame
+	Reason string `json:"reason,omitempty"`
+	// TimeoutMs resumes the hub by itself after this many milliseconds, in
+	// case whoever paused it never does (0 = only when resumed)
+	TimeoutMs int64 `json:"timeout_ms,omitempty"`
+	// Compact rewrites the hub's storage from its current state once the
+	// pause has taken hold
+	Compact bool `json:"compact,omitempty"`
+}
+
+// Validate checks the mode and that the timeout is not negative
+func (o PauseOptions) Validate() error {
+	if err := validatePauseMode(o.Mode); err != nil {
+		return err
+	}
+	if o.TimeoutMs < 0 {
+		return fmt.Errorf("%w: timeout_ms must not be negative", ErrInvalidPause)
+	}
+	return nil
+}
+
+// PauseInfo describes a pause, as sent on hub_paused info frames
+type PauseInfo struct {
+	Mode   string    `json:"mode"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	// Until is when the hub resumes by itself, for pauses with a timeout
+	Until *time.Time `json:"until,omitempty"`
+	// Buffered is how many publishes are waiting for the hub to resume,
+	// set when the pause is reported rather than announced
+	Buffered int `json:"buffered"`
+}
+
+// pauseState is a pause in effect
+type pauseState struct {
+	info PauseInfo
+	// timer resumes the hub when the pause times out
+	timer Timer
+}
+
+// Pause stops the hub delivering publishes, or accepting them, until Resume
+// is called or the pause times out, and tells every client with an info
+// frame. Subscriptions, topic changes and everything else carry on. Pausing
+// a paused hub replaces the pause.
+func (h *Hub) Pause(opts PauseOptions) (*PauseInfo, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Mode == "" {
+		opts.Mode = h.pauseMode
+	}
+
+	state := &pauseState{info: PauseInfo{Mode: opts.Mode, Reason: opts.Reason, Since: h.clock.Now()}}
+	h.mu.Lock()
+	if h.pause != nil && h.pause.timer != nil {
+		h.pause.timer.Stop()
+	}
+	if opts.TimeoutMs > 0 {
+		timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
+		until := state.info.Since.Add(timeout)
+		state.info.Until = &until
+		state.timer = h.clock.AfterFunc(timeout, func() { h.resume(state) })
+	}
+	h.pause = state
+	info := state.info
+	h.mu.Unlock()
+
+	slog.Warn("Hub paused", "mode", info.Mode, "reason", info.Reason, "timeout_ms", opts.TimeoutMs)
+	h.notifyClients(h.createPauseInfoMessageBytes(HubPausedInfo, &info))
+
+	if opts.Compact {
+		if err := h.CompactStorage(); err != nil {
+			return &info, err
+		}
+	}
+	return &info, nil
+}
+
+// Resume ends the pause in effect, if any, delivering the publishes it held
+// back, and returns the pause it ended
+func (h *Hub) Resume() *PauseInfo {
+	h.mu.Lock()
+	state := h.pause
+	h.mu.Unlock()
+
+	if state == nil {
+		return nil
+	}
+	return h.resume(state)
+}
+
+// resume ends the given pause unless another has replaced it since
+func (h *Hub) resume(state *pauseState) *PauseInfo {
+	h.mu.Lock()
+	if h.pause != state {
+		h.mu.Unlock()
+		return nil
+	}
+	h.pause = nil
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	h.mu.Unlock()
+
+	info := state.info
+	info.Buffered = h.publishes.pending()
+	slog.Info("Hub resumed", "paused_for", h.clock.Now().Sub(info.Since), "buffered", info.Buffered)
+	// Wake the shard loops for the publishes held while paused
+	h.publishes.signal()
+	h.notifyClients(h.createPauseInfoMessageBytes(HubResumedInfo, nil))
+	return &info
+}
+
+// PauseStatus returns the pause in effect, or nil if the hub is running
+func (h *Hub) PauseStatus() *PauseInfo {
+	h.mu.RLock()
+	state := h.pause
+	h.mu.RUnlock()
+
+	if state == nil {
+		return nil
+	}
+	info := state.info
+	info.Buffered = h.publishes.pending()
+	return &info
+}
+
+// buffering reports whether a pause is holding publishes back
+func (h *Hub) buffering() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.pause != nil && h.pause.info.Mode == PauseBuffer
+}
+
+// notifyClients sends a frame to every connected client
+func (h *Hub) notifyClients(data []byte) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	// Send outside the lock, since a full queue may disconnect the client
+	for _, client := range clients {
+		client.sendWithBackpressure("", data)
+	}
+}
+
+// createPauseInfoMessageBytes creates the info frame sent to clients when
+// the hub pauses or resumes
+func (h *Hub) createPauseInfoMessageBytes(info string, pause *PauseInfo) []byte {
+	msg := ServerMessage{
+		Type:  InfoMessage,
+		Msg:   info,
+		Pause: pause,
+		TS:    h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
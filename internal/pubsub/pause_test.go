@@ -0,0 +1,116 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// pausedTestClient registers a test client with the hub, subscribed to
+// topic, so it gets pause info frames and events
+func pausedTestClient(hub *Hub, topic string) *Client {
+	client := newTestClient(hub)
+	hub.mu.Lock()
+	hub.clients[client] = true
+	hub.mu.Unlock()
+	client.subscriptions[topic] = true
+	hub.subscribeClient(&Subscription{client: client, topic: topic})
+	client.queue.Drain()
+	return client
+}
+
+func TestPauseBuffersPublishes(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	client := pausedTestClient(hub, "orders")
+
+	if _, err := hub.Pause(PauseOptions{Reason: "compaction"}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Msg != HubPausedInfo || frames[0].Pause == nil ||
+		frames[0].Pause.Mode != PauseBuffer || frames[0].Pause.Reason != "compaction" {
+		t.Fatalf("Expected a hub_paused frame in buffer mode, got %+v", frames)
+	}
+
+	// Publishes are accepted but held in the backlog
+	if _, err := hub.TryPublish(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "a"}}, time.Second); err != nil {
+		t.Fatalf("Expected the publish to be buffered, got %v", err)
+	}
+	hub.dispatchPublishes()
+	if frames := drainFrames(t, client); len(frames) != 0 {
+		t.Fatalf("Expected nothing delivered while paused, got %+v", frames)
+	}
+	if status := hub.PauseStatus(); status == nil || status.Buffered != 1 {
+		t.Fatalf("Expected 1 buffered publish, got %+v", status)
+	}
+
+	ended := hub.Resume()
+	if ended == nil || ended.Buffered != 1 {
+		t.Errorf("Expected Resume to report the pause with 1 buffered publish, got %+v", ended)
+	}
+	hub.dispatchPublishes()
+	frames = drainFrames(t, client)
+	if len(frames) != 2 || frames[0].Msg != HubResumedInfo || frames[1].Type != EventMessage || frames[1].Message.ID != "a" {
+		t.Fatalf("Expected hub_resumed then the held event, got %+v", frames)
+	}
+	if hub.PauseStatus() != nil || hub.Resume() != nil {
+		t.Error("Expected the hub to be running")
+	}
+}
+
+func TestPauseRejectsPublishes(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PauseMode: PauseReject})
+	hub.CreateTopic("orders")
+
+	if _, err := hub.Pause(PauseOptions{}); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	_, err := hub.TryPublish(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "a"}}, time.Second)
+	if !errors.Is(err, ErrHubPaused) || CodeOf(err) != CodeHubPaused {
+		t.Fatalf("Expected ErrHubPaused, got %v", err)
+	}
+
+	hub.Resume()
+	if _, err := hub.TryPublish(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "b"}}, time.Second); err != nil {
+		t.Errorf("Expected publishes accepted after resuming, got %v", err)
+	}
+}
+
+func TestPauseTimesOut(t *testing.T) {
+	hub := NewHub()
+
+	// A pause replacing a timed one keeps the hub paused past the timeout
+	hub.Pause(PauseOptions{TimeoutMs: 10})
+	hub.Pause(PauseOptions{Mode: PauseReject})
+	time.Sleep(30 * time.Millisecond)
+	if status := hub.PauseStatus(); status == nil || status.Mode != PauseReject {
+		t.Fatalf("Expected the replacing pause in effect, got %+v", status)
+	}
+
+	info, _ := hub.Pause(PauseOptions{TimeoutMs: 10})
+	if info.Until == nil {
+		t.Fatal("Expected the pause to report when it ends")
+	}
+	for deadline := time.Now().Add(time.Second); hub.PauseStatus() != nil && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if hub.PauseStatus() != nil {
+		t.Error("Expected the hub to resume by itself")
+	}
+}
+
+func TestPauseOptionsValidate(t *testing.T) {
+	hub := NewHub()
+	for _, opts := range []PauseOptions{{Mode: "drop"}, {TimeoutMs: -1}} {
+		if _, err := hub.Pause(opts); !errors.Is(err, ErrInvalidPause) {
+			t.Errorf("%+v: expected ErrInvalidPause, got %v", opts, err)
+		}
+	}
+	if hub.PauseStatus() != nil {
+		t.Error("Expected an invalid pause to leave the hub running")
+	}
+	if err := (HubOptions{PauseMode: "drop"}).Validate(); !errors.Is(err, ErrInvalidPause) {
+		t.Errorf("Expected an unknown default mode to fail, got %v", err)
+	}
+}
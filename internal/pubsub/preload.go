@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TopicDeclaration is a topic the server creates at startup: its name and
+// the settings a POST /topics request would give it
+type TopicDeclaration struct {
+	Name string `json:"name"`
+	TopicOptions
+}
+
+// PreloadResult summarizes what PreloadTopics created
+type PreloadResult struct {
+	Created int `json:"created"`
+	// Existing lists declared topics that were already there, recovered from
+	// storage or warmed from a peer, and were left as they are
+	Existing []string `json:"existing,omitempty"`
+}
+
+// LoadTopicDeclarations reads a JSON array of topic declarations from a
+// file, rejecting unknown fields, unnamed topics and duplicates
+func LoadTopicDeclarations(path string) ([]TopicDeclaration, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var declarations []TopicDeclaration
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&declarations); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(declarations))
+	for i, declaration := range declarations {
+		if declaration.Name == "" {
+			return nil, fmt.Errorf("%s: topic %d has no name", path, i)
+		}
+		if seen[declaration.Name] {
+			return nil, fmt.Errorf("%s: topic %s is declared twice", path, declaration.Name)
+		}
+		seen[declaration.Name] = true
+	}
+	return declarations, nil
+}
+
+// PreloadTopics creates the declared topics, in order, that don't exist yet.
+// Existing topics keep their settings, since they may have been changed at
+// runtime; any other failure stops the preload.
+func (h *Hub) PreloadTopics(declarations []TopicDeclaration) (*PreloadResult, error) {
+	result := &PreloadResult{}
+	for _, declaration := range declarations {
+		err := h.CreateTopicWithOptions(declaration.Name, declaration.TopicOptions)
+		switch {
+		case err == nil:
+			result.Created++
+		case errors.Is(err, ErrTopicExists):
+			result.Existing = append(result.Existing, declaration.Name)
+		default:
+			return result, fmt.Errorf("topic %s: %w", declaration.Name, err)
+		}
+	}
+	return result, nil
+}
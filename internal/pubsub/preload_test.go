@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTopicsFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "topics.json")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write topics file: %v", err)
+	}
+	return path
+}
+
+func TestPreloadTopics(t *testing.T) {
+	path := writeTopicsFile(t, `[
+		{"name": "orders", "weight": 3, "labels": {"team": "billing"}},
+		{"name": "payments", "retention": {"max_messages": 10}}
+	]`)
+	declarations, err := LoadTopicDeclarations(path)
+	if err != nil {
+		t.Fatalf("LoadTopicDeclarations failed: %v", err)
+	}
+
+	hub := NewHub()
+	hub.CreateTopic("payments")
+
+	result, err := hub.PreloadTopics(declarations)
+	if err != nil {
+		t.Fatalf("PreloadTopics failed: %v", err)
+	}
+	if result.Created != 1 || len(result.Existing) != 1 || result.Existing[0] != "payments" {
+		t.Fatalf("Expected orders created and payments left alone, got %+v", result)
+	}
+
+	stats, err := hub.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Weight != 3 || stats.Labels["team"] != "billing" {
+		t.Errorf("Expected the declared settings, got weight %d and labels %v", stats.Weight, stats.Labels)
+	}
+	if hub.topics["payments"].retention != nil {
+		t.Error("Expected the existing topic to keep its settings")
+	}
+
+	// Preloading again is a no-op
+	if result, err := hub.PreloadTopics(declarations); err != nil || result.Created != 0 || len(result.Existing) != 2 {
+		t.Errorf("Expected nothing created on a second preload, got %+v, %v", result, err)
+	}
+}
+
+func TestPreloadTopicsRejectsInvalidDeclarations(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"malformed", `{"name": "orders"}`, "cannot unmarshal"},
+		{"unknown field", `[{"name": "orders", "wieght": 3}]`, "unknown field"},
+		{"unnamed", `[{"weight": 3}]`, "has no name"},
+		{"duplicate", `[{"name": "orders"}, {"name": "orders"}]`, "declared twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTopicDeclarations(writeTopicsFile(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Settings are validated as a POST /topics would validate them
+	hub := NewHub()
+	_, err := hub.PreloadTopics([]TopicDeclaration{{Name: "orders", TopicOptions: TopicOptions{Weight: -1}}})
+	if !errors.Is(err, ErrInvalidWeight) {
+		t.Errorf("Expected ErrInvalidWeight, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// projectedObject is an object built by projection. It is distinct from
+// decoded payload objects so that projection never writes into a payload
+// shared with other subscribers.
+type projectedObject map[string]interface{}
+
+// validateFields checks a subscription's projection field list
+func validateFields(fields []string) error {
+	for _, field := range fields {
+		if field == "" {
+			return fmt.Errorf("projection fields must not be empty")
+		}
+		for _, part := range strings.Split(field, ".") {
+			if part == "" {
+				return fmt.Errorf("invalid projection field %q", field)
+			}
+		}
+	}
+	return nil
+}
+
+// projectPayload returns a copy of an object payload holding only the listed
+// fields. Fields are top-level keys or dot-separated paths into nested
+// objects; missing fields are skipped. Non-object payloads are returned
+// unchanged.
+func projectPayload(payload interface{}, fields []string) interface{} {
+	source, ok := payload.(map[string]interface{})
+	if !ok {
+		return payload
+	}
+
+	projected := projectedObject{}
+	for _, field := range fields {
+		copyField(projected, source, strings.Split(field, "."))
+	}
+	return projected
+}
+
+// copyField copies the value at path from src into dst
+func copyField(dst projectedObject, src map[string]interface{}, path []string) {
+	key := path[0]
+	value, ok := src[key]
+	if !ok {
+		return
+	}
+
+	if len(path) == 1 {
+		dst[key] = value
+		return
+	}
+
+	child, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	next, ok := dst[key].(projectedObject)
+	if !ok {
+		if _, included := dst[key]; included {
+			// The whole value was already requested
+			return
+		}
+		next = projectedObject{}
+		dst[key] = next
+	}
+	copyField(next, child, path[1:])
+}
+
+// project returns a copy of the message with its payload trimmed to fields
+func (m *PubSubMessage) project(fields []string) *PubSubMessage {
+	if m.Message == nil {
+		return m
+	}
+
+	data := *m.Message
+	data.Payload = projectPayload(m.Message.Payload, fields)
+
+	projected := *m
+	projected.Message = &data
+	projected.frames = nil
+	return &projected
+}
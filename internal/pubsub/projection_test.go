@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProjectPayload(t *testing.T) {
+	payload := map[string]interface{}{
+		"id":     "ORD-1",
+		"status": "shipped",
+		"items":  []interface{}{"a", "b"},
+		"customer": map[string]interface{}{
+			"id":    "C-9",
+			"email": "c9@example.com",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		fields   []string
+		expected string
+	}{
+		{"top-level keys", []string{"id", "status"}, `{"id":"ORD-1","status":"shipped"}`},
+		{"nested path", []string{"id", "customer.id"}, `{"customer":{"id":"C-9"},"id":"ORD-1"}`},
+		{"missing fields skipped", []string{"id", "missing", "status.code"}, `{"id":"ORD-1"}`},
+		{"whole object wins over path", []string{"customer", "customer.id"}, `{"customer":{"email":"c9@example.com","id":"C-9"}}`},
+		{"path then whole object", []string{"customer.id", "customer"}, `{"customer":{"email":"c9@example.com","id":"C-9"}}`},
+	}
+
+	for _, tt := range tests {
+		encoded, err := json.Marshal(projectPayload(payload, tt.fields))
+		if err != nil {
+			t.Fatalf("%s: failed to marshal projection: %v", tt.name, err)
+		}
+		if string(encoded) != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, encoded)
+		}
+	}
+
+	// The source payload must be left untouched
+	if len(payload) != 4 || len(payload["customer"].(map[string]interface{})) != 2 {
+		t.Errorf("Projection modified the source payload: %v", payload)
+	}
+}
+
+func TestProjectPayloadNonObject(t *testing.T) {
+	if projected := projectPayload("plain text", []string{"id"}); projected != "plain text" {
+		t.Errorf("Expected non-object payload unchanged, got %v", projected)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	if err := validateFields([]string{"id", "customer.id"}); err != nil {
+		t.Errorf("Expected valid fields, got %v", err)
+	}
+
+	for _, fields := range [][]string{{""}, {"customer."}, {".id"}, {"a..b"}} {
+		if err := validateFields(fields); err == nil {
+			t.Errorf("Expected %q to be rejected", fields)
+		}
+	}
+}
+
+func TestSubscriptionProjection(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+
+	trimmed := newTestClient(hub)
+	trimmed.handleMessage(&ClientMessage{
+		Type:     SubscribeMessage,
+		Topic:    "orders",
+		ClientID: "mobile",
+		Fields:   []string{"id", "status"},
+	})
+
+	full := newTestClient(hub)
+	full.handleMessage(&ClientMessage{
+		Type:     SubscribeMessage,
+		Topic:    "orders",
+		ClientID: "backend",
+	})
+
+	waitForSubscribers(t, hub, "orders", 2)
+	drainFrames(t, trimmed)
+	drainFrames(t, full)
+
+	hub.publishMessage(&PubSubMessage{
+		Topic: "orders",
+		Message: &MessageData{ID: "msg-1", Payload: map[string]interface{}{
+			"id": "ORD-1", "status": "shipped", "notes": "a large blob",
+		}},
+	})
+
+	frames := drainFrames(t, trimmed)
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(frames))
+	}
+	payload := frames[0].Message.Payload.(map[string]interface{})
+	if len(payload) != 2 || payload["id"] != "ORD-1" || payload["status"] != "shipped" {
+		t.Errorf("Expected projected payload, got %v", payload)
+	}
+	if frames[0].Message.ID != "msg-1" {
+		t.Errorf("Expected message ID to be preserved, got %s", frames[0].Message.ID)
+	}
+
+	frames = drainFrames(t, full)
+	if len(frames) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(frames))
+	}
+	if payload := frames[0].Message.Payload.(map[string]interface{}); len(payload) != 3 {
+		t.Errorf("Expected full payload for subscriber without projection, got %v", payload)
+	}
+}
+
+func TestSubscribeRejectsInvalidFields(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.handleMessage(&ClientMessage{
+		Type:      SubscribeMessage,
+		Topic:     "orders",
+		ClientID:  "mobile",
+		Fields:    []string{"id", ""},
+		RequestID: "sub-1",
+	})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != "BAD_REQUEST" {
+		t.Errorf("Expected BAD_REQUEST for empty field, got %+v", frames)
+	}
+
+	if client.IsSubscribed("orders") {
+		t.Error("Client should not be subscribed after a rejected subscribe")
+	}
+}
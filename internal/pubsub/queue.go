@@ -0,0 +1,152 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+)
+
+// queuedFrame is an encoded outbound frame
+type queuedFrame struct {
+	topic string // topic of an event frame, empty for control frames
+	data  []byte
+	// sequence of an event frame, for gap notices
+	sequence int64
+	// deadline after which the frame is dropped instead of written, zero
+	// for none
+	deadline time.Time
+	// message an event frame delivers, for dead lettering it if dropped
+	message *PubSubMessage
+}
+
+// messageQueue is a bounded FIFO of outbound frames owned by a client. It is
+// backed by a ring buffer; when full, pushing drops the oldest frame so the
+// newest data always gets through. The client's WritePump is the only consumer.
+type messageQueue struct {
+	mu     sync.Mutex
+	items  []queuedFrame
+	head   int
+	size   int
+	drops  int // frames dropped since the last drain
+	closed bool
+
+	// notify wakes the consumer when frames are pushed or the queue is closed
+	notify chan struct{}
+}
+
+// newMessageQueue creates a queue holding at most capacity frames
+func newMessageQueue(capacity int) *messageQueue {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &messageQueue{
+		items:  make([]queuedFrame, capacity),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Push appends a frame, dropping the oldest queued frame if the queue is full.
+// It returns the dropped frame (nil if nothing was dropped), and ok is false
+// once the queue has been closed.
+func (q *messageQueue) Push(topic string, data []byte) (dropped *queuedFrame, ok bool) {
+	return q.PushFrame(queuedFrame{topic: topic, data: data})
+}
+
+// PushFrame is Push for a frame carrying a sequence or deadline
+func (q *messageQueue) PushFrame(frame queuedFrame) (dropped *queuedFrame, ok bool) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil, false
+	}
+
+	if q.size == len(q.items) {
+		// Drop oldest
+		evicted := q.items[q.head]
+		dropped = &evicted
+		q.items[q.head] = queuedFrame{}
+		q.head = (q.head + 1) % len(q.items)
+		q.size--
+		q.drops++
+	}
+
+	q.items[(q.head+q.size)%len(q.items)] = frame
+	q.size++
+	q.mu.Unlock()
+
+	q.signal()
+	return dropped, true
+}
+
+// Drain removes and returns all queued frames' data in FIFO order, and
+// reports whether the queue has been closed
+func (q *messageQueue) Drain() ([][]byte, bool) {
+	frames, closed := q.DrainFrames()
+	data := make([][]byte, len(frames))
+	for i, frame := range frames {
+		data[i] = frame.data
+	}
+	return data, closed
+}
+
+// DrainFrames removes and returns all queued frames in FIFO order, and
+// reports whether the queue has been closed
+func (q *messageQueue) DrainFrames() ([]queuedFrame, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	frames := make([]queuedFrame, 0, q.size)
+	for q.size > 0 {
+		frames = append(frames, q.items[q.head])
+		q.items[q.head] = queuedFrame{}
+		q.head = (q.head + 1) % len(q.items)
+		q.size--
+	}
+	q.drops = 0
+
+	return frames, q.closed
+}
+
+// Len returns the number of queued frames
+func (q *messageQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// DropsSinceDrain returns how many frames were dropped since the consumer
+// last drained the queue
+func (q *messageQueue) DropsSinceDrain() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.drops
+}
+
+// Close stops the queue from accepting frames; frames already queued can
+// still be drained
+func (q *messageQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Closed reports whether the queue has been closed
+func (q *messageQueue) Closed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
+
+// Ready returns a channel that receives when the queue has frames or closes
+func (q *messageQueue) Ready() <-chan struct{} {
+	return q.notify
+}
+
+// signal wakes the consumer without blocking
+func (q *messageQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMessageQueueFIFO(t *testing.T) {
+	q := newMessageQueue(5)
+
+	for i := 0; i < 3; i++ {
+		if dropped, ok := q.Push("", []byte(strconv.Itoa(i))); dropped != nil || !ok {
+			t.Fatalf("Push %d: expected accepted without drop, got dropped=%v ok=%v", i, dropped, ok)
+		}
+	}
+
+	if q.Len() != 3 {
+		t.Errorf("Expected length 3, got %d", q.Len())
+	}
+
+	frames, closed := q.Drain()
+	if closed {
+		t.Error("Queue should not be closed")
+	}
+
+	for i, frame := range frames {
+		if string(frame) != strconv.Itoa(i) {
+			t.Errorf("Frame %d: expected %d, got %s", i, i, frame)
+		}
+	}
+
+	if q.Len() != 0 {
+		t.Errorf("Expected empty queue after drain, got %d", q.Len())
+	}
+}
+
+func TestMessageQueueDropsOldest(t *testing.T) {
+	q := newMessageQueue(3)
+
+	for i := 1; i <= 5; i++ {
+		dropped, _ := q.Push("topic-"+strconv.Itoa(i), []byte(strconv.Itoa(i)))
+		if i > 3 && (dropped == nil || dropped.topic != "topic-"+strconv.Itoa(i-3)) {
+			t.Errorf("Push %d: expected topic-%d to be dropped, got %v", i, i-3, dropped)
+		}
+	}
+
+	if drops := q.DropsSinceDrain(); drops != 2 {
+		t.Errorf("Expected 2 drops, got %d", drops)
+	}
+
+	frames, _ := q.Drain()
+	got := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		got = append(got, string(frame))
+	}
+
+	if strings.Join(got, ",") != "3,4,5" {
+		t.Errorf("Expected newest frames 3,4,5, got %v", got)
+	}
+
+	if drops := q.DropsSinceDrain(); drops != 0 {
+		t.Errorf("Expected drop counter reset after drain, got %d", drops)
+	}
+}
+
+func TestMessageQueueClose(t *testing.T) {
+	q := newMessageQueue(3)
+	q.Push("", []byte("pending"))
+	q.Close()
+
+	if _, ok := q.Push("", []byte("late")); ok {
+		t.Error("Push after close should be rejected")
+	}
+
+	select {
+	case <-q.Ready():
+	default:
+		t.Error("Close should wake the consumer")
+	}
+
+	frames, closed := q.Drain()
+	if !closed {
+		t.Error("Drain should report the queue as closed")
+	}
+
+	if len(frames) != 1 || string(frames[0]) != "pending" {
+		t.Errorf("Expected pending frame to survive close, got %d frames", len(frames))
+	}
+}
+
+func TestMessageQueueConcurrentProducers(t *testing.T) {
+	const producers = 8
+	const perProducer = 500
+
+	q := newMessageQueue(16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Push("", []byte(fmt.Sprintf("%d:%d", p, i)))
+			}
+		}(p)
+	}
+
+	// Consumer drains concurrently until producers finish
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		q.Close()
+		close(done)
+	}()
+
+	lastSeen := make(map[int]int)
+	received := 0
+	for closed := false; !closed; {
+		<-q.Ready()
+		var frames [][]byte
+		frames, closed = q.Drain()
+
+		for _, frame := range frames {
+			var p, i int
+			if _, err := fmt.Sscanf(string(frame), "%d:%d", &p, &i); err != nil {
+				t.Fatalf("Malformed frame %q", frame)
+			}
+			// Dropping may skip messages but must never reorder a producer's stream
+			if last, ok := lastSeen[p]; ok && i <= last {
+				t.Fatalf("Producer %d reordered: %d after %d", p, i, last)
+			}
+			lastSeen[p] = i
+			received++
+		}
+	}
+	<-done
+
+	if received == 0 || received > producers*perProducer {
+		t.Errorf("Unexpected number of received frames: %d", received)
+	}
+
+	if q.Len() != 0 {
+		t.Errorf("Expected empty queue, got %d", q.Len())
+	}
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+// QuotaType names a limit the broker enforces
+type QuotaType string
+
+// Quota types reported on $SYS/quota
+const (
+	// QuotaQueue is a client's send queue: the client overflowed it and
+	// was disconnected as a slow consumer
+	QuotaQueue QuotaType = "queue"
+	// QuotaPublishBacklog is a topic's backlog of publishes waiting for
+	// fan-out: the publish was rejected
+	QuotaPublishBacklog QuotaType = "publish_backlog"
+	// QuotaPublishRate is a WebSocket client's publish rate limit: the
+	// publish was rejected
+	QuotaPublishRate QuotaType = "publish_rate"
+	// QuotaRequestRate is a REST caller's request rate limit: the request
+	// was rejected
+	QuotaRequestRate QuotaType = "request_rate"
+	// QuotaTenantTopics is the topics a tenant may own: the topic was not
+	// created or transferred
+	QuotaTenantTopics QuotaType = "tenant_topics"
+)
+
+// QuotaEvent is the payload of a $SYS/quota event, published whenever a
+// client or publisher exceeds a quota
+type QuotaEvent struct {
+	Quota QuotaType `json:"quota"`
+	// Identity is who exceeded the quota, as websocket:<client id>,
+	// rest:<remote address> or tenant:<name>
+	Identity string `json:"identity"`
+	// Topic is set for per-topic quotas
+	Topic string `json:"topic,omitempty"`
+	// Limit is the quota that was exceeded
+	Limit int64 `json:"limit"`
+}
+
+// ReportQuota publishes a quota event to $SYS/quota when anyone is
+// subscribed to it
+func (h *Hub) ReportQuota(event QuotaEvent) {
+	h.publishSystemEvent(QuotaTopic, event)
+}
+
+// publishSystemEvent publishes a broker event to a system topic if it has
+// subscribers. The event never waits for room in the topic's backlog, since
+// events are also reported from the hub loop's fan-out; it is dropped
+// instead.
+func (h *Hub) publishSystemEvent(topic string, payload interface{}) {
+	h.mu.RLock()
+	watched := len(h.subscriptions[topic]) > 0
+	h.mu.RUnlock()
+	if !watched {
+		return
+	}
+
+	event := &PubSubMessage{
+		Topic: topic,
+		Message: &MessageData{
+			ID:          NewMessageID(),
+			ContentType: ContentTypeJSON,
+			Payload:     payload,
+		},
+		Timestamp: h.clock.Now(),
+	}
+	h.publishes.push(topic, event, 1, nil, expired)
+}
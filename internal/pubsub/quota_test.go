@@ -0,0 +1,58 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaturatedPublishReportsQuota(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.PublishBuffer = 1
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+
+	watcher := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: watcher, topic: QuotaTopic})
+
+	// The hub isn't running yet, so the second publish finds the backlog full
+	for i, id := range []string{"msg-1", "msg-2"} {
+		message, _ := NewMessage("orders", "x", WithID(id), WithPublisher(RESTIdentity("10.0.0.1:5000")))
+		_, err := hub.TryPublish(message, time.Millisecond)
+		if i == 0 && err != nil {
+			t.Fatalf("First publish failed: %v", err)
+		}
+		if i == 1 && err != ErrHubSaturated {
+			t.Fatalf("Expected ErrHubSaturated, got %v", err)
+		}
+	}
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	deadline := time.Now().Add(time.Second)
+	for watcher.queue.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the $SYS/quota event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	events := drainFrames(t, watcher)
+	if len(events) != 1 || events[0].Topic != QuotaTopic {
+		t.Fatalf("Expected one $SYS/quota event, got %+v", events)
+	}
+	payload, _ := events[0].Message.Payload.(map[string]interface{})
+	if payload["quota"] != string(QuotaPublishBacklog) || payload["identity"] != "rest:10.0.0.1:5000" ||
+		payload["topic"] != "orders" || payload["limit"] != float64(1) {
+		t.Errorf("Unexpected quota event payload: %v", events[0].Message.Payload)
+	}
+}
+
+func TestQuotaEventsNeedSubscribers(t *testing.T) {
+	hub := NewHub()
+	hub.ReportQuota(QuotaEvent{Quota: QuotaQueue, Identity: WebSocketIdentity("c1"), Limit: 100})
+
+	if depth, _ := hub.PublishBacklog(QuotaTopic); depth != 0 {
+		t.Errorf("Expected no event queued without subscribers, got %d", depth)
+	}
+}
@@ -0,0 +1,105 @@
+package pubsub
+
+import (
+	"fmt"
+	"plivo/internal/config"
+	"sync"
+	"time"
+)
+
+// RateLimit caps how often a caller may act: PerMinute actions a minute on
+// average, with bursts of up to Burst at once
+type RateLimit struct {
+	// PerMinute is the sustained rate (0 = unlimited)
+	PerMinute int
+	// Burst is how many actions may be taken at once after a quiet spell
+	// (0 = 1)
+	Burst int
+}
+
+// NewRateLimit derives the per-client rate limit from the security
+// configuration
+func NewRateLimit(cfg config.SecurityConfig) RateLimit {
+	return RateLimit{PerMinute: cfg.RateLimitPerMin, Burst: cfg.RateLimitBurst}
+}
+
+// Validate checks that the rate and burst are not negative
+func (l RateLimit) Validate() error {
+	if l.PerMinute < 0 || l.Burst < 0 {
+		return fmt.Errorf("rate limit and burst must not be negative, got %d per minute and %d", l.PerMinute, l.Burst)
+	}
+	return nil
+}
+
+// Enabled reports whether the limit caps anything
+func (l RateLimit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// capacity is how many tokens a bucket holds when full
+func (l RateLimit) capacity() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// TokenBucket enforces a RateLimit. It starts full, spends a token per
+// action and refills continuously at the limit's rate. It is safe for
+// concurrent use.
+type TokenBucket struct {
+	limit RateLimit
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full bucket for limit, or nil when the limit is
+// disabled; a nil bucket allows everything. The bucket starts refilling from
+// the first time it is asked, so it follows whatever clock its caller reads.
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	if !limit.Enabled() {
+		return nil
+	}
+	return &TokenBucket{limit: limit, tokens: limit.capacity()}
+}
+
+// Allow spends a token if one is available at now. When none is, it
+// returns how long until one will be.
+func (b *TokenBucket) Allow(now time.Time) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.IsZero() {
+		b.last = now
+	}
+	perSecond := float64(b.limit.PerMinute) / 60
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.limit.capacity(), b.tokens+elapsed*perSecond)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// Full reports whether the bucket would be full at now, so a caller idle
+// that long can be forgotten without loosening its limit
+func (b *TokenBucket) Full(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	perSecond := float64(b.limit.PerMinute) / 60
+	return b.tokens+now.Sub(b.last).Seconds()*perSecond >= b.limit.capacity()
+}
@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	if NewTokenBucket(RateLimit{}) != nil {
+		t.Fatal("Expected no bucket for a disabled limit")
+	}
+
+	// 60 a minute is one a second, in bursts of 3
+	bucket := NewTokenBucket(RateLimit{PerMinute: 60, Burst: 3})
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if allowed, _ := bucket.Allow(now); !allowed {
+			t.Fatalf("Expected action %d of the burst allowed", i+1)
+		}
+	}
+	allowed, retryAfter := bucket.Allow(now)
+	if allowed || retryAfter <= 0 || retryAfter > time.Second {
+		t.Fatalf("Expected the 4th action refused for up to a second, got %v after %s", allowed, retryAfter)
+	}
+
+	if allowed, _ := bucket.Allow(now.Add(time.Second)); !allowed {
+		t.Error("Expected a token refilled after a second")
+	}
+	if bucket.Full(now.Add(2 * time.Second)) {
+		t.Error("Expected the bucket not yet full after 2 seconds")
+	}
+	if !bucket.Full(now.Add(4 * time.Second)) {
+		t.Error("Expected the bucket full after 4 seconds")
+	}
+}
+
+func TestPublishRateLimit(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	client := newTestClient(hub)
+	client.id = "limited"
+	client.opts.PublishRate = RateLimit{PerMinute: 60, Burst: 2}
+	client.publishLimit = NewTokenBucket(client.opts.PublishRate)
+
+	for i := 0; i < 3; i++ {
+		client.handleMessage(&ClientMessage{
+			Type:      PublishMessage,
+			Topic:     "orders",
+			RequestID: "req",
+			Message:   &MessageData{ID: NewMessageID(), Payload: i},
+		})
+	}
+
+	frames := drainFrames(t, client)
+	if len(frames) != 3 || frames[0].Type != AckMessage || frames[1].Type != AckMessage {
+		t.Fatalf("Expected the burst of 2 acknowledged, got %+v", frames)
+	}
+	limited := frames[2]
+	if limited.Type != ErrorMessage || limited.Error.Code != CodeRateLimited {
+		t.Fatalf("Expected RATE_LIMITED for the 3rd publish, got %+v", limited)
+	}
+	if limited.Error.Limit != 60 || limited.Error.RetryAfterMs <= 0 {
+		t.Errorf("Expected the limit and a retry delay, got %+v", limited.Error)
+	}
+}
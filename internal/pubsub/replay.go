@@ -0,0 +1,74 @@
+package pubsub
+
+import "fmt"
+
+// replayBufferSize is how many recent messages each topic retains for replay
+// unless the hub is configured with another ring buffer size
+const replayBufferSize = 100
+
+// ReplayLimits bounds last_n replay on subscribe, server-wide or per topic
+type ReplayLimits struct {
+	// DefaultLastN is replayed when a subscribe omits last_n (0 = none)
+	DefaultLastN int `json:"default_last_n"`
+	// MaxLastN caps last_n; larger requests are reduced to it (0 = the
+	// topic's replay buffer size)
+	MaxLastN int `json:"max_last_n"`
+}
+
+// Validate checks that the limits are non-negative and that the default
+// does not exceed the cap. Whether they fit a replay buffer is checked
+// against the buffer they apply to.
+func (l ReplayLimits) Validate() error {
+	if l.DefaultLastN < 0 || l.MaxLastN < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidReplay)
+	}
+	if l.MaxLastN > 0 && l.DefaultLastN > l.MaxLastN {
+		return fmt.Errorf("%w: default_last_n %d exceeds max_last_n %d", ErrInvalidReplay, l.DefaultLastN, l.MaxLastN)
+	}
+	return nil
+}
+
+// fits checks that the limits replay no more than a buffer of the given
+// size holds
+func (l ReplayLimits) fits(buffer int) error {
+	if l.MaxLastN > buffer {
+		return fmt.Errorf("%w: max_last_n %d exceeds the replay buffer of %d messages", ErrInvalidReplay, l.MaxLastN, buffer)
+	}
+	if l.DefaultLastN > buffer {
+		return fmt.Errorf("%w: default_last_n %d exceeds the replay buffer of %d messages", ErrInvalidReplay, l.DefaultLastN, buffer)
+	}
+	return nil
+}
+
+// max returns the effective cap for a replay buffer of the given size
+func (l ReplayLimits) max(buffer int) int {
+	if l.MaxLastN == 0 {
+		return buffer
+	}
+	return l.MaxLastN
+}
+
+// resolve turns a requested last_n into the number of messages to replay
+// from a buffer of the given size: 0 takes the default, a negative value
+// opts out of replay, and anything above the cap is reduced to it,
+// reporting capped
+func (l ReplayLimits) resolve(requested, buffer int) (n int, capped bool) {
+	switch {
+	case requested < 0:
+		return 0, false
+	case requested == 0:
+		return l.DefaultLastN, false
+	case requested > l.max(buffer):
+		return l.max(buffer), true
+	default:
+		return requested, false
+	}
+}
+
+// replayLimits returns the topic's limits, falling back to the hub's
+func (t *Topic) replayLimits(hubLimits ReplayLimits) ReplayLimits {
+	if t.replay != nil {
+		return *t.replay
+	}
+	return hubLimits
+}
@@ -0,0 +1,216 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// retainMessages publishes n messages to a topic for replay
+func retainMessages(hub *Hub, topic string, n int) {
+	for i := 1; i <= n; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: topic, Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+}
+
+func TestReplayLimitsResolve(t *testing.T) {
+	limits := ReplayLimits{DefaultLastN: 5, MaxLastN: 20}
+
+	tests := []struct {
+		requested int
+		want      int
+		capped    bool
+	}{
+		{0, 5, false},
+		{-1, 0, false},
+		{10, 10, false},
+		{20, 20, false},
+		{50, 20, true},
+	}
+
+	for _, tt := range tests {
+		n, capped := limits.resolve(tt.requested, replayBufferSize)
+		if n != tt.want || capped != tt.capped {
+			t.Errorf("resolve(%d): expected %d (capped %t), got %d (capped %t)", tt.requested, tt.want, tt.capped, n, capped)
+		}
+	}
+
+	// No cap configured means the replay buffer size
+	if n, capped := (ReplayLimits{}).resolve(500, replayBufferSize); n != replayBufferSize || !capped {
+		t.Errorf("Expected cap at the replay buffer size, got %d (capped %t)", n, capped)
+	}
+}
+
+func TestReplayLimitsValidate(t *testing.T) {
+	tests := []struct {
+		limits ReplayLimits
+		valid  bool
+	}{
+		{ReplayLimits{}, true},
+		{ReplayLimits{DefaultLastN: 10, MaxLastN: 10}, true},
+		{ReplayLimits{DefaultLastN: 50}, true},
+		{ReplayLimits{DefaultLastN: -1}, false},
+		{ReplayLimits{MaxLastN: replayBufferSize + 1}, false},
+		{ReplayLimits{DefaultLastN: 20, MaxLastN: 10}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.limits.Validate()
+		if err == nil {
+			err = tt.limits.fits(replayBufferSize)
+		}
+		if tt.valid && err != nil {
+			t.Errorf("%+v: expected valid, got %v", tt.limits, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidReplay) {
+			t.Errorf("%+v: expected ErrInvalidReplay, got %v", tt.limits, err)
+		}
+	}
+}
+
+func TestPrepareReplayAppliesHubLimits(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{Replay: ReplayLimits{DefaultLastN: 3, MaxLastN: 5}})
+	hub.CreateTopic("orders")
+	retainMessages(hub, "orders", 10)
+
+	info, backlog := hub.prepareReplay("orders", 0, "")
+	if len(backlog) != 3 || info.Capped {
+		t.Errorf("Expected the default of 3 messages, got %d (capped %t)", len(backlog), info.Capped)
+	}
+	if backlog[0].Message.ID != "msg-8" {
+		t.Errorf("Expected the most recent messages, got %s first", backlog[0].Message.ID)
+	}
+
+	info, backlog = hub.prepareReplay("orders", 50, "")
+	if len(backlog) != 5 || !info.Capped || info.Replaying != 5 {
+		t.Errorf("Expected last_n capped at 5, got %d (capped %t)", len(backlog), info.Capped)
+	}
+
+	if _, backlog = hub.prepareReplay("orders", -1, ""); len(backlog) != 0 {
+		t.Errorf("Expected a negative last_n to opt out of replay, got %d", len(backlog))
+	}
+}
+
+func TestPrepareReplayTopicOverride(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{Replay: ReplayLimits{DefaultLastN: 3}})
+	hub.CreateTopicWithOptions("audit", TopicOptions{Replay: &ReplayLimits{MaxLastN: 2}})
+	retainMessages(hub, "audit", 10)
+
+	// The topic's limits replace the hub's, so there is no default replay
+	if _, backlog := hub.prepareReplay("audit", 0, ""); len(backlog) != 0 {
+		t.Errorf("Expected no default replay, got %d", len(backlog))
+	}
+
+	if info, backlog := hub.prepareReplay("audit", 10, ""); len(backlog) != 2 || !info.Capped {
+		t.Errorf("Expected last_n capped at 2, got %d", len(backlog))
+	}
+
+	stats, _ := hub.GetTopicStats("audit")
+	if stats.Replay.MaxLastN != 2 || stats.Replay.DefaultLastN != 0 {
+		t.Errorf("Expected topic stats to report the override, got %+v", stats.Replay)
+	}
+}
+
+func TestCreateTopicRejectsInvalidReplayLimits(t *testing.T) {
+	hub := NewHub()
+
+	err := hub.CreateTopicWithOptions("orders", TopicOptions{Replay: &ReplayLimits{DefaultLastN: 10, MaxLastN: 5}})
+	if !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected ErrInvalidReplay, got %v", err)
+	}
+	if hub.TopicExists("orders") {
+		t.Error("Topic should not be created with invalid limits")
+	}
+}
+
+func TestRingBufferSizeBoundsReplay(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{RingBufferSize: 5})
+	hub.CreateTopic("orders")
+	hub.CreateTopicWithOptions("audit", TopicOptions{Retention: &RetentionPolicy{MaxMessages: 50}})
+	retainMessages(hub, "orders", 10)
+	retainMessages(hub, "audit", 60)
+
+	// Without a cap, last_n replays up to what the topic retains
+	if info, backlog := hub.prepareReplay("orders", 50, ""); len(backlog) != 5 || !info.Capped {
+		t.Errorf("Expected last_n capped at the ring buffer of 5, got %d (capped %t)", len(backlog), info.Capped)
+	}
+	if _, backlog := hub.prepareReplay("audit", 40, ""); len(backlog) != 40 {
+		t.Errorf("Expected 40 replayed from the topic's larger buffer, got %d", len(backlog))
+	}
+
+	stats, _ := hub.GetTopicStats("orders")
+	if stats.BufferCapacity != 5 || stats.Retention.MaxMessages != 5 {
+		t.Errorf("Expected a buffer capacity of 5, got %d (retention %+v)", stats.BufferCapacity, stats.Retention)
+	}
+
+	// A cap must fit the topic's buffer
+	err := hub.CreateTopicWithOptions("payments", TopicOptions{Replay: &ReplayLimits{MaxLastN: 10}})
+	if !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected ErrInvalidReplay for max_last_n beyond the ring buffer, got %v", err)
+	}
+	err = hub.CreateTopicWithOptions("payments", TopicOptions{
+		Replay:    &ReplayLimits{MaxLastN: 10},
+		Retention: &RetentionPolicy{MaxMessages: 10},
+	})
+	if err != nil {
+		t.Errorf("Expected max_last_n to fit the topic's retention, got %v", err)
+	}
+	if _, err := hub.UpdateTopic("payments", TopicUpdate{Retention: &RetentionPolicy{MaxMessages: 8}}, 0); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected shrinking retention below max_last_n to fail, got %v", err)
+	}
+}
+
+func TestHubOptionsValidateRingBufferSize(t *testing.T) {
+	if err := (HubOptions{RingBufferSize: 50, Replay: ReplayLimits{MaxLastN: 50}}).Validate(); err != nil {
+		t.Errorf("Expected valid options, got %v", err)
+	}
+	if err := (HubOptions{RingBufferSize: 50, Replay: ReplayLimits{MaxLastN: 100}}).Validate(); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected max_last_n beyond the ring buffer to fail, got %v", err)
+	}
+	if err := (HubOptions{RingBufferSize: maxRetainedMessages + 1}).Validate(); err == nil {
+		t.Error("Expected a ring buffer beyond the retention limit to fail")
+	}
+}
+
+func TestSetRingBufferSizeAppliesToNewTopics(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{RingBufferSize: 5, Replay: ReplayLimits{MaxLastN: 5}})
+	hub.CreateTopic("orders")
+
+	if err := hub.SetRingBufferSize(3); !errors.Is(err, ErrInvalidReplay) {
+		t.Errorf("Expected a size below max_last_n to fail, got %v", err)
+	}
+	if err := hub.SetRingBufferSize(maxRetainedMessages + 1); err == nil {
+		t.Error("Expected a size beyond the retention limit to fail")
+	}
+	if err := hub.SetRingBufferSize(8); err != nil {
+		t.Fatalf("SetRingBufferSize failed: %v", err)
+	}
+	hub.CreateTopic("audit")
+	retainMessages(hub, "orders", 10)
+	retainMessages(hub, "audit", 10)
+
+	for topic, want := range map[string]int{"orders": 5, "audit": 8} {
+		stats, _ := hub.GetTopicStats(topic)
+		if stats.BufferCapacity != want || stats.BufferOccupancy != want {
+			t.Errorf("%s: expected a full buffer of %d, got %d of %d", topic, want, stats.BufferOccupancy, stats.BufferCapacity)
+		}
+	}
+
+	// Clearing a retention policy goes back to the topic's own size
+	hub.UpdateTopic("orders", TopicUpdate{Retention: &RetentionPolicy{MaxMessages: 20}}, 0)
+	hub.UpdateTopic("orders", TopicUpdate{Retention: &RetentionPolicy{}}, 0)
+	if stats, _ := hub.GetTopicStats("orders"); stats.BufferCapacity != 5 {
+		t.Errorf("Expected orders back to a buffer of 5, got %d", stats.BufferCapacity)
+	}
+}
+
+func TestDefaultLastNDoesNotOverrideGroupReplay(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{Replay: ReplayLimits{DefaultLastN: 1}})
+	hub.CreateTopic("orders")
+	hub.prepareReplay("orders", 0, "billing") // group joins at sequence 0
+	retainMessages(hub, "orders", 4)
+
+	if _, backlog := hub.prepareReplay("orders", 0, "billing"); len(backlog) != 4 {
+		t.Errorf("Expected the group's 4 pending messages, got %d", len(backlog))
+	}
+}
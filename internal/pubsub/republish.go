@@ -0,0 +1,178 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"plivo/internal/logging"
+)
+
+// Headers stamped on messages re-published into another topic, naming where
+// they were first published
+const (
+	// ReplayHeaderPrefix starts every replay provenance header
+	ReplayHeaderPrefix = ReservedHeaderPrefix + "replay."
+	// ReplaySourceTopicHeader is the topic the message was replayed from
+	ReplaySourceTopicHeader = ReplayHeaderPrefix + "topic"
+	// ReplaySourceSequenceHeader is the message's sequence in that topic
+	ReplaySourceSequenceHeader = ReplayHeaderPrefix + "sequence"
+)
+
+// ReplayIntoRequest selects retained messages of a topic to re-publish into
+// another topic. Unset range bounds are open.
+type ReplayIntoRequest struct {
+	// TargetTopic receives the re-published messages
+	TargetTopic string `json:"target_topic"`
+	// FromSequence and ToSequence bound the source sequences, inclusive
+	FromSequence int64 `json:"from_sequence,omitempty"`
+	ToSequence   int64 `json:"to_sequence,omitempty"`
+	// Since and Until bound the server receive times, inclusive
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// Rate caps re-publishes per second (0 = unpaced)
+	Rate int `json:"rate,omitempty"`
+}
+
+// ReplayIntoResult reports the messages a replay re-publishes in the
+// background
+type ReplayIntoResult struct {
+	Topic       string `json:"topic"`
+	TargetTopic string `json:"target_topic"`
+	// Messages is how many retained messages matched the range
+	Messages int `json:"messages"`
+	// FromSequence and ToSequence are the first and last matched source
+	// sequences, 0 if none matched
+	FromSequence int64 `json:"from_sequence,omitempty"`
+	ToSequence   int64 `json:"to_sequence,omitempty"`
+}
+
+// validate checks the target and that the range and rate are consistent
+func (r ReplayIntoRequest) validate(source string) error {
+	switch {
+	case r.TargetTopic == "":
+		return fmt.Errorf("%w: target_topic is required", ErrInvalidRange)
+	case r.TargetTopic == source:
+		return fmt.Errorf("%w: a topic cannot be replayed into itself", ErrInvalidRange)
+	case IsSystemTopic(r.TargetTopic):
+		return ErrReservedTopic
+	case r.FromSequence < 0 || r.ToSequence < 0:
+		return fmt.Errorf("%w: sequences must not be negative", ErrInvalidRange)
+	case r.ToSequence > 0 && r.FromSequence > r.ToSequence:
+		return fmt.Errorf("%w: from_sequence is after to_sequence", ErrInvalidRange)
+	case r.Since != nil && r.Until != nil && r.Since.After(*r.Until):
+		return fmt.Errorf("%w: since is after until", ErrInvalidRange)
+	case r.Rate < 0:
+		return fmt.Errorf("%w: rate must not be negative", ErrInvalidRange)
+	}
+	return nil
+}
+
+// matches reports whether a retained message falls in the range
+func (r ReplayIntoRequest) matches(message *PubSubMessage) bool {
+	switch {
+	case r.FromSequence > 0 && message.Sequence < r.FromSequence:
+		return false
+	case r.ToSequence > 0 && message.Sequence > r.ToSequence:
+		return false
+	case r.Since != nil && message.Timestamp.Before(*r.Since):
+		return false
+	case r.Until != nil && message.Timestamp.After(*r.Until):
+		return false
+	}
+	return true
+}
+
+// ReplayInto re-publishes a topic's retained messages in the request's range
+// into the target topic, oldest first, for reprocessing after consumer bugs.
+// The messages are selected when the call is made and re-published in the
+// background at the request's rate, as new publishes from publisher with
+// new sequences and the replay provenance headers. Encrypted topics can only
+// be replayed into topics encrypted with the same key.
+func (h *Hub) ReplayInto(source string, req ReplayIntoRequest, publisher string) (*ReplayIntoResult, error) {
+	if err := req.validate(source); err != nil {
+		return nil, err
+	}
+
+	h.mu.RLock()
+	topic, exists := h.topics[source]
+	if !exists {
+		h.mu.RUnlock()
+		return nil, ErrTopicNotFound
+	}
+	target, exists := h.topics[req.TargetTopic]
+	if !exists {
+		h.mu.RUnlock()
+		return nil, fmt.Errorf("%w: target topic %s", ErrTopicNotFound, req.TargetTopic)
+	}
+	if target.keyID != topic.keyID {
+		h.mu.RUnlock()
+		return nil, fmt.Errorf("%w: target topic %s", ErrKeyIDMismatch, req.TargetTopic)
+	}
+
+	var messages []*PubSubMessage
+	for _, message := range h.retained(source, 0) {
+		if req.matches(message) {
+			messages = append(messages, message)
+		}
+	}
+	h.mu.RUnlock()
+
+	result := &ReplayIntoResult{Topic: source, TargetTopic: req.TargetTopic, Messages: len(messages)}
+	if len(messages) > 0 {
+		result.FromSequence = messages[0].Sequence
+		result.ToSequence = messages[len(messages)-1].Sequence
+		go h.republish(req.TargetTopic, messages, req.Rate, publisher)
+	}
+	return result, nil
+}
+
+// republish publishes copies of messages into a topic at up to rate per
+// second, stopping at the first publish the topic refuses
+func (h *Hub) republish(topic string, messages []*PubSubMessage, rate int, publisher string) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.RecordPanic("hub.republish", r)
+		}
+	}()
+
+	var pace <-chan time.Time
+	if rate > 0 {
+		if interval := time.Second / time.Duration(rate); interval > 0 {
+			ticker := h.clock.NewTicker(interval)
+			defer ticker.Stop()
+			pace = ticker.C()
+		}
+	}
+
+	for i, message := range messages {
+		if i > 0 && pace != nil {
+			<-pace
+		}
+		if err := h.enqueuePublish(replayCopy(topic, message, publisher, h.clock.Now())); err != nil {
+			slog.Warn("Replay stopped", "from", message.Topic, logging.Topic, topic, "replayed", i, "total", len(messages), "error", err)
+			return
+		}
+	}
+	slog.Info("Replayed messages", "from", messages[0].Topic, logging.Topic, topic, "count", len(messages))
+}
+
+// replayCopy builds the re-publish of a retained message into a topic,
+// received at receivedAt and stamped with where it came from
+func replayCopy(topic string, message *PubSubMessage, publisher string, receivedAt time.Time) *PubSubMessage {
+	data := *message.Message
+	data.Headers = make(map[string]string, len(message.Message.Headers)+2)
+	for key, value := range message.Message.Headers {
+		data.Headers[key] = value
+	}
+	data.Headers[ReplaySourceTopicHeader] = message.Topic
+	data.Headers[ReplaySourceSequenceHeader] = strconv.FormatInt(message.Sequence, 10)
+
+	return &PubSubMessage{
+		Topic:     topic,
+		Message:   &data,
+		Timestamp: receivedAt,
+		publisher: publisher,
+	}
+}
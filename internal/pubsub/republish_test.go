@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplayIntoRepublishesRange(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-retry")
+	retainMessages(hub, "orders", 5)
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: "orders-retry"})
+
+	result, err := hub.ReplayInto("orders", ReplayIntoRequest{TargetTopic: "orders-retry", FromSequence: 2, ToSequence: 4}, "rest:10.0.0.1:5000")
+	if err != nil {
+		t.Fatalf("ReplayInto failed: %v", err)
+	}
+	if result.Messages != 3 || result.FromSequence != 2 || result.ToSequence != 4 {
+		t.Errorf("Expected sequences 2 to 4 selected, got %+v", result)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if stats, _ := hub.GetTopicStats("orders-retry"); stats.Sequence == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the replayed messages")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	replayed := hub.GetRecentMessages("orders-retry", 0)
+	if len(replayed) != 3 {
+		t.Fatalf("Expected 3 replayed messages, got %d", len(replayed))
+	}
+	first := replayed[0]
+	if first.Sequence != 1 || first.Message.ID != "msg-2" ||
+		first.Message.Headers[ReplaySourceTopicHeader] != "orders" || first.Message.Headers[ReplaySourceSequenceHeader] != "2" {
+		t.Errorf("Expected msg-2 re-published as sequence 1 with provenance headers, got %+v with %v", first, first.Message.Headers)
+	}
+
+	// The source messages are left alone
+	if source := hub.GetRecentMessages("orders", 0); source[1].Message.Headers != nil {
+		t.Errorf("Expected source headers untouched, got %v", source[1].Message.Headers)
+	}
+}
+
+func TestReplayIntoPacedByHubClock(t *testing.T) {
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	opts := DefaultHubOptions()
+	opts.Clock = clock
+	hub := NewHubWithOptions(opts)
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-retry")
+	retainMessages(hub, "orders", 3)
+
+	if _, err := hub.ReplayInto("orders", ReplayIntoRequest{TargetTopic: "orders-retry", Rate: 1}, ""); err != nil {
+		t.Fatalf("ReplayInto failed: %v", err)
+	}
+	waitForSequence := func(want int64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for stats, _ := hub.GetTopicStats("orders-retry"); stats.Sequence != want; stats, _ = hub.GetTopicStats("orders-retry") {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for sequence %d, at %d", want, stats.Sequence)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// One message a second of the hub's clock, stamped with its time
+	waitForSequence(1)
+	clock.Advance(time.Second)
+	waitForSequence(2)
+	clock.Advance(time.Second)
+	waitForSequence(3)
+	if replayed := hub.GetRecentMessages("orders-retry", 0); !replayed[2].Timestamp.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("Expected the last copy received at the hub's clock time, got %v", replayed[2].Timestamp)
+	}
+}
+
+func TestReplayIntoValidation(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopic("archive")
+	hub.CreateTopicWithOptions("sealed", TopicOptions{KeyID: "key-1"})
+
+	since := time.Now()
+	until := since.Add(-time.Minute)
+	tests := []struct {
+		name   string
+		source string
+		req    ReplayIntoRequest
+		want   error
+	}{
+		{"missing target", "orders", ReplayIntoRequest{}, ErrInvalidRange},
+		{"same topic", "orders", ReplayIntoRequest{TargetTopic: "orders"}, ErrInvalidRange},
+		{"system target", "orders", ReplayIntoRequest{TargetTopic: ErrorsTopic}, ErrReservedTopic},
+		{"reversed sequences", "orders", ReplayIntoRequest{TargetTopic: "archive", FromSequence: 5, ToSequence: 2}, ErrInvalidRange},
+		{"reversed times", "orders", ReplayIntoRequest{TargetTopic: "archive", Since: &since, Until: &until}, ErrInvalidRange},
+		{"negative rate", "orders", ReplayIntoRequest{TargetTopic: "archive", Rate: -1}, ErrInvalidRange},
+		{"missing source", "missing", ReplayIntoRequest{TargetTopic: "archive"}, ErrTopicNotFound},
+		{"missing target topic", "orders", ReplayIntoRequest{TargetTopic: "missing"}, ErrTopicNotFound},
+		{"different key", "orders", ReplayIntoRequest{TargetTopic: "sealed"}, ErrKeyIDMismatch},
+	}
+
+	for _, tt := range tests {
+		if _, err := hub.ReplayInto(tt.source, tt.req, ""); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	result, err := hub.ReplayInto("orders", ReplayIntoRequest{TargetTopic: "archive"}, "")
+	if err != nil || result.Messages != 0 {
+		t.Errorf("Expected an empty replay to succeed, got %+v, %v", result, err)
+	}
+}
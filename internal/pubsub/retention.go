@@ -0,0 +1,376 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// retainedOverhead approximates the memory a retained message holds besides
+// its ID, headers and payload: the message structs, pointers and ring slot
+const retainedOverhead = 256
+
+// retentionWarnRatio is the share of the retention budget at which a
+// warning is published to $SYS/retention
+const retentionWarnRatio = 0.8
+
+// RetentionUsage reports the memory held by retained messages against the
+// retention budget
+type RetentionUsage struct {
+	// Bytes approximates the memory retained messages hold across topics
+	Bytes int64 `json:"bytes"`
+	// Budget caps Bytes (0 = unbounded)
+	Budget int64 `json:"budget"`
+	// Evictions counts messages dropped early to stay within the budget
+	Evictions int64 `json:"evictions"`
+}
+
+// UsageReporter is implemented by stores that track the memory their
+// retained messages hold. The hub reports it in its statistics and on
+// $SYS/retention.
+type UsageReporter interface {
+	// Usage reports the memory held across all topics
+	Usage() RetentionUsage
+	// TopicBytes approximates the memory a topic's retained messages hold
+	TopicBytes(topic string) int64
+}
+
+var _ UsageReporter = (*MemoryStore)(nil)
+
+// WithBudget caps the approximate memory retained messages hold across all
+// topics. Appends beyond it evict the oldest messages of the topics least
+// recently published to, so busy topics keep their replay window at the
+// expense of idle ones. budget <= 0 leaves retention unbounded.
+func WithBudget(budget int64) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.budget = max(budget, 0)
+	}
+}
+
+// Usage reports the memory held across all topics
+func (s *MemoryStore) Usage() RetentionUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return RetentionUsage{Bytes: s.bytes, Budget: s.budget, Evictions: s.evictions}
+}
+
+// TopicBytes approximates the memory a topic's retained messages hold
+func (s *MemoryStore) TopicBytes(topic string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ring, exists := s.rings[topic]; exists {
+		return ring.bytes
+	}
+	return 0
+}
+
+// entrySize approximates the memory a retained message holds, given the
+// size its payload is kept at
+func entrySize(message *PubSubMessage, payloadBytes int) int64 {
+	size := retainedOverhead + len(message.Topic) + payloadBytes
+	if message.Message != nil {
+		size += len(message.Message.ID) + len(message.Message.ContentType)
+		for key, value := range message.Message.Headers {
+			size += len(key) + len(value)
+		}
+	}
+	return int64(size)
+}
+
+// account adds delta bytes to a ring and the store total. Caller must hold
+// s.mu.
+func (s *MemoryStore) account(ring *messageRing, delta int64) {
+	ring.bytes += delta
+	s.bytes += delta
+}
+
+// release drops a ring's bytes from the store total and the ring from the
+// LRU list. Caller must hold s.mu.
+func (s *MemoryStore) release(ring *messageRing) {
+	s.bytes -= ring.bytes
+	if ring.elem != nil {
+		s.lru.Remove(ring.elem)
+		ring.elem = nil
+	}
+}
+
+// enforceBudget evicts the oldest messages of the least recently appended
+// to rings until the store is within its budget. The message just appended
+// to current is never evicted, even when it alone exceeds the budget.
+// Caller must hold s.mu.
+func (s *MemoryStore) enforceBudget(current *messageRing) {
+	for s.budget > 0 && s.bytes > s.budget {
+		back := s.lru.Back()
+		if back == nil {
+			return
+		}
+		ring := back.Value.(*messageRing)
+		if ring == current && ring.size == 1 {
+			return
+		}
+		s.evictOldest(ring)
+	}
+}
+
+// evictOldest drops a ring's oldest message. Caller must hold s.mu.
+func (s *MemoryStore) evictOldest(ring *messageRing) {
+	oldest := ring.oldest()
+	s.account(ring, -ring.entries[oldest].size)
+	ring.entries[oldest] = retainedEntry{}
+	ring.size--
+	s.evictions++
+
+	if ring.size == 0 {
+		s.lru.Remove(ring.elem)
+		ring.elem = nil
+	}
+}
+
+// RetentionEvent is the payload of a $SYS/retention event, published as
+// retained messages approach and reach the retention budget
+type RetentionEvent struct {
+	// Event is "warning" when usage reaches 80% of the budget and
+	// "evicting" when messages are evicted to stay within it
+	Event string `json:"event"`
+	RetentionUsage
+}
+
+// retentionAlert remembers the highest retention event published since
+// usage was last below the warning level, so each is published once
+type retentionAlert struct {
+	mu        sync.Mutex
+	level     int // 0 = none, 1 = warning, 2 = evicting
+	evictions int64
+}
+
+// checkRetention publishes a $SYS/retention event when retained messages
+// cross the warning level or start being evicted
+func (h *Hub) checkRetention() {
+	reporter, ok := h.store.(UsageReporter)
+	if !ok {
+		return
+	}
+	usage := reporter.Usage()
+	if usage.Budget == 0 {
+		return
+	}
+
+	alert := &h.retentionAlert
+	alert.mu.Lock()
+	level := 0
+	switch {
+	case usage.Evictions > alert.evictions:
+		level = 2
+	case float64(usage.Bytes) >= retentionWarnRatio*float64(usage.Budget):
+		level = 1
+	}
+	alert.evictions = usage.Evictions
+	raised := level > alert.level
+	if raised || level == 0 {
+		alert.level = level
+	}
+	alert.mu.Unlock()
+
+	if !raised {
+		return
+	}
+	event := RetentionEvent{Event: "warning", RetentionUsage: usage}
+	if level == 2 {
+		event.Event = "evicting"
+	}
+	slog.Warn("Retention budget "+event.Event, "bytes", usage.Bytes, "budget", usage.Budget, "evictions", usage.Evictions)
+	h.publishSystemEvent(RetentionTopic, event)
+}
+
+// maxRetainedMessages caps a retention policy's max_messages
+const maxRetainedMessages = 10000
+
+// RetentionPolicy bounds what a topic retains for replay, by count and by
+// age. Messages also expire individually once their ttl_ms passes.
+type RetentionPolicy struct {
+	// MaxMessages is how many of the newest messages are retained (0 = the
+	// hub's ring buffer size)
+	MaxMessages int `json:"max_messages,omitempty"`
+	// MaxAgeMs expires retained messages this many milliseconds after they
+	// were published (0 = never)
+	MaxAgeMs int64 `json:"max_age_ms,omitempty"`
+}
+
+// Validate checks that the policy is non-negative and that max_messages
+// stays within what a topic may retain
+func (p RetentionPolicy) Validate() error {
+	if p.MaxMessages < 0 || p.MaxAgeMs < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidRetention)
+	}
+	if p.MaxMessages > maxRetainedMessages {
+		return fmt.Errorf("%w: max_messages %d exceeds the limit of %d", ErrInvalidRetention, p.MaxMessages, maxRetainedMessages)
+	}
+	return nil
+}
+
+// capacity returns how many messages the policy retains; a nil policy
+// retains the given ring buffer size
+func (p *RetentionPolicy) capacity(ringBufferSize int) int {
+	if p == nil || p.MaxMessages == 0 {
+		return ringBufferSize
+	}
+	return p.MaxMessages
+}
+
+// maxAge returns the age at which retained messages expire (0 = never)
+func (p *RetentionPolicy) maxAge() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return time.Duration(p.MaxAgeMs) * time.Millisecond
+}
+
+// capacity returns how many messages the topic retains
+func (t *Topic) capacity() int {
+	return t.retention.capacity(t.ringBufferSize)
+}
+
+// retentionPolicy returns the topic's effective policy
+func (t *Topic) retentionPolicy() RetentionPolicy {
+	return RetentionPolicy{MaxMessages: t.capacity(), MaxAgeMs: t.retention.maxAge().Milliseconds()}
+}
+
+// expired reports whether a message retained by the topic has outlived its
+// TTL or the topic's max age
+func (t *Topic) expired(message *PubSubMessage, now time.Time) bool {
+	if message.expired(now) {
+		return true
+	}
+	maxAge := t.retention.maxAge()
+	return maxAge > 0 && now.Sub(message.Timestamp) > maxAge
+}
+
+// RetentionStore is implemented by stores that honour topic retention
+// policies: retaining more or fewer messages than their default capacity,
+// and dropping expired messages before newer ones overwrite them
+type RetentionStore interface {
+	// SetCapacity changes how many messages a topic retains (0 = the
+	// store's default), dropping its oldest beyond the new capacity
+	SetCapacity(topic string, capacity int) error
+	// Expire drops a topic's retained messages for which expired returns
+	// true and returns how many it dropped
+	Expire(topic string, expired func(*PubSubMessage) bool) (int, error)
+}
+
+var _ RetentionStore = (*MemoryStore)(nil)
+
+// SetCapacity resizes a topic's ring, keeping its newest messages
+func (s *MemoryStore) SetCapacity(topic string, capacity int) error {
+	if capacity <= 0 {
+		capacity = s.capacity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, exists := s.rings[topic]
+	if !exists {
+		return ErrTopicNotFound
+	}
+	entries := ring.ordered()
+	for len(entries) > capacity {
+		s.account(ring, -entries[0].size)
+		entries = entries[1:]
+	}
+	s.refill(ring, entries, capacity)
+	return nil
+}
+
+// Expire drops a topic's expired messages, wherever they are in its ring
+func (s *MemoryStore) Expire(topic string, expired func(*PubSubMessage) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, exists := s.rings[topic]
+	if !exists {
+		return 0, ErrTopicNotFound
+	}
+	entries := ring.ordered()
+	kept := entries[:0]
+	for _, entry := range entries {
+		if expired(entry.message) {
+			s.account(ring, -entry.size)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	dropped := len(entries) - len(kept)
+	if dropped > 0 {
+		s.refill(ring, kept, len(ring.entries))
+	}
+	return dropped, nil
+}
+
+// oldest returns the slot of the ring's oldest message
+func (r *messageRing) oldest() int {
+	capacity := len(r.entries)
+	if capacity == 0 {
+		return 0
+	}
+	return (r.head - r.size + capacity) % capacity
+}
+
+// ordered returns a copy of the ring's entries, oldest first
+func (r *messageRing) ordered() []retainedEntry {
+	entries := make([]retainedEntry, r.size)
+	for i := range entries {
+		entries[i] = r.entries[(r.oldest()+i)%len(r.entries)]
+	}
+	return entries
+}
+
+// refill replaces a ring's contents with entries, oldest first, in a ring
+// of the given capacity. Caller must hold s.mu and have accounted for any
+// entries dropped.
+func (s *MemoryStore) refill(ring *messageRing, entries []retainedEntry, capacity int) {
+	ring.entries = make([]retainedEntry, capacity)
+	copy(ring.entries, entries)
+	ring.size = len(entries)
+	ring.head = 0
+	if capacity > 0 {
+		ring.head = ring.size % capacity
+	}
+	if ring.size == 0 && ring.elem != nil {
+		s.lru.Remove(ring.elem)
+		ring.elem = nil
+	}
+}
+
+// applyRetention sizes a newly created topic's retention to its policy or,
+// without one, its ring buffer size, when the store supports it. Caller
+// must hold the hub write lock.
+func (h *Hub) applyRetention(topic *Topic) {
+	if store, ok := h.store.(RetentionStore); ok {
+		logStoreError("set capacity", store.SetCapacity(topic.Name, topic.capacity()))
+	}
+}
+
+// expireRetained drops expired messages from the store, so they stop
+// holding memory before newer messages overwrite them. Reads already skip
+// expired messages, so this only reclaims space.
+func (h *Hub) expireRetained() {
+	store, ok := h.store.(RetentionStore)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	for name, topic := range h.topics {
+		dropped, err := store.Expire(name, func(message *PubSubMessage) bool {
+			return topic.expired(message, now)
+		})
+		logStoreError("expire", err)
+		h.stats.ExpiredMessages += int64(dropped)
+	}
+}
@@ -0,0 +1,294 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreBudgetEvictsLeastRecentTopics(t *testing.T) {
+	message := func(topic string, sequence int64) *PubSubMessage {
+		return &PubSubMessage{Topic: topic, Sequence: sequence, Message: &MessageData{ID: "m"}}
+	}
+	size := entrySize(message("idle", 0), 0)
+
+	store := NewMemoryStore(10, WithBudget(6*size))
+	store.CreateTopic("idle")
+	store.CreateTopic("busy")
+	for i := int64(1); i <= 3; i++ {
+		store.AppendMessage(message("idle", i))
+	}
+	for i := int64(1); i <= 5; i++ {
+		store.AppendMessage(message("busy", i))
+	}
+
+	sequences := func(topic string) string {
+		messages, _ := store.LoadRecent(topic, 0)
+		got := make([]int64, len(messages))
+		for i, message := range messages {
+			got[i] = message.Sequence
+		}
+		return fmt.Sprint(got)
+	}
+
+	// Publishing to busy evicted the oldest messages of idle
+	if got := sequences("idle"); got != "[3]" {
+		t.Errorf("Expected idle to keep only its newest message, got %v", got)
+	}
+	if got := sequences("busy"); got != "[1 2 3 4 5]" {
+		t.Errorf("Expected busy to keep all its messages, got %v", got)
+	}
+	usage := store.Usage()
+	if usage.Bytes != 6*size || usage.Evictions != 2 || usage.Budget != 6*size {
+		t.Errorf("Expected 6 messages' worth retained after 2 evictions, got %+v", usage)
+	}
+
+	// Once idle is empty, busy gives up its own oldest messages
+	store.AppendMessage(message("busy", 6))
+	store.AppendMessage(message("busy", 7))
+	if got := sequences("idle"); got != "[]" {
+		t.Errorf("Expected idle emptied, got %v", got)
+	}
+	if got := sequences("busy"); got != "[2 3 4 5 6 7]" {
+		t.Errorf("Expected busy to drop its oldest message, got %v", got)
+	}
+
+	if store.DeleteTopic("busy"); store.Usage().Bytes != 0 || store.TopicBytes("idle") != 0 {
+		t.Errorf("Expected nothing retained after deleting busy, got %+v", store.Usage())
+	}
+
+	// A message larger than the whole budget is still retained on its own
+	store.CreateTopic("large")
+	store.AppendMessage(&PubSubMessage{Topic: "large", Message: &MessageData{ID: "big", Payload: make([]int, 10*int(size))}})
+	if messages, _ := store.LoadRecent("large", 0); len(messages) != 1 {
+		t.Errorf("Expected the oversized message retained, got %d messages", len(messages))
+	}
+}
+
+func TestMemoryStoreTracksBytesWithoutBudget(t *testing.T) {
+	store := NewMemoryStore(2)
+	store.CreateTopic("orders")
+	for i := 0; i < 5; i++ {
+		store.AppendMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "m", Payload: "abc"}})
+	}
+
+	want := 2 * entrySize(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "m"}}, len(`"abc"`))
+	if usage := store.Usage(); usage.Bytes != want || usage.Evictions != 0 {
+		t.Errorf("Expected %d bytes for the 2 retained messages, got %+v", want, usage)
+	}
+	if bytes := store.TopicBytes("orders"); bytes != want {
+		t.Errorf("Expected %d bytes for orders, got %d", want, bytes)
+	}
+
+	// Re-creating a topic releases what it retained
+	store.CreateTopic("orders")
+	if usage := store.Usage(); usage.Bytes != 0 {
+		t.Errorf("Expected nothing retained after re-creating the topic, got %+v", usage)
+	}
+}
+
+func TestRetentionEventsPublished(t *testing.T) {
+	size := entrySize(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "msg-1"}}, 0)
+	opts := DefaultHubOptions()
+	opts.RetentionBudget = 10 * size
+	hub := NewHubWithOptions(opts)
+	hub.CreateTopic("orders")
+
+	watcher := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: watcher, topic: RetentionTopic})
+	retainMessages(hub, "orders", 20)
+
+	stats := hub.GetStats()
+	if stats.Retention == nil || stats.Retention.Bytes > opts.RetentionBudget || stats.Retention.Evictions == 0 {
+		t.Errorf("Expected retention within budget after evictions, got %+v", stats.Retention)
+	}
+	if topic := stats.Topics["orders"]; topic.RetainedBytes != stats.Retention.Bytes || topic.BufferOccupancy >= 20 {
+		t.Errorf("Expected orders to hold all retained bytes and fewer than 20 messages, got %+v", topic)
+	}
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	deadline := time.Now().Add(time.Second)
+	for watcher.queue.Len() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for $SYS/retention events")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Each level is reported once, however many publishes cross it
+	events := drainFrames(t, watcher)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 $SYS/retention events, got %+v", events)
+	}
+	for i, want := range []string{"warning", "evicting"} {
+		payload, _ := events[i].Message.Payload.(map[string]interface{})
+		if payload["event"] != want || payload["budget"] != float64(opts.RetentionBudget) {
+			t.Errorf("Expected a %s event, got %v", want, events[i].Message.Payload)
+		}
+	}
+}
+
+func TestMemoryStoreSetCapacityAndExpire(t *testing.T) {
+	message := func(sequence int64) *PubSubMessage {
+		return &PubSubMessage{Topic: "orders", Sequence: sequence, Message: &MessageData{ID: "m"}}
+	}
+	size := entrySize(message(0), 0)
+	sequences := func(store *MemoryStore) string {
+		messages, _ := store.LoadRecent("orders", 0)
+		got := make([]int64, len(messages))
+		for i, message := range messages {
+			got[i] = message.Sequence
+		}
+		return fmt.Sprint(got)
+	}
+
+	store := NewMemoryStore(3)
+	store.CreateTopic("orders")
+	for i := int64(1); i <= 3; i++ {
+		store.AppendMessage(message(i))
+	}
+
+	// Growing keeps everything and makes room for more
+	store.SetCapacity("orders", 5)
+	for i := int64(4); i <= 6; i++ {
+		store.AppendMessage(message(i))
+	}
+	if got := sequences(store); got != "[2 3 4 5 6]" {
+		t.Errorf("Expected the newest 5 messages, got %v", got)
+	}
+
+	// Shrinking keeps the newest
+	store.SetCapacity("orders", 2)
+	if got := sequences(store); got != "[5 6]" {
+		t.Errorf("Expected the newest 2 messages, got %v", got)
+	}
+	if bytes := store.TopicBytes("orders"); bytes != 2*size {
+		t.Errorf("Expected 2 messages' worth retained, got %d", bytes)
+	}
+
+	dropped, err := store.Expire("orders", func(m *PubSubMessage) bool { return m.Sequence == 5 })
+	if err != nil || dropped != 1 {
+		t.Fatalf("Expected 1 message expired, got %d (%v)", dropped, err)
+	}
+	store.AppendMessage(message(7))
+	if got := sequences(store); got != "[6 7]" {
+		t.Errorf("Expected expiry to free a slot, got %v", got)
+	}
+
+	if err := store.SetCapacity("missing", 5); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestRetentionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		policy RetentionPolicy
+		valid  bool
+	}{
+		{RetentionPolicy{}, true},
+		{RetentionPolicy{MaxMessages: 1000, MaxAgeMs: 60000}, true},
+		{RetentionPolicy{MaxMessages: maxRetainedMessages}, true},
+		{RetentionPolicy{MaxMessages: maxRetainedMessages + 1}, false},
+		{RetentionPolicy{MaxMessages: -1}, false},
+		{RetentionPolicy{MaxAgeMs: -1}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v): expected valid %t, got %v", tt.policy, tt.valid, err)
+		}
+	}
+
+	hub := NewHub()
+	err := hub.CreateTopicWithOptions("orders", TopicOptions{Retention: &RetentionPolicy{MaxMessages: -1}})
+	if !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("Expected ErrInvalidRetention, got %v", err)
+	}
+}
+
+func TestRetentionPolicyMaxMessages(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Retention: &RetentionPolicy{MaxMessages: 250}})
+	hub.CreateTopicWithOptions("prices", TopicOptions{Retention: &RetentionPolicy{MaxMessages: 10}})
+	for topic, n := range map[string]int{"orders": 300, "prices": 20} {
+		// Record without fan-out, which would overflow the test client
+		hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: topic})
+		for i := 1; i <= n; i++ {
+			hub.recordMessage(&PubSubMessage{Topic: topic, Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+		}
+	}
+
+	if retained := hub.GetRecentMessages("orders", 0); len(retained) != 250 || retained[0].Sequence != 51 {
+		t.Errorf("Expected orders to retain its newest 250 messages, got %d", len(retained))
+	}
+	if retained := hub.GetRecentMessages("prices", 0); len(retained) != 10 || retained[0].Sequence != 11 {
+		t.Errorf("Expected prices to retain its newest 10 messages, got %d", len(retained))
+	}
+
+	stats, _ := hub.GetTopicStats("orders")
+	if stats.BufferCapacity != 250 || stats.BufferOccupancy != 250 || stats.Retention.MaxMessages != 250 {
+		t.Errorf("Expected a full 250-message buffer, got %+v", stats)
+	}
+}
+
+func TestRetentionSkipsExpiredMessages(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.CreateTopicWithOptions("prices", TopicOptions{Retention: &RetentionPolicy{MaxAgeMs: 60000}})
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: "orders"})
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: "prices"})
+
+	old := time.Now().Add(-2 * time.Minute)
+	publish := func(topic, id string, timestamp time.Time, ttl int64) {
+		hub.publishMessage(&PubSubMessage{Topic: topic, Timestamp: timestamp, Message: &MessageData{ID: id, TTLMs: ttl}})
+	}
+	publish("orders", "expired", old, 60000)
+	publish("orders", "forever", old, 0)
+	publish("orders", "fresh", time.Now(), 60000)
+	publish("prices", "stale", old, 0)
+	publish("prices", "recent", time.Now(), 0)
+
+	ids := func(topic string) string {
+		var got []string
+		for _, message := range hub.GetRecentMessages(topic, 0) {
+			got = append(got, message.Message.ID)
+		}
+		return fmt.Sprint(got)
+	}
+	if got := ids("orders"); got != "[forever fresh]" {
+		t.Errorf("Expected messages past their TTL skipped, got %v", got)
+	}
+	if got := ids("prices"); got != "[recent]" {
+		t.Errorf("Expected messages past the max age skipped, got %v", got)
+	}
+
+	// Expiry drops them from the store too
+	hub.expireRetained()
+	if got := hub.GetStats().ExpiredMessages; got != 2 {
+		t.Errorf("Expected 2 expired messages, got %d", got)
+	}
+	if messages, _ := hub.store.LoadRecent("orders", 0); len(messages) != 2 {
+		t.Errorf("Expected 2 orders messages left in the store, got %d", len(messages))
+	}
+}
+
+func TestRetentionPolicySurvivesSnapshot(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Retention: &RetentionPolicy{MaxMessages: 150, MaxAgeMs: 60000}})
+	hub.subscribeClient(&Subscription{client: newTestClient(hub), topic: "orders"})
+	for i := 1; i <= 200; i++ {
+		hub.recordMessage(&PubSubMessage{Topic: "orders", Timestamp: time.Now(), Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+
+	restored := NewHub()
+	if result := restored.Restore(hub.Snapshot()); result.Topics != 1 || result.Messages != 150 {
+		t.Fatalf("Expected 1 topic with 150 messages restored, got %+v", result)
+	}
+	stats, _ := restored.GetTopicStats("orders")
+	if stats.Retention != (RetentionPolicy{MaxMessages: 150, MaxAgeMs: 60000}) || stats.BufferOccupancy != 150 {
+		t.Errorf("Expected the retention policy restored, got %+v", stats)
+	}
+}
@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxPingData bounds the data a ping may ask to have echoed, in bytes
+const MaxPingData = 1024
+
+// slowestClients is how many clients /stats lists by round-trip time
+const slowestClients = 5
+
+// RTTStats summarizes a client's round-trip times, measured from the
+// server's WebSocket keepalive pings to the client's pongs
+type RTTStats struct {
+	// Samples is how many pongs have been timed
+	Samples int64   `json:"samples"`
+	LastMs  float64 `json:"last_ms"`
+	MinMs   float64 `json:"min_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	// MeasuredAt is when the last pong was timed, unset before the first
+	MeasuredAt *time.Time `json:"measured_at,omitempty"`
+}
+
+// rttTracker accumulates a client's round-trip times. Pongs are timed on
+// the read pump while stats are read from REST handlers, hence the lock.
+type rttTracker struct {
+	mu         sync.Mutex
+	samples    int64
+	last       time.Duration
+	min        time.Duration
+	max        time.Duration
+	sum        time.Duration
+	measuredAt time.Time
+}
+
+// record adds one round-trip time
+func (r *rttTracker) record(rtt time.Duration, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == 0 || rtt < r.min {
+		r.min = rtt
+	}
+	if rtt > r.max {
+		r.max = rtt
+	}
+	r.samples++
+	r.last = rtt
+	r.sum += rtt
+	r.measuredAt = now
+}
+
+// snapshot returns the round-trip times recorded so far
+func (r *rttTracker) snapshot() RTTStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == 0 {
+		return RTTStats{}
+	}
+	measuredAt := r.measuredAt
+	return RTTStats{
+		Samples:    r.samples,
+		LastMs:     milliseconds(r.last),
+		MinMs:      milliseconds(r.min),
+		MeanMs:     milliseconds(r.sum / time.Duration(r.samples)),
+		MaxMs:      milliseconds(r.max),
+		MeasuredAt: &measuredAt,
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// pingData is the application data of a keepalive ping: the time it was
+// sent, which the client's pong echoes back
+func pingData(now time.Time) []byte {
+	return strconv.AppendInt(nil, now.UnixNano(), 10)
+}
+
+// timePong records the round-trip time of a pong echoing a keepalive
+// ping's data. Pongs that don't echo a send time, such as unsolicited
+// ones, are ignored.
+func (c *Client) timePong(data string, now time.Time) {
+	sent, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || sent <= 0 {
+		return
+	}
+	if rtt := now.Sub(time.Unix(0, sent)); rtt >= 0 {
+		c.rtt.record(rtt, now)
+	}
+}
+
+// validatePingData checks the data a ping asks to have echoed
+func validatePingData(data json.RawMessage) error {
+	if len(data) > MaxPingData {
+		return fmt.Errorf("ping data must be at most %d bytes, got %d", MaxPingData, len(data))
+	}
+	return nil
+}
+
+// ClientRTT is one client's latest round-trip time
+type ClientRTT struct {
+	ClientID string  `json:"client_id"`
+	LastMs   float64 `json:"last_ms"`
+}
+
+// RTTSummary summarizes round-trip times across connected WebSocket
+// clients, listing the slowest so clients on degraded networks stand out
+type RTTSummary struct {
+	// Clients is how many connected clients have been timed
+	Clients int     `json:"clients"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	// Slowest are the clients with the longest latest round-trip times,
+	// slowest first
+	Slowest []ClientRTT `json:"slowest"`
+}
+
+// rttSummary summarizes the latest round-trip times of the connected
+// clients. Caller must hold the hub lock.
+func (h *Hub) rttSummary() RTTSummary {
+	summary := RTTSummary{Slowest: []ClientRTT{}}
+	var total float64
+	for client := range h.clients {
+		rtt := client.rtt.snapshot()
+		if rtt.Samples == 0 {
+			continue
+		}
+		summary.Clients++
+		total += rtt.LastMs
+		summary.MaxMs = max(summary.MaxMs, rtt.LastMs)
+		summary.Slowest = append(summary.Slowest, ClientRTT{ClientID: client.id, LastMs: rtt.LastMs})
+	}
+	if summary.Clients > 0 {
+		summary.MeanMs = total / float64(summary.Clients)
+	}
+
+	sort.Slice(summary.Slowest, func(i, j int) bool {
+		if summary.Slowest[i].LastMs != summary.Slowest[j].LastMs {
+			return summary.Slowest[i].LastMs > summary.Slowest[j].LastMs
+		}
+		return summary.Slowest[i].ClientID < summary.Slowest[j].ClientID
+	})
+	if len(summary.Slowest) > slowestClients {
+		summary.Slowest = summary.Slowest[:slowestClients]
+	}
+	return summary
+}
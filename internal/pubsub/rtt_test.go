@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPongsAreTimed(t *testing.T) {
+	client := newTestClient(NewHub())
+	now := time.Now()
+
+	for _, rtt := range []time.Duration{30 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond} {
+		client.timePong(string(pingData(now.Add(-rtt))), now)
+	}
+	// Pongs not echoing a send time are ignored
+	client.timePong("", now)
+	client.timePong("hello", now)
+
+	stats := client.rtt.snapshot()
+	if stats.Samples != 3 || stats.LastMs != 20 || stats.MinMs != 10 || stats.MaxMs != 30 || stats.MeanMs != 20 {
+		t.Errorf("Expected 3 samples of 10-30ms, got %+v", stats)
+	}
+	if stats.MeasuredAt == nil || !stats.MeasuredAt.Equal(now) {
+		t.Errorf("Expected the measurement time, got %v", stats.MeasuredAt)
+	}
+}
+
+func TestPingDataIsEchoed(t *testing.T) {
+	client := newTestClient(NewHub())
+
+	client.handleMessage(&ClientMessage{Type: PingMessage, RequestID: "p1", Data: json.RawMessage(`{"sent":1700000000000}`)})
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != PongMessage || string(frames[0].Data) != `{"sent":1700000000000}` {
+		t.Fatalf("Expected a pong echoing the ping's data, got %+v", frames)
+	}
+
+	client.handleMessage(&ClientMessage{Type: PingMessage, RequestID: "p2", Data: json.RawMessage(`"` + strings.Repeat("x", MaxPingData) + `"`)})
+	frames = drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Type != ErrorMessage || frames[0].Error.Code != CodeBadRequest {
+		t.Errorf("Expected BAD_REQUEST for oversized ping data, got %+v", frames)
+	}
+}
+
+func TestGetClientAndRTTSummary(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	now := time.Now()
+
+	var clients []*Client
+	for i, rtt := range []time.Duration{5, 250, 40} {
+		client := newTestClient(hub)
+		client.id = []string{"fast", "slow", "medium"}[i]
+		client.timePong(string(pingData(now.Add(-rtt*time.Millisecond))), now)
+		hub.clients[client] = true
+		clients = append(clients, client)
+	}
+	clients[1].subscriptions["orders"] = true
+
+	info, err := hub.GetClient("slow")
+	if err != nil {
+		t.Fatalf("GetClient failed: %v", err)
+	}
+	if info.ID != "slow" || len(info.Subscriptions) != 1 || info.Subscriptions[0] != "orders" || info.RTT.LastMs != 250 {
+		t.Errorf("Expected the slow client's details, got %+v", info)
+	}
+	if _, err := hub.GetClient("missing"); !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("Expected ErrClientNotFound, got %v", err)
+	}
+
+	summary := hub.GetStats().RTT
+	if summary == nil || summary.Clients != 3 || summary.MaxMs != 250 {
+		t.Fatalf("Expected 3 timed clients with a 250ms max, got %+v", summary)
+	}
+	if len(summary.Slowest) != 3 || summary.Slowest[0].ClientID != "slow" || summary.Slowest[2].ClientID != "fast" {
+		t.Errorf("Expected clients listed slowest first, got %+v", summary.Slowest)
+	}
+}
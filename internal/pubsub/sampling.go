@@ -0,0 +1,53 @@
+package pubsub
+
+import "fmt"
+
+// sampleCapacity is how many sampled publishes each sampling topic keeps;
+// older samples are dropped as new ones arrive
+const sampleCapacity = 100
+
+// validateSampleEvery checks a sampling rate: 0 for none, or 1 in N
+// publishes
+func validateSampleEvery(every int) error {
+	if every < 0 {
+		return fmt.Errorf("%w: sample_every must not be negative", ErrInvalidSampling)
+	}
+	return nil
+}
+
+// sample keeps the publish if it is one in the topic's sampling rate,
+// counting by sequence so exactly one in every sampleEvery is kept. Caller
+// must hold the hub write lock.
+func (t *Topic) sample(message *PubSubMessage) {
+	if t.sampleEvery == 0 || message.Sequence%int64(t.sampleEvery) != 0 {
+		return
+	}
+	t.samples = append(t.samples, message)
+	if len(t.samples) > sampleCapacity {
+		t.samples = append(t.samples[:0:0], t.samples[len(t.samples)-sampleCapacity:]...)
+	}
+	t.sampled++
+}
+
+// setSampleEvery changes the topic's sampling rate. Samples taken at the
+// old rate are dropped, so the buffer only shows one rate at a time.
+// Caller must hold the hub write lock.
+func (t *Topic) setSampleEvery(every int) {
+	if every != t.sampleEvery {
+		t.samples = nil
+	}
+	t.sampleEvery = every
+}
+
+// TopicSamples returns a topic's sampled publishes, oldest first, for
+// inspecting the shape of its traffic without subscribing
+func (h *Hub) TopicSamples(name string) ([]*PubSubMessage, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[name]
+	if !exists {
+		return nil, ErrTopicNotFound
+	}
+	return append([]*PubSubMessage(nil), topic.samples...), nil
+}
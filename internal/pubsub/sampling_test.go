@@ -0,0 +1,58 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSamplingKeepsOneInN(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{SampleEvery: 10}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+
+	for i := 1; i <= 1005; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+
+	samples, err := hub.TopicSamples("orders")
+	if err != nil {
+		t.Fatalf("TopicSamples failed: %v", err)
+	}
+	// 100 of the 1005 publishes are sampled, and all fit
+	if len(samples) != sampleCapacity || samples[0].Message.ID != "msg-10" || samples[len(samples)-1].Message.ID != "msg-1000" {
+		t.Fatalf("Expected every tenth publish from msg-10 to msg-1000, got %d from %s", len(samples), samples[0].Message.ID)
+	}
+
+	for i := 1006; i <= 1100; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+	}
+	samples, _ = hub.TopicSamples("orders")
+	if len(samples) != sampleCapacity || samples[0].Message.ID != "msg-110" {
+		t.Errorf("Expected the oldest samples dropped, got %d from %s", len(samples), samples[0].Message.ID)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.SampleEvery != 10 || stats.Sampled != 110 {
+		t.Errorf("Expected 110 publishes sampled 1 in 10, got %+v", stats)
+	}
+
+	// Changing the rate starts over, and 0 stops sampling
+	zero := 0
+	if _, err := hub.UpdateTopic("orders", TopicUpdate{SampleEvery: &zero}, 0); err != nil {
+		t.Fatalf("UpdateTopic failed: %v", err)
+	}
+	hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "after"}})
+	if samples, _ := hub.TopicSamples("orders"); len(samples) != 0 {
+		t.Errorf("Expected no samples once sampling stopped, got %d", len(samples))
+	}
+}
+
+func TestSamplingValidation(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{SampleEvery: -1}); !errors.Is(err, ErrInvalidSampling) {
+		t.Errorf("Expected ErrInvalidSampling, got %v", err)
+	}
+	if _, err := hub.TopicSamples("missing"); !errors.Is(err, ErrTopicNotFound) {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+}
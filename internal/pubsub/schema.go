@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// TopicSchema is a versioned JSON Schema document registered for a topic
+type TopicSchema struct {
+	Topic     string          `json:"topic"`
+	Version   int             `json:"version"`
+	Schema    json.RawMessage `json:"schema" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+	compiled  *jsonschema.Schema
+}
+
+// compileSchema parses and compiles a JSON Schema document
+func compileSchema(topic string, version int, doc json.RawMessage) (*jsonschema.Schema, error) {
+	url := fmt.Sprintf("plivo://topics/%s/schema/v%d.json", topic, version)
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, bytes.NewReader(doc)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return compiled, nil
+}
+
+// Validate checks a message payload against the schema
+func (s *TopicSchema) Validate(data *MessageData) error {
+	var payload interface{}
+	if data != nil {
+		payload = data.Payload
+	}
+
+	// Normalize the payload to plain JSON values so that payloads built from
+	// Go types validate the same way as decoded ones
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	return s.compiled.Validate(value)
+}
+
+// SetTopicSchema registers a new schema version for a topic and returns it
+func (h *Hub) SetTopicSchema(topicName string, doc json.RawMessage) (*TopicSchema, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return nil, ErrTopicNotFound
+	}
+
+	schema, err := newTopicSchema(topicName, len(topic.schemas)+1, doc, h.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	topic.schemas = append(topic.schemas, schema)
+	topic.revision++
+	h.persist("save schema", func(s Storage) error { return s.SaveTopic(topic.snapshot()) })
+	return schema, nil
+}
+
+// newTopicSchema compiles a schema document as a topic's given version,
+// registered at createdAt
+func newTopicSchema(topicName string, version int, doc json.RawMessage, createdAt time.Time) (*TopicSchema, error) {
+	compiled, err := compileSchema(topicName, version, doc)
+	if err != nil {
+		return nil, err
+	}
+	return &TopicSchema{
+		Topic:     topicName,
+		Version:   version,
+		Schema:    append(json.RawMessage(nil), doc...),
+		CreatedAt: createdAt,
+		compiled:  compiled,
+	}, nil
+}
+
+// GetTopicSchema returns a schema version for a topic; version 0 returns the latest
+func (h *Hub) GetTopicSchema(topicName string, version int) (*TopicSchema, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return nil, ErrTopicNotFound
+	}
+
+	if len(topic.schemas) == 0 {
+		return nil, ErrSchemaNotFound
+	}
+
+	if version == 0 {
+		return topic.schemas[len(topic.schemas)-1], nil
+	}
+	if version < 0 || version > len(topic.schemas) {
+		return nil, ErrSchemaNotFound
+	}
+	return topic.schemas[version-1], nil
+}
+
+// checkSchema refuses a payload that doesn't validate against the topic's
+// latest schema with ErrSchemaViolation, saying where and why, and returns
+// the version it validated against otherwise. Topics without a schema, and
+// payloads that aren't JSON, are not checked and return version 0. Caller
+// must hold the hub lock.
+func (t *Topic) checkSchema(data *MessageData) (int, error) {
+	if len(t.schemas) == 0 || !data.isJSON() {
+		return 0, nil
+	}
+
+	latest := t.schemas[len(t.schemas)-1]
+	err := latest.Validate(data)
+	if err == nil {
+		return latest.Version, nil
+	}
+	var invalid *jsonschema.ValidationError
+	if errors.As(err, &invalid) {
+		// The innermost cause names the offending value
+		for len(invalid.Causes) > 0 {
+			invalid = invalid.Causes[0]
+		}
+		location := invalid.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return 0, fmt.Errorf("%w version %d: %s: %s", ErrSchemaViolation, latest.Version, location, invalid.Message)
+	}
+	return 0, fmt.Errorf("%w version %d: %v", ErrSchemaViolation, latest.Version, err)
+}
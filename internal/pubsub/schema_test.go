@@ -0,0 +1,194 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["order_id", "amount"],
+	"properties": {
+		"order_id": {"type": "string"},
+		"amount": {"type": "number", "minimum": 0}
+	}
+}`
+
+func TestSetTopicSchemaVersions(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	v1, err := hub.SetTopicSchema("orders", json.RawMessage(orderSchema))
+	if err != nil {
+		t.Fatalf("SetTopicSchema failed: %v", err)
+	}
+	if v1.Version != 1 {
+		t.Errorf("Expected version 1, got %d", v1.Version)
+	}
+
+	v2, err := hub.SetTopicSchema("orders", json.RawMessage(`{"type": "object"}`))
+	if err != nil {
+		t.Fatalf("SetTopicSchema failed: %v", err)
+	}
+	if v2.Version != 2 {
+		t.Errorf("Expected version 2, got %d", v2.Version)
+	}
+
+	latest, err := hub.GetTopicSchema("orders", 0)
+	if err != nil || latest.Version != 2 {
+		t.Errorf("Expected latest version 2, got %v (err %v)", latest, err)
+	}
+
+	first, err := hub.GetTopicSchema("orders", 1)
+	if err != nil || first.Version != 1 {
+		t.Errorf("Expected version 1, got %v (err %v)", first, err)
+	}
+
+	if _, err := hub.GetTopicSchema("orders", 3); err != ErrSchemaNotFound {
+		t.Errorf("Expected ErrSchemaNotFound for unknown version, got %v", err)
+	}
+}
+
+func TestSetTopicSchemaErrors(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	if _, err := hub.SetTopicSchema("missing", json.RawMessage(orderSchema)); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+
+	if _, err := hub.SetTopicSchema("orders", json.RawMessage(`{"type": 42}`)); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("Expected ErrInvalidSchema, got %v", err)
+	}
+
+	if _, err := hub.GetTopicSchema("orders", 0); err != ErrSchemaNotFound {
+		t.Errorf("Expected ErrSchemaNotFound when no schema is registered, got %v", err)
+	}
+}
+
+func TestPublishStampsSchemaVersion(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.SetTopicSchema("orders", json.RawMessage(orderSchema))
+
+	client := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: client, topic: "orders"})
+
+	first := &PubSubMessage{
+		Topic:     "orders",
+		Message:   &MessageData{ID: "msg-1", Payload: map[string]interface{}{"order_id": "ORD-1", "amount": 9.5}},
+		Timestamp: time.Now(),
+	}
+	second := &PubSubMessage{
+		Topic:     "orders",
+		Message:   &MessageData{ID: "msg-2", Payload: map[string]interface{}{"order_id": "ORD-2", "amount": 3}},
+		Timestamp: time.Now(),
+	}
+
+	// A message keeps the version it matched when admitted, even if a
+	// newer schema is registered before it is published
+	if _, err := hub.admitPublish(first); err != nil {
+		t.Fatalf("admitPublish failed: %v", err)
+	}
+	hub.SetTopicSchema("orders", json.RawMessage(`{"type": "object"}`))
+	if _, err := hub.admitPublish(second); err != nil {
+		t.Fatalf("admitPublish failed: %v", err)
+	}
+	hub.publishMessage(first)
+	hub.publishMessage(second)
+
+	frames := drainFrames(t, client)
+	if len(frames) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(frames))
+	}
+	if frames[0].SchemaVersion != 1 {
+		t.Errorf("Expected the first payload stamped with schema version 1, got %d", frames[0].SchemaVersion)
+	}
+	if frames[1].SchemaVersion != 2 {
+		t.Errorf("Expected the second payload stamped with schema version 2, got %d", frames[1].SchemaVersion)
+	}
+}
+
+func TestSchemaSkipsNonJSONPayloads(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("logs")
+	hub.SetTopicSchema("logs", json.RawMessage(`{"type": "string"}`))
+	retainMessages(hub, "logs", 0)
+
+	text, err := NewMessage("logs", "disk full", WithID("msg-1"), WithContentType(ContentTypeText))
+	if err != nil {
+		t.Fatalf("NewMessage failed: %v", err)
+	}
+	hub.publishMessage(text)
+
+	if text.SchemaVersion != 0 {
+		t.Errorf("Expected text/plain message to be unstamped, got version %d", text.SchemaVersion)
+	}
+
+	// The content type is retained for replay
+	_, backlog := hub.prepareReplay("logs", 1, "")
+	if len(backlog) != 1 || backlog[0].Message.ContentType != ContentTypeText {
+		t.Errorf("Expected the retained message to keep text/plain, got %+v", backlog)
+	}
+}
+
+func TestPublishRejectsSchemaViolations(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{Schema: json.RawMessage(orderSchema)}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+	subscriber := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: subscriber, topic: "orders"})
+
+	publisher := newTestClient(hub)
+	publisher.handleMessage(&ClientMessage{
+		Type:      PublishMessage,
+		Topic:     "orders",
+		RequestID: "req-1",
+		Message:   &MessageData{ID: "msg-1", Payload: map[string]interface{}{"order_id": "ORD-1", "amount": -1}},
+	})
+	frames := drainFrames(t, publisher)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeSchemaViolation {
+		t.Fatalf("Expected a SCHEMA_VIOLATION error, got %+v", frames)
+	}
+	if message := frames[0].Error.Message; message != "payload does not match the topic schema version 1: /amount: must be >= 0 but found -1" {
+		t.Errorf("Unexpected error message %q", message)
+	}
+
+	data := []*MessageData{
+		{ID: "msg-2", Payload: map[string]interface{}{"order_id": "ORD-2", "amount": 1}},
+		{ID: "msg-3", Payload: map[string]interface{}{"order_id": "ORD-3"}},
+	}
+	_, err := hub.PublishBatch(context.Background(), "orders", data)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || CodeOf(err) != CodeSchemaViolation {
+		t.Fatalf("Expected the batch rejected for message 1, got %v", err)
+	}
+	if batchErr.Results[0].Status != BatchAborted || batchErr.Results[1].Status != BatchRejected {
+		t.Errorf("Expected the valid message aborted and the invalid one rejected, got %+v", batchErr.Results)
+	}
+
+	if receipt, err := hub.PublishDirect(context.Background(), "orders", data[0]); err != nil {
+		t.Fatalf("Expected a conforming payload published, got %v", err)
+	} else if receipt.ID != "msg-2" {
+		t.Errorf("Expected msg-2 published, got %+v", receipt)
+	}
+	hub.dispatchPublishes()
+	events := drainFrames(t, subscriber)
+	if len(events) != 1 || events[0].SchemaVersion != 1 {
+		t.Errorf("Expected only the conforming payload delivered, stamped with version 1, got %+v", events)
+	}
+}
+
+func TestCreateTopicWithInvalidSchema(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{Schema: json.RawMessage(`{"type": 42}`)}); !errors.Is(err, ErrInvalidSchema) {
+		t.Errorf("Expected ErrInvalidSchema, got %v", err)
+	}
+	if hub.TopicExists("orders") {
+		t.Error("Expected the topic not created")
+	}
+}
@@ -0,0 +1,211 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Topic label limits
+const (
+	// MaxTopicLabels is the most labels a topic may carry
+	MaxTopicLabels = 32
+	// MaxLabelKeyLength and MaxLabelValueLength bound each label in bytes
+	MaxLabelKeyLength   = 64
+	MaxLabelValueLength = 256
+)
+
+// validateLabels bounds the number and size of a topic's labels
+func validateLabels(labels map[string]string) error {
+	if len(labels) > MaxTopicLabels {
+		return fmt.Errorf("%w: exceeds %d entries", ErrInvalidLabels, MaxTopicLabels)
+	}
+	for key, value := range labels {
+		switch {
+		case key == "":
+			return fmt.Errorf("%w: keys must not be empty", ErrInvalidLabels)
+		case len(key) > MaxLabelKeyLength:
+			return fmt.Errorf("%w: key %q exceeds %d bytes", ErrInvalidLabels, key[:16]+"...", MaxLabelKeyLength)
+		case len(value) > MaxLabelValueLength:
+			return fmt.Errorf("%w: value of %q exceeds %d bytes", ErrInvalidLabels, key, MaxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// TopicUpdate changes an existing topic's settings. Nil fields are left as
+// they are, so an update only names what it changes.
+type TopicUpdate struct {
+	// Replay replaces the topic's last_n limits
+	Replay *ReplayLimits `json:"replay,omitempty"`
+	// Retention replaces the retention policy; messages beyond a smaller
+	// max_messages are dropped at once
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Weight replaces the fan-out scheduling weight, 0 for the default
+	Weight *int `json:"weight,omitempty"`
+	// Enrich turns server metadata headers on or off
+	Enrich *bool `json:"enrich,omitempty"`
+	// Labels replaces the topic's labels; an empty object clears them
+	Labels map[string]string `json:"labels,omitempty"`
+	// DeadLetter replaces the dead-letter topic, created if it doesn't
+	// exist; "" stops dead lettering
+	DeadLetter *string `json:"dead_letter,omitempty"`
+	// Shadow replaces the shadow topic and the share of publishes copied
+	// to it, created if it doesn't exist; one without a topic stops
+	// shadowing
+	Shadow *Shadow `json:"shadow,omitempty"`
+	// SampleEvery replaces the sampling rate, keeping one in every
+	// SampleEvery publishes for inspection; 0 stops sampling. Samples taken
+	// at the old rate are dropped.
+	SampleEvery *int `json:"sample_every,omitempty"`
+	// DedupWindowMs replaces how long published message IDs are
+	// remembered to drop retries; 0 stops dedup and forgets them
+	DedupWindowMs *int64 `json:"dedup_window_ms,omitempty"`
+	// Schema registers a new schema version, which published messages are
+	// then checked against
+	Schema json.RawMessage `json:"schema,omitempty" swaggertype:"object"`
+}
+
+// validate checks every setting the update changes, short of compiling its
+// schema
+func (u *TopicUpdate) validate() error {
+	if u.Replay != nil {
+		if err := u.Replay.Validate(); err != nil {
+			return err
+		}
+	}
+	if u.Retention != nil {
+		if err := u.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+	if u.Weight != nil {
+		if err := validateWeight(*u.Weight); err != nil {
+			return err
+		}
+	}
+	if u.Labels != nil {
+		return validateLabels(u.Labels)
+	}
+	return nil
+}
+
+// UpdateTopic applies an update to an existing topic's settings, keeping
+// its messages, subscribers and groups. With a non-zero revision the update
+// only applies if the topic is still at that revision, and fails with
+// ErrRevisionMismatch otherwise, so concurrent editors don't overwrite each
+// other. Updates are all or nothing: an invalid setting or schema changes
+// nothing. Each applied update advances the topic's revision.
+func (h *Hub) UpdateTopic(name string, update TopicUpdate, revision int64) (TopicStats, error) {
+	if err := update.validate(); err != nil {
+		return TopicStats{}, err
+	}
+	if update.DeadLetter != nil {
+		if err := validateDeadLetter(name, *update.DeadLetter); err != nil {
+			return TopicStats{}, err
+		}
+	}
+	if update.Shadow != nil && update.Shadow.Topic != "" {
+		if err := update.Shadow.validate(name); err != nil {
+			return TopicStats{}, err
+		}
+	}
+	if update.SampleEvery != nil {
+		if err := validateSampleEvery(*update.SampleEvery); err != nil {
+			return TopicStats{}, err
+		}
+	}
+	if update.DedupWindowMs != nil {
+		if err := validateDedupWindow(*update.DedupWindowMs); err != nil {
+			return TopicStats{}, err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic, exists := h.topics[name]
+	if !exists {
+		return TopicStats{}, ErrTopicNotFound
+	}
+	if revision != 0 && revision != topic.revision {
+		return TopicStats{}, fmt.Errorf("%w: topic is at revision %d", ErrRevisionMismatch, topic.revision)
+	}
+
+	// The topic's last_n cap must still fit what it retains
+	replay, retention := topic.replay, topic.retention
+	if update.Replay != nil {
+		replay = update.Replay
+	}
+	if update.Retention != nil {
+		retention = update.Retention
+	}
+	if replay != nil && (update.Replay != nil || update.Retention != nil) {
+		if err := replay.fits(retention.capacity(topic.ringBufferSize)); err != nil {
+			return TopicStats{}, err
+		}
+	}
+
+	// Compile the schema before changing anything else
+	var schema *TopicSchema
+	if len(update.Schema) > 0 {
+		var err error
+		if schema, err = newTopicSchema(name, len(topic.schemas)+1, update.Schema, h.clock.Now()); err != nil {
+			return TopicStats{}, err
+		}
+	}
+
+	if update.Replay != nil {
+		topic.replay = update.Replay
+	}
+	if update.Retention != nil {
+		topic.retention = update.Retention
+		if store, ok := h.store.(RetentionStore); ok {
+			logStoreError("set capacity", store.SetCapacity(name, topic.capacity()))
+		}
+	}
+	if update.Weight != nil {
+		topic.weight = *update.Weight
+	}
+	if update.Enrich != nil {
+		topic.enrich = *update.Enrich
+	}
+	if update.Labels != nil {
+		topic.labels = copyLabels(update.Labels)
+	}
+	if schema != nil {
+		topic.schemas = append(topic.schemas, schema)
+	}
+	if update.DeadLetter != nil {
+		topic.deadLetter = *update.DeadLetter
+	}
+	if update.Shadow != nil {
+		topic.shadow = nil
+		if update.Shadow.Topic != "" {
+			topic.shadow = copyShadow(update.Shadow)
+		}
+	}
+	if update.SampleEvery != nil {
+		topic.setSampleEvery(*update.SampleEvery)
+	}
+	if update.DedupWindowMs != nil {
+		topic.setDedupWindow(*update.DedupWindowMs)
+	}
+	topic.revision++
+	h.persist("update topic", func(s Storage) error { return s.SaveTopic(topic.snapshot()) })
+	h.ensureDeadLetterTopic(topic)
+	h.ensureShadowTopic(topic)
+
+	return h.topicStats(topic), nil
+}
+
+// copyLabels copies a label set, returning nil for an empty one
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,127 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateTopicKeepsMessages(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Labels: map[string]string{"team": "checkout"}})
+	retainMessages(hub, "orders", 20)
+
+	weight, enrich := 3, true
+	stats, err := hub.UpdateTopic("orders", TopicUpdate{
+		Retention: &RetentionPolicy{MaxMessages: 5},
+		Weight:    &weight,
+		Enrich:    &enrich,
+		Labels:    map[string]string{"team": "payments", "tier": "gold"},
+		Schema:    json.RawMessage(`{"type": "object"}`),
+	}, 1)
+	if err != nil {
+		t.Fatalf("UpdateTopic failed: %v", err)
+	}
+
+	if stats.Revision != 2 || stats.Weight != 3 || !stats.Enrich {
+		t.Errorf("Expected the update applied at revision 2, got %+v", stats)
+	}
+	if schema, err := hub.GetTopicSchema("orders", 0); err != nil || schema.Version != 1 {
+		t.Errorf("Expected schema version 1 registered, got %v, %v", schema, err)
+	}
+	if stats.Labels["team"] != "payments" || stats.Labels["tier"] != "gold" {
+		t.Errorf("Expected the labels replaced, got %v", stats.Labels)
+	}
+	// Shrinking retention keeps the newest messages rather than wiping them
+	retained := hub.GetRecentMessages("orders", 100)
+	if len(retained) != 5 || retained[0].Sequence != 16 || stats.MessageCount != 20 {
+		t.Errorf("Expected messages 16-20 retained of 20, got %d of %d", len(retained), stats.MessageCount)
+	}
+
+	// Fields left out are unchanged, and empty labels clear them
+	stats, err = hub.UpdateTopic("orders", TopicUpdate{Labels: map[string]string{}}, 0)
+	if err != nil {
+		t.Fatalf("Unconditional UpdateTopic failed: %v", err)
+	}
+	if stats.Revision != 3 || stats.Weight != 3 || stats.Labels != nil {
+		t.Errorf("Expected only the labels cleared, got %+v", stats)
+	}
+}
+
+func TestUpdateTopicRevisionMismatch(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	if _, err := hub.SetTopicSchema("orders", json.RawMessage(`{"type": "object"}`)); err != nil {
+		t.Fatalf("SetTopicSchema failed: %v", err)
+	}
+
+	// The schema registration moved the topic to revision 2
+	weight := 2
+	_, err := hub.UpdateTopic("orders", TopicUpdate{Weight: &weight}, 1)
+	if !errors.Is(err, ErrRevisionMismatch) || CodeOf(err) != CodeRevisionMismatch {
+		t.Fatalf("Expected ErrRevisionMismatch, got %v", err)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.Weight != 1 || stats.Revision != 2 {
+		t.Errorf("Expected the topic unchanged at revision 2, got %+v", stats)
+	}
+
+	if _, err := hub.UpdateTopic("missing", TopicUpdate{}, 0); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestUpdateTopicIsAllOrNothing(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+
+	weight := 5
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxTopicLabels; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	tests := []struct {
+		name   string
+		update TopicUpdate
+		want   error
+	}{
+		{"bad retention", TopicUpdate{Weight: &weight, Retention: &RetentionPolicy{MaxMessages: -1}}, ErrInvalidRetention},
+		{"too many labels", TopicUpdate{Weight: &weight, Labels: tooMany}, ErrInvalidLabels},
+		{"empty label key", TopicUpdate{Weight: &weight, Labels: map[string]string{"": "v"}}, ErrInvalidLabels},
+		{"bad schema", TopicUpdate{Weight: &weight, Schema: json.RawMessage(`{"type": 5}`)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := hub.UpdateTopic("orders", tt.update, 0)
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("Expected %v, got %v", tt.want, err)
+			}
+			if stats, _ := hub.GetTopicStats("orders"); stats.Weight != 1 || stats.Revision != 1 {
+				t.Errorf("Expected the topic unchanged, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestTopicSettingsSurviveSnapshot(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Labels: map[string]string{"team": "checkout"}})
+	hub.TransferTopic("orders", "acme")
+
+	snapshot := hub.Snapshot()
+	restored := NewHub()
+	if result := restored.Restore(snapshot); len(result.Skipped) != 0 {
+		t.Fatalf("Restore skipped %v", result.Skipped)
+	}
+
+	stats, err := restored.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Revision != 2 || stats.Labels["team"] != "checkout" {
+		t.Errorf("Expected revision 2 and the labels restored, got %+v", stats)
+	}
+}
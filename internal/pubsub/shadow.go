@@ -0,0 +1,118 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+
+	"plivo/internal/logging"
+)
+
+// Headers stamped on shadow copies, naming the publish they duplicate. A
+// message carrying them is never shadowed again, so shadow topics can't
+// feed each other in a loop.
+const (
+	// ShadowHeaderPrefix starts every shadow header
+	ShadowHeaderPrefix = ReservedHeaderPrefix + "shadow."
+	// ShadowSourceTopicHeader is the topic the message was published to
+	ShadowSourceTopicHeader = ShadowHeaderPrefix + "topic"
+	// ShadowSourceSequenceHeader is the message's sequence in that topic
+	ShadowSourceSequenceHeader = ShadowHeaderPrefix + "sequence"
+)
+
+// Shadow duplicates a sample of a topic's publishes into a shadow topic, so
+// new consumers can be tried against realistic traffic without subscribing
+// to all of it
+type Shadow struct {
+	// Topic receives the copies, created if it doesn't exist
+	Topic string `json:"topic"`
+	// Percent is the share of publishes copied, over 0 and up to 100
+	Percent float64 `json:"percent"`
+}
+
+// validate checks a topic's shadow: it names another topic and copies some
+// but not more than all of the publishes
+func (s *Shadow) validate(topic string) error {
+	switch {
+	case s.Topic == "":
+		return fmt.Errorf("%w: topic is required", ErrInvalidShadow)
+	case s.Topic == topic:
+		return fmt.Errorf("%w: a topic can't be its own shadow", ErrInvalidShadow)
+	case IsSystemTopic(s.Topic) || IsInboxTopic(s.Topic):
+		return fmt.Errorf("%w: %v", ErrInvalidShadow, ErrReservedTopic)
+	case !(s.Percent > 0 && s.Percent <= 100):
+		return fmt.Errorf("%w: percent must be over 0 and at most 100", ErrInvalidShadow)
+	}
+	return nil
+}
+
+// sampled reports whether a publish is copied to the shadow topic
+func (s *Shadow) sampled() bool {
+	return s.Percent >= 100 || rand.Float64()*100 < s.Percent
+}
+
+// copyShadow copies a shadow setting, so callers can't change a topic's
+// through it
+func copyShadow(s *Shadow) *Shadow {
+	if s == nil {
+		return nil
+	}
+	copied := *s
+	return &copied
+}
+
+// shadow copies a sampled publish into its topic's shadow topic, stamped
+// with where it was published. Like dead letters, copies never wait for
+// room in the backlog, since they are made on the hub loop's fan-out; one
+// that finds the backlog full is lost and logged.
+func (h *Hub) shadow(message *PubSubMessage) {
+	if message.Message == nil {
+		return
+	}
+	if _, isCopy := message.Message.Headers[ShadowSourceTopicHeader]; isCopy {
+		return
+	}
+
+	h.mu.RLock()
+	var target *Topic
+	if topic, exists := h.topics[message.Topic]; exists && topic.shadow != nil && topic.shadow.sampled() {
+		target = h.topics[topic.shadow.Topic]
+	}
+	h.mu.RUnlock()
+	if target == nil {
+		return
+	}
+
+	headers := make(map[string]string, len(message.Message.Headers)+2)
+	for key, value := range message.Message.Headers {
+		headers[key] = value
+	}
+	headers[ShadowSourceTopicHeader] = message.Topic
+	headers[ShadowSourceSequenceHeader] = strconv.FormatInt(message.Sequence, 10)
+
+	data := *message.Message
+	data.Headers = headers
+	copied := &PubSubMessage{Topic: target.Name, Message: &data, Timestamp: message.Timestamp}
+
+	if _, err := h.publishes.push(target.Name, copied, target.schedulingWeight(), nil, expired); err != nil {
+		slog.Error("Lost shadow copy", logging.Topic, message.Topic, "shadow_topic", target.Name, "message_id", data.ID, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	if topic, exists := h.topics[message.Topic]; exists {
+		topic.shadowed++
+	}
+	h.mu.Unlock()
+}
+
+// ensureShadowTopic creates a topic's shadow topic, owned by the same
+// tenant, unless it already exists or can still be restored. Caller must
+// hold the hub write lock.
+func (h *Hub) ensureShadowTopic(topic *Topic) {
+	if topic.shadow == nil {
+		return
+	}
+	h.ensureCompanionTopic(topic.shadow.Topic, topic.owner)
+}
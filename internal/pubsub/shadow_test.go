@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestShadowCopiesPublishes(t *testing.T) {
+	hub := NewHub()
+	if err := hub.CreateTopicWithOptions("orders", TopicOptions{Owner: "acme", Shadow: &Shadow{Topic: "orders.canary", Percent: 100}}); err != nil {
+		t.Fatalf("CreateTopicWithOptions failed: %v", err)
+	}
+	if stats, err := hub.GetTopicStats("orders.canary"); err != nil || stats.Owner != "acme" {
+		t.Fatalf("Expected the shadow topic created and owned by acme, got %+v, %v", stats, err)
+	}
+
+	hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "msg-1", Payload: "paid", Headers: map[string]string{"region": "eu"}}})
+
+	copied, ok := hub.publishes.next()
+	if !ok || copied.Topic != "orders.canary" {
+		t.Fatalf("Expected a copy queued for orders.canary, got %+v", copied)
+	}
+	headers := copied.Message.Headers
+	if headers[ShadowSourceTopicHeader] != "orders" || headers[ShadowSourceSequenceHeader] != "1" || headers["region"] != "eu" {
+		t.Errorf("Unexpected shadow headers: %v", headers)
+	}
+	if copied.Message.ID != "msg-1" || copied.Message.Payload != "paid" {
+		t.Errorf("Expected the publish's ID and payload kept, got %+v", copied.Message)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.Shadowed != 1 || stats.Shadow == nil || stats.Shadow.Percent != 100 {
+		t.Errorf("Expected 1 publish shadowed, got %+v", stats)
+	}
+
+	// Copies aren't shadowed again, even by a shadow topic that has one
+	hub.UpdateTopic("orders.canary", TopicUpdate{Shadow: &Shadow{Topic: "orders", Percent: 100}}, 0)
+	hub.publishMessage(copied)
+	if _, ok := hub.publishes.next(); ok {
+		t.Error("Expected a shadow copy not to be shadowed again")
+	}
+}
+
+func TestShadowSamplesPercent(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopicWithOptions("orders", TopicOptions{Shadow: &Shadow{Topic: "orders.canary", Percent: 10}})
+
+	const publishes = 2000
+	copies := 0
+	for i := 0; i < publishes; i++ {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: fmt.Sprintf("msg-%d", i)}})
+		if _, ok := hub.publishes.next(); ok {
+			copies++
+		}
+	}
+	// 10% of 2000 is 200; this bound fails by chance far less than once in
+	// a billion runs
+	if copies < 100 || copies > 300 {
+		t.Errorf("Expected about 200 of %d publishes copied, got %d", publishes, copies)
+	}
+
+	// An update without a topic stops shadowing
+	if _, err := hub.UpdateTopic("orders", TopicUpdate{Shadow: &Shadow{}}, 0); err != nil {
+		t.Fatalf("UpdateTopic failed: %v", err)
+	}
+	hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "after"}})
+	if _, ok := hub.publishes.next(); ok {
+		t.Error("Expected no copies once shadowing stopped")
+	}
+}
+
+func TestShadowValidation(t *testing.T) {
+	hub := NewHub()
+
+	tests := []struct {
+		name   string
+		shadow Shadow
+	}{
+		{"no topic", Shadow{Percent: 10}},
+		{"itself", Shadow{Topic: "orders", Percent: 10}},
+		{"system topic", Shadow{Topic: QuotaTopic, Percent: 10}},
+		{"zero percent", Shadow{Topic: "orders.canary"}},
+		{"over 100 percent", Shadow{Topic: "orders.canary", Percent: 150}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := hub.CreateTopicWithOptions("orders", TopicOptions{Shadow: &tt.shadow}); !errors.Is(err, ErrInvalidShadow) {
+				t.Errorf("Expected ErrInvalidShadow, got %v", err)
+			}
+		})
+	}
+}
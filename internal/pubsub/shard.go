@@ -0,0 +1,119 @@
+package pubsub
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// maxHubShards bounds how many publish loops the hub runs
+const maxHubShards = 256
+
+// publishShards routes publishes by topic hash to one of several
+// schedulers, each fanned out by its own loop. A topic always maps to the
+// same shard, so its publishes are still fanned out one at a time in
+// sequence order, while topics on different shards are fanned out in
+// parallel. Weighted round-robin fairness holds among the topics sharing a
+// shard.
+type publishShards struct {
+	shards   []*publishScheduler
+	capacity int // per topic
+}
+
+// newPublishShards creates count shards holding at most capacity pending
+// publishes per topic
+func newPublishShards(count, capacity int) *publishShards {
+	if count < 1 {
+		count = 1
+	}
+	shards := make([]*publishScheduler, count)
+	for i := range shards {
+		shards[i] = newPublishScheduler(capacity)
+	}
+	return &publishShards{shards: shards, capacity: shards[0].capacity}
+}
+
+// shard returns the scheduler a topic's publishes are routed to
+func (p *publishShards) shard(topic string) *publishScheduler {
+	if len(p.shards) == 1 {
+		return p.shards[0]
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(topic))
+	return p.shards[hash.Sum32()%uint32(len(p.shards))]
+}
+
+// push appends a message to the topic's backlog on its shard; see
+// publishScheduler.push
+func (p *publishShards) push(topic string, message *PubSubMessage, weight int, cancel <-chan struct{}, deadline <-chan time.Time) (int, error) {
+	return p.shard(topic).push(topic, message, weight, cancel, deadline)
+}
+
+// pushAll appends messages to the topic's backlog together; see
+// publishScheduler.pushAll
+func (p *publishShards) pushAll(topic string, messages []*PubSubMessage, weight int, cancel <-chan struct{}, deadline <-chan time.Time) (int, error) {
+	return p.shard(topic).pushAll(topic, messages, weight, cancel, deadline)
+}
+
+// next removes and returns the next message of the first shard with any
+// pending, or false if nothing is pending. The shard loops each serve their
+// own scheduler; this is for callers draining the hub outside them.
+func (p *publishShards) next() (*PubSubMessage, bool) {
+	for _, shard := range p.shards {
+		if message, ok := shard.next(); ok {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+// pending returns the number of queued publishes across all shards
+func (p *publishShards) pending() int {
+	total := 0
+	for _, shard := range p.shards {
+		total += shard.pending()
+	}
+	return total
+}
+
+// topicPending returns the number of the topic's queued publishes
+func (p *publishShards) topicPending(topic string) int {
+	return p.shard(topic).topicPending(topic)
+}
+
+// signal wakes every shard loop without blocking
+func (p *publishShards) signal() {
+	for _, shard := range p.shards {
+		shard.signal()
+	}
+}
+
+// runShard fans out one shard's publishes until the hub shuts down
+func (h *Hub) runShard(shard *publishScheduler) {
+	for {
+		select {
+		case <-shard.ready:
+			h.dispatchShard(shard)
+		case <-h.shutdown:
+			return
+		}
+	}
+}
+
+// awaitSubscribe hands a subscription to the hub loop and waits until it
+// has been applied. Publishes are fanned out by the shard loops, not the
+// hub loop, so without waiting a publish accepted right after the handoff
+// could be fanned out before the subscriber is in place.
+func (h *Hub) awaitSubscribe(subscription *Subscription) error {
+	subscription.done = make(chan struct{})
+	select {
+	case h.subscribe <- subscription:
+	case <-h.shutdown:
+		return ErrShuttingDown
+	}
+	select {
+	case <-subscription.done:
+		return nil
+	case <-h.shutdown:
+		return ErrShuttingDown
+	}
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPublishShardsRouteByTopic(t *testing.T) {
+	p := newPublishShards(4, 100)
+	used := make(map[*publishScheduler]bool)
+	for i := 0; i < 20; i++ {
+		topic := fmt.Sprintf("topic-%d", i)
+		if p.shard(topic) != p.shard(topic) {
+			t.Fatalf("Expected %s to always route to the same shard", topic)
+		}
+		used[p.shard(topic)] = true
+		p.push(topic, &PubSubMessage{Topic: topic}, 1, nil, nil)
+	}
+	if len(used) < 2 {
+		t.Errorf("Expected topics spread over several shards, got %d", len(used))
+	}
+	if p.pending() != 20 || p.topicPending("topic-3") != 1 {
+		t.Errorf("Expected 20 pending with 1 for topic-3, got %d and %d", p.pending(), p.topicPending("topic-3"))
+	}
+
+	for i := 0; i < 20; i++ {
+		if _, ok := p.next(); !ok {
+			t.Fatalf("Expected message %d from some shard", i)
+		}
+	}
+	if _, ok := p.next(); ok || p.pending() != 0 {
+		t.Error("Expected every shard drained")
+	}
+}
+
+func TestShardedHubKeepsTopicOrder(t *testing.T) {
+	hub := NewHubWithOptions(HubOptions{PublishBuffer: 100, Shards: 4})
+	go hub.Run()
+	defer hub.Shutdown()
+
+	client := newTestClient(hub)
+	topics := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, topic := range topics {
+		hub.CreateTopic(topic)
+		hub.subscribeClient(&Subscription{client: client, topic: topic})
+	}
+
+	for i := 0; i < 10; i++ {
+		for _, topic := range topics {
+			if _, err := hub.TryPublish(&PubSubMessage{Topic: topic, Message: &MessageData{ID: fmt.Sprint(i)}}, time.Second); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+		}
+	}
+
+	want := 10 * len(topics)
+	deadline := time.Now().Add(time.Second)
+	for client.queue.Len() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out with %d of %d events delivered", client.queue.Len(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Topics on different shards interleave, but each keeps its own order
+	last := make(map[string]int64)
+	for _, event := range drainFrames(t, client) {
+		if event.Sequence != last[event.Topic]+1 {
+			t.Fatalf("%s: expected sequence %d, got %d", event.Topic, last[event.Topic]+1, event.Sequence)
+		}
+		last[event.Topic] = event.Sequence
+	}
+	for _, topic := range topics {
+		if last[topic] != 10 {
+			t.Errorf("%s: expected 10 events, got %d", topic, last[topic])
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package pubsub
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"plivo/internal/logging"
+)
+
+// Snapshot is a point-in-time copy of the hub's topics and their retained
+// messages, used to warm a node from a peer before it accepts subscribers
+type Snapshot struct {
+	TakenAt time.Time       `json:"taken_at"`
+	Topics  []TopicSnapshot `json:"topics"`
+}
+
+// TopicSnapshot holds one topic's metadata and retained messages
+type TopicSnapshot struct {
+	Name         string        `json:"name"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Sequence     int64         `json:"sequence"`
+	MessageCount int64         `json:"message_count"`
+	Replay       *ReplayLimits `json:"replay,omitempty"`
+	Weight       int           `json:"weight,omitempty"`
+	KeyID        string        `json:"key_id,omitempty"`
+	Enrich       bool          `json:"enrich,omitempty"`
+	Owner        string        `json:"owner,omitempty"`
+	// Retention is the topic's retention policy, if it set one
+	Retention *RetentionPolicy  `json:"retention,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Revision  int64             `json:"revision,omitempty"`
+	// DeadLetter is the topic's dead-letter topic, if it set one
+	DeadLetter string `json:"dead_letter,omitempty"`
+	// Partitioning is the topic's partitioning, if it is partitioned
+	Partitioning *Partitioning `json:"partitioning,omitempty"`
+	// Shadow is the topic's shadow, if it set one
+	Shadow *Shadow `json:"shadow,omitempty"`
+	// SampleEvery is the topic's sampling rate, if it samples publishes
+	SampleEvery int `json:"sample_every,omitempty"`
+	// DedupWindowMs is the topic's dedup window, if it dedups publishes.
+	// The IDs it remembered are not kept.
+	DedupWindowMs int64 `json:"dedup_window_ms,omitempty"`
+	// Schemas are the registered schema versions, oldest first
+	Schemas []*TopicSchema `json:"schemas,omitempty"`
+	// Groups maps consumer group names to their offsets
+	Groups map[string]int64 `json:"groups,omitempty"`
+	// Messages are the retained messages, oldest first
+	Messages []*PubSubMessage `json:"messages"`
+}
+
+// RestoreResult summarizes what a snapshot restore loaded
+type RestoreResult struct {
+	Topics   int `json:"topics"`
+	Messages int `json:"messages"`
+	// Skipped lists topics that already existed or failed validation
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Snapshot copies every topic's metadata and retained messages
+func (h *Hub) Snapshot() *Snapshot {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.snapshotLocked()
+}
+
+// snapshotLocked is Snapshot for callers holding the hub lock
+func (h *Hub) snapshotLocked() *Snapshot {
+	snapshot := &Snapshot{
+		TakenAt: h.clock.Now(),
+		Topics:  make([]TopicSnapshot, 0, len(h.topics)),
+	}
+	for _, topic := range h.topics {
+		if topic.inbox {
+			continue
+		}
+		ts := topic.snapshot()
+		ts.Messages = h.retained(topic.Name, 0)
+		snapshot.Topics = append(snapshot.Topics, ts)
+	}
+	sort.Slice(snapshot.Topics, func(i, j int) bool {
+		return snapshot.Topics[i].Name < snapshot.Topics[j].Name
+	})
+	return snapshot
+}
+
+// snapshot copies the topic's metadata, without its retained messages.
+// Caller must hold the hub lock.
+func (t *Topic) snapshot() TopicSnapshot {
+	ts := TopicSnapshot{
+		Name:          t.Name,
+		CreatedAt:     t.CreatedAt,
+		Sequence:      t.Sequence,
+		MessageCount:  t.MessageCount,
+		Replay:        t.replay,
+		Weight:        t.weight,
+		KeyID:         t.keyID,
+		Enrich:        t.enrich,
+		Owner:         t.owner,
+		Retention:     t.retention,
+		Labels:        copyLabels(t.labels),
+		Revision:      t.revision,
+		DeadLetter:    t.deadLetter,
+		Partitioning:  t.partitioning,
+		Shadow:        copyShadow(t.shadow),
+		SampleEvery:   t.sampleEvery,
+		DedupWindowMs: t.dedupWindowMs(),
+		Schemas:       append([]*TopicSchema(nil), t.schemas...),
+	}
+	if len(t.groups) > 0 {
+		ts.Groups = make(map[string]int64, len(t.groups))
+		for name, cursor := range t.groups {
+			ts.Groups[name] = cursor.offset
+		}
+	}
+	return ts
+}
+
+// Restore creates the snapshot's topics with their sequence, retained
+// messages, schemas and group offsets. Topics that already exist, or were
+// deleted and can still be restored, are left alone, so a restore never
+// rewinds local state.
+func (h *Hub) Restore(snapshot *Snapshot) *RestoreResult {
+	result := &RestoreResult{}
+	h.mu.RLock()
+	ringBufferSize := h.ringBufferSize
+	h.mu.RUnlock()
+
+	for i := range snapshot.Topics {
+		ts := &snapshot.Topics[i]
+		topic, messages, err := restoreTopic(ts, ringBufferSize, h.clock.Now())
+		if err != nil {
+			slog.Warn("Skipping topic from snapshot", logging.Topic, ts.Name, "error", err)
+			result.Skipped = append(result.Skipped, ts.Name)
+			continue
+		}
+
+		h.mu.Lock()
+		if _, exists := h.topics[ts.Name]; exists || h.trashed(ts.Name) != nil {
+			h.mu.Unlock()
+			result.Skipped = append(result.Skipped, ts.Name)
+			continue
+		}
+		h.topics[ts.Name] = topic
+		logStoreError("create topic", h.store.CreateTopic(ts.Name))
+		h.applyRetention(topic)
+		for _, message := range messages {
+			logStoreError("append", h.store.AppendMessage(message))
+		}
+		h.updateSubscriberCount(ts.Name)
+		h.stats.TotalTopics = len(h.topics)
+		h.persistTopic("restore topic", topic)
+		h.mu.Unlock()
+
+		result.Topics++
+		result.Messages += len(messages)
+	}
+	return result
+}
+
+// restoreTopic rebuilds a topic from its snapshot, validating it as a topic
+// created locally on a hub with the given ring buffer size would be, and
+// returns it with the retained messages to store for it. Its consumer
+// groups count as active at now.
+func restoreTopic(ts *TopicSnapshot, ringBufferSize int, now time.Time) (*Topic, []*PubSubMessage, error) {
+	if ts.Name == "" || IsSystemTopic(ts.Name) {
+		return nil, nil, ErrReservedTopic
+	}
+	if ts.Replay != nil {
+		if err := ts.Replay.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := validateWeight(ts.Weight); err != nil {
+		return nil, nil, err
+	}
+	if err := validateKeyID(ts.KeyID); err != nil {
+		return nil, nil, err
+	}
+	if ts.Owner != "" {
+		if err := validateOwner(ts.Owner); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ts.Retention != nil {
+		if err := ts.Retention.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := validateLabels(ts.Labels); err != nil {
+		return nil, nil, err
+	}
+	if err := validateDeadLetter(ts.Name, ts.DeadLetter); err != nil {
+		return nil, nil, err
+	}
+	if ts.Partitioning != nil {
+		if err := ts.Partitioning.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if ts.Shadow != nil {
+		if err := ts.Shadow.validate(ts.Name); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := validateSampleEvery(ts.SampleEvery); err != nil {
+		return nil, nil, err
+	}
+	if err := validateDedupWindow(ts.DedupWindowMs); err != nil {
+		return nil, nil, err
+	}
+
+	topic := &Topic{
+		Name:         ts.Name,
+		CreatedAt:    ts.CreatedAt,
+		MessageCount: ts.MessageCount,
+		Sequence:     ts.Sequence,
+		payloadSizes: NewSizeHistogram(),
+		replay:       ts.Replay,
+		weight:       ts.Weight,
+		keyID:        ts.KeyID,
+		enrich:       ts.Enrich,
+		owner:        ts.Owner,
+		retention:    ts.Retention,
+		labels:       copyLabels(ts.Labels),
+		// Snapshots from before revisions start over
+		revision:     max(ts.Revision, 1),
+		deadLetter:   ts.DeadLetter,
+		partitioning: ts.Partitioning,
+		shadow:       copyShadow(ts.Shadow),
+		sampleEvery:  ts.SampleEvery,
+		// Restored topics take this hub's ring buffer size
+		ringBufferSize: ringBufferSize,
+	}
+	topic.setDedupWindow(ts.DedupWindowMs)
+
+	for i, schema := range ts.Schemas {
+		version := i + 1
+		compiled, err := compileSchema(ts.Name, version, schema.Schema)
+		if err != nil {
+			return nil, nil, fmt.Errorf("schema version %d: %w", version, err)
+		}
+		topic.schemas = append(topic.schemas, &TopicSchema{
+			Topic:     ts.Name,
+			Version:   version,
+			Schema:    schema.Schema,
+			CreatedAt: schema.CreatedAt,
+			compiled:  compiled,
+		})
+	}
+
+	if len(ts.Groups) > 0 {
+		topic.groups = make(map[string]*groupCursor, len(ts.Groups))
+		for name, offset := range ts.Groups {
+			if offset < 0 || offset > ts.Sequence {
+				return nil, nil, fmt.Errorf("group %s: %w", name, ErrInvalidOffset)
+			}
+			// Restored groups get a full expiry window to reconnect
+			topic.groups[name] = &groupCursor{offset: offset, updatedAt: now, activeAt: now}
+		}
+	}
+
+	// Keep the newest messages that fit the ring, in sequence order
+	messages := ts.Messages
+	if capacity := topic.capacity(); len(messages) > capacity {
+		messages = messages[len(messages)-capacity:]
+	}
+	var last int64
+	var retained []*PubSubMessage
+	for _, message := range messages {
+		if message == nil || message.Message == nil {
+			continue
+		}
+		if message.Sequence <= last || message.Sequence > ts.Sequence {
+			return nil, nil, fmt.Errorf("message sequence %d out of order", message.Sequence)
+		}
+		last = message.Sequence
+		message.Topic = ts.Name
+		message.keyID = ts.KeyID
+		retained = append(retained, message)
+		topic.payloadSizes.Record(message.payloadBytes())
+		if message.Timestamp.After(topic.LastPublishAt) {
+			topic.LastPublishAt = message.Timestamp
+		}
+	}
+	return topic, retained, nil
+}
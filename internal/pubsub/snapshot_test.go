@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	source := NewHub()
+	source.CreateTopicWithOptions("orders", TopicOptions{
+		Replay: &ReplayLimits{DefaultLastN: 2, MaxLastN: 50},
+		Weight: 3,
+	})
+	source.CreateTopic("empty")
+	if _, err := source.SetTopicSchema("orders", json.RawMessage(`{"type": "object"}`)); err != nil {
+		t.Fatalf("SetTopicSchema failed: %v", err)
+	}
+	retainMessages(source, "orders", 5)
+	source.topics["orders"].groupCursor("billing", source.clock.Now()).offset = 3
+
+	// Round-trip through JSON as a peer fetch would
+	encoded, err := json.Marshal(source.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+
+	target := NewHub()
+	result := target.Restore(&snapshot)
+	if result.Topics != 2 || result.Messages != 5 || len(result.Skipped) != 0 {
+		t.Fatalf("Expected 2 topics and 5 messages restored, got %+v", result)
+	}
+
+	stats, err := target.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Sequence != 5 || stats.BufferOccupancy != 5 || stats.Weight != 3 {
+		t.Errorf("Expected sequence 5, 5 retained and weight 3, got %+v", stats)
+	}
+	if stats.Replay.DefaultLastN != 2 || stats.Replay.MaxLastN != 50 {
+		t.Errorf("Expected replay limits 2/50, got %+v", stats.Replay)
+	}
+
+	if schema, err := target.GetTopicSchema("orders", 0); err != nil || schema.Version != 1 {
+		t.Errorf("Expected schema version 1, got %v, %v", schema, err)
+	}
+
+	offset, err := target.GetGroupOffset("orders", "billing")
+	if err != nil || offset.Offset != 3 || offset.Lag != 2 {
+		t.Errorf("Expected billing offset 3 with lag 2, got %+v, %v", offset, err)
+	}
+
+	// Subscribers on the warmed hub get replay, and new publishes continue
+	// the peer's sequence
+	info, backlog := target.prepareReplay("orders", 0, "")
+	if info.Replaying != 2 || backlog[0].Sequence != 4 || backlog[1].Message.ID != "msg-5" {
+		t.Errorf("Expected the default 2 messages replayed from sequence 4, got %+v", info)
+	}
+
+	retainMessages(target, "orders", 1)
+	if stats, _ := target.GetTopicStats("orders"); stats.Sequence != 6 {
+		t.Errorf("Expected sequence 6 after a new publish, got %d", stats.Sequence)
+	}
+}
+
+func TestRestoreSkipsExistingAndInvalidTopics(t *testing.T) {
+	target := NewHub()
+	target.CreateTopic("orders")
+
+	snapshot := &Snapshot{Topics: []TopicSnapshot{
+		{Name: "orders", Sequence: 10},
+		{Name: "$SYS/custom"},
+		{Name: "bad-weight", Weight: -1},
+		{Name: "bad-order", Sequence: 2, Messages: []*PubSubMessage{
+			{Sequence: 2, Message: &MessageData{ID: "b"}},
+			{Sequence: 1, Message: &MessageData{ID: "a"}},
+		}},
+		{Name: "bad-group", Sequence: 1, Groups: map[string]int64{"billing": 5}},
+		{Name: "payments", Sequence: 1, Messages: []*PubSubMessage{
+			{Sequence: 1, Message: &MessageData{ID: "a"}},
+		}},
+	}}
+
+	result := target.Restore(snapshot)
+	if result.Topics != 1 || result.Messages != 1 || len(result.Skipped) != 5 {
+		t.Errorf("Expected only payments restored, got %+v", result)
+	}
+
+	// Local state is never rewound
+	if stats, _ := target.GetTopicStats("orders"); stats.Sequence != 0 {
+		t.Errorf("Expected existing topic untouched, got sequence %d", stats.Sequence)
+	}
+}
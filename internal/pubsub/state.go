@@ -0,0 +1,321 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"plivo/internal/auth"
+	"sort"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxStateKeyLength bounds a state key in bytes
+	MaxStateKeyLength = 256
+	// MaxStateValueSize bounds a state value's JSON encoding in bytes
+	MaxStateValueSize = 64 * 1024
+	// MaxStateKeys bounds how many keys a topic's state holds
+	MaxStateKeys = 1000
+)
+
+// StateEntry is the value of one key of a topic's state
+type StateEntry struct {
+	Value     json.RawMessage `json:"value" swaggertype:"object"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	// Ephemeral entries are removed when the connection that set them
+	// closes, as presence should be
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// owner is the connection that set an ephemeral entry
+	owner *Client
+}
+
+// StateUpdate is a change to one key of a topic's state, delivered to the
+// topic's subscribers in a state_update frame
+type StateUpdate struct {
+	Key string `json:"key"`
+	// Value is the key's new value, unset when the key was deleted
+	Value     json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+	Deleted   bool            `json:"deleted,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// validateStateKey checks a state key: non-empty, at most
+// MaxStateKeyLength bytes, without control characters
+func validateStateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key is required", ErrInvalidState)
+	}
+	if len(key) > MaxStateKeyLength {
+		return fmt.Errorf("%w: key exceeds %d bytes", ErrInvalidState, MaxStateKeyLength)
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: key must not contain control characters", ErrInvalidState)
+		}
+	}
+	return nil
+}
+
+// SetState sets a key of a topic's state, or deletes it for a nil or null
+// value, and sends the change to the topic's subscribers. It is safe for
+// concurrent use.
+func (h *Hub) SetState(topicName, key string, value json.RawMessage) (*StateUpdate, error) {
+	return h.setState(topicName, key, value, nil)
+}
+
+// setState is SetState for an entry owned by a connection, which makes it
+// ephemeral, or for none
+func (h *Hub) setState(topicName, key string, value json.RawMessage, owner *Client) (*StateUpdate, error) {
+	if IsSystemTopic(topicName) {
+		return nil, ErrReservedTopic
+	}
+	if err := validateStateKey(key); err != nil {
+		return nil, err
+	}
+	deleted := len(value) == 0 || string(value) == "null"
+	if !deleted && len(value) > MaxStateValueSize {
+		return nil, fmt.Errorf("%w: value exceeds %d bytes", ErrInvalidState, MaxStateValueSize)
+	}
+	if !deleted && !json.Valid(value) {
+		return nil, fmt.Errorf("%w: value is not valid JSON", ErrInvalidState)
+	}
+
+	// The state lock is held until the update is queued for every
+	// subscriber, so subscribers see updates in order and none slips in
+	// between a new subscriber's snapshot and its subscription
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+
+	h.mu.RLock()
+	topic, exists := h.topics[topicName]
+	if !exists {
+		h.mu.RUnlock()
+		return nil, ErrTopicNotFound
+	}
+	update := &StateUpdate{Key: key, Deleted: deleted, UpdatedAt: h.clock.Now()}
+	if deleted {
+		if _, ok := topic.state[key]; !ok {
+			h.mu.RUnlock()
+			return update, nil
+		}
+		delete(topic.state, key)
+	} else {
+		if _, ok := topic.state[key]; !ok && len(topic.state) >= MaxStateKeys {
+			h.mu.RUnlock()
+			return nil, fmt.Errorf("%w: topic state holds %d keys", ErrStateFull, MaxStateKeys)
+		}
+		if topic.state == nil {
+			topic.state = make(map[string]*StateEntry)
+		}
+		update.Value = append(json.RawMessage(nil), value...)
+		topic.state[key] = &StateEntry{Value: update.Value, UpdatedAt: update.UpdatedAt, Ephemeral: owner != nil, owner: owner}
+		if owner != nil {
+			h.trackStateOwner(owner, topicName)
+		}
+	}
+	subscribers := h.subscribersOf(topicName)
+	h.mu.RUnlock()
+
+	data := h.createStateUpdateMessageBytes(topicName, update)
+	for _, client := range subscribers {
+		client.sendWithBackpressure("", data)
+	}
+	return update, nil
+}
+
+// GetState returns a copy of a topic's state. It is safe for concurrent
+// use.
+func (h *Hub) GetState(topicName string) (map[string]StateEntry, error) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	topic, exists := h.topics[topicName]
+	if !exists {
+		return nil, ErrTopicNotFound
+	}
+	return topic.copyState(), nil
+}
+
+// GetStateKey returns one key of a topic's state, or ErrStateNotFound
+func (h *Hub) GetStateKey(topicName, key string) (StateEntry, error) {
+	state, err := h.GetState(topicName)
+	if err != nil {
+		return StateEntry{}, err
+	}
+	entry, ok := state[key]
+	if !ok {
+		return StateEntry{}, ErrStateNotFound
+	}
+	return entry, nil
+}
+
+// copyState copies the topic's state. Caller must hold the state lock.
+func (t *Topic) copyState() map[string]StateEntry {
+	state := make(map[string]StateEntry, len(t.state))
+	for key, entry := range t.state {
+		state[key] = *entry
+	}
+	return state
+}
+
+// subscribersOf lists a topic's subscribers. Caller must hold the hub lock.
+func (h *Hub) subscribersOf(topicName string) []*Client {
+	subscribers := make([]*Client, 0, len(h.subscriptions[topicName]))
+	for client := range h.subscriptions[topicName] {
+		subscribers = append(subscribers, client)
+	}
+	return subscribers
+}
+
+// sendStateSnapshot queues a topic's state, if it has any, for a client
+// about to subscribe to it. Caller must hold the state lock, and register
+// the subscription before releasing it, so the snapshot precedes every
+// live update and event.
+func (h *Hub) sendStateSnapshot(client *Client, topicName string) {
+	h.mu.RLock()
+	topic, exists := h.topics[topicName]
+	var state map[string]StateEntry
+	if exists && len(topic.state) > 0 {
+		state = topic.copyState()
+	}
+	h.mu.RUnlock()
+
+	if state != nil {
+		client.sendWithBackpressure("", h.createStateMessageBytes("", topicName, state))
+	}
+}
+
+// trackStateOwner remembers that a connection owns ephemeral state on a
+// topic. Caller must hold the state lock.
+func (h *Hub) trackStateOwner(owner *Client, topicName string) {
+	if h.stateOwners == nil {
+		h.stateOwners = make(map[*Client]map[string]bool)
+	}
+	if h.stateOwners[owner] == nil {
+		h.stateOwners[owner] = make(map[string]bool)
+	}
+	h.stateOwners[owner][topicName] = true
+}
+
+// releaseState deletes the ephemeral state a departing connection set,
+// sending the deletions to each topic's subscribers
+func (h *Hub) releaseState(owner *Client) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+
+	topics := h.stateOwners[owner]
+	delete(h.stateOwners, owner)
+	if len(topics) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(topics))
+	for name := range topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h.mu.RLock()
+		topic, exists := h.topics[name]
+		var released []string
+		if exists {
+			for key, entry := range topic.state {
+				if entry.owner == owner {
+					delete(topic.state, key)
+					released = append(released, key)
+				}
+			}
+		}
+		subscribers := h.subscribersOf(name)
+		h.mu.RUnlock()
+
+		sort.Strings(released)
+		for _, key := range released {
+			data := h.createStateUpdateMessageBytes(name, &StateUpdate{Key: key, Deleted: true, UpdatedAt: h.clock.Now()})
+			for _, client := range subscribers {
+				client.sendWithBackpressure("", data)
+			}
+		}
+	}
+}
+
+// createStateMessageBytes creates a state frame carrying a topic's state,
+// sent to new subscribers and in reply to state_get
+func (h *Hub) createStateMessageBytes(requestID, topic string, state map[string]StateEntry) []byte {
+	msg := ServerMessage{
+		Type:      StateMessage,
+		RequestID: requestID,
+		Topic:     topic,
+		State:     state,
+		TS:        h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// createStateUpdateMessageBytes creates a state_update frame carrying a
+// change to a key of a topic's state
+func (h *Hub) createStateUpdateMessageBytes(topic string, update *StateUpdate) []byte {
+	msg := ServerMessage{
+		Type:        StateUpdateMessage,
+		Topic:       topic,
+		StateUpdate: update,
+		TS:          h.clock.Now().Format(time.RFC3339),
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// handleStateSet processes state_set requests: the key is set, or deleted
+// for a null or missing value, and the change sent to the topic's
+// subscribers. Entries set with ephemeral are removed when the connection
+// closes. Like a publish, a state_set spends a token of the client's
+// publish rate limit.
+func (c *Client) handleStateSet(msg *ClientMessage) {
+	if !c.allowPublish(msg, c.hub.clock.Now()) {
+		return
+	}
+	if IsSystemTopic(msg.Topic) {
+		c.sendErrorData(msg.RequestID, ErrorFrom(ErrReservedTopic))
+		return
+	}
+	if !c.permitted(msg.RequestID, auth.PermPublish, msg.Topic) {
+		return
+	}
+
+	var owner *Client
+	if msg.Ephemeral {
+		owner = c
+	}
+	if _, err := c.hub.setState(msg.Topic, msg.Key, msg.Value, owner); err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+	c.sendAck(msg.RequestID, msg.Topic, "ok")
+}
+
+// handleStateGet processes state_get requests, answered with a state frame
+// holding the topic's whole state or, given a key, just that key
+func (c *Client) handleStateGet(msg *ClientMessage) {
+	if !c.permitted(msg.RequestID, auth.PermSubscribe, msg.Topic) {
+		return
+	}
+
+	state, err := c.hub.GetState(msg.Topic)
+	if err == nil && msg.Key != "" {
+		entry, ok := state[msg.Key]
+		if !ok {
+			err = ErrStateNotFound
+		}
+		state = map[string]StateEntry{msg.Key: entry}
+	}
+	if err != nil {
+		c.sendErrorData(msg.RequestID, ErrorFrom(err))
+		return
+	}
+	c.sendWithBackpressure("", c.hub.createStateMessageBytes(msg.RequestID, msg.Topic, state))
+}
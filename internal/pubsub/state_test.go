@@ -0,0 +1,137 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStateSnapshotPrecedesEvents(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("doc")
+
+	writer := newTestClient(hub)
+	writer.handleMessage(&ClientMessage{Type: StateSetMessage, Topic: "doc", Key: "lock", Value: json.RawMessage(`{"holder":"ana"}`), RequestID: "r1"})
+	if frames := drainFrames(t, writer); len(frames) != 1 || frames[0].Type != AckMessage || frames[0].Status != "ok" {
+		t.Fatalf("Expected the state_set acked, got %+v", frames)
+	}
+
+	subscriber := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: subscriber, topic: "doc"})
+	hub.SetState("doc", "cursor", json.RawMessage(`3`))
+	hub.publishMessage(&PubSubMessage{Topic: "doc", Message: &MessageData{ID: "m1"}})
+	hub.SetState("doc", "lock", nil)
+
+	frames := drainFrames(t, subscriber)
+	if len(frames) != 4 {
+		t.Fatalf("Expected a snapshot, an update, an event and a deletion, got %+v", frames)
+	}
+	if frames[0].Type != StateMessage || string(frames[0].State["lock"].Value) != `{"holder":"ana"}` {
+		t.Errorf("Expected the snapshot first, got %+v", frames[0])
+	}
+	if update := frames[1].StateUpdate; frames[1].Type != StateUpdateMessage || update.Key != "cursor" || string(update.Value) != "3" {
+		t.Errorf("Expected the cursor update, got %+v", frames[1])
+	}
+	if update := frames[3].StateUpdate; frames[3].Type != StateUpdateMessage || update.Key != "lock" || !update.Deleted {
+		t.Errorf("Expected the lock deleted, got %+v", frames[3])
+	}
+
+	state, _ := hub.GetState("doc")
+	if len(state) != 1 || string(state["cursor"].Value) != "3" {
+		t.Errorf("Expected only the cursor left, got %+v", state)
+	}
+
+	// Topics without state send no snapshot
+	hub.CreateTopic("empty")
+	quiet := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: quiet, topic: "empty"})
+	if frames := drainFrames(t, quiet); len(frames) != 0 {
+		t.Errorf("Expected no snapshot for an empty state, got %+v", frames)
+	}
+}
+
+func TestStateGet(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("doc")
+	hub.SetState("doc", "title", json.RawMessage(`"Draft"`))
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: StateGetMessage, Topic: "doc", RequestID: "r1"})
+	client.handleMessage(&ClientMessage{Type: StateGetMessage, Topic: "doc", Key: "owner", RequestID: "r2"})
+
+	frames := drainFrames(t, client)
+	if len(frames) != 2 {
+		t.Fatalf("Expected two replies, got %+v", frames)
+	}
+	if frames[0].Type != StateMessage || frames[0].RequestID != "r1" || string(frames[0].State["title"].Value) != `"Draft"` {
+		t.Errorf("Expected the state, got %+v", frames[0])
+	}
+	if frames[1].Type != ErrorMessage || frames[1].Error.Code != CodeStateNotFound {
+		t.Errorf("Expected STATE_NOT_FOUND for a missing key, got %+v", frames[1])
+	}
+}
+
+func TestEphemeralStateReleasedOnDisconnect(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("doc")
+
+	owner := newTestClient(hub)
+	hub.registerClient(owner)
+	owner.handleMessage(&ClientMessage{Type: StateSetMessage, Topic: "doc", Key: "cursor:ana", Value: json.RawMessage(`{"line":4}`), Ephemeral: true})
+	owner.handleMessage(&ClientMessage{Type: StateSetMessage, Topic: "doc", Key: "title", Value: json.RawMessage(`"Draft"`)})
+
+	subscriber := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: subscriber, topic: "doc"})
+	if frames := drainFrames(t, subscriber); len(frames) != 1 || !frames[0].State["cursor:ana"].Ephemeral {
+		t.Fatalf("Expected a snapshot with the ephemeral cursor, got %+v", frames)
+	}
+
+	hub.unregisterClient(owner)
+	frames := drainFrames(t, subscriber)
+	if len(frames) != 1 || frames[0].StateUpdate.Key != "cursor:ana" || !frames[0].StateUpdate.Deleted {
+		t.Fatalf("Expected the cursor deleted, got %+v", frames)
+	}
+	if state, _ := hub.GetState("doc"); len(state) != 1 || state["title"].Ephemeral {
+		t.Errorf("Expected the title kept, got %+v", state)
+	}
+}
+
+func TestStateLimits(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("doc")
+
+	tests := []struct {
+		name  string
+		topic string
+		key   string
+		value string
+		err   error
+	}{
+		{"missing topic", "nope", "k", `1`, ErrTopicNotFound},
+		{"system topic", HealthTopic, "k", `1`, ErrReservedTopic},
+		{"empty key", "doc", "", `1`, ErrInvalidState},
+		{"long key", "doc", strings.Repeat("k", MaxStateKeyLength+1), `1`, ErrInvalidState},
+		{"control character", "doc", "a\nb", `1`, ErrInvalidState},
+		{"invalid JSON", "doc", "k", `{`, ErrInvalidState},
+		{"large value", "doc", "k", `"` + strings.Repeat("v", MaxStateValueSize) + `"`, ErrInvalidState},
+	}
+	for _, tt := range tests {
+		if _, err := hub.SetState(tt.topic, tt.key, json.RawMessage(tt.value)); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	for i := 0; i < MaxStateKeys; i++ {
+		if _, err := hub.SetState("doc", strconv.Itoa(i), json.RawMessage(`true`)); err != nil {
+			t.Fatalf("SetState failed: %v", err)
+		}
+	}
+	if _, err := hub.SetState("doc", "one-more", json.RawMessage(`true`)); !errors.Is(err, ErrStateFull) {
+		t.Errorf("Expected ErrStateFull past %d keys, got %v", MaxStateKeys, err)
+	}
+	if _, err := hub.SetState("doc", "0", json.RawMessage(`false`)); err != nil {
+		t.Errorf("Expected existing keys still writable, got %v", err)
+	}
+}
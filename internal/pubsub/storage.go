@@ -0,0 +1,145 @@
+package pubsub
+
+import "log/slog"
+
+// Storage persists topics and their retained messages so they survive
+// restarts. The hub calls it with its lock held, so records are written in
+// the order of the changes they describe.
+type Storage interface {
+	// Load returns the persisted topics and retained messages
+	Load() (*Snapshot, error)
+	// SaveTopic records a topic's creation or a change to its metadata.
+	// Retained messages already recorded for the topic are kept.
+	SaveTopic(topic TopicSnapshot) error
+	// AppendMessage records a message retained for replay
+	AppendMessage(message *PubSubMessage) error
+	// DeleteTopic records a topic's deletion
+	DeleteTopic(name string) error
+	// Compact replaces everything recorded so far with the given state
+	Compact(snapshot *Snapshot) error
+	// Close flushes and releases the storage
+	Close() error
+}
+
+// compactAfterWrites is how many records are written to storage before the
+// hub compacts it on its next reconcile tick
+const compactAfterWrites = 10000
+
+// Recover restores the topics and retained messages persisted in storage,
+// then attaches it so later changes are persisted too. Call it before the
+// hub starts serving.
+func (h *Hub) Recover(storage Storage) (*RestoreResult, error) {
+	snapshot, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	result := h.Restore(snapshot)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Start the log over from the recovered state, dropping superseded
+	// records and any torn write at its end
+	if err := storage.Compact(h.snapshotLocked()); err != nil {
+		return nil, err
+	}
+	h.storage = storage
+	return result, nil
+}
+
+// CloseStorage writes the hub's final state to storage and closes it.
+// Consumer group offsets are only persisted this way and by periodic
+// compaction.
+func (h *Hub) CloseStorage() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.storage == nil {
+		return nil
+	}
+	storage := h.storage
+	h.storage = nil
+
+	if err := storage.Compact(h.snapshotLocked()); err != nil {
+		storage.Close()
+		return err
+	}
+	return storage.Close()
+}
+
+// persist records a state change in the hub's storage, if any. A failure
+// doesn't fail the change, since the in-memory state stays authoritative:
+// it degrades storage, and changes are then held in memory and written
+// behind by a compaction once storage takes writes again. Caller must hold
+// the hub write lock.
+func (h *Hub) persist(op string, write func(Storage) error) {
+	if h.storage == nil {
+		return
+	}
+	if h.storageHealth.degraded() {
+		h.storageHealth.pending++
+		return
+	}
+	if err := write(h.storage); err != nil {
+		slog.Error("Storage write failed", "op", op, "error", err)
+		h.storageHealth.pending++
+		h.storageFailed(err)
+		return
+	}
+	h.storageWrites++
+}
+
+// persistTopic records a topic's metadata and retained messages, for topics
+// added whole rather than built up by individual changes. Caller must hold
+// the hub write lock.
+func (h *Hub) persistTopic(op string, topic *Topic) {
+	h.persist(op, func(s Storage) error {
+		if err := s.SaveTopic(topic.snapshot()); err != nil {
+			return err
+		}
+		for _, message := range h.retained(topic.Name, 0) {
+			if err := s.AppendMessage(message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// compactStorage rewrites the storage from the current state once enough
+// records have accumulated
+func (h *Hub) compactStorage() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.storage == nil || h.storageWrites < compactAfterWrites {
+		return
+	}
+	h.compactLocked()
+}
+
+// CompactStorage rewrites the hub's storage, if any, from its current
+// state, without waiting for records to accumulate
+func (h *Hub) CompactStorage() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.storage == nil {
+		return nil
+	}
+	return h.compactLocked()
+}
+
+// compactLocked rewrites the storage from the current state, which ends
+// degraded storage if it succeeds and degrades it if it fails. Caller must
+// hold the hub write lock and have checked there is storage.
+func (h *Hub) compactLocked() error {
+	if err := h.storage.Compact(h.snapshotLocked()); err != nil {
+		slog.Error("Storage compaction failed", "error", err)
+		h.storageFailed(err)
+		return err
+	}
+	h.storageWrites = 0
+	h.storageRecovered()
+	return nil
+}
@@ -0,0 +1,132 @@
+package pubsub
+
+import (
+	"log/slog"
+	"time"
+)
+
+// storageRetryInterval is how often the hub tries to write its state to
+// storage that failed
+const storageRetryInterval = 5 * time.Second
+
+// $SYS/health events
+const (
+	// HealthStorageDegraded is published when a storage write fails and the
+	// hub starts serving from memory alone
+	HealthStorageDegraded = "storage_degraded"
+	// HealthStorageRecovered is published once the hub's state was written
+	// to storage again
+	HealthStorageRecovered = "storage_recovered"
+)
+
+// StorageHealth reports whether the hub's storage is taking writes
+type StorageHealth struct {
+	// Degraded is set while storage is failing. The hub keeps serving from
+	// memory and writes its state behind once storage recovers.
+	Degraded bool `json:"degraded"`
+	// Since is when storage failed
+	Since *time.Time `json:"since,omitempty"`
+	// Error is the failure that degraded storage, or the latest retry's
+	Error string `json:"error,omitempty"`
+	// Pending counts the changes made since storage failed; they are
+	// written together when it recovers
+	Pending int64 `json:"pending,omitempty"`
+	// Retries counts the failed attempts to write the state behind
+	Retries int `json:"retries,omitempty"`
+}
+
+// HealthEvent is the payload of a $SYS/health event
+type HealthEvent struct {
+	// Event is HealthStorageDegraded or HealthStorageRecovered
+	Event   string        `json:"event"`
+	Storage StorageHealth `json:"storage"`
+	// DowntimeMs is how long storage was degraded (recovered events only)
+	DowntimeMs int64 `json:"downtime_ms,omitempty"`
+}
+
+// storageHealth tracks a storage failure, zero while storage is healthy
+type storageHealth struct {
+	since   time.Time
+	err     error
+	pending int64
+	retries int
+}
+
+func (s *storageHealth) degraded() bool {
+	return !s.since.IsZero()
+}
+
+func (s *storageHealth) report() StorageHealth {
+	if !s.degraded() {
+		return StorageHealth{}
+	}
+	since := s.since
+	return StorageHealth{
+		Degraded: true,
+		Since:    &since,
+		Error:    s.err.Error(),
+		Pending:  s.pending,
+		Retries:  s.retries,
+	}
+}
+
+// StorageHealth reports whether the hub's storage is taking writes. Hubs
+// without storage are never degraded.
+func (h *Hub) StorageHealth() StorageHealth {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.storageHealth.report()
+}
+
+// storageFailed degrades storage after a failed write, or records a failed
+// retry. Caller must hold the hub write lock.
+func (h *Hub) storageFailed(err error) {
+	health := &h.storageHealth
+	if health.degraded() {
+		health.err = err
+		health.retries++
+		return
+	}
+
+	health.since = h.clock.Now()
+	health.err = err
+	slog.Error("Storage unavailable, serving from memory until it recovers", "error", err)
+	// Health events are published outside the lock
+	go h.publishSystemEvent(HealthTopic, HealthEvent{Event: HealthStorageDegraded, Storage: health.report()})
+}
+
+// storageRecovered ends degraded storage once the hub's state was written
+// to it. Caller must hold the hub write lock.
+func (h *Hub) storageRecovered() {
+	health := &h.storageHealth
+	if !health.degraded() {
+		return
+	}
+
+	report := health.report()
+	downtime := h.clock.Now().Sub(health.since)
+	*health = storageHealth{}
+	slog.Info("Storage recovered", "pending", report.Pending, "retries", report.Retries, "downtime", downtime)
+	go h.publishSystemEvent(HealthTopic, HealthEvent{
+		Event:      HealthStorageRecovered,
+		Storage:    report,
+		DowntimeMs: downtime.Milliseconds(),
+	})
+}
+
+// retryStorage tries to write the hub's state to degraded storage, which
+// brings it back if the write succeeds
+func (h *Hub) retryStorage() {
+	h.mu.RLock()
+	degraded := h.storageHealth.degraded()
+	h.mu.RUnlock()
+	if !degraded {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.storage != nil && h.storageHealth.degraded() {
+		h.compactLocked()
+	}
+}
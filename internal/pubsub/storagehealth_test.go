@@ -0,0 +1,143 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyStorage fails every write while down is set
+type flakyStorage struct {
+	*FileStorage
+	down atomic.Bool
+}
+
+var errStorageDown = errors.New("storage down")
+
+func (s *flakyStorage) SaveTopic(topic TopicSnapshot) error {
+	if s.down.Load() {
+		return errStorageDown
+	}
+	return s.FileStorage.SaveTopic(topic)
+}
+
+func (s *flakyStorage) AppendMessage(message *PubSubMessage) error {
+	if s.down.Load() {
+		return errStorageDown
+	}
+	return s.FileStorage.AppendMessage(message)
+}
+
+func (s *flakyStorage) Compact(snapshot *Snapshot) error {
+	if s.down.Load() {
+		return errStorageDown
+	}
+	return s.FileStorage.Compact(snapshot)
+}
+
+func TestStorageDegradesAndRecovers(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	opts := DefaultHubOptions()
+	opts.Clock = clock
+	hub := NewHubWithOptions(opts)
+	storage := &flakyStorage{FileStorage: openTestStorage(t, dir)}
+	if _, err := hub.Recover(storage); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	watcher := newTestClient(hub)
+	hub.subscribeClient(&Subscription{client: watcher, topic: HealthTopic})
+
+	storage.down.Store(true)
+	hub.CreateTopic("orders")
+	retainMessages(hub, "orders", 3)
+
+	health := hub.StorageHealth()
+	if !health.Degraded || health.Pending != 4 || health.Error != errStorageDown.Error() {
+		t.Fatalf("Expected storage degraded with 4 changes pending, got %+v", health)
+	}
+	if stats, _ := hub.GetTopicStats("orders"); stats.BufferOccupancy != 3 {
+		t.Errorf("Expected publishes served from memory, got %d retained", stats.BufferOccupancy)
+	}
+	if event := nextHealthEvent(t, hub, watcher); event.Event != HealthStorageDegraded {
+		t.Errorf("Expected a degraded event, got %+v", event)
+	}
+
+	clock.Advance(storageRetryInterval)
+	hub.retryStorage()
+	if health := hub.StorageHealth(); !health.Degraded || health.Retries != 1 {
+		t.Errorf("Expected a failed retry counted, got %+v", health)
+	}
+
+	storage.down.Store(false)
+	clock.Advance(storageRetryInterval)
+	hub.retryStorage()
+	if health := hub.StorageHealth(); health.Degraded {
+		t.Fatalf("Expected storage recovered, got %+v", health)
+	}
+	event := nextHealthEvent(t, hub, watcher)
+	if event.Event != HealthStorageRecovered || event.Storage.Pending != 4 || event.DowntimeMs != (2*storageRetryInterval).Milliseconds() {
+		t.Errorf("Expected a recovered event for 4 pending changes after 10s, got %+v", event)
+	}
+
+	// The changes made while degraded were written behind
+	hub.CloseStorage()
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Topics != 1 || result.Messages != 3 {
+		t.Errorf("Expected the topic and its 3 messages persisted, got %+v", result)
+	}
+}
+
+// nextHealthEvent waits for the next $SYS/health event, which the hub
+// publishes from its own goroutine
+func nextHealthEvent(t *testing.T, hub *Hub, watcher *Client) HealthEvent {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for hub.publishes.topicPending(HealthTopic) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a health event")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	hub.dispatchPublishes()
+
+	frames := drainFrames(t, watcher)
+	if len(frames) != 1 {
+		t.Fatalf("Expected one health event, got %d", len(frames))
+	}
+	var event HealthEvent
+	data, _ := json.Marshal(frames[0].Message.Payload)
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Failed to decode health event: %v", err)
+	}
+	return event
+}
+
+func TestFileStorageCompactsAfterFailedReopen(t *testing.T) {
+	dir := t.TempDir()
+	storage := openTestStorage(t, dir)
+
+	// As if the log couldn't be reopened after an earlier compaction
+	storage.file.Close()
+	storage.file = nil
+	if err := storage.AppendMessage(&PubSubMessage{Topic: "orders"}); err == nil {
+		t.Fatal("Expected writes to fail without a log")
+	}
+	if err := storage.Compact(&Snapshot{}); err != nil {
+		t.Fatalf("Expected compaction to reopen the log, got %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := storage.Compact(&Snapshot{}); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed log to stay closed, got %v", err)
+	}
+}
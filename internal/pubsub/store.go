@@ -0,0 +1,301 @@
+package pubsub
+
+import (
+	"bytes"
+	"compress/flate"
+	"container/list"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+
+	"plivo/internal/logging"
+)
+
+// Store holds the messages each topic retains for replay, so retention can
+// live outside the broker's memory. The hub calls it with its lock held, in
+// publish order, and keeps topic metadata itself. Implementations should
+// retain at least the newest replayBufferSize messages of each topic, since
+// that is the replay window subscribers are promised.
+type Store interface {
+	// CreateTopic starts an empty retention for a topic, replacing any
+	// messages left over under the same name
+	CreateTopic(name string) error
+	// AppendMessage retains a message published to its topic, evicting the
+	// topic's oldest messages beyond the store's capacity
+	AppendMessage(message *PubSubMessage) error
+	// LoadRecent returns up to n of a topic's newest retained messages,
+	// oldest first; n <= 0 returns everything retained
+	LoadRecent(topic string, n int) ([]*PubSubMessage, error)
+	// DeleteTopic drops a topic's retained messages
+	DeleteTopic(name string) error
+}
+
+// MemoryStore is the default Store: a ring buffer per topic, sized to the
+// store's capacity unless the topic's retention policy resizes it
+type MemoryStore struct {
+	mu       sync.RWMutex
+	capacity int
+	rings    map[string]*messageRing
+	// compressMin is the payload size from which retained payloads are
+	// kept compressed (0 = never)
+	compressMin int
+	// budget caps the approximate bytes held across all topics (0 =
+	// unbounded); bytes is the current total
+	budget    int64
+	bytes     int64
+	evictions int64
+	// lru orders rings holding messages, most recently appended to first
+	lru *list.List
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// messageRing holds a topic's newest messages
+type messageRing struct {
+	name    string
+	entries []retainedEntry
+	head    int // next slot to write
+	size    int
+	bytes   int64
+	// elem is the ring's place in the store's LRU list, nil while empty
+	elem *list.Element
+}
+
+// retainedEntry is a retained message, with its payload held compressed
+// when it was large enough
+type retainedEntry struct {
+	message *PubSubMessage
+	// payload is the compressed JSON payload; message.Message.Payload is
+	// nil while it is set
+	payload []byte
+	// size is the approximate memory the entry holds
+	size int64
+}
+
+// MemoryStoreOption configures a MemoryStore
+type MemoryStoreOption func(*MemoryStore)
+
+// WithCompression keeps retained payloads of at least minSize encoded bytes
+// deflate-compressed, decompressing them on replay. Large payloads then
+// take a fraction of the memory for some CPU on publish and replay.
+// minSize <= 0 disables compression.
+func WithCompression(minSize int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.compressMin = max(minSize, 0)
+	}
+}
+
+// NewMemoryStore creates an in-memory store retaining up to capacity
+// messages per topic
+func NewMemoryStore(capacity int, opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		capacity: capacity,
+		rings:    make(map[string]*messageRing),
+		lru:      list.New(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateTopic starts an empty ring for a topic
+func (s *MemoryStore) CreateTopic(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ring, exists := s.rings[name]; exists {
+		s.release(ring)
+	}
+	s.rings[name] = &messageRing{name: name, entries: make([]retainedEntry, s.capacity)}
+	return nil
+}
+
+// AppendMessage writes a message over the oldest in its topic's ring, then
+// evicts messages of the least recently appended to topics while the store
+// is over its budget
+func (s *MemoryStore) AppendMessage(message *PubSubMessage) error {
+	entry := s.retain(message)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, exists := s.rings[message.Topic]
+	if !exists {
+		return ErrTopicNotFound
+	}
+	capacity := len(ring.entries)
+	if capacity == 0 {
+		return nil
+	}
+	if ring.size == capacity {
+		s.account(ring, -ring.entries[ring.head].size)
+	} else {
+		ring.size++
+	}
+	ring.entries[ring.head] = entry
+	ring.head = (ring.head + 1) % capacity
+	s.account(ring, entry.size)
+
+	if ring.elem == nil {
+		ring.elem = s.lru.PushFront(ring)
+	} else {
+		s.lru.MoveToFront(ring.elem)
+	}
+	s.enforceBudget(ring)
+	return nil
+}
+
+// LoadRecent returns up to n of a topic's newest messages, oldest first
+func (s *MemoryStore) LoadRecent(topic string, n int) ([]*PubSubMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ring, exists := s.rings[topic]
+	if !exists {
+		return nil, ErrTopicNotFound
+	}
+	if n <= 0 || n > ring.size {
+		n = ring.size
+	}
+	if n == 0 {
+		return []*PubSubMessage{}, nil
+	}
+
+	capacity := len(ring.entries)
+	messages := make([]*PubSubMessage, 0, n)
+	start := (ring.head - n + capacity) % capacity
+	for i := 0; i < n; i++ {
+		if message := ring.entries[(start+i)%capacity].load(); message != nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// DeleteTopic drops a topic's ring
+func (s *MemoryStore) DeleteTopic(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ring, exists := s.rings[name]; exists {
+		s.release(ring)
+		delete(s.rings, name)
+	}
+	return nil
+}
+
+// retain builds a message's ring entry, compressing its payload when it is
+// at least compressMin bytes encoded and compression saves space. The
+// retained copy shares everything but the payload with message.
+func (s *MemoryStore) retain(message *PubSubMessage) retainedEntry {
+	if message.Message == nil || message.Message.Payload == nil {
+		return retainedEntry{message: message, size: entrySize(message, 0)}
+	}
+	encoded, err := json.Marshal(message.Message.Payload)
+	if err != nil || s.compressMin == 0 || len(encoded) < s.compressMin {
+		return retainedEntry{message: message, size: entrySize(message, len(encoded))}
+	}
+
+	var buf bytes.Buffer
+	writer := flateWriters.Get().(*flate.Writer)
+	writer.Reset(&buf)
+	writer.Write(encoded)
+	writer.Close()
+	flateWriters.Put(writer)
+	if buf.Len() >= len(encoded) {
+		return retainedEntry{message: message, size: entrySize(message, len(encoded))}
+	}
+
+	data := *message.Message
+	data.Payload = nil
+	retained := *message
+	retained.Message = &data
+	return retainedEntry{message: &retained, payload: bytes.Clone(buf.Bytes()), size: entrySize(message, buf.Len())}
+}
+
+// flateWriters reuses compressors, which are expensive to allocate
+var flateWriters = sync.Pool{
+	New: func() any {
+		writer, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return writer
+	},
+}
+
+// load returns the entry's message with its payload decompressed. A
+// payload that fails to decompress, which can only be a bug, is logged and
+// the message returned without it.
+func (e retainedEntry) load() *PubSubMessage {
+	if e.payload == nil {
+		return e.message
+	}
+
+	data := *e.message.Message
+	message := *e.message
+	message.Message = &data
+
+	reader := flate.NewReader(bytes.NewReader(e.payload))
+	defer reader.Close()
+	encoded, err := io.ReadAll(reader)
+	if err == nil {
+		err = json.Unmarshal(encoded, &data.Payload)
+	}
+	if err != nil {
+		slog.Error("Decompressing retained message failed", logging.Topic, message.Topic, "message_id", data.ID, "error", err)
+	}
+	return &message
+}
+
+// retained returns up to n of a topic's newest retained messages, oldest
+// first; n <= 0 returns everything retained. A topic's retention policy
+// applies on read too: no more than its max_messages are returned, and
+// messages past their TTL or its max age are skipped, whether or not the
+// store has dropped them yet. Store failures are logged and read as nothing
+// retained. Caller must hold the hub lock.
+func (h *Hub) retained(topic string, n int) []*PubSubMessage {
+	t, exists := h.topics[topic]
+	if exists && t.retention != nil && t.retention.MaxMessages > 0 {
+		if n <= 0 || n > t.retention.MaxMessages {
+			n = t.retention.MaxMessages
+		}
+	}
+
+	messages, err := h.store.LoadRecent(topic, n)
+	if err != nil {
+		slog.Error("Store load failed", logging.Topic, topic, "error", err)
+		return []*PubSubMessage{}
+	}
+	if !exists {
+		return messages
+	}
+
+	now := h.clock.Now()
+	live := make([]*PubSubMessage, 0, len(messages))
+	for _, message := range messages {
+		if !t.expired(message, now) {
+			live = append(live, message)
+		}
+	}
+	return live
+}
+
+// messagesAfter returns a topic's retained messages with a sequence greater
+// than sequence, oldest first. Caller must hold the hub lock.
+func (h *Hub) messagesAfter(topic string, sequence int64) []*PubSubMessage {
+	var messages []*PubSubMessage
+	for _, message := range h.retained(topic, 0) {
+		if message.Sequence > sequence {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// logStoreError logs a failed store write. The change it belonged to still
+// applies, since the hub's own state stays authoritative.
+func logStoreError(op string, err error) {
+	if err != nil {
+		slog.Error("Store write failed", "op", op, "error", err)
+	}
+}
@@ -0,0 +1,135 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStoreKeepsNewestMessages(t *testing.T) {
+	store := NewMemoryStore(3)
+	store.CreateTopic("orders")
+
+	for i := 1; i <= 5; i++ {
+		if err := store.AppendMessage(&PubSubMessage{Topic: "orders", Sequence: int64(i)}); err != nil {
+			t.Fatalf("AppendMessage failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		n    int
+		want []int64
+	}{
+		{0, []int64{3, 4, 5}},
+		{2, []int64{4, 5}},
+		{10, []int64{3, 4, 5}},
+	}
+	for _, tt := range tests {
+		messages, err := store.LoadRecent("orders", tt.n)
+		if err != nil {
+			t.Fatalf("LoadRecent(%d) failed: %v", tt.n, err)
+		}
+		got := make([]int64, len(messages))
+		for i, message := range messages {
+			got[i] = message.Sequence
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("LoadRecent(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+
+	// Re-creating a topic starts it empty
+	store.CreateTopic("orders")
+	if messages, _ := store.LoadRecent("orders", 0); len(messages) != 0 {
+		t.Errorf("Expected a re-created topic to be empty, got %d messages", len(messages))
+	}
+
+	store.DeleteTopic("orders")
+	if err := store.AppendMessage(&PubSubMessage{Topic: "orders"}); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound appending to a deleted topic, got %v", err)
+	}
+	if _, err := store.LoadRecent("orders", 0); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound loading a deleted topic, got %v", err)
+	}
+}
+
+func TestMemoryStoreCompressesLargePayloads(t *testing.T) {
+	store := NewMemoryStore(10, WithCompression(256))
+	store.CreateTopic("logs")
+
+	large := map[string]interface{}{"line": strings.Repeat("GET /health 200 ", 64), "level": "info"}
+	published := []*PubSubMessage{
+		{Topic: "logs", Sequence: 1, Message: &MessageData{ID: "small", Payload: "ok", Headers: map[string]string{"env": "prod"}}},
+		{Topic: "logs", Sequence: 2, Message: &MessageData{ID: "large", Payload: large, Headers: map[string]string{"env": "prod"}}},
+	}
+	for _, message := range published {
+		store.AppendMessage(message)
+	}
+
+	ring := store.rings["logs"]
+	if ring.entries[0].payload != nil {
+		t.Error("Expected a payload under the threshold kept as is")
+	}
+	if entry := ring.entries[1]; entry.payload == nil || entry.message.Message.Payload != nil {
+		t.Fatal("Expected a large payload kept compressed")
+	} else if size := payloadSize(published[1].Message); len(entry.payload) >= size/4 {
+		t.Errorf("Expected a repetitive %d byte payload to compress well, got %d bytes", size, len(entry.payload))
+	}
+
+	messages, _ := store.LoadRecent("logs", 0)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	restored := messages[1].Message
+	if fmt.Sprint(restored.Payload) != fmt.Sprint(large) || restored.ID != "large" || restored.Headers["env"] != "prod" || messages[1].Sequence != 2 {
+		t.Errorf("Expected the large message restored intact, got %+v", restored)
+	}
+
+	// The published message is left alone
+	if published[1].Message.Payload == nil {
+		t.Error("Expected compression to leave the published message untouched")
+	}
+}
+
+// countingStore records the calls the hub makes to its store
+type countingStore struct {
+	*MemoryStore
+	appends map[string]int
+	deletes []string
+}
+
+func (s *countingStore) AppendMessage(message *PubSubMessage) error {
+	s.appends[message.Topic]++
+	return s.MemoryStore.AppendMessage(message)
+}
+
+func (s *countingStore) DeleteTopic(name string) error {
+	s.deletes = append(s.deletes, name)
+	return s.MemoryStore.DeleteTopic(name)
+}
+
+func TestHubUsesConfiguredStore(t *testing.T) {
+	store := &countingStore{MemoryStore: NewMemoryStore(replayBufferSize), appends: make(map[string]int)}
+	opts := DefaultHubOptions()
+	opts.Store = store
+	hub := NewHubWithOptions(opts)
+
+	hub.CreateTopic("orders")
+	retainMessages(hub, "orders", 3)
+	if store.appends["orders"] != 3 {
+		t.Errorf("Expected 3 appends to the store, got %d", store.appends["orders"])
+	}
+
+	stats, _ := hub.GetTopicStats("orders")
+	if stats.BufferOccupancy != 3 {
+		t.Errorf("Expected occupancy 3 read from the store, got %d", stats.BufferOccupancy)
+	}
+	if recent := hub.GetRecentMessages("orders", 2); len(recent) != 2 || recent[1].Message.ID != "msg-3" {
+		t.Errorf("Expected msg-2 and msg-3 from the store, got %d messages", len(recent))
+	}
+
+	hub.DeleteTopic("orders")
+	if len(store.deletes) != 1 || store.deletes[0] != "orders" {
+		t.Errorf("Expected the topic deleted from the store, got %v", store.deletes)
+	}
+}
@@ -0,0 +1,210 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StreamIdentity identifies a stream subscriber, such as a server-sent
+// events consumer, by its client ID
+func StreamIdentity(clientID string) string {
+	return "stream:" + clientID
+}
+
+// StreamOptions selects what a stream replays before live events and how
+// they are delivered
+type StreamOptions struct {
+	// LastN replays the newest retained messages, like last_n on subscribe
+	LastN int
+	// AfterSequence resumes after a topic sequence the consumer already
+	// has, replaying every retained message after it; it takes precedence
+	// over LastN (0 = unset)
+	AfterSequence int64
+	// KeyID must match an encrypted topic's key ID
+	KeyID string
+	// Fields projects event payloads, like fields on subscribe
+	Fields []string
+	// Group joins a consumer group, like group on subscribe: its members
+	// share the topic's events round-robin, and without a replay position
+	// the stream resumes from the group's offset ("" = none)
+	Group string
+	// Where delivers only events whose content meets the expression, like
+	// where on subscribe ("" = every event)
+	Where string
+}
+
+// StreamFrame is a frame for a stream consumer: the same JSON a WebSocket
+// client receives, and for events the topic sequence
+type StreamFrame struct {
+	// Sequence is the event's topic sequence, 0 for other frames
+	Sequence int64
+	Data     []byte
+}
+
+// Stream is a subscriber delivered over a transport other than a WebSocket,
+// such as server-sent events, gRPC or MQTT. The hub treats it as a client
+// without a connection: frames queue with the same backpressure, and the
+// owner reads them with Next until the stream closes. A stream that falls a
+// full queue behind is closed as a slow consumer.
+type Stream struct {
+	client *Client
+}
+
+// OpenStream registers a stream subscriber for a topic and queues its
+// replay. The stream must be closed with Close. Subscribes to draining,
+// deleted and encrypted topics fail as they do over WebSocket.
+func (h *Hub) OpenStream(id, topic string, opts StreamOptions, clientOpts ClientOptions) (*Stream, error) {
+	if err := h.checkStreamSubscribe(topic, opts); err != nil {
+		return nil, err
+	}
+	s, err := h.NewStream(id, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.subscribe(topic, opts); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewStream registers a stream subscriber without subscriptions, for
+// transports that subscribe to topics as they go. The stream must be closed
+// with Close.
+func (h *Hub) NewStream(id string, clientOpts ClientOptions) (*Stream, error) {
+	client := NewClient(h, nil, id, clientOpts)
+	client.stream = true
+	if err := h.RegisterClient(client); err != nil {
+		return nil, err
+	}
+	client.pumpStarted()
+	return &Stream{client: client}, nil
+}
+
+// Subscribe subscribes the stream to a topic and queues its replay
+func (s *Stream) Subscribe(topic string, opts StreamOptions) error {
+	if err := s.client.hub.checkStreamSubscribe(topic, opts); err != nil {
+		return err
+	}
+	return s.subscribe(topic, opts)
+}
+
+// Unsubscribe stops delivering a topic's events to the stream. Events
+// already queued are still delivered.
+func (s *Stream) Unsubscribe(topic string) {
+	c := s.client
+	c.mu.Lock()
+	delete(c.subscriptions, topic)
+	delete(c.options, topic)
+	c.endReplayDedup(topic)
+	c.mu.Unlock()
+
+	select {
+	case c.hub.unsubscribe <- &Subscription{client: c, topic: topic}:
+	case <-c.hub.shutdown:
+	}
+}
+
+// checkStreamSubscribe validates a stream subscription the way WebSocket
+// subscribes are validated
+func (h *Hub) checkStreamSubscribe(topic string, opts StreamOptions) error {
+	if err := validateFields(opts.Fields); err != nil {
+		return err
+	}
+	if _, err := parseWhere(opts.Where); err != nil {
+		return err
+	}
+	if opts.LastN < 0 || opts.AfterSequence < 0 {
+		return errors.New("replay position must not be negative")
+	}
+	if opts.Group != "" {
+		if err := ValidateGroupName(opts.Group); err != nil {
+			return err
+		}
+	}
+	// Streams have no inbox of their own
+	if IsInboxTopic(topic) {
+		return ErrInboxPrivate
+	}
+	if replacement, draining := h.drainingTopic(topic); draining {
+		if replacement != "" {
+			return fmt.Errorf("%w; subscribe to %s instead", ErrTopicDraining, replacement)
+		}
+		return ErrTopicDraining
+	}
+	if err := h.checkNotDeleted(topic); err != nil {
+		return err
+	}
+	return h.checkSubscribeKey(topic, opts.KeyID)
+}
+
+// subscribe subscribes the stream to a checked topic and queues its replay
+func (s *Stream) subscribe(topic string, opts StreamOptions) error {
+	c, h := s.client, s.client.hub
+	// Checked by checkStreamSubscribe
+	where, _ := parseWhere(opts.Where)
+	c.mu.Lock()
+	c.subscriptions[topic] = true
+	c.options[topic] = subscriptionOptions{fields: opts.Fields, keyID: opts.KeyID, group: opts.Group, where: where}
+	c.mu.Unlock()
+
+	if err := h.awaitSubscribe(&Subscription{client: c, topic: topic}); err != nil {
+		return err
+	}
+
+	var backlog []*PubSubMessage
+	if opts.AfterSequence > 0 {
+		h.mu.RLock()
+		backlog = h.messagesAfter(topic, opts.AfterSequence)
+		h.mu.RUnlock()
+	} else {
+		_, backlog = h.prepareReplay(topic, opts.LastN, opts.Group)
+	}
+	if where != nil {
+		backlog = filterMessages(backlog, nil, where)
+	}
+	if len(backlog) > 0 {
+		go c.replay(topic, backlog)
+	}
+	return nil
+}
+
+// Ready is signalled when frames are waiting to be read
+func (s *Stream) Ready() <-chan struct{} {
+	return s.client.queue.Ready()
+}
+
+// Next returns the frames waiting to be written and whether the stream has
+// closed, in which case the frames are the last. Events whose TTL ran out
+// while queued are dropped and counted against their topic.
+func (s *Stream) Next() ([]StreamFrame, bool) {
+	queued, closed := s.client.queue.DrainFrames()
+	frames := make([]StreamFrame, 0, len(queued))
+	dropped := make(map[string]int)
+	now := s.client.hub.clock.Now()
+	for _, frame := range queued {
+		// Streams never set a max_latency, so only TTLs set deadlines
+		if !frame.deadline.IsZero() && now.After(frame.deadline) {
+			dropped[frame.topic]++
+			s.client.hub.deadLetter(frame.message, DropExpired, s.client.identity())
+			continue
+		}
+		frames = append(frames, StreamFrame{Sequence: frame.sequence, Data: frame.data})
+	}
+	for topic, count := range dropped {
+		s.client.hub.recordDrops(topic, count)
+	}
+	return frames, closed
+}
+
+// Close unsubscribes the stream and unregisters it from the hub
+func (s *Stream) Close() {
+	h := s.client.hub
+	select {
+	case h.unregister <- s.client:
+	case <-h.shutdown:
+	}
+	s.client.queue.Close()
+	s.client.queue.Drain()
+	s.client.pumpStopped()
+}
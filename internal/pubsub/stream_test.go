@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// readStream collects a stream's frames until want have arrived
+func readStream(t *testing.T, stream *Stream, want int) []StreamFrame {
+	t.Helper()
+
+	var frames []StreamFrame
+	timeout := time.After(time.Second)
+	for len(frames) < want {
+		select {
+		case <-stream.Ready():
+			next, _ := stream.Next()
+			frames = append(frames, next...)
+		case <-timeout:
+			t.Fatalf("Timed out with %d of %d frames", len(frames), want)
+		}
+	}
+	return frames
+}
+
+func TestStreamResumesAfterSequence(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	retainMessages(hub, "orders", 5)
+
+	stream, err := hub.OpenStream("sse-1", "orders", StreamOptions{AfterSequence: 3}, DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	// The welcome frame, then sequences 4 and 5
+	frames := readStream(t, stream, 3)
+	var welcome ServerMessage
+	json.Unmarshal(frames[0].Data, &welcome)
+	if welcome.Type != InfoMessage || frames[0].Sequence != 0 {
+		t.Errorf("Expected the welcome info frame first, got %s", frames[0].Data)
+	}
+	if frames[1].Sequence != 4 || frames[2].Sequence != 5 {
+		t.Errorf("Expected sequences 4 and 5 replayed, got %d and %d", frames[1].Sequence, frames[2].Sequence)
+	}
+
+	// Live events follow
+	message, _ := NewMessage("orders", "live", WithID("msg-6"))
+	hub.TryPublish(message, time.Second)
+	live := readStream(t, stream, 1)
+	var event ServerMessage
+	json.Unmarshal(live[0].Data, &event)
+	if live[0].Sequence != 6 || event.Type != EventMessage || event.Message.ID != "msg-6" {
+		t.Errorf("Expected live event msg-6 at sequence 6, got %d: %s", live[0].Sequence, live[0].Data)
+	}
+
+	if stats := hub.GetStats(); stats.TotalClients != 1 {
+		t.Errorf("Expected the stream registered as a client, got %d clients", stats.TotalClients)
+	}
+}
+
+func TestStreamClosesWithHub(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	hub.CreateTopic("orders")
+
+	stream, err := hub.OpenStream("sse-1", "orders", StreamOptions{}, DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	hub.Shutdown()
+	timeout := time.After(6 * time.Second)
+	for {
+		select {
+		case <-stream.Ready():
+			if _, closed := stream.Next(); closed {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for the stream to close")
+		}
+	}
+}
+
+func TestOpenStreamRejections(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	hub.CreateTopic("orders-v2")
+	hub.CreateTopicWithOptions("sealed", TopicOptions{KeyID: "key-1"})
+	hub.DrainTopic("orders", DrainOptions{Replacement: "orders-v2"})
+
+	tests := []struct {
+		topic string
+		opts  StreamOptions
+		want  ErrorCode
+	}{
+		{"orders", StreamOptions{}, CodeTopicDraining},
+		{"sealed", StreamOptions{KeyID: "key-2"}, CodeForbidden},
+		{"orders-v2", StreamOptions{LastN: -1}, CodeBadRequest},
+		{"orders-v2", StreamOptions{Fields: []string{""}}, CodeBadRequest},
+	}
+	for _, tt := range tests {
+		if _, err := hub.OpenStream("sse", tt.topic, tt.opts, DefaultClientOptions()); CodeOf(err) != tt.want || err == nil {
+			t.Errorf("%s %+v: expected %s, got %v", tt.topic, tt.opts, tt.want, err)
+		}
+	}
+
+	if _, err := hub.OpenStream("sse", "orders", StreamOptions{}, DefaultClientOptions()); !errors.Is(err, ErrTopicDraining) {
+		t.Errorf("Expected ErrTopicDraining, got %v", err)
+	}
+}
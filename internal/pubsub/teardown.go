@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"runtime"
+	"time"
+)
+
+// pumpExitGrace is how long a disconnected client's pumps may keep running
+// before they are reported as lingering. Pumps exit as soon as the connection
+// is closed and the queue drained, which is bounded by the write deadline.
+const pumpExitGrace = 5 * time.Second
+
+// RuntimeStats reports process and client teardown health, used to verify
+// that disconnected clients release their goroutines and queues
+type RuntimeStats struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	Clients        int    `json:"clients"`
+	// ReadPump and WritePump goroutines currently running
+	ActivePumps int64 `json:"active_pumps"`
+	// Frames queued for registered clients
+	QueuedFrames int `json:"queued_frames"`
+	// Unregistered clients whose pumps are still exiting
+	DepartingClients int `json:"departing_clients"`
+	// Departing clients whose pumps outlived pumpExitGrace
+	LingeringClients int `json:"lingering_clients"`
+	// LeakSuspected is set when any departing client is lingering
+	LeakSuspected bool `json:"leak_suspected"`
+}
+
+// pumpStarted records a ReadPump or WritePump starting
+func (c *Client) pumpStarted() {
+	c.pumps.Add(1)
+	c.hub.pumps.Add(1)
+}
+
+// pumpStopped records a pump exiting; once both have exited the client is
+// fully torn down
+func (c *Client) pumpStopped() {
+	c.hub.pumps.Add(-1)
+	if c.pumps.Add(-1) == 0 {
+		c.hub.clientTornDown(c)
+	}
+}
+
+// trackDeparture remembers an unregistered client until its pumps exit.
+// Caller must hold the lock.
+func (h *Hub) trackDeparture(client *Client) {
+	if client.pumps.Load() > 0 {
+		h.departed[client] = h.clock.Now()
+	}
+}
+
+// clientTornDown forgets a departed client once its pumps have exited
+func (h *Hub) clientTornDown(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.departed, client)
+}
+
+// GetRuntimeStats returns goroutine, memory and client teardown statistics
+func (h *Hub) GetRuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := RuntimeStats{
+		Goroutines:       runtime.NumGoroutine(),
+		HeapAllocBytes:   mem.HeapAlloc,
+		Clients:          len(h.clients),
+		ActivePumps:      h.pumps.Load(),
+		DepartingClients: len(h.departed),
+	}
+
+	for client := range h.clients {
+		stats.QueuedFrames += client.queue.Len()
+	}
+
+	for _, since := range h.departed {
+		if h.clock.Now().Sub(since) > pumpExitGrace {
+			stats.LingeringClients++
+		}
+	}
+
+	stats.LeakSuspected = stats.LingeringClients > 0
+	return stats
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuntimeStatsTracksDepartingClients(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	client.pumpStarted()
+	client.pumpStarted()
+
+	hub.mu.Lock()
+	hub.clients[client] = true
+	hub.mu.Unlock()
+	hub.unregisterClient(client)
+
+	stats := hub.GetRuntimeStats()
+	if stats.ActivePumps != 2 || stats.DepartingClients != 1 {
+		t.Errorf("Expected 2 pumps for 1 departing client, got %+v", stats)
+	}
+	if stats.LeakSuspected {
+		t.Error("A client that just departed should not be reported as leaking")
+	}
+
+	client.pumpStopped()
+	client.pumpStopped()
+
+	stats = hub.GetRuntimeStats()
+	if stats.ActivePumps != 0 || stats.DepartingClients != 0 {
+		t.Errorf("Expected the client to be torn down, got %+v", stats)
+	}
+}
+
+func TestRuntimeStatsReportsLingeringPumps(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+	client.pumpStarted()
+
+	hub.mu.Lock()
+	hub.departed[client] = time.Now().Add(-2 * pumpExitGrace)
+	hub.mu.Unlock()
+
+	stats := hub.GetRuntimeStats()
+	if stats.LingeringClients != 1 || !stats.LeakSuspected {
+		t.Errorf("Expected a lingering client to be flagged, got %+v", stats)
+	}
+}
+
+func TestUnregisterWithoutPumpsIsNotTracked(t *testing.T) {
+	hub := NewHub()
+	client := newTestClient(hub)
+
+	hub.mu.Lock()
+	hub.clients[client] = true
+	hub.mu.Unlock()
+	hub.unregisterClient(client)
+
+	if stats := hub.GetRuntimeStats(); stats.DepartingClients != 0 {
+		t.Errorf("Expected no departing clients, got %d", stats.DepartingClients)
+	}
+}
@@ -0,0 +1,68 @@
+package pubsub
+
+import "fmt"
+
+// TenantUsage is what a tenant's topics hold, for its share of a broker
+// shared between teams
+type TenantUsage struct {
+	Tenant string `json:"tenant"`
+	// Topics is how many topics the tenant owns, out of TopicLimit (0 =
+	// unlimited)
+	Topics     int `json:"topics"`
+	TopicLimit int `json:"topic_limit,omitempty"`
+	// Subscribers and Messages total over the tenant's topics
+	Subscribers int   `json:"subscribers"`
+	Messages    int64 `json:"messages"`
+	// RetainedMessages and RetainedBytes are what the tenant's topics
+	// retain for replay
+	RetainedMessages int   `json:"retained_messages"`
+	RetainedBytes    int64 `json:"retained_bytes"`
+}
+
+// TenantUsage totals the topics the tenant owns
+func (h *Hub) TenantUsage(tenant string) TenantUsage {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	usage := TenantUsage{Tenant: tenant, TopicLimit: h.tenantTopicLimit}
+	for _, topic := range h.topics {
+		if topic.owner != tenant {
+			continue
+		}
+		stats := h.topicStats(topic)
+		usage.Topics++
+		usage.Subscribers += stats.SubscriberCount
+		usage.Messages += stats.MessageCount
+		usage.RetainedMessages += stats.BufferOccupancy
+		usage.RetainedBytes += stats.RetainedBytes
+	}
+	return usage
+}
+
+// ownedTopics counts the topics a tenant owns. Caller must hold the hub lock.
+func (h *Hub) ownedTopics(tenant string) int {
+	owned := 0
+	for _, topic := range h.topics {
+		if topic.owner == tenant {
+			owned++
+		}
+	}
+	return owned
+}
+
+// checkTenantQuota rejects giving a tenant another topic once it owns
+// tenantTopicLimit of them, reporting it on $SYS/quota. Caller must hold
+// the hub write lock.
+func (h *Hub) checkTenantQuota(tenant, topic string) error {
+	if tenant == "" || h.tenantTopicLimit <= 0 || h.ownedTopics(tenant) < h.tenantTopicLimit {
+		return nil
+	}
+	// Quota events are published outside the lock
+	go h.ReportQuota(QuotaEvent{
+		Quota:    QuotaTenantTopics,
+		Identity: TenantIdentity(tenant),
+		Topic:    topic,
+		Limit:    int64(h.tenantTopicLimit),
+	})
+	return fmt.Errorf("%w: tenant %s owns %d topics", ErrTenantQuota, tenant, h.tenantTopicLimit)
+}
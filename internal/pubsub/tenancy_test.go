@@ -0,0 +1,69 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTenantTopicQuota(t *testing.T) {
+	opts := DefaultHubOptions()
+	opts.TenantTopicLimit = 2
+	hub := NewHubWithOptions(opts)
+
+	for _, name := range []string{"payments/orders", "payments/refunds"} {
+		if err := hub.CreateTopicWithOptions(name, TopicOptions{Owner: "payments"}); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	err := hub.CreateTopicWithOptions("payments/payouts", TopicOptions{Owner: "payments"})
+	if !errors.Is(err, ErrTenantQuota) || CodeOf(err) != CodeQuotaExceeded {
+		t.Errorf("Expected QUOTA_EXCEEDED for a third topic, got %v", err)
+	}
+
+	// Other tenants and unowned topics have their own allowance
+	if err := hub.CreateTopicWithOptions("search/queries", TopicOptions{Owner: "search"}); err != nil {
+		t.Errorf("Expected another tenant's topic to be created, got %v", err)
+	}
+	if err := hub.CreateTopic("shared"); err != nil {
+		t.Errorf("Expected an unowned topic to be created, got %v", err)
+	}
+
+	if _, err := hub.TransferTopic("shared", "payments"); !errors.Is(err, ErrTenantQuota) {
+		t.Errorf("Expected a transfer to a tenant at its quota to fail, got %v", err)
+	}
+	if _, err := hub.TransferTopic("payments/orders", "payments"); err != nil {
+		t.Errorf("Expected a transfer to the current owner to succeed, got %v", err)
+	}
+
+	if err := hub.DeleteTopic("payments/orders"); err != nil {
+		t.Fatalf("Failed to delete topic: %v", err)
+	}
+	if err := hub.CreateTopicWithOptions("payments/payouts", TopicOptions{Owner: "payments"}); err != nil {
+		t.Errorf("Expected a deleted topic to free quota, got %v", err)
+	}
+}
+
+func TestTenantUsage(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopicWithOptions("payments/orders", TopicOptions{Owner: "payments"})
+	hub.CreateTopicWithOptions("payments/refunds", TopicOptions{Owner: "payments"})
+	hub.CreateTopicWithOptions("search/queries", TopicOptions{Owner: "search"})
+
+	subscriber := newTestClient(hub)
+	subscriber.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "payments/orders", ClientID: "c1"})
+	waitForSubscribers(t, hub, "payments/orders", 1)
+	for _, id := range []string{"msg-1", "msg-2"} {
+		hub.publishMessage(&PubSubMessage{Topic: "payments/orders", Message: &MessageData{ID: id, Payload: "x"}})
+	}
+
+	usage := hub.TenantUsage("payments")
+	if usage.Topics != 2 || usage.Subscribers != 1 || usage.Messages != 2 || usage.RetainedMessages != 2 || usage.RetainedBytes == 0 {
+		t.Errorf("Unexpected payments usage %+v", usage)
+	}
+	if usage := hub.TenantUsage("search"); usage.Topics != 1 || usage.Messages != 0 {
+		t.Errorf("Unexpected search usage %+v", usage)
+	}
+}
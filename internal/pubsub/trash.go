@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"log/slog"
+	"time"
+
+	"plivo/internal/logging"
+)
+
+// DeletedTopic describes a topic deleted within the trash window, which can
+// still be restored
+type DeletedTopic struct {
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// PurgeAt is when the topic is deleted for good
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// trashedTopic is a deleted topic kept, with its retained messages, schemas
+// and group offsets, until its trash window passes
+type trashedTopic struct {
+	topic     *Topic
+	deletedAt time.Time
+	purgeAt   time.Time
+}
+
+// trashed returns a deleted topic that can still be restored, nil if there
+// is none. Caller must hold the hub lock.
+func (h *Hub) trashed(name string) *trashedTopic {
+	entry, exists := h.trash[name]
+	if !exists || !h.clock.Now().Before(entry.purgeAt) {
+		return nil
+	}
+	return entry
+}
+
+// checkNotDeleted rejects subscribes to a deleted topic that can still be
+// restored
+func (h *Hub) checkNotDeleted(name string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.trashed(name) != nil {
+		return ErrTopicDeleted
+	}
+	return nil
+}
+
+// DeletedTopicInfo returns the trash state of a deleted topic, and false if
+// the topic isn't awaiting purge
+func (h *Hub) DeletedTopicInfo(name string) (DeletedTopic, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entry := h.trashed(name)
+	if entry == nil {
+		return DeletedTopic{}, false
+	}
+	return DeletedTopic{Name: name, DeletedAt: entry.deletedAt, PurgeAt: entry.purgeAt}, true
+}
+
+// RestoreTopic brings back a topic deleted within the trash window, with its
+// sequence, retained messages, schemas, group offsets and owner. Subscribers
+// detached by the delete are not re-attached.
+func (h *Hub) RestoreTopic(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := h.trashed(name)
+	if entry == nil {
+		return ErrTopicNotFound
+	}
+	delete(h.trash, name)
+
+	topic := entry.topic
+	h.topics[name] = topic
+	h.updateSubscriberCount(name)
+	h.stats.TotalTopics = len(h.topics)
+	h.persistTopic("restore topic", topic)
+	return nil
+}
+
+// PurgeTopic deletes a topic for good, whether it is live or awaiting purge,
+// so its name can be reused at once
+func (h *Hub) PurgeTopic(name string) error {
+	if IsInboxTopic(name) {
+		return ErrReservedTopic
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.topics[name]; exists {
+		h.removeTopic(name)
+	} else if _, exists := h.trash[name]; exists {
+		delete(h.trash, name)
+	} else {
+		return ErrTopicNotFound
+	}
+	logStoreError("delete topic", h.store.DeleteTopic(name))
+	return nil
+}
+
+// purgeExpiredTopics drops deleted topics whose trash window has passed
+func (h *Hub) purgeExpiredTopics() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.clock.Now()
+	for name, entry := range h.trash {
+		if !now.Before(entry.purgeAt) {
+			delete(h.trash, name)
+			logStoreError("delete topic", h.store.DeleteTopic(name))
+			slog.Info("Purged deleted topic", logging.Topic, name)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+)
+
+func newTrashHub(window time.Duration) *Hub {
+	opts := DefaultHubOptions()
+	opts.TrashWindow = window
+	return NewHubWithOptions(opts)
+}
+
+func TestDeletedTopicCanBeRestored(t *testing.T) {
+	hub := newTrashHub(time.Minute)
+	hub.CreateTopicWithOptions("orders", TopicOptions{Owner: "acme"})
+	retainMessages(hub, "orders", 3)
+
+	if err := hub.DeleteTopic("orders"); err != nil {
+		t.Fatalf("DeleteTopic failed: %v", err)
+	}
+	if hub.TopicExists("orders") {
+		t.Fatal("Expected the deleted topic to be gone")
+	}
+	if deleted, ok := hub.DeletedTopicInfo("orders"); !ok || deleted.PurgeAt.Sub(deleted.DeletedAt) != time.Minute {
+		t.Errorf("Expected the topic awaiting purge a minute after deletion, got %+v, %v", deleted, ok)
+	}
+	if owner, err := hub.TopicOwner("orders"); err != nil || owner != "acme" {
+		t.Errorf("Expected the deleted topic to keep owner acme, got %q, %v", owner, err)
+	}
+
+	// Publishes, subscribes and re-creation are rejected meanwhile
+	message, _ := NewMessage("orders", "x", WithID("msg-4"))
+	if _, err := hub.TryPublish(message, time.Millisecond); err != ErrTopicDeleted {
+		t.Errorf("Expected publish to fail with ErrTopicDeleted, got %v", err)
+	}
+	if err := hub.CreateTopic("orders"); err != ErrTopicDeleted {
+		t.Errorf("Expected re-creation to fail with ErrTopicDeleted, got %v", err)
+	}
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "late", RequestID: "sub-1"})
+	frames := drainFrames(t, client)
+	if len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeTopicDeleted {
+		t.Errorf("Expected a TOPIC_DELETED error frame, got %+v", frames)
+	}
+
+	if err := hub.RestoreTopic("orders"); err != nil {
+		t.Fatalf("RestoreTopic failed: %v", err)
+	}
+	stats, err := hub.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Sequence != 3 || stats.BufferOccupancy != 3 || stats.Owner != "acme" {
+		t.Errorf("Expected sequence 3, 3 retained and owner acme, got %+v", stats)
+	}
+	if _, ok := hub.DeletedTopicInfo("orders"); ok {
+		t.Error("Expected the restored topic to leave the trash")
+	}
+	if err := hub.RestoreTopic("orders"); err != ErrTopicNotFound {
+		t.Errorf("Expected a second restore to fail with ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestExpiredTopicIsPurged(t *testing.T) {
+	hub := newTrashHub(time.Minute)
+	hub.CreateTopic("orders")
+	hub.DeleteTopic("orders")
+
+	hub.mu.Lock()
+	hub.trash["orders"].purgeAt = time.Now()
+	hub.mu.Unlock()
+
+	if err := hub.RestoreTopic("orders"); err != ErrTopicNotFound {
+		t.Errorf("Expected an expired topic to be unrestorable, got %v", err)
+	}
+	hub.purgeExpiredTopics()
+	if len(hub.trash) != 0 {
+		t.Errorf("Expected the expired topic purged, got %d in trash", len(hub.trash))
+	}
+	if err := hub.CreateTopic("orders"); err != nil {
+		t.Errorf("Expected the name to be reusable after purge, got %v", err)
+	}
+}
+
+func TestPurgeTopic(t *testing.T) {
+	hub := newTrashHub(time.Minute)
+	hub.CreateTopic("orders")
+	hub.CreateTopic("invoices")
+	hub.DeleteTopic("orders")
+
+	if err := hub.PurgeTopic("orders"); err != nil {
+		t.Errorf("Expected a deleted topic to be purgeable, got %v", err)
+	}
+	if err := hub.PurgeTopic("invoices"); err != nil {
+		t.Errorf("Expected a live topic to be purgeable, got %v", err)
+	}
+	if _, ok := hub.DeletedTopicInfo("invoices"); ok || hub.TopicExists("invoices") {
+		t.Error("Expected the purged topic to skip the trash")
+	}
+	if err := hub.PurgeTopic("orders"); err != ErrTopicNotFound {
+		t.Errorf("Expected ErrTopicNotFound purging twice, got %v", err)
+	}
+	if err := hub.CreateTopic("orders"); err != nil {
+		t.Errorf("Expected the name to be reusable after purge, got %v", err)
+	}
+}
+
+func TestDeleteWithoutTrashWindow(t *testing.T) {
+	hub := NewHub()
+	hub.CreateTopic("orders")
+	hub.DeleteTopic("orders")
+
+	if err := hub.RestoreTopic("orders"); err != ErrTopicNotFound {
+		t.Errorf("Expected no restore without a trash window, got %v", err)
+	}
+	if err := hub.CreateTopic("orders"); err != nil {
+		t.Errorf("Expected immediate re-creation, got %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package pubsub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Message validation limits
+const (
+	// MaxMessageIDLength is the longest accepted message ID in bytes
+	MaxMessageIDLength = 256
+	// MaxMessageHeaders is the most headers a message may carry
+	MaxMessageHeaders = 32
+	// MaxHeaderKeyLength and MaxHeaderValueLength bound each header in bytes
+	MaxHeaderKeyLength   = 128
+	MaxHeaderValueLength = 1024
+	// ReservedHeaderPrefix marks header keys set by the broker, not publishers
+	ReservedHeaderPrefix = "_"
+)
+
+// Payload content types
+const (
+	// ContentTypeJSON payloads are any JSON value
+	ContentTypeJSON = "application/json"
+	// ContentTypeText payloads are a JSON string
+	ContentTypeText = "text/plain"
+	// ContentTypeBinary payloads are a base64-encoded JSON string
+	ContentTypeBinary = "application/octet-stream"
+)
+
+// InvalidMessageError reports a message field that failed validation. It
+// matches ErrInvalidMessage with errors.Is.
+type InvalidMessageError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InvalidMessageError) Error() string {
+	return fmt.Sprintf("invalid message %s: %s", e.Field, e.Reason)
+}
+
+// Unwrap lets callers test for any validation failure with ErrInvalidMessage
+func (e *InvalidMessageError) Unwrap() error {
+	return ErrInvalidMessage
+}
+
+// MessageOption configures a message built by NewMessage
+type MessageOption func(*messageOptions)
+
+type messageOptions struct {
+	id          string
+	ttl         time.Duration
+	headers     map[string]string
+	contentType string
+	maxSize     int64
+	generateID  bool
+	publisher   string
+	timestamp   time.Time
+}
+
+// WithID sets the message ID
+func WithID(id string) MessageOption {
+	return func(o *messageOptions) { o.id = id }
+}
+
+// WithTTL sets the message's time to live, stored in milliseconds
+func WithTTL(ttl time.Duration) MessageOption {
+	return func(o *messageOptions) { o.ttl = ttl }
+}
+
+// WithHeaders attaches string headers delivered with the event
+func WithHeaders(headers map[string]string) MessageOption {
+	return func(o *messageOptions) { o.headers = headers }
+}
+
+// WithContentType sets the payload's content type
+func WithContentType(contentType string) MessageOption {
+	return func(o *messageOptions) { o.contentType = contentType }
+}
+
+// WithMaxSize rejects payloads whose encoded size exceeds limit bytes
+// (0 = unlimited)
+func WithMaxSize(limit int64) MessageOption {
+	return func(o *messageOptions) { o.maxSize = limit }
+}
+
+// WithGeneratedID assigns a broker-generated ID when the message has none,
+// instead of rejecting it
+func WithGeneratedID() MessageOption {
+	return func(o *messageOptions) { o.generateID = true }
+}
+
+// NewMessage builds a message for publishing to topic, validating its ID,
+// TTL, headers, content type and size up front. Validation failures are returned as
+// *InvalidMessageError, or *MessageTooLargeError for oversized payloads.
+func NewMessage(topic string, payload interface{}, opts ...MessageOption) (*PubSubMessage, error) {
+	return NewMessageFromData(topic, &MessageData{Payload: payload}, opts...)
+}
+
+// NewMessageFromData validates decoded message data, such as the body of a
+// publish request, and wraps it for publishing to topic. Options override
+// the data's ID, TTL, headers and content type when set. A missing content
+// type is set to application/json. Without WithTimestamp, the hub stamps
+// the message with its clock when it admits the publish.
+func NewMessageFromData(topic string, data *MessageData, opts ...MessageOption) (*PubSubMessage, error) {
+	if data == nil {
+		return nil, &InvalidMessageError{Field: "message", Reason: "is required"}
+	}
+
+	var o messageOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.id != "" {
+		data.ID = o.id
+	}
+	if o.ttl != 0 {
+		data.TTLMs = o.ttl.Milliseconds()
+	}
+	if o.headers != nil {
+		data.Headers = o.headers
+	}
+	if o.contentType != "" {
+		data.ContentType = o.contentType
+	}
+	if data.ID == "" && o.generateID {
+		data.ID = NewMessageID()
+	}
+
+	if topic == "" {
+		return nil, &InvalidMessageError{Field: "topic", Reason: "is required"}
+	}
+	if err := validateMessageID(data.ID); err != nil {
+		return nil, err
+	}
+	if data.TTLMs < 0 {
+		return nil, &InvalidMessageError{Field: "ttl_ms", Reason: "must not be negative"}
+	}
+	if err := validateHeaders(data.Headers); err != nil {
+		return nil, err
+	}
+	if err := validateContentType(data); err != nil {
+		return nil, err
+	}
+	// Measured once, for the size limit and the topic's size histogram
+	size := payloadSize(data)
+	if err := checkMessageSize(size, o.maxSize); err != nil {
+		return nil, err
+	}
+
+	return &PubSubMessage{
+		Topic:     topic,
+		Message:   data,
+		Timestamp: o.timestamp,
+		publisher: o.publisher,
+		size:      size,
+	}, nil
+}
+
+// validateMessageID requires a non-empty, bounded ID of printable characters
+func validateMessageID(id string) error {
+	switch {
+	case id == "":
+		return &InvalidMessageError{Field: "id", Reason: "is required"}
+	case len(id) > MaxMessageIDLength:
+		return &InvalidMessageError{Field: "id", Reason: fmt.Sprintf("exceeds %d bytes", MaxMessageIDLength)}
+	case strings.IndexFunc(id, unicode.IsControl) >= 0:
+		return &InvalidMessageError{Field: "id", Reason: "must not contain control characters"}
+	}
+	return nil
+}
+
+// validateHeaders bounds the number and size of headers and keeps publishers
+// out of the reserved key prefix
+func validateHeaders(headers map[string]string) error {
+	if len(headers) > MaxMessageHeaders {
+		return &InvalidMessageError{Field: "headers", Reason: fmt.Sprintf("exceeds %d entries", MaxMessageHeaders)}
+	}
+	for key, value := range headers {
+		switch {
+		case key == "":
+			return &InvalidMessageError{Field: "headers", Reason: "keys must not be empty"}
+		case len(key) > MaxHeaderKeyLength:
+			return &InvalidMessageError{Field: "headers", Reason: fmt.Sprintf("key %q exceeds %d bytes", key[:16]+"...", MaxHeaderKeyLength)}
+		case strings.HasPrefix(key, ReservedHeaderPrefix):
+			return &InvalidMessageError{Field: "headers", Reason: fmt.Sprintf("key %q uses the reserved %q prefix", key, ReservedHeaderPrefix)}
+		case len(value) > MaxHeaderValueLength:
+			return &InvalidMessageError{Field: "headers", Reason: fmt.Sprintf("value of %q exceeds %d bytes", key, MaxHeaderValueLength)}
+		}
+	}
+	return nil
+}
+
+// validateContentType checks the payload matches its content type and
+// normalizes the type, defaulting to application/json
+func validateContentType(data *MessageData) error {
+	if data.ContentType == "" {
+		data.ContentType = ContentTypeJSON
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(data.ContentType)
+	if err != nil {
+		return &InvalidMessageError{Field: "content_type", Reason: "is not a valid media type"}
+	}
+
+	switch mediaType {
+	case ContentTypeJSON:
+	case ContentTypeText:
+		if _, ok := data.Payload.(string); !ok {
+			return &InvalidMessageError{Field: "payload", Reason: "must be a string for text/plain"}
+		}
+	case ContentTypeBinary:
+		encoded, ok := data.Payload.(string)
+		if !ok {
+			return &InvalidMessageError{Field: "payload", Reason: "must be a base64 string for application/octet-stream"}
+		}
+		if _, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+			return &InvalidMessageError{Field: "payload", Reason: "is not valid base64"}
+		}
+	default:
+		return &InvalidMessageError{
+			Field:  "content_type",
+			Reason: fmt.Sprintf("must be %s, %s or %s", ContentTypeJSON, ContentTypeText, ContentTypeBinary),
+		}
+	}
+
+	data.ContentType = mime.FormatMediaType(mediaType, params)
+	return nil
+}
+
+// isJSON reports whether the payload is JSON, as opposed to text or bytes
+func (d *MessageData) isJSON() bool {
+	return d == nil || d.ContentType == "" || strings.HasPrefix(d.ContentType, ContentTypeJSON)
+}
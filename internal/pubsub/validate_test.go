@@ -0,0 +1,130 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewMessage(t *testing.T) {
+	msg, err := NewMessage("orders", map[string]interface{}{"n": 1},
+		WithID("msg-1"),
+		WithTTL(1500*time.Millisecond),
+		WithHeaders(map[string]string{"source": "billing"}),
+	)
+	if err != nil {
+		t.Fatalf("NewMessage failed: %v", err)
+	}
+	if msg.Topic != "orders" || msg.Message.ID != "msg-1" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+	if msg.Message.TTLMs != 1500 {
+		t.Errorf("Expected ttl_ms 1500, got %d", msg.Message.TTLMs)
+	}
+	if msg.Message.Headers["source"] != "billing" {
+		t.Errorf("Expected headers to be kept, got %v", msg.Message.Headers)
+	}
+
+	// The hub stamps the receive time with its own clock
+	clock := NewManualClock(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	hub := NewHubWithOptions(HubOptions{Clock: clock})
+	hub.CreateTopic("orders")
+	if _, err := hub.admitPublish(msg); err != nil || !msg.Timestamp.Equal(clock.Now()) {
+		t.Errorf("Expected the message stamped with the hub's clock, got %v (%v)", msg.Timestamp, err)
+	}
+}
+
+func TestNewMessageGeneratesID(t *testing.T) {
+	msg, err := NewMessage("orders", "x", WithGeneratedID())
+	if err != nil {
+		t.Fatalf("NewMessage failed: %v", err)
+	}
+	if len(msg.Message.ID) != 26 {
+		t.Errorf("Expected a generated ULID, got %q", msg.Message.ID)
+	}
+
+	// A supplied ID is kept
+	msg, _ = NewMessage("orders", "x", WithID("mine"), WithGeneratedID())
+	if msg.Message.ID != "mine" {
+		t.Errorf("Expected supplied ID to be kept, got %q", msg.Message.ID)
+	}
+}
+
+func TestNewMessageValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		topic string
+		opts  []MessageOption
+		field string
+	}{
+		{"missing topic", "", []MessageOption{WithID("a")}, "topic"},
+		{"missing id", "orders", nil, "id"},
+		{"long id", "orders", []MessageOption{WithID(strings.Repeat("a", MaxMessageIDLength+1))}, "id"},
+		{"control character in id", "orders", []MessageOption{WithID("a\nb")}, "id"},
+		{"negative ttl", "orders", []MessageOption{WithID("a"), WithTTL(-time.Second)}, "ttl_ms"},
+		{"empty header key", "orders", []MessageOption{WithID("a"), WithHeaders(map[string]string{"": "x"})}, "headers"},
+		{"reserved header", "orders", []MessageOption{WithID("a"), WithHeaders(map[string]string{"_meta": "x"})}, "headers"},
+		{"long header value", "orders", []MessageOption{WithID("a"), WithHeaders(map[string]string{"k": strings.Repeat("v", MaxHeaderValueLength+1)})}, "headers"},
+		{"long header key", "orders", []MessageOption{WithID("a"), WithHeaders(map[string]string{strings.Repeat("k", MaxHeaderKeyLength+1): "v"})}, "headers"},
+		{"unknown content type", "orders", []MessageOption{WithID("a"), WithContentType("image/png")}, "content_type"},
+		{"malformed content type", "orders", []MessageOption{WithID("a"), WithContentType("text/")}, "content_type"},
+		{"binary payload not base64", "orders", []MessageOption{WithID("a"), WithContentType(ContentTypeBinary)}, "payload"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewMessage(tt.topic, "x", tt.opts...)
+		var invalid *InvalidMessageError
+		if !errors.As(err, &invalid) {
+			t.Errorf("%s: expected InvalidMessageError, got %v", tt.name, err)
+			continue
+		}
+		if invalid.Field != tt.field {
+			t.Errorf("%s: expected field %q, got %q", tt.name, tt.field, invalid.Field)
+		}
+		if !errors.Is(err, ErrInvalidMessage) {
+			t.Errorf("%s: expected error to match ErrInvalidMessage", tt.name)
+		}
+	}
+
+	headers := make(map[string]string)
+	for i := 0; i <= MaxMessageHeaders; i++ {
+		headers[strings.Repeat("k", i+1)] = "v"
+	}
+	if _, err := NewMessage("orders", "x", WithID("a"), WithHeaders(headers)); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected too many headers to be rejected, got %v", err)
+	}
+}
+
+func TestNewMessageContentType(t *testing.T) {
+	msg, err := NewMessage("orders", map[string]interface{}{"n": 1}, WithID("a"))
+	if err != nil || msg.Message.ContentType != ContentTypeJSON {
+		t.Errorf("Expected content type to default to JSON, got %+v, %v", msg, err)
+	}
+
+	msg, err = NewMessage("orders", "hello", WithID("a"), WithContentType("Text/Plain; charset=UTF-8"))
+	if err != nil || msg.Message.ContentType != "text/plain; charset=UTF-8" {
+		t.Errorf("Expected normalized text/plain content type, got %+v, %v", msg, err)
+	}
+
+	msg, err = NewMessage("orders", "aGVsbG8=", WithID("a"), WithContentType(ContentTypeBinary))
+	if err != nil || msg.Message.ContentType != ContentTypeBinary {
+		t.Errorf("Expected base64 payload to be accepted, got %+v, %v", msg, err)
+	}
+
+	if _, err := NewMessage("orders", 42, WithID("a"), WithContentType(ContentTypeText)); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected non-string text/plain payload to be rejected, got %v", err)
+	}
+}
+
+func TestNewMessageSizeLimit(t *testing.T) {
+	_, err := NewMessage("orders", strings.Repeat("x", 100), WithID("a"), WithMaxSize(64))
+	var tooLarge *MessageTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 64 {
+		t.Fatalf("Expected MessageTooLargeError with limit 64, got %v", err)
+	}
+
+	if _, err := NewMessageFromData("orders", nil); !errors.Is(err, ErrInvalidMessage) {
+		t.Errorf("Expected nil data to be rejected, got %v", err)
+	}
+}
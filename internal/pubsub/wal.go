@@ -0,0 +1,377 @@
+package pubsub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// walFileName is the write-ahead log's file name in the data directory
+const walFileName = "topics.wal"
+
+// walFormat is the version of the log format this broker writes, recorded
+// in the log's first record. Logs from before the format was versioned have
+// no format record and are version 1.
+const walFormat = 2
+
+// walMigrations upgrade each record of a log written in an older format,
+// by the version they upgrade from, to the next version. Logs are migrated
+// as they are replayed and rewritten in the current format by the
+// compaction that follows recovery.
+var walMigrations = map[int]func(record *walRecord){
+	// Version 2 added the format record; other records are unchanged
+	1: func(*walRecord) {},
+}
+
+// walOp is the kind of change a WAL record describes
+type walOp string
+
+const (
+	walFormatOp    walOp = "format"
+	walSaveTopic   walOp = "topic"
+	walAppend      walOp = "message"
+	walDeleteTopic walOp = "delete"
+)
+
+// walRecord is one line of the write-ahead log
+type walRecord struct {
+	Op      walOp          `json:"op"`
+	Version int            `json:"version,omitempty"`
+	Topic   *TopicSnapshot `json:"topic,omitempty"`
+	Name    string         `json:"name,omitempty"`
+	Message *PubSubMessage `json:"message,omitempty"`
+}
+
+// WALFormatError refuses a write-ahead log in a format this broker can't
+// read, such as one written by a newer broker, rather than misreading it
+type WALFormatError struct {
+	Path    string
+	Version int
+}
+
+func (e *WALFormatError) Error() string {
+	if e.Version > walFormat {
+		return fmt.Sprintf("%s is in log format %d, newer than format %d this broker reads; run a broker that reads it, or move the log aside to start empty", e.Path, e.Version, walFormat)
+	}
+	return fmt.Sprintf("%s is in unknown log format %d", e.Path, e.Version)
+}
+
+// FileStorage is a Storage backed by an append-only log of JSON records in
+// a data directory. Records reach the operating system as they are
+// written, so they survive a crash of the broker; they are synced to disk
+// on compaction and close.
+type FileStorage struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	// closed is set by Close; file is also nil while the log couldn't be
+	// reopened after a compaction
+	closed bool
+}
+
+var _ Storage = (*FileStorage)(nil)
+
+// OpenFileStorage opens the write-ahead log in dir, creating the directory
+// and log as needed
+func OpenFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+
+	s := &FileStorage{path: filepath.Join(dir, walFileName)}
+	if err := s.openLog(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// openLog opens the log for appending. Caller must hold s.mu or own s.
+func (s *FileStorage) openLog() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open write-ahead log: %w", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	return nil
+}
+
+// Load replays the log into a snapshot. A record that fails to decode ends
+// the replay, since it can only be a write torn by a crash. A log in an
+// older format is migrated, after copying it aside as topics.wal.v<format>
+// so a failed upgrade can be rolled back; one in a newer or unknown format
+// is refused with a *WALFormatError.
+func (s *FileStorage) Load() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("open write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	snapshot, version, err := replayWAL(file)
+	var formatErr *WALFormatError
+	if errors.As(err, &formatErr) {
+		formatErr.Path = s.path
+	}
+	if err != nil || version == walFormat {
+		return snapshot, err
+	}
+
+	backup := fmt.Sprintf("%s.v%d", s.path, version)
+	if err := copyFile(s.path, backup); err != nil {
+		return nil, fmt.Errorf("back up write-ahead log before migrating it: %w", err)
+	}
+	slog.Info("Migrating write-ahead log", "from", version, "to", walFormat, "backup", backup)
+	return snapshot, nil
+}
+
+// copyFile copies src to a new file dst, synced to disk. An existing dst,
+// from an earlier attempt, is left as it is.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// replayWAL rebuilds topics and their retained messages from log records,
+// migrating records of older formats. It returns the log's format, the
+// current one for an empty log.
+func replayWAL(r io.Reader) (*Snapshot, int, error) {
+	topics := make(map[string]*TopicSnapshot)
+
+	version := 0
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for records := 0; ; records++ {
+		var record walRecord
+		if err := decoder.Decode(&record); err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("Ignoring write-ahead log after a torn record", "records", records, "error", err)
+			}
+			break
+		}
+
+		if version == 0 {
+			version = 1
+			if record.Op == walFormatOp {
+				version = record.Version
+			}
+			if version < 1 || version > walFormat {
+				return nil, version, &WALFormatError{Version: version}
+			}
+		}
+		for from := version; from < walFormat; from++ {
+			walMigrations[from](&record)
+		}
+
+		switch record.Op {
+		case walSaveTopic:
+			if record.Topic == nil {
+				continue
+			}
+			ts := *record.Topic
+			if existing, exists := topics[ts.Name]; exists {
+				ts.Messages = existing.Messages
+				ts.Sequence = max(ts.Sequence, existing.Sequence)
+			}
+			ts.Messages = append([]*PubSubMessage(nil), ts.Messages...)
+			topics[ts.Name] = &ts
+
+		case walAppend:
+			if record.Message == nil {
+				continue
+			}
+			ts, exists := topics[record.Message.Topic]
+			if !exists {
+				continue
+			}
+			ts.Messages = append(ts.Messages, record.Message)
+			// The log doesn't know the hub's ring buffer size, so topics
+			// without a policy keep the most any topic may; the restore
+			// trims them to fit
+			if capacity := ts.Retention.capacity(maxRetainedMessages); len(ts.Messages) > capacity {
+				ts.Messages = ts.Messages[len(ts.Messages)-capacity:]
+			}
+			ts.Sequence = max(ts.Sequence, record.Message.Sequence)
+			ts.MessageCount++
+
+		case walDeleteTopic:
+			delete(topics, record.Name)
+		}
+	}
+
+	// A replayed log is no point-in-time copy, so it has no TakenAt
+	snapshot := &Snapshot{Topics: make([]TopicSnapshot, 0, len(topics))}
+	for _, ts := range topics {
+		snapshot.Topics = append(snapshot.Topics, *ts)
+	}
+	sort.Slice(snapshot.Topics, func(i, j int) bool {
+		return snapshot.Topics[i].Name < snapshot.Topics[j].Name
+	})
+	if version == 0 {
+		version = walFormat
+	}
+	return snapshot, version, nil
+}
+
+// SaveTopic records a topic's metadata
+func (s *FileStorage) SaveTopic(topic TopicSnapshot) error {
+	topic.Messages = nil
+	return s.append(walRecord{Op: walSaveTopic, Topic: &topic})
+}
+
+// AppendMessage records a retained message
+func (s *FileStorage) AppendMessage(message *PubSubMessage) error {
+	return s.append(walRecord{Op: walAppend, Message: message})
+}
+
+// DeleteTopic records a topic's deletion
+func (s *FileStorage) DeleteTopic(name string) error {
+	return s.append(walRecord{Op: walDeleteTopic, Name: name})
+}
+
+// append writes a record and hands it to the operating system
+func (s *FileStorage) append(record walRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	s.writer.Write(data)
+	s.writer.WriteByte('\n')
+	return s.writer.Flush()
+}
+
+// Compact writes the snapshot to a new log and atomically replaces the old
+// one with it. It also recovers a log whose writes failed, or that couldn't
+// be reopened, since the new log supersedes whatever they lost.
+func (s *FileStorage) Compact(snapshot *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return os.ErrClosed
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := writeWAL(tmpPath, snapshot); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if s.file != nil {
+		s.writer.Flush()
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		if reopenErr := s.openLog(); reopenErr != nil {
+			return reopenErr
+		}
+		return fmt.Errorf("replace write-ahead log: %w", err)
+	}
+	syncDir(filepath.Dir(s.path))
+	return s.openLog()
+}
+
+// writeWAL writes a snapshot as log records to path and syncs it
+func writeWAL(path string, snapshot *Snapshot) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create write-ahead log: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	if err := encoder.Encode(walRecord{Op: walFormatOp, Version: walFormat}); err != nil {
+		return err
+	}
+	for i := range snapshot.Topics {
+		ts := snapshot.Topics[i]
+		messages := ts.Messages
+		ts.Messages = nil
+		if err := encoder.Encode(walRecord{Op: walSaveTopic, Topic: &ts}); err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := encoder.Encode(walRecord{Op: walAppend, Message: message}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// syncDir makes a rename in dir durable. Failures are ignored, as some
+// platforms can't sync directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Close flushes and syncs the log and closes it
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	file := s.file
+	s.file = nil
+
+	if err := s.writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
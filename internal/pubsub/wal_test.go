@@ -0,0 +1,219 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestStorage(t *testing.T, dir string) *FileStorage {
+	t.Helper()
+	storage, err := OpenFileStorage(dir)
+	if err != nil {
+		t.Fatalf("OpenFileStorage failed: %v", err)
+	}
+	return storage
+}
+
+func TestFileStorageRecoversAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	source := NewHub()
+	if _, err := source.Recover(openTestStorage(t, dir)); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	source.CreateTopicWithOptions("orders", TopicOptions{Weight: 2, Owner: "acme"})
+	source.CreateTopic("scratch")
+	if _, err := source.SetTopicSchema("orders", json.RawMessage(`{"type": "object"}`)); err != nil {
+		t.Fatalf("SetTopicSchema failed: %v", err)
+	}
+	retainMessages(source, "orders", 3)
+	if err := source.DeleteTopic("scratch"); err != nil {
+		t.Fatalf("DeleteTopic failed: %v", err)
+	}
+
+	// Recover from the log as written, without a clean close
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Topics != 1 || result.Messages != 3 {
+		t.Fatalf("Expected 1 topic and 3 messages recovered, got %+v", result)
+	}
+
+	stats, err := target.GetTopicStats("orders")
+	if err != nil {
+		t.Fatalf("GetTopicStats failed: %v", err)
+	}
+	if stats.Sequence != 3 || stats.BufferOccupancy != 3 || stats.Weight != 2 || stats.Owner != "acme" {
+		t.Errorf("Expected sequence 3, 3 retained, weight 2 and owner acme, got %+v", stats)
+	}
+	if schema, err := target.GetTopicSchema("orders", 0); err != nil || schema.Version != 1 {
+		t.Errorf("Expected schema version 1, got %v, %v", schema, err)
+	}
+	if _, err := target.GetTopicStats("scratch"); err != ErrTopicNotFound {
+		t.Errorf("Expected deleted topic to stay deleted, got %v", err)
+	}
+
+	recent := target.GetRecentMessages("orders", 0)
+	if len(recent) != 3 || recent[2].Message.ID != "msg-3" {
+		t.Errorf("Expected msg-1..msg-3 retained, got %d messages", len(recent))
+	}
+}
+
+func TestFileStorageKeepsMessagesPublishedWithoutSubscribers(t *testing.T) {
+	dir := t.TempDir()
+
+	source := NewHub()
+	if _, err := source.Recover(openTestStorage(t, dir)); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	source.CreateTopic("orders")
+	source.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: "msg-1"}})
+	if stats, _ := source.GetTopicStats("orders"); stats.SubscriberCount != 0 {
+		t.Fatalf("Expected no subscribers, got %d", stats.SubscriberCount)
+	}
+
+	// Recover from the log as written, without a clean close
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Messages != 1 {
+		t.Fatalf("Expected 1 message recovered, got %+v", result)
+	}
+
+	recent := target.GetRecentMessages("orders", 0)
+	if len(recent) != 1 || recent[0].Message.ID != "msg-1" || recent[0].Sequence != 1 {
+		t.Errorf("Expected msg-1 retained at sequence 1, got %d messages", len(recent))
+	}
+}
+
+func TestFileStorageSurvivesCleanRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	source := NewHub()
+	if _, err := source.Recover(openTestStorage(t, dir)); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	source.CreateTopic("orders")
+	retainMessages(source, "orders", replayBufferSize+5)
+	if err := source.CloseStorage(); err != nil {
+		t.Fatalf("CloseStorage failed: %v", err)
+	}
+
+	// Changes after close aren't persisted
+	source.CreateTopic("late")
+
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Topics != 1 || result.Messages != replayBufferSize {
+		t.Fatalf("Expected 1 topic and %d messages recovered, got %+v", replayBufferSize, result)
+	}
+
+	stats, _ := target.GetTopicStats("orders")
+	if stats.Sequence != int64(replayBufferSize+5) {
+		t.Errorf("Expected sequence %d, got %d", replayBufferSize+5, stats.Sequence)
+	}
+}
+
+func TestFileStorageIgnoresTornRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	source := NewHub()
+	if _, err := source.Recover(openTestStorage(t, dir)); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	source.CreateTopic("orders")
+	retainMessages(source, "orders", 2)
+
+	file, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	file.WriteString(`{"op":"message","message":{"topic":"ord`)
+	file.Close()
+
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Topics != 1 || result.Messages != 2 {
+		t.Errorf("Expected the records before the torn write recovered, got %+v", result)
+	}
+}
+
+func TestFileStorageMigratesUnversionedLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, walFileName)
+
+	source := NewHub()
+	if _, err := source.Recover(openTestStorage(t, dir)); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	source.CreateTopic("orders")
+	retainMessages(source, "orders", 2)
+	source.CloseStorage()
+
+	// Logs from before the format was versioned have no format record
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	header, records, _ := strings.Cut(string(data), "\n")
+	if !strings.Contains(header, `"op":"format"`) {
+		t.Fatalf("Expected the log to start with its format, got %s", header)
+	}
+	os.WriteFile(path, []byte(records), 0o644)
+
+	target := NewHub()
+	result, err := target.Recover(openTestStorage(t, dir))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	defer target.CloseStorage()
+	if result.Topics != 1 || result.Messages != 2 {
+		t.Errorf("Expected the unversioned log recovered, got %+v", result)
+	}
+
+	if backup, err := os.ReadFile(path + ".v1"); err != nil || string(backup) != records {
+		t.Errorf("Expected the unversioned log backed up, got %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(data), header+"\n") {
+		t.Errorf("Expected the log rewritten in the current format, got %s", data)
+	}
+}
+
+func TestFileStorageRefusesNewerLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, walFileName)
+	log := `{"op":"format","version":99}` + "\n" + `{"op":"topic","topic":{"name":"orders"}}` + "\n"
+	os.WriteFile(path, []byte(log), 0o644)
+
+	hub := NewHub()
+	_, err := hub.Recover(openTestStorage(t, dir))
+	var formatErr *WALFormatError
+	if !errors.As(err, &formatErr) || formatErr.Version != 99 || formatErr.Path != path {
+		t.Fatalf("Expected a WALFormatError for format 99, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != log {
+		t.Errorf("Expected the newer log left untouched, got %s", data)
+	}
+	if len(hub.GetTopics()) != 0 {
+		t.Error("Expected nothing recovered from the newer log")
+	}
+}
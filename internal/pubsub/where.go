@@ -0,0 +1,547 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxWhereLength bounds a subscription's where expression in bytes
+	MaxWhereLength = 1024
+	// maxWhereDepth bounds how deeply a where expression nests parentheses
+	// and negations
+	maxWhereDepth = 32
+)
+
+// whereExpr is a compiled where expression: a condition on an event's
+// payload, headers, ID and key that a subscription's events must meet.
+//
+//	payload.status == "shipped" && (payload.total >= 100 || headers.vip == "true")
+//
+// Paths start at payload, headers, id or key and step into objects with
+// .name or ["name"] and into arrays with [index]. Values are double- or
+// single-quoted strings, numbers, true, false and null. Comparisons are
+// ==, !=, <, <=, > and >=, combined with &&, || and ! and grouped with
+// parentheses; a path on its own tests that the field is present and
+// neither null nor false. A comparison involving a missing field, or
+// ordering values of different types, is false.
+type whereExpr struct {
+	source string
+	root   whereNode
+}
+
+// whereNode is a node of a compiled where expression
+type whereNode interface {
+	eval(data *MessageData) bool
+}
+
+// parseWhere compiles a where expression. An empty expression compiles to
+// nil, which matches every event.
+func parseWhere(source string) (*whereExpr, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil
+	}
+	if len(source) > MaxWhereLength {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrInvalidWhere, MaxWhereLength)
+	}
+	tokens, err := lexWhere(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEnd {
+		return nil, tok.errorf("unexpected %s", tok)
+	}
+	return &whereExpr{source: source, root: root}, nil
+}
+
+// match reports whether an event meets the expression; a nil expression
+// matches every event
+func (w *whereExpr) match(message *PubSubMessage) bool {
+	if w == nil {
+		return true
+	}
+	if message.Message == nil {
+		return false
+	}
+	return w.root.eval(message.Message)
+}
+
+type whereAnd struct{ left, right whereNode }
+
+func (n whereAnd) eval(data *MessageData) bool { return n.left.eval(data) && n.right.eval(data) }
+
+type whereOr struct{ left, right whereNode }
+
+func (n whereOr) eval(data *MessageData) bool { return n.left.eval(data) || n.right.eval(data) }
+
+type whereNot struct{ operand whereNode }
+
+func (n whereNot) eval(data *MessageData) bool { return !n.operand.eval(data) }
+
+// wherePresent tests that a path is present and neither null nor false
+type wherePresent struct{ path *wherePath }
+
+func (n wherePresent) eval(data *MessageData) bool {
+	value, ok := n.path.value(data)
+	return ok && value != nil && value != false
+}
+
+// whereCompare compares two operands
+type whereCompare struct {
+	op          string
+	left, right whereOperand
+}
+
+func (n whereCompare) eval(data *MessageData) bool {
+	left, ok := n.left.value(data)
+	if !ok {
+		return false
+	}
+	right, ok := n.right.value(data)
+	if !ok {
+		return false
+	}
+	return compareWhere(n.op, left, right)
+}
+
+// compareWhere applies a comparison operator to two JSON values. Numbers
+// and strings are ordered; any two values may be tested for equality.
+func compareWhere(op string, left, right interface{}) bool {
+	switch op {
+	case "==":
+		return reflect.DeepEqual(left, right)
+	case "!=":
+		return !reflect.DeepEqual(left, right)
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(l, r)
+	default:
+		return false
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// whereOperand is a literal or a path
+type whereOperand interface {
+	value(data *MessageData) (interface{}, bool)
+}
+
+type whereLiteral struct{ v interface{} }
+
+func (l whereLiteral) value(*MessageData) (interface{}, bool) { return l.v, true }
+
+// wherePath is a path into an event, from one of its roots
+type wherePath struct {
+	root  string
+	steps []whereStep
+}
+
+// whereStep is one step of a path: an object member, or an array element
+// when index is set
+type whereStep struct {
+	name  string
+	index *int
+}
+
+// value looks the path up in an event, reporting whether it's present
+func (p *wherePath) value(data *MessageData) (interface{}, bool) {
+	var current interface{}
+	switch p.root {
+	case "id":
+		return data.ID, true
+	case "key":
+		return data.Key, true
+	case "headers":
+		headers := make(map[string]interface{}, len(data.Headers))
+		for name, value := range data.Headers {
+			headers[name] = value
+		}
+		current = headers
+	default:
+		current = jsonValue(data.Payload)
+	}
+
+	for _, step := range p.steps {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if step.index != nil {
+				return nil, false
+			}
+			next, ok := node[step.name]
+			if !ok {
+				return nil, false
+			}
+			current = jsonValue(next)
+		case []interface{}:
+			if step.index == nil || *step.index >= len(node) {
+				return nil, false
+			}
+			current = jsonValue(node[*step.index])
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonValue normalizes a payload value to the types encoding/json decodes
+// into, so payloads built from Go values match the same way as decoded ones
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case nil, bool, string, float64, map[string]interface{}, []interface{}:
+		return value
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	json.Unmarshal(encoded, &decoded)
+	return decoded
+}
+
+// Where expression tokens
+type whereTokenKind int
+
+const (
+	tokEnd whereTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokPunct
+)
+
+type whereToken struct {
+	kind whereTokenKind
+	text string
+	// value is a string or number literal's value
+	value interface{}
+	pos   int
+}
+
+func (t whereToken) String() string {
+	if t.kind == tokEnd {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+func (t whereToken) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: at offset %d: %s", ErrInvalidWhere, t.pos, fmt.Sprintf(format, args...))
+}
+
+// lexWhere splits a where expression into tokens
+func lexWhere(source string) ([]whereToken, error) {
+	var tokens []whereToken
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isWhereIdentStart(c):
+			start := i
+			for i < len(source) && isWhereIdentPart(source[i]) {
+				i++
+			}
+			tokens = append(tokens, whereToken{kind: tokIdent, text: source[start:i], pos: start})
+		case c == '"' || c == '\'':
+			tok, end, err := lexWhereString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(source) && strings.IndexByte("0123456789.eE+-", source[i]) >= 0 {
+				// A sign only follows an exponent
+				if (source[i] == '+' || source[i] == '-') && source[i-1] != 'e' && source[i-1] != 'E' {
+					break
+				}
+				i++
+			}
+			number, err := strconv.ParseFloat(source[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: at offset %d: invalid number %q", ErrInvalidWhere, start, source[start:i])
+			}
+			tokens = append(tokens, whereToken{kind: tokNumber, text: source[start:i], value: number, pos: start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			switch {
+			case op != "":
+				tokens = append(tokens, whereToken{kind: tokOp, text: op, pos: i})
+				i += len(op)
+			case strings.IndexByte("().[]", c) >= 0:
+				tokens = append(tokens, whereToken{kind: tokPunct, text: string(c), pos: i})
+				i++
+			default:
+				return nil, fmt.Errorf("%w: at offset %d: unexpected %q", ErrInvalidWhere, i, string(c))
+			}
+		}
+	}
+	return append(tokens, whereToken{kind: tokEnd, pos: len(source)}), nil
+}
+
+// lexWhereString reads a quoted string starting at start, returning it and
+// the offset after its closing quote. Double-quoted strings follow JSON;
+// in single-quoted ones a backslash escapes the next character.
+func lexWhereString(source string, start int) (whereToken, int, error) {
+	quote := source[start]
+	var b strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == quote:
+			text := source[start : i+1]
+			value := b.String()
+			if quote == '"' {
+				if err := json.Unmarshal([]byte(text), &value); err != nil {
+					return whereToken{}, 0, fmt.Errorf("%w: at offset %d: invalid string %s", ErrInvalidWhere, start, text)
+				}
+			}
+			return whereToken{kind: tokString, text: text, value: value, pos: start}, i + 1, nil
+		case c == '\\' && i+1 < len(source):
+			if quote == '"' {
+				b.WriteByte(c)
+			}
+			i++
+			b.WriteByte(source[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return whereToken{}, 0, fmt.Errorf("%w: at offset %d: unterminated string", ErrInvalidWhere, start)
+}
+
+func isWhereIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isWhereIdentPart(c byte) bool {
+	return isWhereIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// whereParser parses where expression tokens by recursive descent
+type whereParser struct {
+	tokens []whereToken
+	next   int
+	depth  int
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.tokens[p.next]
+}
+
+func (p *whereParser) take() whereToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokEnd {
+		p.next++
+	}
+	return tok
+}
+
+// accept takes the next token if it has the given text
+func (p *whereParser) accept(text string) bool {
+	if tok := p.peek(); (tok.kind == tokOp || tok.kind == tokPunct) && tok.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.accept("||") {
+		var right whereNode
+		if right, err = p.parseAnd(); err == nil {
+			left = whereOr{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.accept("&&") {
+		var right whereNode
+		if right, err = p.parseUnary(); err == nil {
+			left = whereAnd{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseUnary() (whereNode, error) {
+	tok := p.peek()
+	if tok.text != "!" && tok.text != "(" {
+		return p.parseComparison()
+	}
+	if p.depth++; p.depth > maxWhereDepth {
+		return nil, tok.errorf("nested more than %d deep", maxWhereDepth)
+	}
+	defer func() { p.depth-- }()
+
+	p.take()
+	if tok.text == "!" {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return whereNot{operand}, nil
+	}
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(")") {
+		return nil, p.peek().errorf("expected \")\", got %s", p.peek())
+	}
+	return inner, nil
+}
+
+func (p *whereParser) parseComparison() (whereNode, error) {
+	start := p.peek()
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	switch tok.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		if tok.kind != tokOp {
+			break
+		}
+		p.take()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return whereCompare{op: tok.text, left: left, right: right}, nil
+	}
+	path, ok := left.(*wherePath)
+	if !ok {
+		return nil, start.errorf("expected a comparison after %s", start)
+	}
+	return wherePresent{path}, nil
+}
+
+func (p *whereParser) parseOperand() (whereOperand, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokString, tokNumber:
+		return whereLiteral{tok.value}, nil
+	case tokIdent:
+	default:
+		return nil, tok.errorf("expected a value or path, got %s", tok)
+	}
+
+	switch tok.text {
+	case "true":
+		return whereLiteral{true}, nil
+	case "false":
+		return whereLiteral{false}, nil
+	case "null":
+		return whereLiteral{nil}, nil
+	case "id", "key":
+		return &wherePath{root: tok.text}, nil
+	case "payload", "headers":
+	default:
+		return nil, tok.errorf("unknown path %s; paths start with payload, headers, id or key", tok)
+	}
+
+	path := &wherePath{root: tok.text}
+	for {
+		switch {
+		case p.accept("."):
+			name := p.take()
+			if name.kind != tokIdent {
+				return nil, name.errorf("expected a field name after \".\", got %s", name)
+			}
+			path.steps = append(path.steps, whereStep{name: name.text})
+		case p.accept("["):
+			step, err := p.parseIndex()
+			if err != nil {
+				return nil, err
+			}
+			path.steps = append(path.steps, step)
+		default:
+			if path.root == "headers" && len(path.steps) > 1 {
+				return nil, tok.errorf("headers are strings and have no fields")
+			}
+			return path, nil
+		}
+	}
+}
+
+// parseIndex parses the inside of a [...] step: an array index or a quoted
+// field name
+func (p *whereParser) parseIndex() (whereStep, error) {
+	tok := p.take()
+	var step whereStep
+	switch {
+	case tok.kind == tokString:
+		step.name = tok.value.(string)
+	case tok.kind == tokNumber:
+		index := int(tok.value.(float64))
+		if index < 0 || float64(index) != tok.value.(float64) {
+			return step, tok.errorf("array index must be a non-negative integer, got %s", tok)
+		}
+		step.index = &index
+	default:
+		return step, tok.errorf("expected an index or quoted field name, got %s", tok)
+	}
+	if !p.accept("]") {
+		return step, p.peek().errorf("expected \"]\", got %s", p.peek())
+	}
+	return step, nil
+}
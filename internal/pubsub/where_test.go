@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWhereMatch(t *testing.T) {
+	message := &PubSubMessage{Message: &MessageData{
+		ID:      "m1",
+		Key:     "customer-42",
+		Headers: map[string]string{"region": "eu", "x-trace": "abc"},
+		Payload: map[string]interface{}{
+			"status": "shipped",
+			"total":  120,
+			"rush":   true,
+			"note":   nil,
+			"items":  []interface{}{map[string]interface{}{"sku": "A-1"}},
+			"it's":   "quoted",
+		},
+	}}
+
+	tests := []struct {
+		where string
+		want  bool
+	}{
+		{`payload.status == "shipped"`, true},
+		{`payload.status == 'pending'`, false},
+		{`payload.status != 'pending'`, true},
+		{`payload.total > 100 && payload.total <= 120`, true},
+		{`payload.total >= 1.2e2`, true},
+		{`payload.total < -1`, false},
+		{`payload.status > "r"`, true},
+		{`payload.rush`, true},
+		{`payload.note`, false},
+		{`payload.missing`, false},
+		{`!payload.missing`, true},
+		{`payload.missing != 1`, false},
+		{`!(payload.missing == 1)`, true},
+		{`payload.total > "100"`, false},
+		{`payload.note == null`, true},
+		{`payload.items[0].sku == "A-1"`, true},
+		{`payload.items[1].sku == "A-1"`, false},
+		{`payload["it's"] == 'quoted'`, true},
+		{`headers.region == "eu" || headers.region == "us"`, true},
+		{`headers["x-trace"] == "abc"`, true},
+		{`id == "m1" && key == "customer-42"`, true},
+		{`payload.status == "pending" || payload.rush && payload.total > 100`, true},
+		{`(payload.status == "pending" || payload.rush) && payload.total > 500`, false},
+	}
+	for _, tt := range tests {
+		where, err := parseWhere(tt.where)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", tt.where, err)
+			continue
+		}
+		if got := where.match(message); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.where, tt.want, got)
+		}
+	}
+
+	if where, _ := parseWhere(""); where != nil || !where.match(message) {
+		t.Error("Expected an empty expression to match every event")
+	}
+}
+
+func TestWhereParseErrors(t *testing.T) {
+	tests := []struct {
+		where   string
+		message string
+	}{
+		{`status == "shipped"`, "unknown path"},
+		{`payload.status ==`, "expected a value or path"},
+		{`payload.status = "shipped"`, `unexpected "="`},
+		{`"shipped"`, "expected a comparison"},
+		{`(payload.rush`, `expected ")"`},
+		{`payload.status == "shipped`, "unterminated string"},
+		{`payload.items[-1]`, "non-negative integer"},
+		{`headers.region.code == "x"`, "no fields"},
+		{`payload.rush payload.total`, "unexpected"},
+		{strings.Repeat("!", maxWhereDepth+1) + "payload.rush", "nested"},
+		{strings.Repeat("x", MaxWhereLength+1), "exceeds"},
+	}
+	for _, tt := range tests {
+		_, err := parseWhere(tt.where)
+		if !errors.Is(err, ErrInvalidWhere) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%.40s: expected an error containing %q, got %v", tt.where, tt.message, err)
+		}
+	}
+}
+
+func TestSubscribeWhere(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+
+	hub.CreateTopic("orders")
+	retainMessages(hub, "orders", 0)
+	publish := func(id, status string) {
+		hub.publishMessage(&PubSubMessage{Topic: "orders", Message: &MessageData{ID: id, Payload: map[string]interface{}{"status": status}}})
+	}
+	publish("m1", "shipped")
+	publish("m2", "pending")
+
+	client := newTestClient(hub)
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", Where: `payload.status = 1`})
+	if frames := drainFrames(t, client); len(frames) != 1 || frames[0].Error == nil || frames[0].Error.Code != CodeBadRequest {
+		t.Fatalf("Expected BAD_REQUEST for an invalid expression, got %+v", frames)
+	}
+	client.handleMessage(&ClientMessage{Type: SubscribeMessage, Topic: "orders", ClientID: "c1", Where: `payload.status == "shipped"`, LastN: 10})
+	if frames := drainFrames(t, client); len(frames) == 0 || frames[0].Subscription == nil || frames[0].Subscription.Replaying != 1 {
+		t.Fatalf("Expected an ack replaying the one shipped order, got %+v", frames)
+	}
+
+	if _, err := hub.OpenStream("sse-1", "orders", StreamOptions{Where: `payload.status ==`}, DefaultClientOptions()); !errors.Is(err, ErrInvalidWhere) {
+		t.Fatalf("Expected ErrInvalidWhere opening a stream, got %v", err)
+	}
+	stream, err := hub.OpenStream("sse-2", "orders", StreamOptions{LastN: 10, Where: `payload.status == "shipped"`}, DefaultClientOptions())
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	publish("m3", "pending")
+	publish("m4", "shipped")
+	// The welcome frame, then m1 replayed and m4 live, in either order
+	var delivered []string
+	for _, frame := range readStream(t, stream, 3)[1:] {
+		var event ServerMessage
+		json.Unmarshal(frame.Data, &event)
+		delivered = append(delivered, event.Message.ID)
+	}
+	sort.Strings(delivered)
+	if strings.Join(delivered, ",") != "m1,m4" {
+		t.Errorf("Expected m1 replayed and m4 delivered, got %v", delivered)
+	}
+}
@@ -0,0 +1,220 @@
+package selfcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"plivo/internal/pubsub"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Options configures a self-check run
+type Options struct {
+	// BaseURL of the broker, e.g. http://localhost:8080
+	BaseURL string
+	// APIKey sent as X-API-Key on REST and WebSocket requests
+	APIKey string
+	// Timeout for each step of the sequence
+	Timeout time.Duration
+}
+
+// checker holds state for a single self-check run
+type checker struct {
+	opts  Options
+	topic string
+	http  *http.Client
+}
+
+// Run executes the publish/subscribe/replay/ack smoke sequence against a
+// broker and returns the first failure encountered
+func Run(opts Options) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	c := &checker{
+		opts:  opts,
+		topic: "selfcheck-" + uuid.New().String(),
+		http:  &http.Client{Timeout: opts.Timeout},
+	}
+
+	if err := c.createTopic(); err != nil {
+		return fmt.Errorf("create topic: %w", err)
+	}
+	defer c.deleteTopic()
+
+	subscriber, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("connect subscriber: %w", err)
+	}
+	defer subscriber.Close()
+
+	if err := c.subscribe(subscriber, "sub-1", 0); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	if err := c.expectAck(subscriber, "sub-1"); err != nil {
+		return fmt.Errorf("subscribe ack: %w", err)
+	}
+	log.Printf("selfcheck: subscribed to %s", c.topic)
+
+	publisher, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("connect publisher: %w", err)
+	}
+	defer publisher.Close()
+
+	messageID := uuid.New().String()
+	if err := publisher.WriteJSON(pubsub.ClientMessage{
+		Type:      pubsub.PublishMessage,
+		Topic:     c.topic,
+		Message:   &pubsub.MessageData{ID: messageID, Payload: "selfcheck"},
+		RequestID: "pub-1",
+	}); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	if err := c.expectAck(publisher, "pub-1"); err != nil {
+		return fmt.Errorf("publish ack: %w", err)
+	}
+	log.Printf("selfcheck: published %s", messageID)
+
+	if err := c.expectEvent(subscriber, messageID); err != nil {
+		return fmt.Errorf("live delivery: %w", err)
+	}
+	log.Printf("selfcheck: live event delivered")
+
+	replayer, err := c.dial()
+	if err != nil {
+		return fmt.Errorf("connect replay subscriber: %w", err)
+	}
+	defer replayer.Close()
+
+	if err := c.subscribe(replayer, "sub-2", 1); err != nil {
+		return fmt.Errorf("replay subscribe: %w", err)
+	}
+	if err := c.expectAck(replayer, "sub-2"); err != nil {
+		return fmt.Errorf("replay subscribe ack: %w", err)
+	}
+	if err := c.expectEvent(replayer, messageID); err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	log.Printf("selfcheck: replay delivered")
+
+	return nil
+}
+
+// createTopic creates the scratch topic used by the run
+func (c *checker) createTopic() error {
+	body, _ := json.Marshal(map[string]string{"name": c.topic})
+	req, err := http.NewRequest("POST", c.opts.BaseURL+"/topics", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deleteTopic removes the scratch topic, best effort
+func (c *checker) deleteTopic() {
+	req, err := http.NewRequest("DELETE", c.opts.BaseURL+"/topics/"+url.PathEscape(c.topic), nil)
+	if err != nil {
+		return
+	}
+	if resp, err := c.do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// do sends a REST request with authentication
+func (c *checker) do(req *http.Request) (*http.Response, error) {
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	}
+	return c.http.Do(req)
+}
+
+// dial opens a WebSocket connection to the broker
+func (c *checker) dial() (*websocket.Conn, error) {
+	wsURL := "ws" + strings.TrimPrefix(c.opts.BaseURL, "http") + "/ws"
+
+	header := http.Header{}
+	if c.opts.APIKey != "" {
+		header.Set("X-API-Key", c.opts.APIKey)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: c.opts.Timeout}
+	conn, _, err := dialer.Dial(wsURL, header)
+	return conn, err
+}
+
+// subscribe sends a subscribe frame for the scratch topic
+func (c *checker) subscribe(conn *websocket.Conn, requestID string, lastN int) error {
+	return conn.WriteJSON(pubsub.ClientMessage{
+		Type:      pubsub.SubscribeMessage,
+		Topic:     c.topic,
+		ClientID:  "selfcheck",
+		LastN:     lastN,
+		RequestID: requestID,
+	})
+}
+
+// read waits for the next server frame, skipping info frames
+func (c *checker) read(conn *websocket.Conn) (*pubsub.ServerMessage, error) {
+	conn.SetReadDeadline(time.Now().Add(c.opts.Timeout))
+
+	for {
+		var msg pubsub.ServerMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return nil, err
+		}
+		if msg.Type == pubsub.InfoMessage {
+			continue
+		}
+		if msg.Type == pubsub.ErrorMessage && msg.Error != nil {
+			return nil, fmt.Errorf("server error %s: %s", msg.Error.Code, msg.Error.Message)
+		}
+		return &msg, nil
+	}
+}
+
+// expectAck waits for an ack frame with the given request ID
+func (c *checker) expectAck(conn *websocket.Conn, requestID string) error {
+	msg, err := c.read(conn)
+	if err != nil {
+		return err
+	}
+	if msg.Type != pubsub.AckMessage || msg.RequestID != requestID {
+		return fmt.Errorf("expected ack for %s, got %s (request_id %q)", requestID, msg.Type, msg.RequestID)
+	}
+	return nil
+}
+
+// expectEvent waits for an event frame carrying the given message ID
+func (c *checker) expectEvent(conn *websocket.Conn, messageID string) error {
+	msg, err := c.read(conn)
+	if err != nil {
+		return err
+	}
+	if msg.Type != pubsub.EventMessage || msg.Message == nil || msg.Message.ID != messageID {
+		return fmt.Errorf("expected event %s, got %s", messageID, msg.Type)
+	}
+	return nil
+}
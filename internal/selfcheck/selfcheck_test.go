@@ -0,0 +1,65 @@
+package selfcheck
+
+import (
+	"net/http/httptest"
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/handlers"
+	"plivo/internal/pubsub"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestServer(cfg *config.Config) (*httptest.Server, *pubsub.Hub) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+
+	wsHandler := handlers.NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security))
+	restHandler := handlers.NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/ws", wsHandler.HandleWebSocket)
+	r.HandleFunc("/topics", restHandler.CreateTopic).Methods("POST")
+	r.HandleFunc("/topics/{topic}", restHandler.DeleteTopic).Methods("DELETE")
+
+	return httptest.NewServer(r), hub
+}
+
+func TestRunAgainstBroker(t *testing.T) {
+	server, hub := newTestServer(config.NewTestConfig())
+	defer server.Close()
+	defer hub.Shutdown()
+
+	err := Run(Options{BaseURL: server.URL, Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("Self-check failed: %v", err)
+	}
+
+	// The scratch topic should be cleaned up
+	if len(hub.GetTopics()) != 0 {
+		t.Errorf("Expected scratch topic to be deleted, found %d topics", len(hub.GetTopics()))
+	}
+}
+
+func TestRunWithAPIKey(t *testing.T) {
+	server, hub := newTestServer(config.NewTestConfigWithAPIKey("test-key"))
+	defer server.Close()
+	defer hub.Shutdown()
+
+	if err := Run(Options{BaseURL: server.URL, APIKey: "test-key", Timeout: 2 * time.Second}); err != nil {
+		t.Errorf("Self-check with correct API key failed: %v", err)
+	}
+
+	if err := Run(Options{BaseURL: server.URL, APIKey: "wrong-key", Timeout: 2 * time.Second}); err == nil {
+		t.Error("Self-check with wrong API key should fail")
+	}
+}
+
+func TestRunUnreachableBroker(t *testing.T) {
+	err := Run(Options{BaseURL: "http://127.0.0.1:1", Timeout: 500 * time.Millisecond})
+	if err == nil {
+		t.Error("Self-check against unreachable broker should fail")
+	}
+}
@@ -0,0 +1,262 @@
+// Package sink taps hub topics into destinations outside the broker, such
+// as local files for audit trails that need no consumer service, or
+// webhooks.
+package sink
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+)
+
+// FsyncPolicy decides when a file sink forces written events to disk
+type FsyncPolicy string
+
+// Fsync policies
+const (
+	// FsyncAlways syncs after every batch of events written
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs at most once per FsyncInterval
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system
+	FsyncNever FsyncPolicy = "never"
+)
+
+// FileOptions configures a file sink
+type FileOptions struct {
+	// Dir is the directory the sink's files are written to, created if
+	// missing
+	Dir string
+	// MaxBytes rotates to a new file once the current one reaches this
+	// size (0 = never)
+	MaxBytes int64
+	// RotateInterval rotates to a new file once the current one is this
+	// old (0 = never)
+	RotateInterval time.Duration
+	// Fsync is when written events are forced to disk ("" = interval)
+	Fsync FsyncPolicy
+	// FsyncInterval is how often the interval policy syncs (0 = 1s)
+	FsyncInterval time.Duration
+	// Client sets the sink's send queue and replay pacing
+	Client pubsub.ClientOptions
+}
+
+// Validate checks the rotation and fsync settings
+func (o FileOptions) Validate() error {
+	if o.Dir == "" {
+		return fmt.Errorf("file sink directory is required")
+	}
+	if o.MaxBytes < 0 || o.RotateInterval < 0 || o.FsyncInterval < 0 {
+		return fmt.Errorf("file sink rotation and fsync settings must not be negative")
+	}
+	switch o.Fsync {
+	case "", FsyncAlways, FsyncInterval, FsyncNever:
+		return nil
+	}
+	return fmt.Errorf("file sink fsync must be %s, %s or %s", FsyncAlways, FsyncInterval, FsyncNever)
+}
+
+// FileSink appends a topic's events to rotating NDJSON files, one event
+// frame per line in topic sequence order. It subscribes to the topic as a
+// stream, so like any subscriber a sink that falls a full queue behind is
+// disconnected as a slow consumer and stops.
+type FileSink struct {
+	topic  string
+	opts   FileOptions
+	stream *pubsub.Stream
+	logger *slog.Logger
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	dirty    bool
+	syncedAt time.Time
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// OpenFile starts a file sink for a topic, writing its live events from now
+// on. The sink must be closed with Close.
+func OpenFile(hub *pubsub.Hub, topic string, opts FileOptions) (*FileSink, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncInterval
+	}
+	if opts.FsyncInterval == 0 {
+		opts.FsyncInterval = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create file sink directory: %w", err)
+	}
+
+	s := &FileSink{
+		topic:  topic,
+		opts:   opts,
+		logger: slog.Default().With(logging.Topic, topic),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := s.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Start after what the topic has already published rather than replay
+	// it, so a restarted sink doesn't write retained events twice
+	var start pubsub.StreamOptions
+	if stats, err := hub.GetTopicStats(topic); err == nil {
+		start.AfterSequence = stats.Sequence
+	}
+	stream, err := hub.OpenStream("file-sink:"+topic, topic, start, opts.Client)
+	if err != nil {
+		s.file.Close()
+		return nil, err
+	}
+	s.stream = stream
+
+	go s.run()
+	return s, nil
+}
+
+// Close stops the sink once the events already queued for it are written,
+// and closes its file
+func (s *FileSink) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// run writes the stream's events until the sink is closed or the stream
+// ends, syncing and rotating on the way
+func (s *FileSink) run() {
+	defer close(s.done)
+	defer s.closeFile()
+	defer s.stream.Close()
+
+	ticker := time.NewTicker(s.tick())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stream.Ready():
+			if closed := s.drain(); closed {
+				s.logger.Warn("File sink stopped: its stream was closed")
+				return
+			}
+		case now := <-ticker.C:
+			s.maintain(now)
+		case <-s.stop:
+			s.drain()
+			return
+		}
+	}
+}
+
+// tick is how often run checks whether to sync or rotate
+func (s *FileSink) tick() time.Duration {
+	tick := s.opts.FsyncInterval
+	if s.opts.RotateInterval > 0 && s.opts.RotateInterval < tick {
+		tick = s.opts.RotateInterval
+	}
+	return tick
+}
+
+// drain writes the events waiting on the stream and reports whether the
+// stream has closed. Info and error frames are not events and are skipped.
+func (s *FileSink) drain() bool {
+	frames, closed := s.stream.Next()
+	for _, frame := range frames {
+		if frame.Sequence == 0 {
+			continue
+		}
+		if err := s.write(frame.Data); err != nil {
+			s.logger.Error("File sink lost event", "sequence", frame.Sequence, "error", err)
+		}
+	}
+	if s.opts.Fsync == FsyncAlways {
+		s.sync(time.Now())
+	}
+	return closed
+}
+
+// write appends one event as a line, rotating first when the file is full
+func (s *FileSink) write(data []byte) error {
+	now := time.Now()
+	if s.due(now) {
+		if err := s.rotate(now); err != nil {
+			return err
+		}
+	}
+	line := append(append(make([]byte, 0, len(data)+1), data...), '\n')
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	s.dirty = s.dirty || n > 0
+	return err
+}
+
+// maintain syncs and rotates on the interval policies
+func (s *FileSink) maintain(now time.Time) {
+	if s.opts.Fsync == FsyncInterval && now.Sub(s.syncedAt) >= s.opts.FsyncInterval {
+		s.sync(now)
+	}
+	if s.size > 0 && s.due(now) {
+		if err := s.rotate(now); err != nil {
+			s.logger.Error("File sink rotation failed", "error", err)
+		}
+	}
+}
+
+// due reports whether the current file has reached its size or age limit
+func (s *FileSink) due(now time.Time) bool {
+	return (s.opts.MaxBytes > 0 && s.size >= s.opts.MaxBytes) ||
+		(s.opts.RotateInterval > 0 && now.Sub(s.openedAt) >= s.opts.RotateInterval)
+}
+
+// sync forces written events to disk
+func (s *FileSink) sync(now time.Time) {
+	s.syncedAt = now
+	if !s.dirty {
+		return
+	}
+	s.dirty = false
+	if err := s.file.Sync(); err != nil {
+		s.logger.Error("File sink sync failed", "error", err)
+	}
+}
+
+// rotate closes the current file, if any, and starts a new one named after
+// the topic and the time it was opened
+func (s *FileSink) rotate(now time.Time) error {
+	name := fmt.Sprintf("%s-%s.ndjson", url.PathEscape(s.topic), now.UTC().Format("20060102T150405.000000000Z"))
+	file, err := os.OpenFile(filepath.Join(s.opts.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open file sink file: %w", err)
+	}
+	s.closeFile()
+	s.file, s.size, s.openedAt = file, 0, now
+	return nil
+}
+
+// closeFile syncs and closes the current file, unless the policy never
+// syncs
+func (s *FileSink) closeFile() {
+	if s.file == nil {
+		return
+	}
+	if s.opts.Fsync != FsyncNever {
+		s.sync(time.Now())
+	}
+	if err := s.file.Close(); err != nil {
+		s.logger.Error("File sink close failed", "file", s.file.Name(), "error", err)
+	}
+	s.file, s.dirty = nil, false
+}
@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"plivo/internal/pubsub"
+)
+
+// readEvents decodes every event written to the sink directory, oldest file
+// first, and returns them with the number of files
+func readEvents(t *testing.T, dir string) ([]pubsub.ServerMessage, int) {
+	t.Helper()
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	sort.Strings(names)
+
+	var events []pubsub.ServerMessage
+	for _, name := range names {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var event pubsub.ServerMessage
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("Line in %s is not JSON: %v", name, err)
+			}
+			events = append(events, event)
+		}
+		file.Close()
+	}
+	return events, len(names)
+}
+
+func TestFileSinkWritesEventsInOrderAndRotates(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	dir := t.TempDir()
+	fileSink, err := OpenFile(hub, "orders", FileOptions{
+		Dir:      dir,
+		MaxBytes: 512,
+		Fsync:    FsyncAlways,
+		Client:   pubsub.DefaultClientOptions(),
+	})
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer fileSink.Close()
+
+	const count = 20
+	for i := 1; i <= count; i++ {
+		message := &pubsub.PubSubMessage{Topic: "orders", Message: &pubsub.MessageData{ID: fmt.Sprintf("msg-%d", i), Payload: i}}
+		if _, err := hub.TryPublish(message, time.Second); err != nil {
+			t.Fatalf("TryPublish failed: %v", err)
+		}
+	}
+
+	var events []pubsub.ServerMessage
+	var files int
+	deadline := time.Now().Add(2 * time.Second)
+	for len(events) < count && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		events, files = readEvents(t, dir)
+	}
+
+	if len(events) != count {
+		t.Fatalf("Expected %d events written, got %d", count, len(events))
+	}
+	for i, event := range events {
+		if event.Type != pubsub.EventMessage || event.Sequence != int64(i+1) {
+			t.Fatalf("Expected event %d in sequence order, got %+v", i+1, event)
+		}
+	}
+	if files < 2 {
+		t.Errorf("Expected the 512-byte limit to rotate files, got %d", files)
+	}
+}
+
+func TestFileOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts FileOptions
+	}{
+		{"no directory", FileOptions{}},
+		{"negative size", FileOptions{Dir: "sink", MaxBytes: -1}},
+		{"unknown fsync", FileOptions{Dir: "sink", Fsync: "sometimes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
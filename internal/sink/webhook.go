@@ -0,0 +1,157 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+)
+
+// defaultWebhookTimeout bounds each webhook request unless Timeout is set
+const defaultWebhookTimeout = 5 * time.Second
+
+// WebhookOptions configures a webhook sink
+type WebhookOptions struct {
+	// URL receives each event as an HTTP POST
+	URL string
+	// Timeout bounds each request (0 = 5s)
+	Timeout time.Duration
+	// Client sets the sink's send queue and replay pacing
+	Client pubsub.ClientOptions
+}
+
+// Validate checks that the URL is an absolute http or https URL
+func (o WebhookOptions) Validate() error {
+	parsed, err := url.Parse(o.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("webhook URL must be an absolute http or https URL")
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("webhook timeout must not be negative")
+	}
+	return nil
+}
+
+// WebhookSink POSTs each of a topic's events, as the JSON event frame
+// subscribers get, to a URL. Events are sent one at a time in topic
+// sequence order; one the receiver fails or refuses is logged and not
+// retried. Like any subscriber, a sink that falls a full queue behind is
+// disconnected as a slow consumer and stops.
+type WebhookSink struct {
+	topic  string
+	opts   WebhookOptions
+	client *http.Client
+	stream *pubsub.Stream
+	logger *slog.Logger
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// OpenWebhook starts a webhook sink for a topic, sending its live events
+// from now on. The sink must be closed with Close.
+func OpenWebhook(hub *pubsub.Hub, topic string, opts WebhookOptions) (*WebhookSink, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultWebhookTimeout
+	}
+
+	var start pubsub.StreamOptions
+	if stats, err := hub.GetTopicStats(topic); err == nil {
+		start.AfterSequence = stats.Sequence
+	}
+	stream, err := hub.OpenStream("webhook-sink:"+topic, topic, start, opts.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &WebhookSink{
+		topic:  topic,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		stream: stream,
+		// Webhook URLs often carry a token, so only the host is logged
+		logger: slog.Default().With(logging.Topic, topic, "webhook_host", webhookHost(opts.URL)),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Close stops the sink once the events already queued for it are sent
+func (s *WebhookSink) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// run sends the stream's events until the sink is closed or the stream
+// ends
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	defer s.stream.Close()
+
+	for {
+		select {
+		case <-s.stream.Ready():
+			if closed := s.drain(); closed {
+				s.logger.Warn("Webhook sink stopped: its stream was closed")
+				return
+			}
+		case <-s.stop:
+			s.drain()
+			return
+		}
+	}
+}
+
+// drain sends the events waiting on the stream and reports whether the
+// stream has closed. Info and error frames are not events and are skipped.
+func (s *WebhookSink) drain() bool {
+	frames, closed := s.stream.Next()
+	for _, frame := range frames {
+		if frame.Sequence == 0 {
+			continue
+		}
+		if err := s.send(frame.Data); err != nil {
+			s.logger.Error("Webhook sink lost event", "sequence", frame.Sequence, "error", err)
+		}
+	}
+	return closed
+}
+
+// send POSTs one event, failing on anything but a 2xx response
+func (s *WebhookSink) send(data []byte) error {
+	resp, err := s.client.Post(s.opts.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		// Leave out the URL the error names, which may carry a token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookHost returns the host a webhook URL points at
+func webhookHost(webhookURL string) string {
+	if parsed, err := url.Parse(webhookURL); err == nil {
+		return parsed.Host
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"plivo/internal/pubsub"
+)
+
+func TestWebhookSinkPostsEvents(t *testing.T) {
+	hub := pubsub.NewHub()
+	go hub.Run()
+	defer hub.Shutdown()
+	hub.CreateTopic("orders")
+
+	var mu sync.Mutex
+	var received []pubsub.ServerMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event pubsub.ServerMessage
+		if err := json.Unmarshal(body, &event); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON event, got %q: %v", body, err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+		// A receiver's failure is logged and the next event still sent
+		if event.Message != nil && event.Message.ID == "msg-1" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	webhook, err := OpenWebhook(hub, "orders", WebhookOptions{URL: server.URL, Client: pubsub.DefaultClientOptions()})
+	if err != nil {
+		t.Fatalf("OpenWebhook failed: %v", err)
+	}
+	for _, id := range []string{"msg-1", "msg-2"} {
+		if _, err := hub.TryPublish(&pubsub.PubSubMessage{Topic: "orders", Message: &pubsub.MessageData{ID: id}}, time.Second); err != nil {
+			t.Fatalf("TryPublish failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		count := len(received)
+		mu.Unlock()
+		if count == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	webhook.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0].Sequence != 1 || received[1].Message.ID != "msg-2" {
+		t.Errorf("Expected both events in order, got %+v", received)
+	}
+}
+
+func TestWebhookOptionsValidate(t *testing.T) {
+	for _, bad := range []string{"", "example.com/hook", "ftp://example.com/hook", "http://"} {
+		if err := (WebhookOptions{URL: bad}).Validate(); err == nil {
+			t.Errorf("Expected %q refused", bad)
+		}
+	}
+	if err := (WebhookOptions{URL: "https://hooks.example.com/T0/abc"}).Validate(); err != nil {
+		t.Errorf("Expected an https URL accepted, got %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package soak
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"plivo/internal/audit"
+	"plivo/internal/pubsub"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Options configures a soak run
+type Options struct {
+	// BaseURL of the broker under test, e.g. http://127.0.0.1:8080
+	BaseURL string
+	// APIKey sent as X-API-Key on REST and WebSocket requests
+	APIKey string
+	// Hub is the in-process hub behind BaseURL, inspected for invariants
+	Hub *pubsub.Hub
+	// Duration of the churn phase
+	Duration time.Duration
+	// Workers is the number of concurrent connection churn workers
+	Workers int
+	// Topics is the size of the topic pool that is churned
+	Topics int
+	// MaxHeapBytes fails the run if the heap grows beyond it (0 = no ceiling)
+	MaxHeapBytes uint64
+	// ReportInterval is how often progress is logged
+	ReportInterval time.Duration
+}
+
+// goroutineSlack is how many goroutines above the baseline are tolerated
+// after teardown, for runtime and net/http housekeeping
+const goroutineSlack = 10
+
+// settleTimeout bounds how long teardown may take to reach a steady state
+const settleTimeout = 10 * time.Second
+
+// Report summarizes a soak run
+type Report struct {
+	Duration       time.Duration        `json:"duration"`
+	Connections    int64                `json:"connections"`
+	Subscriptions  int64                `json:"subscriptions"`
+	Publishes      int64                `json:"publishes"`
+	EventsReceived int64                `json:"events_received"`
+	TopicsCreated  int64                `json:"topics_created"`
+	TopicsDeleted  int64                `json:"topics_deleted"`
+	Errors         int64                `json:"errors"`
+	Ordering       audit.OrderingReport `json:"ordering"`
+	// Goroutines before the run and after teardown
+	BaselineGoroutines int `json:"baseline_goroutines"`
+	FinalGoroutines    int `json:"final_goroutines"`
+	// PeakHeapBytes is the largest heap observed during the run
+	PeakHeapBytes uint64 `json:"peak_heap_bytes"`
+	// Failures lists every violated invariant
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Passed reports whether every invariant held
+func (r *Report) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Print writes a human-readable report
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Soak report (%s)\n", r.Duration.Round(time.Second))
+	fmt.Fprintf(w, "  connections:      %d\n", r.Connections)
+	fmt.Fprintf(w, "  subscriptions:    %d\n", r.Subscriptions)
+	fmt.Fprintf(w, "  publishes:        %d\n", r.Publishes)
+	fmt.Fprintf(w, "  events received:  %d\n", r.EventsReceived)
+	fmt.Fprintf(w, "  topics created:   %d\n", r.TopicsCreated)
+	fmt.Fprintf(w, "  topics deleted:   %d\n", r.TopicsDeleted)
+	fmt.Fprintf(w, "  client errors:    %d\n", r.Errors)
+	fmt.Fprintf(w, "  audited events:   %d (gaps %d, violations %d)\n", r.Ordering.Events, r.Ordering.Gaps, len(r.Ordering.Violations))
+	fmt.Fprintf(w, "  goroutines:       %d -> %d\n", r.BaselineGoroutines, r.FinalGoroutines)
+	fmt.Fprintf(w, "  peak heap:        %.1f MB\n", float64(r.PeakHeapBytes)/(1024*1024))
+
+	if r.Passed() {
+		fmt.Fprintln(w, "Result: PASS")
+		return
+	}
+	fmt.Fprintf(w, "Result: FAIL (%d invariant violations)\n", len(r.Failures))
+	for _, failure := range r.Failures {
+		fmt.Fprintf(w, "  - %s\n", failure)
+	}
+}
+
+// soaker holds state for a single soak run
+type soaker struct {
+	opts   Options
+	topics []string
+	http   *http.Client
+
+	connections    atomic.Int64
+	subscriptions  atomic.Int64
+	publishes      atomic.Int64
+	eventsReceived atomic.Int64
+	topicsCreated  atomic.Int64
+	topicsDeleted  atomic.Int64
+	errors         atomic.Int64
+	peakHeap       atomic.Uint64
+
+	mu       sync.Mutex
+	ordering audit.OrderingReport
+}
+
+// Run churns connections, subscriptions, topics and publishes against the
+// broker for the configured duration, then tears everything down and checks
+// invariants: no leaked goroutines or clients, consistent stats, in-order
+// delivery and a bounded heap. Violations are listed in the report.
+func Run(opts Options) *Report {
+	if opts.Workers <= 0 {
+		opts.Workers = 16
+	}
+	if opts.Topics <= 0 {
+		opts.Topics = 8
+	}
+	if opts.ReportInterval <= 0 {
+		opts.ReportInterval = 30 * time.Second
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	s := &soaker{
+		opts: opts,
+		// Keep-alive connections would show up as goroutines after teardown
+		http: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{DisableKeepAlives: true},
+		},
+	}
+
+	report := &Report{BaselineGoroutines: runtime.NumGoroutine()}
+
+	runID := uuid.New().String()[:8]
+	for i := 0; i < opts.Topics; i++ {
+		topic := fmt.Sprintf("soak-%s-%d", runID, i)
+		s.topics = append(s.topics, topic)
+		s.createTopic(topic)
+	}
+
+	start := time.Now()
+	deadline := start.Add(opts.Duration)
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(2)
+	go s.sampleHeap(stop, &background)
+	go s.logProgress(start, stop, &background)
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		workers.Add(1)
+		go func(seed int64) {
+			defer workers.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for time.Now().Before(deadline) {
+				s.session(rng, deadline)
+			}
+		}(start.UnixNano() + int64(i))
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		s.churnTopics(rand.New(rand.NewSource(start.UnixNano())), deadline)
+	}()
+
+	workers.Wait()
+	close(stop)
+	background.Wait()
+
+	for _, topic := range s.topics {
+		s.deleteTopic(topic)
+	}
+
+	report.Duration = time.Since(start)
+	report.Connections = s.connections.Load()
+	report.Subscriptions = s.subscriptions.Load()
+	report.Publishes = s.publishes.Load()
+	report.EventsReceived = s.eventsReceived.Load()
+	report.TopicsCreated = s.topicsCreated.Load()
+	report.TopicsDeleted = s.topicsDeleted.Load()
+	report.Errors = s.errors.Load()
+	report.PeakHeapBytes = s.peakHeap.Load()
+	report.Ordering = s.ordering
+
+	s.checkInvariants(report)
+	return report
+}
+
+// session runs one connection's lifetime: subscribe to a few topics, publish
+// a burst while reading events, maybe unsubscribe, then disconnect
+func (s *soaker) session(rng *rand.Rand, deadline time.Time) {
+	conn, err := s.dial()
+	if err != nil {
+		s.errors.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return
+	}
+	s.connections.Add(1)
+
+	verifier := audit.NewOrderingVerifier()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var msg pubsub.ServerMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == pubsub.EventMessage {
+				s.eventsReceived.Add(1)
+			}
+			if err := verifier.Observe(&msg); err != nil {
+				log.Printf("soak: ORDERING VIOLATION: %v", err)
+			}
+		}
+	}()
+
+	clientID := "soak-" + uuid.New().String()[:8]
+	subscribed := s.pick(rng, 1+rng.Intn(3))
+	for _, topic := range subscribed {
+		if err := conn.WriteJSON(pubsub.ClientMessage{Type: pubsub.SubscribeMessage, Topic: topic, ClientID: clientID}); err != nil {
+			s.errors.Add(1)
+			break
+		}
+		s.subscriptions.Add(1)
+	}
+
+	for i, n := 0, rng.Intn(50); i < n && time.Now().Before(deadline); i++ {
+		err := conn.WriteJSON(pubsub.ClientMessage{
+			Type:    pubsub.PublishMessage,
+			Topic:   s.topics[rng.Intn(len(s.topics))],
+			Message: &pubsub.MessageData{ID: uuid.New().String(), Payload: map[string]interface{}{"n": i}},
+		})
+		if err != nil {
+			s.errors.Add(1)
+			break
+		}
+		s.publishes.Add(1)
+		time.Sleep(time.Duration(rng.Intn(5)) * time.Millisecond)
+	}
+
+	if rng.Intn(2) == 0 {
+		conn.WriteJSON(pubsub.ClientMessage{Type: pubsub.UnsubscribeMessage, Topic: subscribed[0], ClientID: clientID})
+	}
+
+	time.Sleep(time.Duration(rng.Intn(200)) * time.Millisecond)
+	conn.Close()
+	<-done
+
+	s.mergeOrdering(verifier.Report())
+}
+
+// churnTopics repeatedly deletes and re-creates topics from the pool
+func (s *soaker) churnTopics(rng *rand.Rand, deadline time.Time) {
+	for time.Now().Before(deadline) {
+		topic := s.topics[rng.Intn(len(s.topics))]
+		if rng.Intn(2) == 0 {
+			s.deleteTopic(topic)
+		} else {
+			s.createTopic(topic)
+		}
+		time.Sleep(time.Duration(50+rng.Intn(100)) * time.Millisecond)
+	}
+}
+
+// pick returns n distinct topics from the pool
+func (s *soaker) pick(rng *rand.Rand, n int) []string {
+	if n > len(s.topics) {
+		n = len(s.topics)
+	}
+	picked := make([]string, 0, n)
+	for _, i := range rng.Perm(len(s.topics))[:n] {
+		picked = append(picked, s.topics[i])
+	}
+	return picked
+}
+
+// mergeOrdering folds a connection's ordering report into the run totals
+func (s *soaker) mergeOrdering(r audit.OrderingReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ordering.Events += r.Events
+	s.ordering.Gaps += r.Gaps
+	s.ordering.Violations = append(s.ordering.Violations, r.Violations...)
+}
+
+// sampleHeap tracks the peak heap size until stopped
+func (s *soaker) sampleHeap(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		if mem.HeapAlloc > s.peakHeap.Load() {
+			s.peakHeap.Store(mem.HeapAlloc)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logProgress logs run totals every report interval until stopped
+func (s *soaker) logProgress(start time.Time, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(s.opts.ReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			log.Printf("soak: %s elapsed, %d connections, %d publishes, %d events, %d goroutines, peak heap %.1f MB",
+				time.Since(start).Round(time.Second), s.connections.Load(), s.publishes.Load(),
+				s.eventsReceived.Load(), runtime.NumGoroutine(), float64(s.peakHeap.Load())/(1024*1024))
+		}
+	}
+}
+
+// checkInvariants waits for teardown to settle and records every violated
+// invariant in the report
+func (s *soaker) checkInvariants(report *Report) {
+	fail := func(format string, args ...interface{}) {
+		report.Failures = append(report.Failures, fmt.Sprintf(format, args...))
+	}
+
+	if s.opts.Hub != nil {
+		// Every connection is closed, so the hub must drain to no clients
+		settled := waitFor(settleTimeout, func() bool {
+			return s.opts.Hub.GetStats().TotalClients == 0
+		})
+		if !settled {
+			fail("hub still has %d clients after teardown", s.opts.Hub.GetStats().TotalClients)
+		}
+
+		// ...and every client's pumps must exit
+		waitFor(settleTimeout, func() bool {
+			return s.opts.Hub.GetRuntimeStats().ActivePumps == 0
+		})
+		if rt := s.opts.Hub.GetRuntimeStats(); rt.ActivePumps != 0 || rt.DepartingClients != 0 {
+			fail("%d pumps still running for %d departed clients after teardown", rt.ActivePumps, rt.DepartingClients)
+		}
+
+		stats := s.opts.Hub.GetStats()
+		if stats.TotalTopics != len(stats.Topics) {
+			fail("stats report %d topics but %d exist", stats.TotalTopics, len(stats.Topics))
+		}
+		if stats.ActiveTopics != 0 {
+			fail("stats report %d active topics after teardown", stats.ActiveTopics)
+		}
+		for name, topic := range stats.Topics {
+			if topic.SubscriberCount != 0 {
+				fail("topic %s reports %d subscribers after teardown", name, topic.SubscriberCount)
+			}
+		}
+		if stats.OrderingViolations > 0 {
+			fail("%d ordering violations detected by the hub", stats.OrderingViolations)
+		}
+	}
+
+	// Client pumps and helpers must all have exited
+	waitFor(settleTimeout, func() bool {
+		return runtime.NumGoroutine() <= report.BaselineGoroutines+goroutineSlack
+	})
+	report.FinalGoroutines = runtime.NumGoroutine()
+	if report.FinalGoroutines > report.BaselineGoroutines+goroutineSlack {
+		fail("goroutine leak: %d before, %d after teardown", report.BaselineGoroutines, report.FinalGoroutines)
+	}
+
+	if s.opts.MaxHeapBytes > 0 && report.PeakHeapBytes > s.opts.MaxHeapBytes {
+		fail("peak heap %d bytes exceeds ceiling of %d bytes", report.PeakHeapBytes, s.opts.MaxHeapBytes)
+	}
+
+	if n := len(report.Ordering.Violations); n > 0 {
+		fail("%d ordering violations observed by subscribers", n)
+	}
+}
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// createTopic creates a pool topic; conflicts are expected while churning
+func (s *soaker) createTopic(topic string) {
+	body, _ := json.Marshal(map[string]string{"name": topic})
+	req, err := http.NewRequest("POST", s.opts.BaseURL+"/topics", bytes.NewReader(body))
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.do(req) == http.StatusCreated {
+		s.topicsCreated.Add(1)
+	}
+}
+
+// deleteTopic deletes a pool topic; misses are expected while churning
+func (s *soaker) deleteTopic(topic string) {
+	req, err := http.NewRequest("DELETE", s.opts.BaseURL+"/topics/"+url.PathEscape(topic), nil)
+	if err != nil {
+		s.errors.Add(1)
+		return
+	}
+
+	if s.do(req) == http.StatusOK {
+		s.topicsDeleted.Add(1)
+	}
+}
+
+// do sends an authenticated REST request and returns the status code
+func (s *soaker) do(req *http.Request) int {
+	if s.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", s.opts.APIKey)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		s.errors.Add(1)
+		return 0
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+// dial opens a WebSocket connection to the broker
+func (s *soaker) dial() (*websocket.Conn, error) {
+	wsURL := "ws" + strings.TrimPrefix(s.opts.BaseURL, "http") + "/ws"
+
+	header := http.Header{}
+	if s.opts.APIKey != "" {
+		header.Set("X-API-Key", s.opts.APIKey)
+	}
+
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.Dial(wsURL, header)
+	return conn, err
+}
@@ -0,0 +1,78 @@
+package soak
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/handlers"
+	"plivo/internal/pubsub"
+
+	"github.com/gorilla/mux"
+)
+
+func newTestServer() (*httptest.Server, *pubsub.Hub) {
+	cfg := config.NewTestConfig()
+	hub := pubsub.NewHubWithOptions(pubsub.HubOptions{PublishBuffer: 1024, OrderingAudit: true})
+	go hub.Run()
+
+	restHandler := handlers.NewRESTHandler(hub, cfg, auth.MustNewService(cfg.Security))
+
+	r := mux.NewRouter()
+	r.HandleFunc("/ws", handlers.NewWebSocketHandler(hub, cfg, auth.MustNewService(cfg.Security)).HandleWebSocket)
+	r.HandleFunc("/topics", restHandler.CreateTopic).Methods("POST")
+	r.HandleFunc("/topics/{topic}", restHandler.DeleteTopic).Methods("DELETE")
+
+	return httptest.NewServer(r), hub
+}
+
+func TestRunShortSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak run skipped in short mode")
+	}
+
+	server, hub := newTestServer()
+	defer server.Close()
+	defer hub.Shutdown()
+
+	report := Run(Options{
+		BaseURL:  server.URL,
+		Hub:      hub,
+		Duration: 2 * time.Second,
+		Workers:  4,
+		Topics:   3,
+	})
+
+	if !report.Passed() {
+		var out bytes.Buffer
+		report.Print(&out)
+		t.Fatalf("Expected soak to pass:\n%s", out.String())
+	}
+	if report.Connections == 0 || report.Publishes == 0 {
+		t.Errorf("Expected churn, got %d connections and %d publishes", report.Connections, report.Publishes)
+	}
+	if report.TopicsCreated == 0 {
+		t.Error("Expected topics to be created")
+	}
+	if len(hub.GetTopics()) != 0 {
+		t.Errorf("Expected the topic pool to be cleaned up, got %d topics", len(hub.GetTopics()))
+	}
+}
+
+func TestReportPrintListsFailures(t *testing.T) {
+	report := &Report{Failures: []string{"goroutine leak: 10 before, 50 after teardown"}}
+
+	var out bytes.Buffer
+	report.Print(&out)
+
+	if report.Passed() {
+		t.Error("Expected a report with failures not to pass")
+	}
+	if !strings.Contains(out.String(), "Result: FAIL") || !strings.Contains(out.String(), "goroutine leak") {
+		t.Errorf("Expected failures in output, got:\n%s", out.String())
+	}
+}
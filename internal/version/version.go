@@ -0,0 +1,35 @@
+package version
+
+import "runtime"
+
+// Build information, injected at build time via ldflags, e.g.
+//
+//	go build -ldflags "-X plivo/internal/version.Version=1.2.0 -X plivo/internal/version.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version   = "1.1.0-dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String returns a one-line summary of the build
+func (i Info) String() string {
+	return "v" + i.Version + " (commit " + i.Commit + ", built " + i.BuildDate + ", " + i.GoVersion + ")"
+}
@@ -0,0 +1,30 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	info := Get()
+
+	if info.Version != Version {
+		t.Errorf("Expected version %s, got %s", Version, info.Version)
+	}
+
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	info := Info{Version: "1.2.3", Commit: "abc123", BuildDate: "2025-01-15", GoVersion: "go1.21"}
+
+	s := info.String()
+	for _, part := range []string{"v1.2.3", "abc123", "2025-01-15", "go1.21"} {
+		if !strings.Contains(s, part) {
+			t.Errorf("Expected %q in %q", part, s)
+		}
+	}
+}
@@ -2,18 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"plivo/docs"
+	"plivo/internal/auth"
+	"plivo/internal/cluster"
 	"plivo/internal/config"
+	"plivo/internal/grpc"
 	"plivo/internal/handlers"
+	"plivo/internal/logging"
+	"plivo/internal/mqtt"
 	"plivo/internal/pubsub"
+	"plivo/internal/sink"
+	"plivo/internal/version"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 // @title Plivo Pub/Sub System API
@@ -35,47 +47,95 @@ import (
 // @in header
 // @name X-API-Key
 
+// @securityDefinitions.apikey AdminKeyAuth
+// @in header
+// @name X-Admin-Key
+
 func main() {
-	// Load configuration from command-line flags and environment variables
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "soak" {
+		os.Exit(runSoak(os.Args[2:]))
+	}
+
+	// Load configuration from the config file, environment variables and flags
 	cfg := config.LoadConfig()
 
-	log.Printf("Starting Plivo Pub/Sub System with configuration:")
-	log.Printf("  Server Port: %s", cfg.Server.Port)
-	log.Printf("  Max Queue Size: %d", cfg.PubSub.MaxQueueSize)
-	log.Printf("  Ring Buffer Size: %d", cfg.PubSub.RingBufferSize)
-	log.Printf("  API Key Required: %t", cfg.Security.APIKey != "")
-	log.Printf("  CORS Enabled: %t", cfg.Security.EnableCORS)
-	log.Printf("  Log Level: %s", cfg.Logging.Level)
+	// Everything logged from here on honors the configured level and format
+	if err := logging.Setup(cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+
+	build := version.Get()
+	slog.Info("Starting Plivo Pub/Sub System",
+		"version", build.Version,
+		"commit", build.Commit,
+		"build_date", build.BuildDate,
+		"go_version", build.GoVersion,
+		"port", cfg.Server.Port,
+		"grpc_port", cfg.Server.GRPCPort,
+		"mqtt_port", cfg.Server.MQTTPort,
+		"max_queue_size", cfg.PubSub.MaxQueueSize,
+		"ring_buffer_size", cfg.PubSub.RingBufferSize,
+		"api_key_required", cfg.Security.APIKey != "",
+		"cors_enabled", cfg.Security.EnableCORS,
+		"docs_enabled", cfg.Docs.Enabled,
+		"log_level", cfg.Logging.Level,
+		"log_format", cfg.Logging.Format,
+	)
+
+	if err := pubsub.NewClientOptions(cfg.PubSub).Validate(); err != nil {
+		fatal("Invalid WebSocket timing configuration", "error", err)
+	}
+	if err := pubsub.NewRateLimit(cfg.Security).Validate(); err != nil {
+		fatal("Invalid rate limit configuration", "error", err)
+	}
+
+	// One auth service checks keys for every transport
+	authService, err := auth.NewService(cfg.Security)
+	if err != nil {
+		fatal("Invalid security configuration", "error", err)
+	}
+
+	hubOpts := pubsub.NewHubOptions(cfg.PubSub)
+	hubOpts.NodeID = cfg.Cluster.NodeID
+	hubOpts.TenantTopicLimit = cfg.Security.TenantMaxTopics
+	if err := hubOpts.Validate(); err != nil {
+		fatal("Invalid hub channel configuration", "error", err)
+	}
+
+	tlsConfig, err := serverTLS(cfg.Server)
+	if err != nil {
+		fatal("Invalid TLS configuration", "error", err)
+	}
 
 	// Initialize the hub
-	hub := pubsub.NewHub()
-	go hub.Run()
+	hub := pubsub.NewHubWithOptions(hubOpts)
 
-	// Initialize handlers with configuration
-	wsHandler := handlers.NewWebSocketHandler(hub, cfg)
-	restHandler := handlers.NewRESTHandler(hub, cfg)
+	// Recover persisted topics before the hub starts serving
+	if cfg.PubSub.DataDir != "" {
+		recoverFromStorage(hub, cfg.PubSub.DataDir)
+	}
+	go hub.Run()
 
-	// Setup routes
-	r := mux.NewRouter()
+	// Copy retained history from a peer before accepting subscribers
+	if cfg.Cluster.WarmFrom != "" {
+		warmFromPeer(hub, cfg)
+	}
 
-	// WebSocket endpoint
-	r.HandleFunc("/ws", wsHandler.HandleWebSocket)
+	// Create declared topics before accepting publishes to them
+	if cfg.PubSub.TopicsFile != "" {
+		preloadTopics(hub, cfg.PubSub.TopicsFile)
+	}
 
-	// REST API endpoints
-	r.HandleFunc("/topics", restHandler.CreateTopic).Methods("POST")
-	r.HandleFunc("/topics", restHandler.ListTopics).Methods("GET")
-	r.HandleFunc("/topics/{topic}", restHandler.DeleteTopic).Methods("DELETE")
-	r.HandleFunc("/health", restHandler.Health).Methods("GET")
-	r.HandleFunc("/stats", restHandler.Stats).Methods("GET")
+	// Tap topics into files before accepting publishes
+	stopSinks := startSinks(hub, cfg)
 
-	// Swagger documentation
-	r.HandleFunc("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(docs.SwaggerInfo.ReadDoc()))
-	}).Methods("GET")
-	r.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"), // The url pointing to API definition
-	))
+	// Setup routes, and reload their settings on SIGHUP
+	r, reloads := newRouter(hub, cfg, authService)
+	watchReloads(reloads)
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -87,19 +147,38 @@ func main() {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server starting on :%s", cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+		slog.Info("Server starting", "port", cfg.Server.Port,
+			"tls", tlsConfig != nil, "client_certs", cfg.Server.TLSClientCA != "")
+		serve := server.ListenAndServe
+		if tlsConfig != nil {
+			// The certificate is already loaded into TLSConfig
+			serve = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := serve(); err != nil && err != http.ErrServerClosed {
+			fatal("Server failed to start", "error", err)
 		}
 	}()
 
+	// Serve the gRPC API on its own port when configured
+	stopGRPC := func(context.Context) {}
+	if cfg.Server.GRPCPort != "" {
+		stopGRPC = startGRPC(hub, cfg, authService)
+	}
+
+	// Serve MQTT clients on their own port when configured
+	stopMQTT := func() {}
+	if cfg.Server.MQTTPort != "" {
+		stopMQTT = startMQTT(hub, cfg, authService)
+	}
+
 	// Wait for shutdown signal
 	<-sigChan
-	log.Println("Shutdown signal received, starting graceful shutdown...")
+	slog.Info("Shutdown signal received, starting graceful shutdown")
 
 	// Shutdown hub first
 	hub.Shutdown()
@@ -108,8 +187,314 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		slog.Error("Server shutdown failed", "error", err)
+	}
+	stopGRPC(ctx)
+	stopMQTT()
+	stopSinks()
+
+	if err := hub.CloseStorage(); err != nil {
+		slog.Error("Storage close failed", "error", err)
+	}
+
+	slog.Info("Server shutdown complete")
+}
+
+// fatal logs a startup failure and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// serverTLS loads the certificate the HTTP server serves TLS with and the
+// CAs it verifies client certificates against. It returns nil to serve
+// plain HTTP.
+func serverTLS(cfg config.ServerConfig) (*tls.Config, error) {
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		if cfg.TLSClientCA != "" {
+			return nil, errors.New("-tls-client-ca requires -tls-cert and -tls-key")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return nil, errors.New("-tls-cert and -tls-key must be set together")
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCA != "" {
+		bundle, err := os.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates in client CA %s", cfg.TLSClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// recoverFromStorage restores topics and retained messages from the
+// write-ahead log in dir and persists later changes to it
+func recoverFromStorage(hub *pubsub.Hub, dir string) {
+	storage, err := pubsub.OpenFileStorage(dir)
+	if err != nil {
+		fatal("Failed to open storage", "dir", dir, "error", err)
+	}
+	result, err := hub.Recover(storage)
+	if err != nil {
+		fatal("Failed to recover from storage", "dir", dir, "error", err)
+	}
+	slog.Info("Recovered from storage", "dir", dir,
+		"topics", result.Topics, "messages", result.Messages, "skipped", len(result.Skipped))
+}
+
+// preloadTopics creates the topics declared in path that recovery and
+// warming didn't already bring back
+func preloadTopics(hub *pubsub.Hub, path string) {
+	declarations, err := pubsub.LoadTopicDeclarations(path)
+	if err != nil {
+		fatal("Failed to read topics file", "path", path, "error", err)
+	}
+	result, err := hub.PreloadTopics(declarations)
+	if err != nil {
+		fatal("Failed to preload topics", "path", path, "error", err)
+	}
+	slog.Info("Preloaded topics", "path", path,
+		"created", result.Created, "existing", len(result.Existing))
+}
+
+// warmFromPeer restores topics and retained messages from the configured
+// peer. Failure is logged and the node starts cold rather than not at all.
+func warmFromPeer(hub *pubsub.Hub, cfg *config.Config) {
+	adminKey := cfg.Security.AdminKey
+	if adminKey == "" {
+		adminKey = cfg.Security.APIKey
+	}
+
+	result, err := cluster.Warm(hub, cluster.WarmOptions{
+		Peer:     cfg.Cluster.WarmFrom,
+		AdminKey: adminKey,
+		Timeout:  cfg.Cluster.WarmTimeout,
+	})
+	if err != nil {
+		slog.Warn("Warm start failed, starting cold", "peer", cfg.Cluster.WarmFrom, "error", err)
+		return
+	}
+	slog.Info("Warmed from peer", "peer", cfg.Cluster.WarmFrom,
+		"topics", result.Topics, "messages", result.Messages, "skipped", len(result.Skipped))
+}
+
+// startSinks opens the configured file sinks and alert webhook and returns
+// a func that closes them once the events already queued for them are
+// written
+func startSinks(hub *pubsub.Hub, cfg *config.Config) func() {
+	files, err := cfg.Sinks.Files()
+	if err != nil {
+		fatal("Invalid sink configuration", "error", err)
+	}
+
+	var sinks []*sink.FileSink
+	for topic, dir := range files {
+		fileSink, err := sink.OpenFile(hub, topic, sink.FileOptions{
+			Dir:            dir,
+			MaxBytes:       cfg.Sinks.MaxBytes,
+			RotateInterval: cfg.Sinks.RotateInterval,
+			Fsync:          sink.FsyncPolicy(cfg.Sinks.Fsync),
+			FsyncInterval:  cfg.Sinks.FsyncInterval,
+			Client:         pubsub.NewClientOptions(cfg.PubSub),
+		})
+		if err != nil {
+			fatal("Failed to open file sink", logging.Topic, topic, "error", err)
+		}
+		slog.Info("File sink started", logging.Topic, topic, "dir", dir)
+		sinks = append(sinks, fileSink)
+	}
+
+	var alertHook *sink.WebhookSink
+	if cfg.PubSub.AlertWebhook != "" {
+		alertHook, err = sink.OpenWebhook(hub, pubsub.AlertsTopic, sink.WebhookOptions{
+			URL:    cfg.PubSub.AlertWebhook,
+			Client: pubsub.NewClientOptions(cfg.PubSub),
+		})
+		if err != nil {
+			fatal("Failed to open alert webhook", "error", err)
+		}
+		slog.Info("Alert webhook started")
+	}
+
+	return func() {
+		for _, fileSink := range sinks {
+			fileSink.Close()
+		}
+		if alertHook != nil {
+			alertHook.Close()
+		}
+	}
+}
+
+// startGRPC serves the gRPC API on the configured port and returns a func
+// that stops it, waiting for in-flight calls until ctx is done
+func startGRPC(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) func(ctx context.Context) {
+	listener, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
+	if err != nil {
+		fatal("gRPC server failed to listen", "error", err)
+	}
+	server := grpc.NewServer(hub, cfg, authService)
+
+	go func() {
+		slog.Info("gRPC server starting", "port", cfg.Server.GRPCPort)
+		if err := server.Serve(listener); err != nil {
+			fatal("gRPC server failed", "error", err)
+		}
+	}()
+
+	return func(ctx context.Context) {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Error("gRPC server shutdown failed", "error", ctx.Err())
+			server.Stop()
+		}
 	}
+}
+
+// topicPath matches a topic in REST paths: a plain name, or a namespaced
+// tenant/topic
+const topicPath = "/topics/{topic:[^/]+(?:/[^/]+)?}"
+
+// newRouter wires the WebSocket, REST and documentation routes. The
+// reloader it returns applies reloaded settings to them.
+func newRouter(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) (*mux.Router, *reloader) {
+	// Initialize handlers with configuration
+	wsHandler := handlers.NewWebSocketHandler(hub, cfg, authService)
+	restHandler := handlers.NewRESTHandler(hub, cfg, authService)
+	rateLimiter := handlers.NewRateLimiter(hub, pubsub.NewRateLimit(cfg.Security))
+
+	r := mux.NewRouter()
+	r.Use(handlers.RequestIDMiddleware())
+	r.Use(handlers.CORSMiddleware(cfg.Security))
+	// Outside the recovery and rate limiting, so operations that panic or
+	// are limited are still recorded, as failed
+	r.Use(handlers.ActivityMiddleware(restHandler.Activity(), authService, map[string]string{
+		"POST /topics":                                 handlers.ActivityTopicCreate,
+		"PATCH " + topicPath:                           handlers.ActivityTopicUpdate,
+		"DELETE " + topicPath:                          handlers.ActivityTopicDelete,
+		"POST " + topicPath + "/restore":               handlers.ActivityTopicRestore,
+		"POST " + topicPath + "/drain":                 handlers.ActivityTopicDrain,
+		"POST " + topicPath + "/transfer":              handlers.ActivityTopicTransfer,
+		"POST " + topicPath + "/replay":                handlers.ActivityTopicReplay,
+		"PUT " + topicPath + "/schema":                 handlers.ActivitySchemaPut,
+		"POST " + topicPath + "/groups/{group}/offset": handlers.ActivityGroupOffset,
+		"POST /pause":                                  handlers.ActivityPause,
+		"DELETE /pause":                                handlers.ActivityResume,
+		"PUT /acl":                                     handlers.ActivityACLPut,
+		"DELETE /acl":                                  handlers.ActivityACLDelete,
+	}))
+	r.Use(handlers.RecoverMiddleware(hub))
+	r.Use(rateLimiter.Middleware)
+	r.Use(handlers.TimeoutMiddleware(handlers.RouteTimeouts{
+		Default: cfg.Server.RequestTimeout,
+		Routes: map[string]time.Duration{
+			// WebSockets and event streams last as long as their clients
+			"/ws":                 0,
+			topicPath + "/events": 0,
+			// Exports copy every topic
+			"/cluster/snapshot": cfg.Server.ExportTimeout,
+		},
+	}))
 
-	log.Println("Server shutdown complete")
+	// WebSocket endpoint
+	r.HandleFunc("/ws", wsHandler.HandleWebSocket)
+
+	// REST API endpoints
+	r.HandleFunc("/topics", restHandler.CreateTopic).Methods("POST")
+	r.HandleFunc("/topics", restHandler.ListTopics).Methods("GET")
+	r.HandleFunc(topicPath+"/publish", restHandler.Publish).Methods("POST")
+	r.HandleFunc(topicPath+"/publish/batch", restHandler.PublishBatch).Methods("POST")
+	r.HandleFunc(topicPath+"/messages", restHandler.GetTopicMessages).Methods("GET")
+	r.HandleFunc(topicPath+"/events", restHandler.StreamEvents).Methods("GET")
+	r.HandleFunc(topicPath+"/metrics", restHandler.TopicMetrics).Methods("GET")
+	r.HandleFunc(topicPath+"/samples", restHandler.GetTopicSamples).Methods("GET")
+	r.HandleFunc(topicPath+"/drain", restHandler.DrainTopic).Methods("POST")
+	r.HandleFunc(topicPath+"/transfer", restHandler.TransferTopic).Methods("POST")
+	r.HandleFunc(topicPath+"/restore", restHandler.RestoreTopic).Methods("POST")
+	r.HandleFunc(topicPath+"/replay", restHandler.ReplayTopic).Methods("POST")
+	r.HandleFunc(topicPath+"/schema", restHandler.PutTopicSchema).Methods("PUT")
+	r.HandleFunc(topicPath+"/schema", restHandler.GetTopicSchema).Methods("GET")
+	r.HandleFunc(topicPath+"/state", restHandler.GetTopicState).Methods("GET")
+	r.HandleFunc(topicPath+"/state/{key}", restHandler.GetStateKey).Methods("GET")
+	r.HandleFunc(topicPath+"/state/{key}", restHandler.PutStateKey).Methods("PUT")
+	r.HandleFunc(topicPath+"/state/{key}", restHandler.DeleteStateKey).Methods("DELETE")
+	r.HandleFunc(topicPath+"/groups/{group}/offset", restHandler.GetGroupOffset).Methods("GET")
+	r.HandleFunc(topicPath+"/groups/{group}/offset", restHandler.SetGroupOffset).Methods("POST")
+	// After the routes above, so their suffixes aren't taken for namespaced
+	// topic names
+	r.HandleFunc(topicPath, restHandler.GetTopic).Methods("GET")
+	r.HandleFunc(topicPath, restHandler.UpdateTopic).Methods("PATCH")
+	r.HandleFunc(topicPath, restHandler.DeleteTopic).Methods("DELETE")
+	r.HandleFunc("/health", restHandler.Health).Methods("GET")
+	r.HandleFunc("/readyz", restHandler.Ready).Methods("GET")
+	r.HandleFunc("/stats", restHandler.Stats).Methods("GET")
+	r.HandleFunc("/clients/{id}", restHandler.GetClient).Methods("GET")
+	r.HandleFunc("/clients/{id}/inbox", restHandler.PublishInbox).Methods("POST")
+	r.HandleFunc("/version", restHandler.Version).Methods("GET")
+	r.HandleFunc("/client.js", handlers.ClientScript).Methods("GET")
+	r.HandleFunc("/cluster/snapshot", restHandler.Snapshot).Methods("GET")
+	r.HandleFunc("/pause", restHandler.GetPause).Methods("GET")
+	r.HandleFunc("/pause", restHandler.Pause).Methods("POST")
+	r.HandleFunc("/pause", restHandler.Resume).Methods("DELETE")
+	r.HandleFunc("/acl", restHandler.GetACL).Methods("GET")
+	r.HandleFunc("/acl", restHandler.PutACL).Methods("PUT")
+	r.HandleFunc("/acl", restHandler.DeleteACL).Methods("DELETE")
+	r.HandleFunc("/admin/activity", restHandler.GetActivity).Methods("GET")
+	r.HandleFunc("/admin/config", restHandler.GetConfig).Methods("GET")
+
+	// Match OPTIONS on every path so CORS preflights reach the middleware
+	r.MatcherFunc(handlers.IsOptions).HandlerFunc(handlers.Preflight)
+
+	// Swagger documentation, only when enabled
+	if cfg.Docs.Enabled {
+		r.PathPrefix("/swagger/").Handler(handlers.NewDocsHandler(cfg, authService)).Methods("GET")
+	}
+
+	return r, &reloader{hub: hub, auth: authService, rateLimit: rateLimiter, websocket: wsHandler, rest: restHandler, activity: restHandler.Activity(), current: cfg}
+}
+
+// startMQTT serves MQTT clients on the configured port and returns a func
+// that closes the listener and disconnects every client
+func startMQTT(hub *pubsub.Hub, cfg *config.Config, authService *auth.Service) func() {
+	listener, err := net.Listen("tcp", ":"+cfg.Server.MQTTPort)
+	if err != nil {
+		fatal("MQTT server failed to listen", "error", err)
+	}
+	server := mqtt.NewServer(hub, cfg, authService)
+
+	go func() {
+		slog.Info("MQTT server starting", "port", cfg.Server.MQTTPort)
+		if err := server.Serve(listener); err != nil {
+			fatal("MQTT server failed", "error", err)
+		}
+	}()
+
+	return func() {
+		if err := server.Close(); err != nil {
+			slog.Error("MQTT server shutdown failed", "error", err)
+		}
+	}
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"plivo/internal/auth"
+	"plivo/internal/config"
+	"plivo/internal/handlers"
+	"plivo/internal/logging"
+	"plivo/internal/pubsub"
+)
+
+// reloadable lists, by config file key, the settings a reload applies to
+// the running broker. Changes to the others are logged and wait for a
+// restart.
+var reloadable = map[string]bool{
+	"logging.level":               true,
+	"security.api_key":            true,
+	"security.admin_key":          true,
+	"security.tenant_keys":        true,
+	"security.rate_limit_per_min": true,
+	"security.rate_limit_burst":   true,
+	"pubsub.ring_buffer_size":     true,
+}
+
+// reloadableKeys lists the reloadable settings' keys
+func reloadableKeys() []string {
+	keys := make([]string, 0, len(reloadable))
+	for key := range reloadable {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// reloader applies the reloadable settings of a reloaded configuration to
+// the components that enforce them. It is not safe for concurrent use.
+type reloader struct {
+	hub       *pubsub.Hub
+	auth      *auth.Service
+	rateLimit *handlers.RateLimiter
+	websocket *handlers.WebSocketHandler
+	// rest reports the running configuration at /admin/config
+	rest *handlers.RESTHandler
+	// activity records each reload as an admin operation
+	activity *handlers.ActivityLog
+	// current is the configuration the broker runs with
+	current *config.Config
+}
+
+// watchReloads reloads the configuration each time the process gets SIGHUP
+func watchReloads(r *reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			next, err := config.Reload()
+			if err == nil {
+				err = r.apply(next)
+			}
+			if err != nil {
+				slog.Error("Config reload failed, keeping the running configuration", "error", err)
+			}
+			r.activity.Record(handlers.ActivityConfigReload, "sighup", "", err != nil)
+		}
+	}()
+}
+
+// apply switches the broker to next's reloadable settings, all of them or,
+// if any is invalid, none, and logs which settings changed
+func (r *reloader) apply(next *config.Config) error {
+	if _, err := logging.ParseLevel(next.Logging.Level); err != nil {
+		return err
+	}
+	limit := pubsub.NewRateLimit(next.Security)
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	if err := r.hub.SetRingBufferSize(next.PubSub.RingBufferSize); err != nil {
+		return err
+	}
+	if err := r.auth.SetKeys(next.Security); err != nil {
+		r.hub.SetRingBufferSize(r.current.PubSub.RingBufferSize)
+		return err
+	}
+	logging.SetLevel(next.Logging.Level)
+	// A new limit refills every caller's bucket, so it is only set when it
+	// changes
+	if limit != pubsub.NewRateLimit(r.current.Security) {
+		r.rateLimit.SetLimit(limit)
+		r.websocket.SetPublishRate(limit)
+	}
+
+	var applied, pending []string
+	for _, key := range config.Changed(r.current, next) {
+		if reloadable[key] {
+			applied = append(applied, key)
+		} else {
+			pending = append(pending, key)
+		}
+	}
+
+	running := *r.current
+	running.Logging.Level = next.Logging.Level
+	running.Security.APIKey = next.Security.APIKey
+	running.Security.AdminKey = next.Security.AdminKey
+	running.Security.TenantKeys = next.Security.TenantKeys
+	running.Security.RateLimitPerMin = next.Security.RateLimitPerMin
+	running.Security.RateLimitBurst = next.Security.RateLimitBurst
+	running.PubSub.RingBufferSize = next.PubSub.RingBufferSize
+	running.CopySources(next, reloadableKeys())
+	r.current = &running
+	r.rest.SetRunningConfig(r.current)
+
+	slog.Info("Reloaded configuration", "applied", applied)
+	if len(pending) > 0 {
+		slog.Warn("Changed settings take effect after a restart", "settings", pending)
+	}
+	return nil
+}